@@ -0,0 +1,94 @@
+package radikron
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bogem/id3v2"
+)
+
+// maxChapters is the most chapters a CTOC frame lists
+const maxChapters = 255
+
+// chapterEnds returns the end of each part of the segments between the discontinuity markers,
+// the offsets from the first segment, or nil if there is no break to split at
+func chapterEnds(segments []mediaSegment) []time.Duration {
+	var ends []time.Duration
+	var offset time.Duration
+	for _, s := range segments {
+		if s.discontinuity && offset > 0 && len(ends) < maxChapters-1 {
+			ends = append(ends, offset)
+		}
+		offset += s.duration
+	}
+	if len(ends) == 0 {
+		return nil
+	}
+	return append(ends, offset)
+}
+
+// chapterTitle returns the title of the i-th part of the recording
+func chapterTitle(i int) string {
+	return fmt.Sprintf("Part %d", i+1)
+}
+
+// addChapterFrames replaces the chapters of the tag with a CHAP frame for each part ending at the ends,
+// and a CTOC frame listing them in order
+func addChapterFrames(tag *id3v2.Tag, ends []time.Duration) {
+	tag.DeleteFrames("CHAP")
+	tag.DeleteFrames("CTOC")
+
+	var toc bytes.Buffer
+	toc.WriteString("toc\x00")
+	toc.WriteByte(0x03) // the top-level and ordered flags
+	toc.WriteByte(byte(len(ends)))
+	var start time.Duration
+	for i, end := range ends {
+		id := fmt.Sprintf("chp%d", i)
+		var chap bytes.Buffer
+		chap.WriteString(id + "\x00")
+		_ = binary.Write(&chap, binary.BigEndian, uint32(start.Milliseconds()))
+		_ = binary.Write(&chap, binary.BigEndian, uint32(end.Milliseconds()))
+		// no byte offsets, the times apply
+		_ = binary.Write(&chap, binary.BigEndian, uint32(0xFFFFFFFF))
+		_ = binary.Write(&chap, binary.BigEndian, uint32(0xFFFFFFFF))
+		// the title in ISO-8859-1, valid in both ID3v2.3 and ID3v2.4
+		chap.Write(id3SubFrame(tag.Version(), "TIT2", append([]byte{0}, chapterTitle(i)...)))
+		tag.AddFrame("CHAP", id3v2.UnknownFrame{Body: chap.Bytes()})
+		toc.WriteString(id + "\x00")
+		start = end
+	}
+	tag.AddFrame("CTOC", id3v2.UnknownFrame{Body: toc.Bytes()})
+}
+
+// id3SubFrame returns the frame embedded in a CHAP frame, with the frame header of the tag version
+func id3SubFrame(version byte, id string, body []byte) []byte {
+	size := uint32(len(body))
+	if version == 4 {
+		// synchsafe integer: 7 bits in each byte
+		size = size&0x7F | (size>>7&0x7F)<<8 | (size>>14&0x7F)<<16 | (size>>21&0x7F)<<24
+	}
+	var frame bytes.Buffer
+	frame.WriteString(id)
+	_ = binary.Write(&frame, binary.BigEndian, size)
+	frame.Write([]byte{0, 0}) // no flags
+	frame.Write(body)
+	return frame.Bytes()
+}
+
+// writeFFMetadataChapters writes the chapters ending at the ends to a file in the ffmetadata format for ffmpeg
+func writeFFMetadataChapters(path string, ends []time.Duration) error {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	var start time.Duration
+	for i, end := range ends {
+		fmt.Fprintf(&b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			start.Milliseconds(), end.Milliseconds(), chapterTitle(i))
+		start = end
+	}
+	return os.WriteFile(path, []byte(b.String()), FilePermissions)
+}
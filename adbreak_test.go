@@ -0,0 +1,163 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bogem/id3v2"
+)
+
+func TestGetMediaSegments_Discontinuity(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:5\n#EXT-X-MEDIA-SEQUENCE:1\n" +
+		"#EXTINF:5.0,\nhttps://example.com/1.aac\n" +
+		"#EXTINF:5.0,\nhttps://example.com/2.aac\n" +
+		"#EXT-X-DISCONTINUITY\n#EXTINF:2.5,\nhttps://example.com/3.aac\n" +
+		"#EXT-X-DISCONTINUITY\n#EXTINF:5.0,\nhttps://example.com/4.aac\n"
+	segments, err := getMediaSegments(strings.NewReader(playlist))
+	if err != nil {
+		t.Fatalf("getMediaSegments failed: %v", err)
+	}
+	if len(segments) != 4 {
+		t.Fatalf("expected 4 segments, got %d", len(segments))
+	}
+	if segments[0].discontinuity || !segments[2].discontinuity || !segments[3].discontinuity {
+		t.Errorf("unexpected discontinuities: %+v", segments)
+	}
+	if segments[2].duration != 2500*time.Millisecond {
+		t.Errorf("expected 2.5s, got %s", segments[2].duration)
+	}
+
+	want := []time.Duration{10 * time.Second, 12500 * time.Millisecond, 17500 * time.Millisecond}
+	if got := chapterEnds(segments); !reflect.DeepEqual(got, want) {
+		t.Errorf("chapterEnds() = %v, want %v", got, want)
+	}
+}
+
+func TestChapterEnds(t *testing.T) {
+	second := time.Second
+	tests := []struct {
+		name     string
+		segments []mediaSegment
+		want     []time.Duration
+	}{
+		{"no segments", nil, nil},
+		{"no breaks", []mediaSegment{{duration: second}, {duration: second}}, nil},
+		{"leading break", []mediaSegment{{duration: second, discontinuity: true}, {duration: second}}, nil},
+		{
+			"one break",
+			[]mediaSegment{{duration: second}, {duration: second, discontinuity: true}, {duration: second}},
+			[]time.Duration{second, 3 * second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chapterEnds(tt.segments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chapterEnds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	many := make([]mediaSegment, 2*maxChapters)
+	for i := range many {
+		many[i] = mediaSegment{duration: second, discontinuity: true}
+	}
+	if got := chapterEnds(many); len(got) != maxChapters || got[len(got)-1] != time.Duration(len(many))*second {
+		t.Errorf("expected %d chapters ending at the end, got %d", maxChapters, len(got))
+	}
+}
+
+func TestAddChapterFrames(t *testing.T) {
+	for _, version := range []byte{3, 4} {
+		path := filepath.Join(t.TempDir(), "test.aac")
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		tag, err := id3v2.Open(path, id3v2.Options{Parse: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag.SetVersion(version)
+		addChapterFrames(tag, []time.Duration{time.Minute, 90 * time.Second})
+		// adding again replaces the chapters
+		addChapterFrames(tag, []time.Duration{time.Minute, 2 * time.Minute})
+		if err := tag.Save(); err != nil {
+			t.Fatal(err)
+		}
+		tag.Close()
+
+		tag, err = id3v2.Open(path, id3v2.Options{Parse: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chaps := tag.GetFrames("CHAP")
+		tocs := tag.GetFrames("CTOC")
+		tag.Close()
+		if len(chaps) != 2 || len(tocs) != 1 {
+			t.Fatalf("v2.%d: expected 2 CHAP and 1 CTOC frames, got %d and %d", version, len(chaps), len(tocs))
+		}
+		second, ok := chaps[1].(id3v2.UnknownFrame)
+		if !ok {
+			t.Fatalf("v2.%d: unexpected frame %T", version, chaps[1])
+		}
+		// chp1, from 60000 ms to 120000 ms, titled Part 2
+		if !bytes.HasPrefix(second.Body, []byte("chp1\x00\x00\x00\xea\x60\x00\x01\xd4\xc0")) {
+			t.Errorf("v2.%d: unexpected CHAP frame %q", version, second.Body)
+		}
+		if !bytes.HasSuffix(second.Body, []byte("TIT2\x00\x00\x00\x07\x00\x00\x00Part 2")) {
+			t.Errorf("v2.%d: unexpected chapter title %q", version, second.Body)
+		}
+		toc := tocs[0].(id3v2.UnknownFrame)
+		if string(toc.Body) != "toc\x00\x03\x02chp0\x00chp1\x00" {
+			t.Errorf("v2.%d: unexpected CTOC frame %q", version, toc.Body)
+		}
+	}
+}
+
+func TestWriteM4ATags_Chapters(t *testing.T) {
+	tmpDir := t.TempDir()
+	argsFile := filepath.Join(tmpDir, "args")
+	asset := &Asset{FFmpegPath: fakeFFmpeg(t, argsFile)}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	output := newOutputConfigFromPath(tmpDir, "out", AudioFormatM4A)
+	if err := os.WriteFile(output.AbsPath(), []byte("m4a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	prog := &Prog{ID: "FMT-1", Title: "Test", Ft: "20230605130000", Chapters: []time.Duration{time.Minute, 2 * time.Minute}}
+	if err := writeM4ATags(ctx, output, prog); err != nil {
+		t.Fatalf("writeM4ATags failed: %v", err)
+	}
+	if _, err := os.Stat(output.AbsPath() + ".chapters"); !os.IsNotExist(err) {
+		t.Error("expected the chapters file to be removed")
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), ".chapters -map 0:a -map_chapters 1 -c copy") {
+		t.Errorf("expected the chapters to be mapped, got %s", args)
+	}
+}
+
+func TestWriteFFMetadataChapters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chapters")
+	if err := writeFFMetadataChapters(path, []time.Duration{time.Minute, 90 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ";FFMETADATA1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=60000\ntitle=Part 1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=60000\nEND=90000\ntitle=Part 2\n"
+	if string(data) != want {
+		t.Errorf("unexpected chapters:\n%s", data)
+	}
+}
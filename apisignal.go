@@ -0,0 +1,211 @@
+package radikron
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiSignalRetention is how long the history keeps the hourly counts of the radiko responses
+	apiSignalRetention = 30 * OneDay * time.Hour
+	// apiSignalFlushInterval is how often the counts are written to the history
+	apiSignalFlushInterval = time.Minute
+)
+
+// APISignalStats counts the responses of radiko in an hour with the download settings in effect,
+// so that being throttled or banned can be told apart from the network and correlated with the settings
+type APISignalStats struct {
+	Hour time.Time `json:"hour"`
+	// MaxDownloadingConcurrency and RequestsPerSecond are the limits in effect, including the throttle-schedule
+	MaxDownloadingConcurrency int     `json:"max-downloading-concurrency"`
+	RequestsPerSecond         float64 `json:"requests-per-second"`
+	Requests                  int     `json:"requests"`
+	// AuthRejected counts the auth and the login not answered 200, and Forbidden and TooManyRequests
+	// the other requests answered 403 and 429
+	Forbidden       int `json:"forbidden"`
+	TooManyRequests int `json:"too-many-requests"`
+	AuthRejected    int `json:"auth-rejected"`
+}
+
+// Throttled returns the number of the responses refusing the requests
+func (s APISignalStats) Throttled() int {
+	return s.Forbidden + s.TooManyRequests + s.AuthRejected
+}
+
+// add adds the counts of o
+func (s *APISignalStats) add(o APISignalStats) {
+	s.Requests += o.Requests
+	s.Forbidden += o.Forbidden
+	s.TooManyRequests += o.TooManyRequests
+	s.AuthRejected += o.AuthRejected
+}
+
+// sameBucket returns true if o counts the same hour with the same settings
+func (s APISignalStats) sameBucket(o APISignalStats) bool {
+	return s.Hour.Equal(o.Hour) &&
+		s.MaxDownloadingConcurrency == o.MaxDownloadingConcurrency &&
+		s.RequestsPerSecond == o.RequestsPerSecond
+}
+
+var (
+	// pendingSignals are the counts not written to the history yet, flushed by signalFlushTimer
+	pendingSignals   []APISignalStats
+	signalFlushTimer *time.Timer
+	apiSignalMu      sync.Mutex
+)
+
+// apiSignalTransport counts the responses of radiko passing through the shared transport
+type apiSignalTransport struct {
+	base http.RoundTripper
+}
+
+func (t apiSignalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && isRadikoHost(req.URL.Hostname()) {
+		recordAPISignal(req, resp.StatusCode)
+	}
+	return resp, err
+}
+
+// isRadikoHost returns true for the API and the stream servers of radiko
+func isRadikoHost(host string) bool {
+	return host == "radiko.jp" || strings.HasSuffix(host, ".radiko.jp") ||
+		strings.HasSuffix(host, ".smartstream.ne.jp")
+}
+
+// isAuthRequest returns true for the auth and the premium login requests
+func isAuthRequest(req *http.Request) bool {
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/v2/api/auth"):
+		return true
+	case req.URL.Path == "/v4/api/member/login":
+		return true
+	}
+	return false
+}
+
+// recordAPISignal counts the response to the request in the bucket of this hour and the limits in effect,
+// which are of the asset of the request, or of the last fetch
+func recordAPISignal(req *http.Request, statusCode int) {
+	now := time.Now()
+	asset := GetAsset(req.Context())
+	if asset == nil {
+		statusMu.Lock()
+		asset = statusAsset
+		statusMu.Unlock()
+	}
+	s := APISignalStats{Hour: now.In(Location).Truncate(time.Hour), Requests: 1}
+	if asset != nil {
+		s.MaxDownloadingConcurrency, s.RequestsPerSecond = asset.throttledLimits(now)
+	}
+	switch {
+	case statusCode != http.StatusOK && isAuthRequest(req):
+		s.AuthRejected = 1
+	case statusCode == http.StatusForbidden:
+		s.Forbidden = 1
+	case statusCode == http.StatusTooManyRequests:
+		s.TooManyRequests = 1
+	}
+
+	apiSignalMu.Lock()
+	defer apiSignalMu.Unlock()
+	pendingSignals = mergeAPISignals(pendingSignals, s)
+	if signalFlushTimer == nil {
+		signalFlushTimer = time.AfterFunc(apiSignalFlushInterval, func() {
+			if err := FlushAPISignals(); err != nil {
+				recordError(fmt.Sprintf("failed to record the radiko responses: %v", err))
+			}
+		})
+	}
+}
+
+// mergeAPISignals adds s to its bucket in stats, or appends it as a new one
+func mergeAPISignals(stats []APISignalStats, s APISignalStats) []APISignalStats {
+	for i := range stats {
+		if stats[i].sameBucket(s) {
+			stats[i].add(s)
+			return stats
+		}
+	}
+	return append(stats, s)
+}
+
+// takePendingSignals returns the counts not written yet and clears them
+func takePendingSignals() []APISignalStats {
+	apiSignalMu.Lock()
+	defer apiSignalMu.Unlock()
+	pending := pendingSignals
+	pendingSignals = nil
+	if signalFlushTimer != nil {
+		signalFlushTimer.Stop()
+		signalFlushTimer = nil
+	}
+	return pending
+}
+
+// FlushAPISignals writes the counts of the radiko responses to the history, dropping the ones older than 30 days.
+// This should be called on shutdown not to lose the counts of the last minute.
+func FlushAPISignals() error {
+	pending := takePendingSignals()
+	if len(pending) == 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-apiSignalRetention)
+	return updateHistory(func(f *historyFile) bool {
+		for _, s := range pending {
+			f.Signals = mergeAPISignals(f.Signals, s)
+		}
+		kept := f.Signals[:0]
+		for _, s := range f.Signals {
+			if s.Hour.After(cutoff) {
+				kept = append(kept, s)
+			}
+		}
+		f.Signals = kept
+		sort.SliceStable(f.Signals, func(i, j int) bool { return f.Signals[i].Hour.Before(f.Signals[j].Hour) })
+		return true
+	})
+}
+
+// LoadAPISignals returns the hourly counts of the radiko responses since the time, oldest first,
+// including the ones of this process not written to the history yet
+func LoadAPISignals(since time.Time) ([]APISignalStats, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	historyMu.Lock()
+	f, err := loadHistoryFile(path)
+	historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	stats := f.Signals
+	apiSignalMu.Lock()
+	for _, s := range pendingSignals {
+		stats = mergeAPISignals(stats, s)
+	}
+	apiSignalMu.Unlock()
+
+	recent := []APISignalStats{}
+	for _, s := range stats {
+		if !s.Hour.Add(time.Hour).Before(since) {
+			recent = append(recent, s)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].Hour.Before(recent[j].Hour) })
+	return recent, nil
+}
+
+// SumAPISignals returns the total counts of the stats, without the hour and the settings
+func SumAPISignals(stats []APISignalStats) APISignalStats {
+	var total APISignalStats
+	for _, s := range stats {
+		total.add(s)
+	}
+	return total
+}
@@ -0,0 +1,110 @@
+package radikron
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAPISignalTransport(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	defer takePendingSignals()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/api/auth2":
+			w.WriteHeader(http.StatusForbidden)
+		case "/playlist.m3u8":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/segment.aac":
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: apiSignalTransport{base: rewriteTransport{target: target}}}
+
+	asset := &Asset{MaxDownloadingConcurrency: 3, RequestsPerSecond: 2}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	for _, u := range []string{
+		"https://radiko.jp/v2/api/auth1",
+		"https://radiko.jp/v2/api/auth2",
+		"https://radiko.jp/playlist.m3u8",
+		"https://f-radiko.smartstream.ne.jp/segment.aac",
+		"https://example.com/segment.aac",
+	} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request to %s failed: %v", u, err)
+		}
+		resp.Body.Close()
+	}
+
+	// the counts of this process are loaded before written
+	signals, err := LoadAPISignals(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("LoadAPISignals failed: %v", err)
+	}
+	want := APISignalStats{
+		Hour:                      time.Now().In(Location).Truncate(time.Hour),
+		MaxDownloadingConcurrency: 3,
+		RequestsPerSecond:         2,
+		Requests:                  4,
+		Forbidden:                 1,
+		TooManyRequests:           1,
+		AuthRejected:              1,
+	}
+	if len(signals) != 1 || signals[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, signals)
+	}
+	if got := signals[0].Throttled(); got != 3 {
+		t.Errorf("expected 3 refusals, got %d", got)
+	}
+
+	if err := FlushAPISignals(); err != nil {
+		t.Fatalf("FlushAPISignals failed: %v", err)
+	}
+	signals, err = LoadAPISignals(time.Now().Add(-time.Hour))
+	if err != nil || len(signals) != 1 || signals[0].Requests != 4 {
+		t.Fatalf("expected the flushed counts, got %+v (%v)", signals, err)
+	}
+}
+
+func TestFlushAPISignals(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	defer takePendingSignals()
+	hour := time.Now().In(Location).Truncate(time.Hour)
+	old := APISignalStats{Hour: hour.Add(-apiSignalRetention - time.Hour), Requests: 5}
+	if err := updateHistory(func(f *historyFile) bool {
+		f.Signals = []APISignalStats{old, {Hour: hour, MaxDownloadingConcurrency: 4, Requests: 10, TooManyRequests: 2}}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	apiSignalMu.Lock()
+	pendingSignals = []APISignalStats{
+		{Hour: hour, MaxDownloadingConcurrency: 4, Requests: 3, TooManyRequests: 1},
+		// the settings changed in the hour
+		{Hour: hour, MaxDownloadingConcurrency: 1, Requests: 2},
+	}
+	apiSignalMu.Unlock()
+	if err := FlushAPISignals(); err != nil {
+		t.Fatalf("FlushAPISignals failed: %v", err)
+	}
+
+	signals, err := LoadAPISignals(old.Hour)
+	if err != nil {
+		t.Fatalf("LoadAPISignals failed: %v", err)
+	}
+	if len(signals) != 2 {
+		t.Fatalf("expected the old hour dropped and a bucket per settings, got %+v", signals)
+	}
+	total := SumAPISignals(signals)
+	if total.Requests != 15 || total.TooManyRequests != 3 {
+		t.Errorf("unexpected total %+v", total)
+	}
+}
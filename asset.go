@@ -11,8 +11,13 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/yyoshiki41/go-radiko"
@@ -26,6 +31,9 @@ var (
 	// CoordinatesJSON is a JSON contains the base GPS locations
 	//go:embed assets/coordinates.json
 	CoordinatesJSON embed.FS
+	// FillerTitlesTXT is the bundled list of the filler program titles never downloaded
+	//go:embed assets/filler-titles.txt
+	FillerTitlesTXT embed.FS
 	// RegionsJSON is a JSON contains the region mapping
 	//go:embed assets/regions.json
 	RegionsJSON embed.FS
@@ -59,6 +67,150 @@ type Asset struct {
 	MaxDownloadingConcurrency int
 	// MaxEncodingConcurrency limits concurrent encoding operations (MP3 conversion)
 	MaxEncodingConcurrency int
+	// PreserveTimestamp sets the output file mtime to the program start time
+	PreserveTimestamp bool
+	// WriteXattrs writes the program metadata to the extended attributes of the output file
+	WriteXattrs bool
+	// FolderArt writes the station logo as folder.jpg and cover.jpg into the station and rule folders
+	FolderArt bool
+	// ReadOnly only reports the matched programs without downloading them
+	ReadOnly bool
+	// CoordinationDir is the dir shared with the other instances to avoid downloading the same program
+	CoordinationDir string
+	// InstanceID identifies this instance in the coordination dir
+	InstanceID string
+	// CoordinationLease is the time before a program lock of a stalled instance is taken over
+	CoordinationLease time.Duration
+	// DownloadStallTimeout cancels a download whose segments make no progress for it to resume from the queue (0 never)
+	DownloadStallTimeout time.Duration
+	// RequestsPerSecond limits the segment downloads and playlist fetches (0 for unlimited)
+	RequestsPerSecond float64
+	// PremiumDevice is the premium (areafree) session used by the areafree rules, nil unless logged in
+	PremiumDevice *Device
+	// PremiumAreaID is the home area of the premium session
+	PremiumAreaID string
+	// PremiumMaxStreams limits the programs downloading with the premium session at once
+	PremiumMaxStreams int
+	// PremiumMail and PremiumPass log in to the premium session on the first areafree program, unless empty
+	PremiumMail string
+	PremiumPass string
+	// MinimumFreeSpace in bytes to keep free on the filesystems after a download, 0 to disable the check
+	MinimumFreeSpace int64
+	// FillerTitles are the filler program titles never downloaded even if a rule matches them
+	FillerTitles []string
+	// NotifyUpcoming reports the future programs matched by a rule with their share links
+	NotifyUpcoming bool
+	// ThrottleSchedule overrides the download limits by the time of day; the first window containing now applies
+	ThrottleSchedule []ThrottleWindow
+	// MinimumSegmentSize in bytes for each downloaded segment, 0 to only check the Content-Length
+	MinimumSegmentSize int64
+	// DurationTolerance is how much shorter than the program the output can be, 0 to skip the check
+	DurationTolerance time.Duration
+	// RecoveryScan is what to do with the broken outputs found at startup (RecoveryScanQuarantine, RecoveryScanRemove, or RecoveryScanOff)
+	RecoveryScan string
+	// DownloadPool runs the segment downloads, created by InitSemaphores
+	DownloadPool *WorkerPool
+	// EncodePool runs the MP3 encodings, created by InitSemaphores
+	EncodePool *WorkerPool
+	// DeferredEncoding encodes the MP3 outputs after the downloads complete instead of right after each download
+	DeferredEncoding bool
+	// EncodingWindow is the time of day to run the deferred encodings in, or nil for any time
+	EncodingWindow *ThrottleWindow
+	// QuietHours are the times of day when no download from timefree or encoding starts
+	QuietHours []ThrottleWindow
+	// TempCleanupInterval is how often to remove the stale aac dirs in the tmp dir besides at startup, 0 for never
+	TempCleanupInterval time.Duration
+	// MaxProgramAttempts is the failed attempts (e.g., a too small output) before giving up a program, 0 for never
+	MaxProgramAttempts int
+	// FFmpegPath is the ffmpeg binary to encode with, or empty to look it up in PATH
+	FFmpegPath string
+	// FFmpegArgs replace DefaultFFmpegArgs, the encoder arguments of ffmpeg, if not empty
+	FFmpegArgs []string
+	// DuplicateScan is which folders to check for an existing output before a download (DuplicateScanAll or DuplicateScanRule)
+	DuplicateScan string
+	// MP3Bitrate is the constant bitrate of the MP3 outputs (e.g., "192k"), or empty for the ffmpeg default
+	MP3Bitrate string
+	// MP3Quality is the VBR quality of the MP3 outputs from 0 (best) to 9 (smallest), or nil for the ffmpeg default
+	MP3Quality *int
+	// Sidecars are the formats of the program metadata files written next to the outputs (SidecarJSON, SidecarNFO)
+	Sidecars []string
+	// TranscriptionURL is the endpoint of the Whisper server to save the transcripts of the outputs, or empty not to
+	TranscriptionURL string
+	// FeedListen is the address to serve the private podcast feeds on (e.g., ":8090"), or empty not to
+	FeedListen string
+	// WebhookListen is the address to serve the inbound webhooks on (e.g., ":8091"), or empty not to
+	WebhookListen string
+	// FileServerListen is the address to serve the downloads read-only on (e.g., ":8092"), or empty not to
+	FileServerListen string
+	// FetchSchedule fetches at the times of the cron expression instead of after the programs end, or nil
+	FetchSchedule *CronSchedule
+	// MaxLiveRecordings is the number of the programs recorded live at once (the tuners or the processes), or 0 for no limit
+	MaxLiveRecordings int
+	// StationDirs are the folders under DownloadDir to save the programs of each station to without a rule folder
+	StationDirs map[string]string
+	// AdBreakChapters splits the outputs into chapters at the discontinuity markers of the playlists, usually the ad breaks
+	AdBreakChapters bool
+	// ImageGallery saves the image of each program into GalleryDirName in its rule folder with an index
+	ImageGallery bool
+	// Storage saves the outputs to a remote storage after writing them in the tmp dir, or nil to keep them in DownloadDir
+	Storage Storage
+	// AreaIDs are the areas the available stations are loaded from, preferred to auth the stations broadcast in several areas
+	AreaIDs []string
+	// Retry is how the failed segment downloads, playlist fetches, and auth requests are retried, DefaultRetryPolicy if zero
+	Retry RetryPolicy
+	// GuideCacheTTL is how long the fetched weekly guides are reused, 0 to always fetch them
+	GuideCacheTTL time.Duration
+	// TitleAliases are the old→new titles of the renamed programs, set with the rules' by SetTitleAliases
+	TitleAliases TitleAliases
+	// Feeds are the folders under DownloadDir each device subscribes to by the device name, set by SetFeeds
+	Feeds map[string][]string
+	// FeedURLBase is the URL of the feed server the feeds link to, or empty for the host of each request
+	FeedURLBase string
+	// WebhookToken is the token the inbound webhooks must bear
+	WebhookToken string
+	// FileServerUser and FileServerPassword are the credentials of the file server, and FileServerListing lists its directories
+	FileServerUser     string
+	FileServerPassword string
+	FileServerListing  bool
+
+	// proxyURL routes the outbound requests, nil to honor the env, set by SetProxy
+	proxyURL *url.URL
+	// tagTemplates override the default tags of the outputs, set by SetTagTemplates
+	tagTemplates map[string]*template.Template
+	// notificationTemplates override the default texts of the notifications of each backend, set by SetNotificationTemplates
+	notificationTemplates map[string]map[string]*template.Template
+	// filenameTemplate names the outputs, nil for DefaultFilenameTemplate, set by SetFilenameTemplate
+	filenameTemplate *template.Template
+
+	// mu protects the worker pools, the rate limiter, the premium streams, the throttle watcher, and the live plans
+	mu sync.Mutex
+	// requestLimiter limits the requests to radiko (nil for unlimited), and premiumStreams the programs downloading
+	// with the premium session at once; both are carried over by Inherit like the pools
+	requestLimiter *rateLimiter
+	premiumStreams *prioritySemaphore
+	// throttle applies the ThrottleSchedule as the time of day changes, carried over by Inherit
+	throttle *throttleWatcher
+	// livePlans are the programs Download planned to record live, until RecordLive starts them
+	livePlans Progs
+}
+
+// Inherit carries the worker pools, the rate limiter, the premium streams, and the throttle watcher of prev
+// (the asset of the previous fetch) over to the asset, so that the downloads in flight keep them across the reloads
+func (a *Asset) Inherit(prev *Asset) {
+	if prev == nil || prev == a {
+		return
+	}
+	prev.mu.Lock()
+	downloadPool, encodePool := prev.DownloadPool, prev.EncodePool
+	requestLimiter, premiumStreams := prev.requestLimiter, prev.premiumStreams
+	throttle := prev.throttle
+	prev.mu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.DownloadPool, a.EncodePool = downloadPool, encodePool
+	a.requestLimiter, a.premiumStreams = requestLimiter, premiumStreams
+	a.throttle = throttle
 }
 
 // AddExtraStations appends stations to AvailableStations
@@ -77,6 +229,33 @@ func (a *Asset) AddExtraStations(es []string) {
 	}
 }
 
+// DeviceForProg returns the authorized Device and its area ID to fetch the program.
+// The programs matched by an areafree rule use the premium session if logged in,
+// and the others use the device authorized in the station's area.
+func (a *Asset) DeviceForProg(ctx context.Context, prog *Prog) (device *Device, areaID string, err error) {
+	if prog.AreaFree {
+		premium, err := a.premiumDevice(ctx)
+		if err != nil {
+			emitLogMessage(ctx, "warn", fmt.Sprintf("failed to log in to the premium account: %v", err))
+		} else if premium != nil {
+			return premium, a.PremiumAreaID, nil
+		}
+		emitLogMessage(ctx, "info", fmt.Sprintf(
+			"no premium session for the areafree rule[%s], using the area auth for [%s]%s",
+			prog.RuleName, prog.StationID, prog.Title))
+	}
+
+	areaID = a.GetAreaIDByStationID(prog.StationID)
+	device, ok := a.AreaDevices[areaID]
+	if !ok {
+		device, err = a.NewDevice(ctx, areaID)
+		if err != nil {
+			return nil, areaID, err
+		}
+	}
+	return device, areaID, nil
+}
+
 // GenerateGPS returns the RadikoLocationHeader GPS string
 // e.g., "35.689492,139.691701,gps"
 func (a *Asset) GenerateGPSForAreaID(areaID string) string {
@@ -96,12 +275,19 @@ func (a *Asset) GenerateGPSForAreaID(areaID string) string {
 	return ""
 }
 
-// GetAreaIDByStationID returns the first AreaID for the station
+// GetAreaIDByStationID returns the first of the loaded AreaIDs the station is broadcast in,
+// or the first AreaID for the station if none of them
 func (a *Asset) GetAreaIDByStationID(stationID string) string {
-	if s, ok := a.Stations[stationID]; ok {
-		return s.Areas[0]
+	s, ok := a.Stations[stationID]
+	if !ok {
+		return ""
 	}
-	return ""
+	for _, areaID := range a.AreaIDs {
+		if slices.Contains(s.Areas, areaID) {
+			return areaID
+		}
+	}
+	return s.Areas[0]
 }
 
 // GetPartialKey returns the partial key for auth2 API
@@ -127,14 +313,41 @@ func (a *Asset) GetStationIDsByAreaID(areaID string) []string {
 	return sids
 }
 
-// LoadAvailableStations loads up the avaialable stations
-func (a *Asset) LoadAvailableStations(areaID string) {
-	// AvailableStations
-	a.AvailableStations = a.GetStationIDsByAreaID(areaID)
+// LoadAvailableStations loads up the avaialable stations of all the areas
+func (a *Asset) LoadAvailableStations(areaIDs ...string) {
+	a.AreaIDs = areaIDs
+	a.AvailableStations = []string{}
+	for _, areaID := range areaIDs {
+		for _, sid := range a.GetStationIDsByAreaID(areaID) {
+			if !slices.Contains(a.AvailableStations, sid) {
+				a.AvailableStations = append(a.AvailableStations, sid)
+			}
+		}
+	}
 }
 
 // NewDevice returns a pointer to a new authorized Device
 func (a *Asset) NewDevice(ctx context.Context, areaID string) (*Device, error) {
+	device, err := a.newDevice()
+	if err != nil {
+		return device, err
+	}
+
+	// get token
+	err = a.retryPolicy().Do(ctx, func() error {
+		return device.Auth(ctx, a, areaID)
+	})
+	if err != nil {
+		return device, err
+	}
+
+	// save the device for areaID
+	a.AreaDevices[areaID] = device
+	return device, nil
+}
+
+// newDevice returns a new Device with a random user ID, app, and model, yet to auth
+func (a *Asset) newDevice() (*Device, error) {
 	// generate userID
 	blob := make([]byte, UserIDLength)
 	if _, err := cr.Read(blob); err != nil {
@@ -193,15 +406,6 @@ func (a *Asset) NewDevice(ctx context.Context, areaID string) (*Device, error) {
 	)
 	//X-Radiko-Device: %SDK_ID%.%MODEL%
 	device.Name = fmt.Sprintf("%s.%s", sdk.ID, model)
-
-	// get token
-	err := device.Auth(ctx, a, areaID)
-	if err != nil {
-		return device, err
-	}
-
-	// save the device for areaID
-	a.AreaDevices[areaID] = device
 	return device, nil
 }
 
@@ -263,6 +467,10 @@ type Device struct {
 	Name       string
 	UserAgent  string
 	UserID     string
+	// Session is the radiko_session of the premium login, or empty for the area auth
+	Session string
+	// AreaID is the area authorized by auth2 for the premium session
+	AreaID string
 }
 
 func (d *Device) Auth(ctx context.Context, a *Asset, areaID string) error {
@@ -287,22 +495,29 @@ func (d *Device) Auth(ctx context.Context, a *Asset, areaID string) error {
 		return err
 	}
 	defer resp.Body.Close()
+	if err := checkResponseStatus(resp); err != nil {
+		return fmt.Errorf("auth1 failed: %w", err)
+	}
 	// auth2
 	d.AuthToken = resp.Header.Get(RadikoAuthTokenHeader)
 	offset, err := strconv.ParseInt(resp.Header.Get(RadikoKeyOffsetHeader), 10, 64)
 	if err != nil {
-		return err
+		return permanent(err)
 	}
 	length, err := strconv.ParseInt(resp.Header.Get(RadikoKeyLengthHeader), 10, 64)
 	if err != nil {
-		return err
+		return permanent(err)
 	}
 	partialKey, err := a.GetPartialKey(offset, length)
 	if err != nil {
 		return err
 	}
 	location := a.GenerateGPSForAreaID(areaID)
-	req, err = http.NewRequestWithContext(ctx, "GET", "https://radiko.jp/v2/api/auth2", http.NoBody)
+	auth2 := APIAuth2
+	if d.Session != "" {
+		auth2 += "?radiko_session=" + url.QueryEscape(d.Session)
+	}
+	req, err = http.NewRequestWithContext(ctx, "GET", auth2, http.NoBody)
 	if err != nil {
 		return err
 	}
@@ -321,10 +536,16 @@ func (d *Device) Auth(ctx context.Context, a *Asset, areaID string) error {
 		req.Header.Set(k, v)
 	}
 	resp, err = client.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	if d.Session != "" {
+		return d.readPremiumArea(resp, err)
+	}
+	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if err := checkResponseStatus(resp); err != nil {
+		return fmt.Errorf("auth2 failed: %w", err)
+	}
 	return nil
 }
 
@@ -349,9 +570,17 @@ type SDK struct {
 }
 
 type Station struct {
-	Areas []string
-	Name  string
-	Ruby  string
+	Areas      []string
+	Name       string
+	Ruby       string
+	ASCIIName  string
+	LogoURL    string
+	BannerURL  string
+	Href       string
+	RegionID   string // e.g., kanto, or nhk for the NHK stations
+	RegionName string
+	AreaFree   bool
+	TimeFree   bool
 }
 
 type Stations map[string]*Station
@@ -386,7 +615,7 @@ func NewAsset(client *radiko.Client) (*Asset, error) {
 	// empty FileFormat
 	asset.OutputFormat = radigo.AudioFormatAAC
 	// default DownloadDir
-	asset.DownloadDir = "downloads"
+	asset.DownloadDir = DefaultDownloadDir
 	// default concurrency values
 	asset.MaxDownloadingConcurrency = MaxDownloadingConcurrency
 	asset.MaxEncodingConcurrency = MaxEncodingConcurrency
@@ -434,9 +663,17 @@ func NewAsset(client *radiko.Client) (*Asset, error) {
 				station.Areas = append(station.Areas, xmlStation.AreaID)
 			} else {
 				station := &Station{
-					Areas: []string{xmlStation.AreaID},
-					Name:  xmlStation.Name,
-					Ruby:  xmlStation.Ruby,
+					Areas:      []string{xmlStation.AreaID},
+					Name:       xmlStation.Name,
+					Ruby:       xmlStation.Ruby,
+					ASCIIName:  xmlStation.ASCIIName,
+					LogoURL:    xmlStation.LogoURL(),
+					BannerURL:  strings.TrimSpace(xmlStation.Banner),
+					Href:       strings.TrimSpace(xmlStation.Href),
+					RegionID:   xmlStations.RegionID,
+					RegionName: xmlStations.RegionName,
+					AreaFree:   xmlStation.AreaFree == 1,
+					TimeFree:   xmlStation.TimeFree == 1,
 				}
 				asset.Stations[xmlStation.ID] = station
 			}
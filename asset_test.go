@@ -135,6 +135,46 @@ func TestGetAreaIDByStationID(t *testing.T) {
 	}
 }
 
+func TestDeviceForProg(t *testing.T) {
+	areaDevice := &Device{AuthToken: "area"}
+	premiumDevice := &Device{AuthToken: "premium"}
+	asset := &Asset{
+		AreaDevices: Devices{"JP27": areaDevice},
+		Stations:    Stations{"MBS": &Station{Areas: []string{"JP27"}}},
+	}
+	ctx := context.Background()
+
+	prog := &Prog{StationID: "MBS", AreaFree: true, RuleName: "areafree-rule"}
+	// no premium session, fall back to the area auth
+	device, areaID, err := asset.DeviceForProg(ctx, prog)
+	if err != nil {
+		t.Fatalf("DeviceForProg failed: %v", err)
+	}
+	if device != areaDevice || areaID != "JP27" {
+		t.Errorf("DeviceForProg => (%v, %v), want the area device for JP27", device.AuthToken, areaID)
+	}
+
+	asset.PremiumDevice = premiumDevice
+	asset.PremiumAreaID = "JP13"
+	device, areaID, err = asset.DeviceForProg(ctx, prog)
+	if err != nil {
+		t.Fatalf("DeviceForProg failed: %v", err)
+	}
+	if device != premiumDevice || areaID != "JP13" {
+		t.Errorf("DeviceForProg => (%v, %v), want the premium device for JP13", device.AuthToken, areaID)
+	}
+
+	// the other rules keep using the area auth
+	prog.AreaFree = false
+	device, _, err = asset.DeviceForProg(ctx, prog)
+	if err != nil {
+		t.Fatalf("DeviceForProg failed: %v", err)
+	}
+	if device != areaDevice {
+		t.Errorf("DeviceForProg => %v, want the area device", device.AuthToken)
+	}
+}
+
 func TestGetStationIDsByAreaID(t *testing.T) {
 	client, err := radiko.New("")
 	if err != nil {
@@ -357,6 +397,28 @@ func TestLoadAvailableStations(t *testing.T) {
 	}
 }
 
+func TestLoadAvailableStationsMultipleAreas(t *testing.T) {
+	asset := &Asset{Stations: Stations{
+		"TBS":      {Areas: []string{"JP13"}},
+		"MBS":      {Areas: []string{"JP27"}},
+		"RADIONIK": {Areas: []string{"JP1", "JP13", "JP27"}},
+	}}
+	asset.LoadAvailableStations("JP27", "JP13")
+
+	expectedStations := []string{"MBS", "RADIONIK", "TBS"}
+	less := func(a, b string) bool { return a < b }
+	if !cmp.Equal(asset.AvailableStations, expectedStations, cmpopts.SortSlices(less)) {
+		t.Errorf("expected stations %v, got %v", expectedStations, asset.AvailableStations)
+	}
+	// the station in several areas is authorized in the first loaded area
+	if got := asset.GetAreaIDByStationID("RADIONIK"); got != "JP27" {
+		t.Errorf("expected JP27 for the station in several areas, got %s", got)
+	}
+	if got := asset.GetAreaIDByStationID("TBS"); got != "JP13" {
+		t.Errorf("expected JP13, got %s", got)
+	}
+}
+
 func TestGetAsset(t *testing.T) {
 	client, err := radiko.New("")
 	if err != nil {
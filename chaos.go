@@ -0,0 +1,35 @@
+package radikron
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+)
+
+// ErrSimulatedFailure is the error of the failures simulated with SetFailureRate
+var ErrSimulatedFailure = errors.New("simulated failure")
+
+// failureRate holds the bits of the probability of the simulated failures, 0 to disable
+var failureRate atomic.Uint64
+
+// SetFailureRate makes the segment downloads, the playlist fetches, and the encodes fail at random
+// with the probability rate from 0 (never, the default) to 1 (always), to exercise the retries,
+// the queue, and the notifications without waiting for the real failures
+func SetFailureRate(rate float64) error {
+	if math.IsNaN(rate) || rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid failure rate %v, expected 0 to 1", rate)
+	}
+	failureRate.Store(math.Float64bits(rate))
+	return nil
+}
+
+// simulateFailure returns ErrSimulatedFailure for the operation at the failure rate, or nil
+func simulateFailure(op string) error {
+	rate := math.Float64frombits(failureRate.Load())
+	if rate > 0 && rand.Float64() < rate { //nolint:gosec
+		return fmt.Errorf("%w of the %s", ErrSimulatedFailure, op)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetFailureRate(t *testing.T) {
+	defer func() { _ = SetFailureRate(0) }()
+	for _, rate := range []float64{-0.1, 1.1, math.NaN()} {
+		if err := SetFailureRate(rate); err == nil {
+			t.Errorf("expected an error for the rate %v", rate)
+		}
+	}
+
+	if err := SetFailureRate(0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := simulateFailure("encode"); err != nil {
+			t.Fatalf("expected no failure at the rate 0, got %v", err)
+		}
+	}
+
+	if err := SetFailureRate(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := simulateFailure("encode"); !errors.Is(err, ErrSimulatedFailure) {
+		t.Errorf("expected the simulated failure at the rate 1, got %v", err)
+	}
+	// the simulated failure comes before any request
+	err := downloadLink(context.Background(), "http://127.0.0.1:0/segment.aac", filepath.Join(t.TempDir(), "segment.aac"))
+	if !errors.Is(err, ErrSimulatedFailure) {
+		t.Errorf("expected the simulated failure of the segment download, got %v", err)
+	}
+}
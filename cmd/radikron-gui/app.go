@@ -16,6 +16,8 @@ import (
 const (
 	// assetRetryDelay is the delay before retrying when asset is nil
 	assetRetryDelay = 10 * time.Second
+	// poolShutdownTimeout is how long to wait for the running jobs of the worker pools on shutdown
+	poolShutdownTimeout = 30 * time.Second
 )
 
 // App struct represents the Wails application
@@ -29,6 +31,7 @@ type App struct {
 	monitorDone   chan struct{}
 	monitorWg     *sync.WaitGroup
 	monitorCancel context.CancelFunc
+	instanceLock  *radikron.InstanceLock
 	mu            sync.RWMutex
 }
 
@@ -44,6 +47,20 @@ func (a *App) OnStartup(ctx context.Context) {
 	a.ctx = ctx
 	a.configFile = "config.yml" // Default config file
 
+	// Never run against the RADICRON_HOME of another radikron
+	lock, err := radikron.AcquireInstanceLock()
+	if err != nil {
+		runtime.LogError(ctx, fmt.Sprintf("Failed to start: %v", err))
+		return
+	}
+	a.instanceLock = lock
+
+	// Upgrade the persistent state left by older versions
+	if err := radikron.MigrateState(); err != nil {
+		runtime.LogError(ctx, fmt.Sprintf("Failed to migrate the state: %v", err))
+		return
+	}
+
 	// Initialize radiko client
 	client, err := radiko.New("")
 	if err != nil {
@@ -66,6 +83,15 @@ func (a *App) OnStartup(ctx context.Context) {
 			a.config = cfg
 		}
 	}
+
+	// Serve the podcast feeds while the app runs; the feeds and their folders follow the reloads
+	if addr := a.asset.FeedListen; addr != "" {
+		go func() {
+			if err := radikron.ServeFeeds(ctx, addr, a.currentAsset); err != nil {
+				runtime.LogError(ctx, fmt.Sprintf("Failed to serve the podcast feeds: %v", err))
+			}
+		}()
+	}
 }
 
 // OnShutdown is called when the app closes
@@ -73,6 +99,29 @@ func (a *App) OnShutdown(_ context.Context) {
 	if err := a.StopMonitoring(); err != nil {
 		runtime.LogError(a.ctx, fmt.Sprintf("Failed to stop monitoring on shutdown: %v", err))
 	}
+
+	a.mu.RLock()
+	asset := a.asset
+	a.mu.RUnlock()
+	if asset != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), poolShutdownTimeout)
+		defer cancel()
+		if err := asset.Shutdown(ctx); err != nil {
+			runtime.LogError(a.ctx, fmt.Sprintf("Failed to shut down the worker pools: %v", err))
+		}
+	}
+
+	// Free the premium sessions for the other devices of the account
+	ctx, cancel := context.WithTimeout(context.Background(), poolShutdownTimeout)
+	defer cancel()
+	radikron.LogoutPremium(ctx)
+	if err := radikron.FlushAPISignals(); err != nil {
+		runtime.LogError(a.ctx, fmt.Sprintf("Failed to record the radiko responses: %v", err))
+	}
+
+	if err := a.instanceLock.Release(); err != nil {
+		runtime.LogError(a.ctx, fmt.Sprintf("Failed to release the instance lock: %v", err))
+	}
 }
 
 // GetConfig returns the current configuration
@@ -155,6 +204,78 @@ func (a *App) GetAvailableStations() ([]string, error) {
 	return a.asset.AvailableStations, nil
 }
 
+// GetStations returns the metadata of all the stations, e.g., their names and logos, sorted by ID
+func (a *App) GetStations() ([]radikron.StationInfo, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.asset == nil {
+		return nil, fmt.Errorf("asset not initialized")
+	}
+
+	return a.asset.StationInfos(), nil
+}
+
+// GetWorkerPools returns the workers and the running and queued jobs of the download and encode pools
+func (a *App) GetWorkerPools() []radikron.PoolStats {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.asset == nil {
+		return nil
+	}
+	return a.asset.PoolStats()
+}
+
+// GetRuleOverlaps returns the pairs of rules where one matches all the programs of the other,
+// with the rule applied to the programs both match
+func (a *App) GetRuleOverlaps() []radikron.RuleOverlap {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.asset == nil {
+		return nil
+	}
+	return a.asset.Rules.Overlaps()
+}
+
+// GetFeedURLs returns the private podcast feed URL of each device, generating the missing tokens
+func (a *App) GetFeedURLs() (map[string]string, error) {
+	asset := a.currentAsset()
+	if asset == nil {
+		return nil, fmt.Errorf("asset not initialized")
+	}
+	return asset.FeedURLs()
+}
+
+// currentAsset returns the asset the configuration is applied to, for the servers started once
+func (a *App) currentAsset() *radikron.Asset {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.asset
+}
+
+// SearchDownloads returns the downloaded programs whose metadata or transcript contain all the words of the query,
+// the newest first
+func (a *App) SearchDownloads(query string) ([]radikron.SearchResult, error) {
+	return radikron.SearchDownloads(query)
+}
+
+// DownloadShareURLs downloads the programs of the radiko share links with the normal queue and concurrency,
+// returning the number of the programs started
+func (a *App) DownloadShareURLs(refs []string) (int, error) {
+	a.mu.RLock()
+	asset := a.asset
+	a.mu.RUnlock()
+	if asset == nil {
+		return 0, fmt.Errorf("asset not initialized")
+	}
+
+	radikron.CurrentTime = time.Now().In(radikron.Location)
+	ctx := context.WithValue(a.ctx, radikron.ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, radikron.ContextKey("eventEmitter"), NewWailsEventEmitter(a.ctx))
+	// the downloads run on their own, outliving a stop of the monitoring
+	return radikron.DownloadShareURLs(ctx, &sync.WaitGroup{}, refs)
+}
+
 // GetMonitoringStatus returns whether monitoring is active
 func (a *App) GetMonitoringStatus() bool {
 	a.mu.RLock()
@@ -269,18 +390,21 @@ type programWithStation struct {
 }
 
 // collectProgramsFromStations collects and deduplicates programs from all stations
-func (a *App) collectProgramsFromStations(asset *radikron.Asset, fetcher *radikronProgramFetcher) []*programWithStation {
+func (a *App) collectProgramsFromStations(
+	ctx context.Context, asset *radikron.Asset, fetcher *radikronProgramFetcher,
+) []*programWithStation {
 	allPrograms := make(map[string]*programWithStation) // key: program ID
 
-	// Process all stations and collect programs
-	for _, stationID := range asset.AvailableStations {
-		// Skip if no rules match this station
-		if !asset.Rules.HasRuleWithoutStationID() && !asset.Rules.HasRuleForStationID(stationID) {
-			continue
+	// Process the stations some rule can match a program on by the next fetch, a day later at the latest
+	until := radikron.CurrentTime.Add(radikron.OneDay * time.Hour)
+	for _, r := range asset.Rules {
+		if !r.CanMatchUntil(until) && asset.FetchSchedule == nil && (asset.NextFetchTime == nil || asset.NextFetchTime.After(until)) {
+			asset.NextFetchTime = &until
 		}
-
+	}
+	for _, stationID := range asset.Rules.GuideStations(asset.AvailableStations, until) {
 		// Fetch weekly programs
-		weeklyPrograms, err := fetcher.FetchWeeklyPrograms(stationID)
+		weeklyPrograms, err := fetcher.FetchWeeklyPrograms(ctx, stationID)
 		if err != nil {
 			log.Printf("failed to fetch the %s program: %v", stationID, err)
 			continue
@@ -367,8 +491,7 @@ func (a *App) processProgram(
 		return true, false
 	}
 
-	p.RuleName = matchedRule.Name
-	p.RuleFolder = matchedRule.Folder
+	matchedRule.ApplyTo(p)
 
 	log.Printf("rule[%s] matched [%s]%s - attempting download (start time: %s)", matchedRule.Name, stationID, p.Title, p.Ft)
 	runtime.EventsEmit(a.ctx, "log-message", map[string]any{
@@ -394,6 +517,58 @@ func (a *App) processProgram(
 	return true, false
 }
 
+// recoverDownloads quarantines or removes the broken files left by a crash and re-queues their programs
+func (a *App) recoverDownloads(downloadCtx context.Context, asset *radikron.Asset) {
+	recovered, err := radikron.RecoverDownloads(downloadCtx, asset)
+	if err != nil {
+		log.Printf("failed to recover the downloads: %v", err)
+		return
+	}
+	if recovered > 0 {
+		runtime.EventsEmit(a.ctx, "log-message", map[string]any{
+			"type":    "info",
+			"message": fmt.Sprintf("Recovered %d broken files left by a previous run", recovered),
+		})
+	}
+}
+
+// cleanupTempDirs removes the stale aac dirs left in the tmp dir by a crash
+func (a *App) cleanupTempDirs(downloadCtx context.Context) {
+	removed, freed, err := radikron.CleanupTempDirs(downloadCtx)
+	if err != nil {
+		log.Printf("failed to clean up the tmp dir: %v", err)
+		return
+	}
+	if removed > 0 {
+		runtime.EventsEmit(a.ctx, "log-message", map[string]any{
+			"type":    "info",
+			"message": fmt.Sprintf("Removed %d stale aac dirs (%.1f MB) left by a previous run", removed, float64(freed)/radikron.Kilobytes/radikron.Kilobytes),
+		})
+	}
+}
+
+// resumeQueue downloads the programs left in the queue by a previous run
+func (a *App) resumeQueue(downloadCtx context.Context, downloader *radikronDownloader) {
+	queued, err := radikron.PendingQueue(downloadCtx)
+	if err != nil {
+		log.Printf("failed to load the queue: %v", err)
+		return
+	}
+	if len(queued) > 0 {
+		log.Printf("resuming %d queued programs", len(queued))
+	}
+	for _, p := range queued {
+		if err := downloader.Download(downloadCtx, a.monitorWg, p); err != nil {
+			log.Printf("download failed for [%s]%s: %s", p.StationID, p.Title, err)
+			runtime.EventsEmit(a.ctx, "download-failed", map[string]any{
+				"station": p.StationID,
+				"title":   p.Title,
+				"error":   err.Error(),
+			})
+		}
+	}
+}
+
 // checkAndLogRulesCount checks and logs the number of configured rules
 func (a *App) checkAndLogRulesCount(asset *radikron.Asset) {
 	a.mu.RLock()
@@ -408,6 +583,17 @@ func (a *App) checkAndLogRulesCount(asset *radikron.Asset) {
 	} else {
 		log.Printf("configured with %d rules", rulesCount)
 	}
+
+	a.mu.RLock()
+	overlaps := asset.Rules.Overlaps()
+	a.mu.RUnlock()
+	for _, o := range overlaps {
+		log.Printf("warning: %s", o)
+		runtime.EventsEmit(a.ctx, "log-message", map[string]any{
+			"type":    "error",
+			"message": o.String(),
+		})
+	}
 }
 
 // processAllPrograms collects programs from stations and processes them
@@ -418,7 +604,10 @@ func (a *App) processAllPrograms(
 	downloader *radikronDownloader,
 ) {
 	// Collect all programs from all stations
-	programList := a.collectProgramsFromStations(asset, fetcher)
+	started := time.Now()
+	programList := a.collectProgramsFromStations(downloadCtx, asset, fetcher)
+	// the next fetch starts earlier by the time until the downloads start
+	radikron.RecordFetchDuration(time.Since(started))
 	log.Printf("collected %d programs from stations", len(programList))
 
 	// Track programs processed in this iteration to prevent duplicates
@@ -503,6 +692,8 @@ func (a *App) runMonitoringLoop(ctx context.Context) {
 
 	fetcher := &radikronProgramFetcher{}
 	downloader := &radikronDownloader{}
+	summaryEmitted := false
+	recovered := false
 
 	for {
 		select {
@@ -547,13 +738,44 @@ func (a *App) runMonitoringLoop(ctx context.Context) {
 		downloadCtx := context.WithValue(ctx, radikron.ContextKey("asset"), asset)
 		downloadCtx = context.WithValue(downloadCtx, radikron.ContextKey("eventEmitter"), eventEmitter)
 
+		// Report the effective configuration once the monitoring starts
+		if !summaryEmitted {
+			a.mu.RLock()
+			cfg := a.config
+			a.mu.RUnlock()
+			if cfg != nil {
+				radikron.EmitConfigSummary(downloadCtx, cfg.Summary(asset))
+				summaryEmitted = true
+			}
+		}
+
 		// Check if rules are configured
 		a.checkAndLogRulesCount(asset)
 
+		// Recover the broken files left by a crash before resuming the queue, which they may be added to
+		if !recovered {
+			a.recoverDownloads(downloadCtx, asset)
+			a.cleanupTempDirs(downloadCtx)
+			recovered = true
+		}
+
+		// Index the saved outputs for the duplicate checks of this iteration
+		radikron.RefreshOutputIndex(downloadCtx)
+
+		// Resume the programs queued before a restart, which may have dropped out of the program guide
+		a.resumeQueue(downloadCtx, downloader)
+
 		// Collect and process programs
 		a.processAllPrograms(asset, fetcher, downloadCtx, downloader)
 
-		// Sleep until next fetch time
+		// Join the live streams of the programs the rules record live
+		radikron.RecordLive(downloadCtx, a.monitorWg)
+
+		// Encode the deferred MP3 outputs in the background; the ones still downloading are encoded next time
+		radikron.StartDeferredEncoding(downloadCtx)
+
+		// Sleep until next fetch time, by the fetch-schedule if any
+		radikron.ScheduleFetch(asset, radikron.CurrentTime)
 		a.logAndSleepUntilNextFetch(asset, ctx)
 	}
 }
@@ -561,8 +783,8 @@ func (a *App) runMonitoringLoop(ctx context.Context) {
 // radikronProgramFetcher implements ProgramFetcher
 type radikronProgramFetcher struct{}
 
-func (f *radikronProgramFetcher) FetchWeeklyPrograms(stationID string) (radikron.Progs, error) {
-	return radikron.FetchWeeklyPrograms(stationID)
+func (f *radikronProgramFetcher) FetchWeeklyPrograms(ctx context.Context, stationID string) (radikron.Progs, error) {
+	return radikron.FetchWeeklyPrograms(ctx, stationID)
 }
 
 // radikronDownloader implements Downloader
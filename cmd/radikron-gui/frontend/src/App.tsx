@@ -6,6 +6,7 @@ import { Badge } from '@/components/ui/badge';
 import { Configuration } from '@/components/Configuration';
 import { Stations } from '@/components/Stations';
 import { Activity } from '@/components/Activity';
+import { Search } from '@/components/Search';
 import { ThemeToggle } from '@/components/ThemeToggle';
 import { useAppStore } from '@/store/useAppStore';
 import { useThemeStore } from '@/store/useThemeStore';
@@ -13,22 +14,102 @@ import iconBlack from './assets/black.png';
 import iconWhite from './assets/white.png';
 
 // Type definitions for event data
+interface ProgramData {
+  id: string;
+  'station-id': string;
+  ft: string;
+  to: string;
+  title: string;
+  pfm?: string;
+  'rule-name'?: string;
+  'rule-folder'?: string;
+}
+
 interface DownloadEventData {
   station: string;
   title: string;
   start?: string;
   error?: string;
+  rule?: string;
+  filePath?: string;
+  program?: ProgramData;
+}
+
+interface ProgramUpcomingData {
+  station: string;
+  title: string;
+  start: string;
+  rule: string;
+  url: string;
+  download: string;
+}
+
+interface ProgramExtendedData {
+  station: string;
+  title: string;
+  start: string;
+  usual: number;
+  minutes: number;
+}
+
+interface ProgramFailedData {
+  station: string;
+  title: string;
+  start: string;
+  attempts: number;
+}
+
+interface DownloadStalledData {
+  station: string;
+  title: string;
+  start: string;
+  timeout: number;
+}
+
+interface FileMovedData {
+  from: string;
+  to: string;
+}
+
+interface ScheduleConflictData {
+  dropped: ProgramData[];
+  limit: number;
+}
+
+interface DownloadProgressData {
+  station: string;
+  title: string;
+  done: number;
+  total: number;
+  bytes: number;
 }
 
 interface ConfigLoadedData {
   success: boolean;
 }
 
+interface ConfigSummaryData {
+  'area-id': string;
+  stations: number;
+  rules: number;
+  'file-format': string;
+  home: string;
+  downloads: string;
+  'max-downloading-concurrency': number;
+  'max-encoding-concurrency': number;
+  'requests-per-second': number;
+  'read-only': boolean;
+}
+
 interface LogMessageData {
   type: 'info' | 'success' | 'error';
   message: string;
 }
 
+// formatRadikoTime formats a radiko time (YYYYMMDDhhmmss) as YYYY-MM-DD hh:mm
+const formatRadikoTime = (t: string): string =>
+  t.length >= 12 ? `${t.slice(0, 4)}-${t.slice(4, 6)}-${t.slice(6, 8)} ${t.slice(8, 10)}:${t.slice(10, 12)}` : t;
+
 // Error fallback component
 const ErrorFallback: React.FC<{ error: Error; resetErrorBoundary: () => void }> = ({
   error,
@@ -118,13 +199,84 @@ const AppComponent: React.FC = () => {
     });
 
     const unsubscribeDownloadCompleted = EventsOn('download-completed', (data: DownloadEventData) => {
-      addActivityLog('success', `Completed: ${data.title} (${data.station})`);
+      const folder = data.program?.['rule-folder'] ? ` to ${data.program['rule-folder']}` : '';
+      addActivityLog('success', `Completed: ${data.title} (${data.station})${folder}`);
+    });
+
+    const unsubscribeDownloadProgress = EventsOn('download-progress', (data: DownloadProgressData) => {
+      // only log every 10% to keep the activity log readable for long programs
+      if (data.total <= 0) return;
+      const decile = Math.floor((data.done * 10) / data.total);
+      const previous = Math.floor(((data.done - 1) * 10) / data.total);
+      if (decile === previous && data.done < data.total) return;
+      const percent = Math.floor((data.done * 100) / data.total);
+      const megabytes = (data.bytes / 1024 / 1024).toFixed(1);
+      addActivityLog(
+        'info',
+        `Downloading: ${data.title} (${data.station}) ${percent}% - ${data.done}/${data.total} segments, ${megabytes} MB`
+      );
     });
 
     const unsubscribeDownloadFailed = EventsOn('download-failed', (data: DownloadEventData) => {
       addActivityLog('error', `Failed: ${data.title} (${data.station}) - ${data.error || 'Unknown error'}`);
     });
 
+    const unsubscribeProgramMatched = EventsOn('program-matched', (data: DownloadEventData) => {
+      addActivityLog('info', `Matched rule '${data.rule}': ${data.title} (${data.station})`);
+    });
+
+    const unsubscribeProgramUpcoming = EventsOn('program-upcoming', (data: ProgramUpcomingData) => {
+      addActivityLog(
+        'info',
+        `Upcoming '${data.rule}': ${data.title} (${data.station}) at ${formatRadikoTime(data.start)}, ` +
+          `downloading at ${formatRadikoTime(data.download)} - listen live: ${data.url}`
+      );
+    });
+
+    const unsubscribeProgramExtended = EventsOn('program-extended', (data: ProgramExtendedData) => {
+      addActivityLog(
+        'info',
+        `Extended: ${data.title} (${data.station}) at ${formatRadikoTime(data.start)} runs ${data.minutes} minutes, usually ${data.usual} minutes`
+      );
+    });
+
+    const unsubscribeProgramFailed = EventsOn('program-failed', (data: ProgramFailedData) => {
+      addActivityLog(
+        'error',
+        `Gave up: ${data.title} (${data.station}) at ${formatRadikoTime(data.start)} after ${data.attempts} failed attempts`
+      );
+    });
+
+    const unsubscribeDownloadStalled = EventsOn('download-stalled', (data: DownloadStalledData) => {
+      addActivityLog(
+        'error',
+        `Stalled: ${data.title} (${data.station}) at ${formatRadikoTime(data.start)} made no progress for ${data.timeout}s, resuming it later`
+      );
+    });
+
+    const unsubscribeFileMoved = EventsOn('file-moved', (data: FileMovedData) => {
+      addActivityLog('info', `Moved: ${data.from} -> ${data.to}`);
+    });
+
+    const unsubscribeScheduleConflict = EventsOn('schedule-conflict', (data: ScheduleConflictData) => {
+      for (const p of data.dropped) {
+        addActivityLog(
+          'error',
+          `Conflict: dropped the live recording of ${p.title} (${p['station-id']}) at ${formatRadikoTime(p.ft)} ` +
+            `for rule '${p['rule-name'] ?? ''}' over ${data.limit} at once`
+        );
+      }
+    });
+
+    const unsubscribeConfigSummary = EventsOn('config-summary', (data: ConfigSummaryData) => {
+      addActivityLog(
+        'info',
+        `Effective configuration: area ${data['area-id']}, ${data.stations} stations, ${data.rules} rules, ` +
+          `${data['file-format']} to ${data.downloads}, concurrency ${data['max-downloading-concurrency']}/${data['max-encoding-concurrency']}` +
+          (data['read-only'] ? ', read-only' : '')
+      );
+    });
+
     const unsubscribeConfigLoaded = EventsOn('config-loaded', (data: ConfigLoadedData) => {
       if (data.success) {
         addActivityLog('success', 'Configuration loaded successfully');
@@ -143,7 +295,16 @@ const AppComponent: React.FC = () => {
       unsubscribeStopped();
       unsubscribeDownloadStarted();
       unsubscribeDownloadCompleted();
+      unsubscribeDownloadProgress();
       unsubscribeDownloadFailed();
+      unsubscribeProgramMatched();
+      unsubscribeProgramUpcoming();
+      unsubscribeProgramExtended();
+      unsubscribeProgramFailed();
+      unsubscribeDownloadStalled();
+      unsubscribeFileMoved();
+      unsubscribeScheduleConflict();
+      unsubscribeConfigSummary();
       unsubscribeConfigLoaded();
       unsubscribeLogMessage();
     };
@@ -180,6 +341,7 @@ const AppComponent: React.FC = () => {
               <Configuration />
               <Stations />
               <Activity />
+              <Search />
             </div>
           </main>
         </div>
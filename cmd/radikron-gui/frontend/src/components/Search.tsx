@@ -0,0 +1,79 @@
+import React, { useState } from 'react';
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '@/components/ui/card';
+import { Badge } from '@/components/ui/badge';
+import { Button } from '@/components/ui/button';
+import { Input } from '@/components/ui/input';
+import { ScrollArea } from '@/components/ui/scroll-area';
+import { SearchDownloads } from '../../wailsjs/go/main/App';
+import { radikron } from '../../wailsjs/go/models';
+
+// formatRadikoTime formats a radiko time (YYYYMMDDhhmmss) as YYYY-MM-DD hh:mm
+const formatRadikoTime = (t: string): string =>
+  t.length >= 12 ? `${t.slice(0, 4)}-${t.slice(4, 6)}-${t.slice(6, 8)} ${t.slice(8, 10)}:${t.slice(10, 12)}` : t;
+
+export const Search: React.FC = () => {
+  const [query, setQuery] = useState('');
+  const [results, setResults] = useState<radikron.SearchResult[] | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const [searching, setSearching] = useState(false);
+
+  const search = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!query.trim()) return;
+    setSearching(true);
+    setError(null);
+    try {
+      setResults((await SearchDownloads(query)) ?? []);
+    } catch (err) {
+      setError(String(err));
+      setResults(null);
+    } finally {
+      setSearching(false);
+    }
+  };
+
+  return (
+    <Card className="md:col-span-2">
+      <CardHeader>
+        <CardTitle>Search</CardTitle>
+        <CardDescription>Search the downloaded programs by title, personality, description, and transcript</CardDescription>
+      </CardHeader>
+      <CardContent className="space-y-4">
+        <form onSubmit={search} className="flex gap-2">
+          <Input
+            value={query}
+            onChange={(e) => setQuery(e.target.value)}
+            placeholder="Keywords"
+            aria-label="Search keywords"
+          />
+          <Button type="submit" disabled={searching || !query.trim()}>
+            {searching ? 'Searching...' : 'Search'}
+          </Button>
+        </form>
+        {error && <p className="text-sm text-destructive">{error}</p>}
+        {results && (
+          <ScrollArea className="h-64">
+            {results.length === 0 ? (
+              <p className="text-sm text-muted-foreground">No downloaded programs match</p>
+            ) : (
+              <ul className="space-y-3">
+                {results.map((r) => (
+                  <li key={r.key} className="text-sm space-y-1">
+                    <div className="flex items-center gap-2">
+                      <Badge variant="outline">{r['station-id']}</Badge>
+                      <span className="font-medium">{r.title}</span>
+                      <span className="text-muted-foreground">{formatRadikoTime(r.ft)}</span>
+                      {r.transcript && <Badge variant="secondary">transcript</Badge>}
+                    </div>
+                    {r.snippet && <p className="text-muted-foreground">{r.snippet}</p>}
+                    {r.path && <p className="text-xs text-muted-foreground break-all">{r.path}</p>}
+                  </li>
+                ))}
+              </ul>
+            )}
+          </ScrollArea>
+        )}
+      </CardContent>
+    </Card>
+  );
+};
@@ -1,17 +1,30 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {config} from '../models';
+import {radikron} from '../models';
+
+export function DownloadShareURLs(arg1:Array<string>):Promise<number>;
 
 export function GetAvailableStations():Promise<Array<string>>;
 
 export function GetConfig():Promise<config.Config>;
 
+export function GetFeedURLs():Promise<{[key: string]: string}>;
+
 export function GetMonitoringStatus():Promise<boolean>;
 
+export function GetRuleOverlaps():Promise<Array<radikron.RuleOverlap>>;
+
+export function GetStations():Promise<Array<radikron.StationInfo>>;
+
+export function GetWorkerPools():Promise<Array<radikron.PoolStats>>;
+
 export function LoadConfig(arg1:string):Promise<void>;
 
 export function SaveConfig(arg1:string):Promise<void>;
 
+export function SearchDownloads(arg1:string):Promise<Array<radikron.SearchResult>>;
+
 export function StartMonitoring():Promise<void>;
 
 export function StopMonitoring():Promise<void>;
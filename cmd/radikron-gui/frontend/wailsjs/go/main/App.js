@@ -2,6 +2,10 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function DownloadShareURLs(arg1) {
+  return window['go']['main']['App']['DownloadShareURLs'](arg1);
+}
+
 export function GetAvailableStations() {
   return window['go']['main']['App']['GetAvailableStations']();
 }
@@ -10,10 +14,26 @@ export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetFeedURLs() {
+  return window['go']['main']['App']['GetFeedURLs']();
+}
+
 export function GetMonitoringStatus() {
   return window['go']['main']['App']['GetMonitoringStatus']();
 }
 
+export function GetRuleOverlaps() {
+  return window['go']['main']['App']['GetRuleOverlaps']();
+}
+
+export function GetStations() {
+  return window['go']['main']['App']['GetStations']();
+}
+
+export function GetWorkerPools() {
+  return window['go']['main']['App']['GetWorkerPools']();
+}
+
 export function LoadConfig(arg1) {
   return window['go']['main']['App']['LoadConfig'](arg1);
 }
@@ -22,6 +42,10 @@ export function SaveConfig(arg1) {
   return window['go']['main']['App']['SaveConfig'](arg1);
 }
 
+export function SearchDownloads(arg1) {
+  return window['go']['main']['App']['SearchDownloads'](arg1);
+}
+
 export function StartMonitoring() {
   return window['go']['main']['App']['StartMonitoring']();
 }
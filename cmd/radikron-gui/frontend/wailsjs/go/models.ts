@@ -51,6 +51,24 @@ export namespace config {
 
 export namespace radikron {
 	
+	export class PoolStats {
+	    name: string;
+	    size: number;
+	    running: number;
+	    queued: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PoolStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.size = source["size"];
+	        this.running = source["running"];
+	        this.queued = source["queued"];
+	    }
+	}
 	export class Rule {
 	    Name: string;
 	    Title: string;
@@ -77,6 +95,101 @@ export namespace radikron {
 	        this.Folder = source["Folder"];
 	    }
 	}
+	export class RuleOverlap {
+	    winner: string;
+	    other: string;
+	    shadowed: boolean;
+	    "same-folder": boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RuleOverlap(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.winner = source["winner"];
+	        this.other = source["other"];
+	        this.shadowed = source["shadowed"];
+	        this["same-folder"] = source["same-folder"];
+	    }
+	}
+	export class SearchResult {
+	    key: string;
+	    "station-id": string;
+	    title: string;
+	    ft: string;
+	    pfm?: string;
+	    desc?: string;
+	    info?: string;
+	    path?: string;
+	    // Go type: time
+	    started: any;
+	    seconds: number;
+	    bytes: number;
+	    speed: number;
+	    retries: number;
+	    snippet?: string;
+	    transcript?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this["station-id"] = source["station-id"];
+	        this.title = source["title"];
+	        this.ft = source["ft"];
+	        this.pfm = source["pfm"];
+	        this.desc = source["desc"];
+	        this.info = source["info"];
+	        this.path = source["path"];
+	        this.started = source["started"];
+	        this.seconds = source["seconds"];
+	        this.bytes = source["bytes"];
+	        this.speed = source["speed"];
+	        this.retries = source["retries"];
+	        this.snippet = source["snippet"];
+	        this.transcript = source["transcript"];
+	    }
+	}
+	export class StationInfo {
+	    id: string;
+	    name: string;
+	    "ascii-name"?: string;
+	    ruby?: string;
+	    "logo-url"?: string;
+	    "banner-url"?: string;
+	    href?: string;
+	    areas: string[];
+	    "region-id"?: string;
+	    "region-name"?: string;
+	    areafree: boolean;
+	    timefree: boolean;
+	    available: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new StationInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this["ascii-name"] = source["ascii-name"];
+	        this.ruby = source["ruby"];
+	        this["logo-url"] = source["logo-url"];
+	        this["banner-url"] = source["banner-url"];
+	        this.href = source["href"];
+	        this.areas = source["areas"];
+	        this["region-id"] = source["region-id"];
+	        this["region-name"] = source["region-name"];
+	        this.areafree = source["areafree"];
+	        this.timefree = source["timefree"];
+	        this.available = source["available"];
+	    }
+	}
 
 }
 
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/iomz/radikron"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -33,25 +34,24 @@ func NewWailsEventEmitter(ctx context.Context) *WailsEventEmitter {
 }
 
 // EmitDownloadStarted implements radikron.EventEmitter
-func (e *WailsEventEmitter) EmitDownloadStarted(stationID, title, startTime, uri string) {
+func (e *WailsEventEmitter) EmitDownloadStarted(prog *radikron.Prog, uri string) {
 	runtime.EventsEmit(e.ctx, "download-started", map[string]any{
-		"station": stationID,
-		"title":   title,
-		"start":   startTime,
+		"station": prog.StationID,
+		"title":   prog.Title,
+		"start":   prog.Ft,
 		"uri":     uri,
+		"program": prog,
 	})
 }
 
 // EmitDownloadCompleted implements radikron.EventEmitter
-func (e *WailsEventEmitter) EmitDownloadCompleted(stationID, title, filePath string) {
-	// Extract station and title from filePath if not provided
-	if stationID == "" || title == "" {
-		stationID, title = extractProgramInfoFromPath(filePath)
-	}
-
+func (e *WailsEventEmitter) EmitDownloadCompleted(prog *radikron.Prog, filePath string) {
 	runtime.EventsEmit(e.ctx, "download-completed", map[string]any{
-		"station": stationID,
-		"title":   title,
+		"station":  prog.StationID,
+		"title":    prog.Title,
+		"start":    prog.Ft,
+		"filePath": filePath,
+		"program":  prog,
 	})
 }
 
@@ -79,6 +79,17 @@ func (e *WailsEventEmitter) EmitDownloadSkipped(reason, stationID, title, startT
 	})
 }
 
+// EmitDownloadProgress implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitDownloadProgress(stationID, title string, done, total int, bytes int64) {
+	runtime.EventsEmit(e.ctx, "download-progress", map[string]any{
+		"station": stationID,
+		"title":   title,
+		"done":    done,
+		"total":   total,
+		"bytes":   bytes,
+	})
+}
+
 // EmitEncodingStarted implements radikron.EventEmitter
 func (e *WailsEventEmitter) EmitEncodingStarted(filePath string) {
 	runtime.EventsEmit(e.ctx, "encoding-started", map[string]any{
@@ -93,6 +104,80 @@ func (e *WailsEventEmitter) EmitEncodingCompleted(filePath string) {
 	})
 }
 
+// EmitProgramMatched implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitProgramMatched(stationID, title, startTime, ruleName string) {
+	runtime.EventsEmit(e.ctx, "program-matched", map[string]any{
+		"station": stationID,
+		"title":   title,
+		"start":   startTime,
+		"rule":    ruleName,
+	})
+}
+
+// EmitProgramUpcoming implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitProgramUpcoming(stationID, title, startTime, ruleName, shareURL, downloadTime string) {
+	runtime.EventsEmit(e.ctx, "program-upcoming", map[string]any{
+		"station":  stationID,
+		"title":    title,
+		"start":    startTime,
+		"rule":     ruleName,
+		"url":      shareURL,
+		"download": downloadTime,
+	})
+}
+
+// EmitProgramExtended implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitProgramExtended(stationID, title, startTime string, usualMinutes, minutes int) {
+	runtime.EventsEmit(e.ctx, "program-extended", map[string]any{
+		"station": stationID,
+		"title":   title,
+		"start":   startTime,
+		"usual":   usualMinutes,
+		"minutes": minutes,
+	})
+}
+
+// EmitProgramFailed implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitProgramFailed(stationID, title, startTime string, attempts int) {
+	runtime.EventsEmit(e.ctx, "program-failed", map[string]any{
+		"station":  stationID,
+		"title":    title,
+		"start":    startTime,
+		"attempts": attempts,
+	})
+}
+
+// EmitDownloadStalled implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitDownloadStalled(stationID, title, startTime string, timeout time.Duration) {
+	runtime.EventsEmit(e.ctx, "download-stalled", map[string]any{
+		"station": stationID,
+		"title":   title,
+		"start":   startTime,
+		"timeout": timeout.Seconds(),
+	})
+}
+
+// EmitFileMoved implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitFileMoved(oldPath, newPath string) {
+	runtime.EventsEmit(e.ctx, "file-moved", map[string]any{
+		"from": oldPath,
+		"to":   newPath,
+	})
+}
+
+// EmitScheduleConflict implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitScheduleConflict(dropped []*radikron.Prog, limit int) {
+	runtime.EventsEmit(e.ctx, "schedule-conflict", map[string]any{
+		"dropped": dropped,
+		"limit":   limit,
+	})
+}
+
+// EmitConfigSummary implements radikron.EventEmitter
+func (e *WailsEventEmitter) EmitConfigSummary(summary radikron.ConfigSummary) {
+	runtime.EventsEmit(e.ctx, "config-summary", summary)
+}
+
 // EmitLogMessage implements radikron.EventEmitter
 func (e *WailsEventEmitter) EmitLogMessage(level, message string) {
 	runtime.EventsEmit(e.ctx, "log-message", map[string]any{
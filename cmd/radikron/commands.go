@@ -0,0 +1,757 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/iomz/radikron"
+	"github.com/yyoshiki41/go-radiko"
+)
+
+// errProblems is returned by a command which ran to the end but found problems, to exit non-zero
+var errProblems = errors.New("problems found")
+
+// command is a node of the command tree, e.g., `config validate`,
+// which runs with the arguments after its name or dispatches them to its subcommands
+type command struct {
+	name    string
+	aliases []string // the older names still accepted
+	short   string   // the one-line description in the usage
+	hidden  bool     // accepted but not listed in the usage, e.g., a command moved under another
+	// run runs the command with the arguments after its name; nil for a group of subcommands
+	run      func(args []string) error
+	commands []*command
+}
+
+// find returns the deepest command named by the leading args, its path, and the rest of the args
+func (c *command) find(args []string) (cmd *command, path string, rest []string) {
+	cmd, rest = c, args
+	var names []string
+	for len(rest) > 0 {
+		sub := cmd.lookup(rest[0])
+		if sub == nil {
+			break
+		}
+		names = append(names, sub.name)
+		cmd, rest = sub, rest[1:]
+	}
+	return cmd, strings.Join(names, " "), rest
+}
+
+// lookup returns the subcommand named or aliased name, or nil
+func (c *command) lookup(name string) *command {
+	for _, sub := range c.commands {
+		if sub.name == name {
+			return sub
+		}
+		for _, alias := range sub.aliases {
+			if alias == name {
+				return sub
+			}
+		}
+	}
+	return nil
+}
+
+// execute runs the command named by args, printing the subcommands of a group named without one;
+// the error is prefixed with the path of the command
+func (c *command) execute(args []string, w io.Writer) error {
+	cmd, path, rest := c.find(args)
+	if cmd.run == nil {
+		if len(rest) > 0 {
+			return fmt.Errorf("%s: unknown command %q", path, rest[0])
+		}
+		cmd.printCommands(w, path)
+		return nil
+	}
+	if err := cmd.run(rest); err != nil {
+		// the flag set of the command printed its usage
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		if path == "" || errors.Is(err, errProblems) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// printCommands prints the visible subcommands of the command at path
+func (c *command) printCommands(w io.Writer, path string) {
+	fmt.Fprintln(w, "Commands:")
+	for _, sub := range c.commands {
+		if sub.hidden {
+			continue
+		}
+		fmt.Fprintf(w, "  %-16s %s\n", strings.TrimSpace(path+" "+sub.name), sub.short)
+	}
+}
+
+// noArgs returns an error if a command without arguments is given any, e.g., a misspelled subcommand
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return nil
+}
+
+// newCommands returns the command tree of radikron with the configuration file conf;
+// without a command, radikron runs the main loop as the run command
+func newCommands(conf string, stdin io.Reader, stdout io.Writer) *command {
+	runCmd := &command{
+		name:  "run",
+		short: "monitor the program guides and download the matched programs (default)",
+		run: func(args []string) error {
+			if err := noArgs(args); err != nil {
+				return err
+			}
+			return daemon(conf, stdout)
+		},
+	}
+	initCmd := &command{
+		name:  "init",
+		short: "write a commented config file from the answers to a wizard",
+		run: func([]string) error {
+			return initConfig(conf, stdin, stdout, radiko.AreaID)
+		},
+	}
+	checkCmd := &command{
+		name:  "check",
+		short: "report the rules shadowing each other",
+		run: func([]string) error {
+			_, err := check(conf, stdout)
+			return err
+		},
+	}
+	return &command{
+		name: "radikron",
+		run:  runCmd.run,
+		commands: []*command{
+			runCmd,
+			{
+				name:  "plan",
+				short: "print the programs the rules match in the program guides without downloading them",
+				run: func(args []string) error {
+					if err := noArgs(args); err != nil {
+						return err
+					}
+					client, err := radiko.New("")
+					if err != nil {
+						return fmt.Errorf("failed to create radiko client: %w", err)
+					}
+					_, err = plan(conf, client, radikron.NewAsset, &radikronProgramFetcher{}, stdout)
+					return err
+				},
+			},
+			{
+				name:  "backfill",
+				short: "download everything the rules match in the past week now, regardless of the next fetch",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+					ruleName := fs.String("rule", "", "backfill only the rule `name`.")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					if err := noArgs(fs.Args()); err != nil {
+						return err
+					}
+					client, err := radiko.New("")
+					if err != nil {
+						return fmt.Errorf("failed to create radiko client: %w", err)
+					}
+					_, err = backfill(conf, *ruleName, client, radikron.NewAsset, &radikronProgramFetcher{},
+						&radikronDownloader{}, interrupted(), stdout)
+					return err
+				},
+			},
+			{
+				name:    "record",
+				aliases: []string{"rec"},
+				short:   "download the programs of the share links and exit",
+				run: func(args []string) error {
+					refs, err := parseRecArgs(args, stdin)
+					if err != nil {
+						return err
+					}
+					return rec(conf, refs, interrupted())
+				},
+			},
+			{
+				name:  "search",
+				short: "search the program guides, or the downloads with --local",
+				run: func(args []string) error {
+					local, query, err := parseSearchArgs(args)
+					if err != nil {
+						return err
+					}
+					if local {
+						_, err = searchLocal(query, stdout)
+					} else {
+						_, err = searchGuide(conf, query, stdout)
+					}
+					return err
+				},
+			},
+			{
+				name:  "config",
+				short: "write, check, and validate the configuration",
+				commands: []*command{
+					initCmd,
+					checkCmd,
+					{
+						name:  "validate",
+						short: "validate the configuration and print the stations of each rule",
+						run: func([]string) error {
+							client, err := radiko.New("")
+							if err != nil {
+								return fmt.Errorf("failed to create radiko client: %w", err)
+							}
+							problems, err := validateConfig(conf, client, radikron.NewAsset, stdout)
+							if err != nil {
+								return err
+							}
+							if problems > 0 {
+								return errProblems
+							}
+							return nil
+						},
+					},
+				},
+			},
+			{
+				name:  "status",
+				short: "print the uptime, the next fetch, the downloads, the encodes, and the errors of the running radikron",
+				run: func(args []string) error {
+					if err := noArgs(args); err != nil {
+						return err
+					}
+					return status(context.Background(), time.Now(), stdout)
+				},
+			},
+			{
+				name:  "schedules",
+				short: "print the programs the running radikron waits for, queued, and downloads",
+				run: func(args []string) error {
+					if err := noArgs(args); err != nil {
+						return err
+					}
+					return schedules(context.Background(), stdout)
+				},
+			},
+			{
+				name:  "history",
+				short: "print the recent downloads",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("history", flag.ContinueOnError)
+					n := fs.Int("n", 20, "print the last `count` downloads (0 for all).")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					return history(*n, stdout)
+				},
+			},
+			{
+				name:  "stats",
+				short: "print the hourly refusals of radiko (403, 429, and the auth) with the download limits in effect",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+					days := fs.Int("days", 7, "print the last `days` (up to 30).")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					return stats(*days, time.Now(), stdout)
+				},
+			},
+			{
+				name:  "doctor",
+				short: "check the configuration, RADICRON_HOME, ffmpeg, radiko, the clock, and the auth",
+				run: func(args []string) error {
+					if err := noArgs(args); err != nil {
+						return err
+					}
+					client, err := radiko.New("")
+					if err != nil {
+						return fmt.Errorf("failed to create radiko client: %w", err)
+					}
+					if doctor(conf, defaultDoctorEnv(client), stdout) > 0 {
+						return errProblems
+					}
+					return nil
+				},
+			},
+			{
+				name:  "feeds",
+				short: "print the private podcast feed URLs, rotating the tokens of the devices given to --rotate",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("feeds", flag.ContinueOnError)
+					rotate := fs.String("rotate", "", "issue new tokens to the comma-separated `devices`, revoking their old feed URLs.")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					var devices []string
+					if *rotate != "" {
+						devices = strings.Split(*rotate, ",")
+					}
+					return feeds(conf, devices, stdout)
+				},
+			},
+			{
+				name:  "digest",
+				short: "write the weekly digest of the downloads, or print its URLs with --url",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+					printURLs := fs.Bool("url", false, "print the private URLs of the digest on the feed server instead.")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					return digest(conf, *printURLs, stdout)
+				},
+			},
+			{
+				name:  "encode",
+				short: "encode the deferred MP3 outputs now, after printing the estimate of the batch",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("encode", flag.ContinueOnError)
+					dryRun := fs.Bool("dry-run", false, "print the estimate of the CPU time and the output size without encoding.")
+					yes := fs.Bool("y", false, "encode without confirming.")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					client, err := radiko.New("")
+					if err != nil {
+						return fmt.Errorf("failed to create radiko client: %w", err)
+					}
+					return encode(conf, client, radikron.NewAsset, *dryRun, *yes, stdin, stdout, isTerminal(os.Stdin), interrupted())
+				},
+			},
+			{
+				name:  "prune",
+				short: "delete the old recordings by age and size, honoring the keep of the rules",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+					olderThan := fs.String("older-than", "", "delete the recordings older than the `age`, e.g., 90d or 720h, in the folders without a keep.")
+					maxSize := fs.Int64("max-size", 0, "delete the oldest recordings until the rest fit in the `MB`.")
+					folder := fs.String("folder", "", "prune only the `folder` under downloads.")
+					trash := fs.Bool("trash", false, "move the recordings to ${RADICRON_HOME}/trash instead of deleting them.")
+					dryRun := fs.Bool("dry-run", false, "list the recordings without deleting them.")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					if err := noArgs(fs.Args()); err != nil {
+						return err
+					}
+					opts := radikron.PruneOptions{
+						MaxSize: *maxSize * radikron.Kilobytes * radikron.Kilobytes,
+						Folder:  *folder,
+						Trash:   *trash,
+						DryRun:  *dryRun,
+					}
+					if *olderThan != "" {
+						age, err := radikron.ParseWindow(*olderThan)
+						if err != nil || age <= 0 {
+							return fmt.Errorf("invalid -older-than %q, expected a duration like 720h or days like 90d", *olderThan)
+						}
+						opts.OlderThan = age
+					}
+					if *maxSize < 0 {
+						return fmt.Errorf("-max-size must not be negative: %d", *maxSize)
+					}
+					return prune(conf, opts, time.Now(), stdout)
+				},
+			},
+			{
+				name:  "install-ffmpeg",
+				short: "install a static build of ffmpeg",
+				run: func(args []string) error {
+					fs := flag.NewFlagSet("install-ffmpeg", flag.ContinueOnError)
+					url := fs.String("url", "", "the `url` of the static build for the platform.")
+					checksum := fs.String("sha256", "", "the SHA-256 `checksum` of the static build, verified before installing it.")
+					if err := fs.Parse(args); err != nil {
+						return err
+					}
+					if *url == "" {
+						return fmt.Errorf("no static build to install for %s/%s, pass its -url and -sha256", runtime.GOOS, runtime.GOARCH)
+					}
+					return installFFmpeg(*url, *checksum, stdout)
+				},
+			},
+			// the config subcommands at the top level before the command tree
+			{name: initCmd.name, hidden: true, run: initCmd.run},
+			{name: checkCmd.name, hidden: true, run: checkCmd.run},
+		},
+	}
+}
+
+// interrupted returns a channel closed on SIGINT or SIGTERM
+func interrupted() <-chan struct{} {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-quit
+		close(done)
+	}()
+	return done
+}
+
+// daemon runs the main loop until SIGINT or SIGTERM, and waits for the downloads in progress to abort
+func daemon(conf string, stdout io.Writer) error {
+	// Look up ffmpeg for the mp3 and m4a outputs, telling how to install it
+	warnMissingFFmpeg(conf, stdout)
+
+	// Never run against the RADICRON_HOME of another radikron, which would download the same programs
+	lock, err := radikron.AcquireInstanceLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	log.Println("starting radikron")
+
+	// Create done channel for graceful shutdown
+	done := interrupted()
+
+	// Serve the control API to the other commands, e.g., schedules
+	controlCtx, stopControl := context.WithCancel(context.Background())
+	controlServed := make(chan struct{})
+	go func() {
+		defer close(controlServed)
+		if err := radikron.ServeControl(controlCtx); err != nil {
+			log.Printf("failed to serve the control socket: %v", err)
+		}
+	}()
+
+	// Run main loop in goroutine
+	wg := sync.WaitGroup{}
+	go func() {
+		if err := runWithDefaults(&wg, conf, done); err != nil {
+			log.Fatalf("fatal error in main loop: %v", err)
+		}
+	}()
+
+	// Wait for signal
+	<-done
+	notifySystemd(sdStopping)
+
+	// Abort downloads in progress, keeping the completed segments to resume them
+	log.Println("exit once all the downloads in progress are aborted")
+	wg.Wait()
+	stopControl()
+	<-controlServed
+
+	// Free the premium sessions for the other devices of the account
+	ctx, cancel := context.WithTimeout(context.Background(), poolShutdownTimeout)
+	radikron.LogoutPremium(ctx)
+	cancel()
+	if err := radikron.FlushAPISignals(); err != nil {
+		log.Printf("failed to record the radiko responses: %v", err)
+	}
+	log.Println("exiting radikron")
+	return nil
+}
+
+// planCollector is the Downloader of plan, collecting the matched programs instead of downloading them
+type planCollector struct {
+	progs radikron.Progs
+}
+
+func (c *planCollector) Download(_ context.Context, _ *sync.WaitGroup, prog *radikron.Prog) error {
+	c.progs = append(c.progs, prog)
+	return nil
+}
+
+// plan prints the programs the rules match in the weekly guides of the configured stations in the order of the start,
+// and whether each is recorded from timefree or live, without downloading them; it returns the number of the programs
+func plan(configFileName string, client *radiko.Client, assetCreator AssetCreator, fetcher ProgramFetcher, w io.Writer) (int, error) {
+	asset, err := assetCreator(client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer shutdownPools(asset)
+	ctx := context.WithValue(context.Background(), contextKey, asset)
+	cfg, err := reloadConfig(ctx, configFileName, time.Now, defaultTimeSetter)
+	if err != nil {
+		return 0, err
+	}
+
+	collector := &planCollector{}
+	processStations(ctx, &sync.WaitGroup{}, asset, cfg.Rules, fetcher, collector)
+	progs := collector.progs
+	sort.SliceStable(progs, func(i, j int) bool { return progs[i].Ft < progs[j].Ft })
+	for _, p := range progs {
+		source := "timefree"
+		if asset.RecordsLive(p) {
+			source = "live"
+		}
+		fmt.Fprintf(w, "%s [%s] %s (rule[%s], %s)\n", p.Ft, p.StationID, p.Title, p.RuleName, source)
+	}
+	fmt.Fprintf(w, "%d programs match the rules in %s\n", len(progs), configFileName)
+	return len(progs), nil
+}
+
+// backfillDownloader downloads the programs ended by now, printing them, and skips the rest
+type backfillDownloader struct {
+	Downloader
+	now     time.Time
+	w       io.Writer
+	started int
+}
+
+func (d *backfillDownloader) Download(ctx context.Context, wg *sync.WaitGroup, prog *radikron.Prog) error {
+	if _, to, err := radikron.ProgramSpan(prog); err != nil || to.After(d.now) {
+		return nil
+	}
+	fmt.Fprintf(d.w, "%s [%s] %s (rule[%s])\n", prog.Ft, prog.StationID, prog.Title, prog.RuleName)
+	d.started++
+	return d.Downloader.Download(ctx, wg, prog)
+}
+
+// backfill downloads the programs the rules, or the rule named, match in the past week of the guides of all
+// the available stations right away, regardless of the next fetch time, e.g., on a new machine or after adding
+// a rule late in the week; the programs already downloaded are skipped as usual, and it returns the number matched
+func backfill(
+	configFileName, ruleName string,
+	client *radiko.Client,
+	assetCreator AssetCreator,
+	fetcher ProgramFetcher,
+	downloader Downloader,
+	done <-chan struct{},
+	w io.Writer,
+) (int, error) {
+	// the running radikron downloads the same programs and records the same history
+	lock, err := radikron.AcquireInstanceLock()
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Release()
+
+	if err := radikron.MigrateState(); err != nil {
+		return 0, fmt.Errorf("failed to migrate the state: %w", err)
+	}
+	asset, err := assetCreator(client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer shutdownPools(asset)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey, asset))
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	cfg, err := reloadConfig(ctx, configFileName, time.Now, defaultTimeSetter)
+	if err != nil {
+		return 0, err
+	}
+	rules := cfg.Rules
+	if ruleName != "" {
+		rules = nil
+		for _, r := range cfg.Rules {
+			if r.Name == ruleName {
+				rules = append(rules, r)
+			}
+		}
+		if len(rules) == 0 {
+			return 0, fmt.Errorf("no rule named %q in %s", ruleName, configFileName)
+		}
+	}
+	radikron.RefreshOutputIndex(ctx)
+
+	wg := sync.WaitGroup{}
+	backfiller := &backfillDownloader{Downloader: downloader, now: time.Now(), w: w}
+	for _, stationID := range asset.AvailableStations {
+		processStation(ctx, &wg, stationID, rules, fetcher, backfiller)
+	}
+	wg.Wait()
+	radikron.EncodeDeferred(ctx)
+	fmt.Fprintf(w, "%d programs in the past week match the rules in %s\n", backfiller.started, configFileName)
+	return backfiller.started, nil
+}
+
+// status prints the status of the running radikron at now
+func status(ctx context.Context, now time.Time, w io.Writer) error {
+	s, err := radikron.FetchStatus(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "uptime: %s (since %s)\n", now.Sub(s.Started).Round(time.Second), s.Started.Format(time.DateTime))
+	if s.NextFetch != nil {
+		fmt.Fprintf(w, "next fetch: %s (in %s)\n", s.NextFetch.Format(time.DateTime), s.NextFetch.Sub(now).Round(time.Second))
+	} else {
+		fmt.Fprintln(w, "next fetch: fetching")
+	}
+	fmt.Fprintf(w, "fetch duration: %s on average\n", s.FetchDuration.Round(time.Second))
+	fmt.Fprintf(w, "downloads: %d\n", len(s.Downloads))
+	for _, d := range s.Downloads {
+		fmt.Fprintf(w, "  %s [%s] %s (rule[%s])\n", d.Ft, d.StationID, d.Title, d.Rule)
+	}
+	fmt.Fprintf(w, "encodes: %d running or waiting, %d deferred\n", s.Encodes, s.Deferred)
+	fmt.Fprintf(w, "radiko refusals in 24h: %s\n", formatAPISignals(s.APISignals))
+	fmt.Fprintf(w, "recent errors: %d\n", len(s.Errors))
+	for _, e := range s.Errors {
+		fmt.Fprintf(w, "  %s %s\n", e.Time.Format(time.DateTime), e.Message)
+	}
+	return nil
+}
+
+// textStatusErrors is how many of the last errors textStatus reads out
+const textStatusErrors = 3
+
+// textStatus prints the status of the running radikron at now in short plain sentences, one per line,
+// without the tables and the symbols, e.g., for the screen readers: whether monitoring, the next fetch,
+// the pending downloads, and the last errors
+func textStatus(ctx context.Context, now time.Time, w io.Writer) error {
+	s, err := radikron.FetchStatus(ctx)
+	if errors.Is(err, radikron.ErrNotRunning) {
+		fmt.Fprintln(w, "Monitoring is off. radikron is not running.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Monitoring is on, for %s.\n", spokenDuration(now.Sub(s.Started)))
+	if s.NextFetch != nil {
+		fmt.Fprintf(w, "Next fetch at %s, in %s.\n", s.NextFetch.In(radikron.Location).Format("15:04 on January 2"),
+			spokenDuration(s.NextFetch.Sub(now)))
+	} else {
+		fmt.Fprintln(w, "Fetching the program guides now.")
+	}
+	switch len(s.Downloads) {
+	case 0:
+		fmt.Fprintln(w, "No pending downloads.")
+	case 1:
+		fmt.Fprintln(w, "1 pending download.")
+	default:
+		fmt.Fprintf(w, "%d pending downloads.\n", len(s.Downloads))
+	}
+	for _, d := range s.Downloads {
+		broadcast := d.Ft
+		if ft, err := radikron.ParseDatetime(d.Ft); err == nil {
+			broadcast = ft.Format("15:04 on January 2")
+		}
+		fmt.Fprintf(w, "Downloading %s on %s, broadcast at %s.\n", d.Title, d.StationID, broadcast)
+	}
+	if s.Deferred > 0 {
+		fmt.Fprintf(w, "%d encodings wait for the encoding window.\n", s.Deferred)
+	}
+	switch len(s.Errors) {
+	case 0:
+		fmt.Fprintln(w, "No recent errors.")
+		return nil
+	case 1:
+		fmt.Fprintln(w, "1 recent error.")
+	default:
+		fmt.Fprintf(w, "%d recent errors.\n", len(s.Errors))
+	}
+	// the last ones first
+	for i := len(s.Errors) - 1; i >= max(0, len(s.Errors)-textStatusErrors); i-- {
+		e := s.Errors[i]
+		fmt.Fprintf(w, "Error %s ago: %s\n", spokenDuration(now.Sub(e.Time)), strings.TrimSuffix(e.Message, "."))
+	}
+	return nil
+}
+
+// spokenDuration returns the duration in words to the minute, e.g., "2 hours 5 minutes", or "less than a minute"
+func spokenDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	var parts []string
+	for _, unit := range []struct {
+		d    time.Duration
+		name string
+	}{{radikron.OneDay * time.Hour, "day"}, {time.Hour, "hour"}, {time.Minute, "minute"}} {
+		n := int(d / unit.d)
+		d -= time.Duration(n) * unit.d
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+unit.name)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, unit.name))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// schedules prints the programs the running radikron waits for, queued, and downloads, in the order of the start
+func schedules(ctx context.Context, w io.Writer) error {
+	entries, err := radikron.FetchSchedules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s [%s] %s (rule[%s], %s)\n", e.Ft, e.StationID, e.Title, e.Rule, e.State)
+	}
+	fmt.Fprintf(w, "%d programs scheduled\n", len(entries))
+	return nil
+}
+
+// history prints the last n downloads in the history, oldest first, or all of them if n is 0
+func history(n int, w io.Writer) error {
+	records, err := radikron.LoadHistory()
+	if err != nil {
+		return err
+	}
+	if n > 0 && len(records) > n {
+		records = records[len(records)-n:]
+	}
+	for _, r := range records {
+		fmt.Fprintf(w, "%s [%s] %s (%.1f MB in %.0fs)", r.Ft, r.StationID, r.Title,
+			float64(r.Bytes)/radikron.Kilobytes/radikron.Kilobytes, r.Seconds)
+		if r.Path != "" {
+			fmt.Fprintf(w, " - %s", r.Path)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d downloads\n", len(records))
+	return nil
+}
+
+// stats prints the hourly counts of the radiko responses refusing the requests in the last days,
+// with the download limits in effect, so that the settings getting throttled can be told
+func stats(days int, now time.Time, w io.Writer) error {
+	recent, err := radikron.LoadAPISignals(now.Add(-time.Duration(days) * radikron.OneDay * time.Hour))
+	if err != nil {
+		return err
+	}
+	signals := recent[:0]
+	for _, s := range recent {
+		if !s.Hour.After(now) {
+			signals = append(signals, s)
+		}
+	}
+	for _, s := range signals {
+		rate := "unlimited"
+		if s.RequestsPerSecond > 0 {
+			rate = fmt.Sprintf("%g/s", s.RequestsPerSecond)
+		}
+		fmt.Fprintf(w, "%s concurrency %d, rate %s: %s\n", s.Hour.In(radikron.Location).Format("2006-01-02 15:04"),
+			s.MaxDownloadingConcurrency, rate, formatAPISignals(s))
+	}
+	fmt.Fprintf(w, "total in %d days: %s\n", days, formatAPISignals(radikron.SumAPISignals(signals)))
+	return nil
+}
+
+// formatAPISignals returns the refusals of the counts in words
+func formatAPISignals(s radikron.APISignalStats) string {
+	return fmt.Sprintf("%d of %d requests (403: %d, 429: %d, auth rejected: %d)",
+		s.Throttled(), s.Requests, s.Forbidden, s.TooManyRequests, s.AuthRejected)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/iomz/radikron"
+	"github.com/iomz/radikron/internal/config"
+	"github.com/yyoshiki41/go-radiko"
+	"github.com/yyoshiki41/radigo"
+)
+
+const (
+	// doctorTimeout limits the checks of doctor reaching radiko
+	doctorTimeout = 30 * time.Second
+	// clockSkewTolerance is the clock skew against radiko doctor accepts, as the programs start on the minute in JST
+	clockSkewTolerance = 30 * time.Second
+)
+
+// doctorEnv is what doctor checks with, replaced in the tests
+type doctorEnv struct {
+	client       *radiko.Client
+	assetCreator AssetCreator
+	// serverTime returns the time of radiko, and authorize gets a token for the area
+	serverTime func(ctx context.Context) (time.Time, error)
+	authorize  func(ctx context.Context, asset *radikron.Asset, areaID string) error
+	now        func() time.Time
+}
+
+// defaultDoctorEnv returns the doctorEnv checking with radiko
+func defaultDoctorEnv(client *radiko.Client) doctorEnv {
+	return doctorEnv{
+		client:       client,
+		assetCreator: radikron.NewAsset,
+		serverTime:   radikron.RadikoServerTime,
+		authorize: func(ctx context.Context, asset *radikron.Asset, areaID string) error {
+			_, err := asset.NewDevice(ctx, areaID)
+			return err
+		},
+		now: time.Now,
+	}
+}
+
+// doctor checks the configuration, RADICRON_HOME, ffmpeg, radiko, the clock, and the auth of the configured areas,
+// which most of the problems running radikron come from, printing whether each passes; it returns the failed checks
+func doctor(configFileName string, env doctorEnv, w io.Writer) int {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	checks, failed := 0, 0
+	report := func(name, detail string, err error) {
+		checks++
+		if err != nil {
+			fmt.Fprintf(w, "[fail] %s: %v\n", name, err)
+			failed++
+			return
+		}
+		fmt.Fprintf(w, "[pass] %s: %s\n", name, detail)
+	}
+
+	cfg, err := config.LoadConfig(configFileName)
+	if err != nil {
+		report("config", "", fmt.Errorf("%s: %w", configFileName, err))
+		cfg = nil
+	} else {
+		report("config", fmt.Sprintf("%s: %d rules in %s, %d overlaps",
+			configFileName, len(cfg.Rules), strings.Join(cfg.AreaIDs, ","), len(cfg.Rules.Overlaps())), nil)
+	}
+
+	// LoadConfig sets RADICRON_HOME if not set
+	paths, warnings, err := validatePaths(cfg)
+	report("RADICRON_HOME", strings.Join(append([]string{paths.Home + " is writable"}, warnings...), "; "), err)
+
+	detail, err := checkFFmpeg(ctx, cfg)
+	report("ffmpeg", detail, err)
+
+	serverTime, err := env.serverTime(ctx)
+	report("radiko", fmt.Sprintf("reachable at %s JST", serverTime.In(radikron.Location).Format(time.DateTime)), err)
+	if err != nil {
+		report("clock", "", errors.New("radiko is unreachable to compare with"))
+	} else {
+		skew := env.now().Sub(serverTime)
+		if skew.Abs() > clockSkewTolerance {
+			err = fmt.Errorf("the clock is off by %s from radiko (%s JST), sync it with NTP",
+				skew.Round(time.Second), serverTime.In(radikron.Location).Format(time.DateTime))
+		}
+		report("clock", fmt.Sprintf("off by %s from radiko", skew.Round(time.Second)), err)
+	}
+
+	report("auth", strings.Join(cfgAreaIDs(cfg), ",")+" authorized", checkAuth(ctx, cfg, env))
+
+	fmt.Fprintf(w, "%d checks, %d failed\n", checks, failed)
+	return failed
+}
+
+// validatePaths resolves and validates RADICRON_HOME, tmp, and the download-dir of the configuration,
+// or the default download-dir without a valid configuration
+func validatePaths(cfg *config.Config) (radikron.RadicronPaths, []string, error) {
+	downloadDir := radikron.DefaultDownloadDir
+	if cfg != nil {
+		downloadDir = cfg.DownloadDir
+	}
+	paths, err := radikron.ResolvePaths(downloadDir)
+	if err != nil {
+		return paths, nil, err
+	}
+	warnings, err := radikron.ValidatePaths(paths)
+	return paths, warnings, err
+}
+
+// checkFFmpeg returns the version of ffmpeg, or an error if the file-format needs ffmpeg but none is found
+func checkFFmpeg(ctx context.Context, cfg *config.Config) (string, error) {
+	fileFormat, ffmpegPath := radigo.AudioFormatAAC, ""
+	if cfg != nil {
+		fileFormat, ffmpegPath = cfg.FileFormat, cfg.FFmpegPath
+	}
+	found, err := radikron.FindFFmpeg(ffmpegPath)
+	if err != nil {
+		if fileFormat != radigo.AudioFormatMP3 && fileFormat != radikron.AudioFormatM4A {
+			return fmt.Sprintf("not found, not needed for %s", fileFormat), nil
+		}
+		return "", fmt.Errorf("file-format %s needs %w; run `radikron install-ffmpeg -url <url> -sha256 <checksum>`", fileFormat, err)
+	}
+	version, err := radikron.FFmpegVersion(ctx, found)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s)", version, found), nil
+}
+
+// checkAuth returns an error unless a token is acquired for each of the configured areas
+func checkAuth(ctx context.Context, cfg *config.Config, env doctorEnv) error {
+	areaIDs := cfgAreaIDs(cfg)
+	if len(areaIDs) == 0 {
+		return errors.New("no area to authorize")
+	}
+	asset, err := env.assetCreator(env.client)
+	if err != nil {
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer shutdownPools(asset)
+	for _, areaID := range areaIDs {
+		if err := env.authorize(ctx, asset, areaID); err != nil {
+			return fmt.Errorf("failed to get a token for %s: %w", areaID, err)
+		}
+	}
+	return nil
+}
+
+// cfgAreaIDs returns the configured areas, or none without a valid configuration
+func cfgAreaIDs(cfg *config.Config) []string {
+	if cfg == nil {
+		return nil
+	}
+	return cfg.AreaIDs
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/iomz/radikron"
+	"github.com/iomz/radikron/internal/config"
+	"github.com/yyoshiki41/radigo"
+	"gopkg.in/yaml.v3"
+)
+
+// prompter asks the questions of the init wizard on w and reads the answers from in
+type prompter struct {
+	in *bufio.Reader
+	w  io.Writer
+}
+
+// ask returns the answer to the question, or def if empty or at the end of the input
+func (p *prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", question)
+	}
+	answer, _ := p.in.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// askValid asks the question again until valid accepts the answer
+func (p *prompter) askValid(question, def string, valid func(string) error) string {
+	for {
+		answer := p.ask(question, def)
+		err := valid(answer)
+		if err == nil {
+			return answer
+		}
+		fmt.Fprintf(p.w, "  %v\n", err)
+		if _, peekErr := p.in.Peek(1); peekErr != nil {
+			return def // no more answers
+		}
+	}
+}
+
+// confirm returns true if the answer to the yes/no question is yes
+func (p *prompter) confirm(question string, def bool) bool {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	switch strings.ToLower(p.ask(fmt.Sprintf("%s (%s)", question, choices), "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
+
+// initAnswers are the answers to the init wizard
+type initAnswers struct {
+	AreaID       string
+	DetectedArea string
+	DownloadDir  string
+	FileFormat   string
+	Concurrency  int
+	Rule         *initRule
+}
+
+// initRule is the first rule created by the init wizard
+type initRule struct {
+	Name      string
+	StationID string
+	Title     string
+	Keyword   string
+	Folder    string
+}
+
+func validAreaID(s string) error {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "JP"))
+	if !strings.HasPrefix(s, "JP") || err != nil || n < 1 || n > 47 {
+		return fmt.Errorf("invalid area ID %q, expected JP1 to JP47", s)
+	}
+	return nil
+}
+
+func validFileFormat(s string) error {
+	switch s {
+	case radigo.AudioFormatAAC, radigo.AudioFormatMP3, radikron.AudioFormatM4A:
+		return nil
+	}
+	return fmt.Errorf("unsupported audio format %q, expected aac, mp3, or m4a", s)
+}
+
+func validConcurrency(s string) error {
+	if n, err := strconv.Atoi(s); err != nil || n < 1 {
+		return fmt.Errorf("invalid concurrency %q, expected a positive number", s)
+	}
+	return nil
+}
+
+// initConfig asks for the essential settings and a first rule, and writes them to a commented config file,
+// the area defaulting to the one detectArea returns
+func initConfig(configFileName string, in io.Reader, w io.Writer, detectArea func() (string, error)) error {
+	p := &prompter{in: bufio.NewReader(in), w: w}
+	if _, err := os.Stat(configFileName); err == nil {
+		if !p.confirm(fmt.Sprintf("%s exists, overwrite it?", configFileName), false) {
+			return fmt.Errorf("%s exists", configFileName)
+		}
+	}
+
+	a := initAnswers{DetectedArea: radikron.DefaultArea}
+	if areaID, err := detectArea(); err == nil && validAreaID(areaID) == nil {
+		a.DetectedArea = areaID
+	}
+	a.AreaID = p.askValid("Area ID of the stations to record", a.DetectedArea, validAreaID)
+	a.DownloadDir = p.ask("Download directory in RADICRON_HOME", "downloads")
+	a.FileFormat = p.askValid("Audio format (aac, mp3, or m4a)", radigo.AudioFormatAAC, validFileFormat)
+	a.Concurrency, _ = strconv.Atoi(p.askValid("Programs to download at once",
+		strconv.Itoa(radikron.MaxDownloadingConcurrency), validConcurrency))
+
+	if p.confirm("Create a first rule?", true) {
+		r := &initRule{}
+		r.Name = p.ask("Rule name", "my-show")
+		r.Title = p.ask("Title to match (empty for none)", "")
+		r.Keyword = p.ask("Keyword to match in the title or the description (empty for none)", "")
+		r.StationID = strings.ToUpper(p.ask("Station ID, e.g., TBS (empty for all the stations)", ""))
+		r.Folder = p.ask("Folder of the downloads", r.Name)
+		if r.Title == "" && r.Keyword == "" {
+			fmt.Fprintln(w, "  skipping the rule matching every program")
+		} else {
+			a.Rule = r
+		}
+	}
+
+	if err := os.WriteFile(configFileName, renderInitConfig(&a), config.FilePermissions); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configFileName, err)
+	}
+	if _, err := config.LoadConfig(configFileName); err != nil {
+		return errors.Join(fmt.Errorf("wrote an invalid %s", configFileName), err)
+	}
+	fmt.Fprintf(w, "wrote %s; check it with `radikron -c %s config check`\n", configFileName, configFileName)
+	return nil
+}
+
+// yamlScalar returns s as a YAML scalar, quoted if needed
+func yamlScalar(s string) string {
+	blob, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSuffix(string(blob), "\n")
+}
+
+// renderInitConfig returns the config file of the answers with the comments on each setting
+func renderInitConfig(a *initAnswers) []byte {
+	var b strings.Builder
+	fmt.Fprintln(&b, "# radikron configuration generated by `radikron config init`")
+	fmt.Fprintln(&b, "# See config.yml.template and the README for all the options.")
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "# The area of the stations to record (detected: %s)\n", a.DetectedArea)
+	fmt.Fprintf(&b, "area-id: %s\n", yamlScalar(a.AreaID))
+	fmt.Fprintln(&b, "# The directory of the downloads in RADICRON_HOME")
+	fmt.Fprintf(&b, "downloads: %s\n", yamlScalar(a.DownloadDir))
+	fmt.Fprintln(&b, "# aac saves the stream as is; mp3 and m4a need ffmpeg")
+	fmt.Fprintf(&b, "file-format: %s\n", yamlScalar(a.FileFormat))
+	fmt.Fprintln(&b, "# The programs downloading at once")
+	fmt.Fprintf(&b, "max-downloading-concurrency: %d\n", a.Concurrency)
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "# The rules to match the programs with title, keyword, pfm, station-id, dow, window, or genre")
+	if a.Rule == nil {
+		fmt.Fprintln(&b, "# rules:")
+		fmt.Fprintln(&b, "#   my-show:")
+		fmt.Fprintln(&b, "#     title: My Show")
+		fmt.Fprintln(&b, "#     folder: my-show")
+		return []byte(b.String())
+	}
+	r := a.Rule
+	fmt.Fprintln(&b, "rules:")
+	fmt.Fprintf(&b, "  %s:\n", yamlScalar(r.Name))
+	if r.StationID != "" {
+		fmt.Fprintf(&b, "    station-id: %s\n", yamlScalar(r.StationID))
+	}
+	if r.Title != "" {
+		fmt.Fprintf(&b, "    title: %s\n", yamlScalar(r.Title))
+	}
+	if r.Keyword != "" {
+		fmt.Fprintf(&b, "    keyword: %s\n", yamlScalar(r.Keyword))
+	}
+	if r.Folder != "" {
+		fmt.Fprintf(&b, "    folder: %s # in the download directory\n", yamlScalar(r.Folder))
+	}
+	return []byte(b.String())
+}
@@ -1,25 +1,33 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/url"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/iomz/radikron"
 	"github.com/iomz/radikron/internal/config"
 	"github.com/yyoshiki41/go-radiko"
+	"github.com/yyoshiki41/radigo"
 )
 
 // ProgramFetcher is an interface for fetching weekly programs
 type ProgramFetcher interface {
-	FetchWeeklyPrograms(stationID string) (radikron.Progs, error)
+	FetchWeeklyPrograms(ctx context.Context, stationID string) (radikron.Progs, error)
 }
 
 // Downloader is an interface for downloading programs
@@ -44,11 +52,20 @@ func defaultTimeSetter(t time.Time) {
 // contextKey is the key used to store asset in context
 var contextKey = radikron.ContextKey("asset")
 
+// startupSummary reports the effective configuration only on the first iteration
+var startupSummary sync.Once
+
+// poolShutdownTimeout is how long to wait for the running jobs of the worker pools on shutdown
+const poolShutdownTimeout = 30 * time.Second
+
+// startupRecovery scans the downloads for the broken files only on the first iteration
+var startupRecovery sync.Once
+
 // radikronProgramFetcher implements ProgramFetcher using radikron.FetchWeeklyPrograms
 type radikronProgramFetcher struct{}
 
-func (f *radikronProgramFetcher) FetchWeeklyPrograms(stationID string) (radikron.Progs, error) {
-	return radikron.FetchWeeklyPrograms(stationID)
+func (f *radikronProgramFetcher) FetchWeeklyPrograms(ctx context.Context, stationID string) (radikron.Progs, error) {
+	return radikron.FetchWeeklyPrograms(ctx, stationID)
 }
 
 // radikronDownloader implements Downloader using radikron.Download
@@ -97,7 +114,7 @@ func processStation(
 	}
 
 	// Fetch weekly programs
-	weeklyPrograms, err := fetcher.FetchWeeklyPrograms(stationID)
+	weeklyPrograms, err := fetcher.FetchWeeklyPrograms(ctx, stationID)
 	if err != nil {
 		log.Printf("failed to fetch the %s program: %v", stationID, err)
 		return
@@ -107,8 +124,7 @@ func processStation(
 	// Process each program
 	for _, p := range weeklyPrograms {
 		if matchedRule := rules.FindMatch(stationID, p); matchedRule != nil {
-			p.RuleName = matchedRule.Name
-			p.RuleFolder = matchedRule.Folder
+			matchedRule.ApplyTo(p)
 			if err := downloader.Download(ctx, wg, p); err != nil {
 				log.Printf("download failed: %s", err)
 			}
@@ -116,7 +132,48 @@ func processStation(
 	}
 }
 
-// processStations processes all stations in the asset
+// recoverDownloads quarantines or removes the broken files left by a crash and re-queues their programs
+func recoverDownloads(ctx context.Context, asset *radikron.Asset) {
+	recovered, err := radikron.RecoverDownloads(ctx, asset)
+	if err != nil {
+		log.Printf("failed to recover the downloads: %v", err)
+		return
+	}
+	if recovered > 0 {
+		log.Printf("recovered %d broken files left by a previous run", recovered)
+	}
+}
+
+// cleanupTempDirs removes the stale aac dirs left in the tmp dir by a crash
+func cleanupTempDirs(ctx context.Context) {
+	removed, freed, err := radikron.CleanupTempDirs(ctx)
+	if err != nil {
+		log.Printf("failed to clean up the tmp dir: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("removed %d stale aac dirs (%.1f MB) left by a previous run", removed, float64(freed)/radikron.Kilobytes/radikron.Kilobytes)
+	}
+}
+
+// resumeQueue downloads the programs left in the queue by a previous run
+func resumeQueue(ctx context.Context, wg *sync.WaitGroup, downloader Downloader) {
+	queued, err := radikron.PendingQueue(ctx)
+	if err != nil {
+		log.Printf("failed to load the queue: %v", err)
+		return
+	}
+	if len(queued) > 0 {
+		log.Printf("resuming %d queued programs", len(queued))
+	}
+	for _, p := range queued {
+		if err := downloader.Download(ctx, wg, p); err != nil {
+			log.Printf("download failed: %s", err)
+		}
+	}
+}
+
+// processStations processes the stations of the asset whose guides the rules need
 func processStations(
 	ctx context.Context,
 	wg *sync.WaitGroup,
@@ -125,13 +182,34 @@ func processStations(
 	fetcher ProgramFetcher,
 	downloader Downloader,
 ) {
-	for _, stationID := range asset.AvailableStations {
+	for _, stationID := range guideStations(asset, rules) {
 		processStation(ctx, wg, stationID, rules, fetcher, downloader)
 	}
 }
 
-// setNextFetchTime sets the next fetch time for the asset
+// guideStations returns the stations whose guides the rules can match a program in by the next fetch,
+// a day later at the latest if a rule has no day to match until then, to check it again
+func guideStations(asset *radikron.Asset, rules radikron.Rules) []string {
+	until := radikron.CurrentTime.Add(radikron.OneDay * time.Hour)
+	for _, r := range rules {
+		if !r.CanMatchUntil(until) {
+			log.Printf("rule[%s] matches no program until %s, skipping it", r.Name, until.Format(time.DateTime))
+			if asset.FetchSchedule == nil && (asset.NextFetchTime == nil || asset.NextFetchTime.After(until)) {
+				asset.NextFetchTime = &until
+			}
+		}
+	}
+	stations := rules.GuideStations(asset.AvailableStations, until)
+	if skipped := len(asset.AvailableStations) - len(stations); skipped > 0 {
+		log.Printf("fetching the guides of %d stations, skipping %d without a rule to match", len(stations), skipped)
+	}
+	return stations
+}
+
+// setNextFetchTime sets the next fetch time for the asset: by the fetch-schedule if any, or a day later
+// if no program sets it
 func setNextFetchTime(asset *radikron.Asset, currentTime time.Time) {
+	radikron.ScheduleFetch(asset, currentTime)
 	if asset.NextFetchTime == nil {
 		oneDayLater := currentTime.Add(radikron.OneDay * time.Hour)
 		asset.NextFetchTime = &oneDayLater
@@ -148,11 +226,16 @@ func runIteration(
 	timeProvider TimeProvider,
 	timeSetter TimeSetter,
 ) error {
+	started := timeProvider()
+
 	// Load and apply configuration
 	cfg, err := reloadConfig(ctx, configFileName, timeProvider, timeSetter)
 	if err != nil {
 		return fmt.Errorf("failed to reload config: %w", err)
 	}
+	if len(cfg.ActiveProfiles) > 0 {
+		log.Printf("active profiles: %s", strings.Join(cfg.ActiveProfiles, ", "))
+	}
 
 	// Get asset from context
 	asset := radikron.GetAsset(ctx)
@@ -160,12 +243,46 @@ func runIteration(
 		return fmt.Errorf("asset not found in context")
 	}
 
+	// Notify the saved and the given-up programs on the desktop
+	if cfg.DesktopNotifications && radikron.GetEventEmitter(ctx) == nil {
+		ctx = context.WithValue(ctx, radikron.ContextKey("eventEmitter"), newDesktopNotifier(asset))
+	}
+
+	startupSummary.Do(func() {
+		radikron.EmitConfigSummary(ctx, cfg.Summary(asset))
+	})
+
+	// Recover the broken files left by a crash before resuming the queue, which they may be added to
+	startupRecovery.Do(func() {
+		recoverDownloads(ctx, asset)
+		cleanupTempDirs(ctx)
+	})
+
+	// Index the saved outputs for the duplicate checks of this iteration
+	radikron.RefreshOutputIndex(ctx)
+
+	// Resume the programs queued before a restart, which may have dropped out of the program guide
+	resumeQueue(ctx, wg, downloader)
+
 	// Process all stations
 	processStations(ctx, wg, asset, cfg.Rules, fetcher, downloader)
+	// the next fetch starts earlier by the time until the downloads start
+	radikron.RecordFetchDuration(timeProvider().Sub(started))
 
-	// Wait for all downloads to complete
+	// Join the live streams of the programs the rules record live
+	radikron.RecordLive(ctx, wg)
+
+	// Wait for all downloads to complete, or reload the configuration with them in flight
 	log.Println("waiting for all the downloads to complete")
-	wg.Wait()
+	if !waitDownloads(wg, configFileName) {
+		// without the next fetch time, the main loop runs the next iteration right away
+		log.Println("reloading the configuration with the downloads in flight")
+		asset.NextFetchTime = nil
+		return nil
+	}
+
+	// Encode the deferred MP3 outputs in the background, not to delay the next fetch
+	radikron.StartDeferredEncoding(ctx)
 
 	// Set next fetch time
 	setNextFetchTime(asset, timeProvider())
@@ -175,6 +292,7 @@ func runIteration(
 
 // runLoopIteration runs a single iteration of the main loop and returns the asset for sleep calculation
 func runLoopIteration(
+	ctx context.Context,
 	wg *sync.WaitGroup,
 	configFileName string,
 	client *radiko.Client,
@@ -191,7 +309,7 @@ func runLoopIteration(
 	}
 
 	// Create context with asset
-	ctx := context.WithValue(context.Background(), contextKey, asset)
+	ctx = context.WithValue(ctx, contextKey, asset)
 
 	// Run single iteration
 	if err := runIteration(ctx, wg, configFileName, fetcher, downloader, timeProvider, timeSetter); err != nil {
@@ -215,6 +333,46 @@ func run(
 	timeSetter TimeSetter,
 	done <-chan struct{},
 ) error {
+	// Cancel the in-flight downloads on shutdown; they resume on the next start
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Drop the jobs still queued in the worker pools of the last asset on shutdown
+	var asset *radikron.Asset
+	defer func() { shutdownPools(asset) }()
+	feedsServed := false
+	webhooksServed := false
+	filesServed := false
+
+	// The downloads in flight keep their workers and limits across the reloads,
+	// as InitSemaphores and InitRateLimiter resize the inherited ones in place
+	createAsset := func(client *radiko.Client) (*radikron.Asset, error) {
+		next, err := assetCreator(client)
+		if err == nil && next != nil {
+			next.Inherit(asset)
+		}
+		return next, err
+	}
+
+	// Reload the configuration as soon as the config file changes or on SIGHUP, not at the next fetch
+	if err := watchConfig(configFileName, done); err != nil {
+		log.Printf("failed to watch %s: %v", configFileName, err)
+	}
+	watchReloadSignal(done)
+
+	// Tell systemd the service is up, and keep pinging its watchdog from the loop,
+	// so that systemd restarts radikron if an iteration hangs, e.g., on a stuck HTTP call
+	notifySystemd(sdReady)
+	watchdog, stopWatchdog := watchdogTicks()
+	defer stopWatchdog()
+
 	for {
 		select {
 		case <-done:
@@ -223,27 +381,118 @@ func run(
 		}
 
 		// Run single iteration
-		asset, err := runLoopIteration(wg, configFileName, client, assetCreator, fetcher, downloader, timeProvider, timeSetter)
+		var err error
+		asset, err = runLoopIteration(ctx, wg, configFileName, client, createAsset, fetcher, downloader, timeProvider, timeSetter)
 		if err != nil {
 			return err
 		}
+		radikron.SetStatusAsset(asset)
+		notifySystemd(sdWatchdog)
+
+		// Serve the podcast feeds from the first configuration; the feeds and their folders follow the reloads
+		if !feedsServed && asset != nil && asset.FeedListen != "" {
+			feedsServed = true
+			log.Printf("serving the podcast feeds on %s", asset.FeedListen)
+			go func(addr string) {
+				if err := radikron.ServeFeeds(ctx, addr, radikron.StatusAsset); err != nil {
+					log.Printf("failed to serve the podcast feeds: %v", err)
+				}
+			}(asset.FeedListen)
+		}
+
+		// Serve the inbound webhooks from the first configuration; the token follows the reloads
+		if !webhooksServed && asset != nil && asset.WebhookListen != "" {
+			webhooksServed = true
+			log.Printf("serving the webhooks on %s", asset.WebhookListen)
+			go func(addr string) {
+				if err := radikron.ServeWebhooks(ctx, addr, radikron.StatusAsset, requestFetch); err != nil {
+					log.Printf("failed to serve the webhooks: %v", err)
+				}
+			}(asset.WebhookListen)
+		}
+
+		// Serve the downloads from the first configuration; the credentials and the listing follow the reloads
+		if !filesServed && asset != nil && asset.FileServerListen != "" {
+			filesServed = true
+			log.Printf("serving the downloads on %s", asset.FileServerListen)
+			go func(addr string) {
+				if err := radikron.ServeFiles(ctx, addr, radikron.StatusAsset); err != nil {
+					log.Printf("failed to serve the downloads: %v", err)
+				}
+			}(asset.FileServerListen)
+		}
 
 		// Sleep until next fetch time
 		if asset != nil && asset.NextFetchTime != nil {
 			log.Printf("fetching completed – sleeping until %v", asset.NextFetchTime)
 			fetchTimer := time.NewTimer(time.Until(*asset.NextFetchTime))
-			select {
-			case <-done:
-				fetchTimer.Stop()
-				return nil
-			case <-fetchTimer.C:
+		sleep:
+			for {
+				select {
+				case <-done:
+					fetchTimer.Stop()
+					return nil
+				case <-fetchTimer.C:
+					break sleep
+				case <-watchdog:
+					notifySystemd(sdWatchdog)
+				case <-fetchRequests:
+					fetchTimer.Stop()
+					log.Println("fetching now as requested")
+					break sleep
+				case <-reloadRequests:
+					if configReloadable(configFileName) {
+						fetchTimer.Stop()
+						log.Println("reloading the configuration")
+						break sleep
+					}
+				}
 			}
 		}
 	}
 }
 
+// shutdownPools shuts down the worker pools of the asset, waiting for the running jobs
+func shutdownPools(asset *radikron.Asset) {
+	if asset == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), poolShutdownTimeout)
+	defer cancel()
+	if err := asset.Shutdown(ctx); err != nil {
+		log.Printf("failed to shut down the worker pools: %v", err)
+	}
+}
+
+// checkPaths validates the paths of the configuration at startup, logging the warnings;
+// a configuration failing to load is left to the main loop to report
+func checkPaths(configFileName string) error {
+	cfg, err := config.LoadConfig(configFileName)
+	if err != nil {
+		return nil
+	}
+	_, warnings, err := validatePaths(cfg)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		log.Printf("warning: %s", warning)
+	}
+	return nil
+}
+
 // runWithDefaults runs with default dependencies (for production use)
 func runWithDefaults(wg *sync.WaitGroup, configFileName string, done <-chan struct{}) error {
+	// Fail early on the paths losing the state, e.g., only the downloads mounted in Docker
+	if err := checkPaths(configFileName); err != nil {
+		return err
+	}
+
+	// Upgrade the persistent state left by older versions
+	if err := radikron.MigrateState(); err != nil {
+		return fmt.Errorf("failed to migrate the state: %w", err)
+	}
+
 	client, err := radiko.New("")
 	if err != nil {
 		return fmt.Errorf("failed to create radiko client: %w", err)
@@ -257,11 +506,462 @@ func runWithDefaults(wg *sync.WaitGroup, configFileName string, done <-chan stru
 	return run(wg, configFileName, client, radikron.NewAsset, fetcher, downloader, timeProvider, timeSetter, done)
 }
 
+// storeSecretFromReader stores the first line read from r as the secret name
+func storeSecretFromReader(name string, r io.Reader) error {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	secret := strings.TrimRight(line, "\r\n")
+	if secret == "" {
+		return errors.New("empty secret")
+	}
+	return radikron.StoreSecret(name, secret)
+}
+
+// parseRecArgs returns the share links given to the rec subcommand as arguments and in its --from-file
+// ("-" for stdin)
+func parseRecArgs(args []string, stdin io.Reader) ([]string, error) {
+	fs := flag.NewFlagSet("rec", flag.ContinueOnError)
+	fromFile := fs.String("from-file", "", "download the share links in `file`, one per line.")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	refs := fs.Args()
+	if *fromFile != "" {
+		r := stdin
+		if *fromFile != "-" {
+			f, err := os.Open(*fromFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		lines, err := radikron.ReadShareURLs(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", *fromFile, err)
+		}
+		refs = append(refs, lines...)
+	}
+	if len(refs) == 0 {
+		return nil, errors.New("no share links to download")
+	}
+	return refs, nil
+}
+
+// rec downloads the programs of the share links once with the configuration, e.g., to backfill a show
+func rec(configFileName string, refs []string, done <-chan struct{}) error {
+	// the running radikron may download the same programs
+	lock, err := radikron.AcquireInstanceLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if err := radikron.MigrateState(); err != nil {
+		return fmt.Errorf("failed to migrate the state: %w", err)
+	}
+	client, err := radiko.New("")
+	if err != nil {
+		return fmt.Errorf("failed to create radiko client: %w", err)
+	}
+	asset, err := radikron.NewAsset(client)
+	if err != nil {
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer shutdownPools(asset)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey, asset))
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if _, err := reloadConfig(ctx, configFileName, time.Now, defaultTimeSetter); err != nil {
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+	started, err := radikron.DownloadShareURLs(ctx, &wg, refs)
+	wg.Wait()
+	radikron.EncodeDeferred(ctx)
+	log.Printf("downloaded %d of %d share links", started, len(refs))
+	return err
+}
+
+// encode encodes the deferred MP3 outputs now, printing the estimate of the batch first and encoding it
+// only if confirmed, unless yes; with dryRun, it only prints the estimate
+func encode(
+	configFileName string,
+	client *radiko.Client,
+	assetCreator AssetCreator,
+	dryRun, yes bool,
+	in io.Reader,
+	w io.Writer,
+	interactive bool,
+	done <-chan struct{},
+) error {
+	// the running radikron encodes the same queue
+	lock, err := radikron.AcquireInstanceLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	asset, err := assetCreator(client)
+	if err != nil {
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer shutdownPools(asset)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey, asset))
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if _, err := reloadConfig(ctx, configFileName, time.Now, defaultTimeSetter); err != nil {
+		return err
+	}
+
+	est, err := radikron.EstimateDeferredEncoding(ctx)
+	if err != nil {
+		return err
+	}
+	if est.Jobs == 0 {
+		fmt.Fprintln(w, "no program to encode")
+		return nil
+	}
+	printEncodeEstimate(w, est, asset.MaxEncodingConcurrency)
+	if dryRun {
+		return nil
+	}
+	if !yes {
+		if !interactive {
+			return errors.New("pass -y to encode without confirming")
+		}
+		fmt.Fprintf(w, "encode %d programs? [y/N] ", est.Jobs)
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			return nil
+		}
+	}
+	radikron.EncodeDeferred(ctx)
+	return ctx.Err()
+}
+
+// printEncodeEstimate prints the length, the output size, and the CPU time of the batch,
+// and the time it takes with the encoders running at once
+func printEncodeEstimate(w io.Writer, est radikron.EncodeEstimate, concurrency int) {
+	fmt.Fprintf(w, "programs: %d (%s of audio)\n", est.Jobs, est.Duration.Round(time.Minute))
+	fmt.Fprintf(w, "output: about %.1f MB at %s\n", float64(est.OutputSize)/radikron.Kilobytes/radikron.Kilobytes, est.Bitrate)
+	cpu := est.CPUTime()
+	if cpu == 0 {
+		fmt.Fprintln(w, "CPU time: unknown, the encoding speed is not measured")
+		return
+	}
+	concurrency = max(concurrency, 1)
+	fmt.Fprintf(w, "CPU time: about %s at %.1fx real time, %s with %d encoders at once\n",
+		cpu.Round(time.Minute), est.Speed, (cpu / time.Duration(concurrency)).Round(time.Minute), concurrency)
+}
+
+// check loads the configuration and reports the overlapping rules, returning the number of the overlaps
+func check(configFileName string, w io.Writer) (int, error) {
+	cfg, err := config.LoadConfig(configFileName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load config: %w", err)
+	}
+	overlaps := cfg.Rules.Overlaps()
+	for _, o := range overlaps {
+		line := o.String()
+		if !o.SameFolder {
+			line += " (different folders)"
+		}
+		fmt.Fprintf(w, "warning: %s\n", line)
+	}
+	if len(cfg.ActiveProfiles) > 0 {
+		fmt.Fprintf(w, "active profiles: %s\n", strings.Join(cfg.ActiveProfiles, ", "))
+	}
+	fmt.Fprintf(w, "%s: %d rules, %d overlaps\n", configFileName, len(cfg.Rules), len(overlaps))
+	return len(overlaps), nil
+}
+
+// validateConfig validates the configuration, resolves the stations of the configured areas,
+// and prints the stations each rule scans, returning the number of the problems, e.g., a rule for an unknown station
+func validateConfig(configFileName string, client *radiko.Client, assetCreator AssetCreator, w io.Writer) (int, error) {
+	asset, err := assetCreator(client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer shutdownPools(asset)
+	ctx := context.WithValue(context.Background(), contextKey, asset)
+	cfg, err := reloadConfig(ctx, configFileName, time.Now, defaultTimeSetter)
+	if err != nil {
+		return 0, err
+	}
+
+	problems := 0
+	for _, r := range cfg.Rules {
+		stations := asset.AvailableStations
+		if r.HasStationID() {
+			if _, ok := asset.Stations[r.StationID]; !ok {
+				fmt.Fprintf(w, "error: rule[%s] has the station %s not on radiko\n", r.Name, r.StationID)
+				problems++
+				continue
+			}
+			stations = []string{r.StationID}
+		}
+		if len(stations) == 0 {
+			fmt.Fprintf(w, "error: rule[%s] scans no station\n", r.Name)
+			problems++
+			continue
+		}
+		fmt.Fprintf(w, "rule[%s]: %s\n", r.Name, strings.Join(stations, ", "))
+	}
+	fmt.Fprintf(w, "%s: %d rules in %s, %d problems\n", configFileName, len(cfg.Rules), strings.Join(cfg.AreaIDs, ","), problems)
+	return problems, nil
+}
+
+// setLogFormat logs in the format to w: text by the log package, with the source files in debug mode,
+// or json by slog, also for the log package, with the structured fields of the events
+func setLogFormat(format string, debug bool, w io.Writer) error {
+	switch format {
+	case "text":
+		if debug {
+			log.SetFlags(log.LstdFlags | log.Lshortfile)
+		}
+	case "json":
+		if debug {
+			// slog adds the source of the log package calls by the flag
+			log.SetFlags(log.Lshortfile)
+		}
+		slog.SetDefault(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{AddSource: debug})))
+		radikron.SetDefaultEventEmitter(radikron.NewSlogEventEmitter(slog.Default()))
+	default:
+		return fmt.Errorf("unsupported log-format: %s", format)
+	}
+	return nil
+}
+
+// usage returns the usage of the flags without the hidden debug flags
+func usage(fs *flag.FlagSet, hidden ...string) func() {
+	return func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		visible.SetOutput(fs.Output())
+		fs.VisitAll(func(f *flag.Flag) {
+			if !slices.Contains(hidden, f.Name) {
+				visible.Var(f.Value, f.Name, f.Usage)
+				visible.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+		visible.PrintDefaults()
+	}
+}
+
+// warnMissingFFmpeg looks up ffmpeg if the configured file-format needs it, and prints how to install a static build
+// if none is found, as the downloads still save the aac files
+func warnMissingFFmpeg(configFileName string, w io.Writer) {
+	cfg, err := config.LoadConfig(configFileName)
+	if err != nil {
+		return // the main loop reports the config error
+	}
+	if cfg.FileFormat != radigo.AudioFormatMP3 && cfg.FileFormat != radikron.AudioFormatM4A {
+		return
+	}
+	if _, err := radikron.FindFFmpeg(cfg.FFmpegPath); err != nil {
+		fmt.Fprintf(w, "file-format %s needs %v\n", cfg.FileFormat, err)
+		if cfg.FFmpegPath == "" {
+			fmt.Fprintln(w, "run `radikron install-ffmpeg -url <url> -sha256 <checksum>` to install a static build")
+		}
+	}
+}
+
+// installFFmpeg installs the static build of ffmpeg at url verified with the SHA-256 checksum
+func installFFmpeg(url, checksum string, w io.Writer) error {
+	ffmpegPath, err := radikron.InstallFFmpeg(context.Background(), url, checksum)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "installed ffmpeg at %s\n", ffmpegPath)
+	return nil
+}
+
+// isTerminal returns true if f is a terminal to prompt on
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// feeds prints the private podcast feed URL of each device in the configuration,
+// generating the missing tokens and the ones of the devices to rotate
+func feeds(configFileName string, rotate []string, w io.Writer) error {
+	cfg, err := config.LoadConfig(configFileName)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	names := make([]string, 0, len(cfg.Feeds))
+	for name := range cfg.Feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range rotate {
+		if _, ok := cfg.Feeds[name]; !ok {
+			return fmt.Errorf("no feed %s in %s", name, configFileName)
+		}
+	}
+	tokens, err := radikron.FeedTokens(names, rotate...)
+	if err != nil {
+		return err
+	}
+	base := cfg.FeedURLBase()
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s/feed/%s.xml (%s)\n", name, base, tokens[name], strings.Join(cfg.Feeds[name], ", "))
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(w, "%s: no feeds\n", configFileName)
+	}
+	return nil
+}
+
+// digest writes the page of the programs downloaded in the last 7 days, linked to the local files,
+// or prints the private URLs of the digest page and feed on the feed server
+func digest(configFileName string, printURLs bool, w io.Writer) error {
+	cfg, err := config.LoadConfig(configFileName)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if printURLs {
+		feedURL, pageURL, err := radikron.DigestURLs(cfg.FeedURLBase())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "page: %s\nfeed: %s\n", pageURL, feedURL)
+		return nil
+	}
+	items, err := radikron.WeeklyDigest(cfg.DownloadDir, time.Now())
+	if err != nil {
+		return err
+	}
+	return radikron.WriteDigestHTML(w, items, time.Now(), func(it radikron.DigestItem) string {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(it.File)}).String()
+	})
+}
+
+// prune deletes the old recordings by the options and the keep of the rules, or lists them in the dry run
+func prune(configFileName string, opts radikron.PruneOptions, now time.Time, w io.Writer) error {
+	cfg, err := config.LoadConfig(configFileName)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	pruned, err := radikron.PruneRecordings(cfg.DownloadDir, cfg.Rules, opts, now)
+	var freed int64
+	for _, r := range pruned {
+		freed += r.Size
+		fmt.Fprintf(w, "%s %s (%.1f MB, %s)\n", r.Modified.In(radikron.Location).Format(time.DateTime), r.Path,
+			float64(r.Size)/radikron.Kilobytes/radikron.Kilobytes, r.Reason)
+	}
+	action := "deleted"
+	switch {
+	case opts.DryRun:
+		action = "would delete"
+	case opts.Trash:
+		action = "moved to the trash"
+	}
+	fmt.Fprintf(w, "%s %d recordings (%.1f MB)\n", action, len(pruned), float64(freed)/radikron.Kilobytes/radikron.Kilobytes)
+	return err
+}
+
+// parseSearchArgs returns whether the search subcommand searches the downloads (--local) and its query
+func parseSearchArgs(args []string) (local bool, query string, err error) {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	localFlag := fs.Bool("local", false, "search the downloaded programs and their transcripts instead of the program guide.")
+	if err := fs.Parse(args); err != nil {
+		return false, "", err
+	}
+	query = strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		return false, "", errors.New("no query to search")
+	}
+	return *localFlag, query, nil
+}
+
+// searchLocal prints the downloaded programs matching the query, returning the number of the matches
+func searchLocal(query string, w io.Writer) (int, error) {
+	results, err := radikron.SearchDownloads(query)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range results {
+		fmt.Fprintf(w, "%s [%s] %s", r.Ft, r.StationID, r.Title)
+		if r.Path != "" {
+			fmt.Fprintf(w, " - %s", r.Path)
+		}
+		fmt.Fprintln(w)
+		if r.Snippet != "" {
+			source := "desc"
+			if r.Transcript {
+				source = "transcript"
+			}
+			fmt.Fprintf(w, "    %s: %s\n", source, r.Snippet)
+		}
+	}
+	fmt.Fprintf(w, "%d downloaded programs match %q\n", len(results), query)
+	return len(results), nil
+}
+
+// searchGuide prints the programs in the weekly guide of the configured stations matching the query,
+// returning the number of the matches
+func searchGuide(configFileName, query string, w io.Writer) (int, error) {
+	client, err := radiko.New("")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create radiko client: %w", err)
+	}
+	asset, err := radikron.NewAsset(client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create asset: %w", err)
+	}
+	defer shutdownPools(asset)
+	ctx := context.WithValue(context.Background(), contextKey, asset)
+	if _, err := reloadConfig(ctx, configFileName, time.Now, defaultTimeSetter); err != nil {
+		return 0, err
+	}
+
+	matched := 0
+	for _, stationID := range asset.AvailableStations {
+		progs, err := radikron.FetchWeeklyPrograms(ctx, stationID)
+		if err != nil {
+			log.Printf("failed to fetch the programs of %s: %v", stationID, err)
+			continue
+		}
+		for _, p := range radikron.SearchPrograms(progs, query) {
+			fmt.Fprintf(w, "%s [%s] %s\n", p.Ft, p.StationID, p.Title)
+			matched++
+		}
+	}
+	fmt.Fprintf(w, "%d programs in the guide match %q\n", matched, query)
+	return matched, nil
+}
+
 func main() {
 	// Parse flags
 	conf := flag.String("c", "config.yml", "the config.yml to use.")
 	enableDebug := flag.Bool("d", false, "enable debug mode.")
+	logFormat := flag.String("log-format", "text", "log in `format`: text, or json with the fields of each event.")
 	version := flag.Bool("v", false, "print version.")
+	setSecret := flag.String("set-secret", "", "store the secret read from stdin as `name` in the encrypted secrets file.")
+	printStatus := flag.Bool("status", false, "print the status of the running radikron in plain sentences, e.g., for a screen reader.")
+	simulateFailures := flag.Float64("simulate-failures", 0, "fail the downloads, the playlist fetches, and the encodes at random at the `rate` (0 to 1).")
+	flag.Usage = func() {
+		usage(flag.CommandLine, "simulate-failures")()
+		newCommands(*conf, os.Stdin, os.Stdout).printCommands(flag.CommandLine.Output(), "")
+	}
 	flag.Parse()
 
 	// Print version
@@ -271,34 +971,41 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Enable debug logging
-	if *enableDebug {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
+	// Store a secret for the config to refer to as secret:<name>
+	if *setSecret != "" {
+		if err := storeSecretFromReader(*setSecret, os.Stdin); err != nil {
+			log.Fatalf("failed to store the secret: %v", err)
+		}
+		fmt.Printf("stored secret:%s\n", *setSecret)
+		os.Exit(0)
 	}
 
-	log.Println("starting radikron")
-
-	// Setup signal handling
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	// Print the status without the tables and the symbols of the status command
+	if *printStatus {
+		if err := textStatus(context.Background(), time.Now(), os.Stdout); err != nil {
+			log.Fatalf("failed to get the status: %v", err)
+		}
+		os.Exit(0)
+	}
 
-	// Create done channel for graceful shutdown
-	done := make(chan struct{})
+	// Enable debug logging and the JSON logs
+	if err := setLogFormat(*logFormat, *enableDebug, os.Stderr); err != nil {
+		log.Fatal(err)
+	}
 
-	// Run main loop in goroutine
-	wg := sync.WaitGroup{}
-	go func() {
-		if err := runWithDefaults(&wg, *conf, done); err != nil {
-			log.Fatalf("fatal error in main loop: %v", err)
+	// Simulate the failures to exercise the retries, the queue, and the notifications
+	if *simulateFailures > 0 {
+		if err := radikron.SetFailureRate(*simulateFailures); err != nil {
+			log.Fatalf("simulate-failures: %v", err)
 		}
-	}()
-
-	// Wait for signal
-	<-quit
-	close(done)
+		log.Printf("simulating the failures at the rate %g", *simulateFailures)
+	}
 
-	// Finish downloads in progress
-	log.Println("exit once all the downloads complete")
-	wg.Wait()
-	log.Println("exiting radikron")
+	// Run the command, or the main loop without one
+	if err := newCommands(*conf, os.Stdin, os.Stdout).execute(flag.Args(), os.Stdout); err != nil {
+		if errors.Is(err, errProblems) {
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
 }
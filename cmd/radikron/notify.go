@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/iomz/radikron"
+)
+
+// notificationTitle is the app name shown in the desktop notifications
+const notificationTitle = "radikron"
+
+// windowsToastScript shows a toast with the title and the body in the environment variables
+const windowsToastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode($env:RADIKRON_NOTIFY_TITLE)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode($env:RADIKRON_NOTIFY_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('radikron').Show([Windows.UI.Notifications.ToastNotification]::new($t))`
+
+// desktopNotifier logs the events like the CLI does and also notifies
+// the saved and the given-up programs on the desktop, without the GUI
+type desktopNotifier struct {
+	radikron.EventEmitter
+	send func(title, body string) error
+	// asset has the notification templates, or nil for the default texts
+	asset *radikron.Asset
+	mu    sync.Mutex
+	// progs are the programs being downloaded by the station and the title, for the notification templates
+	progs map[string]*radikron.Prog
+}
+
+// newDesktopNotifier returns a desktopNotifier logging the events by the default EventEmitter,
+// with the notification command of the platform and the notification templates of the asset
+func newDesktopNotifier(asset *radikron.Asset) *desktopNotifier {
+	return &desktopNotifier{EventEmitter: radikron.DefaultEventEmitter(), send: sendDesktopNotification, asset: asset}
+}
+
+// EmitDownloadStarted implements radikron.EventEmitter
+func (n *desktopNotifier) EmitDownloadStarted(prog *radikron.Prog, uri string) {
+	n.EventEmitter.EmitDownloadStarted(prog, uri)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.progs == nil {
+		n.progs = map[string]*radikron.Prog{}
+	}
+	n.progs[prog.StationID+"/"+prog.Title] = prog
+}
+
+// EmitFileSaved implements radikron.EventEmitter
+func (n *desktopNotifier) EmitFileSaved(stationID, title, filePath string) {
+	n.EventEmitter.EmitFileSaved(stationID, title, filePath)
+	data := n.notificationData(stationID, title)
+	data.Path, data.File = filePath, filepath.Base(filePath)
+	if info, err := os.Stat(filePath); err == nil {
+		data.Size = info.Size()
+	}
+	n.notifyTemplate(radikron.NotificationSaved, data, "Saved: "+title, fmt.Sprintf("[%s] %s", stationID, data.File))
+}
+
+// EmitProgramFailed implements radikron.EventEmitter
+func (n *desktopNotifier) EmitProgramFailed(stationID, title, startTime string, attempts int) {
+	n.EventEmitter.EmitProgramFailed(stationID, title, startTime, attempts)
+	data := n.notificationData(stationID, title)
+	data.Ft, data.Attempts = startTime, attempts
+	n.notifyTemplate(radikron.NotificationFailed, data,
+		"Gave up: "+title, fmt.Sprintf("[%s] %s after %d failed attempts", stationID, startTime, attempts))
+}
+
+// notificationData returns the data of the notification templates of the program,
+// with its start, rule, and length if its download started with this notifier
+func (n *desktopNotifier) notificationData(stationID, title string) radikron.NotificationData {
+	data := radikron.NotificationData{StationID: stationID, Title: title}
+	n.mu.Lock()
+	prog := n.progs[stationID+"/"+title]
+	delete(n.progs, stationID+"/"+title)
+	n.mu.Unlock()
+	if prog == nil {
+		return data
+	}
+	data.Ft, data.Rule = prog.Ft, prog.RuleName
+	ft, err := radikron.ParseDatetime(prog.Ft)
+	if err != nil {
+		return data
+	}
+	if to, err := radikron.ParseDatetime(prog.To); err == nil {
+		data.Duration = to.Sub(ft)
+	}
+	return data
+}
+
+// notifyTemplate notifies by the templates of the notification for the desktop, or by the default title and body
+func (n *desktopNotifier) notifyTemplate(notification string, data radikron.NotificationData, title, body string) {
+	n.notify(
+		n.asset.NotificationText(radikron.NotificationBackendDesktop, notification+"-title", data, title),
+		n.asset.NotificationText(radikron.NotificationBackendDesktop, notification+"-body", data, body))
+}
+
+func (n *desktopNotifier) notify(title, body string) {
+	if err := n.send(title, body); err != nil {
+		log.Printf("failed to send the desktop notification: %v", err)
+	}
+}
+
+// sendDesktopNotification shows a notification with libnotify (notify-send) on Linux and BSD,
+// osascript on macOS, or a PowerShell toast on Windows
+func sendDesktopNotification(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			notificationTitle+": "+title, body)
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToastScript)
+		cmd.Env = append(os.Environ(), "RADIKRON_NOTIFY_TITLE="+notificationTitle+": "+title, "RADIKRON_NOTIFY_BODY="+body)
+	default:
+		cmd = exec.Command("notify-send", "--app-name="+notificationTitle, title, body)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w (%s)", cmd.Path, err, out)
+	}
+	return nil
+}
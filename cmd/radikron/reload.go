@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/iomz/radikron/internal/config"
+)
+
+// reloadDebounce coalesces the burst of the events an editor makes saving the config file
+const reloadDebounce = 500 * time.Millisecond
+
+// reloadRequests wakes the main loop to reload the configuration,
+// on a change of the config file or SIGHUP
+var reloadRequests = make(chan struct{}, 1)
+
+// requestReload asks the main loop to reload the configuration, coalescing the pending requests
+func requestReload() {
+	select {
+	case reloadRequests <- struct{}{}:
+	default:
+	}
+}
+
+// fetchRequests wakes the main loop to fetch right away, e.g., by the fetch-now webhook
+var fetchRequests = make(chan struct{}, 1)
+
+// requestFetch asks the main loop to fetch without waiting for the next fetch time,
+// coalescing the pending requests; a request during a fetch starts another one after it
+func requestFetch() {
+	select {
+	case fetchRequests <- struct{}{}:
+	default:
+	}
+}
+
+// configReloadable returns true if the config file loads, so that a broken edit never stops the main loop
+func configReloadable(configFileName string) bool {
+	if _, err := config.LoadConfig(configFileName); err != nil {
+		log.Printf("ignoring the change of %s: %v", configFileName, err)
+		return false
+	}
+	return true
+}
+
+// watchConfig requests a reload when the config file or the files it includes change until done
+func watchConfig(configFileName string, done <-chan struct{}) error {
+	path, err := filepath.Abs(configFileName)
+	if err != nil {
+		return err
+	}
+	paths := []string{path}
+	if files, err := config.ConfigFiles(path); err == nil {
+		paths = files
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// watch the dirs, as many editors replace the file on save
+	for _, p := range paths {
+		if err := watcher.Add(filepath.Dir(p)); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		var debounce *time.Timer
+		for {
+			select {
+			case <-done:
+				if debounce != nil {
+					debounce.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !slices.Contains(paths, filepath.Clean(event.Name)) || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(reloadDebounce, func() {
+					log.Printf("%s changed", configFileName)
+					requestReload()
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("failed to watch %s: %v", configFileName, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// watchReloadSignal requests a reload on SIGHUP until done
+func watchReloadSignal(done <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+				log.Println("received SIGHUP")
+				requestReload()
+			}
+		}
+	}()
+}
+
+// waitDownloads waits for the downloads in flight to complete and returns true,
+// or returns false as soon as a reload is requested with a valid config file;
+// it keeps pinging the systemd watchdog meanwhile, as a live recording holds wg for the whole broadcast
+func waitDownloads(wg *sync.WaitGroup, configFileName string) bool {
+	waited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(waited)
+	}()
+	watchdog, stopWatchdog := watchdogTicks()
+	defer stopWatchdog()
+	for {
+		select {
+		case <-waited:
+			return true
+		case <-watchdog:
+			notifySystemd(sdWatchdog)
+		case <-reloadRequests:
+			if configReloadable(configFileName) {
+				return false
+			}
+		}
+	}
+}
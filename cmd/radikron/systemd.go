@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// the states sent to systemd with sdNotify
+const (
+	sdReady    = "READY=1"
+	sdStopping = "STOPPING=1"
+	sdWatchdog = "WATCHDOG=1"
+)
+
+// sdNotify sends the state to the service manager at NOTIFY_SOCKET, e.g., systemd running radikron as Type=notify;
+// it does nothing without NOTIFY_SOCKET
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// a socket starting with @ is in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// notifySystemd sends the state to systemd, logging the failure
+func notifySystemd(state string) {
+	if err := sdNotify(state); err != nil {
+		log.Printf("failed to notify systemd of %s: %v", state, err)
+	}
+}
+
+// watchdogInterval returns how often to send WATCHDOG=1, half the WatchdogSec systemd passes in WATCHDOG_USEC,
+// or 0 if the watchdog is not enabled for this process
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// watchdogTicks returns the ticks to send WATCHDOG=1 on and the func to stop them;
+// it never ticks without the watchdog
+func watchdogTicks() (<-chan time.Time, func()) {
+	interval := watchdogInterval()
+	if interval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
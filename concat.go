@@ -0,0 +1,175 @@
+package radikron
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// concatedFileName is the file in the aac dir the segments are concatenated into
+const concatedFileName = "concated.aac"
+
+// id3v2HeaderSize is the size of the ID3v2 header (and footer)
+const id3v2HeaderSize = 10
+
+// segmentAppender appends the downloaded segments to the concatenated file in the chunklist order
+// as soon as they are available, removing each segment after it is appended.
+// This keeps the disk usage at about the size of the program and avoids a long concat at the end.
+type segmentAppender struct {
+	mu         sync.Mutex
+	aacDir     string
+	chunklist  []string
+	file       *os.File
+	size       int64           // bytes appended to file
+	next       int             // index of the next segment to append
+	downloaded map[string]bool // segment file names, used if manifest is nil
+	manifest   *segmentManifest
+}
+
+// newSegmentAppender opens the concatenated file in aacDir.
+// If manifest is not nil, it resumes from the appended segments recorded in the manifest,
+// and appends the segments downloaded but not yet appended before a restart.
+func newSegmentAppender(aacDir string, chunklist []string, manifest *segmentManifest) (*segmentAppender, error) {
+	a := &segmentAppender{
+		aacDir:     aacDir,
+		chunklist:  chunklist,
+		downloaded: map[string]bool{},
+		manifest:   manifest,
+	}
+	if manifest != nil {
+		a.next, a.size = manifest.appendedState()
+	}
+
+	f, err := os.OpenFile(a.path(), os.O_RDWR|os.O_CREATE, OutputFilePermissions)
+	if err != nil {
+		return nil, err
+	}
+	// drop a segment partially appended before a restart
+	if err := f.Truncate(a.size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(a.size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	a.file = f
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// path returns the path to the concatenated file
+func (a *segmentAppender) path() string {
+	return filepath.Join(a.aacDir, concatedFileName)
+}
+
+// isCompleted returns true if the segment has been downloaded
+func (a *segmentAppender) isCompleted(link string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.hasSegment(link)
+}
+
+// hasSegment returns true if the segment has been downloaded; the caller must hold a.mu
+func (a *segmentAppender) hasSegment(link string) bool {
+	if a.manifest != nil {
+		return a.manifest.isCompleted(link)
+	}
+	return a.downloaded[segmentFileName(link)]
+}
+
+// add records the segment as downloaded and appends the segments ready in order
+func (a *segmentAppender) add(link string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.manifest != nil {
+		if err := a.manifest.markCompleted(link); err != nil {
+			return err
+		}
+	} else {
+		a.downloaded[segmentFileName(link)] = true
+	}
+	return a.flush()
+}
+
+// flush appends the downloaded segments following the last appended one; the caller must hold a.mu
+func (a *segmentAppender) flush() error {
+	for a.next < len(a.chunklist) {
+		link := a.chunklist[a.next]
+		if !a.hasSegment(link) {
+			return nil
+		}
+
+		segment := filepath.Join(a.aacDir, segmentFileName(link))
+		n, err := appendSegment(a.file, segment)
+		if err != nil {
+			return fmt.Errorf("failed to append %s: %w", segmentFileName(link), err)
+		}
+		a.size += n
+		a.next++
+
+		// record the progress before removing the segment, so that a restart never loses it
+		if a.manifest != nil {
+			if err := a.manifest.markAppended(a.next, a.size); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(segment); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// close closes the concatenated file and returns its path,
+// or an error if any segment has not been appended
+func (a *segmentAppender) close() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.file.Close(); err != nil {
+		return "", err
+	}
+	if a.next < len(a.chunklist) {
+		return "", fmt.Errorf("lack of aac files: %d/%d segments appended", a.next, len(a.chunklist))
+	}
+	return a.path(), nil
+}
+
+// appendSegment appends the audio in the segment file to dst and returns the appended bytes
+func appendSegment(dst io.Writer, segment string) (int64, error) {
+	data, err := os.ReadFile(segment)
+	if err != nil {
+		return 0, err
+	}
+	n, err := dst.Write(stripID3v2(data))
+	return int64(n), err
+}
+
+// stripID3v2 returns the ADTS stream without the leading ID3v2 tags,
+// which radiko puts in every segment for the timestamp
+func stripID3v2(data []byte) []byte {
+	for len(data) >= id3v2HeaderSize && string(data[:3]) == "ID3" {
+		// the tag size is a 28-bit synchsafe integer excluding the header (and footer)
+		size := int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f)
+		total := id3v2HeaderSize + size
+		if data[5]&0x10 != 0 {
+			total += id3v2HeaderSize // footer
+		}
+		if total > len(data) {
+			break
+		}
+		data = data[total:]
+	}
+	return data
+}
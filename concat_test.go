@@ -0,0 +1,212 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// id3v2Tag returns an ID3v2 tag with the payload like the one radiko puts in the segments
+func id3v2Tag(payload string) []byte {
+	size := len(payload)
+	header := []byte{'I', 'D', '3', 4, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, payload...)
+}
+
+func TestStripID3v2(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"no tag", []byte("adts"), []byte("adts")},
+		{"one tag", append(id3v2Tag("PRIV timestamp"), "adts"...), []byte("adts")},
+		{"two tags", append(append(id3v2Tag("a"), id3v2Tag("b")...), "adts"...), []byte("adts")},
+		{"truncated tag", id3v2Tag("PRIV")[:12], id3v2Tag("PRIV")[:12]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripID3v2(tt.data); !bytes.Equal(got, tt.want) {
+				t.Errorf("stripID3v2() => %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentAppender(t *testing.T) {
+	aacDir := t.TempDir()
+	chunklist := []string{"http://example.com/1.aac", "http://example.com/2.aac", "http://example.com/3.aac"}
+	m, err := loadSegmentManifest(aacDir, "test-id", chunklist)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+	a, err := newSegmentAppender(aacDir, chunklist, m)
+	if err != nil {
+		t.Fatalf("newSegmentAppender failed: %v", err)
+	}
+
+	// the segments are downloaded out of order
+	for _, i := range []int{2, 3, 1} {
+		name := filepath.Join(aacDir, segmentFileName(chunklist[i-1]))
+		data := append(id3v2Tag("PRIV"), strings.Repeat(string(rune('0'+i)), 3)...)
+		if err := os.WriteFile(name, data, FilePermissions); err != nil {
+			t.Fatalf("Failed to write the segment: %v", err)
+		}
+		if err := a.add(chunklist[i-1]); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+		if i == 2 {
+			if appended, _ := m.appendedState(); appended != 0 {
+				t.Errorf("appended => %d, want 0 before the first segment", appended)
+			}
+		}
+	}
+
+	concatedFile, err := a.close()
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	got, err := os.ReadFile(concatedFile)
+	if err != nil {
+		t.Fatalf("Failed to read the concatenated file: %v", err)
+	}
+	if string(got) != "111222333" {
+		t.Errorf("concatenated => %q, want 111222333", got)
+	}
+	// the concatenated file becomes the aac output, readable by the players
+	info, err := os.Stat(concatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0044 == 0 {
+		t.Errorf("expected the concatenated file readable by the others, got %v", info.Mode().Perm())
+	}
+	for _, link := range chunklist {
+		if _, err := os.Stat(filepath.Join(aacDir, segmentFileName(link))); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed once appended", segmentFileName(link))
+		}
+	}
+}
+
+func TestSegmentAppender_Resume(t *testing.T) {
+	aacDir := t.TempDir()
+	chunklist := []string{"http://example.com/1.aac", "http://example.com/2.aac"}
+	m, err := loadSegmentManifest(aacDir, "test-id", chunklist)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+	a, err := newSegmentAppender(aacDir, chunklist, m)
+	if err != nil {
+		t.Fatalf("newSegmentAppender failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(aacDir, "1.aac"), []byte("111"), FilePermissions); err != nil {
+		t.Fatalf("Failed to write the segment: %v", err)
+	}
+	if err := a.add(chunklist[0]); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	// simulate a crash while appending the second segment
+	if _, err := a.file.WriteString("2"); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	a.file.Close()
+
+	resumed, err := loadSegmentManifest(aacDir, "test-id", chunklist)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+	if !resumed.isCompleted(chunklist[0]) || resumed.isCompleted(chunklist[1]) {
+		t.Fatal("only the first segment should be completed after resume")
+	}
+	a, err = newSegmentAppender(aacDir, chunklist, resumed)
+	if err != nil {
+		t.Fatalf("newSegmentAppender failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(aacDir, "2.aac"), []byte("222"), FilePermissions); err != nil {
+		t.Fatalf("Failed to write the segment: %v", err)
+	}
+	if err := a.add(chunklist[1]); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	concatedFile, err := a.close()
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if got, _ := os.ReadFile(concatedFile); string(got) != "111222" {
+		t.Errorf("concatenated => %q, want 111222", got)
+	}
+}
+
+func TestSegmentAppender_Missing(t *testing.T) {
+	aacDir := t.TempDir()
+	a, err := newSegmentAppender(aacDir, []string{"http://example.com/1.aac"}, nil)
+	if err != nil {
+		t.Fatalf("newSegmentAppender failed: %v", err)
+	}
+	if _, err := a.close(); err == nil {
+		t.Error("close should fail with the missing segment")
+	}
+}
+
+func TestDownloadProgram_AAC(t *testing.T) {
+	testDir := t.TempDir()
+	t.Setenv(EnvRadicronHome, testDir)
+	InitSemaphores(&Asset{})
+
+	segment := append(id3v2Tag("PRIV timestamp"), bytes.Repeat([]byte{0xff}, 1024)...)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".m3u8") {
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXTINF:5.0,\n" + server.URL +
+				"/1.aac\n#EXTINF:5.0,\n" + server.URL + "/2.aac\n#EXT-X-ENDLIST\n"))
+			return
+		}
+		_, _ = w.Write(segment)
+	}))
+	defer server.Close()
+
+	downloadsDir := filepath.Join(testDir, "downloads")
+	if err := os.MkdirAll(downloadsDir, DirPermissions); err != nil {
+		t.Fatalf("Failed to create the downloads dir: %v", err)
+	}
+	output := newOutputConfigFromPath(downloadsDir, "test-output", radigo.AudioFormatAAC)
+	prog := &Prog{
+		ID:        "test-aac",
+		StationID: "FMT",
+		Title:     "Test Program",
+		Ft:        "20230605130000",
+		M3U8:      server.URL + "/playlist.m3u8",
+	}
+	asset := &Asset{MinimumOutputSize: 1}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	downloadProgram(ctx, wg, prog, output, nil)
+	wg.Wait()
+
+	info, err := os.Stat(output.AbsPath())
+	if err != nil {
+		t.Fatalf("the output file should be saved: %v", err)
+	}
+	// the ID3v2 tag written by writeID3Tag comes first, followed by the segments without their tags
+	if info.Size() < 2*1024 {
+		t.Errorf("output size => %d, want at least %d", info.Size(), 2*1024)
+	}
+	aacDir, err := programAACDir(prog.ID)
+	if err != nil {
+		t.Fatalf("programAACDir failed: %v", err)
+	}
+	if _, err := os.Stat(aacDir + SegmentManifestExt); !os.IsNotExist(err) {
+		t.Error("the segment manifest should be removed after the download")
+	}
+}
@@ -1,5 +1,7 @@
 package radikron
 
+import "time"
+
 const (
 	// BufferMinutes for fetching the playlist.m3u8 chunks
 	BufferMinutes = 5
@@ -11,12 +13,18 @@ const (
 	DefaultInitialDelaySeconds = 60
 	// DefaultInterval to fetch the programs
 	DefaultInterval = "168h"
+	// DefaultMinimumFreeSpace in MB to keep free on the filesystems after a download
+	DefaultMinimumFreeSpace = 512
 	// DefaultMinimumOutputSize
 	DefaultMinimumOutputSize = 1
 	// Environment Variable for RADICRON_HOME
 	EnvRadicronHome = "RADICRON_HOME"
+	// Environment Variable for the master key of the encrypted secrets file
+	EnvMasterKey = "RADIKRON_MASTER_KEY"
 	// Language for ID3v2 tags
 	ID3v2LangJPN = "jpn"
+	// ID3v2ProgramID is the description of the user defined text frame (TXXX) for the program ID
+	ID3v2ProgramID = "RADIKO_PROGRAM_ID"
 	// LatLng for Japan
 	JapanLatLng = 40.0
 	// Kilobytes for the metric bytes
@@ -28,10 +36,40 @@ const (
 	MaxEncodingConcurrency = 2
 	// MaxRetryAttempts for BackOffDelay
 	MaxRetryAttempts = 8
+	// DefaultRetryInitialDelay before the first retry
+	DefaultRetryInitialDelay = 1 * time.Second
+	// DefaultRetryMaxDelay caps the delay between retries
+	DefaultRetryMaxDelay = 30 * time.Second
+	// DefaultRetryMultiplier for the exponential backoff
+	DefaultRetryMultiplier = 2.0
+	// DefaultRetryJitter randomizes the retry delay by up to 20%
+	DefaultRetryJitter = 0.2
+	// DefaultDurationTolerance is how much shorter than the program the output can be
+	DefaultDurationTolerance = time.Minute
+	// DefaultCoordinationLease is the time before a program lock of a stalled instance is taken over
+	DefaultCoordinationLease = 5 * time.Minute
+	// DefaultDownloadStallTimeout is the time without a segment downloaded before canceling the download
+	DefaultDownloadStallTimeout = 5 * time.Minute
+	// DefaultDownloadDir is the folder in RADICRON_HOME to save the programs to
+	DefaultDownloadDir = "downloads"
+	// DefaultFileServerUser is the user of the basic auth of the file server
+	DefaultFileServerUser = "radikron"
+	// DefaultPremiumMaxStreams is the simultaneous-stream limit of a radiko premium account
+	DefaultPremiumMaxStreams = 1
+	// DefaultMaxProgramAttempts is the failed attempts before giving up a program
+	DefaultMaxProgramAttempts = 5
+	// DefaultRequestsPerSecond limits the requests to radiko's CDN
+	DefaultRequestsPerSecond = 10.0
 	// OneDay is 24 hours
 	OneDay = 24
 	// OutputDatetimeLayout for downloaded files
 	OutputDatetimeLayout = "2006-01-02-1504"
+	// LiveLeadTime is how long before the start a live recording joins the stream, not to miss the beginning
+	LiveLeadTime = 2 * time.Minute
+	// LivePollInterval is how often a live recording fetches the playlist for the new segments
+	LivePollInterval = 5 * time.Second
+	// TimefreeWindow is how long a program stays available on radiko timefree after it starts
+	TimefreeWindow = 7 * OneDay * time.Hour
 	// TZTokyo for time location
 	TZTokyo = "Asia/Tokyo"
 	// UserIDLength for user-id
@@ -40,12 +78,87 @@ const (
 	PlaylistM3U8Length = "15"
 	// DirPermissions for directory creation (0755 = rwxr-xr-x)
 	DirPermissions = 0755
+	// FilePermissions for state file creation (0600 = rw-------)
+	FilePermissions = 0600
+	// OutputFilePermissions for the outputs and the files saved beside them for the players (0644 = rw-r--r--)
+	OutputFilePermissions = 0644
+	// AudioFormatM4A is the output format remuxing the AAC stream into an MP4 container
+	AudioFormatM4A = "m4a"
+	// PartFileExt is appended to the output file name until the file is complete
+	PartFileExt = ".part"
+	// SegmentManifestExt for the segment manifest beside the aac dir
+	SegmentManifestExt = ".json"
+	// SegmentManifestVersion is the format version of the segment manifest
+	SegmentManifestVersion = 2
+	// QueueFileName persists the queued programs in RADICRON_HOME across restarts
+	QueueFileName = "queue.json"
+	// QueueVersion is the format version of the queue file
+	QueueVersion = 1
+	// EncodeQueueDirName keeps the downloaded programs waiting for the deferred encoding in RADICRON_HOME
+	EncodeQueueDirName = "encode-queue"
+	// GuideCacheDirName keeps the fetched weekly guides compressed in RADICRON_HOME
+	GuideCacheDirName = "guide-cache"
+	// GuideCacheExt for the gzip-compressed weekly guide of a station
+	GuideCacheExt = ".xml.gz"
+	// GuideCacheIndexFileName lists the cached weekly guides in GuideCacheDirName
+	GuideCacheIndexFileName = "index.json"
+	// GuideCacheVersion is the format version of the guide cache index
+	GuideCacheVersion = 1
+	// LogoCacheDirName keeps the station logos converted to JPEG in RADICRON_HOME
+	LogoCacheDirName = "cache/logos"
+	// FeedTokensFileName keeps the private tokens of the podcast feeds in RADICRON_HOME
+	FeedTokensFileName = "feed-tokens.json"
+	// FeedTokensVersion is the format version of the feed tokens file
+	FeedTokensVersion = 1
+	// HistoryFileName records the recent program downloads in RADICRON_HOME
+	HistoryFileName = "history.json"
+	// HistoryVersion is the format version of the history file
+	HistoryVersion = 1
+	// QuarantineDirName keeps the broken outputs found by the recovery scan in RADICRON_HOME
+	QuarantineDirName = "quarantine"
+	// TrashDirName keeps the recordings moved out by the prune command in RADICRON_HOME
+	TrashDirName = "trash"
+	// RecoveryScanQuarantine moves the broken outputs found at startup to QuarantineDirName
+	RecoveryScanQuarantine = "quarantine"
+	// RecoveryScanRemove removes the broken outputs found at startup
+	RecoveryScanRemove = "remove"
+	// RecoveryScanOff disables the recovery scan at startup
+	RecoveryScanOff = "off"
+	// SidecarJSON writes the program metadata to "<name>.json" next to the output
+	SidecarJSON = "json"
+	// SidecarNFO writes the program metadata to the Kodi-style "<name>.nfo" next to the output
+	SidecarNFO = "nfo"
+	// DuplicateScanAll checks the folders of all the rules for an existing output before a download
+	DuplicateScanAll = "all"
+	// DuplicateScanRule checks only the folder of the matched rule and the downloads dir for an existing output
+	DuplicateScanRule = "rule"
+	// ControlSocketFileName is the Unix socket of the control API of the running radikron in RADICRON_HOME
+	ControlSocketFileName = "radikron.sock"
+	// InstanceLockFileName is locked by the running radikron in RADICRON_HOME, so that only one runs against it
+	InstanceLockFileName = "radikron.lock"
+	// SecretsFileName is the encrypted secrets file in RADICRON_HOME
+	SecretsFileName = "secrets.enc"
+	// SlotsFileName records the length of the recent airings of the matched programs in RADICRON_HOME
+	SlotsFileName = "slots.json"
+	// SlotsVersion is the format version of the slots file
+	SlotsVersion = 1
+	// StateFileName records the schema version of the persistent state in RADICRON_HOME
+	StateFileName = "state.json"
+	// StateSchemaVersion is the schema version of the persistent state in RADICRON_HOME
+	StateSchemaVersion = 2
 
 	// API endpoints
 	// region full
 	APIRegionFull    = "https://radiko.jp/v3/station/region/full.xml"
 	APIPlaylistM3U8  = "https://radiko.jp/v2/api/ts/playlist.m3u8"
+	APIAuth2         = "https://radiko.jp/v2/api/auth2"
+	APIMemberLogin   = "https://radiko.jp/v4/api/member/login"
+	APIMemberLogout  = "https://radiko.jp/v4/api/member/logout"
 	APIWeeklyProgram = "https://radiko.jp/v3/program/station/weekly/%s.xml"
+	// live stream of a station
+	APILiveStreamM3U8 = "https://f-radiko.smartstream.ne.jp/%s/_definst_/simul-stream.stream/playlist.m3u8"
+	// share link to a program with the station ID and the start time
+	RadikoShareURL = "https://radiko.jp/share/?sid=%s&t=%s"
 
 	// HTTP Headers
 	// auth1 req
@@ -0,0 +1,155 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// controlShutdownTimeout is how long the control server waits for the requests in flight on shutdown
+	controlShutdownTimeout = 5 * time.Second
+	// controlRequestTimeout is how long a command waits for the running radikron to answer
+	controlRequestTimeout = 10 * time.Second
+)
+
+// ErrNotRunning is returned by the control API client if no radikron listens on the control socket
+var ErrNotRunning = errors.New("radikron is not running")
+
+// controlSocketPath returns the path to the control socket
+func controlSocketPath() (string, error) {
+	home, err := getRadicronPath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ControlSocketFileName), nil
+}
+
+// NewControlHandler returns the handler of the control API, serving the schedules at "/schedules"
+// and the status at "/status"
+func NewControlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /schedules", serveSchedules)
+	mux.HandleFunc("GET /status", serveStatus)
+	return mux
+}
+
+func serveStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(CurrentStatus())
+}
+
+func serveSchedules(w http.ResponseWriter, _ *http.Request) {
+	entries, err := ScheduledPrograms()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// ServeControl serves the control API on ControlSocketFileName until ctx is done,
+// replacing the socket left by a radikron which did not exit cleanly
+func ServeControl(ctx context.Context) error {
+	path, err := controlSocketPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another radikron is serving %s", path)
+	}
+	_ = os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	statusMu.Lock()
+	statusStarted = time.Now()
+	statusMu.Unlock()
+	// only the user running radikron may control it
+	if err := os.Chmod(path, FilePermissions); err != nil {
+		ln.Close()
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:           NewControlHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), controlShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	// the listener removes the socket when closed
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// controlGet decodes the JSON answer of the running radikron at the path of the control API into v
+func controlGet(ctx context.Context, path string, v any) error {
+	socket, err := controlSocketPath()
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: controlRequestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	// the host is ignored by the dialer
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://radikron"+path, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return fmt.Errorf("%w: no control socket at %s", ErrNotRunning, socket)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// FetchStatus returns the status of the running radikron over the control socket
+func FetchStatus(ctx context.Context) (*Status, error) {
+	var s Status
+	if err := controlGet(ctx, "/status", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FetchSchedules returns the schedules of the running radikron over the control socket
+func FetchSchedules(ctx context.Context) ([]ScheduleEntry, error) {
+	var entries []ScheduleEntry
+	if err := controlGet(ctx, "/schedules", &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
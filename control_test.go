@@ -0,0 +1,66 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeControl(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	socket := filepath.Join(home, ControlSocketFileName)
+
+	if _, err := FetchSchedules(context.Background()); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning without the server, got %v", err)
+	}
+
+	// the socket left by a crash is replaced
+	if err := os.WriteFile(socket, nil, FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	scheduleProgram(&Asset{}, &Prog{ID: "1", StationID: "FMT", Ft: "20230605130000", To: "20230605140000", Title: "Test"})
+	defer unscheduleProgram(&Prog{ID: "1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- ServeControl(ctx) }()
+
+	var entries []ScheduleEntry
+	var err error
+	for i := 0; i < 50; i++ {
+		if entries, err = FetchSchedules(context.Background()); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("FetchSchedules failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Title != "Test" || entries[0].State != ScheduleWaiting {
+		t.Errorf("unexpected schedules %+v", entries)
+	}
+
+	status, err := FetchStatus(context.Background())
+	if err != nil {
+		t.Fatalf("FetchStatus failed: %v", err)
+	}
+	if status.Started.IsZero() {
+		t.Error("expected the start time in the status")
+	}
+
+	if err := ServeControl(context.Background()); err == nil {
+		t.Error("expected an error serving the socket of another radikron")
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("ServeControl failed: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed, got %v", err)
+	}
+}
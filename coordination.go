@@ -0,0 +1,214 @@
+package radikron
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// doneMarkerRetention is how long the done markers are kept in the coordination dir;
+// a program is never downloaded again after the timefree window
+const doneMarkerRetention = 2 * TimefreeWindow
+
+var (
+	// errProgramLocked is returned when another instance is downloading the program
+	errProgramLocked = errors.New("program is being downloaded by another instance")
+	// errProgramDone is returned when another instance has already downloaded the program
+	errProgramDone = errors.New("program has been downloaded by another instance")
+)
+
+// programLock is a lease on a program in the coordination dir shared by multiple instances.
+// The holder refreshes the lease while downloading, and a lease not refreshed within
+// CoordinationLease is considered abandoned so that another instance can take over.
+type programLock struct {
+	InstanceID string    `json:"instance-id"`
+	Acquired   time.Time `json:"acquired"`
+
+	path     string
+	donePath string
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// programLockKey returns the file name stem for the program in the coordination dir
+func programLockKey(prog *Prog) string {
+	key := prog.ID
+	if key == "" {
+		key = prog.StationID + "_" + prog.Ft
+	}
+	return unsafeDirNameChars.ReplaceAllString(key, "_")
+}
+
+// acquireProgramLock tries to take the lease on the program in dir.
+// It returns errProgramDone if the program has been downloaded by any instance,
+// or errProgramLocked if another instance holds a live lease on it.
+func acquireProgramLock(dir, instanceID string, lease time.Duration, prog *Prog) (*programLock, error) {
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create the coordination dir: %w", err)
+	}
+	if lease <= 0 {
+		lease = DefaultCoordinationLease
+	}
+
+	key := programLockKey(prog)
+	l := &programLock{
+		InstanceID: instanceID,
+		Acquired:   time.Now(),
+		path:       filepath.Join(dir, key+".lock"),
+		donePath:   filepath.Join(dir, key+".done"),
+		stop:       make(chan struct{}),
+	}
+	if _, err := os.Stat(l.donePath); err == nil {
+		return nil, errProgramDone
+	}
+
+	blob, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	// release the takeover marker only after the lock is created in its place
+	endTakeover := func() {}
+	defer func() { endTakeover() }()
+
+	// try twice: the second attempt after clearing an abandoned lease
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FilePermissions)
+		if err == nil {
+			_, err = f.Write(blob)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(l.path)
+				return nil, fmt.Errorf("failed to write the program lock: %w", err)
+			}
+			l.heartbeat(lease / 3)
+			return l, nil
+		}
+		if !errors.Is(err, os.ErrExist) || i > 0 {
+			return nil, fmt.Errorf("failed to create the program lock: %w", err)
+		}
+		if !l.isAbandoned(lease) {
+			return nil, errProgramLocked
+		}
+		if endTakeover, err = l.takeOver(lease); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errProgramLocked
+}
+
+// takeOver removes the abandoned lock holding the takeover marker of the program, returning the func to release it.
+// Two instances may see the same abandoned lock, and without the marker, the second would remove the lock
+// the first has just created in its place, so that both download the program
+func (l *programLock) takeOver(lease time.Duration) (func(), error) {
+	marker := l.path + ".takeover"
+	f, err := os.OpenFile(marker, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FilePermissions)
+	if err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			return func() {}, fmt.Errorf("failed to take over the program lock: %w", err)
+		}
+		// clear the marker of an instance which stopped taking over, for the next attempt
+		if info, err := os.Stat(marker); err == nil && time.Since(info.ModTime()) > lease {
+			_ = os.Remove(marker)
+		}
+		return func() {}, errProgramLocked
+	}
+	f.Close()
+	end := func() { _ = os.Remove(marker) }
+
+	// another instance may have taken it over since inspected
+	if !l.isAbandoned(lease) {
+		end()
+		return func() {}, errProgramLocked
+	}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		end()
+		return func() {}, fmt.Errorf("failed to take over the program lock: %w", err)
+	}
+	return end, nil
+}
+
+// isAbandoned returns true if the existing lock was left by this instance
+// (e.g., before a restart) or has not been refreshed within the lease
+func (l *programLock) isAbandoned(lease time.Duration) bool {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist)
+	}
+	if time.Since(info.ModTime()) > lease {
+		return true
+	}
+	blob, err := os.ReadFile(l.path)
+	if err != nil {
+		return false
+	}
+	var holder programLock
+	if err := json.Unmarshal(blob, &holder); err != nil {
+		return false
+	}
+	return holder.InstanceID == l.InstanceID
+}
+
+// heartbeat refreshes the lease on every interval until released
+func (l *programLock) heartbeat(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				_ = os.Chtimes(l.path, now, now)
+			}
+		}
+	}()
+}
+
+// release gives up the lease; if downloaded, it leaves a done marker
+// so that the other instances skip the program
+func (l *programLock) release(downloaded bool) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	l.wg.Wait()
+
+	if downloaded {
+		if err := os.WriteFile(l.donePath, []byte(l.InstanceID), FilePermissions); err != nil {
+			return fmt.Errorf("failed to write the done marker: %w", err)
+		}
+		pruneDoneMarkers(filepath.Dir(l.donePath), time.Now())
+	}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// pruneDoneMarkers removes the done markers in the coordination dir older than doneMarkerRetention
+func pruneDoneMarkers(dir string, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".done") {
+			continue
+		}
+		if info, err := e.Info(); err == nil && now.Sub(info.ModTime()) > doneMarkerRetention {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package radikron
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireProgramLock(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "coordination")
+	prog := &Prog{ID: "12345", StationID: "FMT", Ft: "20230605130000"}
+
+	lock, err := acquireProgramLock(dir, "leader", time.Minute, prog)
+	if err != nil {
+		t.Fatalf("acquireProgramLock failed: %v", err)
+	}
+
+	// another instance should not take a live lock
+	if _, err := acquireProgramLock(dir, "follower", time.Minute, prog); !errors.Is(err, errProgramLocked) {
+		t.Errorf("acquireProgramLock => %v, want %v", err, errProgramLocked)
+	}
+
+	// a failed download lets another instance take over
+	if err := lock.release(false); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	lock, err = acquireProgramLock(dir, "follower", time.Minute, prog)
+	if err != nil {
+		t.Fatalf("acquireProgramLock after release failed: %v", err)
+	}
+
+	// a completed download is skipped by every instance
+	if err := lock.release(true); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	for _, id := range []string{"leader", "follower"} {
+		if _, err := acquireProgramLock(dir, id, time.Minute, prog); !errors.Is(err, errProgramDone) {
+			t.Errorf("acquireProgramLock(%s) => %v, want %v", id, err, errProgramDone)
+		}
+	}
+}
+
+func TestAcquireProgramLock_TakeOver(t *testing.T) {
+	dir := t.TempDir()
+	prog := &Prog{StationID: "FMT", Ft: "20230605130000"}
+
+	lock, err := acquireProgramLock(dir, "leader", time.Minute, prog)
+	if err != nil {
+		t.Fatalf("acquireProgramLock failed: %v", err)
+	}
+	// simulate a crash: stop the heartbeat without releasing the lock
+	lock.stopOnce.Do(func() { close(lock.stop) })
+	lock.wg.Wait()
+
+	// the same instance takes over its own lock after a restart
+	lock, err = acquireProgramLock(dir, "leader", time.Minute, prog)
+	if err != nil {
+		t.Fatalf("acquireProgramLock by the same instance failed: %v", err)
+	}
+	lock.stopOnce.Do(func() { close(lock.stop) })
+	lock.wg.Wait()
+
+	// another instance takes over once the lease expires
+	expired := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(lock.path, expired, expired); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	// another instance taking it over first holds the takeover marker
+	marker := lock.path + ".takeover"
+	if err := os.WriteFile(marker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireProgramLock(dir, "follower", time.Minute, prog); !errors.Is(err, errProgramLocked) {
+		t.Errorf("acquireProgramLock during a takeover => %v, want %v", err, errProgramLocked)
+	}
+	// the marker of an instance which stopped taking over is cleared for the next attempt
+	if err := os.Chtimes(marker, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireProgramLock(dir, "follower", time.Minute, prog); !errors.Is(err, errProgramLocked) {
+		t.Errorf("acquireProgramLock with a stale takeover => %v, want %v", err, errProgramLocked)
+	}
+
+	lock, err = acquireProgramLock(dir, "follower", time.Minute, prog)
+	if err != nil {
+		t.Fatalf("acquireProgramLock after the lease expired failed: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("the takeover marker should be removed once taken over")
+	}
+	// the lock taken over is live for the other instances seeing the same abandoned lock
+	if _, err := acquireProgramLock(dir, "observer", time.Minute, prog); !errors.Is(err, errProgramLocked) {
+		t.Errorf("acquireProgramLock after the takeover => %v, want %v", err, errProgramLocked)
+	}
+	if err := lock.release(false); err != nil {
+		t.Errorf("release failed: %v", err)
+	}
+	if _, err := os.Stat(lock.path); !os.IsNotExist(err) {
+		t.Error("the lock should be removed on release")
+	}
+}
+
+func TestProgramLockKey(t *testing.T) {
+	if got := programLockKey(&Prog{ID: "a/b"}); got != "a_b" {
+		t.Errorf("programLockKey => %v, want a_b", got)
+	}
+	if got := programLockKey(&Prog{StationID: "FMT", Ft: "20230605130000"}); got != "FMT_20230605130000" {
+		t.Errorf("programLockKey => %v, want FMT_20230605130000", got)
+	}
+}
+
+func TestPruneDoneMarkers(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"old.done": doneMarkerRetention + time.Hour,
+		"new.done": time.Hour,
+		"old.lock": doneMarkerRetention + time.Hour,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pruneDoneMarkers(dir, now)
+	for name, want := range map[string]bool{"old.done": false, "new.done": true, "old.lock": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("expected %s kept %v, got %v", name, want, err)
+		}
+	}
+}
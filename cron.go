@@ -0,0 +1,148 @@
+package radikron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit is how far CronSchedule.Next looks for a matching time, e.g., for "0 0 30 2 *" never matching
+const cronSearchLimit = 5 * 366 * OneDay * time.Hour
+
+// cronDescriptors are the shorthands of the cron expressions
+var cronDescriptors = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// cronField is the range of a field of the cron expressions
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 7 is also Sunday
+	{"day of week", 0, 7},
+}
+
+// CronSchedule is a cron expression of five fields (minute, hour, day of month, month, and day of week) in JST
+type CronSchedule struct {
+	expr   string
+	fields [5]uint64
+	// a day matches either the day of month or the day of week if both are restricted, as in cron
+	domAny, dowAny bool
+}
+
+// ParseCronSchedule parses the cron expression, e.g., "0 5 * * *" for 05:00 every day,
+// with the lists ("1,15"), the ranges ("1-5"), the steps ("*/6"), and the descriptors, e.g., "@daily"
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[spec]; ok {
+		spec = d
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(parts))
+	}
+	s := &CronSchedule{expr: expr, domAny: parts[2] == "*", dowAny: parts[4] == "*"}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		s.fields[i] = bits
+	}
+	// Sunday as 7
+	if s.fields[4]&(1<<7) != 0 {
+		s.fields[4] |= 1
+	}
+	return s, nil
+}
+
+// parseCronField returns the bits of the values of the comma-separated field
+func parseCronField(part string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(part, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step of the %s: %q", f.name, item)
+			}
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid %s: %q", f.name, item)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid %s: %q", f.name, item)
+				}
+			} else if hasStep {
+				// "5/15" is from 5 to the end
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("the %s must be in %d-%d: %q", f.name, f.min, f.max, item)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// String returns the cron expression
+func (s *CronSchedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time matching the schedule after t, in JST; the zero time if none does in five years
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	next := t.In(Location).Truncate(time.Minute).Add(time.Minute)
+	limit := next.Add(cronSearchLimit)
+	for next.Before(limit) {
+		switch {
+		case !s.has(3, int(next.Month())):
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, Location)
+		case !s.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, Location)
+		case !s.has(1, next.Hour()):
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, Location)
+		case !s.has(0, next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) has(field, v int) bool {
+	return s.fields[field]&(1<<uint(v)) != 0
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dom, dow := s.has(2, t.Day()), s.has(4, int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
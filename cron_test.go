@@ -0,0 +1,57 @@
+package radikron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	for _, expr := range []string{"0 5 * * *", "*/15 * * * *", "0 5,17 1-15 * 1-5", "30 4 * * 7", "@daily", "5/20 * * * *"} {
+		if _, err := ParseCronSchedule(expr); err != nil {
+			t.Errorf("ParseCronSchedule(%q) failed: %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "0 5 * *", "60 5 * * *", "0 24 * * *", "0 5 0 * *", "0 5 * 13 *", "0 5 * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"} {
+		if _, err := ParseCronSchedule(expr); err == nil {
+			t.Errorf("expected an error for %q", expr)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2023, month, day, hour, minute, 0, 0, Location)
+	}
+	// Monday
+	now := at(6, 5, 13, 7)
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"0 5 * * *", now, at(6, 6, 5, 0)},
+		{"0 5 * * *", at(6, 5, 4, 59), at(6, 5, 5, 0)},
+		// never the time itself
+		{"0 5 * * *", at(6, 5, 5, 0), at(6, 6, 5, 0)},
+		{"*/15 * * * *", now, at(6, 5, 13, 15)},
+		{"0 5 * * 0", now, at(6, 11, 5, 0)},
+		{"0 5 * * 7", now, at(6, 11, 5, 0)},
+		{"0 5 1 * *", now, at(7, 1, 5, 0)},
+		// either the day of month or the day of week if both are restricted
+		{"0 5 10 * 3", now, at(6, 7, 5, 0)},
+		{"@hourly", now, at(6, 5, 14, 0)},
+		{"0 0 1 1 *", now, time.Date(2024, 1, 1, 0, 0, 0, 0, Location)},
+		// in JST whatever the location of the time
+		{"0 5 * * *", time.Date(2023, 6, 5, 21, 0, 0, 0, time.UTC), at(6, 7, 5, 0)},
+		{"0 0 30 2 *", now, time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := ParseCronSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCronSchedule(%q) failed: %v", tt.expr, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
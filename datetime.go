@@ -0,0 +1,70 @@
+package radikron
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// hoursPerDay is the hour the 24:00-style notation of the programs after midnight starts at
+const hoursPerDay = 24
+
+// ParseDatetime parses the Ft or To of a program in JST (DatetimeLayout), also in the 24:00-style notation
+// some guides use for the programs after midnight, e.g., "20231231250000" for 01:00 on January 1, 2024
+func ParseDatetime(s string) (time.Time, error) {
+	if len(s) != len(DatetimeLayout) {
+		return time.ParseInLocation(DatetimeLayout, s, Location)
+	}
+	hour, err := strconv.Atoi(s[8:10])
+	if err != nil || hour < hoursPerDay || hour >= 2*hoursPerDay {
+		return time.ParseInLocation(DatetimeLayout, s, Location)
+	}
+	t, err := time.ParseInLocation(DatetimeLayout, fmt.Sprintf("%s%02d%s", s[:8], hour-hoursPerDay, s[10:]), Location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid datetime %q: %w", s, err)
+	}
+	// the next day, rolling over the month and the year
+	return t.AddDate(0, 0, 1), nil
+}
+
+// FormatDatetime formats t as the Ft or To of a program in JST
+func FormatDatetime(t time.Time) string {
+	return t.In(Location).Format(DatetimeLayout)
+}
+
+// normalizeDatetime returns s in DatetimeLayout, e.g., "20240101010000" for "20231231250000",
+// or s as is if it does not parse
+func normalizeDatetime(s string) string {
+	t, err := ParseDatetime(s)
+	if err != nil {
+		return s
+	}
+	return FormatDatetime(t)
+}
+
+// ProgramSpan returns the start and the end of the program; an end not after the start,
+// e.g., a To on the day of the Ft for a program crossing midnight, is the next day
+func ProgramSpan(prog *Prog) (from, to time.Time, err error) {
+	if from, err = ParseDatetime(prog.Ft); err != nil {
+		return from, to, err
+	}
+	if to, err = ParseDatetime(prog.To); err != nil {
+		return from, to, err
+	}
+	if !to.After(from) {
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// normalizeProgramTimes rewrites the Ft and To of the program in DatetimeLayout,
+// moving a To not after the Ft to the next day, so that the programs crossing midnight or the New Year
+// are filed under the date they start and end
+func normalizeProgramTimes(prog *Prog) {
+	from, to, err := ProgramSpan(prog)
+	if err != nil {
+		prog.Ft, prog.To = normalizeDatetime(prog.Ft), normalizeDatetime(prog.To)
+		return
+	}
+	prog.Ft, prog.To = FormatDatetime(from), FormatDatetime(to)
+}
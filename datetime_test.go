@@ -0,0 +1,105 @@
+package radikron
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestParseDatetime(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"plain", "20230605130000", time.Date(2023, 6, 5, 13, 0, 0, 0, Location), false},
+		{"midnight", "20230606000000", time.Date(2023, 6, 6, 0, 0, 0, 0, Location), false},
+		{"24:00 notation", "20230605240000", time.Date(2023, 6, 6, 0, 0, 0, 0, Location), false},
+		{"after midnight", "20230605253000", time.Date(2023, 6, 6, 1, 30, 0, 0, Location), false},
+		{"end of the month", "20230630260000", time.Date(2023, 7, 1, 2, 0, 0, 0, Location), false},
+		{"leap day", "20240228240000", time.Date(2024, 2, 29, 0, 0, 0, 0, Location), false},
+		{"New Year", "20231231250000", time.Date(2024, 1, 1, 1, 0, 0, 0, Location), false},
+		{"New Year's Eve", "20231231235959", time.Date(2023, 12, 31, 23, 59, 59, 0, Location), false},
+		{"hour out of range", "20230605480000", time.Time{}, true},
+		{"minute out of range", "20230605256000", time.Time{}, true},
+		{"invalid date", "20230231130000", time.Time{}, true},
+		{"short", "202306051300", time.Time{}, true},
+		{"empty", "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDatetime(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDatetime(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDatetime(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDatetime(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"JST", time.Date(2023, 6, 5, 13, 0, 0, 0, Location), "20230605130000"},
+		{"UTC on New Year's Eve", time.Date(2023, 12, 31, 15, 0, 0, 0, time.UTC), "20240101000000"},
+		{"UTC before midnight in JST", time.Date(2023, 12, 31, 14, 59, 59, 0, time.UTC), "20231231235959"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatDatetime(tt.t); got != tt.want {
+				t.Errorf("FormatDatetime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeProgramTimes(t *testing.T) {
+	tests := []struct {
+		name           string
+		ft, to         string
+		wantFt, wantTo string
+	}{
+		{"within a day", "20230605130000", "20230605140000", "20230605130000", "20230605140000"},
+		{"crossing midnight", "20230605230000", "20230606010000", "20230605230000", "20230606010000"},
+		{"To on the day of Ft", "20230605230000", "20230605010000", "20230605230000", "20230606010000"},
+		{"24:00 notation", "20230605240000", "20230605260000", "20230606000000", "20230606020000"},
+		{"crossing the New Year", "20231231230000", "20231231250000", "20231231230000", "20240101010000"},
+		{"To on New Year's Eve", "20231231233000", "20231231003000", "20231231233000", "20240101003000"},
+		{"invalid To", "20231231250000", "", "20240101010000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := &Prog{Ft: tt.ft, To: tt.to}
+			normalizeProgramTimes(prog)
+			if prog.Ft != tt.wantFt || prog.To != tt.wantTo {
+				t.Errorf("normalizeProgramTimes() = %s-%s, want %s-%s", prog.Ft, prog.To, tt.wantFt, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestProgs_UnmarshalXML_NewYear(t *testing.T) {
+	blob := `<radiko><stations><station id="TBS"><name>TBS</name><progs><date>20231231</date>
+<prog id="1" ft="20231231230000" to="20231231250000"><title>ゆく年くる年</title></prog>
+<prog id="2" ft="20231231250000" to="20231231260000"><title>新春特番</title></prog>
+</progs></station></stations></radiko>`
+	progs := Progs{}
+	if err := xml.Unmarshal([]byte(blob), &progs); err != nil {
+		t.Fatal(err)
+	}
+	if len(progs) != 2 {
+		t.Fatalf("expected 2 programs, got %d", len(progs))
+	}
+	if progs[0].To != "20240101010000" {
+		t.Errorf("expected the To in the New Year, got %s", progs[0].To)
+	}
+	if progs[1].Ft != "20240101010000" || progs[1].To != "20240101020000" {
+		t.Errorf("expected the program filed under the New Year, got %s-%s", progs[1].Ft, progs[1].To)
+	}
+}
@@ -0,0 +1,246 @@
+package radikron
+
+import (
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DigestWindow is the period before now of the downloads listed in the digest
+const DigestWindow = 7 * 24 * time.Hour
+
+// digestTokenName is the name of the digest's private token in the feed tokens,
+// which no device of the feeds can have as a YAML key without quotes
+const digestTokenName = "@digest"
+
+// DigestItem is a download listed in the digest
+type DigestItem struct {
+	StationID string
+	Title     string
+	Pfm       string
+	Desc      string
+	Ft        time.Time
+	Started   time.Time
+	// Path is the output relative to the downloads dir, slash separated
+	Path string
+	// File is the absolute path of the output
+	File string
+	Size int64
+}
+
+// Digest returns the downloads of the history in the DigestWindow before now
+// whose outputs are still in the downloads dir, the newest first
+func Digest(records []DownloadRecord, downloadsDir string, now time.Time) []DigestItem {
+	var items []DigestItem
+	for i := range records {
+		rec := &records[i]
+		if rec.Path == "" || rec.Started.Before(now.Add(-DigestWindow)) || rec.Started.After(now) {
+			continue
+		}
+		rel, err := filepath.Rel(downloadsDir, rec.Path)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		info, err := os.Stat(rec.Path)
+		if err != nil || info.IsDir() {
+			continue // deleted since
+		}
+		item := DigestItem{
+			StationID: rec.StationID,
+			Title:     rec.Title,
+			Pfm:       rec.Pfm,
+			Desc:      rec.Desc,
+			Started:   rec.Started,
+			Path:      filepath.ToSlash(rel),
+			File:      rec.Path,
+			Size:      info.Size(),
+		}
+		if ft, err := ParseDatetime(rec.Ft); err == nil {
+			item.Ft = ft
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Started.After(items[j].Started)
+	})
+	return items
+}
+
+// WeeklyDigest returns the digest of the history of the downloads under the downloads dir in RADICRON_HOME
+func WeeklyDigest(downloadDir string, now time.Time) ([]DigestItem, error) {
+	downloadsDir, err := getRadicronPath(downloadDir)
+	if err != nil {
+		return nil, err
+	}
+	records, err := LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+	return Digest(records, downloadsDir, now), nil
+}
+
+// DigestURLs returns the private URLs of the digest feed and page on the feed server, generating the token
+func DigestURLs(base string) (feedURL, pageURL string, err error) {
+	tokens, err := FeedTokens([]string{digestTokenName})
+	if err != nil {
+		return "", "", err
+	}
+	token := tokens[digestTokenName]
+	return base + "/digest/" + token + ".xml", base + "/digest/" + token + ".html", nil
+}
+
+// newDigestFeed returns the digest as a feed with the enclosures under the digest media URL of the token
+func newDigestFeed(baseURL, token string, items []DigestItem) rssFeed {
+	feed := rssFeed{Version: "2.0", Channel: rssChannel{
+		Title:       "radikron: this week",
+		Link:        baseURL + "/digest/" + token + ".html",
+		Description: "The programs radikron recorded in the last 7 days",
+	}}
+	for _, it := range items {
+		pubDate := it.Ft
+		if pubDate.IsZero() {
+			pubDate = it.Started
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       fmt.Sprintf("[%s] %s", it.StationID, it.Title),
+			Description: it.Desc,
+			GUID:        it.Path,
+			PubDate:     pubDate.Format(time.RFC1123Z),
+			Enclosure: rssEnclosure{
+				URL:    digestMediaURL(baseURL, token, it.Path),
+				Length: it.Size,
+				Type:   audioMIMEType(it.Path),
+			},
+		})
+	}
+	return feed
+}
+
+func digestMediaURL(baseURL, token, rel string) string {
+	return baseURL + "/digest/" + token + "/media/" + (&url.URL{Path: rel}).EscapedPath()
+}
+
+// digestTemplate renders the digest as a page to skim, with the paths of the outputs to delete
+var digestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(Location).Format("2006-01-02 (Mon) 15:04")
+	},
+	"mb": func(size int64) string { return fmt.Sprintf("%.1f MB", float64(size)/(Kilobytes*Kilobytes)) },
+}).Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>radikron: this week</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+li { margin-bottom: 1em; }
+.meta, code { color: #666; font-size: smaller; }
+</style>
+</head>
+<body>
+<h1>radikron: this week</h1>
+<p>{{len .Items}} programs recorded from {{date .From}} to {{date .Now}}</p>
+<ol>
+{{- range .Items}}
+<li><a href="{{call $.Link .}}">[{{.StationID}}] {{.Title}}</a>
+<div class="meta">{{date .Ft}}{{if .Pfm}} – {{.Pfm}}{{end}} – {{mb .Size}}</div>
+{{- if .Desc}}<div>{{.Desc}}</div>{{end}}
+<code>{{.File}}</code></li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+// WriteDigestHTML writes the digest page of the items, linking each to the URL link returns,
+// e.g., the file URL of the output, which the template would otherwise reject as unsafe
+func WriteDigestHTML(w io.Writer, items []DigestItem, now time.Time, link func(DigestItem) string) error {
+	return digestTemplate.Execute(w, struct {
+		Items []DigestItem
+		From  time.Time
+		Now   time.Time
+		Link  func(DigestItem) template.URL
+	}{items, now.Add(-DigestWindow), now, func(it DigestItem) template.URL {
+		return template.URL(link(it)) //nolint:gosec // the links are built by radikron, not the programs
+	}})
+}
+
+// digestToken returns true if token is the digest's, compared in constant time not to leak it
+func digestToken(token string) bool {
+	tokens, err := FeedTokens([]string{digestTokenName})
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(tokens[digestTokenName]), []byte(token)) == 1
+}
+
+func serveDigest(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	ext := path.Ext(file)
+	token := strings.TrimSuffix(file, ext)
+	asset := GetAsset(r.Context())
+	if (ext != ".xml" && ext != ".html") || asset == nil || !digestToken(token) {
+		http.NotFound(w, r)
+		return
+	}
+	base := asset.feedBase(r)
+	now := time.Now()
+	items, err := WeeklyDigest(asset.DownloadDir, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ext == ".html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = WriteDigestHTML(w, items, now, func(it DigestItem) string { return digestMediaURL(base, token, it.Path) })
+		return
+	}
+	blob, err := xml.MarshalIndent(newDigestFeed(base, token, items), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(blob)
+}
+
+func serveDigestMedia(w http.ResponseWriter, r *http.Request) {
+	rel := r.PathValue("path")
+	asset := GetAsset(r.Context())
+	if asset == nil || !digestToken(r.PathValue("token")) || !filepath.IsLocal(filepath.FromSlash(rel)) || !isAudioOutput(rel) {
+		http.NotFound(w, r)
+		return
+	}
+	downloadsDir, err := getRadicronPath(asset.DownloadDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f, err := os.Open(filepath.Join(downloadsDir, filepath.FromSlash(rel)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", audioMIMEType(rel))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -0,0 +1,117 @@
+package radikron
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigest(t *testing.T) {
+	downloads := t.TempDir()
+	now := time.Date(2023, 6, 12, 12, 0, 0, 0, Location)
+	kept := filepath.Join(downloads, "citypop", "city-pop.mp3")
+	if err := os.MkdirAll(filepath.Dir(kept), DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kept, []byte("0123456789"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(downloads, "old.mp3")
+	if err := os.WriteFile(old, []byte("old"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "outside.mp3")
+	if err := os.WriteFile(outside, []byte("outside"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	records := []DownloadRecord{
+		{StationID: "FMT", Title: "Old", Path: old, Started: now.Add(-8 * 24 * time.Hour)},
+		{StationID: "FMT", Title: "City Pop", Ft: "20230610130000", Path: kept, Started: now.Add(-48 * time.Hour)},
+		{StationID: "TBS", Title: "Deleted", Path: filepath.Join(downloads, "deleted.mp3"), Started: now.Add(-time.Hour)},
+		{StationID: "TBS", Title: "Outside", Path: outside, Started: now.Add(-time.Hour)},
+	}
+
+	items := Digest(records, downloads, now)
+	if len(items) != 1 {
+		t.Fatalf("expected the city pop only, got %+v", items)
+	}
+	it := items[0]
+	if it.Title != "City Pop" || it.Path != "citypop/city-pop.mp3" || it.Size != 10 || it.Ft.Day() != 10 {
+		t.Errorf("unexpected item: %+v", it)
+	}
+
+	var page strings.Builder
+	if err := WriteDigestHTML(&page, items, now, func(it DigestItem) string { return "file://" + it.File }); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1 programs recorded", "[FMT] City Pop", `href="file://` + kept, "<code>" + kept + "</code>"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("expected %q in the page:\n%s", want, page.String())
+		}
+	}
+}
+
+func TestServeDigest(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	episode := filepath.Join(home, "downloads", "citypop", "city-pop.mp3")
+	if err := os.MkdirAll(filepath.Dir(episode), DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(episode, []byte("0123456789"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordDownload(DownloadRecord{StationID: "FMT", Title: "City Pop", Path: episode, Started: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	asset := &Asset{DownloadDir: "downloads"}
+	srv := httptest.NewServer(NewFeedHandler(func() *Asset { return asset }))
+	defer srv.Close()
+	if err := asset.SetFeeds(srv.URL, map[string][]string{}); err != nil {
+		t.Fatal(err)
+	}
+	feedURL, pageURL, err := DigestURLs(srv.URL)
+	if err != nil {
+		t.Fatalf("DigestURLs failed: %v", err)
+	}
+
+	get := func(u string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(u) //nolint:gosec
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	_, body := get(feedURL)
+	var feed rssFeed
+	if err := xml.Unmarshal([]byte(body), &feed); err != nil {
+		t.Fatalf("failed to parse the digest: %v\n%s", err, body)
+	}
+	if len(feed.Channel.Items) != 1 || feed.Channel.Items[0].Title != "[FMT] City Pop" {
+		t.Fatalf("expected the city pop, got:\n%s", body)
+	}
+	if status, media := get(feed.Channel.Items[0].Enclosure.URL); status != http.StatusOK || media != "0123456789" {
+		t.Errorf("expected the episode, got %d %q", status, media)
+	}
+	if status, page := get(pageURL); status != http.StatusOK || !strings.Contains(page, "[FMT] City Pop") {
+		t.Errorf("expected the page, got %d:\n%s", status, page)
+	}
+
+	// the digest is private
+	for _, u := range []string{srv.URL + "/digest/wrong.html", srv.URL + "/digest/wrong/media/citypop/city-pop.mp3"} {
+		if status, _ := get(u); status != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", u, status)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package radikron
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+const (
+	// aacBytesPerSecond is the bitrate of radiko's AAC streams (48 kbps)
+	aacBytesPerSecond = 48 * 1000 / 8
+	// mp3BytesPerSecond is the bitrate of the MP3 encoded by ffmpeg's libmp3lame (128 kbps)
+	mp3BytesPerSecond = 128 * 1000 / 8
+)
+
+var (
+	// errInsufficientDiskSpace is returned when a filesystem would fall below MinimumFreeSpace
+	errInsufficientDiskSpace = errors.New("insufficient disk space")
+	// errDiskFreeUnsupported is returned when the platform cannot report the free space
+	errDiskFreeUnsupported = errors.New("free disk space is not available on this platform")
+	// diskFree returns the bytes available to the user on the filesystem of path; replaced in tests
+	diskFree = availableDiskSpace
+)
+
+// estimateDownloadSize returns the bytes the program takes in the aac dir
+// (the concatenated file and a segment margin) and in the output dir
+func estimateDownloadSize(prog *Prog, format string) (aacSize, outputSize int64, err error) {
+	from, err := ParseDatetime(prog.Ft)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start time format '%s': %w", prog.Ft, err)
+	}
+	to, err := ParseDatetime(prog.To)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end time format '%s': %w", prog.To, err)
+	}
+	seconds := int64(to.Sub(from).Seconds())
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	// the segments are removed as they are appended, but a few may be waiting for their turn
+	aacSize = seconds * aacBytesPerSecond * 11 / 10
+	outputSize = seconds * aacBytesPerSecond
+	if format == radigo.AudioFormatMP3 {
+		outputSize = seconds * mp3BytesPerSecond
+	}
+	return aacSize, outputSize, nil
+}
+
+// checkDiskSpace returns errInsufficientDiskSpace if downloading the program would leave
+// less than MinimumFreeSpace on the filesystem of the aac dir or the output dir.
+// The check is skipped if MinimumFreeSpace is 0 or the platform cannot report the free space.
+func checkDiskSpace(asset *Asset, prog *Prog, outputDir string) error {
+	if asset.MinimumFreeSpace <= 0 {
+		return nil
+	}
+	aacSize, outputSize, err := estimateDownloadSize(prog, asset.OutputFormat)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := getRadicronPath("tmp")
+	if err != nil {
+		return err
+	}
+
+	// the output is written while the aac dir still exists, so the output dir
+	// needs both if they share the filesystem
+	for _, c := range []struct {
+		dir      string
+		required int64
+	}{
+		{tmpDir, aacSize},
+		{outputDir, aacSize + outputSize},
+	} {
+		free, err := diskFree(existingDir(c.dir))
+		if errors.Is(err, errDiskFreeUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the free space of %s: %w", c.dir, err)
+		}
+		if free-c.required < asset.MinimumFreeSpace {
+			return fmt.Errorf("%w in %s: %d MB free, %d MB required with %d MB to keep free",
+				errInsufficientDiskSpace, c.dir,
+				free/Kilobytes/Kilobytes, c.required/Kilobytes/Kilobytes, asset.MinimumFreeSpace/Kilobytes/Kilobytes)
+		}
+	}
+	return nil
+}
+
+// existingDir returns the closest existing ancestor of dir, which shares its filesystem
+func existingDir(dir string) string {
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
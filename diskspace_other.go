@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package radikron
+
+// availableDiskSpace is not supported on this platform
+func availableDiskSpace(_ string) (int64, error) {
+	return 0, errDiskFreeUnsupported
+}
@@ -0,0 +1,142 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestEstimateDownloadSize(t *testing.T) {
+	prog := &Prog{Ft: "20240101100000", To: "20240101110000"}
+
+	aacSize, outputSize, err := estimateDownloadSize(prog, radigo.AudioFormatAAC)
+	if err != nil {
+		t.Fatalf("estimateDownloadSize failed: %v", err)
+	}
+	if outputSize != 3600*aacBytesPerSecond {
+		t.Errorf("expected the aac output size %d, got %d", 3600*aacBytesPerSecond, outputSize)
+	}
+	if aacSize <= outputSize {
+		t.Errorf("expected the aac dir to need a margin over %d, got %d", outputSize, aacSize)
+	}
+
+	_, outputSize, err = estimateDownloadSize(prog, radigo.AudioFormatMP3)
+	if err != nil {
+		t.Fatalf("estimateDownloadSize failed: %v", err)
+	}
+	if outputSize != 3600*mp3BytesPerSecond {
+		t.Errorf("expected the mp3 output size %d, got %d", 3600*mp3BytesPerSecond, outputSize)
+	}
+
+	if _, _, err := estimateDownloadSize(&Prog{Ft: "invalid", To: "20240101110000"}, radigo.AudioFormatAAC); err == nil {
+		t.Error("expected an error for an invalid start time")
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	orig := diskFree
+	t.Cleanup(func() { diskFree = orig })
+
+	prog := &Prog{Ft: "20240101100000", To: "20240101110000"} // about 24 MB in aac
+	const mb = Kilobytes * Kilobytes
+	asset := &Asset{OutputFormat: radigo.AudioFormatAAC, MinimumFreeSpace: 100 * mb}
+
+	tests := []struct {
+		name    string
+		free    int64
+		wantErr bool
+	}{
+		{"enough", 1024 * mb, false},
+		{"below the minimum after the download", 140 * mb, true},
+		{"full", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diskFree = func(string) (int64, error) { return tt.free, nil }
+			err := checkDiskSpace(asset, prog, t.TempDir())
+			if tt.wantErr != errors.Is(err, errInsufficientDiskSpace) {
+				t.Errorf("checkDiskSpace() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		diskFree = func(string) (int64, error) { return 0, nil }
+		if err := checkDiskSpace(&Asset{}, prog, t.TempDir()); err != nil {
+			t.Errorf("expected no check with MinimumFreeSpace 0, got %v", err)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		diskFree = func(string) (int64, error) { return 0, errDiskFreeUnsupported }
+		if err := checkDiskSpace(asset, prog, t.TempDir()); err != nil {
+			t.Errorf("expected the check skipped, got %v", err)
+		}
+	})
+}
+
+func TestAvailableDiskSpace(t *testing.T) {
+	free, err := availableDiskSpace(t.TempDir())
+	if errors.Is(err, errDiskFreeUnsupported) {
+		t.Skip("free disk space is not available on this platform")
+	}
+	if err != nil {
+		t.Fatalf("availableDiskSpace failed: %v", err)
+	}
+	if free <= 0 {
+		t.Errorf("expected positive free space, got %d", free)
+	}
+}
+
+func TestExistingDir(t *testing.T) {
+	dir := t.TempDir()
+	if got := existingDir(dir + "/not/yet/created"); got != dir {
+		t.Errorf("expected %s, got %s", dir, got)
+	}
+}
+
+func TestDownload_InsufficientDiskSpace(t *testing.T) {
+	testDir := t.TempDir()
+	t.Setenv(EnvRadicronHome, testDir)
+	orig := diskFree
+	t.Cleanup(func() { diskFree = orig })
+	diskFree = func(string) (int64, error) { return 0, nil }
+
+	CurrentTime = time.Date(2023, 6, 5, 16, 0, 0, 0, Location)
+
+	asset := &Asset{
+		OutputFormat:      radigo.AudioFormatAAC,
+		DownloadDir:       "downloads",
+		MinimumOutputSize: 1024,
+		MinimumFreeSpace:  Kilobytes * Kilobytes,
+		Rules:             Rules{},
+		Schedules:         Schedules{},
+	}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	prog := &Prog{
+		ID:        "test-disk-full",
+		StationID: "FMT",
+		Title:     "Test Program",
+		Ft:        "20230605130000",
+		To:        "20230605140000",
+	}
+
+	err := Download(ctx, &sync.WaitGroup{}, prog)
+	if !errors.Is(err, errInsufficientDiskSpace) {
+		t.Fatalf("expected errInsufficientDiskSpace, got %v", err)
+	}
+	if len(emitter.downloadSkipped) != 1 || emitter.downloadSkipped[0].reason != "insufficient disk space" {
+		t.Errorf("expected an insufficient disk space skip event, got %+v", emitter.downloadSkipped)
+	}
+	if len(emitter.downloadStarted) != 0 {
+		t.Errorf("expected no download started event, got %d", len(emitter.downloadStarted))
+	}
+}
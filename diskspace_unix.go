@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package radikron
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// availableDiskSpace returns the bytes available to the user on the filesystem of path
+func availableDiskSpace(path string) (int64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil //nolint:gosec,unconvert
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package radikron
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// availableDiskSpace returns the bytes available to the user on the filesystem of path
+func availableDiskSpace(path string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return int64(available), nil //nolint:gosec
+}
@@ -12,6 +12,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,91 +23,98 @@ import (
 	"github.com/yyoshiki41/radigo"
 )
 
-var (
-	downloadingSem = make(chan struct{}, MaxDownloadingConcurrency)
-	encodingSem    = make(chan struct{}, MaxEncodingConcurrency)
-	semMu          sync.Mutex // protects semaphore recreation
-)
-
 // emitDownloadStarted emits a download started event if emitter is available, otherwise logs it
-func emitDownloadStarted(ctx context.Context, stationID, title, startTime, uri string) {
-	if emitter := GetEventEmitter(ctx); emitter != nil {
-		emitter.EmitDownloadStarted(stationID, title, startTime, uri)
-	} else {
-		log.Printf("start downloading [%s]%s (%s): %s", stationID, title, startTime, uri)
-	}
+func emitDownloadStarted(ctx context.Context, prog *Prog, uri string) {
+	eventEmitter(ctx).EmitDownloadStarted(prog, uri)
 }
 
 // emitDownloadCompleted emits a download completed event if emitter is available, otherwise logs it
-func emitDownloadCompleted(ctx context.Context, stationID, title, filePath string) {
-	if emitter := GetEventEmitter(ctx); emitter != nil {
-		emitter.EmitDownloadCompleted(stationID, title, filePath)
-	} else {
-		log.Printf("download completed [%s]%s: %s", stationID, title, filePath)
-	}
+func emitDownloadCompleted(ctx context.Context, prog *Prog, filePath string) {
+	eventEmitter(ctx).EmitDownloadCompleted(prog, filePath)
 }
 
 // emitFileSaved emits a file saved event if emitter is available, otherwise logs it
 func emitFileSaved(ctx context.Context, stationID, title, filePath string) {
-	if emitter := GetEventEmitter(ctx); emitter != nil {
-		emitter.EmitFileSaved(stationID, title, filePath)
-	} else {
-		log.Printf("+file saved: %s", filePath)
-	}
+	eventEmitter(ctx).EmitFileSaved(stationID, title, filePath)
 }
 
 // emitDownloadSkipped emits a download skipped event if emitter is available, otherwise logs it
 func emitDownloadSkipped(ctx context.Context, reason, stationID, title, startTime string) {
-	if emitter := GetEventEmitter(ctx); emitter != nil {
-		emitter.EmitDownloadSkipped(reason, stationID, title, startTime)
-	} else {
-		if stationID != "" && title != "" && startTime != "" {
-			log.Printf("-skip %s [%s]%s (%s)", reason, stationID, title, startTime)
-		} else {
-			log.Printf("-skip %s", reason)
-		}
-	}
+	eventEmitter(ctx).EmitDownloadSkipped(reason, stationID, title, startTime)
+}
+
+// emitDownloadProgress emits a download progress event if emitter is available,
+// otherwise logs it at every 10%
+func emitDownloadProgress(ctx context.Context, stationID, title string, done, total int, bytes int64) {
+	eventEmitter(ctx).EmitDownloadProgress(stationID, title, done, total, bytes)
 }
 
 // emitEncodingStarted emits an encoding started event if emitter is available, otherwise logs it
 func emitEncodingStarted(ctx context.Context, filePath string) {
-	if emitter := GetEventEmitter(ctx); emitter != nil {
-		emitter.EmitEncodingStarted(filePath)
-	} else {
-		log.Printf("start encoding to MP3: %s", filePath)
-	}
+	eventEmitter(ctx).EmitEncodingStarted(filePath)
 }
 
 // emitEncodingCompleted emits an encoding completed event if emitter is available, otherwise logs it
 func emitEncodingCompleted(ctx context.Context, filePath string) {
-	if emitter := GetEventEmitter(ctx); emitter != nil {
-		emitter.EmitEncodingCompleted(filePath)
-	} else {
-		log.Printf("finish encoding to MP3: %s", filePath)
-	}
+	eventEmitter(ctx).EmitEncodingCompleted(filePath)
+}
+
+// emitProgramMatched emits a program matched event if emitter is available, otherwise logs it
+func emitProgramMatched(ctx context.Context, stationID, title, startTime, ruleName string) {
+	eventEmitter(ctx).EmitProgramMatched(stationID, title, startTime, ruleName)
+}
+
+// emitProgramUpcoming emits a program upcoming event if emitter is available, otherwise logs it
+func emitProgramUpcoming(ctx context.Context, stationID, title, startTime, ruleName, shareURL, downloadTime string) {
+	eventEmitter(ctx).EmitProgramUpcoming(stationID, title, startTime, ruleName, shareURL, downloadTime)
+}
+
+// emitProgramExtended emits a program extended event if emitter is available, otherwise logs it
+func emitProgramExtended(ctx context.Context, stationID, title, startTime string, usualMinutes, minutes int) {
+	eventEmitter(ctx).EmitProgramExtended(stationID, title, startTime, usualMinutes, minutes)
 }
 
-// emitLogMessage emits a log message if emitter is available, otherwise logs it
+// emitProgramFailed emits a program failed event if emitter is available, otherwise logs it
+func emitProgramFailed(ctx context.Context, stationID, title, startTime string, attempts int) {
+	eventEmitter(ctx).EmitProgramFailed(stationID, title, startTime, attempts)
+}
+
+// emitDownloadStalled emits a download stalled event if emitter is available, otherwise logs it
+func emitDownloadStalled(ctx context.Context, stationID, title, startTime string, timeout time.Duration) {
+	eventEmitter(ctx).EmitDownloadStalled(stationID, title, startTime, timeout)
+}
+
+// emitFileMoved emits a file moved event if emitter is available, otherwise logs it
+func emitFileMoved(ctx context.Context, oldPath, newPath string) {
+	eventEmitter(ctx).EmitFileMoved(oldPath, newPath)
+}
+
+// emitScheduleConflict emits a schedule conflict event if emitter is available, otherwise logs it
+func emitScheduleConflict(ctx context.Context, dropped []*Prog, limit int) {
+	eventEmitter(ctx).EmitScheduleConflict(dropped, limit)
+}
+
+// emitLogMessage emits a log message if emitter is available, otherwise logs it,
+// collapsing its repeats in LogDedupWindow into a summary
 func emitLogMessage(ctx context.Context, level, message string) {
-	if emitter := GetEventEmitter(ctx); emitter != nil {
-		emitter.EmitLogMessage(level, message)
-	} else {
-		// Use default level if empty or missing
-		if level == "" {
-			level = "INFO"
-		}
-		log.Printf("[%s] %s", strings.ToUpper(level), message)
+	emitter := eventEmitter(ctx)
+	if logDedup.suppress(emitter, level, message) {
+		return
 	}
+	if level == "error" {
+		recordError(message)
+	}
+	emitter.EmitLogMessage(level, message)
 }
 
-// InitSemaphores initializes or updates the semaphores based on the asset's concurrency settings.
-// This should be called when configuration is applied to ensure semaphores match the config.
+// InitSemaphores creates or resizes the asset's worker pools and premium stream limit
+// based on its concurrency settings and the throttle window active now
 func InitSemaphores(asset *Asset) {
 	if asset == nil {
 		return
 	}
 
-	maxDownloadingConcurrency := asset.MaxDownloadingConcurrency
+	maxDownloadingConcurrency, _ := asset.throttledLimits(throttleNow())
 	if maxDownloadingConcurrency <= 0 {
 		maxDownloadingConcurrency = MaxDownloadingConcurrency
 	}
@@ -115,15 +124,29 @@ func InitSemaphores(asset *Asset) {
 		maxEncodingConcurrency = MaxEncodingConcurrency
 	}
 
-	semMu.Lock()
-	defer semMu.Unlock()
+	premiumMaxStreams := asset.PremiumMaxStreams
+	if premiumMaxStreams <= 0 {
+		premiumMaxStreams = DefaultPremiumMaxStreams
+	}
+
+	asset.mu.Lock()
+	defer asset.mu.Unlock()
 
-	// Recreate semaphores if values changed
-	if cap(downloadingSem) != maxDownloadingConcurrency {
-		downloadingSem = make(chan struct{}, maxDownloadingConcurrency)
+	// Resize the pools in place so that the running jobs keep their workers
+	if asset.DownloadPool == nil {
+		asset.DownloadPool = NewWorkerPool("download", maxDownloadingConcurrency)
+	} else {
+		asset.DownloadPool.Resize(maxDownloadingConcurrency)
 	}
-	if cap(encodingSem) != maxEncodingConcurrency {
-		encodingSem = make(chan struct{}, maxEncodingConcurrency)
+	if asset.EncodePool == nil {
+		asset.EncodePool = NewWorkerPool("encode", maxEncodingConcurrency)
+	} else {
+		asset.EncodePool.Resize(maxEncodingConcurrency)
+	}
+	if asset.premiumStreams == nil {
+		asset.premiumStreams = newPrioritySemaphore(premiumMaxStreams)
+	} else {
+		asset.premiumStreams.SetCap(premiumMaxStreams)
 	}
 }
 
@@ -141,29 +164,72 @@ func Download(
 	start := prog.Ft
 	var startTime, nextEndTime time.Time
 
-	startTime, err = time.ParseInLocation(DatetimeLayout, start, Location)
+	startTime, err = ParseDatetime(start)
 	if err != nil {
 		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to parse start time '%s': %v", start, err))
 		return fmt.Errorf("invalid start time format '%s': %w", start, err)
 	}
 
-	// the program is in the future
-	if startTime.After(CurrentTime) {
-		nextEndTime, err = time.ParseInLocation(DatetimeLayout, prog.To, Location)
-		if err != nil {
-			emitLogMessage(ctx, "error", fmt.Sprintf("Failed to parse end time '%s': %v", prog.To, err))
-			return fmt.Errorf("invalid end time format '%s': %w", prog.To, err)
+	// Never download filler programs (e.g., 放送休止) matched by a broad rule
+	if filler, ok := asset.IsFillerProgram(prog); ok {
+		emitDownloadSkipped(ctx, "filler program", prog.StationID, title, start)
+		emitLogMessage(ctx, "info", fmt.Sprintf("filler program (%s), skipping [%s]%s (%s)", filler, prog.StationID, title, start))
+		return nil
+	}
+
+	// Report a special edition longer than the usual slot
+	checkExtendedProgram(ctx, prog)
+
+	nextEndTime, err = ParseDatetime(prog.To)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to parse end time '%s': %v", prog.To, err))
+		return fmt.Errorf("invalid end time format '%s': %w", prog.To, err)
+	}
+
+	// The rule records the program from the live stream on air, which RecordLive joins in time
+	if asset.RecordsLive(prog) {
+		planLiveRecording(ctx, prog, startTime, nextEndTime)
+		return nil
+	}
+
+	// the program is in the future or still on air, with only a partial playlist until it ends
+	if nextEndTime.After(CurrentTime) {
+		if !asset.ReadOnly {
+			scheduleProgram(asset, prog)
 		}
-		// update the next fetching time
-		if asset.NextFetchTime == nil || asset.NextFetchTime.After(nextEndTime) {
-			next := nextEndTime.Add(BufferMinutes * time.Minute)
+		// update the next fetching time, unless the fetch-schedule sets it
+		if asset.FetchSchedule == nil && (asset.NextFetchTime == nil || asset.NextFetchTime.After(nextEndTime)) {
+			next := nextFetchAfter(nextEndTime)
 			asset.NextFetchTime = &next
 		}
+		if startTime.After(CurrentTime) {
+			if asset.NotifyUpcoming {
+				notifyUpcomingProgram(ctx, prog, nextEndTime.Add(BufferMinutes*time.Minute))
+			}
+			emitLogMessage(ctx, "info", fmt.Sprintf(
+				"skipping future program [%s]%s (starts at %s, current time %s)",
+				prog.StationID, title, start, CurrentTime.Format(DatetimeLayout)))
+			return nil
+		}
 		emitLogMessage(ctx, "info", fmt.Sprintf(
-			"skipping future program [%s]%s (starts at %s, current time %s)",
-			prog.StationID, title, start, CurrentTime.Format(DatetimeLayout)))
+			"deferring in-progress program [%s]%s until it ends at %s (current time %s)",
+			prog.StationID, title, prog.To, CurrentTime.Format(DatetimeLayout)))
+		return nil
+	}
+	// no download starts in the quiet hours, while the ones in flight finish
+	if !asset.ReadOnly && deferForQuietHours(ctx, asset, prog, CurrentTime) {
 		return nil
 	}
+	return startDownload(ctx, wg, prog, startTime, false)
+}
+
+// startDownload prepares the output of the program and downloads it in the background,
+// from timefree or, if live, from the live stream until the program ends
+func startDownload(ctx context.Context, wg *sync.WaitGroup, prog *Prog, startTime time.Time, live bool) error {
+	asset := GetAsset(ctx)
+	title := prog.Title
+	start := prog.Ft
+	unscheduleProgram(prog)
 
 	// Check for duplicate in schedules (for direct calls to Download, e.g., in tests)
 	// Note: In normal flow, processProgram() checks duplicates before adding to schedules,
@@ -174,31 +240,27 @@ func Download(
 		return nil
 	}
 
-	// the output config
-	fileBaseName := fmt.Sprintf(
-		"%s_%s_%s",
-		startTime.In(Location).Format(OutputDatetimeLayout),
-		prog.StationID,
-		title,
-	)
-	output, err := newOutputConfig(
-		fileBaseName,
-		asset.OutputFormat,
-		asset.DownloadDir,
-		prog.RuleFolder,
-	)
-	if err != nil {
-		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to configure output: %v", err))
-		return fmt.Errorf("failed to configure output: %w", err)
+	// Only report the match in read-only mode, never write anything
+	if asset.ReadOnly {
+		emitProgramMatched(ctx, prog.StationID, title, start, prog.RuleName)
+		return nil
 	}
 
-	if err = output.SetupDir(); err != nil {
-		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to setup output dir: %v", err))
-		return fmt.Errorf("failed to setup the output dir: %w", err)
+	// the episode the output is named with, only looked up until the program is to download
+	if asset.filenameNamesEpisode() {
+		setEpisode(ctx, prog, false)
+	}
+
+	// the output config
+	folder := outputFolder(asset, prog)
+	fileBaseName, output, err := configureOutput(ctx, asset, prog, folder, startTime)
+	if err != nil {
+		return err
 	}
 
 	// Final check: verify target location doesn't exist before proceeding with download
-	if output.IsExist() {
+	if output.IsExist() || existsInStorage(ctx, output.AbsPath()) {
+		dequeueProgram(ctx, prog)
 		emitDownloadSkipped(ctx, "already exists", prog.StationID, title, start)
 		emitLogMessage(ctx, "info", fmt.Sprintf("file already exists at target, skipping [%s]%s: %s", prog.StationID, title, output.AbsPath()))
 		return nil
@@ -207,19 +269,150 @@ func Download(
 	// Check for duplicates and move from default folder to configured folder if needed
 	// handleDuplicate checks other locations and handles skip cases
 	if err := handleDuplicate(
-		ctx, fileBaseName, asset.OutputFormat, asset.DownloadDir,
-		prog.RuleFolder, output, asset.Rules, prog.StationID, title, start); err != nil {
+		ctx, fileBaseName, asset.OutputFormat, asset.outputDir(),
+		folder, output, asset.Rules, prog.StationID, title, start); err != nil {
 		// If errSkipAfterMove, file was moved and exists at target - skip without logging again
 		if errors.Is(err, errSkipAfterMove) {
+			dequeueProgram(ctx, prog)
 			return nil
 		}
 		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to handle duplicate: %v", err))
 		return fmt.Errorf("failed to handle duplicate: %w", err)
 	}
 
+	// A program renamed mid-season may have been downloaded under its old title
+	if existingPath, ok := findAliasedDuplicate(ctx, asset, prog, startTime, output); ok {
+		dequeueProgram(ctx, prog)
+		emitDownloadSkipped(ctx, "already exists", prog.StationID, title, start)
+		emitLogMessage(ctx, "info", fmt.Sprintf("program already downloaded under an old title as %s, skipping [%s]%s", existingPath, prog.StationID, title))
+		return nil
+	}
+
+	// A file renamed after the download is still recognized by the program ID in its tag
+	if existingPath, ok := findDownloadedProgram(asset.DownloadDir, prog.ID); ok {
+		dequeueProgram(ctx, prog)
+		emitDownloadSkipped(ctx, "already exists", prog.StationID, title, start)
+		emitLogMessage(ctx, "info", fmt.Sprintf("program already downloaded as %s, skipping [%s]%s", existingPath, prog.StationID, title))
+		return nil
+	}
+
+	// The download is complete and only its deferred encoding is pending
+	if isEncodePending(prog) {
+		dequeueProgram(ctx, prog)
+		emitDownloadSkipped(ctx, "encoding pending", prog.StationID, title, start)
+		return nil
+	}
+
+	// Never retry a program failing every attempt
+	if hasGivenUp(prog) {
+		dequeueProgram(ctx, prog)
+		emitDownloadSkipped(ctx, "given up", prog.StationID, title, start)
+		return nil
+	}
+
+	// Number the episode of the rule to tag the output with only now, so that the skipped programs take none;
+	// another program of the rule may have taken the number the output was named with meanwhile
+	named := prog.Episode
+	setEpisode(ctx, prog, true)
+	if prog.Episode != named && asset.filenameNamesEpisode() {
+		if _, output, err = configureOutput(ctx, asset, prog, folder, startTime); err != nil {
+			return err
+		}
+	}
+
+	// Keep the program in the queue until downloaded, so that a restart resumes it;
+	// a live recording cannot be resumed after its program
+	if !live {
+		enqueueProgram(ctx, prog)
+	}
+
+	// Skip the program rather than writing a truncated file to a full disk
+	if err := checkDiskSpace(asset, prog, filepath.Dir(output.AbsPath())); err != nil {
+		emitDownloadSkipped(ctx, "insufficient disk space", prog.StationID, title, start)
+		emitLogMessage(ctx, "error", fmt.Sprintf("Failed the disk space check for [%s]%s (%s): %v", prog.StationID, title, start, err))
+		return fmt.Errorf("disk space check failed: %w", err)
+	}
+
+	// release the resources held for the download in reverse order
+	var releases []func(downloaded bool)
+	finish := func(downloaded bool) {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i](downloaded)
+		}
+	}
+
+	// The same program may be both resumed from the queue and found in the program guide
+	if !acquireInFlight(prog) {
+		emitDownloadSkipped(ctx, "already downloading", prog.StationID, title, start)
+		return nil
+	}
+	releases = append(releases, func(downloaded bool) {
+		if downloaded {
+			dequeueProgram(ctx, prog)
+		}
+		releaseInFlight(prog)
+	})
+
+	// Two programs (e.g., matched by the rules with the same folder) may share the output path;
+	// the one taking the target first writes it, and the other skips it whether still writing or done
+	if !acquireTarget(output.AbsPath()) {
+		finish(false)
+		emitDownloadSkipped(ctx, "already downloading", prog.StationID, title, start)
+		emitLogMessage(ctx, "info", fmt.Sprintf("output is being written, skipping [%s]%s: %s", prog.StationID, title, output.AbsPath()))
+		return nil
+	}
+	releases = append(releases, func(bool) { releaseTarget(output.AbsPath()) })
+	if output.IsExist() || existsInStorage(ctx, output.AbsPath()) {
+		finish(true)
+		emitDownloadSkipped(ctx, "already exists", prog.StationID, title, start)
+		emitLogMessage(ctx, "info", fmt.Sprintf("file already exists at target, skipping [%s]%s: %s", prog.StationID, title, output.AbsPath()))
+		return nil
+	}
+
+	// Only one of the coordinated instances downloads the program
+	if asset.CoordinationDir != "" {
+		lock, err := acquireProgramLock(asset.CoordinationDir, asset.InstanceID, asset.CoordinationLease, prog)
+		switch {
+		case errors.Is(err, errProgramDone):
+			finish(true)
+			emitDownloadSkipped(ctx, "downloaded by another instance", prog.StationID, title, start)
+			return nil
+		case errors.Is(err, errProgramLocked):
+			finish(false)
+			emitDownloadSkipped(ctx, "downloading by another instance", prog.StationID, title, start)
+			return nil
+		case err != nil:
+			finish(false)
+			emitLogMessage(ctx, "error", fmt.Sprintf("Failed to lock the program: %v", err))
+			return fmt.Errorf("failed to lock the program: %w", err)
+		}
+		// release the lock to let another instance take over on failure
+		releases = append(releases, func(downloaded bool) {
+			if err := lock.release(downloaded); err != nil {
+				emitLogMessage(ctx, "error", fmt.Sprintf("failed to release the program lock: %v", err))
+			}
+		})
+	}
+
+	// Wait for a premium stream before requesting the playlist
+	if usesPremiumStream(asset, prog) {
+		release, err := acquirePremiumStream(ctx, asset, prog)
+		if err != nil {
+			finish(false)
+			return fmt.Errorf("canceled waiting for a premium stream: %w", err)
+		}
+		releases = append(releases, func(bool) { release() })
+	}
+
 	// fetch the recording m3u8 uri
-	uri, err := timeshiftProgM3U8(ctx, prog)
+	var uri string
+	if live {
+		uri, err = liveStreamM3U8(ctx, prog)
+	} else {
+		uri, err = timeshiftProgM3U8(ctx, prog)
+	}
 	if err != nil {
+		finish(false)
 		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to fetch M3U8 URI: %v", err))
 		return fmt.Errorf(
 			"playlist.m3u8 not available [%s]%s (%s): %s",
@@ -233,10 +426,14 @@ func Download(
 	if prog.RuleName != "" {
 		emitLogMessage(ctx, "info", fmt.Sprintf("rule[%s] matched: [%s]%s (%s)", prog.RuleName, prog.StationID, title, start))
 	}
-	emitDownloadStarted(ctx, prog.StationID, title, start, uri)
+	emitDownloadStarted(ctx, prog, uri)
 	prog.M3U8 = uri
 	wg.Add(1)
-	go downloadProgram(ctx, wg, prog, output)
+	if live {
+		go recordLiveProgram(ctx, wg, prog, output, finish)
+	} else {
+		go downloadProgram(ctx, wg, prog, output, finish)
+	}
 	return nil
 }
 
@@ -261,31 +458,59 @@ func buildM3U8RequestURI(prog *Prog) string {
 	return u.String()
 }
 
-func bulkDownload(list []string, output string) error {
+// bulkDownload downloads the segments in the list to the output dir.
+// If appender is not nil, the completed segments are skipped and the newly downloaded ones are appended.
+// If progress is not nil, each downloaded segment is reported to it.
+// The segments of the program expiring first from timefree (see withTimefreeExpiry) are downloaded first.
+// Canceling ctx aborts the in-flight segments, leaving the completed ones in the manifest to resume later.
+func bulkDownload(
+	ctx context.Context,
+	list []string,
+	output string,
+	appender *segmentAppender,
+	progress *downloadProgress,
+) error {
 	var (
-		errFlag bool
-		mu      sync.Mutex
+		errFlag     bool
+		authExpired bool
+		mu          sync.Mutex
 	)
 	var wg sync.WaitGroup
+	expiry := timefreeExpiryFromContext(ctx)
+	pool := GetAsset(ctx).downloadPool()
 
 	for _, v := range list {
+		if appender != nil && appender.isCompleted(v) {
+			continue
+		}
 		wg.Add(1)
 		go func(link string) {
 			defer wg.Done()
 
-			var err error
-			for i := 0; i < MaxRetryAttempts; i++ {
-				downloadingSem <- struct{}{}
-				err = downloadLink(link, output)
-				<-downloadingSem
-				if err == nil {
-					break
+			attempts := 0
+			err := currentRetryPolicy(ctx).Do(ctx, func() error {
+				attempts++
+				return pool.Run(ctx, expiry, func() error {
+					defer progress.begin()()
+					return downloadLink(ctx, link, output)
+				})
+			})
+			progress.retry(attempts - 1)
+			if err == nil && progress != nil {
+				var size int64
+				if info, statErr := os.Stat(filepath.Join(output, segmentFileName(link))); statErr == nil {
+					size = info.Size()
 				}
+				progress.add(size)
+			}
+			if err == nil && appender != nil {
+				err = appender.add(link)
 			}
 			if err != nil {
-				log.Printf("failed to download: %s", err)
+				emitLogMessage(ctx, "warn", fmt.Sprintf("failed to download: %s", err))
 				mu.Lock()
 				errFlag = true
+				authExpired = authExpired || errors.Is(err, errAuthExpired)
 				mu.Unlock()
 			}
 		}(v)
@@ -293,32 +518,77 @@ func bulkDownload(list []string, output string) error {
 	wg.Wait()
 
 	mu.Lock()
-	hasError := errFlag
+	hasError, hasAuthExpired := errFlag, authExpired
 	mu.Unlock()
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if hasAuthExpired {
+		return fmt.Errorf("lack of aac files: %w", errAuthExpired)
+	}
 	if hasError {
 		return errors.New("lack of aac files")
 	}
 	return nil
 }
 
-func downloadLink(link, output string) error {
-	resp, err := http.Get(link) //nolint:gosec,noctx
+// errTruncatedSegment is returned when a segment is shorter than its Content-Length or MinimumSegmentSize
+var errTruncatedSegment = errors.New("truncated segment")
+
+// downloadLink downloads the segment to the output dir.
+// A truncated segment is deleted and errTruncatedSegment is returned to download it again.
+func downloadLink(ctx context.Context, link, output string) error {
+	if err := waitRateLimit(ctx); err != nil {
+		return err
+	}
+	if err := simulateFailure("segment download"); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req) //nolint:gosec
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if err := checkAuthStatus(resp); err != nil {
+		return err
+	}
+	if err := checkResponseStatus(resp); err != nil {
+		return err
+	}
 
-	_, fileName := filepath.Split(link)
-	file, err := os.Create(filepath.Join(output, fileName))
+	path := filepath.Join(output, segmentFileName(link))
+	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(file, resp.Body)
+	written, err := io.Copy(file, resp.Body)
 	if closeErr := file.Close(); err == nil {
 		err = closeErr
 	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	var minimumSize int64
+	if asset := GetAsset(ctx); asset != nil {
+		minimumSize = asset.MinimumSegmentSize
+	}
+	switch {
+	case resp.ContentLength >= 0 && written != resp.ContentLength:
+		err = fmt.Errorf("%w: %s got %d of %d bytes", errTruncatedSegment, link, written, resp.ContentLength)
+	case written < minimumSize:
+		err = fmt.Errorf("%w: %s got %d bytes, less than %d", errTruncatedSegment, link, written, minimumSize)
+	default:
+		return nil
+	}
+	os.Remove(path)
 	return err
 }
 
@@ -329,72 +599,245 @@ func downloadProgram(
 	wg *sync.WaitGroup, // the wg to notify
 	prog *Prog, // the program metadata
 	output *radigo.OutputConfig, // the file configuration
+	finish func(downloaded bool), // called with the result when finished (optional)
 ) {
 	defer wg.Done()
 	var err error
+	completed := false
+	saved := false
+	// transcribe after finish releases the program (e.g., the premium stream), but before wg.Done
+	defer func() {
+		if saved {
+			transcribeOutput(ctx, prog, output)
+		}
+	}()
+	// the segments and the encoding of the program expiring first from timefree go first
+	ctx = withTimefreeExpiry(ctx, prog)
+
+	if finish != nil {
+		defer func() { finish(completed) }()
+	}
 
-	chunklist, err := getChunklistFromM3U8(prog.M3U8)
+	segments, err := getMediaSegmentsFromM3U8(ctx, prog.M3U8)
+	chunklist := segmentURIs(segments)
+	if errors.Is(err, errAuthExpired) {
+		chunklist, err = refreshChunklist(ctx, prog, nil)
+	}
 	if err != nil {
 		log.Printf("failed to get chunklist: %s", err)
 		return
 	}
+	if asset := GetAsset(ctx); asset != nil && asset.AdBreakChapters {
+		prog.Chapters = chapterEnds(segments)
+	}
 
-	aacDir, err := tempAACDir()
+	aacDir, manifest, err := prepareAACDir(prog, chunklist)
 	if err != nil {
 		log.Printf("failed to create the aac dir: %s", err)
 		return
 	}
-	defer os.RemoveAll(aacDir) // clean up
+	defer releaseAACDir(aacDir)
+	defer func() {
+		// keep the aac dir of an unfinished download to resume it later
+		if completed || manifest == nil {
+			os.RemoveAll(aacDir) // clean up
+			if manifest != nil {
+				_ = manifest.remove()
+			}
+		}
+	}()
+	resumed := 0
+	if manifest != nil && manifest.completedCount() > 0 {
+		resumed = manifest.completedCount()
+		log.Printf("resuming download [%s]%s from %d/%d segments", prog.StationID, prog.Title, resumed, len(chunklist))
+	}
 
-	if err = bulkDownload(chunklist, aacDir); err != nil {
+	// the segments are concatenated as they are downloaded
+	appender, err := newSegmentAppender(aacDir, chunklist, manifest)
+	if err != nil {
+		log.Printf("failed to create the concatenated file: %s", err)
+		return
+	}
+	progress := newDownloadProgress(ctx, prog, len(chunklist), resumed)
+	ctx, stopWatchdog := watchDownload(ctx, progress)
+	defer stopWatchdog()
+	started := time.Now()
+	err = bulkDownload(ctx, chunklist, aacDir, appender, progress)
+	if errors.Is(err, errAuthExpired) {
+		// the token expired mid-session: continue once with the playlist fetched with a new token
+		var refreshed []string
+		if refreshed, err = refreshChunklist(ctx, prog, chunklist); err == nil {
+			err = bulkDownload(ctx, refreshed, aacDir, appender, progress)
+		}
+	}
+	concatedFile, closeErr := appender.close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if errors.Is(context.Cause(ctx), errDownloadStalled) {
+			// the completed segments are kept in the manifest to resume from the queue
+			emitDownloadStalled(ctx, prog.StationID, prog.Title, prog.Ft, GetAsset(ctx).DownloadStallTimeout)
+			countFailedAttempt(ctx, prog)
+			return
+		}
+		if ctx.Err() != nil {
+			// the completed segments are kept in the manifest to resume later
+			log.Printf("download canceled [%s]%s: %s", prog.StationID, prog.Title, err)
+			return
+		}
 		log.Printf("failed to download aac files: %s", err)
 		return
 	}
 
-	// Download completed - tmp files are ready for concatenation and validation
-	emitDownloadCompleted(ctx, prog.StationID, prog.Title, output.AbsPath())
+	// Download completed - the concatenated file is ready for validation
+	emitDownloadCompleted(ctx, prog, output.AbsPath())
+	bytes, retries := progress.stats()
+	logDownloadRecord(ctx, newDownloadRecord(prog, output.AbsPath(), started, bytes, retries))
 
-	concatedFile, err := radigo.ConcatAACFilesFromList(ctx, aacDir)
-	if err != nil {
-		log.Printf("failed to concat aac files: %s", err)
+	// Encode after all the downloads complete, not to delay the next fetch, or after the quiet hours
+	if asset := GetAsset(ctx); asset != nil && output.AudioFormat() == radigo.AudioFormatMP3 &&
+		(asset.DeferredEncoding || asset.ActiveQuietHours(throttleNow()) != nil) {
+		if err = deferEncoding(prog, concatedFile, output); err != nil {
+			log.Printf("failed to queue the encoding: %s", err)
+			return
+		}
+		completed = true
+		emitLogMessage(ctx, "info", fmt.Sprintf("queued the encoding of [%s]%s", prog.StationID, prog.Title))
 		return
 	}
 
-	if err = writeOutputFile(ctx, concatedFile, output); err != nil {
+	completed = finalizeOutput(ctx, prog, concatedFile, output)
+	saved = completed
+}
+
+// finalizeOutput writes the concatenated file to the output, validates it, and tags it.
+// The file is written as "<name>.<ext>.part" and renamed only once complete,
+// so that the tools watching the downloads never pick up a half-written file.
+// Returns true if the output is saved.
+func finalizeOutput(ctx context.Context, prog *Prog, concatedFile string, output *radigo.OutputConfig) bool {
+	part := partOutputConfig(output)
+	if err := writeOutputFile(ctx, concatedFile, part); err != nil {
 		log.Printf("failed to write the output file: %s", err)
-		return
+		os.Remove(part.AbsPath())
+		return false
 	}
 
-	if shouldRetry := validateAndCleanupOutputFile(ctx, output); shouldRetry {
-		return
+	if shouldRetry := validateAndCleanupOutputFile(ctx, part); shouldRetry {
+		countFailedAttempt(ctx, prog)
+		return false
+	}
+	if shouldRetry := validateOutputDuration(ctx, prog, part); shouldRetry {
+		countFailedAttempt(ctx, prog)
+		return false
 	}
 
-	err = writeID3Tag(output, prog)
-	if err != nil {
+	if strings.TrimSuffix(part.AudioFormat(), PartFileExt) == AudioFormatM4A {
+		if err := writeM4ATags(ctx, part, prog); err != nil {
+			emitLogMessage(ctx, "error", fmt.Sprintf("M4A metadata: %v", err))
+			os.Remove(part.AbsPath())
+			return false
+		}
+	} else if err := writeID3Tag(GetAsset(ctx), part, prog); err != nil {
 		emitLogMessage(ctx, "error", fmt.Sprintf("ID3v2: %v", err))
-		return
+		os.Remove(part.AbsPath())
+		return false
+	}
+
+	if asset := GetAsset(ctx); asset != nil {
+		// Extended attributes are best-effort as not all filesystems support them
+		if asset.WriteXattrs {
+			if err := writeXattrs(part, prog); err != nil {
+				emitLogMessage(ctx, "error", fmt.Sprintf("failed to write extended attributes: %v", err))
+			}
+		}
+		// Set the mtime to the broadcast time so the files sort in broadcast order
+		if asset.PreserveTimestamp {
+			if err := setBroadcastTime(part, prog); err != nil {
+				emitLogMessage(ctx, "error", fmt.Sprintf("failed to set the file timestamp: %v", err))
+			}
+		}
+	}
+
+	// the rename keeps the extended attributes and the mtime
+	if err := os.Rename(part.AbsPath(), output.AbsPath()); err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to rename the output file: %v", err))
+		os.Remove(part.AbsPath())
+		return false
+	}
+
+	// The sidecar files are best-effort like the extended attributes
+	if asset := GetAsset(ctx); asset != nil && len(asset.Sidecars) > 0 {
+		if err := writeSidecars(output, prog, asset.Sidecars); err != nil {
+			emitLogMessage(ctx, "error", fmt.Sprintf("failed to write the sidecar files: %v", err))
+		}
+	}
+	// The staged output goes to the remote storage, or is retried with a new download
+	if storage := remoteStorage(ctx); storage != nil {
+		if err := saveToStorage(ctx, storage, output.AbsPath()); err != nil {
+			emitLogMessage(ctx, "error", err.Error())
+			os.Remove(output.AbsPath())
+			for _, ext := range sidecarExts() {
+				os.Remove(sidecarPath(output.AbsPath(), ext))
+			}
+			countFailedAttempt(ctx, prog)
+			return false
+		}
+	} else {
+		indexOutput(output.AbsPath())
+	}
+	// So is the folder art, not in the download dir shared by the stations nor in a remote storage
+	if asset := GetAsset(ctx); asset != nil && asset.FolderArt && asset.Storage == nil && outputFolder(asset, prog) != "" {
+		if err := writeFolderArt(ctx, asset, prog.StationID, filepath.Dir(output.AbsPath())); err != nil {
+			emitLogMessage(ctx, "error", fmt.Sprintf("failed to write the folder art: %v", err))
+		}
+	}
+	// And the program image in the gallery of the folder
+	if asset := GetAsset(ctx); asset != nil && asset.ImageGallery && asset.Storage == nil && outputFolder(asset, prog) != "" {
+		if err := writeGalleryImage(ctx, prog, output.AbsPath()); err != nil {
+			emitLogMessage(ctx, "error", fmt.Sprintf("failed to save the program image: %v", err))
+		}
+	}
+
+	if err := clearFailedAttempts(prog); err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to clear the failed attempts: %v", err))
 	}
 
 	// File saved - metadata tags have been written
 	emitFileSaved(ctx, prog.StationID, prog.Title, output.AbsPath())
+	return true
+}
+
+// partOutputConfig returns the output written as "<name>.<ext>.part" until complete
+func partOutputConfig(output *radigo.OutputConfig) *radigo.OutputConfig {
+	return &radigo.OutputConfig{
+		DirFullPath:  output.DirFullPath,
+		FileBaseName: output.FileBaseName,
+		FileFormat:   output.FileFormat + PartFileExt,
+	}
 }
 
 // writeOutputFile writes the concatenated file to the output location,
-// handling format conversion (AAC to MP3) if needed.
+// handling format conversion (AAC to MP3) or the remux (AAC to M4A) if needed.
 func writeOutputFile(ctx context.Context, concatedFile string, output *radigo.OutputConfig) error {
-	switch output.AudioFormat() {
+	switch strings.TrimSuffix(output.AudioFormat(), PartFileExt) {
 	case radigo.AudioFormatAAC:
 		return os.Rename(concatedFile, output.AbsPath())
 	case radigo.AudioFormatMP3:
 		// Limit concurrent encoding operations to prevent resource exhaustion
-		encodingSem <- struct{}{}
-		defer func() { <-encodingSem }()
-		emitEncodingStarted(ctx, output.AbsPath())
-		err := convertAACtoMP3(ctx, concatedFile, output.AbsPath())
-		if err == nil {
-			emitEncodingCompleted(ctx, output.AbsPath())
+		return GetAsset(ctx).encodePool().Run(ctx, timefreeExpiryFromContext(ctx), func() error {
+			emitEncodingStarted(ctx, output.AbsPath())
+			err := convertAACtoMP3(ctx, concatedFile, output.AbsPath())
+			if err == nil {
+				emitEncodingCompleted(ctx, output.AbsPath())
+			}
+			return err
+		})
+	case AudioFormatM4A:
+		if err := remuxAACtoM4A(ctx, concatedFile, output.AbsPath()); err != nil {
+			return err
 		}
-		return err
+		return os.Remove(concatedFile)
 	default:
 		return fmt.Errorf("invalid file format")
 	}
@@ -412,42 +855,60 @@ func validateAndCleanupOutputFile(ctx context.Context, output *radigo.OutputConf
 	asset := GetAsset(ctx)
 	if info.Size() < asset.MinimumOutputSize {
 		log.Printf("the output file is too small: %v MB", float32(info.Size())/Kilobytes/Kilobytes)
-		err = os.Remove(output.AbsPath())
-		if err != nil {
-			log.Printf("failed to remove the file: %v", err)
-			return false
-		}
-		next := time.Now().In(Location).Add(BufferMinutes * time.Minute)
-		asset.NextFetchTime = &next
-		log.Printf("removed the file, retry downloading at %v", next)
-		return true
+		return removeOutputForRetry(asset, output)
 	}
 	return false
 }
 
+// removeOutputForRetry removes the invalid output file and schedules a retry.
+// Returns true if a retry was scheduled.
+func removeOutputForRetry(asset *Asset, output *radigo.OutputConfig) bool {
+	err := os.Remove(output.AbsPath())
+	if err != nil {
+		log.Printf("failed to remove the file: %v", err)
+		return false
+	}
+	next := time.Now().In(Location).Add(BufferMinutes * time.Minute)
+	asset.NextFetchTime = &next
+	log.Printf("removed the file, retry downloading at %v", next)
+	return true
+}
+
+// DefaultFFmpegArgs are the encoder arguments of ffmpeg unless the asset's FFmpegArgs override them
+var DefaultFFmpegArgs = []string{"-acodec", "libmp3lame", "-ar", "44100"}
+
 // convertAACtoMP3 converts an AAC file to MP3 format using ffmpeg.
+// The asset's FFmpegPath and FFmpegArgs, if set, override the ffmpeg binary and the encoder arguments,
+// and its MP3Bitrate or MP3Quality, if set, follow the encoder arguments.
 func convertAACtoMP3(ctx context.Context, sourceFile, destFile string) error {
+	asset := GetAsset(ctx)
+	if err := simulateFailure("encode"); err != nil {
+		return err
+	}
+
 	// Check if ffmpeg is available
-	ffmpegPath, err := exec.LookPath("ffmpeg")
+	ffmpegPath, err := lookFFmpeg(asset)
 	if err != nil {
-		return fmt.Errorf("ffmpeg not found in PATH: %w", err)
+		return err
 	}
 
 	// Build ffmpeg command:
 	// -i: input file
-	// -acodec libmp3lame: use MP3 codec
-	// -ar 44100: sample rate 44.1kHz
+	// -acodec libmp3lame: use MP3 codec (DefaultFFmpegArgs, unless overridden)
+	// -ar 44100: sample rate 44.1kHz (DefaultFFmpegArgs, unless overridden)
+	// -f mp3: the MP3 container, as the destination may have the .part extension
 	// -y: overwrite output file if it exists
 	// -loglevel error: only show errors
-	cmd := exec.CommandContext(ctx, ffmpegPath,
-		"-i", sourceFile,
-		"-acodec", "libmp3lame",
+	args := []string{"-i", sourceFile}
+	args = append(args, mp3EncoderArgs(asset)...)
+	args = append(args,
+		"-f", "mp3",
 		"-map_metadata", "0",
-		"-ar", "44100",
 		"-y",
 		"-loglevel", "error",
 		destFile,
 	)
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
 
 	// Capture stderr for error messages
 	var stderr bytes.Buffer
@@ -460,32 +921,114 @@ func convertAACtoMP3(ctx context.Context, sourceFile, destFile string) error {
 	return nil
 }
 
-// getChunklist returns a slice of uri string.
-func getChunklist(input io.Reader) ([]string, error) {
+// mp3EncoderArgs returns the encoder arguments of ffmpeg for the MP3 outputs of the asset:
+// its FFmpegArgs or DefaultFFmpegArgs, followed by its MP3Bitrate or MP3Quality, if set
+func mp3EncoderArgs(asset *Asset) []string {
+	encoderArgs := DefaultFFmpegArgs
+	if asset != nil && len(asset.FFmpegArgs) > 0 {
+		encoderArgs = asset.FFmpegArgs
+	}
+	args := slices.Clone(encoderArgs)
+	// -b:a: the constant bitrate, or -q:a: the VBR quality
+	if asset != nil && asset.MP3Bitrate != "" {
+		args = append(args, "-b:a", asset.MP3Bitrate)
+	}
+	if asset != nil && asset.MP3Quality != nil {
+		args = append(args, "-q:a", strconv.Itoa(*asset.MP3Quality))
+	}
+	return args
+}
+
+// lookFFmpeg returns the ffmpeg binary configured in the asset, or the one found by FindFFmpeg
+func lookFFmpeg(asset *Asset) (string, error) {
+	if asset != nil {
+		return FindFFmpeg(asset.FFmpegPath)
+	}
+	return FindFFmpeg("")
+}
+
+// mediaSegment is a segment of a media playlist
+type mediaSegment struct {
+	uri      string
+	duration time.Duration
+	// discontinuity is true if a discontinuity marker precedes the segment, usually at an ad or a junction break
+	discontinuity bool
+}
+
+// getMediaSegments returns the segments of the media playlist.
+func getMediaSegments(input io.Reader) ([]mediaSegment, error) {
 	playlist, listType, err := m3u8.DecodeFrom(input, true)
 	if err != nil || listType != m3u8.MEDIA {
 		return nil, err
 	}
 	p := playlist.(*m3u8.MediaPlaylist)
 
-	var chunklist []string
+	var segments []mediaSegment
 	for _, v := range p.Segments {
 		if v != nil {
-			chunklist = append(chunklist, v.URI)
+			segments = append(segments, mediaSegment{
+				uri:           v.URI,
+				duration:      time.Duration(v.Duration * float64(time.Second)),
+				discontinuity: v.Discontinuity,
+			})
 		}
 	}
-	return chunklist, nil
+	return segments, nil
 }
 
-// getChunklistFromM3U8 returns a slice of url.
-func getChunklistFromM3U8(uri string) ([]string, error) {
-	resp, err := http.Get(uri) //nolint:gosec,noctx
-	if err != nil {
-		return nil, err
+// segmentURIs returns the uris of the segments.
+func segmentURIs(segments []mediaSegment) []string {
+	var chunklist []string
+	for _, s := range segments {
+		chunklist = append(chunklist, s.uri)
 	}
-	defer resp.Body.Close()
+	return chunklist
+}
+
+// getChunklist returns a slice of uri string.
+func getChunklist(input io.Reader) ([]string, error) {
+	segments, err := getMediaSegments(input)
+	return segmentURIs(segments), err
+}
 
-	return getChunklist(resp.Body)
+// getChunklistFromM3U8 returns a slice of url.
+func getChunklistFromM3U8(ctx context.Context, uri string) ([]string, error) {
+	segments, err := getMediaSegmentsFromM3U8(ctx, uri)
+	return segmentURIs(segments), err
+}
+
+// getMediaSegmentsFromM3U8 returns the segments of the media playlist at uri.
+func getMediaSegmentsFromM3U8(ctx context.Context, uri string) ([]mediaSegment, error) {
+	var segments []mediaSegment
+	err := currentRetryPolicy(ctx).Do(ctx, func() error {
+		if err := waitRateLimit(ctx); err != nil {
+			return err
+		}
+		if err := simulateFailure("chunklist fetch"); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, http.NoBody)
+		if err != nil {
+			return err
+		}
+		resp, err := httpClient.Do(req) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if err := checkAuthStatus(resp); err != nil {
+			return err
+		}
+		if err := checkResponseStatus(resp); err != nil {
+			return err
+		}
+
+		if segments, err = getMediaSegments(resp.Body); err != nil {
+			return permanent(err)
+		}
+		return nil
+	})
+	return segments, err
 }
 
 // getRadicronPath gets the RADICRON_HOME path
@@ -569,6 +1112,11 @@ func moveFile(source, dest string) error {
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
 
+	// Preserve the modification time of the source file like os.Rename does
+	if info, statErr := srcFile.Stat(); statErr == nil {
+		_ = os.Chtimes(dest, info.ModTime(), info.ModTime())
+	}
+
 	// Delete source file after successful copy
 	if err := os.Remove(source); err != nil {
 		// Clean up destination file if delete failed (atomic operation)
@@ -579,7 +1127,7 @@ func moveFile(source, dest string) error {
 	return nil
 }
 
-// collectConfiguredFolders collects all unique configured folders from rules and the current configured folder
+// collectConfiguredFolders collects all unique configured folders (including folder-by-tag) from rules and the current configured folder
 func collectConfiguredFolders(configuredFolder string, rules Rules) map[string]bool {
 	configuredFolders := make(map[string]bool)
 	if configuredFolder != "" {
@@ -589,12 +1137,19 @@ func collectConfiguredFolders(configuredFolder string, rules Rules) map[string]b
 		if rule.Folder != "" {
 			configuredFolders[rule.Folder] = true
 		}
+		for _, folder := range rule.FolderByTag {
+			if folder != "" {
+				configuredFolders[folder] = true
+			}
+		}
 	}
 	return configuredFolders
 }
 
-// checkConfiguredFoldersForDuplicate checks if file exists in any configured folder (excluding target)
+// checkConfiguredFoldersForDuplicate checks if file exists in any configured folder (excluding target),
+// in the storage of the asset of ctx if remote
 func checkConfiguredFoldersForDuplicate(
+	ctx context.Context,
 	configuredFolders map[string]bool,
 	downloadDir, fileBaseName, fileFormat, targetPath string,
 ) (exists bool, existingPath string) {
@@ -608,7 +1163,7 @@ func checkConfiguredFoldersForDuplicate(
 		if configuredOutput.AbsPath() == targetPath {
 			continue
 		}
-		if configuredOutput.IsExist() {
+		if outputExists(configuredOutput.AbsPath()) || existsInStorage(ctx, configuredOutput.AbsPath()) {
 			return true, configuredOutput.AbsPath()
 		}
 	}
@@ -623,27 +1178,34 @@ func handleMoveFromDefaultFolder(
 	stationID, title, startTime string,
 ) error {
 	// Check if target already exists (edge case: file appeared between checks or race condition)
-	if output.IsExist() {
+	if output.IsExist() || existsInStorage(ctx, targetPath) {
 		emitDownloadSkipped(ctx, "target already exists, keeping both files", stationID, title, startTime)
 		emitLogMessage(ctx, "info", fmt.Sprintf("target already exists, keeping both files: %s (target: %s)", source, targetPath))
 		// Target exists, keep both files and skip download
 		return nil
 	}
 
-	if err := moveFile(source, targetPath); err != nil {
+	if err := moveOutput(ctx, source, targetPath); err != nil {
 		// Check if error is due to target existing (race condition during move)
-		if _, statErr := os.Stat(targetPath); statErr == nil {
+		if _, statErr := os.Stat(targetPath); statErr == nil || existsInStorage(ctx, targetPath) {
 			log.Printf("warning: target file appeared during move, removing source: %s (target: %s)", source, targetPath)
-			_ = os.Remove(source)
+			_ = removeOutput(ctx, source)
 			return nil
 		}
 		return fmt.Errorf("failed to move file from default to configured folder (%s -> %s): %w", source, targetPath, err)
 	}
 
-	log.Printf("moved file: %s -> %s", source, targetPath)
+	if err := relocateDownloadRecords(source, targetPath); err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to update the history of %s: %v", source, err))
+	}
+	emitFileMoved(ctx, source, targetPath)
+	if remoteStorage(ctx) == nil {
+		unindexOutput(source)
+		indexOutput(targetPath)
+	}
 	// After successful move, file exists at target - skip download
 	// Return sentinel error to signal skip without logging "skip already exists"
-	if output.IsExist() {
+	if output.IsExist() || existsInStorage(ctx, targetPath) {
 		return errSkipAfterMove
 	}
 	return nil
@@ -657,14 +1219,24 @@ func handleDuplicate(
 	rules Rules,
 	stationID, title, startTime string,
 ) error {
-	// Collect all unique configured folders from all rules
+	// Collect all unique configured folders from all rules and stations, or only the rule's own folder
+	asset := GetAsset(ctx)
+	scanAll := asset == nil || asset.DuplicateScan != DuplicateScanRule
+	if !scanAll {
+		rules = nil
+	}
 	configuredFolders := collectConfiguredFolders(configuredFolder, rules)
+	if scanAll && asset != nil {
+		for _, dir := range asset.StationDirs {
+			configuredFolders[dir] = true
+		}
+	}
 
 	// Check in all configured folders (excluding target, which is already checked before calling this)
 	// This takes precedence over default folder
 	targetPath := output.AbsPath()
 	exists, existingPath := checkConfiguredFoldersForDuplicate(
-		configuredFolders, downloadDir, fileBaseName, fileFormat, targetPath)
+		ctx, configuredFolders, downloadDir, fileBaseName, fileFormat, targetPath)
 	if exists {
 		emitDownloadSkipped(ctx, "already exists", stationID, title, startTime)
 		emitLogMessage(ctx, "info", fmt.Sprintf("file already exists in configured folder, skipping [%s]%s: %s", stationID, title, existingPath))
@@ -677,7 +1249,7 @@ func handleDuplicate(
 		return nil
 	}
 	defaultOutput := newOutputConfigFromPath(defaultPath, fileBaseName, fileFormat)
-	if !defaultOutput.IsExist() {
+	if !defaultOutput.IsExist() && !existsInStorage(ctx, defaultOutput.AbsPath()) {
 		return nil
 	}
 
@@ -694,7 +1266,42 @@ func handleDuplicate(
 	return nil
 }
 
+// outputFolder returns the folder under the download dir to save the program to:
+// the folder of its rule, or the folder of its station in StationDirs if the rule has none
+func outputFolder(asset *Asset, prog *Prog) string {
+	if prog.RuleFolder != "" {
+		return prog.RuleFolder
+	}
+	return asset.StationDirs[prog.StationID]
+}
+
 // newOutputConfig prepares the outputdir
+// configureOutput names the output of the program in the folder and creates its dir
+func configureOutput(
+	ctx context.Context, asset *Asset, prog *Prog, folder string, startTime time.Time,
+) (string, *radigo.OutputConfig, error) {
+	fileBaseName, err := asset.outputFileBaseName(prog, asset.TitleAliases.Canonical(prog.Title), startTime)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to configure output: %v", err))
+		return "", nil, fmt.Errorf("failed to configure output: %w", err)
+	}
+	output, err := newOutputConfig(
+		fileBaseName,
+		asset.OutputFormat,
+		asset.outputDir(),
+		folder,
+	)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to configure output: %v", err))
+		return "", nil, fmt.Errorf("failed to configure output: %w", err)
+	}
+	if err = output.SetupDir(); err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to setup output dir: %v", err))
+		return "", nil, fmt.Errorf("failed to setup the output dir: %w", err)
+	}
+	return fileBaseName, output, nil
+}
+
 func newOutputConfig(fileBaseName, fileFormat, downloadDir, folder string) (*radigo.OutputConfig, error) {
 	basePath := downloadDir
 	if folder != "" {
@@ -712,6 +1319,32 @@ func newOutputConfig(fileBaseName, fileFormat, downloadDir, folder string) (*rad
 	}, nil
 }
 
+// prepareAACDir returns the dir to store the aac files for the program.
+// If the program has an ID, the dir and its segment manifest are kept across restarts to resume the download.
+func prepareAACDir(prog *Prog, chunklist []string) (string, *segmentManifest, error) {
+	// the cleanup of the tmp dir must not see the dir before it is marked in use
+	activeAACDirsMu.Lock()
+	defer activeAACDirsMu.Unlock()
+
+	if prog.ID == "" {
+		aacDir, err := tempAACDir()
+		if err == nil {
+			activeAACDirs[aacDir]++
+		}
+		return aacDir, nil, err
+	}
+	aacDir, err := programAACDir(prog.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	manifest, err := loadSegmentManifest(aacDir, prog.ID, chunklist)
+	if err != nil {
+		return "", nil, err
+	}
+	activeAACDirs[aacDir]++
+	return aacDir, manifest, nil
+}
+
 // tempAACDir creates a dir to store temporary aac files
 func tempAACDir() (string, error) {
 	fullPath, err := getRadicronPath("tmp")
@@ -737,67 +1370,133 @@ func timeshiftProgM3U8(
 	ctx context.Context,
 	prog *Prog,
 ) (string, error) {
-	asset := GetAsset(ctx)
-	client := asset.DefaultClient
-	var req *http.Request
-	var err error
+	return progM3U8(ctx, prog, http.MethodPost, buildM3U8RequestURI(prog))
+}
 
-	areaID := asset.GetAreaIDByStationID(prog.StationID)
+// liveStreamM3U8 gets playlist.m3u8 of the live stream of the Prog's station
+func liveStreamM3U8(ctx context.Context, prog *Prog) (string, error) {
+	return progM3U8(ctx, prog, http.MethodGet, fmt.Sprintf(APILiveStreamM3U8, prog.StationID))
+}
 
-	device, ok := asset.AreaDevices[areaID]
-	if !ok {
-		device, err = asset.NewDevice(ctx, areaID)
-		if err != nil {
+// progM3U8 requests the playlist.m3u8 uri with the device authorized for the Prog
+func progM3U8(ctx context.Context, prog *Prog, method, uri string) (string, error) {
+	asset := GetAsset(ctx)
+	device, areaID, err := asset.DeviceForProg(ctx, prog)
+	if err != nil {
+		return "", err
+	}
+
+	token := deviceAuthToken(device)
+	m3u8URI, err := fetchM3U8URI(ctx, asset, method, uri, device, areaID, token)
+	if errors.Is(err, errAuthExpired) {
+		// the token expired mid-session: authorize again and retry once
+		if err = reauthDevice(ctx, asset, device, areaID, token); err != nil {
 			return "", err
 		}
+		m3u8URI, err = fetchM3U8URI(ctx, asset, method, uri, device, areaID, deviceAuthToken(device))
 	}
+	return m3u8URI, err
+}
 
-	uri := buildM3U8RequestURI(prog)
-	req, err = http.NewRequestWithContext(ctx, "POST", uri, http.NoBody)
-	if err != nil {
-		return "", err
-	}
+// fetchM3U8URI requests the playlist.m3u8 uri with the auth token
+func fetchM3U8URI(ctx context.Context, asset *Asset, method, uri string, device *Device, areaID, token string) (string, error) {
 	headers := map[string]string{
 		UserAgentHeader:       device.UserAgent,
 		RadikoAreaIDHeader:    areaID,
-		RadikoAuthTokenHeader: device.AuthToken,
-	}
-	for k, v := range headers {
-		req.Header.Set(k, v)
+		RadikoAuthTokenHeader: token,
 	}
-	resp, err := client.Do(req)
+	var m3u8URI string
+	err := currentRetryPolicy(ctx).Do(ctx, func() error {
+		if err := waitRateLimit(ctx); err != nil {
+			return err
+		}
+		if err := simulateFailure("playlist fetch"); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, method, uri, http.NoBody)
+		if err != nil {
+			return err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := asset.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if err := checkAuthStatus(resp); err != nil {
+			return err
+		}
+		if err := checkResponseStatus(resp); err != nil {
+			return err
+		}
+
+		if m3u8URI, err = getURI(resp.Body); err != nil {
+			return permanent(err)
+		}
+		return nil
+	})
+	return m3u8URI, err
+}
+
+// setBroadcastTime sets the access and modification times of the output file to the program start time
+func setBroadcastTime(output *radigo.OutputConfig, prog *Prog) error {
+	startTime, err := ParseDatetime(prog.Ft)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("invalid start time format '%s': %w", prog.Ft, err)
 	}
-	defer resp.Body.Close()
-
-	return getURI(resp.Body)
+	return os.Chtimes(output.AbsPath(), startTime, startTime)
 }
 
-func writeID3Tag(output *radigo.OutputConfig, prog *Prog) error {
+func writeID3Tag(asset *Asset, output *radigo.OutputConfig, prog *Prog) error {
 	tag, err := id3v2.Open(output.AbsPath(), id3v2.Options{Parse: true})
 	if err != nil {
 		return fmt.Errorf("error while opening the output file: %w", err)
 	}
 	defer tag.Close()
 
-	// Set tags
-	tag.SetTitle(output.FileBaseName)
-	tag.SetArtist(prog.Pfm)
-	tag.SetAlbum(prog.Title)
-	tag.SetYear(prog.Ft[:4])
+	// Set tags, the defaults or the configured tag templates
+	values, err := asset.programTags(output, prog)
+	if err != nil {
+		return err
+	}
+	tag.SetTitle(values[TagTitle])
+	tag.SetArtist(values[TagArtist])
+	tag.SetAlbum(values[TagAlbum])
+	tag.SetYear(values[TagYear])
+	if values[TagGenre] != "" {
+		tag.SetGenre(values[TagGenre])
+	}
+	if values[TagTrack] != "" {
+		tag.AddTextFrame(tag.CommonID("Track number/Position in set"), id3v2.EncodingUTF8, values[TagTrack])
+	}
 
 	// Add comment with program info
 	tag.AddCommentFrame(id3v2.CommentFrame{
 		Encoding:    id3v2.EncodingUTF8,
 		Language:    ID3v2LangJPN,
-		Description: prog.Info,
+		Description: values[TagComment],
 	})
 
 	// Set rule name as Band/Orchestra/Accompaniment (TPE2) if available
 	// Note: Many music players display TPE2 as "Album Artist"
-	if prog.RuleName != "" {
-		tag.AddTextFrame(tag.CommonID("Band/Orchestra/Accompaniment"), id3v2.EncodingUTF8, prog.RuleName)
+	if values[TagAlbumArtist] != "" {
+		tag.AddTextFrame(tag.CommonID("Band/Orchestra/Accompaniment"), id3v2.EncodingUTF8, values[TagAlbumArtist])
+	}
+
+	// Set the program ID in a TXXX frame to recognize the file even if renamed
+	if prog.ID != "" {
+		tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{
+			Encoding:    id3v2.EncodingUTF8,
+			Description: ID3v2ProgramID,
+			Value:       prog.ID,
+		})
+	}
+
+	// Split the output into chapters at the ad breaks
+	if len(prog.Chapters) > 1 {
+		addChapterFrames(tag, prog.Chapters)
 	}
 
 	// write tag to the aac
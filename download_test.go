@@ -343,7 +343,8 @@ func TestHandleDuplicate_MoveToConfiguredFolder(t *testing.T) {
 	if err != nil {
 		t.Fatalf("newOutputConfig failed: %v", err)
 	}
-	ctx := context.Background()
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
 	err = handleDuplicate(
 		ctx, "move-test", radigo.AudioFormatAAC, "downloads", "citypop",
 		output, Rules{}, "TEST", "Test Program", "20230605100000")
@@ -359,6 +360,9 @@ func TestHandleDuplicate_MoveToConfiguredFolder(t *testing.T) {
 	if _, err := os.Stat(moveFile); err == nil {
 		t.Error("File should no longer exist in default folder")
 	}
+	if len(emitter.fileMoved) != 1 || emitter.fileMoved[0].oldPath != moveFile || emitter.fileMoved[0].newPath != expectedPath {
+		t.Errorf("expected a file moved event, got %v", emitter.fileMoved)
+	}
 }
 
 func TestHandleDuplicate_ExistingInConfiguredFolder(t *testing.T) {
@@ -657,7 +661,7 @@ func testWriteID3Tag(t *testing.T, tmpDir, fileFormat, fileBaseName string) {
 	}
 
 	// Write ID3 tags
-	err = writeID3Tag(output, prog)
+	err = writeID3Tag(nil, output, prog)
 	if err != nil {
 		t.Fatalf("writeID3Tag failed: %v", err)
 	}
@@ -760,7 +764,7 @@ func TestWriteID3TagWithoutRuleName(t *testing.T) {
 	}
 
 	// Write ID3 tags
-	err = writeID3Tag(output, prog)
+	err = writeID3Tag(nil, output, prog)
 	if err != nil {
 		t.Fatalf("writeID3Tag failed: %v", err)
 	}
@@ -787,6 +791,33 @@ func TestWriteID3TagWithoutRuleName(t *testing.T) {
 	}
 }
 
+func TestSetBroadcastTime(t *testing.T) {
+	tmpDir := t.TempDir()
+	output := newOutputConfigFromPath(tmpDir, "test-mtime", radigo.AudioFormatAAC)
+	if err := os.WriteFile(output.AbsPath(), []byte("test content"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	prog := &Prog{Ft: "20230605130000"}
+	if err := setBroadcastTime(output, prog); err != nil {
+		t.Fatalf("setBroadcastTime failed: %v", err)
+	}
+
+	info, err := os.Stat(output.AbsPath())
+	if err != nil {
+		t.Fatalf("Failed to stat test file: %v", err)
+	}
+	want, _ := time.ParseInLocation(DatetimeLayout, prog.Ft, Location)
+	if !info.ModTime().Equal(want) {
+		t.Errorf("ModTime => %v, want %v", info.ModTime(), want)
+	}
+
+	// invalid start time
+	if err := setBroadcastTime(output, &Prog{Ft: "invalid"}); err == nil {
+		t.Error("setBroadcastTime should fail with an invalid start time")
+	}
+}
+
 func TestMoveFile(t *testing.T) {
 	// Create temporary directory for testing
 	tmpDir := t.TempDir()
@@ -1010,7 +1041,7 @@ func TestWriteID3Tag_ErrorCases(t *testing.T) {
 		Ft:    "20230605130000",
 	}
 
-	err := writeID3Tag(output, prog)
+	err := writeID3Tag(nil, output, prog)
 	if err == nil {
 		t.Error("writeID3Tag should return error when file doesn't exist")
 	}
@@ -1027,7 +1058,7 @@ func TestWriteID3Tag_ErrorCases(t *testing.T) {
 		}
 
 		output2 := newOutputConfigFromPath(tmpDir, "dir", radigo.AudioFormatAAC)
-		err = writeID3Tag(output2, prog)
+		err = writeID3Tag(nil, output2, prog)
 		if err == nil {
 			t.Error("writeID3Tag should return error when path is a directory")
 		}
@@ -1072,13 +1103,13 @@ func TestGetChunklistFromM3U8(t *testing.T) {
 	// For now, we'll test error cases that don't require a real server
 
 	// Test with invalid URL (should fail)
-	_, err := getChunklistFromM3U8("http://invalid-url-that-does-not-exist-12345.com/test.m3u8")
+	_, err := getChunklistFromM3U8(context.Background(), "http://invalid-url-that-does-not-exist-12345.com/test.m3u8")
 	if err == nil {
 		t.Log("getChunklistFromM3U8 may succeed with network retries, but should eventually fail")
 	}
 
 	// Test with empty URL (should fail)
-	_, err = getChunklistFromM3U8("")
+	_, err = getChunklistFromM3U8(context.Background(), "")
 	if err == nil {
 		t.Error("getChunklistFromM3U8 should return error for empty URL")
 	}
@@ -1330,6 +1361,40 @@ func TestDownload_FutureProgram(t *testing.T) {
 	}
 }
 
+func TestDownload_InProgressProgram(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 5, 13, 30, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+
+	emitter := &mockEventEmitter{}
+	asset := &Asset{
+		OutputFormat: radigo.AudioFormatAAC,
+		DownloadDir:  "downloads",
+		Rules:        Rules{},
+		Schedules:    Schedules{},
+	}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	// on air from 1 PM to 2 PM
+	prog := &Prog{ID: "FMT-1", StationID: "FMT", Title: "Test Program", Ft: "20230605130000", To: "20230605140000"}
+	if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+		t.Fatalf("Download should not return error for an in-progress program: %v", err)
+	}
+
+	want := time.Date(2023, 6, 5, 14, BufferMinutes, 0, 0, Location)
+	if asset.NextFetchTime == nil || !asset.NextFetchTime.Equal(want) {
+		t.Errorf("expected the next fetch at %v, got %v", want, asset.NextFetchTime)
+	}
+	if len(emitter.downloadStarted) != 0 {
+		t.Error("expected the in-progress program not to be downloaded")
+	}
+	if queued, err := LoadQueue(); err != nil || len(queued) != 0 {
+		t.Errorf("expected the in-progress program not to be queued, got %v, %v", queued, err)
+	}
+}
+
 func TestDownload_DuplicateProgram(t *testing.T) {
 	// Save original env value
 	originalEnv := os.Getenv(EnvRadicronHome)
@@ -1395,6 +1460,113 @@ func TestDownload_DuplicateProgram(t *testing.T) {
 	}
 }
 
+func TestDownload_ReadOnly(t *testing.T) {
+	testDir := t.TempDir()
+	t.Setenv(EnvRadicronHome, testDir)
+
+	CurrentTime = time.Date(2023, 6, 5, 16, 0, 0, 0, Location)
+
+	asset := &Asset{
+		OutputFormat:      radigo.AudioFormatAAC,
+		DownloadDir:       "downloads",
+		MinimumOutputSize: 1024,
+		Rules:             Rules{},
+		Schedules:         Schedules{},
+		ReadOnly:          true,
+	}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	wg := &sync.WaitGroup{}
+	prog := &Prog{
+		ID:        "test-read-only",
+		StationID: "FMT",
+		Title:     "Test Program",
+		Ft:        "20230605130000",
+		To:        "20230605140000",
+		RuleName:  "test-rule",
+	}
+
+	if err := Download(ctx, wg, prog); err != nil {
+		t.Fatalf("Download should not fail in read-only mode: %v", err)
+	}
+	wg.Wait()
+
+	if len(emitter.programMatched) != 1 {
+		t.Fatalf("Expected 1 program matched event, got %d", len(emitter.programMatched))
+	}
+	if emitter.programMatched[0].ruleName != "test-rule" {
+		t.Errorf("Expected rule test-rule, got %s", emitter.programMatched[0].ruleName)
+	}
+	if len(emitter.downloadStarted) != 0 {
+		t.Errorf("Expected no download started event in read-only mode, got %d", len(emitter.downloadStarted))
+	}
+	// Nothing should be written to RADICRON_HOME
+	if _, err := os.Stat(filepath.Join(testDir, "downloads")); !os.IsNotExist(err) {
+		t.Error("Download should not create the download directory in read-only mode")
+	}
+}
+
+func TestDownload_Coordinated(t *testing.T) {
+	testDir := t.TempDir()
+	t.Setenv(EnvRadicronHome, testDir)
+
+	CurrentTime = time.Date(2023, 6, 5, 16, 0, 0, 0, Location)
+
+	asset := &Asset{
+		OutputFormat:      radigo.AudioFormatAAC,
+		DownloadDir:       "downloads",
+		MinimumOutputSize: 1024,
+		Rules:             Rules{},
+		Schedules:         Schedules{},
+		CoordinationDir:   filepath.Join(testDir, "coordination"),
+		InstanceID:        "follower",
+		CoordinationLease: time.Minute,
+	}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	prog := &Prog{
+		ID:        "test-coordinated",
+		StationID: "FMT",
+		Title:     "Test Program",
+		Ft:        "20230605130000",
+		To:        "20230605140000",
+	}
+
+	// the leader is downloading the program
+	lock, err := acquireProgramLock(asset.CoordinationDir, "leader", asset.CoordinationLease, prog)
+	if err != nil {
+		t.Fatalf("acquireProgramLock failed: %v", err)
+	}
+	wg := &sync.WaitGroup{}
+	if err := Download(ctx, wg, prog); err != nil {
+		t.Fatalf("Download should not fail while another instance downloads: %v", err)
+	}
+	// the leader has downloaded the program
+	if err := lock.release(true); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := Download(ctx, wg, prog); err != nil {
+		t.Fatalf("Download should not fail after another instance downloaded: %v", err)
+	}
+	wg.Wait()
+
+	if len(emitter.downloadSkipped) != 2 {
+		t.Fatalf("Expected 2 download skipped events, got %d", len(emitter.downloadSkipped))
+	}
+	for i, want := range []string{"downloading by another instance", "downloaded by another instance"} {
+		if emitter.downloadSkipped[i].reason != want {
+			t.Errorf("Expected skip reason %q, got %q", want, emitter.downloadSkipped[i].reason)
+		}
+	}
+	if len(emitter.downloadStarted) != 0 {
+		t.Errorf("Expected no download started event, got %d", len(emitter.downloadStarted))
+	}
+}
+
 func TestDownload_InvalidEndTime(t *testing.T) {
 	// Save original env value
 	originalEnv := os.Getenv(EnvRadicronHome)
@@ -1474,7 +1646,7 @@ func TestDownloadLink(t *testing.T) {
 
 	// Test successful download
 	testURL := server.URL + "/test.aac"
-	err := downloadLink(testURL, tmpDir)
+	err := downloadLink(context.Background(), testURL, tmpDir)
 	if err != nil {
 		t.Errorf("downloadLink failed: %v", err)
 	}
@@ -1496,22 +1668,29 @@ func TestDownloadLink(t *testing.T) {
 }
 
 func TestDownloadLink_ServerError(t *testing.T) {
-	// Create a test HTTP server that returns error
+	status := http.StatusInternalServerError
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("error page"))
 	}))
 	defer server.Close()
 
 	tmpDir := t.TempDir()
 	testURL := server.URL + "/test.aac"
 
-	// downloadLink doesn't check status code, so it will still create the file
-	// but the content will be empty or error response
-	err := downloadLink(testURL, tmpDir)
-	// The function may or may not return an error depending on implementation
-	// It writes the response body regardless of status code
-	if err != nil {
-		t.Logf("downloadLink returned error (may be expected): %v", err)
+	// a 5xx is retried
+	err := downloadLink(context.Background(), testURL, tmpDir)
+	if err == nil || isPermanent(err) {
+		t.Errorf("expected a retryable error for 500, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "test.aac")); !os.IsNotExist(err) {
+		t.Error("the error page should not be saved as the segment")
+	}
+
+	// a 4xx is not
+	status = http.StatusNotFound
+	if err := downloadLink(context.Background(), testURL, tmpDir); !isPermanent(err) {
+		t.Errorf("expected a permanent error for 404, got %v", err)
 	}
 }
 
@@ -1519,7 +1698,7 @@ func TestDownloadLink_InvalidURL(t *testing.T) {
 	tmpDir := t.TempDir()
 	invalidURL := "http://invalid-url-that-does-not-exist-12345.com/test.aac"
 
-	err := downloadLink(invalidURL, tmpDir)
+	err := downloadLink(context.Background(), invalidURL, tmpDir)
 	if err == nil {
 		t.Error("downloadLink should return error for invalid URL")
 	}
@@ -1537,12 +1716,53 @@ func TestDownloadLink_FileCreationError(t *testing.T) {
 	invalidDir := filepath.Join(os.TempDir(), "nonexistent", "subdir", "path")
 	testURL := server.URL + "/test.aac"
 
-	err := downloadLink(testURL, invalidDir)
+	err := downloadLink(context.Background(), testURL, invalidDir)
 	if err == nil {
 		t.Error("downloadLink should return error when file creation fails")
 	}
 }
 
+func TestDownloadLink_Truncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// promise more than sent, as a dropped connection does
+		w.Header().Set("Content-Length", "100")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("short"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	if err := downloadLink(context.Background(), server.URL+"/test.aac", tmpDir); err == nil {
+		t.Error("downloadLink should return error for a truncated segment")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "test.aac")); !os.IsNotExist(err) {
+		t.Error("truncated segment should be deleted")
+	}
+}
+
+func TestDownloadLink_MinimumSegmentSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("test audio content"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{MinimumSegmentSize: 1024})
+	err := downloadLink(ctx, server.URL+"/test.aac", tmpDir)
+	if !errors.Is(err, errTruncatedSegment) {
+		t.Errorf("expected errTruncatedSegment, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "test.aac")); !os.IsNotExist(err) {
+		t.Error("segment under the minimum size should be deleted")
+	}
+
+	ctx = context.WithValue(context.Background(), ContextKey("asset"), &Asset{MinimumSegmentSize: 10})
+	if err := downloadLink(ctx, server.URL+"/test.aac", tmpDir); err != nil {
+		t.Errorf("downloadLink failed: %v", err)
+	}
+}
+
 func TestBulkDownload_Success(t *testing.T) {
 	// Initialize semaphores
 	InitSemaphores(&Asset{
@@ -1567,7 +1787,7 @@ func TestBulkDownload_Success(t *testing.T) {
 		server.URL + "/chunk3.aac",
 	}
 
-	err := bulkDownload(urls, tmpDir)
+	err := bulkDownload(context.Background(), urls, tmpDir, nil, nil)
 	if err != nil {
 		t.Errorf("bulkDownload failed: %v", err)
 	}
@@ -1617,7 +1837,7 @@ func TestBulkDownload_WithErrors(t *testing.T) {
 		server.URL + "/chunk3.aac",
 	}
 
-	err := bulkDownload(urls, tmpDir)
+	err := bulkDownload(context.Background(), urls, tmpDir, nil, nil)
 	// bulkDownload retries, so it may succeed or fail depending on retry logic
 	// The function returns error only if all retries fail
 	if err != nil {
@@ -1640,7 +1860,7 @@ func TestBulkDownload_AllFail(t *testing.T) {
 		"http://invalid-url-2.com/chunk2.aac",
 	}
 
-	err := bulkDownload(urls, tmpDir)
+	err := bulkDownload(context.Background(), urls, tmpDir, nil, nil)
 	if err == nil {
 		t.Error("bulkDownload should return error when all downloads fail")
 	}
@@ -1661,7 +1881,7 @@ func TestBulkDownload_EmptyList(t *testing.T) {
 	// Test with empty list
 	urls := []string{}
 
-	err := bulkDownload(urls, tmpDir)
+	err := bulkDownload(context.Background(), urls, tmpDir, nil, nil)
 	if err != nil {
 		t.Errorf("bulkDownload should not return error for empty list: %v", err)
 	}
@@ -1711,7 +1931,7 @@ chunk2.aac
 	}))
 	defer server.Close()
 
-	chunklist, err := getChunklistFromM3U8(server.URL)
+	chunklist, err := getChunklistFromM3U8(context.Background(), server.URL)
 	if err != nil {
 		t.Errorf("getChunklistFromM3U8 failed: %v", err)
 	}
@@ -1770,7 +1990,7 @@ func TestDownloadProgram_ChunklistError(t *testing.T) {
 
 	// downloadProgram runs in a goroutine, so we need to wait for it
 	wg.Add(1)
-	downloadProgram(ctx, wg, prog, output)
+	downloadProgram(ctx, wg, prog, output, nil)
 	wg.Wait()
 
 	// Verify output file was not created (download should have failed)
@@ -1825,7 +2045,7 @@ http://invalid-url-2.com/chunk2.aac
 
 	// downloadProgram runs in a goroutine, so we need to wait for it
 	wg.Add(1)
-	downloadProgram(ctx, wg, prog, output)
+	downloadProgram(ctx, wg, prog, output, nil)
 	wg.Wait()
 
 	// Verify output file was not created (download should have failed)
@@ -1836,21 +2056,57 @@ http://invalid-url-2.com/chunk2.aac
 
 // mockEventEmitter is a test implementation of EventEmitter
 type mockEventEmitter struct {
-	downloadStarted   []struct{ stationID, title, startTime, uri string }
-	downloadCompleted []struct{ stationID, title, filePath string }
-	fileSaved         []struct{ stationID, title, filePath string }
-	downloadSkipped   []struct{ reason, stationID, title, startTime string }
+	downloadStarted []struct {
+		stationID, title, startTime, uri string
+		prog                             *Prog
+	}
+	downloadCompleted []struct {
+		stationID, title, filePath string
+		prog                       *Prog
+	}
+	fileSaved        []struct{ stationID, title, filePath string }
+	downloadSkipped  []struct{ reason, stationID, title, startTime string }
+	downloadProgress []struct {
+		done, total int
+		bytes       int64
+	}
 	encodingStarted   []string
 	encodingCompleted []string
-	logMessages       []struct{ level, message string }
+	programMatched    []struct{ stationID, title, startTime, ruleName string }
+	programUpcoming   []struct{ stationID, title, startTime, ruleName, shareURL, downloadTime string }
+	programExtended   []struct {
+		stationID, title, startTime string
+		usualMinutes, minutes       int
+	}
+	programFailed []struct {
+		stationID, title, startTime string
+		attempts                    int
+	}
+	downloadStalled []struct {
+		stationID, title, startTime string
+		timeout                     time.Duration
+	}
+	fileMoved         []struct{ oldPath, newPath string }
+	scheduleConflicts []struct {
+		dropped []*Prog
+		limit   int
+	}
+	configSummaries []ConfigSummary
+	logMessages     []struct{ level, message string }
 }
 
-func (m *mockEventEmitter) EmitDownloadStarted(stationID, title, startTime, uri string) {
-	m.downloadStarted = append(m.downloadStarted, struct{ stationID, title, startTime, uri string }{stationID, title, startTime, uri})
+func (m *mockEventEmitter) EmitDownloadStarted(prog *Prog, uri string) {
+	m.downloadStarted = append(m.downloadStarted, struct {
+		stationID, title, startTime, uri string
+		prog                             *Prog
+	}{prog.StationID, prog.Title, prog.Ft, uri, prog})
 }
 
-func (m *mockEventEmitter) EmitDownloadCompleted(stationID, title, filePath string) {
-	m.downloadCompleted = append(m.downloadCompleted, struct{ stationID, title, filePath string }{stationID, title, filePath})
+func (m *mockEventEmitter) EmitDownloadCompleted(prog *Prog, filePath string) {
+	m.downloadCompleted = append(m.downloadCompleted, struct {
+		stationID, title, filePath string
+		prog                       *Prog
+	}{prog.StationID, prog.Title, filePath, prog})
 }
 
 func (m *mockEventEmitter) EmitFileSaved(stationID, title, filePath string) {
@@ -1861,6 +2117,13 @@ func (m *mockEventEmitter) EmitDownloadSkipped(reason, stationID, title, startTi
 	m.downloadSkipped = append(m.downloadSkipped, struct{ reason, stationID, title, startTime string }{reason, stationID, title, startTime})
 }
 
+func (m *mockEventEmitter) EmitDownloadProgress(stationID, title string, done, total int, bytes int64) {
+	m.downloadProgress = append(m.downloadProgress, struct {
+		done, total int
+		bytes       int64
+	}{done, total, bytes})
+}
+
 func (m *mockEventEmitter) EmitEncodingStarted(filePath string) {
 	m.encodingStarted = append(m.encodingStarted, filePath)
 }
@@ -1869,6 +2132,52 @@ func (m *mockEventEmitter) EmitEncodingCompleted(filePath string) {
 	m.encodingCompleted = append(m.encodingCompleted, filePath)
 }
 
+func (m *mockEventEmitter) EmitProgramMatched(stationID, title, startTime, ruleName string) {
+	m.programMatched = append(m.programMatched, struct{ stationID, title, startTime, ruleName string }{stationID, title, startTime, ruleName})
+}
+
+func (m *mockEventEmitter) EmitProgramUpcoming(stationID, title, startTime, ruleName, shareURL, downloadTime string) {
+	m.programUpcoming = append(m.programUpcoming, struct {
+		stationID, title, startTime, ruleName, shareURL, downloadTime string
+	}{stationID, title, startTime, ruleName, shareURL, downloadTime})
+}
+
+func (m *mockEventEmitter) EmitProgramExtended(stationID, title, startTime string, usualMinutes, minutes int) {
+	m.programExtended = append(m.programExtended, struct {
+		stationID, title, startTime string
+		usualMinutes, minutes       int
+	}{stationID, title, startTime, usualMinutes, minutes})
+}
+
+func (m *mockEventEmitter) EmitProgramFailed(stationID, title, startTime string, attempts int) {
+	m.programFailed = append(m.programFailed, struct {
+		stationID, title, startTime string
+		attempts                    int
+	}{stationID, title, startTime, attempts})
+}
+
+func (m *mockEventEmitter) EmitDownloadStalled(stationID, title, startTime string, timeout time.Duration) {
+	m.downloadStalled = append(m.downloadStalled, struct {
+		stationID, title, startTime string
+		timeout                     time.Duration
+	}{stationID, title, startTime, timeout})
+}
+
+func (m *mockEventEmitter) EmitFileMoved(oldPath, newPath string) {
+	m.fileMoved = append(m.fileMoved, struct{ oldPath, newPath string }{oldPath, newPath})
+}
+
+func (m *mockEventEmitter) EmitScheduleConflict(dropped []*Prog, limit int) {
+	m.scheduleConflicts = append(m.scheduleConflicts, struct {
+		dropped []*Prog
+		limit   int
+	}{dropped, limit})
+}
+
+func (m *mockEventEmitter) EmitConfigSummary(summary ConfigSummary) {
+	m.configSummaries = append(m.configSummaries, summary)
+}
+
 func (m *mockEventEmitter) EmitLogMessage(level, message string) {
 	m.logMessages = append(m.logMessages, struct{ level, message string }{level, message})
 }
@@ -1877,28 +2186,33 @@ func TestEmitDownloadStarted_WithEmitter(t *testing.T) {
 	emitter := &mockEventEmitter{}
 	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
 
-	emitDownloadStarted(ctx, "FMT", "Test Program", "20230605100000", "http://test.com/playlist.m3u8")
+	prog := &Prog{ID: "FMT_20230605100000", StationID: "FMT", Title: "Test Program", Ft: "20230605100000",
+		To: "20230605110000", RuleName: "test-rule", RuleFolder: "citypop"}
+	emitDownloadStarted(ctx, prog, "http://test.com/playlist.m3u8")
 
 	if len(emitter.downloadStarted) != 1 {
-		t.Errorf("Expected 1 download started event, got %d", len(emitter.downloadStarted))
+		t.Fatalf("Expected 1 download started event, got %d", len(emitter.downloadStarted))
 	}
 	if emitter.downloadStarted[0].stationID != "FMT" {
 		t.Errorf("Expected stationID FMT, got %s", emitter.downloadStarted[0].stationID)
 	}
+	if emitter.downloadStarted[0].prog != prog {
+		t.Errorf("Expected the program in the event, got %+v", emitter.downloadStarted[0].prog)
+	}
 }
 
 func TestEmitDownloadStarted_WithoutEmitter(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
 	// Should not panic, just log
-	emitDownloadStarted(ctx, "FMT", "Test Program", "20230605100000", "http://test.com/playlist.m3u8")
+	emitDownloadStarted(ctx, &Prog{StationID: "FMT", Title: "Test Program", Ft: "20230605100000"}, "http://test.com/playlist.m3u8")
 }
 
 func TestEmitDownloadCompleted_WithEmitter(t *testing.T) {
 	emitter := &mockEventEmitter{}
 	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
 
-	emitDownloadCompleted(ctx, "FMT", "Test Program", "/path/to/file.aac")
+	emitDownloadCompleted(ctx, &Prog{StationID: "FMT", Title: "Test Program"}, "/path/to/file.aac")
 
 	if len(emitter.downloadCompleted) != 1 {
 		t.Errorf("Expected 1 download completed event, got %d", len(emitter.downloadCompleted))
@@ -1908,7 +2222,7 @@ func TestEmitDownloadCompleted_WithEmitter(t *testing.T) {
 func TestEmitDownloadCompleted_WithoutEmitter(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
-	emitDownloadCompleted(ctx, "FMT", "Test Program", "/path/to/file.aac")
+	emitDownloadCompleted(ctx, &Prog{StationID: "FMT", Title: "Test Program"}, "/path/to/file.aac")
 }
 
 func TestEmitFileSaved_WithEmitter(t *testing.T) {
@@ -1999,6 +2313,23 @@ func TestEmitLogMessage_WithEmitter(t *testing.T) {
 	}
 }
 
+func TestEmitProgramMatched_WithEmitter(t *testing.T) {
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
+
+	emitProgramMatched(ctx, "FMT", "Test Program", "20230605100000", "test-rule")
+
+	if len(emitter.programMatched) != 1 {
+		t.Errorf("Expected 1 program matched event, got %d", len(emitter.programMatched))
+	}
+}
+
+func TestEmitProgramMatched_WithoutEmitter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	emitProgramMatched(ctx, "FMT", "Test Program", "20230605100000", "test-rule")
+}
+
 func TestEmitLogMessage_WithoutEmitter(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -2171,8 +2502,12 @@ func TestConvertAACtoMP3_FFmpegNotFound(t *testing.T) {
 	originalPath := os.Getenv("PATH")
 	defer os.Setenv("PATH", originalPath)
 
-	// Set PATH to empty to simulate ffmpeg not found
+	// Set PATH to empty and skip the common locations to simulate ffmpeg not found
 	os.Setenv("PATH", "")
+	searchPaths := FFmpegSearchPaths
+	defer func() { FFmpegSearchPaths = searchPaths }()
+	FFmpegSearchPaths = nil
+	t.Setenv(EnvRadicronHome, t.TempDir())
 
 	err = convertAACtoMP3(ctx, sourceFile, destFile)
 	if err == nil {
@@ -2211,3 +2546,174 @@ func TestConvertAACtoMP3_ConversionError(t *testing.T) {
 		t.Logf("convertAACtoMP3 returned error (expected): %v", err)
 	}
 }
+
+func TestBulkDownload_Canceled(t *testing.T) {
+	InitSemaphores(&Asset{
+		MaxDownloadingConcurrency: 10,
+		MaxEncodingConcurrency:    2,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "chunk1.aac") {
+			_, _ = w.Write([]byte("done"))
+			return
+		}
+		// block the other segments until the download is canceled
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	aacDir := filepath.Join(t.TempDir(), "aac-test")
+	if err := os.MkdirAll(aacDir, DirPermissions); err != nil {
+		t.Fatalf("Failed to create the aac dir: %v", err)
+	}
+	urls := []string{server.URL + "/chunk1.aac", server.URL + "/chunk2.aac"}
+	m, err := loadSegmentManifest(aacDir, "test-id", urls)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+
+	appender, err := newSegmentAppender(aacDir, urls, m)
+	if err != nil {
+		t.Fatalf("newSegmentAppender failed: %v", err)
+	}
+
+	// download chunk1 first so that it is completed before the cancellation
+	if err := bulkDownload(context.Background(), urls[:1], aacDir, appender, nil); err != nil {
+		t.Fatalf("bulkDownload failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- bulkDownload(ctx, urls, aacDir, appender, nil) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("bulkDownload => %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bulkDownload should abort quickly on cancellation")
+	}
+
+	if !m.isCompleted(urls[0]) || m.isCompleted(urls[1]) {
+		t.Error("only chunk1 should be completed to resume later")
+	}
+}
+
+func TestFinalizeOutput_PartFile(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	dir := t.TempDir()
+	prog := &Prog{ID: "p1", StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"}
+	output := newOutputConfigFromPath(dir, "2023-06-05-1300_FMT_Test", radigo.AudioFormatAAC)
+	part := partOutputConfig(output)
+	if part.AbsPath() != output.AbsPath()+PartFileExt {
+		t.Fatalf("unexpected part file: %s", part.AbsPath())
+	}
+
+	concated := filepath.Join(dir, "concated.aac")
+	if err := os.WriteFile(concated, make([]byte, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{})
+	if !finalizeOutput(ctx, prog, concated, output) {
+		t.Fatal("expected the output saved")
+	}
+	if _, err := os.Stat(output.AbsPath()); err != nil {
+		t.Errorf("expected the output renamed from the part file: %v", err)
+	}
+	if _, err := os.Stat(part.AbsPath()); !os.IsNotExist(err) {
+		t.Error("expected no part file left")
+	}
+	if id, err := readProgramID(output.AbsPath()); err != nil || id != "p1" {
+		t.Errorf("expected the output tagged before the rename, got %q, %v", id, err)
+	}
+
+	// a too small output never appears under its name
+	small := newOutputConfigFromPath(dir, "2023-06-05-1400_FMT_Small", radigo.AudioFormatAAC)
+	if err := os.WriteFile(concated, make([]byte, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx = context.WithValue(context.Background(), ContextKey("asset"), &Asset{MinimumOutputSize: 1 << 20})
+	if finalizeOutput(ctx, prog, concated, small) {
+		t.Fatal("expected the too small output rejected")
+	}
+	for _, path := range []string{small.AbsPath(), partOutputConfig(small).AbsPath()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to exist", path)
+		}
+	}
+}
+
+func TestConvertAACtoMP3_FFmpegOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.aac")
+	destFile := filepath.Join(tmpDir, "dest.mp3")
+	argsFile := filepath.Join(tmpDir, "args")
+
+	// a fake ffmpeg recording its arguments
+	ffmpeg := filepath.Join(tmpDir, "ffmpeg-static")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	asset := &Asset{FFmpegPath: ffmpeg, FFmpegArgs: []string{"-acodec", "libmp3lame", "-threads", "2"}}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	if err := convertAACtoMP3(ctx, sourceFile, destFile); err != nil {
+		t.Fatalf("convertAACtoMP3 failed: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-i " + sourceFile + " -acodec libmp3lame -threads 2 -f mp3 -map_metadata 0 -y -loglevel error " + destFile
+	if got := strings.TrimSpace(string(args)); got != want {
+		t.Errorf("expected args %q, got %q", want, got)
+	}
+
+	// the bitrate or the quality follows the encoder arguments
+	asset.FFmpegArgs = nil
+	asset.MP3Bitrate = "192k"
+	if err := convertAACtoMP3(ctx, sourceFile, destFile); err != nil {
+		t.Fatalf("convertAACtoMP3 failed: %v", err)
+	}
+	if args, _ := os.ReadFile(argsFile); !strings.Contains(string(args), "-ar 44100 -b:a 192k -f mp3") {
+		t.Errorf("expected the bitrate, got %q", args)
+	}
+	quality := 4
+	asset.MP3Bitrate = ""
+	asset.MP3Quality = &quality
+	if err := convertAACtoMP3(ctx, sourceFile, destFile); err != nil {
+		t.Fatalf("convertAACtoMP3 failed: %v", err)
+	}
+	if args, _ := os.ReadFile(argsFile); !strings.Contains(string(args), "-ar 44100 -q:a 4 -f mp3") {
+		t.Errorf("expected the VBR quality, got %q", args)
+	}
+
+	asset.FFmpegPath = filepath.Join(tmpDir, "missing")
+	if err := convertAACtoMP3(ctx, sourceFile, destFile); err == nil || !strings.Contains(err.Error(), "ffmpeg not found at") {
+		t.Errorf("expected an error for the missing ffmpeg, got %v", err)
+	}
+}
+
+func TestOutputFolder(t *testing.T) {
+	asset := &Asset{StationDirs: map[string]string{"TBS": "tbs-archive"}}
+	tests := []struct {
+		name string
+		prog *Prog
+		want string
+	}{
+		{"rule folder wins", &Prog{StationID: "TBS", RuleFolder: "news"}, "news"},
+		{"station dir without a rule folder", &Prog{StationID: "TBS"}, "tbs-archive"},
+		{"neither", &Prog{StationID: "FMT"}, ""},
+	}
+	for _, tt := range tests {
+		if got := outputFolder(asset, tt.prog); got != tt.want {
+			t.Errorf("%s: outputFolder() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := outputFolder(&Asset{}, &Prog{StationID: "TBS"}); got != "" {
+		t.Errorf("outputFolder() without station dirs = %q, want the downloads dir", got)
+	}
+}
@@ -0,0 +1,74 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// errFFprobeNotFound is returned when ffprobe is not installed
+var errFFprobeNotFound = errors.New("ffprobe not found in PATH")
+
+// probeDuration returns the audio duration of the file with ffprobe
+var probeDuration = func(ctx context.Context, path string) (time.Duration, error) {
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return 0, errFFprobeNotFound
+	}
+
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w (stderr: %s)", err, stderr.String())
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(stdout.String()), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration from ffprobe '%s': %w", strings.TrimSpace(stdout.String()), err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// validateOutputDuration compares the audio duration of the output file against the program length
+// and removes it if it's shorter by more than DurationTolerance, scheduling a retry like a too small file.
+// The check is skipped if ffprobe is not available. Returns true if a retry was scheduled.
+func validateOutputDuration(ctx context.Context, prog *Prog, output *radigo.OutputConfig) bool {
+	asset := GetAsset(ctx)
+	if asset == nil || asset.DurationTolerance <= 0 {
+		return false
+	}
+	expected, err := programDuration(prog)
+	if err != nil {
+		return false
+	}
+
+	actual, err := probeDuration(ctx, output.AbsPath())
+	if errors.Is(err, errFFprobeNotFound) {
+		return false
+	}
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to check the duration of [%s]%s: %v", prog.StationID, prog.Title, err))
+		return false
+	}
+
+	if expected-actual <= asset.DurationTolerance {
+		return false
+	}
+	emitLogMessage(ctx, "error", fmt.Sprintf("the output file of [%s]%s is too short: %v of %v",
+		prog.StationID, prog.Title, actual.Round(time.Second), expected))
+	return removeOutputForRetry(asset, output)
+}
@@ -0,0 +1,64 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// stubProbeDuration replaces probeDuration for the test
+func stubProbeDuration(t *testing.T, d time.Duration, err error) {
+	t.Helper()
+	orig := probeDuration
+	probeDuration = func(context.Context, string) (time.Duration, error) { return d, err }
+	t.Cleanup(func() { probeDuration = orig })
+}
+
+func TestValidateOutputDuration(t *testing.T) {
+	prog := &Prog{StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"}
+
+	tests := []struct {
+		name      string
+		tolerance time.Duration
+		duration  time.Duration
+		probeErr  error
+		wantRetry bool
+	}{
+		{"full length", time.Minute, time.Hour, nil, false},
+		{"within tolerance", time.Minute, 59*time.Minute + 30*time.Second, nil, false},
+		{"missing middle", time.Minute, 45 * time.Minute, nil, true},
+		{"check disabled", 0, 45 * time.Minute, nil, false},
+		{"no ffprobe", time.Minute, 0, errFFprobeNotFound, false},
+		{"ffprobe error", time.Minute, 0, errors.New("ffprobe failed"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubProbeDuration(t, tt.duration, tt.probeErr)
+			output := newOutputConfigFromPath(t.TempDir(), "test", radigo.AudioFormatAAC)
+			if err := os.WriteFile(output.AbsPath(), []byte("audio"), 0600); err != nil {
+				t.Fatalf("failed to create the output: %v", err)
+			}
+			asset := &Asset{DurationTolerance: tt.tolerance}
+			ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+			if got := validateOutputDuration(ctx, prog, output); got != tt.wantRetry {
+				t.Errorf("validateOutputDuration() = %v, want %v", got, tt.wantRetry)
+			}
+			_, err := os.Stat(output.AbsPath())
+			if tt.wantRetry {
+				if !os.IsNotExist(err) {
+					t.Error("expected the short output to be removed")
+				}
+				if asset.NextFetchTime == nil {
+					t.Error("expected a retry to be scheduled")
+				}
+			} else if err != nil {
+				t.Errorf("expected the output to be kept: %v", err)
+			}
+		})
+	}
+}
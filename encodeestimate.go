@@ -0,0 +1,148 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encodeSampleDuration is how much of a queued program measureEncodeSpeed encodes
+const encodeSampleDuration = 30 * time.Second
+
+// measureEncodeSpeed returns how many times faster than real time ffmpeg encodes the AAC file as the asset's
+// MP3 outputs on this machine; replaced in tests
+var measureEncodeSpeed = encodeSpeed
+
+// EncodeEstimate is the estimate of encoding the deferred MP3 outputs
+type EncodeEstimate struct {
+	// Jobs is the number of the programs waiting for the encoding, and Duration their total length
+	Jobs     int
+	Duration time.Duration
+	// OutputSize is the total size of the MP3 outputs at Bitrate
+	OutputSize int64
+	Bitrate    string
+	// Speed is how many times faster than real time ffmpeg encodes on this machine, or 0 if not measured
+	Speed float64
+}
+
+// CPUTime returns how long ffmpeg takes to encode all the programs one by one, or 0 if the speed is not measured
+func (e EncodeEstimate) CPUTime() time.Duration {
+	if e.Speed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(e.Duration) / e.Speed)
+}
+
+// EstimateDeferredEncoding estimates the length of the programs in the encode queue, the size of their MP3 outputs
+// at the bitrate of the asset of ctx, and the speed of ffmpeg measured on a sample of the first,
+// so that a large batch is not started by mistake, e.g., on a Raspberry Pi; a failed measurement leaves Speed 0
+func EstimateDeferredEncoding(ctx context.Context) (EncodeEstimate, error) {
+	asset := GetAsset(ctx)
+	bytesPerSecond, bitrate := mp3OutputRate(asset)
+	est := EncodeEstimate{Bitrate: bitrate}
+	dir, err := encodeQueueDir()
+	if err != nil {
+		return est, err
+	}
+	keys, err := pendingEncodeJobs(dir)
+	if err != nil {
+		return est, err
+	}
+
+	sample := ""
+	for _, key := range keys {
+		aacPath := filepath.Join(dir, key+".aac")
+		d, ok := encodeJobDuration(dir, key, aacPath)
+		if !ok {
+			continue
+		}
+		est.Jobs++
+		est.Duration += d
+		if sample == "" {
+			sample = aacPath
+		}
+	}
+	est.OutputSize = int64(est.Duration.Seconds() * float64(bytesPerSecond))
+
+	if sample != "" {
+		if speed, err := measureEncodeSpeed(ctx, asset, sample); err == nil {
+			est.Speed = speed
+		} else {
+			emitLogMessage(ctx, "error", fmt.Sprintf("failed to measure the encoding speed: %v", err))
+		}
+	}
+	return est, nil
+}
+
+// encodeJobDuration returns the length of the program of the queued job, or of its AAC file at the bitrate of radiko
+// if the program has no valid times; false for a broken job
+func encodeJobDuration(dir, key, aacPath string) (time.Duration, bool) {
+	var job encodeJob
+	blob, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil || json.Unmarshal(blob, &job) != nil || job.Prog == nil {
+		return 0, false
+	}
+	if from, to, err := ProgramSpan(job.Prog); err == nil {
+		return to.Sub(from), true
+	}
+	info, err := os.Stat(aacPath)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(info.Size()) * time.Second / aacBytesPerSecond, true
+}
+
+// mp3OutputRate returns the bytes per second of the MP3 outputs of the asset and its bitrate in words:
+// the MP3Bitrate, or the default of libmp3lame, which the VBR qualities average around
+func mp3OutputRate(asset *Asset) (int64, string) {
+	if asset != nil && asset.MP3Bitrate != "" {
+		rate := strings.ToLower(asset.MP3Bitrate)
+		multiplier := int64(1)
+		if r, ok := strings.CutSuffix(rate, "k"); ok {
+			rate, multiplier = r, 1000
+		}
+		if bits, err := strconv.ParseInt(rate, 10, 64); err == nil && bits > 0 {
+			return bits * multiplier / 8, asset.MP3Bitrate
+		}
+	}
+	if asset != nil && asset.MP3Quality != nil {
+		return mp3BytesPerSecond, fmt.Sprintf("VBR quality %d (about 128k)", *asset.MP3Quality)
+	}
+	return mp3BytesPerSecond, "128k"
+}
+
+// encodeSpeed encodes encodeSampleDuration of the AAC file to nowhere with the encoder arguments of the asset
+// and returns how many times faster than real time it took
+func encodeSpeed(ctx context.Context, asset *Asset, aacPath string) (float64, error) {
+	ffmpegPath, err := lookFFmpeg(asset)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(aacPath)
+	if err != nil {
+		return 0, err
+	}
+	sample := min(encodeSampleDuration, time.Duration(info.Size())*time.Second/aacBytesPerSecond)
+	if sample <= 0 {
+		return 0, fmt.Errorf("%s is empty", aacPath)
+	}
+	args := []string{"-t", strconv.FormatFloat(sample.Seconds(), 'f', -1, 64), "-i", aacPath}
+	args = append(args, mp3EncoderArgs(asset)...)
+	args = append(args, "-f", "null", "-loglevel", "error", "-")
+	started := time.Now()
+	if out, err := exec.CommandContext(ctx, ffmpegPath, args...).CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	elapsed := time.Since(started)
+	if elapsed <= 0 {
+		return 0, errors.New("ffmpeg took no time")
+	}
+	return sample.Seconds() / elapsed.Seconds(), nil
+}
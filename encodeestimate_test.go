@@ -0,0 +1,93 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestEstimateDeferredEncoding(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origMeasure := measureEncodeSpeed
+	t.Cleanup(func() { measureEncodeSpeed = origMeasure })
+	var sampled string
+	measureEncodeSpeed = func(_ context.Context, _ *Asset, aacPath string) (float64, error) {
+		sampled = aacPath
+		return 4, nil
+	}
+
+	asset := &Asset{MP3Bitrate: "192k"}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	est, err := EstimateDeferredEncoding(ctx)
+	if err != nil || est.Jobs != 0 || est.Speed != 0 {
+		t.Fatalf("expected nothing to estimate, got %+v %v", est, err)
+	}
+
+	// an hour and a half-hour program, the latter without valid times
+	tmp := t.TempDir()
+	for _, prog := range []*Prog{
+		{ID: "1", StationID: "TBS", Ft: "20230605010000", To: "20230605020000"},
+		{ID: "2", StationID: "TBS", Ft: "invalid", To: "invalid"},
+	} {
+		aac := filepath.Join(tmp, prog.ID+".aac")
+		size := int64(0)
+		if prog.ID == "2" {
+			size = 30 * 60 * aacBytesPerSecond
+		}
+		if err := os.WriteFile(aac, make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		output := newOutputConfigFromPath(tmp, prog.ID, radigo.AudioFormatMP3)
+		if err := deferEncoding(prog, aac, output); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	est, err = EstimateDeferredEncoding(ctx)
+	if err != nil {
+		t.Fatalf("EstimateDeferredEncoding failed: %v", err)
+	}
+	if est.Jobs != 2 || est.Duration != 90*time.Minute {
+		t.Errorf("expected 2 programs of 1h30m, got %d of %v", est.Jobs, est.Duration)
+	}
+	if want := int64(90 * 60 * 192000 / 8); est.OutputSize != want || est.Bitrate != "192k" {
+		t.Errorf("expected %d bytes at 192k, got %d at %s", want, est.OutputSize, est.Bitrate)
+	}
+	if est.Speed != 4 || est.CPUTime() != 90*time.Minute/4 {
+		t.Errorf("expected the CPU time at 4x, got %v at %vx", est.CPUTime(), est.Speed)
+	}
+	if filepath.Base(sampled) != "1.aac" {
+		t.Errorf("expected the first program sampled, got %s", sampled)
+	}
+
+	// the speed unknown
+	measureEncodeSpeed = func(context.Context, *Asset, string) (float64, error) { return 0, errors.New("no ffmpeg") }
+	est, err = EstimateDeferredEncoding(ctx)
+	if err != nil || est.Speed != 0 || est.CPUTime() != 0 {
+		t.Errorf("expected the CPU time unknown, got %v %v", est.CPUTime(), err)
+	}
+}
+
+func TestMP3OutputRate(t *testing.T) {
+	quality := 4
+	tests := []struct {
+		asset   *Asset
+		rate    int64
+		bitrate string
+	}{
+		{nil, mp3BytesPerSecond, "128k"},
+		{&Asset{MP3Bitrate: "320k"}, 40000, "320k"},
+		{&Asset{MP3Bitrate: "96000"}, 12000, "96000"},
+		{&Asset{MP3Quality: &quality}, mp3BytesPerSecond, "VBR quality 4 (about 128k)"},
+	}
+	for _, tt := range tests {
+		if rate, bitrate := mp3OutputRate(tt.asset); rate != tt.rate || bitrate != tt.bitrate {
+			t.Errorf("mp3OutputRate(%+v) = %d, %q, want %d, %q", tt.asset, rate, bitrate, tt.rate, tt.bitrate)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+var (
+	// encodeDraining is true while a drainer encodes the queued jobs
+	encodeDraining   bool
+	encodeDrainingMu sync.Mutex
+)
+
+// encodeJob is an MP3 encoding deferred until the downloads complete,
+// persisted as "<key>.json" beside the downloaded "<key>.aac" in EncodeQueueDirName
+type encodeJob struct {
+	Prog         *Prog  `json:"prog"`
+	DirFullPath  string `json:"dir"`
+	FileBaseName string `json:"base"`
+	FileFormat   string `json:"format"`
+}
+
+// encodeQueueDir returns the dir of the deferred encodings in RADICRON_HOME
+func encodeQueueDir() (string, error) {
+	return getRadicronPath(EncodeQueueDirName)
+}
+
+// deferEncoding moves the downloaded file to the encode queue with the job to encode it later
+func deferEncoding(prog *Prog, concatedFile string, output *radigo.OutputConfig) error {
+	dir, err := encodeQueueDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		return fmt.Errorf("failed to create the encode queue: %w", err)
+	}
+	key := programLockKey(prog)
+	blob, err := json.Marshal(encodeJob{
+		Prog:         prog,
+		DirFullPath:  output.DirFullPath,
+		FileBaseName: output.FileBaseName,
+		FileFormat:   output.FileFormat,
+	})
+	if err != nil {
+		return err
+	}
+	if err := moveFile(concatedFile, filepath.Join(dir, key+".aac")); err != nil {
+		return fmt.Errorf("failed to move the downloaded file to the encode queue: %w", err)
+	}
+	// the job is written last so that a queued job always has its file
+	return writeFileAtomic(filepath.Join(dir, key+".json"), blob, FilePermissions)
+}
+
+// isEncodePending returns true if the program is downloaded and waiting for its deferred encoding
+func isEncodePending(prog *Prog) bool {
+	dir, err := encodeQueueDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, programLockKey(prog)+".json"))
+	return err == nil
+}
+
+// pendingEncodeJobs returns the keys of the queued jobs in order
+func pendingEncodeJobs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			keys = append(keys, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// StartDeferredEncoding encodes the queued jobs in the background unless already doing so,
+// waiting for the asset's EncodingWindow if set and out of its QuietHours. Call it once the downloads complete.
+func StartDeferredEncoding(ctx context.Context) {
+	encodeDrainingMu.Lock()
+	defer encodeDrainingMu.Unlock()
+	if encodeDraining {
+		return
+	}
+	encodeDraining = true
+	go func() {
+		defer func() {
+			encodeDrainingMu.Lock()
+			encodeDraining = false
+			encodeDrainingMu.Unlock()
+		}()
+		drainEncodeQueue(ctx)
+	}()
+}
+
+// EncodeDeferred encodes the queued jobs and returns once none is left, e.g., before exiting
+func EncodeDeferred(ctx context.Context) {
+	drainEncodeQueue(ctx)
+}
+
+// drainEncodeQueue encodes the queued jobs, including the ones queued meanwhile, until none is left
+func drainEncodeQueue(ctx context.Context) {
+	dir, err := encodeQueueDir()
+	if err != nil {
+		return
+	}
+	for ctx.Err() == nil {
+		keys, err := pendingEncodeJobs(dir)
+		if err != nil {
+			emitLogMessage(ctx, "error", fmt.Sprintf("failed to read the encode queue: %v", err))
+			return
+		}
+		if len(keys) == 0 {
+			return
+		}
+		asset := GetAsset(ctx)
+		var window *ThrottleWindow
+		if asset != nil {
+			window = asset.EncodingWindow
+		}
+		if !waitEncodingWindow(ctx, window) {
+			return
+		}
+		// the encoding window may have started in the quiet hours
+		if asset.ActiveQuietHours(throttleNow()) != nil {
+			if !waitQuietHours(ctx, asset) {
+				return
+			}
+			continue
+		}
+		emitLogMessage(ctx, "info", fmt.Sprintf("encoding %d downloaded programs", len(keys)))
+
+		// the encode pool limits the jobs running at once
+		var wg sync.WaitGroup
+		for _, key := range keys {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				runEncodeJob(ctx, dir, key)
+			}(key)
+		}
+		wg.Wait()
+	}
+}
+
+// waitEncodingWindow blocks until the window starts, returning false if ctx is done first
+func waitEncodingWindow(ctx context.Context, window *ThrottleWindow) bool {
+	now := throttleNow()
+	if window == nil || window.Contains(now) {
+		return true
+	}
+	now = now.In(Location)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, window.From, 0, 0, Location)
+	if !start.After(now) {
+		start = start.Add(OneDay * time.Hour)
+	}
+	emitLogMessage(ctx, "info", fmt.Sprintf("waiting for the encoding window %s until %v", window, start))
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// runEncodeJob encodes, validates, and tags the queued job, removing it from the queue.
+// A program failing to be saved is queued to download again.
+func runEncodeJob(ctx context.Context, dir, key string) {
+	jobPath := filepath.Join(dir, key+".json")
+	aacPath := filepath.Join(dir, key+".aac")
+	var job encodeJob
+	blob, err := os.ReadFile(jobPath)
+	if err == nil {
+		err = json.Unmarshal(blob, &job)
+	}
+	if err == nil && job.Prog == nil {
+		err = errors.New("no program")
+	}
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("dropping the broken encode job %s: %v", key, err))
+		os.Remove(jobPath)
+		os.Remove(aacPath)
+		return
+	}
+
+	ctx = withTimefreeExpiry(ctx, job.Prog)
+	output := newOutputConfigFromPath(job.DirFullPath, job.FileBaseName, job.FileFormat)
+	saved := false
+	if err := output.SetupDir(); err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("Failed to setup output dir: %v", err))
+	} else {
+		saved = finalizeOutput(ctx, job.Prog, aacPath, output)
+	}
+	if !saved && ctx.Err() != nil {
+		// encode it on the next start
+		return
+	}
+
+	os.Remove(jobPath)
+	os.Remove(aacPath)
+	if !saved {
+		enqueueProgram(ctx, job.Prog)
+		return
+	}
+	transcribeOutput(ctx, job.Prog, output)
+}
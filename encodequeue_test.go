@@ -0,0 +1,109 @@
+package radikron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeferEncoding(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	prog := &Prog{ID: "p1", StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"}
+	if isEncodePending(prog) {
+		t.Fatal("expected no encoding pending before deferring")
+	}
+
+	concated := filepath.Join(home, "concated.aac")
+	if err := os.WriteFile(concated, []byte("audio"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output := newOutputConfigFromPath(filepath.Join(home, "downloads"), "2023-06-05-1300_FMT_Test", "mp3")
+	if err := deferEncoding(prog, concated, output); err != nil {
+		t.Fatalf("deferEncoding failed: %v", err)
+	}
+	if !isEncodePending(prog) {
+		t.Error("expected the encoding pending")
+	}
+	if _, err := os.Stat(concated); !os.IsNotExist(err) {
+		t.Error("expected the downloaded file moved to the encode queue")
+	}
+	keys, err := pendingEncodeJobs(filepath.Join(home, EncodeQueueDirName))
+	if err != nil || len(keys) != 1 || keys[0] != "p1" {
+		t.Errorf("expected the job p1, got %v, %v", keys, err)
+	}
+}
+
+func TestRunEncodeJob(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+
+	dir := filepath.Join(home, EncodeQueueDirName)
+	queue := func(prog *Prog, base string) {
+		t.Helper()
+		concated := filepath.Join(home, base+".tmp")
+		if err := os.WriteFile(concated, make([]byte, 1024), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// AAC skips ffmpeg and runs the rest of the pipeline
+		output := newOutputConfigFromPath(filepath.Join(home, "downloads"), base, "aac")
+		if err := deferEncoding(prog, concated, output); err != nil {
+			t.Fatalf("deferEncoding failed: %v", err)
+		}
+	}
+
+	saved := &Prog{ID: "p1", StationID: "FMT", Title: "Saved", Ft: "20230605130000", To: "20230605140000"}
+	queue(saved, "2023-06-05-1300_FMT_Saved")
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{})
+	runEncodeJob(ctx, dir, "p1")
+	if _, err := os.Stat(filepath.Join(home, "downloads", "2023-06-05-1300_FMT_Saved.aac")); err != nil {
+		t.Errorf("expected the output saved: %v", err)
+	}
+	if isEncodePending(saved) {
+		t.Error("expected the job removed once saved")
+	}
+
+	// a too small output is downloaded again
+	failed := &Prog{ID: "p2", StationID: "FMT", Title: "Small", Ft: "20230605140000", To: "20230605150000"}
+	queue(failed, "2023-06-05-1400_FMT_Small")
+	ctx = context.WithValue(context.Background(), ContextKey("asset"), &Asset{MinimumOutputSize: 1 << 20})
+	runEncodeJob(ctx, dir, "p2")
+	if isEncodePending(failed) {
+		t.Error("expected the job removed after the failure")
+	}
+	queued, err := LoadQueue()
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	if len(queued) != 1 || queued[0].ID != "p2" {
+		t.Errorf("expected p2 queued to download again, got %v", queued)
+	}
+}
+
+func TestWaitEncodingWindow(t *testing.T) {
+	now := time.Date(2023, 6, 5, 12, 0, 0, 0, Location)
+	origNow := throttleNow
+	throttleNow = func() time.Time { return now }
+	t.Cleanup(func() { throttleNow = origNow })
+
+	if !waitEncodingWindow(context.Background(), nil) {
+		t.Error("expected no wait without a window")
+	}
+	day, _ := ParseThrottleWindow("09:00", "18:00", 0, 0)
+	if !waitEncodingWindow(context.Background(), &day) {
+		t.Error("expected no wait inside the window")
+	}
+
+	night, _ := ParseThrottleWindow("01:00", "06:00", 0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if waitEncodingWindow(ctx, &night) {
+		t.Error("expected the wait canceled before the window")
+	}
+}
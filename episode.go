@@ -0,0 +1,77 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+)
+
+// EpisodeCounter numbers the programs downloaded by a rule, so that the podcast apps order them
+// even if their titles have no numbers
+type EpisodeCounter struct {
+	// Last is the last episode assigned
+	Last int `json:"last"`
+	// Pending are the episodes assigned to the programs not downloaded yet, kept over their retries
+	Pending map[string]int `json:"pending,omitempty"`
+}
+
+// assignEpisode returns the episode of the program in its rule: the one it was downloaded or assigned with before,
+// or the next one of the rule, reserved for the program only with reserve; 0 without a rule
+func assignEpisode(prog *Prog, reserve bool) (int, error) {
+	if prog.RuleName == "" {
+		return 0, nil
+	}
+	key := programLockKey(prog)
+	episode := 0
+	err := updateHistory(func(f *historyFile) bool {
+		// the program downloaded again, e.g., the output deleted, keeps its episode
+		for i := len(f.Downloads) - 1; i >= 0; i-- {
+			if d := f.Downloads[i]; d.Key == key && d.Episode > 0 {
+				episode = d.Episode
+				return false
+			}
+		}
+		if f.Episodes == nil {
+			f.Episodes = map[string]EpisodeCounter{}
+		}
+		counter := f.Episodes[prog.RuleName]
+		if e, ok := counter.Pending[key]; ok {
+			episode = e
+			return false
+		}
+		if !reserve {
+			episode = counter.Last + 1
+			return false
+		}
+		if counter.Pending == nil {
+			counter.Pending = map[string]int{}
+		}
+		counter.Last++
+		counter.Pending[key] = counter.Last
+		f.Episodes[prog.RuleName] = counter
+		episode = counter.Last
+		return true
+	})
+	return episode, err
+}
+
+// releaseEpisode forgets the pending episode of the program of the rule once it needs no more download,
+// as the download record keeps the episode of a downloaded program
+func releaseEpisode(f *historyFile, rule, key string) {
+	counter, ok := f.Episodes[rule]
+	if !ok {
+		return
+	}
+	delete(counter.Pending, key)
+	f.Episodes[rule] = counter
+}
+
+// setEpisode numbers the program in its rule, logging the failure to leave it unnumbered;
+// without reserve, the number is only looked up, e.g., to name the output of a program which may still be skipped
+func setEpisode(ctx context.Context, prog *Prog, reserve bool) {
+	episode, err := assignEpisode(prog, reserve)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to number the episode of [%s]%s: %v", prog.StationID, prog.Title, err))
+		return
+	}
+	prog.Episode = episode
+}
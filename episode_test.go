@@ -0,0 +1,105 @@
+package radikron
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAssignEpisode(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+
+	first := &Prog{ID: "1", StationID: "FMT", Ft: "20230605130000", RuleName: "airship"}
+	second := &Prog{ID: "2", StationID: "FMT", Ft: "20230612130000", RuleName: "airship"}
+	other := &Prog{ID: "3", StationID: "TBS", Ft: "20230605010000", RuleName: "junk"}
+	for _, tt := range []struct {
+		prog *Prog
+		want int
+	}{
+		{first, 1},
+		{second, 2},
+		{other, 1},
+		// a retry keeps the episode pending
+		{first, 1},
+		{&Prog{ID: "4", StationID: "FMT", Ft: "20230605150000"}, 0},
+	} {
+		got, err := assignEpisode(tt.prog, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expected episode %d of %s, got %d", tt.want, tt.prog.ID, got)
+		}
+	}
+
+	// the download record keeps the episode after the pending one is released
+	first.Episode = 1
+	if err := recordDownload(newDownloadRecord(first, "/tmp/1.aac", time.Now(), 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	path, err := historyPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := loadHistoryFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Episodes["airship"].Pending[programLockKey(first)]; ok {
+		t.Error("expected the pending episode to be released once downloaded")
+	}
+	if got, err := assignEpisode(first, true); err != nil || got != 1 {
+		t.Errorf("expected the downloaded program to keep episode 1, got %d (%v)", got, err)
+	}
+	// the next episode is only looked up without reserve
+	fifth := &Prog{ID: "5", StationID: "FMT", Ft: "20230619130000", RuleName: "airship"}
+	for range 2 {
+		if got, err := assignEpisode(&Prog{ID: "6", StationID: "FMT", Ft: "20230626130000", RuleName: "airship"}, false); err != nil || got != 3 {
+			t.Errorf("expected the next episode 3 looked up, got %d (%v)", got, err)
+		}
+	}
+	if got, err := assignEpisode(fifth, true); err != nil || got != 3 {
+		t.Errorf("expected the next episode 3, got %d (%v)", got, err)
+	}
+
+	// a given-up program releases its episode
+	if _, err := recordFailedAttempt(second, 1); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = loadHistoryFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Episodes["airship"].Pending[programLockKey(second)]; ok {
+		t.Error("expected the pending episode to be released once given up")
+	}
+}
+
+func TestDownload_SkippedTakesNoEpisode(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+	asset := &Asset{DownloadDir: "downloads", OutputFormat: "aac", Schedules: Schedules{}}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	prog := &Prog{ID: "1", StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000", RuleName: "airship"}
+	if _, err := recordFailedAttempt(prog, 1); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	if err := Download(ctx, &wg, prog); err != nil {
+		t.Fatalf("expected the given-up program to be skipped, got %v", err)
+	}
+	path, err := historyPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := loadHistoryFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter := f.Episodes["airship"]; counter.Last != 0 || len(counter.Pending) != 0 {
+		t.Errorf("expected the skipped program to take no episode, got %+v", counter)
+	}
+}
@@ -0,0 +1,421 @@
+package radikron
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+const (
+	// feedTokenBytes is the length of the random feed tokens
+	feedTokenBytes = 24
+	// feedItemsLimit is the number of the newest outputs listed in a feed
+	feedItemsLimit = 200
+	// feedShutdownTimeout is how long the feed server waits for the requests in flight on shutdown
+	feedShutdownTimeout = 5 * time.Second
+)
+
+// feedTokensMu serializes the updates to the feed tokens file
+var feedTokensMu sync.Mutex
+
+// feedTokensFile keeps the private token of each device in RADICRON_HOME
+type feedTokensFile struct {
+	Version int               `json:"version"`
+	Tokens  map[string]string `json:"tokens"`
+}
+
+// SetFeeds sets the folders under the asset's DownloadDir each device subscribes to,
+// and the URL of the feed server the feeds link to
+func (a *Asset) SetFeeds(baseURL string, devices map[string][]string) error {
+	if err := ValidateFeeds(devices); err != nil {
+		return err
+	}
+	a.Feeds = devices
+	a.FeedURLBase = strings.TrimSuffix(baseURL, "/")
+	return nil
+}
+
+// ValidateFeeds returns an error if a device has no folders or a folder is outside the downloads dir
+func ValidateFeeds(devices map[string][]string) error {
+	for name, folders := range devices {
+		if len(folders) == 0 {
+			return fmt.Errorf("feed %s has no folders", name)
+		}
+		for _, folder := range folders {
+			if !filepath.IsLocal(folder) {
+				return fmt.Errorf("invalid folder of feed %s: %s", name, folder)
+			}
+		}
+	}
+	return nil
+}
+
+// feedTokensPath returns the path of the feed tokens file in RADICRON_HOME
+func feedTokensPath() (string, error) {
+	home, err := getRadicronPath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, FeedTokensFileName), nil
+}
+
+func loadFeedTokens(path string) (map[string]string, error) {
+	f := feedTokensFile{Tokens: map[string]string{}}
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f.Tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the feed tokens: %w", err)
+	}
+	if err := json.Unmarshal(blob, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse the feed tokens: %w", err)
+	}
+	if f.Tokens == nil {
+		f.Tokens = map[string]string{}
+	}
+	return f.Tokens, nil
+}
+
+func newFeedToken() (string, error) {
+	b := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FeedTokens returns the tokens of the devices, generating the missing ones and the rotated ones anew
+func FeedTokens(names []string, rotate ...string) (map[string]string, error) {
+	path, err := feedTokensPath()
+	if err != nil {
+		return nil, err
+	}
+	feedTokensMu.Lock()
+	defer feedTokensMu.Unlock()
+	tokens, err := loadFeedTokens(path)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := false
+	for _, name := range append(append([]string{}, names...), rotate...) {
+		if _, ok := tokens[name]; ok && !containsString(rotate, name) {
+			continue
+		}
+		if tokens[name], err = newFeedToken(); err != nil {
+			return nil, err
+		}
+		updated = true
+	}
+	if updated {
+		if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+			return nil, fmt.Errorf("failed to create RADICRON_HOME: %w", err)
+		}
+		blob, err := json.Marshal(feedTokensFile{Version: FeedTokensVersion, Tokens: tokens})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(path, blob, FilePermissions); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make(map[string]string, len(names))
+	for _, name := range names {
+		result[name] = tokens[name]
+	}
+	return result, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedURLs returns the private feed URL of each device of the asset, generating the missing tokens
+func (a *Asset) FeedURLs() (map[string]string, error) {
+	names := make([]string, 0, len(a.Feeds))
+	for name := range a.Feeds {
+		names = append(names, name)
+	}
+	tokens, err := FeedTokens(names)
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string]string, len(tokens))
+	for name, token := range tokens {
+		urls[name] = a.FeedURLBase + "/feed/" + token + ".xml"
+	}
+	return urls, nil
+}
+
+// feedDevice returns the device of the asset with the token and its folders, or false if no device has the token
+func (a *Asset) feedDevice(token string) (name string, folders []string, ok bool) {
+	if a == nil {
+		return "", nil, false
+	}
+	names := make([]string, 0, len(a.Feeds))
+	for n := range a.Feeds {
+		names = append(names, n)
+	}
+
+	tokens, err := FeedTokens(names)
+	if err != nil {
+		return "", nil, false
+	}
+	for n, t := range tokens {
+		// compare in constant time not to leak the tokens
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return n, a.Feeds[n], true
+		}
+	}
+	return "", nil, false
+}
+
+// feedItem is an output listed in a feed
+type feedItem struct {
+	folder string
+	name   string
+	info   os.FileInfo
+	meta   *programSidecar
+}
+
+// listFeedItems returns the outputs directly in the folders under the downloads dir, the newest first
+func listFeedItems(downloadsDir string, folders []string) []feedItem {
+	var items []feedItem
+	for _, folder := range folders {
+		entries, err := os.ReadDir(filepath.Join(downloadsDir, folder))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !isAudioOutput(e.Name()) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			item := feedItem{folder: folder, name: e.Name(), info: info}
+			if blob, err := os.ReadFile(sidecarPath(filepath.Join(downloadsDir, folder, e.Name()), "."+SidecarJSON)); err == nil {
+				var meta programSidecar
+				if json.Unmarshal(blob, &meta) == nil {
+					item.meta = &meta
+				}
+			}
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].info.ModTime().After(items[j].info.ModTime())
+	})
+	if len(items) > feedItemsLimit {
+		items = items[:feedItemsLimit]
+	}
+	return items
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Description string       `xml:"description,omitempty"`
+	GUID        string       `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// audioMIMEType returns the MIME type of the output format
+func audioMIMEType(name string) string {
+	switch strings.TrimPrefix(filepath.Ext(name), ".") {
+	case radigo.AudioFormatMP3:
+		return "audio/mpeg"
+	case AudioFormatM4A:
+		return "audio/mp4"
+	default:
+		return "audio/aac"
+	}
+}
+
+// newRSSFeed returns the podcast feed of the items with the enclosures under the media URL of the token
+func newRSSFeed(device, baseURL, token string, items []feedItem) rssFeed {
+	feed := rssFeed{Version: "2.0", Channel: rssChannel{
+		Title:       "radikron: " + device,
+		Link:        baseURL + "/feed/" + token + ".xml",
+		Description: "The programs saved by radikron for " + device,
+	}}
+	for _, it := range items {
+		rel := path.Join(filepath.ToSlash(it.folder), it.name)
+		item := rssItem{
+			Title:   strings.TrimSuffix(it.name, filepath.Ext(it.name)),
+			GUID:    rel,
+			PubDate: it.info.ModTime().Format(time.RFC1123Z),
+			Enclosure: rssEnclosure{
+				URL:    baseURL + "/media/" + token + "/" + (&url.URL{Path: rel}).EscapedPath(),
+				Length: it.info.Size(),
+				Type:   audioMIMEType(it.name),
+			},
+		}
+		if it.meta != nil {
+			item.Title = it.meta.Title
+			item.Description = plainText(it.meta.Info)
+			if item.Description == "" {
+				item.Description = it.meta.Desc
+			}
+			if ft, err := ParseDatetime(it.meta.Ft); err == nil {
+				item.PubDate = ft.Format(time.RFC1123Z)
+			}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
+
+// NewFeedHandler returns the handler serving each device its private podcast feed at "/feed/<token>.xml"
+// and the outputs of its folders at "/media/<token>/<folder>/<file>", the weekly digest of the downloads
+// at "/digest/<token>.xml" and "/digest/<token>.html", and the public station metadata
+// at "/api/stations" and "/api/stations/<id>", all of the asset of current, e.g., StatusAsset
+func NewFeedHandler(current func() *Asset) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feed/{file}", serveFeed)
+	mux.HandleFunc("GET /media/{token}/{path...}", serveFeedMedia)
+	mux.HandleFunc("GET /digest/{file}", serveDigest)
+	mux.HandleFunc("GET /digest/{token}/media/{path...}", serveDigestMedia)
+	mux.HandleFunc("GET /api/stations", serveStations)
+	mux.HandleFunc("GET /api/stations/{id}", serveStation)
+	return withAsset(current, mux)
+}
+
+// feedBase returns the URL of the feed server the feeds of the asset link to, the host of the request if unset
+func (a *Asset) feedBase(r *http.Request) string {
+	if a.FeedURLBase == "" {
+		return "http://" + r.Host
+	}
+	return a.FeedURLBase
+}
+
+func serveFeed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	asset := GetAsset(r.Context())
+	device, folders, ok := asset.feedDevice(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	downloadsDir, err := getRadicronPath(asset.DownloadDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	blob, err := xml.MarshalIndent(newRSSFeed(device, asset.feedBase(r), token, listFeedItems(downloadsDir, folders)), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(blob)
+}
+
+func serveFeedMedia(w http.ResponseWriter, r *http.Request) {
+	asset := GetAsset(r.Context())
+	_, folders, ok := asset.feedDevice(r.PathValue("token"))
+	rel := r.PathValue("path")
+	if !ok || !filepath.IsLocal(filepath.FromSlash(rel)) || !isAudioOutput(rel) {
+		http.NotFound(w, r)
+		return
+	}
+	// only the outputs directly in the folders of the device
+	folder := filepath.Dir(filepath.FromSlash(rel))
+	if !containsString(cleanFolders(folders), folder) {
+		http.NotFound(w, r)
+		return
+	}
+	downloadsDir, err := getRadicronPath(asset.DownloadDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f, err := os.Open(filepath.Join(downloadsDir, filepath.FromSlash(rel)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", audioMIMEType(rel))
+	// ServeContent handles the range requests of the podcast apps
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func cleanFolders(folders []string) []string {
+	cleaned := make([]string, len(folders))
+	for i, f := range folders {
+		cleaned[i] = filepath.Clean(f)
+	}
+	return cleaned
+}
+
+// ServeFeeds serves the private podcast feeds of the asset of current on addr until ctx is done
+func ServeFeeds(ctx context.Context, addr string, current func() *Asset) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           NewFeedHandler(current),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), feedShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package radikron
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFeedTokens(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+
+	tokens, err := FeedTokens([]string{"dad", "kids"})
+	if err != nil {
+		t.Fatalf("FeedTokens failed: %v", err)
+	}
+	if len(tokens["dad"]) != 2*feedTokenBytes || tokens["dad"] == tokens["kids"] {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+
+	again, err := FeedTokens([]string{"dad", "kids"})
+	if err != nil || again["dad"] != tokens["dad"] || again["kids"] != tokens["kids"] {
+		t.Errorf("expected the same tokens, got %v, %v", again, err)
+	}
+
+	rotated, err := FeedTokens([]string{"dad", "kids"}, "kids")
+	if err != nil || rotated["dad"] != tokens["dad"] || rotated["kids"] == tokens["kids"] {
+		t.Errorf("expected a new token of kids only, got %v, %v", rotated, err)
+	}
+}
+
+func TestValidateFeeds(t *testing.T) {
+	if err := ValidateFeeds(map[string][]string{"dad": {"citypop", "."}}); err != nil {
+		t.Errorf("expected valid feeds: %v", err)
+	}
+	for _, devices := range []map[string][]string{
+		{"dad": {}},
+		{"dad": {"../secret"}},
+		{"dad": {"/etc"}},
+	} {
+		if err := ValidateFeeds(devices); err == nil {
+			t.Errorf("expected an error for %v", devices)
+		}
+	}
+}
+
+func TestFeedHandler(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	downloads := filepath.Join(home, "downloads")
+	for _, dir := range []string{"citypop", "anime"} {
+		if err := os.MkdirAll(filepath.Join(downloads, dir), DirPermissions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	episode := filepath.Join(downloads, "citypop", "2023-06-05-1300_FMT_City Pop.mp3")
+	if err := os.WriteFile(episode, []byte("0123456789"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := `{"station-id":"FMT","title":"City Pop","ft":"20230605130000","info":"<p>80s pop</p>"}`
+	if err := os.WriteFile(sidecarPath(episode, ".json"), []byte(sidecar), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(downloads, "anime", "anime.mp3"), []byte("anime"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	asset := &Asset{DownloadDir: "downloads"}
+	srv := httptest.NewServer(NewFeedHandler(func() *Asset { return asset }))
+	defer srv.Close()
+	if err := asset.SetFeeds(srv.URL, map[string][]string{"dad": {"citypop"}, "kids": {"anime"}}); err != nil {
+		t.Fatal(err)
+	}
+	urls, err := asset.FeedURLs()
+	if err != nil {
+		t.Fatalf("FeedURLs failed: %v", err)
+	}
+
+	resp, err := http.Get(urls["dad"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var feed rssFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		t.Fatalf("failed to parse the feed: %v\n%s", err, body)
+	}
+	if len(feed.Channel.Items) != 1 {
+		t.Fatalf("expected the citypop episode only, got:\n%s", body)
+	}
+	item := feed.Channel.Items[0]
+	if item.Title != "City Pop" || item.Description != "80s pop" || item.Enclosure.Length != 10 ||
+		item.Enclosure.Type != "audio/mpeg" || !strings.Contains(item.PubDate, "05 Jun 2023 13:00:00 +0900") {
+		t.Errorf("unexpected item: %+v", item)
+	}
+
+	// the podcast apps request the ranges of the episodes
+	req, _ := http.NewRequest(http.MethodGet, item.Enclosure.URL, http.NoBody)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "234" {
+		t.Errorf("expected the range of the episode, got %s %q", resp.Status, body)
+	}
+
+	dadToken := strings.TrimSuffix(strings.TrimPrefix(urls["dad"], srv.URL+"/feed/"), ".xml")
+	for _, u := range []string{
+		srv.URL + "/feed/wrong.xml",
+		srv.URL + "/media/wrong/citypop/2023-06-05-1300_FMT_City%20Pop.mp3",
+		srv.URL + "/media/" + dadToken + "/anime/anime.mp3",
+		srv.URL + "/media/" + dadToken + "/citypop/2023-06-05-1300_FMT_City%20Pop.json",
+		srv.URL + "/media/" + dadToken + "/citypop/..%2Fanime%2Fanime.mp3",
+	} {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %s", u, resp.Status)
+		}
+	}
+}
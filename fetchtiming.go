@@ -0,0 +1,57 @@
+package radikron
+
+import (
+	"sync"
+	"time"
+)
+
+// fetchDurationWeight is the weight of the last fetch in the moving average of the fetch durations
+const fetchDurationWeight = 0.3
+
+var (
+	// fetchDuration is the exponential moving average of the fetch durations, 0 before the first fetch
+	fetchDuration   time.Duration
+	fetchDurationMu sync.Mutex
+)
+
+// RecordFetchDuration adds how long a fetch took from its start to the downloads of the matched programs,
+// e.g., reloading the configuration and fetching the program guides, to the moving average
+func RecordFetchDuration(d time.Duration) {
+	fetchDurationMu.Lock()
+	defer fetchDurationMu.Unlock()
+	if fetchDuration == 0 {
+		fetchDuration = d
+		return
+	}
+	fetchDuration = time.Duration(fetchDurationWeight*float64(d) + (1-fetchDurationWeight)*float64(fetchDuration))
+}
+
+// FetchDuration returns the moving average of the fetch durations, 0 before the first fetch
+func FetchDuration() time.Duration {
+	fetchDurationMu.Lock()
+	defer fetchDurationMu.Unlock()
+	return fetchDuration
+}
+
+// ScheduleFetch moves the next fetch of the asset to the next time of its fetch-schedule after now,
+// unless an earlier fetch cannot wait, e.g., to join a live stream in time; it does nothing without a fetch-schedule
+func ScheduleFetch(asset *Asset, now time.Time) {
+	if asset.FetchSchedule == nil {
+		return
+	}
+	next := asset.FetchSchedule.Next(now)
+	if next.IsZero() {
+		return
+	}
+	if asset.NextFetchTime == nil || asset.NextFetchTime.After(next) {
+		asset.NextFetchTime = &next
+	}
+}
+
+// nextFetchAfter returns when to fetch to download the program ending at end: BufferMinutes after it,
+// for timefree to publish the program, less the fetch duration so that the download starts by then;
+// never before the end, or the fetch finds the program still on air
+func nextFetchAfter(end time.Time) time.Time {
+	buffer := BufferMinutes * time.Minute
+	return end.Add(buffer - min(FetchDuration(), buffer))
+}
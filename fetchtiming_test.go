@@ -0,0 +1,69 @@
+package radikron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordFetchDuration(t *testing.T) {
+	fetchDurationMu.Lock()
+	saved := fetchDuration
+	fetchDuration = 0
+	fetchDurationMu.Unlock()
+	defer func() {
+		fetchDurationMu.Lock()
+		fetchDuration = saved
+		fetchDurationMu.Unlock()
+	}()
+
+	end := time.Date(2023, 6, 5, 14, 0, 0, 0, Location)
+	if got := nextFetchAfter(end); !got.Equal(end.Add(BufferMinutes * time.Minute)) {
+		t.Errorf("expected BufferMinutes after the end before the first fetch, got %v", got)
+	}
+
+	RecordFetchDuration(100 * time.Second)
+	if got := FetchDuration(); got != 100*time.Second {
+		t.Errorf("expected the first fetch duration, got %v", got)
+	}
+	RecordFetchDuration(200 * time.Second)
+	if got := FetchDuration(); got != 130*time.Second {
+		t.Errorf("expected the moving average of 130s, got %v", got)
+	}
+	if got := nextFetchAfter(end); !got.Equal(end.Add(BufferMinutes*time.Minute - 130*time.Second)) {
+		t.Errorf("expected the fetch duration before BufferMinutes after the end, got %v", got)
+	}
+
+	// the fetch never starts before the end
+	RecordFetchDuration(time.Hour)
+	if got := nextFetchAfter(end); !got.Equal(end) {
+		t.Errorf("expected the end, got %v", got)
+	}
+}
+
+func TestScheduleFetch(t *testing.T) {
+	now := time.Date(2023, 6, 5, 13, 0, 0, 0, Location)
+	asset := &Asset{}
+	ScheduleFetch(asset, now)
+	if asset.NextFetchTime != nil {
+		t.Errorf("expected no fetch scheduled without a fetch-schedule, got %v", asset.NextFetchTime)
+	}
+
+	schedule, err := ParseCronSchedule("0 5 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset.FetchSchedule = schedule
+	ScheduleFetch(asset, now)
+	want := time.Date(2023, 6, 6, 5, 0, 0, 0, Location)
+	if asset.NextFetchTime == nil || !asset.NextFetchTime.Equal(want) {
+		t.Errorf("expected the next fetch at %v, got %v", want, asset.NextFetchTime)
+	}
+
+	// an earlier fetch, e.g., to join a live stream, is kept
+	live := now.Add(time.Hour)
+	asset.NextFetchTime = &live
+	ScheduleFetch(asset, now)
+	if !asset.NextFetchTime.Equal(live) {
+		t.Errorf("expected the earlier fetch kept, got %v", asset.NextFetchTime)
+	}
+}
@@ -0,0 +1,154 @@
+package radikron
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FFmpegBinDir is the dir under RADICRON_HOME InstallFFmpeg saves the static build of ffmpeg to
+const FFmpegBinDir = "bin"
+
+// FFmpegSearchPaths are the common install locations of ffmpeg looked up when it is not in PATH,
+// e.g., Homebrew on Apple silicon or a service running with a minimal PATH
+var FFmpegSearchPaths = []string{
+	"/usr/local/bin/ffmpeg",
+	"/opt/homebrew/bin/ffmpeg",
+	"/usr/bin/ffmpeg",
+	"/snap/bin/ffmpeg",
+	"/opt/local/bin/ffmpeg",
+	`C:\ffmpeg\bin\ffmpeg.exe`,
+	`C:\Program Files\ffmpeg\bin\ffmpeg.exe`,
+}
+
+// ffmpegBinaryName returns the file name of the ffmpeg binary on the OS
+func ffmpegBinaryName() string {
+	if runtime.GOOS == "windows" {
+		return "ffmpeg.exe"
+	}
+	return "ffmpeg"
+}
+
+// FindFFmpeg returns the ffmpeg binary to encode with: the configured one if any,
+// or the first found in PATH, in RADICRON_HOME/bin, and in FFmpegSearchPaths
+func FindFFmpeg(configured string) (string, error) {
+	if configured != "" {
+		ffmpegPath, err := exec.LookPath(configured)
+		if err != nil {
+			return "", fmt.Errorf("ffmpeg not found at %s: %w", configured, err)
+		}
+		return ffmpegPath, nil
+	}
+	if ffmpegPath, err := exec.LookPath("ffmpeg"); err == nil {
+		return ffmpegPath, nil
+	}
+	candidates := FFmpegSearchPaths
+	if binDir, err := getRadicronPath(FFmpegBinDir); err == nil {
+		candidates = append([]string{filepath.Join(binDir, ffmpegBinaryName())}, candidates...)
+	}
+	for _, candidate := range candidates {
+		if ffmpegPath, err := exec.LookPath(candidate); err == nil {
+			return ffmpegPath, nil
+		}
+	}
+	return "", fmt.Errorf("ffmpeg not found in PATH, %s, or the common locations", filepath.Join("$"+EnvRadicronHome, FFmpegBinDir))
+}
+
+// FFmpegVersion returns the first line of `ffmpeg -version`, e.g., "ffmpeg version 6.0 Copyright (c) ..."
+func FFmpegVersion(ctx context.Context, ffmpegPath string) (string, error) {
+	out, err := exec.CommandContext(ctx, ffmpegPath, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s -version: %w", ffmpegPath, err)
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	if line = strings.TrimSpace(line); line == "" {
+		return "", fmt.Errorf("%s -version printed no version", ffmpegPath)
+	}
+	return line, nil
+}
+
+// InstallFFmpeg downloads the static build of ffmpeg at url into RADICRON_HOME/bin and returns its path.
+// The download must match the SHA-256 checksum; a build without a checksum is never installed.
+// A .gz build is decompressed.
+func InstallFFmpeg(ctx context.Context, url, checksum string) (string, error) {
+	if checksum == "" {
+		return "", fmt.Errorf("no checksum to verify %s, pass its SHA-256", url)
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+
+	binDir, err := getRadicronPath(FFmpegBinDir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(binDir, DirPermissions); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", binDir, err)
+	}
+	archive, err := os.CreateTemp(binDir, "ffmpeg-download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req) //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to download ffmpeg: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download ffmpeg: %s", resp.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archive, hash), resp.Body); err != nil {
+		return "", fmt.Errorf("failed to download ffmpeg: %w", err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+		return "", fmt.Errorf("checksum mismatch of %s: got %s, want %s", url, sum, checksum)
+	}
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	var binary io.Reader = archive
+	if strings.HasSuffix(url, ".gz") {
+		gz, err := gzip.NewReader(archive)
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress ffmpeg: %w", err)
+		}
+		defer gz.Close()
+		binary = gz
+	}
+	tmp, err := os.CreateTemp(binDir, "ffmpeg-*.part")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, binary); err != nil { //nolint:gosec // the size is bounded by the verified download
+		tmp.Close()
+		return "", fmt.Errorf("failed to decompress ffmpeg: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil { //nolint:gosec // the binary must be executable
+		return "", err
+	}
+	ffmpegPath := filepath.Join(binDir, ffmpegBinaryName())
+	if err := os.Rename(tmp.Name(), ffmpegPath); err != nil {
+		return "", fmt.Errorf("failed to install ffmpeg: %w", err)
+	}
+	return ffmpegPath, nil
+}
@@ -0,0 +1,133 @@
+package radikron
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFindFFmpeg(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	t.Setenv("PATH", "")
+	searchPaths := FFmpegSearchPaths
+	defer func() { FFmpegSearchPaths = searchPaths }()
+
+	FFmpegSearchPaths = nil
+	if _, err := FindFFmpeg(""); err == nil || !strings.Contains(err.Error(), "ffmpeg not found") {
+		t.Errorf("expected ffmpeg not found, got %v", err)
+	}
+
+	// a common location is found without PATH
+	common := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(common, []byte("#!/bin/sh\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	FFmpegSearchPaths = []string{filepath.Join(t.TempDir(), "missing"), common}
+	if got, err := FindFFmpeg(""); err != nil || got != common {
+		t.Errorf("expected %s, got %s (%v)", common, got, err)
+	}
+
+	// the installed one comes before the common locations
+	installed := filepath.Join(home, FFmpegBinDir, "ffmpeg")
+	if err := os.MkdirAll(filepath.Dir(installed), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(installed, []byte("#!/bin/sh\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FindFFmpeg(""); err != nil || got != installed {
+		t.Errorf("expected %s, got %s (%v)", installed, got, err)
+	}
+
+	// the configured one is never substituted
+	if _, err := FindFFmpeg(filepath.Join(home, "nonexistent")); err == nil || !strings.Contains(err.Error(), "ffmpeg not found at") {
+		t.Errorf("expected ffmpeg not found at the configured path, got %v", err)
+	}
+}
+
+func TestFFmpegVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte("#!/bin/sh\necho 'ffmpeg version 6.0 Copyright (c) 2000-2023'\necho 'built with gcc'\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FFmpegVersion(context.Background(), ffmpeg); err != nil || got != "ffmpeg version 6.0 Copyright (c) 2000-2023" {
+		t.Errorf("unexpected version %q (%v)", got, err)
+	}
+	if _, err := FFmpegVersion(context.Background(), filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for the missing ffmpeg")
+	}
+}
+
+func TestInstallFFmpeg(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho ffmpeg\n")
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write(binary); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(gz.Bytes())
+	checksum := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ffmpeg.gz":
+			_, _ = w.Write(gz.Bytes())
+		case "/ffmpeg.gz.sha256":
+			_, _ = w.Write([]byte(checksum + "  ffmpeg.gz\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	ctx := context.Background()
+
+	ffmpegPath, err := InstallFFmpeg(ctx, server.URL+"/ffmpeg.gz", strings.ToUpper(checksum))
+	if err != nil {
+		t.Fatalf("failed to install ffmpeg: %v", err)
+	}
+	if ffmpegPath != filepath.Join(home, FFmpegBinDir, ffmpegBinaryName()) {
+		t.Errorf("unexpected install path: %s", ffmpegPath)
+	}
+	data, err := os.ReadFile(ffmpegPath)
+	if err != nil || !bytes.Equal(data, binary) {
+		t.Errorf("expected the decompressed binary, got %q (%v)", data, err)
+	}
+	if info, err := os.Stat(ffmpegPath); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected the binary to be executable, got %v (%v)", info.Mode(), err)
+	}
+
+	if _, err := InstallFFmpeg(ctx, server.URL+"/ffmpeg.gz", strings.Repeat("0", 64)); err == nil ||
+		!strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	// the checksum published next to the download is never trusted
+	if _, err := InstallFFmpeg(ctx, server.URL+"/ffmpeg.gz", ""); err == nil || !strings.Contains(err.Error(), "no checksum") {
+		t.Errorf("expected no checksum, got %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(home, FFmpegBinDir))
+	if len(entries) != 1 {
+		t.Errorf("expected only the installed binary to remain, got %d entries", len(entries))
+	}
+}
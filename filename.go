@@ -0,0 +1,124 @@
+package radikron
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultFilenameTemplate names the outputs "<OutputDatetimeLayout>_<station ID>_<title>",
+// which the recovery scan parses to queue the programs of the broken outputs again
+const DefaultFilenameTemplate = "{{.Date}}-{{.Time}}_{{.Station}}_{{.Title}}"
+
+// defaultFilenameTemplate names the outputs (without the extension) of the assets without a filename template
+var defaultFilenameTemplate = template.Must(parseFilenameTemplate(DefaultFilenameTemplate))
+
+// filenameData is the data of the filename template
+type filenameData struct {
+	Date    string // the broadcast date (YYYY-MM-DD)
+	Time    string // the broadcast start time (HHMM)
+	Station string // the station ID
+	Title   string // the canonical title of the program
+	Pfm     string // the personalities of the program
+	Rule    string // the name of the matched rule
+	Episode int    // the number of the program in the matched rule, 0 without a rule
+}
+
+// SetFilenameTemplate sets the template naming the outputs of the asset, or DefaultFilenameTemplate if empty
+func (a *Asset) SetFilenameTemplate(text string) error {
+	if text == "" {
+		a.filenameTemplate = nil
+		return nil
+	}
+	t, err := parseFilenameTemplate(text)
+	if err != nil {
+		return err
+	}
+	a.filenameTemplate = t
+	return nil
+}
+
+// outputFilenameTemplate returns the template naming the outputs of the asset
+func (a *Asset) outputFilenameTemplate() *template.Template {
+	if a == nil || a.filenameTemplate == nil {
+		return defaultFilenameTemplate
+	}
+	return a.filenameTemplate
+}
+
+// namesEpisode returns true if the template names the outputs of two episodes differently
+func namesEpisode(t *template.Template) bool {
+	sample := filenameData{Date: "2006-01-02", Time: "1504", Station: "FMT", Title: "title", Episode: 1}
+	first, _ := renderFilename(t, sample)
+	sample.Episode = 2
+	second, _ := renderFilename(t, sample)
+	return first != second
+}
+
+// filenameNamesEpisode returns true if the outputs of the asset are named with the episode
+func (a *Asset) filenameNamesEpisode() bool {
+	return namesEpisode(a.outputFilenameTemplate())
+}
+
+// ValidateFilenameTemplate returns an error if the template does not parse or names an output with nothing
+func ValidateFilenameTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := parseFilenameTemplate(text)
+	return err
+}
+
+func parseFilenameTemplate(text string) (*template.Template, error) {
+	t, err := template.New("filename").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename template: %w", err)
+	}
+	// catch the unknown fields before the first download
+	sample := filenameData{Date: "2006-01-02", Time: "1504", Station: "FMT", Title: "title", Pfm: "pfm", Rule: "rule", Episode: 1}
+	name, err := renderFilename(t, sample)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename template: %w", err)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return nil, errors.New("invalid filename template: must not contain a path separator")
+	}
+	return t, nil
+}
+
+func renderFilename(t *template.Template, data filenameData) (string, error) {
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(sb.String())
+	if name == "" {
+		return "", errors.New("empty file name")
+	}
+	return name, nil
+}
+
+// outputFileBaseName returns the name of the output of the program (without the extension) with the title,
+// normalized to the FilenameCharset of its rule
+func (a *Asset) outputFileBaseName(prog *Prog, title string, startTime time.Time) (string, error) {
+	start := startTime.In(Location)
+	data := filenameData{
+		Date:    start.Format(time.DateOnly),
+		Time:    start.Format("1504"),
+		Station: prog.StationID,
+		Title:   title,
+		Pfm:     prog.Pfm,
+		Rule:    prog.RuleName,
+		Episode: prog.Episode,
+	}
+	name, err := renderFilename(a.outputFilenameTemplate(), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to name the output: %w", err)
+	}
+	if name = normalizeFilename(name, prog.FilenameCharset); name == "" {
+		return "", errors.New("failed to name the output: empty file name")
+	}
+	return name, nil
+}
@@ -0,0 +1,69 @@
+package radikron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputFileBaseName(t *testing.T) {
+	asset := &Asset{}
+	prog := &Prog{StationID: "FMT", Title: "Test", Pfm: "Host", RuleName: "rule"}
+	start := time.Date(2023, 6, 5, 13, 0, 0, 0, Location)
+
+	name, err := asset.outputFileBaseName(prog, "Test", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Format(OutputDatetimeLayout) + "_FMT_Test"; name != want {
+		t.Errorf("expected the default name %s, got %s", want, name)
+	}
+	if id, parsed, ok := parseOutputFileName(name + ".aac"); !ok || id != "FMT" || !parsed.Equal(start) {
+		t.Errorf("expected the default name to be parsed by the recovery scan, got %s %v %v", id, parsed, ok)
+	}
+
+	if err := asset.SetFilenameTemplate("{{.Rule}} - {{.Title}} ({{.Pfm}}) {{.Date}}"); err != nil {
+		t.Fatal(err)
+	}
+	name, err = asset.outputFileBaseName(prog, "Test", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "rule - Test (Host) 2023-06-05"; name != want {
+		t.Errorf("expected %s, got %s", want, name)
+	}
+	if asset.filenameNamesEpisode() {
+		t.Error("expected the outputs not named with the episode")
+	}
+
+	if err := asset.SetFilenameTemplate(`{{.Title}} #{{printf "%03d" .Episode}}`); err != nil {
+		t.Fatal(err)
+	}
+	prog.Episode = 12
+	name, err = asset.outputFileBaseName(prog, "Test", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Test #012"; name != want {
+		t.Errorf("expected %s, got %s", want, name)
+	}
+	if !asset.filenameNamesEpisode() {
+		t.Error("expected the outputs named with the episode")
+	}
+}
+
+func TestSetFilenameTemplate_Invalid(t *testing.T) {
+	asset := &Asset{}
+	for _, text := range []string{
+		"{{.Title",
+		"{{.Season}}",
+		"{{.Station}}/{{.Title}}",
+		"  ",
+	} {
+		if err := asset.SetFilenameTemplate(text); err == nil {
+			t.Errorf("expected an error for %q", text)
+		}
+	}
+	if asset.filenameTemplate != nil {
+		t.Error("expected the invalid templates not to be set")
+	}
+}
@@ -0,0 +1,162 @@
+package radikron
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The charsets of a rule to normalize the file names of its programs to
+const (
+	// FilenameCharsetASCII transliterates the kana to romaji and the full-width characters to ASCII,
+	// and drops the rest, e.g., the kanji and the emoji
+	FilenameCharsetASCII = "ascii"
+	// FilenameCharsetNoEmoji drops the emoji and the pictographs, e.g., ☆ and ♪
+	FilenameCharsetNoEmoji = "no-emoji"
+)
+
+// romaji are the Hepburn romanizations of the hiragana, with the digraphs
+var romaji = map[string]string{
+	"あ": "a", "い": "i", "う": "u", "え": "e", "お": "o",
+	"か": "ka", "き": "ki", "く": "ku", "け": "ke", "こ": "ko",
+	"さ": "sa", "し": "shi", "す": "su", "せ": "se", "そ": "so",
+	"た": "ta", "ち": "chi", "つ": "tsu", "て": "te", "と": "to",
+	"な": "na", "に": "ni", "ぬ": "nu", "ね": "ne", "の": "no",
+	"は": "ha", "ひ": "hi", "ふ": "fu", "へ": "he", "ほ": "ho",
+	"ま": "ma", "み": "mi", "む": "mu", "め": "me", "も": "mo",
+	"や": "ya", "ゆ": "yu", "よ": "yo",
+	"ら": "ra", "り": "ri", "る": "ru", "れ": "re", "ろ": "ro",
+	"わ": "wa", "ゐ": "i", "ゑ": "e", "を": "o", "ん": "n",
+	"が": "ga", "ぎ": "gi", "ぐ": "gu", "げ": "ge", "ご": "go",
+	"ざ": "za", "じ": "ji", "ず": "zu", "ぜ": "ze", "ぞ": "zo",
+	"だ": "da", "ぢ": "ji", "づ": "zu", "で": "de", "ど": "do",
+	"ば": "ba", "び": "bi", "ぶ": "bu", "べ": "be", "ぼ": "bo",
+	"ぱ": "pa", "ぴ": "pi", "ぷ": "pu", "ぺ": "pe", "ぽ": "po",
+	"ゔ": "vu",
+	"ぁ": "a", "ぃ": "i", "ぅ": "u", "ぇ": "e", "ぉ": "o",
+	"ゃ": "ya", "ゅ": "yu", "ょ": "yo", "ゎ": "wa",
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "しぇ": "she",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "ちぇ": "che",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "じぇ": "je",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"てぃ": "ti", "でぃ": "di", "とぅ": "tu", "どぅ": "du",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo",
+	"ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+}
+
+// asciiPunctuation are the Japanese punctuations with their ASCII counterparts
+var asciiPunctuation = map[rune]string{
+	'、': ",", '。': ".", '・': " ", '〜': "~",
+	'「': "(", '」': ")", '『': "(", '』': ")", '【': "(", '】': ")", '〔': "(", '〕': ")",
+	'“': "\"", '”': "\"", '‘': "'", '’': "'",
+}
+
+// filenameSpaces removes the spaces around the underscores
+var filenameSpaces = strings.NewReplacer(" _", "_", "_ ", "_")
+
+// ValidFilenameCharset returns true if the charset is empty (the names as they are) or known
+func ValidFilenameCharset(charset string) bool {
+	switch charset {
+	case "", FilenameCharsetASCII, FilenameCharsetNoEmoji:
+		return true
+	}
+	return false
+}
+
+// normalizeFilename returns the file name in the charset, or as it is if the charset is empty
+func normalizeFilename(name, charset string) string {
+	switch charset {
+	case FilenameCharsetASCII:
+		name = toASCII(name)
+	case FilenameCharsetNoEmoji:
+		name = strings.Map(func(r rune) rune {
+			if isEmoji(r) {
+				return -1
+			}
+			return r
+		}, name)
+	default:
+		return name
+	}
+	// the dropped characters leave the spaces around them, also next to the separators of the template
+	return filenameSpaces.Replace(strings.Join(strings.Fields(name), " "))
+}
+
+// toASCII transliterates the kana to romaji and the full-width characters to ASCII, dropping the rest
+func toASCII(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		// katakana to hiragana to share the table
+		if r >= 'ァ' && r <= 'ヶ' {
+			runes[i] = r - 0x60
+		}
+	}
+	var b strings.Builder
+	double := false // the small tsu doubles the next consonant
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		var ro string
+		if i+1 < len(runes) {
+			ro = romaji[string(runes[i:i+2])]
+		}
+		if ro != "" {
+			i++
+		} else {
+			ro = romaji[string(r)]
+		}
+		switch {
+		case ro != "":
+			if double {
+				b.WriteByte(ro[0])
+				double = false
+			}
+			b.WriteString(ro)
+			continue
+		case r == 'っ':
+			double = true
+			continue
+		case r == 'ー':
+			// the long vowel repeats the last one
+			if out := b.String(); out != "" && strings.ContainsRune("aiueo", rune(out[len(out)-1])) {
+				b.WriteByte(out[len(out)-1])
+			}
+		case r == '　':
+			b.WriteByte(' ')
+		case r >= '！' && r <= '～':
+			b.WriteRune(r - 0xFEE0)
+		case r <= unicode.MaxASCII:
+			b.WriteRune(r)
+		default:
+			if p, ok := asciiPunctuation[r]; ok {
+				b.WriteString(p)
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		double = false
+	}
+	return b.String()
+}
+
+// isEmoji returns true if r is an emoji, a pictograph, or a modifier of them
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // the emoji, including the flags and the skin tones
+	case r >= 0x2600 && r <= 0x27BF: // the miscellaneous symbols and the dingbats
+	case r >= 0x2300 && r <= 0x23FF: // the technical symbols, e.g., ⌚
+	case r >= 0x2B00 && r <= 0x2BFF: // the arrows and the stars, e.g., ⭐
+	case r >= 0xFE00 && r <= 0xFE0F: // the variation selectors
+	case r >= 0xE0020 && r <= 0xE007F: // the tags of the subdivision flags
+	case r == 0x200D || r == 0x20E3: // the zero width joiner and the keycap
+	default:
+		return false
+	}
+	return true
+}
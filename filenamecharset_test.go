@@ -0,0 +1,42 @@
+package radikron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeFilename(t *testing.T) {
+	tests := []struct {
+		name, charset, want string
+	}{
+		{"2023-06-05-1300_FMT_山下達郎のサンデー・ソングブック", "", "2023-06-05-1300_FMT_山下達郎のサンデー・ソングブック"},
+		{"2023-06-05-1300_FMT_山下達郎のサンデー・ソングブック", FilenameCharsetASCII, "2023-06-05-1300_FMT_nosandee songubukku"},
+		{"ヤマタツ　ＳＨＯＷ！", FilenameCharsetASCII, "yamatatsu SHOW!"},
+		{"きゃりーぱみゅぱみゅ【生】", FilenameCharsetASCII, "kyariipamyupamyu( )"},
+		{"ちょっとティータイム", FilenameCharsetASCII, "chottotiitaimu"},
+		{"Music ☆ Night ♪🎵", FilenameCharsetNoEmoji, "Music Night"},
+		{"👍🏽東京 ⭐️", FilenameCharsetNoEmoji, "東京"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.charset, func(t *testing.T) {
+			if got := normalizeFilename(tt.name, tt.charset); got != tt.want {
+				t.Errorf("normalizeFilename(%q, %q) = %q, want %q", tt.name, tt.charset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputFileBaseName_FilenameCharset(t *testing.T) {
+	start := time.Date(2023, 6, 5, 13, 0, 0, 0, Location)
+	prog := &Prog{StationID: "FMT", Title: "シティポップ☆", FilenameCharset: FilenameCharsetASCII}
+	name, err := (&Asset{}).outputFileBaseName(prog, prog.Title, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Format(OutputDatetimeLayout) + "_FMT_shitipoppu"; name != want {
+		t.Errorf("expected %s, got %s", want, name)
+	}
+	if prog.Title != "シティポップ☆" {
+		t.Errorf("expected the title to be kept, got %s", prog.Title)
+	}
+}
@@ -0,0 +1,208 @@
+package radikron
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fileServerShutdownTimeout is how long the file server waits for the requests in flight on shutdown
+const fileServerShutdownTimeout = 5 * time.Second
+
+// fileListingTemplate renders the listing of a directory of the downloads
+var fileListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Path}}</title></head>
+<body>
+<h1>{{.Path}}</h1>
+<ul>
+{{- if ne .Path "/"}}
+<li><a href="../">../</a></li>
+{{- end}}
+{{- range .Entries}}
+<li><a href="{{.Href}}">{{.Name}}</a>{{if not .Dir}} ({{.SizeMB}} MB, {{.Modified}}){{end}}</li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// fileListingEntry is a file or a directory in the listing
+type fileListingEntry struct {
+	Name     string
+	Href     string
+	Dir      bool
+	SizeMB   string
+	Modified string
+}
+
+// fileServerAuthorized returns true if the request bears the file server credentials of the asset in its context
+// by the basic auth, which the browsers and the players of the phones prompt for
+func fileServerAuthorized(r *http.Request) bool {
+	asset := GetAsset(r.Context())
+	if asset == nil || asset.FileServerPassword == "" {
+		return false
+	}
+	wantUser, wantPassword := asset.FileServerUser, asset.FileServerPassword
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(wantPassword)) == 1
+	return userOK && passwordOK
+}
+
+// NewFileServerHandler returns the read-only handler of the downloads under "/files/", so that the recordings
+// can be streamed on the LAN: the files support range requests, and the directories are listed only if enabled;
+// the hidden files and the downloads in progress are never served.
+// The downloads, the credentials, and the listing are of the asset of current, e.g., StatusAsset.
+func NewFileServerHandler(current func() *Asset) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files/{path...}", serveArchiveFile)
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/files/", http.StatusFound)
+	})
+	return withAsset(current, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fileServerAuthorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="radikron", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func serveArchiveFile(w http.ResponseWriter, r *http.Request) {
+	rel := r.PathValue("path")
+	if rel != "" && (!filepath.IsLocal(filepath.FromSlash(rel)) || hasUnservedElement(rel)) {
+		http.NotFound(w, r)
+		return
+	}
+	// the authorized requests have the asset in their context
+	asset := GetAsset(r.Context())
+	listing := asset.FileServerListing
+	downloadsDir, err := getRadicronPath(asset.DownloadDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(downloadsDir, filepath.FromSlash(rel)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if info.IsDir() {
+		if !listing {
+			http.NotFound(w, r)
+			return
+		}
+		// the links of the listing are relative to the directory
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		serveListing(w, f, "/"+rel)
+		return
+	}
+	if isAudioOutput(rel) {
+		w.Header().Set("Content-Type", audioMIMEType(rel))
+	}
+	// ServeContent handles the range requests of the players seeking the recordings
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func serveListing(w http.ResponseWriter, dir *os.File, dirPath string) {
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	listed := []fileListingEntry{}
+	for _, entry := range entries {
+		if isUnservedName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		e := fileListingEntry{Name: entry.Name(), Href: "./" + (&url.URL{Path: entry.Name()}).EscapedPath(), Dir: entry.IsDir()}
+		if e.Dir {
+			e.Name += "/"
+			e.Href += "/"
+		} else {
+			e.SizeMB = fmt.Sprintf("%.1f", float64(info.Size())/Kilobytes/Kilobytes)
+			e.Modified = info.ModTime().In(Location).Format(time.DateTime)
+		}
+		listed = append(listed, e)
+	}
+	// the directories first, then the files by name
+	slices.SortStableFunc(listed, func(a, b fileListingEntry) int {
+		if a.Dir != b.Dir {
+			if a.Dir {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = fileListingTemplate.Execute(w, struct {
+		Path    string
+		Entries []fileListingEntry
+	}{path.Clean(dirPath), listed})
+}
+
+// hasUnservedElement returns true if an element of the slash-separated path is never served
+func hasUnservedElement(rel string) bool {
+	for _, elem := range strings.Split(rel, "/") {
+		if isUnservedName(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnservedName returns true if the file is hidden or a download in progress written as "<name>.<ext>.part"
+func isUnservedName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, PartFileExt)
+}
+
+// ServeFiles serves the downloads of the asset of current read-only on addr until ctx is done
+func ServeFiles(ctx context.Context, addr string, current func() *Asset) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           NewFileServerHandler(current),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), fileServerShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
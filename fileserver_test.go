@@ -0,0 +1,96 @@
+package radikron
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileServerHandler(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	downloads := filepath.Join(home, "downloads")
+	for name, content := range map[string]string{
+		"citypop/2023-06-05-0100_TBS_Show.mp3":      "0123456789",
+		"citypop/.tmp/partial.aac":                  "partial",
+		".hidden.mp3":                               "hidden",
+		"citypop/2023-06-12-0100_TBS_Show.mp3.part": "partial",
+	} {
+		path := filepath.Join(downloads, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	asset := &Asset{DownloadDir: "downloads", FileServerUser: DefaultFileServerUser}
+	handler := NewFileServerHandler(func() *Asset { return asset })
+	request := func(path, user, password, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	const file = "/files/citypop/2023-06-05-0100_TBS_Show.mp3"
+
+	// never served without a password
+	if rec := request(file, DefaultFileServerUser, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the password set, got %d", rec.Code)
+	}
+	asset.FileServerPassword = "secret"
+	rec := request(file, DefaultFileServerUser, "wrong", "")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected 401 with the challenge for a wrong password, got %d", rec.Code)
+	}
+	if rec := request(file, "someone", "secret", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong user, got %d", rec.Code)
+	}
+
+	rec = request(file, DefaultFileServerUser, "secret", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("expected the file, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "audio/mpeg" {
+		t.Errorf("expected audio/mpeg, got %q", got)
+	}
+	rec = request(file, DefaultFileServerUser, "secret", "bytes=2-5")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "2345" {
+		t.Errorf("expected the range, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	for _, path := range []string{"/files/.hidden.mp3", "/files/citypop/.tmp/partial.aac",
+		"/files/citypop/2023-06-12-0100_TBS_Show.mp3.part", "/files/missing.mp3", "/files/citypop/"} {
+		if rec := request(path, DefaultFileServerUser, "secret", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", path, rec.Code)
+		}
+	}
+
+	asset.FileServerListing = true
+	rec = request("/files/citypop/", DefaultFileServerUser, "secret", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="./2023-06-05-0100_TBS_Show.mp3"`) {
+		t.Errorf("expected the listing, got %d %q", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), ".tmp") {
+		t.Errorf("expected no hidden entry in the listing, got %q", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), PartFileExt) {
+		t.Errorf("expected no download in progress in the listing, got %q", rec.Body.String())
+	}
+	rec = request("/files/", DefaultFileServerUser, "secret", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="./citypop/"`) || strings.Contains(rec.Body.String(), "hidden") {
+		t.Errorf("expected the root listing, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := request("/files/citypop", DefaultFileServerUser, "secret", ""); rec.Code != http.StatusMovedPermanently {
+		t.Errorf("expected a redirect to the directory, got %d", rec.Code)
+	}
+}
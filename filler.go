@@ -0,0 +1,56 @@
+package radikron
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// fillerTitlesFile is the bundled filler title list in FillerTitlesTXT
+const fillerTitlesFile = "assets/filler-titles.txt"
+
+// LoadFillerTitles returns the filler titles in the list file at path,
+// or the bundled list if path is empty
+func LoadFillerTitles(path string) ([]string, error) {
+	var r io.ReadCloser
+	var err error
+	if path == "" {
+		r, err = FillerTitlesTXT.Open(fillerTitlesFile)
+	} else {
+		r, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the filler title list: %w", err)
+	}
+	defer r.Close()
+	return parseFillerTitles(r)
+}
+
+// parseFillerTitles reads one title per line, skipping blank lines and # comments
+func parseFillerTitles(r io.Reader) ([]string, error) {
+	var titles []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		titles = append(titles, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the filler title list: %w", err)
+	}
+	return titles, nil
+}
+
+// IsFillerProgram returns the filler title the program title contains, if any
+func (a *Asset) IsFillerProgram(p *Prog) (string, bool) {
+	for _, title := range a.FillerTitles {
+		if strings.Contains(p.Title, title) {
+			return title, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,101 @@
+package radikron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestLoadFillerTitles_Bundled(t *testing.T) {
+	titles, err := LoadFillerTitles("")
+	if err != nil {
+		t.Fatalf("LoadFillerTitles failed: %v", err)
+	}
+	found := false
+	for _, title := range titles {
+		if strings.HasPrefix(title, "#") || title == "" {
+			t.Errorf("comment or blank line loaded: %q", title)
+		}
+		if title == "放送休止" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected 放送休止 in the bundled list, got %v", titles)
+	}
+}
+
+func TestLoadFillerTitles_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filler.txt")
+	if err := os.WriteFile(path, []byte("# comment\n\n  休止  \nお知らせ\n"), 0600); err != nil {
+		t.Fatalf("failed to write the list: %v", err)
+	}
+	titles, err := LoadFillerTitles(path)
+	if err != nil {
+		t.Fatalf("LoadFillerTitles failed: %v", err)
+	}
+	if len(titles) != 2 || titles[0] != "休止" || titles[1] != "お知らせ" {
+		t.Errorf("unexpected titles: %v", titles)
+	}
+
+	if _, err := LoadFillerTitles(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing list")
+	}
+}
+
+func TestIsFillerProgram(t *testing.T) {
+	asset := &Asset{FillerTitles: []string{"放送休止", "番組案内"}}
+
+	if filler, ok := asset.IsFillerProgram(&Prog{Title: "放送休止"}); !ok || filler != "放送休止" {
+		t.Errorf("expected 放送休止 to be a filler, got %q, %v", filler, ok)
+	}
+	if _, ok := asset.IsFillerProgram(&Prog{Title: "TBSラジオ 番組案内"}); !ok {
+		t.Error("expected a title containing 番組案内 to be a filler")
+	}
+	if _, ok := asset.IsFillerProgram(&Prog{Title: "シティポップ レイディオ"}); ok {
+		t.Error("expected a regular program not to be a filler")
+	}
+	if _, ok := (&Asset{}).IsFillerProgram(&Prog{Title: "放送休止"}); ok {
+		t.Error("expected no filler without the list")
+	}
+}
+
+func TestDownload_FillerProgram(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	CurrentTime = time.Date(2023, 6, 5, 16, 0, 0, 0, Location)
+
+	asset := &Asset{
+		OutputFormat: radigo.AudioFormatAAC,
+		DownloadDir:  "downloads",
+		Rules:        Rules{},
+		Schedules:    Schedules{},
+		FillerTitles: []string{"放送休止"},
+	}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	prog := &Prog{
+		ID:        "test-filler",
+		StationID: "FMT",
+		Title:     "放送休止",
+		Ft:        "20230605030000",
+		To:        "20230605050000",
+		RuleName:  "broad",
+	}
+	if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+		t.Fatalf("Download should not fail for a filler program: %v", err)
+	}
+	if len(emitter.downloadSkipped) != 1 || emitter.downloadSkipped[0].reason != "filler program" {
+		t.Errorf("expected a filler program skip event, got %+v", emitter.downloadSkipped)
+	}
+	if len(emitter.downloadStarted) != 0 {
+		t.Errorf("expected no download started event, got %d", len(emitter.downloadStarted))
+	}
+}
@@ -0,0 +1,122 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // the logo formats to decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// folderArtFileNames are the artwork files the media servers look up in a folder
+var folderArtFileNames = []string{"folder.jpg", "cover.jpg"}
+
+// logoCacheMu serializes the downloads of the station logos into the cache
+var logoCacheMu sync.Mutex
+
+// writeFolderArt writes the logo of the station as the folder art files missing in dir,
+// keeping the ones already there, e.g., chosen by the user
+func writeFolderArt(ctx context.Context, asset *Asset, stationID, dir string) error {
+	var missing []string
+	for _, name := range folderArtFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); errors.Is(err, os.ErrNotExist) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	logo, err := stationLogo(ctx, asset, stationID)
+	if err != nil {
+		return err
+	}
+	for _, name := range missing {
+		if err := os.WriteFile(filepath.Join(dir, name), logo, OutputFilePermissions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stationLogo returns the logo of the station as JPEG from LogoCacheDirName,
+// downloading and converting it on the first use
+func stationLogo(ctx context.Context, asset *Asset, stationID string) ([]byte, error) {
+	dir, err := getRadicronPath(LogoCacheDirName)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, stationID+".jpg")
+
+	logoCacheMu.Lock()
+	defer logoCacheMu.Unlock()
+	if logo, err := os.ReadFile(path); err == nil {
+		return logo, nil
+	}
+
+	station, ok := asset.Stations[stationID]
+	if !ok || station.LogoURL == "" {
+		return nil, fmt.Errorf("no logo of the station %s", stationID)
+	}
+	logo, err := fetchJPEG(ctx, station.LogoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the logo of %s: %w", stationID, err)
+	}
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		return nil, err
+	}
+	// the partial file must not be taken for the cached logo
+	tmp := path + PartFileExt
+	if err := os.WriteFile(tmp, logo, OutputFilePermissions); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return logo, nil
+}
+
+// fetchJPEG downloads the image at url and converts it to JPEG on a white background,
+// as the logos are transparent PNGs
+func fetchJPEG(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	blob, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(blob))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Over)
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWriteFolderArt(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	logo := image.NewNRGBA(image.Rect(0, 0, 4, 2)) // transparent
+	logo.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_ = png.Encode(w, logo)
+	}))
+	defer server.Close()
+	asset := &Asset{Stations: Stations{"FMT": {LogoURL: server.URL + "/FMT/224x100.png"}}}
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	if err := writeFolderArt(ctx, asset, "FMT", dir); err != nil {
+		t.Fatalf("writeFolderArt failed: %v", err)
+	}
+	folder, err := os.ReadFile(filepath.Join(dir, "folder.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(folder))
+	if err != nil {
+		t.Fatalf("expected a JPEG: %v", err)
+	}
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 2 {
+		t.Errorf("unexpected size %v", img.Bounds())
+	}
+	// the transparent background turns white
+	if r, g, b, _ := img.At(3, 1).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("expected a white background, got %d %d %d", r>>8, g>>8, b>>8)
+	}
+	if cover, _ := os.ReadFile(filepath.Join(dir, "cover.jpg")); !bytes.Equal(cover, folder) {
+		t.Error("expected the same cover.jpg")
+	}
+	// the art is read by the media servers like the outputs
+	for _, name := range []string{"folder.jpg", "cover.jpg"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0044 == 0 {
+			t.Errorf("expected %s readable by the others, got %v", name, info.Mode().Perm())
+		}
+	}
+
+	// the logo is cached, and the art in a folder is kept
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "folder.jpg"), []byte("mine"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFolderArt(ctx, asset, "FMT", other); err != nil {
+		t.Fatalf("writeFolderArt failed: %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected the logo to be downloaded once, got %d requests", requests.Load())
+	}
+	if mine, _ := os.ReadFile(filepath.Join(other, "folder.jpg")); string(mine) != "mine" {
+		t.Error("expected the folder.jpg to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(other, "cover.jpg")); err != nil {
+		t.Errorf("expected the missing cover.jpg: %v", err)
+	}
+
+	if err := writeFolderArt(ctx, asset, "TBS", t.TempDir()); err == nil {
+		t.Error("expected an error for the station without a logo")
+	}
+}
@@ -0,0 +1,97 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// GalleryDirName is the subfolder of the rule folders the program images are saved to
+	GalleryDirName = "images"
+	// GalleryIndexName is the index of the images in GalleryDirName
+	GalleryIndexName = "index.json"
+)
+
+// GalleryEntry is an image in the gallery with the recording it is of
+type GalleryEntry struct {
+	// Image is the file name in GalleryDirName, and Output the file name of the recording in the folder above
+	Image     string `json:"image"`
+	Output    string `json:"output"`
+	ID        string `json:"id,omitempty"`
+	StationID string `json:"station-id"`
+	Title     string `json:"title"`
+	Ft        string `json:"ft"`
+}
+
+// galleryMu serializes the updates of the gallery indexes
+var galleryMu sync.Mutex
+
+// writeGalleryImage saves the image of the program as "<output name>.jpg" into GalleryDirName next to the output
+// and adds it to the index, for the players and the media centers not reading the embedded artwork
+func writeGalleryImage(ctx context.Context, prog *Prog, outputPath string) error {
+	if prog.Img == "" {
+		return nil
+	}
+	dir := filepath.Join(filepath.Dir(outputPath), GalleryDirName)
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		return err
+	}
+	img, err := fetchJPEG(ctx, prog.Img)
+	if err != nil {
+		return err
+	}
+	output := filepath.Base(outputPath)
+	entry := GalleryEntry{
+		Image:     strings.TrimSuffix(output, filepath.Ext(output)) + ".jpg",
+		Output:    output,
+		ID:        prog.ID,
+		StationID: prog.StationID,
+		Title:     prog.Title,
+		Ft:        prog.Ft,
+	}
+	if err := writeFileAtomic(filepath.Join(dir, entry.Image), img, OutputFilePermissions); err != nil {
+		return err
+	}
+
+	galleryMu.Lock()
+	defer galleryMu.Unlock()
+	entries, err := LoadGalleryIndex(dir)
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Image != entry.Image {
+			kept = append(kept, e)
+		}
+	}
+	entries = append(kept, entry)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Ft < entries[j].Ft })
+	blob, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, GalleryIndexName), blob, OutputFilePermissions)
+}
+
+// LoadGalleryIndex returns the images in the gallery dir in the order of the broadcast, or none without an index
+func LoadGalleryIndex(dir string) ([]GalleryEntry, error) {
+	blob, err := os.ReadFile(filepath.Join(dir, GalleryIndexName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []GalleryEntry
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,68 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteGalleryImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	later := &Prog{ID: "2", StationID: "FMT", Title: "Later", Ft: "20230612100000", Img: server.URL + "/later.jpg"}
+	if err := writeGalleryImage(ctx, later, filepath.Join(dir, "20230612100000-Later.mp3")); err != nil {
+		t.Fatalf("writeGalleryImage failed: %v", err)
+	}
+	earlier := &Prog{ID: "1", StationID: "FMT", Title: "Earlier", Ft: "20230605100000", Img: server.URL + "/earlier.jpg"}
+	for range 2 {
+		if err := writeGalleryImage(ctx, earlier, filepath.Join(dir, "20230605100000-Earlier.mp3")); err != nil {
+			t.Fatalf("writeGalleryImage failed: %v", err)
+		}
+	}
+
+	gallery := filepath.Join(dir, GalleryDirName)
+	img, err := os.ReadFile(filepath.Join(gallery, "20230605100000-Earlier.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(img)); err != nil {
+		t.Errorf("expected a JPEG: %v", err)
+	}
+	entries, err := LoadGalleryIndex(gallery)
+	if err != nil {
+		t.Fatalf("LoadGalleryIndex failed: %v", err)
+	}
+	// once each, in the order of the broadcast
+	if len(entries) != 2 || entries[0].Title != "Earlier" || entries[1].Title != "Later" {
+		t.Fatalf("unexpected index %+v", entries)
+	}
+	if entries[0].Image != "20230605100000-Earlier.jpg" || entries[0].Output != "20230605100000-Earlier.mp3" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+	// the gallery is browsed by the frontends like the outputs
+	for _, name := range []string{"20230605100000-Earlier.jpg", GalleryIndexName} {
+		info, err := os.Stat(filepath.Join(gallery, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0044 == 0 {
+			t.Errorf("expected %s readable by the others, got %v", name, info.Mode().Perm())
+		}
+	}
+
+	// a program without an image is skipped
+	if err := writeGalleryImage(ctx, &Prog{Title: "No image"}, filepath.Join(dir, "x.mp3")); err != nil {
+		t.Errorf("expected no error without an image, got %v", err)
+	}
+}
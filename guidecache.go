@@ -0,0 +1,129 @@
+package radikron
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// guideCacheMu serializes the updates of the guide cache and its index
+var guideCacheMu sync.Mutex
+
+// guideCacheIndex lists the weekly guides in GuideCacheDirName, keyed by the station ID
+type guideCacheIndex struct {
+	Version int                        `json:"version"`
+	Guides  map[string]guideCacheEntry `json:"guides"`
+}
+
+// guideCacheEntry is a weekly guide stored as "<station>.xml.gz"
+type guideCacheEntry struct {
+	Fetched    time.Time `json:"fetched"`
+	Size       int64     `json:"size"`
+	Compressed int64     `json:"compressed"`
+}
+
+// guideCacheDir returns the dir of the cached weekly guides in RADICRON_HOME
+func guideCacheDir() (string, error) {
+	return getRadicronPath(GuideCacheDirName)
+}
+
+// loadGuideCacheIndex reads the index of the guide cache, empty if none
+func loadGuideCacheIndex(dir string) (*guideCacheIndex, error) {
+	index := &guideCacheIndex{Version: GuideCacheVersion, Guides: map[string]guideCacheEntry{}}
+	blob, err := os.ReadFile(filepath.Join(dir, GuideCacheIndexFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var loaded guideCacheIndex
+	if err := json.Unmarshal(blob, &loaded); err != nil || loaded.Version != GuideCacheVersion {
+		// start over from an unreadable index; the guides are fetched again
+		return index, nil
+	}
+	if loaded.Guides != nil {
+		index.Guides = loaded.Guides
+	}
+	return index, nil
+}
+
+// readCachedGuide returns the raw XML of the weekly guide cached within ttl, or nil
+func readCachedGuide(stationID string, ttl time.Duration) []byte {
+	dir, err := guideCacheDir()
+	if err != nil {
+		return nil
+	}
+	guideCacheMu.Lock()
+	index, err := loadGuideCacheIndex(dir)
+	guideCacheMu.Unlock()
+	if err != nil {
+		return nil
+	}
+	entry, ok := index.Guides[stationID]
+	if !ok || time.Since(entry.Fetched) >= ttl {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, stationID+GuideCacheExt))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	if err != nil || int64(len(body)) != entry.Size {
+		return nil
+	}
+	return body
+}
+
+// writeCachedGuide stores the raw XML of the weekly guide compressed and records it in the index
+func writeCachedGuide(stationID string, body []byte, fetched time.Time) error {
+	dir, err := guideCacheDir()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	guideCacheMu.Lock()
+	defer guideCacheMu.Unlock()
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		return fmt.Errorf("failed to create the guide cache: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, stationID+GuideCacheExt), buf.Bytes(), FilePermissions); err != nil {
+		return err
+	}
+	index, err := loadGuideCacheIndex(dir)
+	if err != nil {
+		return err
+	}
+	index.Guides[stationID] = guideCacheEntry{
+		Fetched:    fetched,
+		Size:       int64(len(body)),
+		Compressed: int64(buf.Len()),
+	}
+	blob, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, GuideCacheIndexFileName), blob, FilePermissions)
+}
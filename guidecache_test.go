@@ -0,0 +1,92 @@
+package radikron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGuideCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	body, err := WeeklyProgramTestXML.ReadFile("test/weekly-program-test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCachedGuide(testStationFMT, body, time.Now()); err != nil {
+		t.Fatalf("writeCachedGuide failed: %v", err)
+	}
+
+	dir := filepath.Join(home, GuideCacheDirName)
+	index, err := loadGuideCacheIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := index.Guides[testStationFMT]
+	if !ok {
+		t.Fatalf("expected %s in the index, got %v", testStationFMT, index.Guides)
+	}
+	if entry.Size != int64(len(body)) {
+		t.Errorf("expected size %d, got %d", len(body), entry.Size)
+	}
+	info, err := os.Stat(filepath.Join(dir, testStationFMT+GuideCacheExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Compressed != info.Size() || entry.Compressed >= entry.Size {
+		t.Errorf("expected the guide compressed to %d bytes, got %d of %d", info.Size(), entry.Compressed, entry.Size)
+	}
+
+	if got := readCachedGuide(testStationFMT, time.Hour); string(got) != string(body) {
+		t.Errorf("expected the cached guide, got %d bytes", len(got))
+	}
+	if got := readCachedGuide("TBS", time.Hour); got != nil {
+		t.Error("expected no guide for an uncached station")
+	}
+
+	// expired
+	if err := writeCachedGuide(testStationFMT, body, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readCachedGuide(testStationFMT, time.Hour); got != nil {
+		t.Error("expected no guide past the TTL")
+	}
+}
+
+func TestFetchWeeklyPrograms_Cached(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{GuideCacheTTL: time.Hour})
+
+	body, err := WeeklyProgramTestXML.ReadFile("test/weekly-program-test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a station no real guide has, so that only the cache can answer
+	if err := writeCachedGuide("CACHED", body, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	progs, err := FetchWeeklyPrograms(ctx, "CACHED")
+	if err != nil {
+		t.Fatalf("expected the cached guide, got %v", err)
+	}
+	if len(progs) != 1 || progs[0].StationID != testStationFMT {
+		t.Errorf("expected the cached program, got %v", progs)
+	}
+}
+
+func TestLoadGuideCacheIndex_Broken(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, GuideCacheIndexFileName), []byte("{"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	index, err := loadGuideCacheIndex(dir)
+	if err != nil {
+		t.Fatalf("expected a broken index to start over, got %v", err)
+	}
+	if len(index.Guides) != 0 {
+		t.Errorf("expected an empty index, got %v", index.Guides)
+	}
+}
@@ -0,0 +1,332 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// historySize is the number of the downloads kept in the history
+const historySize = 1000
+
+// historyMu serializes the updates to the history file
+var historyMu sync.Mutex
+
+// DownloadRecord is a program download in the history
+type DownloadRecord struct {
+	Key       string `json:"key"`
+	StationID string `json:"station-id"`
+	Title     string `json:"title"`
+	Ft        string `json:"ft"`
+	// Pfm, Desc, and Info are the program metadata in plain text for the local search
+	Pfm  string `json:"pfm,omitempty"`
+	Desc string `json:"desc,omitempty"`
+	Info string `json:"info,omitempty"`
+	// Path is the output of the program, with the transcript next to it if any
+	Path    string    `json:"path,omitempty"`
+	Started time.Time `json:"started"`
+	// Seconds is how long the segments took to download
+	Seconds float64 `json:"seconds"`
+	// Bytes is the size of the segments downloaded, excluding the ones resumed from an earlier run
+	Bytes int64 `json:"bytes"`
+	// Speed is the average download speed in bytes per second
+	Speed float64 `json:"speed"`
+	// Retries is the number of the segment requests retried
+	Retries int `json:"retries"`
+	// Rule is the rule matched the program, and Episode its number in the rule
+	Rule    string `json:"rule,omitempty"`
+	Episode int    `json:"episode,omitempty"`
+}
+
+// FailureRecord counts the failed attempts of a program, e.g., the outputs too small to keep
+type FailureRecord struct {
+	StationID string    `json:"station-id"`
+	Title     string    `json:"title"`
+	Ft        string    `json:"ft"`
+	Attempts  int       `json:"attempts"`
+	Last      time.Time `json:"last"`
+	// GivenUp is true once the attempts reach the asset's MaxProgramAttempts
+	GivenUp bool `json:"given-up,omitempty"`
+}
+
+// historyFile records the recent program downloads, oldest first,
+// so that the chronically slow stations or hours can be told from the data,
+// and the failed attempts of the programs not downloaded yet
+type historyFile struct {
+	Version   int                      `json:"version"`
+	Downloads []DownloadRecord         `json:"downloads"`
+	Failures  map[string]FailureRecord `json:"failures,omitempty"`
+	// Episodes are the episode counters of the rules
+	Episodes map[string]EpisodeCounter `json:"episodes,omitempty"`
+	// Signals are the hourly counts of the radiko responses of the last 30 days
+	Signals []APISignalStats `json:"signals,omitempty"`
+}
+
+// historyPath returns the path of the history file in RADICRON_HOME
+func historyPath() (string, error) {
+	home, err := getRadicronPath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, HistoryFileName), nil
+}
+
+// loadHistoryFile reads the history file, empty if it does not exist
+func loadHistoryFile(path string) (historyFile, error) {
+	f := historyFile{Version: HistoryVersion}
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("failed to read the history: %w", err)
+	}
+	if err := json.Unmarshal(blob, &f); err != nil {
+		return f, fmt.Errorf("failed to parse the history: %w", err)
+	}
+	return f, nil
+}
+
+// LoadHistory returns the recent program downloads in RADICRON_HOME, oldest first
+func LoadHistory() ([]DownloadRecord, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	f, err := loadHistoryFile(path)
+	return f.Downloads, err
+}
+
+// newDownloadRecord returns the record of the program downloaded from started to the output path
+func newDownloadRecord(prog *Prog, path string, started time.Time, bytes int64, retries int) DownloadRecord {
+	rec := DownloadRecord{
+		Key:       programLockKey(prog),
+		StationID: prog.StationID,
+		Title:     prog.Title,
+		Ft:        prog.Ft,
+		Pfm:       prog.Pfm,
+		Desc:      plainText(prog.Desc),
+		Info:      plainText(prog.Info),
+		Path:      path,
+		Started:   started,
+		Seconds:   time.Since(started).Seconds(),
+		Bytes:     bytes,
+		Retries:   retries,
+		Rule:      prog.RuleName,
+		Episode:   prog.Episode,
+	}
+	if rec.Seconds > 0 {
+		rec.Speed = float64(bytes) / rec.Seconds
+	}
+	return rec
+}
+
+// updateHistory applies update to the history file and writes it if update returns true
+func updateHistory(update func(f *historyFile) bool) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	f, err := loadHistoryFile(path)
+	if err != nil {
+		return err
+	}
+	if !update(&f) {
+		return nil
+	}
+	f.Version = HistoryVersion
+
+	if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+		return fmt.Errorf("failed to create RADICRON_HOME: %w", err)
+	}
+	blob, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, blob, FilePermissions)
+}
+
+// recordDownload appends the download to the history, replacing an earlier record of the program
+func recordDownload(rec DownloadRecord) error {
+	return updateHistory(func(f *historyFile) bool {
+		f.Downloads = appendDownloadRecord(f.Downloads, rec)
+		releaseEpisode(f, rec.Rule, rec.Key)
+		return true
+	})
+}
+
+// appendDownloadRecord appends rec to the downloads, dropping an earlier record of the program and the oldest ones
+func appendDownloadRecord(records []DownloadRecord, rec DownloadRecord) []DownloadRecord {
+	downloads := make([]DownloadRecord, 0, len(records)+1)
+	for _, d := range records {
+		if d.Key != rec.Key {
+			downloads = append(downloads, d)
+		}
+	}
+	downloads = append(downloads, rec)
+	if len(downloads) > historySize {
+		downloads = downloads[len(downloads)-historySize:]
+	}
+	return downloads
+}
+
+// relocateDownloadRecords updates the path of the downloads saved to oldPath, e.g., moved to the folder of the rule
+func relocateDownloadRecords(oldPath, newPath string) error {
+	return updateHistory(func(f *historyFile) bool {
+		updated := false
+		for i := range f.Downloads {
+			if f.Downloads[i].Path == oldPath {
+				f.Downloads[i].Path = newPath
+				updated = true
+			}
+		}
+		return updated
+	})
+}
+
+// recordFailedAttempt counts a failed attempt of the program and gives it up
+// once the attempts reach maxAttempts (0 for never). Returns the updated record.
+func recordFailedAttempt(prog *Prog, maxAttempts int) (FailureRecord, error) {
+	var rec FailureRecord
+	err := updateHistory(func(f *historyFile) bool {
+		if f.Failures == nil {
+			f.Failures = map[string]FailureRecord{}
+		}
+		// the programs out of timefree are never attempted again
+		for k, r := range f.Failures {
+			if time.Since(r.Last) > TimefreeWindow {
+				delete(f.Failures, k)
+			}
+		}
+		key := programLockKey(prog)
+		rec = f.Failures[key]
+		rec.StationID, rec.Title, rec.Ft = prog.StationID, prog.Title, prog.Ft
+		rec.Attempts++
+		rec.Last = time.Now()
+		rec.GivenUp = maxAttempts > 0 && rec.Attempts >= maxAttempts
+		f.Failures[key] = rec
+		if rec.GivenUp {
+			releaseEpisode(f, prog.RuleName, key)
+		}
+		return true
+	})
+	return rec, err
+}
+
+// clearFailedAttempts forgets the failed attempts of the program once it is downloaded
+func clearFailedAttempts(prog *Prog) error {
+	return updateHistory(func(f *historyFile) bool {
+		key := programLockKey(prog)
+		if _, ok := f.Failures[key]; !ok {
+			return false
+		}
+		delete(f.Failures, key)
+		return true
+	})
+}
+
+// hasGivenUp returns true if the program is given up after its failed attempts
+func hasGivenUp(prog *Prog) bool {
+	path, err := historyPath()
+	if err != nil {
+		return false
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	f, err := loadHistoryFile(path)
+	if err != nil {
+		return false
+	}
+	return f.Failures[programLockKey(prog)].GivenUp
+}
+
+// countFailedAttempt records a failed attempt of the program,
+// dropping it from the queue and reporting it once it is given up
+func countFailedAttempt(ctx context.Context, prog *Prog) {
+	maxAttempts := 0
+	if asset := GetAsset(ctx); asset != nil {
+		maxAttempts = asset.MaxProgramAttempts
+	}
+	rec, err := recordFailedAttempt(prog, maxAttempts)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to record the failed attempt of [%s]%s: %v", prog.StationID, prog.Title, err))
+		return
+	}
+	if !rec.GivenUp {
+		emitLogMessage(ctx, "info", fmt.Sprintf("attempt %d of [%s]%s failed", rec.Attempts, prog.StationID, prog.Title))
+		return
+	}
+	dequeueProgram(ctx, prog)
+	emitProgramFailed(ctx, prog.StationID, prog.Title, prog.Ft, rec.Attempts)
+}
+
+// logDownloadRecord records the download and logs its bandwidth
+func logDownloadRecord(ctx context.Context, rec DownloadRecord) {
+	if err := recordDownload(rec); err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to record the download of [%s]%s: %v", rec.StationID, rec.Title, err))
+		return
+	}
+	emitLogMessage(ctx, "info", fmt.Sprintf("downloaded [%s]%s in %.0fs at %.1f KB/s with %d retries",
+		rec.StationID, rec.Title, rec.Seconds, rec.Speed/Kilobytes, rec.Retries))
+}
+
+// BandwidthStats summarizes the downloads of a station at an hour of the broadcast
+type BandwidthStats struct {
+	StationID string  `json:"station-id"`
+	Hour      int     `json:"hour"`
+	Downloads int     `json:"downloads"`
+	Speed     float64 `json:"speed"`
+	Retries   int     `json:"retries"`
+}
+
+// BandwidthReport returns the average speed and the total retries of the downloads
+// per station and broadcast hour, the slowest first
+func BandwidthReport(records []DownloadRecord) []BandwidthStats {
+	type group struct {
+		stationID string
+		hour      int
+	}
+	stats := map[group]*BandwidthStats{}
+	for _, r := range records {
+		ft, err := ParseDatetime(r.Ft)
+		if err != nil {
+			continue
+		}
+		g := group{r.StationID, ft.Hour()}
+		s, ok := stats[g]
+		if !ok {
+			s = &BandwidthStats{StationID: r.StationID, Hour: ft.Hour()}
+			stats[g] = s
+		}
+		// the running mean of the speeds
+		s.Downloads++
+		s.Speed += (r.Speed - s.Speed) / float64(s.Downloads)
+		s.Retries += r.Retries
+	}
+
+	report := make([]BandwidthStats, 0, len(stats))
+	for _, s := range stats {
+		report = append(report, *s)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Speed != report[j].Speed {
+			return report[i].Speed < report[j].Speed
+		}
+		if report[i].StationID != report[j].StationID {
+			return report[i].StationID < report[j].StationID
+		}
+		return report[i].Hour < report[j].Hour
+	})
+	return report
+}
@@ -0,0 +1,161 @@
+package radikron
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecordDownload(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+
+	prog := &Prog{ID: "1", StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"}
+	rec := newDownloadRecord(prog, "", time.Now().Add(-10*time.Second), 1000*Kilobytes, 3)
+	if rec.Seconds < 10 || rec.Speed <= 0 || rec.Speed > 100*Kilobytes {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	if err := recordDownload(rec); err != nil {
+		t.Fatalf("recordDownload failed: %v", err)
+	}
+	// downloaded again
+	rec.Retries = 0
+	if err := recordDownload(rec); err != nil {
+		t.Fatal(err)
+	}
+	other := newDownloadRecord(&Prog{ID: "2", StationID: "TBS", Ft: "20230605150000"}, "", time.Now(), 0, 0)
+	if err := recordDownload(other); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 records, got %+v", history)
+	}
+	if history[0].Key != rec.Key || history[0].Retries != 0 || history[1].StationID != "TBS" {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
+
+func TestLoadHistory_Empty(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	history, err := LoadHistory()
+	if err != nil || len(history) != 0 {
+		t.Errorf("expected no history, got %v, %v", history, err)
+	}
+}
+
+func TestBandwidthReport(t *testing.T) {
+	records := []DownloadRecord{
+		{StationID: "FMT", Ft: "20230605130000", Speed: 300, Retries: 1},
+		{StationID: "FMT", Ft: "20230606133000", Speed: 100, Retries: 2},
+		{StationID: "FMT", Ft: "20230605220000", Speed: 500},
+		{StationID: "TBS", Ft: "20230605130000", Speed: 50, Retries: 5},
+		{StationID: "TBS", Ft: "invalid", Speed: 1},
+	}
+	report := BandwidthReport(records)
+	want := []BandwidthStats{
+		{StationID: "TBS", Hour: 13, Downloads: 1, Speed: 50, Retries: 5},
+		{StationID: "FMT", Hour: 13, Downloads: 2, Speed: 200, Retries: 3},
+		{StationID: "FMT", Hour: 22, Downloads: 1, Speed: 500},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("expected %d groups, got %+v", len(want), report)
+	}
+	for i := range want {
+		if report[i] != want[i] {
+			t.Errorf("report[%d] = %+v, want %+v", i, report[i], want[i])
+		}
+	}
+}
+
+func TestRecordFailedAttempt(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	emitter := &mockEventEmitter{}
+	asset := &Asset{MaxProgramAttempts: 2}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	prog := &Prog{ID: "1", StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"}
+	countFailedAttempt(ctx, prog)
+	if hasGivenUp(prog) {
+		t.Fatal("expected the program not given up after the first attempt")
+	}
+	if len(emitter.programFailed) != 0 {
+		t.Errorf("expected no program failed event, got %v", emitter.programFailed)
+	}
+
+	countFailedAttempt(ctx, prog)
+	if !hasGivenUp(prog) {
+		t.Fatal("expected the program given up after 2 attempts")
+	}
+	if len(emitter.programFailed) != 1 || emitter.programFailed[0].attempts != 2 {
+		t.Errorf("expected a program failed event after 2 attempts, got %v", emitter.programFailed)
+	}
+
+	if err := clearFailedAttempts(prog); err != nil {
+		t.Fatal(err)
+	}
+	if hasGivenUp(prog) {
+		t.Error("expected the failed attempts to be cleared")
+	}
+}
+
+func TestRecordFailedAttempt_Unlimited(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	prog := &Prog{ID: "1", StationID: "FMT", Title: "Test", Ft: "20230605130000"}
+	for i := 0; i < 10; i++ {
+		rec, err := recordFailedAttempt(prog, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.GivenUp {
+			t.Fatalf("expected never to give up, gave up after %d attempts", rec.Attempts)
+		}
+	}
+}
+
+func TestDownload_GivenUp(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+	emitter := &mockEventEmitter{}
+	asset := &Asset{DownloadDir: "downloads", OutputFormat: "aac", Schedules: Schedules{}}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	prog := &Prog{ID: "1", StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"}
+	if _, err := recordFailedAttempt(prog, 1); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	if err := Download(ctx, &wg, prog); err != nil {
+		t.Fatalf("expected the given-up program to be skipped, got %v", err)
+	}
+	if len(emitter.downloadSkipped) != 1 || emitter.downloadSkipped[0].reason != "given up" {
+		t.Errorf("expected a given up skip, got %v", emitter.downloadSkipped)
+	}
+}
+
+func TestRelocateDownloadRecords(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+
+	prog := &Prog{ID: "1", StationID: "FMT", Title: "Test", Ft: "20230605130000", Desc: "<b>desc</b>"}
+	if err := recordDownload(newDownloadRecord(prog, "/downloads/test.mp3", time.Now(), 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := relocateDownloadRecords("/downloads/test.mp3", "/downloads/citypop/test.mp3"); err != nil {
+		t.Fatalf("relocateDownloadRecords failed: %v", err)
+	}
+	history, err := LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Path != "/downloads/citypop/test.mp3" || history[0].Desc != "desc" {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
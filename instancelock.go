@@ -0,0 +1,79 @@
+package radikron
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrAlreadyRunning is returned by AcquireInstanceLock if another radikron runs against RADICRON_HOME
+	ErrAlreadyRunning = errors.New("another radikron is running")
+	// errInstanceLocked is returned by lockFile if another process holds the lock
+	errInstanceLocked = errors.New("locked by another process")
+)
+
+// InstanceLock is the lock of RADICRON_HOME held by the running radikron
+type InstanceLock struct {
+	f *os.File
+}
+
+// AcquireInstanceLock locks InstanceLockFileName in RADICRON_HOME for the lifetime of the process,
+// so that two radikrons never download the same programs into the same directories.
+// It returns ErrAlreadyRunning with the PID of the other radikron if the lock is held;
+// the OS releases the lock of a radikron which did not exit cleanly
+func AcquireInstanceLock() (*InstanceLock, error) {
+	home, err := getRadicronPath("")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(home, DirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create RADICRON_HOME: %w", err)
+	}
+	path := filepath.Join(home, InstanceLockFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, FilePermissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errInstanceLocked) {
+			return nil, fmt.Errorf("%w against %s (pid %s); stop it first", ErrAlreadyRunning, home, lockHolder(path))
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	// record the PID for the error of the next radikron
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &InstanceLock{f: f}, nil
+}
+
+// Release unlocks RADICRON_HOME
+func (l *InstanceLock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// lockHolder returns the PID recorded in the lock file, or "unknown"
+func lockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	pid := strings.TrimSpace(string(data))
+	if _, err := strconv.Atoi(pid); err != nil {
+		return "unknown"
+	}
+	return pid
+}
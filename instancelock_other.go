@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !windows
+
+package radikron
+
+import "os"
+
+// lockFile is not supported on this platform, so more than one radikron may run
+func lockFile(_ *os.File) error {
+	return nil
+}
+
+// unlockFile is not supported on this platform
+func unlockFile(_ *os.File) error {
+	return nil
+}
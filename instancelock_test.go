@@ -0,0 +1,41 @@
+package radikron
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquireInstanceLock(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" && runtime.GOOS != "windows" {
+		t.Skip("file locks are not supported on this platform")
+	}
+	t.Setenv(EnvRadicronHome, t.TempDir())
+
+	lock, err := AcquireInstanceLock()
+	if err != nil {
+		t.Fatalf("AcquireInstanceLock failed: %v", err)
+	}
+	_, err = AcquireInstanceLock()
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+	if runtime.GOOS != "windows" && !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("expected the PID of the running radikron in %q", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("expected releasing twice to do nothing, got %v", err)
+	}
+	lock, err = AcquireInstanceLock()
+	if err != nil {
+		t.Fatalf("expected the lock acquired again after the release, got %v", err)
+	}
+	defer lock.Release()
+}
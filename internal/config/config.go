@@ -1,11 +1,22 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/iomz/radikron"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
 	"github.com/spf13/viper"
 	"github.com/yyoshiki41/go-radiko"
 	"github.com/yyoshiki41/radigo"
@@ -19,17 +30,84 @@ const (
 	FilePermissions = 0600
 )
 
+// mp3BitratePattern matches the bitrates ffmpeg accepts for -b:a, e.g., 192k or 192000
+var mp3BitratePattern = regexp.MustCompile(`^[1-9][0-9]*k?$`)
+
+// areaIDPattern matches the area IDs of the 47 prefectures, JP1 to JP47
+var areaIDPattern = regexp.MustCompile(`^JP([1-9]|[1-3][0-9]|4[0-7])$`)
+
 // Config holds the application configuration
 type Config struct {
 	AreaID                    string
+	AreaIDs                   []string
+	Include                   []string
 	ExtraStations             []string
 	IgnoreStations            []string
 	FileFormat                string
 	MinimumOutputSize         int64
+	MinimumFreeSpace          int64
+	MinimumSegmentSize        int64
+	DurationTolerance         time.Duration
+	RecoveryScan              string
+	TempCleanupInterval       time.Duration
+	GuideCacheTTL             time.Duration
+	FetchSchedule             string
+	FillerFilter              bool
+	FillerTitlesFile          string
+	FillerTitles              []string
+	TitleAliases              map[string]string
+	NotifyUpcoming            bool
 	DownloadDir               string
+	PreserveTimestamp         bool
+	WriteXattrs               bool
+	FolderArt                 bool
+	ImageGallery              bool
+	ReadOnly                  bool
+	Retry                     radikron.RetryPolicy
+	CoordinationDir           string
+	InstanceID                string
+	CoordinationLease         time.Duration
+	RequestsPerSecond         float64
+	ThrottleSchedule          []radikron.ThrottleWindow
+	Proxy                     string
+	Storage                   string
 	Rules                     radikron.Rules
+	BaseRules                 radikron.Rules // the rules without the profiles, which Rules are built from
+	Profiles                  []Profile
+	ActiveProfiles            []string
 	MaxDownloadingConcurrency int
 	MaxEncodingConcurrency    int
+	DeferredEncoding          bool
+	EncodingWindow            *radikron.ThrottleWindow
+	QuietHours                []radikron.ThrottleWindow
+	AdBreakChapters           bool
+	PremiumMaxStreams         int
+	PremiumMail               string
+	PremiumPass               string
+	MaxProgramAttempts        int
+	DownloadStallTimeout      time.Duration
+	FFmpegPath                string
+	FFmpegArgs                []string
+	DuplicateScan             string
+	MP3Bitrate                string
+	MP3Quality                *int
+	Sidecars                  []string
+	TagTemplates              map[string]string
+	DesktopNotifications      bool
+	NotificationTemplates     map[string]map[string]string
+	FilenameTemplate          string
+	TranscriptionURL          string
+	FeedListen                string
+	FeedBaseURL               string
+	Feeds                     map[string][]string
+	WebhookListen             string
+	WebhookToken              string
+	FileServerListen          string
+	FileServerUser            string
+	FileServerPassword        string
+	FileServerListing         bool
+	MaxLiveRecordings         int
+	StationDirs               map[string]string
 }
 
 // LoadConfig loads and validates configuration from the specified file
@@ -44,7 +122,8 @@ func LoadConfig(filename string) (*Config, error) {
 		os.Setenv(radikron.EnvRadicronHome, filepath.Join(cwd, "radiko"))
 	}
 
-	// Configure viper
+	// Configure a clean viper, not to keep the keys of the last load, e.g., the rules set by parseRuleFromMap
+	viper.Reset()
 	if err := setupViper(filename, cwd); err != nil {
 		return nil, err
 	}
@@ -54,14 +133,41 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, fmt.Errorf("error reading config: %w", err)
 	}
 
+	// Merge the included files, rejecting the unknown keys in any of them, which viper would ignore
+	files, err := ConfigFiles(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	schemas := make([]configSchema, 0, len(files))
+	var keyErrs []error
+	for _, file := range files {
+		schema, err := loadSchema(file)
+		if err != nil {
+			return nil, err
+		}
+		keyErrs = append(keyErrs, validateKeys(file, schema))
+		schemas = append(schemas, configSchema{file: file, root: schema})
+	}
+	if err := errors.Join(keyErrs...); err != nil {
+		return nil, err
+	}
+	include := viper.GetStringSlice("include")
+	if err := mergeConfigFiles(files); err != nil {
+		return nil, err
+	}
+	configFiles = files
+
 	// Set defaults
 	setDefaults()
 
 	// Validate and build config
-	cfg := &Config{}
+	cfg := &Config{Include: include}
 	if err := cfg.buildConfig(); err != nil {
 		return nil, err
 	}
+	if err := validateConfigRules(schemas, cfg); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
@@ -70,16 +176,114 @@ func LoadConfig(filename string) (*Config, error) {
 func (c *Config) ApplyToAsset(asset *radikron.Asset) error {
 	asset.OutputFormat = c.FileFormat
 	asset.MinimumOutputSize = c.MinimumOutputSize
+	asset.MinimumFreeSpace = c.MinimumFreeSpace
+	asset.MinimumSegmentSize = c.MinimumSegmentSize
+	asset.DurationTolerance = c.DurationTolerance
+	asset.RecoveryScan = c.RecoveryScan
+	asset.TempCleanupInterval = c.TempCleanupInterval
+	asset.FetchSchedule = nil
+	if c.FetchSchedule != "" {
+		schedule, err := radikron.ParseCronSchedule(c.FetchSchedule)
+		if err != nil {
+			return fmt.Errorf("fetch-schedule: %w", err)
+		}
+		asset.FetchSchedule = schedule
+	}
+	asset.FillerTitles = c.FillerTitles
+	asset.NotifyUpcoming = c.NotifyUpcoming
 	asset.DownloadDir = c.DownloadDir
+	asset.PreserveTimestamp = c.PreserveTimestamp
+	asset.WriteXattrs = c.WriteXattrs
+	asset.FolderArt = c.FolderArt
+	asset.ImageGallery = c.ImageGallery
+	asset.ReadOnly = c.ReadOnly
+	asset.CoordinationDir = c.CoordinationDir
+	asset.InstanceID = c.InstanceID
+	asset.CoordinationLease = c.CoordinationLease
+	asset.RequestsPerSecond = c.RequestsPerSecond
+	asset.ThrottleSchedule = c.ThrottleSchedule
 	asset.MaxDownloadingConcurrency = c.MaxDownloadingConcurrency
 	asset.MaxEncodingConcurrency = c.MaxEncodingConcurrency
-	asset.LoadAvailableStations(c.AreaID)
+	asset.DeferredEncoding = c.DeferredEncoding
+	asset.EncodingWindow = c.EncodingWindow
+	asset.QuietHours = c.QuietHours
+	asset.AdBreakChapters = c.AdBreakChapters
+	asset.PremiumMaxStreams = c.PremiumMaxStreams
+	asset.PremiumMail = c.PremiumMail
+	premiumPass, err := radikron.ResolveSecret(c.PremiumPass)
+	if err != nil {
+		return fmt.Errorf("premium-pass: %w", err)
+	}
+	asset.PremiumPass = premiumPass
+	asset.MaxProgramAttempts = c.MaxProgramAttempts
+	asset.DownloadStallTimeout = c.DownloadStallTimeout
+	asset.FFmpegPath = c.FFmpegPath
+	asset.FFmpegArgs = c.FFmpegArgs
+	asset.DuplicateScan = c.DuplicateScan
+	asset.MP3Bitrate = c.MP3Bitrate
+	asset.MP3Quality = c.MP3Quality
+	asset.Sidecars = c.Sidecars
+	asset.TranscriptionURL = c.TranscriptionURL
+	asset.FeedListen = c.FeedListen
+	asset.WebhookListen = c.WebhookListen
+	asset.FileServerListen = c.FileServerListen
+	asset.MaxLiveRecordings = c.MaxLiveRecordings
+	asset.StationDirs = c.StationDirs
+	asset.LoadAvailableStations(c.AreaIDs...)
 	asset.AddExtraStations(c.ExtraStations)
 	asset.RemoveIgnoreStations(c.IgnoreStations)
 	asset.Rules = c.Rules
 
 	// Initialize semaphores with the configured concurrency values
 	radikron.InitSemaphores(asset)
+	radikron.InitRateLimiter(asset)
+	radikron.WatchThrottleSchedule(asset)
+	radikron.WatchTempCleanup(asset)
+	asset.Retry = c.Retry
+	asset.GuideCacheTTL = c.GuideCacheTTL
+	if err := asset.SetTitleAliases(c.TitleAliases); err != nil {
+		return err
+	}
+	if err := asset.SetTagTemplates(c.TagTemplates); err != nil {
+		return err
+	}
+	if err := asset.SetNotificationTemplates(c.NotificationTemplates); err != nil {
+		return err
+	}
+	if err := asset.SetFilenameTemplate(c.FilenameTemplate); err != nil {
+		return err
+	}
+	if err := asset.SetFeeds(c.FeedURLBase(), c.Feeds); err != nil {
+		return err
+	}
+	webhookToken, err := radikron.ResolveSecret(c.WebhookToken)
+	if err != nil {
+		return fmt.Errorf("webhook-token: %w", err)
+	}
+	asset.WebhookToken = webhookToken
+	fileServerPassword, err := radikron.ResolveSecret(c.FileServerPassword)
+	if err != nil {
+		return fmt.Errorf("file-server-password: %w", err)
+	}
+	asset.FileServerUser = c.FileServerUser
+	asset.FileServerPassword = fileServerPassword
+	asset.FileServerListing = c.FileServerListing
+	proxy, err := radikron.ResolveSecret(c.Proxy)
+	if err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := asset.SetProxy(proxy); err != nil {
+		if radikron.IsSecretRef(c.Proxy) {
+			// do not leak the secret in the error
+			return fmt.Errorf("invalid proxy in %s", c.Proxy)
+		}
+		return err
+	}
+	storage, err := c.storage()
+	if err != nil {
+		return err
+	}
+	asset.Storage = storage
 
 	// Build a set of existing stations for faster lookup
 	existingStations := make(map[string]bool)
@@ -100,9 +304,18 @@ func (c *Config) ApplyToAsset(asset *radikron.Asset) error {
 	return nil
 }
 
-// setupViper configures the viper instance with the config file path
+// Summary returns the effective configuration applied to the asset
+func (c *Config) Summary(asset *radikron.Asset) radikron.ConfigSummary {
+	return radikron.NewConfigSummary(asset, strings.Join(c.AreaIDs, ","))
+}
+
+// setupViper configures the viper instance with the config file path.
+// The default names fall back to any config.{yml,yaml,toml,json} in cwd when the file does not exist.
 func setupViper(filename, cwd string) error {
-	if filename != "config.yml" && filename != "config.toml" {
+	isDefault := filename == "config.yml" || filename == "config.toml"
+	if _, err := os.Stat(filepath.Join(cwd, filename)); err == nil && isDefault {
+		viper.SetConfigFile(filepath.Join(cwd, filename))
+	} else if !isDefault {
 		configPath, err := filepath.Abs(filename)
 		if err != nil {
 			return fmt.Errorf("invalid config path: %w", err)
@@ -128,51 +341,460 @@ func setDefaults() {
 	viper.SetDefault("ignore-stations", []string{})
 	viper.SetDefault("file-format", radigo.AudioFormatAAC)
 	viper.SetDefault("minimum-output-size", radikron.DefaultMinimumOutputSize)
-	viper.SetDefault("downloads", "downloads")
+	viper.SetDefault("minimum-free-space", radikron.DefaultMinimumFreeSpace)
+	viper.SetDefault("minimum-segment-size", 0)
+	viper.SetDefault("duration-tolerance", radikron.DefaultDurationTolerance)
+	viper.SetDefault("recovery-scan", radikron.RecoveryScanQuarantine)
+	viper.SetDefault("tmp-cleanup-interval", 0)
+	viper.SetDefault("guide-cache-ttl", 0)
+	viper.SetDefault("fetch-schedule", "")
+	viper.SetDefault("filler-filter", true)
+	viper.SetDefault("filler-titles-file", "")
+	viper.SetDefault("downloads", radikron.DefaultDownloadDir)
+	viper.SetDefault("preserve-timestamp", false)
+	viper.SetDefault("write-xattrs", false)
+	viper.SetDefault("folder-art", false)
+	viper.SetDefault("image-gallery", false)
+	viper.SetDefault("read-only", false)
+	viper.SetDefault("notify-upcoming", false)
+	viper.SetDefault("retry-max-attempts", radikron.MaxRetryAttempts)
+	viper.SetDefault("retry-initial-delay", radikron.DefaultRetryInitialDelay)
+	viper.SetDefault("retry-max-delay", radikron.DefaultRetryMaxDelay)
+	viper.SetDefault("retry-multiplier", radikron.DefaultRetryMultiplier)
+	viper.SetDefault("retry-jitter", radikron.DefaultRetryJitter)
+	viper.SetDefault("coordination-dir", "")
+	viper.SetDefault("instance-id", defaultInstanceID())
+	viper.SetDefault("coordination-lease", radikron.DefaultCoordinationLease)
+	viper.SetDefault("requests-per-second", radikron.DefaultRequestsPerSecond)
+	viper.SetDefault("proxy", "")
+	viper.SetDefault("storage", "")
 	viper.SetDefault("max-downloading-concurrency", radikron.MaxDownloadingConcurrency)
 	viper.SetDefault("max-encoding-concurrency", radikron.MaxEncodingConcurrency)
+	viper.SetDefault("deferred-encoding", false)
+	viper.SetDefault("encoding-window", "")
+	viper.SetDefault("quiet-hours", []string{})
+	viper.SetDefault("ad-break-chapters", false)
+	viper.SetDefault("premium-max-streams", radikron.DefaultPremiumMaxStreams)
+	viper.SetDefault("max-program-attempts", radikron.DefaultMaxProgramAttempts)
+	viper.SetDefault("download-stall-timeout", radikron.DefaultDownloadStallTimeout)
+	viper.SetDefault("ffmpeg-path", "")
+	viper.SetDefault("ffmpeg-args", []string{})
+	viper.SetDefault("duplicate-scan", radikron.DuplicateScanAll)
+	viper.SetDefault("write-sidecars", []string{})
+	viper.SetDefault("transcription-url", "")
+	viper.SetDefault("feed-listen", "")
+	viper.SetDefault("feed-base-url", "")
+	viper.SetDefault("webhook-listen", "")
+	viper.SetDefault("webhook-token", "")
+	viper.SetDefault("file-server-listen", "")
+	viper.SetDefault("file-server-user", radikron.DefaultFileServerUser)
+	viper.SetDefault("file-server-password", "")
+	viper.SetDefault("file-server-listing", false)
+	viper.SetDefault("max-live-recordings", 0)
+	viper.SetDefault("desktop-notifications", false)
+	viper.SetDefault("filename-template", radikron.DefaultFilenameTemplate)
 }
 
 // buildConfig builds the Config struct from viper values
 func (c *Config) buildConfig() error {
 	// Validate file format
 	fileFormat := viper.GetString("file-format")
-	if fileFormat != radigo.AudioFormatAAC && fileFormat != radigo.AudioFormatMP3 {
+	if fileFormat != radigo.AudioFormatAAC && fileFormat != radigo.AudioFormatMP3 && fileFormat != radikron.AudioFormatM4A {
 		return fmt.Errorf("unsupported audio format: %s", fileFormat)
 	}
 
 	c.FileFormat = fileFormat
-	c.AreaID = viper.GetString("area-id")
+	areaIDs, err := loadAreaIDs()
+	if err != nil {
+		return err
+	}
+	c.AreaIDs = areaIDs
+	c.AreaID = areaIDs[0]
 	c.ExtraStations = viper.GetStringSlice("extra-stations")
 	c.IgnoreStations = viper.GetStringSlice("ignore-stations")
 	c.MinimumOutputSize = viper.GetInt64("minimum-output-size") * radikron.Kilobytes * radikron.Kilobytes
+	c.MinimumFreeSpace = viper.GetInt64("minimum-free-space") * radikron.Kilobytes * radikron.Kilobytes
+	if c.MinimumFreeSpace < 0 {
+		return fmt.Errorf("minimum-free-space must not be negative: %v", viper.GetInt64("minimum-free-space"))
+	}
+	c.MinimumSegmentSize = viper.GetInt64("minimum-segment-size") * radikron.Kilobytes
+	if c.MinimumSegmentSize < 0 {
+		return fmt.Errorf("minimum-segment-size must not be negative: %v", viper.GetInt64("minimum-segment-size"))
+	}
+	c.DurationTolerance = viper.GetDuration("duration-tolerance")
+	if c.DurationTolerance < 0 {
+		return fmt.Errorf("duration-tolerance must not be negative: %v", c.DurationTolerance)
+	}
+	c.RecoveryScan = viper.GetString("recovery-scan")
+	switch c.RecoveryScan {
+	case radikron.RecoveryScanQuarantine, radikron.RecoveryScanRemove, radikron.RecoveryScanOff:
+	default:
+		return fmt.Errorf("unsupported recovery-scan: %s", c.RecoveryScan)
+	}
+	c.TempCleanupInterval = viper.GetDuration("tmp-cleanup-interval")
+	if c.TempCleanupInterval < 0 {
+		return fmt.Errorf("tmp-cleanup-interval must not be negative: %v", c.TempCleanupInterval)
+	}
+	c.GuideCacheTTL = viper.GetDuration("guide-cache-ttl")
+	if c.GuideCacheTTL < 0 {
+		return fmt.Errorf("guide-cache-ttl must not be negative: %v", c.GuideCacheTTL)
+	}
+	c.FetchSchedule = viper.GetString("fetch-schedule")
+	if c.FetchSchedule != "" {
+		if _, err := radikron.ParseCronSchedule(c.FetchSchedule); err != nil {
+			return fmt.Errorf("fetch-schedule: %w", err)
+		}
+	}
 	c.DownloadDir = viper.GetString("downloads")
+	c.NotifyUpcoming = viper.GetBool("notify-upcoming")
+	c.FillerFilter = viper.GetBool("filler-filter")
+	c.FillerTitlesFile = viper.GetString("filler-titles-file")
+	c.FillerTitles = nil
+	if c.FillerFilter {
+		fillerTitles, err := radikron.LoadFillerTitles(c.FillerTitlesFile)
+		if err != nil {
+			return err
+		}
+		c.FillerTitles = fillerTitles
+	}
+	titleAliases, err := loadTitleAliases()
+	if err != nil {
+		return err
+	}
+	c.TitleAliases = titleAliases
+	c.PreserveTimestamp = viper.GetBool("preserve-timestamp")
+	c.WriteXattrs = viper.GetBool("write-xattrs")
+	c.FolderArt = viper.GetBool("folder-art")
+	c.ImageGallery = viper.GetBool("image-gallery")
+	c.ReadOnly = viper.GetBool("read-only")
+	c.Retry = radikron.RetryPolicy{
+		MaxAttempts:  viper.GetInt("retry-max-attempts"),
+		InitialDelay: viper.GetDuration("retry-initial-delay"),
+		MaxDelay:     viper.GetDuration("retry-max-delay"),
+		Multiplier:   viper.GetFloat64("retry-multiplier"),
+		Jitter:       viper.GetFloat64("retry-jitter"),
+	}
+	if c.Retry.MaxAttempts <= 0 {
+		return fmt.Errorf("retry-max-attempts must be positive: %d", c.Retry.MaxAttempts)
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		return fmt.Errorf("retry-jitter must be between 0 and 1: %v", c.Retry.Jitter)
+	}
+	c.CoordinationDir = viper.GetString("coordination-dir")
+	c.InstanceID = viper.GetString("instance-id")
+	c.CoordinationLease = viper.GetDuration("coordination-lease")
+	if c.CoordinationDir != "" && c.CoordinationLease <= 0 {
+		return fmt.Errorf("coordination-lease must be positive: %v", c.CoordinationLease)
+	}
+	c.RequestsPerSecond = viper.GetFloat64("requests-per-second")
+	if c.RequestsPerSecond < 0 {
+		return fmt.Errorf("requests-per-second must not be negative: %v", c.RequestsPerSecond)
+	}
+	throttleSchedule, err := loadThrottleSchedule()
+	if err != nil {
+		return err
+	}
+	c.ThrottleSchedule = throttleSchedule
+	c.Proxy = viper.GetString("proxy")
+	if _, err := radikron.ResolveSecret(c.Proxy); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	c.Storage = viper.GetString("storage")
+	if _, err := c.storage(); err != nil {
+		return err
+	}
 	c.MaxDownloadingConcurrency = viper.GetInt("max-downloading-concurrency")
 	c.MaxEncodingConcurrency = viper.GetInt("max-encoding-concurrency")
+	c.DeferredEncoding = viper.GetBool("deferred-encoding")
+	c.AdBreakChapters = viper.GetBool("ad-break-chapters")
+	c.EncodingWindow = nil
+	if encodingWindow := viper.GetString("encoding-window"); encodingWindow != "" {
+		from, to, _ := strings.Cut(encodingWindow, "-")
+		window, err := radikron.ParseThrottleWindow(from, to, 0, 0)
+		if err != nil {
+			return fmt.Errorf("invalid encoding-window: %w", err)
+		}
+		c.EncodingWindow = &window
+	}
+	c.QuietHours = nil
+	for _, quietHours := range viper.GetStringSlice("quiet-hours") {
+		from, to, _ := strings.Cut(quietHours, "-")
+		window, err := radikron.ParseThrottleWindow(from, to, 0, 0)
+		if err != nil {
+			return fmt.Errorf("invalid quiet-hours: %w", err)
+		}
+		c.QuietHours = append(c.QuietHours, window)
+	}
+	c.PremiumMaxStreams = viper.GetInt("premium-max-streams")
+	c.PremiumMail = viper.GetString("premium-mail")
+	c.PremiumPass = viper.GetString("premium-pass")
+	if (c.PremiumMail == "") != (c.PremiumPass == "") {
+		return fmt.Errorf("premium-mail and premium-pass must be set together")
+	}
+	if _, err := radikron.ResolveSecret(c.PremiumPass); err != nil {
+		return fmt.Errorf("premium-pass: %w", err)
+	}
+	c.MaxProgramAttempts = viper.GetInt("max-program-attempts")
+	if c.MaxProgramAttempts < 0 {
+		return fmt.Errorf("max-program-attempts must not be negative: %d", c.MaxProgramAttempts)
+	}
+	c.DownloadStallTimeout = viper.GetDuration("download-stall-timeout")
+	if c.DownloadStallTimeout < 0 {
+		return fmt.Errorf("download-stall-timeout must not be negative: %v", c.DownloadStallTimeout)
+	}
+	c.FFmpegPath = viper.GetString("ffmpeg-path")
+	c.FFmpegArgs = viper.GetStringSlice("ffmpeg-args")
+	c.DuplicateScan = viper.GetString("duplicate-scan")
+	switch c.DuplicateScan {
+	case radikron.DuplicateScanAll, radikron.DuplicateScanRule:
+	default:
+		return fmt.Errorf("unsupported duplicate-scan: %s", c.DuplicateScan)
+	}
+	c.MP3Bitrate = viper.GetString("mp3-bitrate")
+	if c.MP3Bitrate != "" && !mp3BitratePattern.MatchString(c.MP3Bitrate) {
+		return fmt.Errorf("invalid mp3-bitrate: %s", c.MP3Bitrate)
+	}
+	c.MP3Quality = nil
+	if viper.IsSet("mp3-quality") {
+		quality := viper.GetInt("mp3-quality")
+		if quality < 0 || quality > 9 {
+			return fmt.Errorf("mp3-quality must be from 0 to 9: %d", quality)
+		}
+		c.MP3Quality = &quality
+	}
+	if c.MP3Bitrate != "" && c.MP3Quality != nil {
+		return fmt.Errorf("mp3-bitrate and mp3-quality are exclusive")
+	}
+	c.Sidecars = viper.GetStringSlice("write-sidecars")
+	for _, format := range c.Sidecars {
+		if format != radikron.SidecarJSON && format != radikron.SidecarNFO {
+			return fmt.Errorf("unsupported write-sidecars format: %s", format)
+		}
+	}
+	c.TranscriptionURL = viper.GetString("transcription-url")
+	if err := radikron.ValidateTranscriptionURL(c.TranscriptionURL); err != nil {
+		return err
+	}
+	c.FeedListen = viper.GetString("feed-listen")
+	c.FeedBaseURL = viper.GetString("feed-base-url")
+	if c.FeedBaseURL != "" && !strings.HasPrefix(c.FeedBaseURL, "http://") && !strings.HasPrefix(c.FeedBaseURL, "https://") {
+		return fmt.Errorf("unsupported feed-base-url: %s", c.FeedBaseURL)
+	}
+	c.Feeds = viper.GetStringMapStringSlice("feeds")
+	if err := radikron.ValidateFeeds(c.Feeds); err != nil {
+		return fmt.Errorf("invalid feeds: %w", err)
+	}
+	c.WebhookListen = viper.GetString("webhook-listen")
+	c.WebhookToken = viper.GetString("webhook-token")
+	if c.WebhookListen != "" && c.WebhookToken == "" {
+		return fmt.Errorf("webhook-listen needs webhook-token")
+	}
+	if _, err := radikron.ResolveSecret(c.WebhookToken); err != nil {
+		return fmt.Errorf("webhook-token: %w", err)
+	}
+	c.FileServerListen = viper.GetString("file-server-listen")
+	c.FileServerUser = viper.GetString("file-server-user")
+	c.FileServerPassword = viper.GetString("file-server-password")
+	c.FileServerListing = viper.GetBool("file-server-listing")
+	if c.FileServerListen != "" && c.FileServerPassword == "" {
+		return fmt.Errorf("file-server-listen needs file-server-password")
+	}
+	if _, err := radikron.ResolveSecret(c.FileServerPassword); err != nil {
+		return fmt.Errorf("file-server-password: %w", err)
+	}
+	c.MaxLiveRecordings = viper.GetInt("max-live-recordings")
+	if c.MaxLiveRecordings < 0 {
+		return fmt.Errorf("max-live-recordings must not be negative: %d", c.MaxLiveRecordings)
+	}
+	// viper lowercases the keys, while the station IDs are uppercase
+	c.StationDirs = map[string]string{}
+	for stationID, dir := range viper.GetStringMapString("station-dirs") {
+		if !filepath.IsLocal(dir) {
+			return fmt.Errorf("invalid station-dirs folder of %s: %q", stationID, dir)
+		}
+		c.StationDirs[strings.ToUpper(stationID)] = dir
+	}
+	c.DesktopNotifications = viper.GetBool("desktop-notifications")
+	c.NotificationTemplates = map[string]map[string]string{}
+	for backend := range viper.GetStringMap("notification-templates") {
+		c.NotificationTemplates[backend] = viper.GetStringMapString("notification-templates." + backend)
+	}
+	if err := radikron.ValidateNotificationTemplates(c.NotificationTemplates); err != nil {
+		return fmt.Errorf("invalid notification-templates: %w", err)
+	}
+	c.FilenameTemplate = viper.GetString("filename-template")
+	if err := radikron.ValidateFilenameTemplate(c.FilenameTemplate); err != nil {
+		return err
+	}
+	c.TagTemplates = viper.GetStringMapString("tags")
+	if err := radikron.ValidateTagTemplates(c.TagTemplates); err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
 
 	// Load rules
 	rules, err := loadRules()
 	if err != nil {
 		return fmt.Errorf("error loading rules: %w", err)
 	}
-	c.Rules = rules
+	c.BaseRules = rules
+
+	// Override the rules by the profiles active today
+	profiles, err := loadProfiles(rules)
+	if err != nil {
+		return fmt.Errorf("error loading profiles: %w", err)
+	}
+	c.Profiles = profiles
+	c.Rules, c.ActiveProfiles = applyProfiles(rules, profiles, profileTime())
+
+	// The renamed titles are the same series when matching and comparing the rules
+	aliases, err := radikron.NewTitleAliases(c.TitleAliases)
+	if err != nil {
+		return fmt.Errorf("invalid title-aliases: %w", err)
+	}
+	c.Rules.SetTitleAliases(aliases)
 
 	return nil
 }
 
+// loadAreaIDs returns the areas to load the stations from: area-ids with area-id first if it is also configured,
+// or area-id alone, defaulting to the detected area
+func loadAreaIDs() ([]string, error) {
+	var areaIDs []string
+	if viper.InConfig("area-id") || !viper.IsSet("area-ids") {
+		areaIDs = append(areaIDs, strings.ToUpper(viper.GetString("area-id")))
+	}
+	for _, areaID := range viper.GetStringSlice("area-ids") {
+		areaID = strings.ToUpper(areaID)
+		if !slices.Contains(areaIDs, areaID) {
+			areaIDs = append(areaIDs, areaID)
+		}
+	}
+	if len(areaIDs) == 0 {
+		return nil, fmt.Errorf("area-ids must not be empty")
+	}
+	for _, areaID := range areaIDs {
+		if !areaIDPattern.MatchString(areaID) {
+			return nil, fmt.Errorf("invalid area ID: %s", areaID)
+		}
+	}
+	return areaIDs, nil
+}
+
+// areaIDsToYAML returns the areas to save as area-ids, or nil if area-id alone has them
+func areaIDsToYAML(areaIDs []string) []string {
+	if len(areaIDs) < 2 {
+		return nil
+	}
+	return areaIDs
+}
+
+// storage returns the storage of the storage URL, resolving the secret it refers to, or nil for the downloads dir
+func (c *Config) storage() (radikron.Storage, error) {
+	storageURL, err := radikron.ResolveSecret(c.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	storage, err := radikron.NewStorage(storageURL)
+	if err != nil && radikron.IsSecretRef(c.Storage) {
+		// do not leak the secret in the error
+		return nil, fmt.Errorf("invalid storage in %s", c.Storage)
+	}
+	return storage, err
+}
+
+// FeedURLBase returns the URL the podcast feeds link to: feed-base-url, or the feed-listen address on localhost
+func (c *Config) FeedURLBase() string {
+	if c.FeedBaseURL != "" || c.FeedListen == "" {
+		return c.FeedBaseURL
+	}
+	host, port, err := net.SplitHostPort(c.FeedListen)
+	if err != nil {
+		return ""
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
 // configYAML represents the YAML structure for saving configuration
 type configYAML struct {
-	AreaID                    string               `yaml:"area-id"`
-	ExtraStations             []string             `yaml:"extra-stations,omitempty"`
-	IgnoreStations            []string             `yaml:"ignore-stations,omitempty"`
-	FileFormat                string               `yaml:"file-format"`
-	MinimumOutputSize         int64                `yaml:"minimum-output-size"`
-	DownloadDir               string               `yaml:"downloads"`
-	MaxDownloadingConcurrency *int                 `yaml:"max-downloading-concurrency,omitempty"`
-	MaxEncodingConcurrency    *int                 `yaml:"max-encoding-concurrency,omitempty"`
-	Rules                     map[string]*ruleYAML `yaml:"rules,omitempty"`
+	AreaID                    string                  `yaml:"area-id"`
+	AreaIDs                   []string                `yaml:"area-ids,omitempty"`
+	Include                   []string                `yaml:"include,omitempty"`
+	ExtraStations             []string                `yaml:"extra-stations,omitempty"`
+	IgnoreStations            []string                `yaml:"ignore-stations,omitempty"`
+	FileFormat                string                  `yaml:"file-format"`
+	MinimumOutputSize         int64                   `yaml:"minimum-output-size"`
+	MinimumFreeSpace          *int64                  `yaml:"minimum-free-space,omitempty"`
+	MinimumSegmentSize        int64                   `yaml:"minimum-segment-size,omitempty"`
+	DurationTolerance         string                  `yaml:"duration-tolerance,omitempty"`
+	RecoveryScan              string                  `yaml:"recovery-scan,omitempty"`
+	TempCleanupInterval       string                  `yaml:"tmp-cleanup-interval,omitempty"`
+	GuideCacheTTL             string                  `yaml:"guide-cache-ttl,omitempty"`
+	FetchSchedule             string                  `yaml:"fetch-schedule,omitempty"`
+	FillerFilter              *bool                   `yaml:"filler-filter,omitempty"`
+	FillerTitlesFile          string                  `yaml:"filler-titles-file,omitempty"`
+	TitleAliases              []titleAliasYAML        `yaml:"title-aliases,omitempty"`
+	DownloadDir               string                  `yaml:"downloads"`
+	PreserveTimestamp         bool                    `yaml:"preserve-timestamp,omitempty"`
+	WriteXattrs               bool                    `yaml:"write-xattrs,omitempty"`
+	FolderArt                 bool                    `yaml:"folder-art,omitempty"`
+	ImageGallery              bool                    `yaml:"image-gallery,omitempty"`
+	ReadOnly                  bool                    `yaml:"read-only,omitempty"`
+	NotifyUpcoming            bool                    `yaml:"notify-upcoming,omitempty"`
+	RetryMaxAttempts          *int                    `yaml:"retry-max-attempts,omitempty"`
+	RetryInitialDelay         string                  `yaml:"retry-initial-delay,omitempty"`
+	RetryMaxDelay             string                  `yaml:"retry-max-delay,omitempty"`
+	RetryMultiplier           *float64                `yaml:"retry-multiplier,omitempty"`
+	RetryJitter               *float64                `yaml:"retry-jitter,omitempty"`
+	CoordinationDir           string                  `yaml:"coordination-dir,omitempty"`
+	InstanceID                string                  `yaml:"instance-id,omitempty"`
+	CoordinationLease         string                  `yaml:"coordination-lease,omitempty"`
+	RequestsPerSecond         *float64                `yaml:"requests-per-second,omitempty"`
+	ThrottleSchedule          []throttleWindowYAML    `yaml:"throttle-schedule,omitempty"`
+	Proxy                     string                  `yaml:"proxy,omitempty"`
+	Storage                   string                  `yaml:"storage,omitempty"`
+	MaxDownloadingConcurrency *int                    `yaml:"max-downloading-concurrency,omitempty"`
+	MaxEncodingConcurrency    *int                    `yaml:"max-encoding-concurrency,omitempty"`
+	DeferredEncoding          bool                    `yaml:"deferred-encoding,omitempty"`
+	EncodingWindow            string                  `yaml:"encoding-window,omitempty"`
+	QuietHours                []string                `yaml:"quiet-hours,omitempty"`
+	AdBreakChapters           bool                    `yaml:"ad-break-chapters,omitempty"`
+	PremiumMaxStreams         *int                    `yaml:"premium-max-streams,omitempty"`
+	PremiumMail               string                  `yaml:"premium-mail,omitempty"`
+	PremiumPass               string                  `yaml:"premium-pass,omitempty"`
+	MaxProgramAttempts        *int                    `yaml:"max-program-attempts,omitempty"`
+	DownloadStallTimeout      string                  `yaml:"download-stall-timeout,omitempty"`
+	FFmpegPath                string                  `yaml:"ffmpeg-path,omitempty"`
+	FFmpegArgs                []string                `yaml:"ffmpeg-args,omitempty"`
+	DuplicateScan             string                  `yaml:"duplicate-scan,omitempty"`
+	MP3Bitrate                string                  `yaml:"mp3-bitrate,omitempty"`
+	MP3Quality                *int                    `yaml:"mp3-quality,omitempty"`
+	Sidecars                  []string                `yaml:"write-sidecars,omitempty"`
+	TagTemplates              map[string]string       `yaml:"tags,omitempty"`
+	DesktopNotifications      bool                    `yaml:"desktop-notifications,omitempty"`
+	NotificationTemplates     notificationsYAML       `yaml:"notification-templates,omitempty"`
+	FilenameTemplate          string                  `yaml:"filename-template,omitempty"`
+	TranscriptionURL          string                  `yaml:"transcription-url,omitempty"`
+	FeedListen                string                  `yaml:"feed-listen,omitempty"`
+	FeedBaseURL               string                  `yaml:"feed-base-url,omitempty"`
+	Feeds                     map[string][]string     `yaml:"feeds,omitempty"`
+	WebhookListen             string                  `yaml:"webhook-listen,omitempty"`
+	WebhookToken              string                  `yaml:"webhook-token,omitempty"`
+	FileServerListen          string                  `yaml:"file-server-listen,omitempty"`
+	FileServerUser            string                  `yaml:"file-server-user,omitempty"`
+	FileServerPassword        string                  `yaml:"file-server-password,omitempty"`
+	FileServerListing         bool                    `yaml:"file-server-listing,omitempty"`
+	MaxLiveRecordings         int                     `yaml:"max-live-recordings,omitempty"`
+	StationDirs               map[string]string       `yaml:"station-dirs,omitempty"`
+	Rules                     map[string]*ruleYAML    `yaml:"rules,omitempty"`
+	Profiles                  map[string]*profileYAML `yaml:"profiles,omitempty"`
 }
 
+// notificationsYAML represents the notification templates of each backend in YAML format
+type notificationsYAML map[string]map[string]string
+
 // ruleYAML represents a rule in YAML format
 type ruleYAML struct {
 	StationID string   `yaml:"station-id,omitempty"`
@@ -182,6 +804,92 @@ type ruleYAML struct {
 	Pfm       string   `yaml:"pfm,omitempty"`
 	Window    string   `yaml:"window,omitempty"`
 	Folder    string   `yaml:"folder,omitempty"`
+	Genre     []string `yaml:"genre,omitempty"`
+	AreaFree  bool     `yaml:"areafree,omitempty"`
+	Mode      string   `yaml:"mode,omitempty"`
+	// FolderByTag routes the programs by their tags
+	FolderByTag map[string]string `yaml:"folder-by-tag,omitempty"`
+	// FilenameCharset normalizes the file names
+	FilenameCharset string `yaml:"filename-charset,omitempty"`
+	// Keep is how long the prune command keeps the recordings
+	Keep string `yaml:"keep,omitempty"`
+}
+
+// throttleWindowYAML represents a window of the throttle schedule in YAML format
+type throttleWindowYAML struct {
+	From                      string  `yaml:"from" mapstructure:"from"`
+	To                        string  `yaml:"to" mapstructure:"to"`
+	MaxDownloadingConcurrency int     `yaml:"max-downloading-concurrency,omitempty" mapstructure:"max-downloading-concurrency"`
+	RequestsPerSecond         float64 `yaml:"requests-per-second,omitempty" mapstructure:"requests-per-second"`
+}
+
+// titleAliasYAML represents a renamed program in YAML format
+type titleAliasYAML struct {
+	From string `yaml:"from" mapstructure:"from"`
+	To   string `yaml:"to" mapstructure:"to"`
+}
+
+// loadTitleAliases loads the old→new titles of the renamed programs from the configuration
+func loadTitleAliases() (map[string]string, error) {
+	var aliases []titleAliasYAML
+	if err := viper.UnmarshalKey("title-aliases", &aliases); err != nil {
+		return nil, fmt.Errorf("invalid title-aliases: %w", err)
+	}
+	result := make(map[string]string, len(aliases))
+	for _, a := range aliases {
+		result[a.From] = a.To
+	}
+	if err := radikron.ValidateTitleAliases(result); err != nil {
+		return nil, fmt.Errorf("invalid title-aliases: %w", err)
+	}
+	return result, nil
+}
+
+// convertTitleAliasesToYAML converts the title aliases to YAML format, sorted by the old title
+func convertTitleAliasesToYAML(aliases map[string]string) []titleAliasYAML {
+	if len(aliases) == 0 {
+		return nil
+	}
+	result := make([]titleAliasYAML, 0, len(aliases))
+	for from, to := range aliases {
+		result = append(result, titleAliasYAML{From: from, To: to})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].From < result[j].From })
+	return result
+}
+
+// loadThrottleSchedule loads the throttle windows from the configuration
+func loadThrottleSchedule() ([]radikron.ThrottleWindow, error) {
+	var windows []throttleWindowYAML
+	if err := viper.UnmarshalKey("throttle-schedule", &windows); err != nil {
+		return nil, fmt.Errorf("invalid throttle-schedule: %w", err)
+	}
+	schedule := make([]radikron.ThrottleWindow, 0, len(windows))
+	for _, w := range windows {
+		window, err := radikron.ParseThrottleWindow(w.From, w.To, w.MaxDownloadingConcurrency, w.RequestsPerSecond)
+		if err != nil {
+			return nil, fmt.Errorf("invalid throttle-schedule: %w", err)
+		}
+		schedule = append(schedule, window)
+	}
+	return schedule, nil
+}
+
+// convertThrottleScheduleToYAML converts the throttle windows to YAML format
+func convertThrottleScheduleToYAML(schedule []radikron.ThrottleWindow) []throttleWindowYAML {
+	if len(schedule) == 0 {
+		return nil
+	}
+	result := make([]throttleWindowYAML, 0, len(schedule))
+	for _, w := range schedule {
+		result = append(result, throttleWindowYAML{
+			From:                      fmt.Sprintf("%02d:%02d", w.From/60, w.From%60),
+			To:                        fmt.Sprintf("%02d:%02d", w.To/60, w.To%60),
+			MaxDownloadingConcurrency: w.MaxDownloadingConcurrency,
+			RequestsPerSecond:         w.RequestsPerSecond,
+		})
+	}
+	return result
 }
 
 // convertRulesToYAML converts rules to YAML format
@@ -192,7 +900,12 @@ func convertRulesToYAML(rules radikron.Rules) map[string]*ruleYAML {
 	result := make(map[string]*ruleYAML)
 	for _, rule := range rules {
 		ruleYAMLObj := &ruleYAML{
-			Folder: rule.Folder,
+			Folder:          rule.Folder,
+			AreaFree:        rule.AreaFree,
+			Mode:            rule.Mode,
+			FolderByTag:     rule.FolderByTag,
+			FilenameCharset: rule.FilenameCharset,
+			Keep:            rule.Keep,
 		}
 		if rule.HasStationID() {
 			ruleYAMLObj.StationID = rule.StationID
@@ -212,11 +925,42 @@ func convertRulesToYAML(rules radikron.Rules) map[string]*ruleYAML {
 		if rule.HasWindow() {
 			ruleYAMLObj.Window = rule.Window
 		}
+		if rule.HasGenre() {
+			ruleYAMLObj.Genre = rule.Genre
+		}
 		result[rule.Name] = ruleYAMLObj
 	}
 	return result
 }
 
+// defaultInstanceID returns the hostname to identify this instance among the coordinated instances
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "radikron"
+	}
+	return hostname
+}
+
+// setRetryYAML sets the retry settings that differ from defaults
+func setRetryYAML(cfgYAML *configYAML, retry radikron.RetryPolicy) {
+	if retry.MaxAttempts != radikron.MaxRetryAttempts {
+		cfgYAML.RetryMaxAttempts = &retry.MaxAttempts
+	}
+	if retry.InitialDelay != radikron.DefaultRetryInitialDelay {
+		cfgYAML.RetryInitialDelay = retry.InitialDelay.String()
+	}
+	if retry.MaxDelay != radikron.DefaultRetryMaxDelay {
+		cfgYAML.RetryMaxDelay = retry.MaxDelay.String()
+	}
+	if retry.Multiplier != radikron.DefaultRetryMultiplier {
+		cfgYAML.RetryMultiplier = &retry.Multiplier
+	}
+	if retry.Jitter != radikron.DefaultRetryJitter {
+		cfgYAML.RetryJitter = &retry.Jitter
+	}
+}
+
 // SaveConfig saves the configuration to a file in YAML format
 func (c *Config) SaveConfig(filename string) error {
 	// Convert absolute path
@@ -233,12 +977,51 @@ func (c *Config) SaveConfig(filename string) error {
 
 	// Convert config to YAML structure
 	cfgYAML := configYAML{
-		AreaID:            c.AreaID,
-		ExtraStations:     c.ExtraStations,
-		IgnoreStations:    c.IgnoreStations,
-		FileFormat:        c.FileFormat,
-		MinimumOutputSize: c.MinimumOutputSize / (radikron.Kilobytes * radikron.Kilobytes), // Convert bytes to MB
-		DownloadDir:       c.DownloadDir,
+		AreaID:               c.AreaID,
+		AreaIDs:              areaIDsToYAML(c.AreaIDs),
+		Include:              c.Include,
+		ExtraStations:        c.ExtraStations,
+		IgnoreStations:       c.IgnoreStations,
+		FileFormat:           c.FileFormat,
+		MinimumOutputSize:    c.MinimumOutputSize / (radikron.Kilobytes * radikron.Kilobytes), // Convert bytes to MB
+		MinimumSegmentSize:   c.MinimumSegmentSize / radikron.Kilobytes,                       // Convert bytes to KB
+		DownloadDir:          c.DownloadDir,
+		FetchSchedule:        c.FetchSchedule,
+		PreserveTimestamp:    c.PreserveTimestamp,
+		WriteXattrs:          c.WriteXattrs,
+		FolderArt:            c.FolderArt,
+		ImageGallery:         c.ImageGallery,
+		ReadOnly:             c.ReadOnly,
+		NotifyUpcoming:       c.NotifyUpcoming,
+		CoordinationDir:      c.CoordinationDir,
+		Proxy:                c.Proxy,
+		Storage:              c.Storage,
+		PremiumMail:          c.PremiumMail,
+		PremiumPass:          c.PremiumPass,
+		FillerTitlesFile:     c.FillerTitlesFile,
+		FFmpegPath:           c.FFmpegPath,
+		FFmpegArgs:           c.FFmpegArgs,
+		MP3Bitrate:           c.MP3Bitrate,
+		MP3Quality:           c.MP3Quality,
+		Sidecars:             c.Sidecars,
+		TagTemplates:         c.TagTemplates,
+		DesktopNotifications: c.DesktopNotifications,
+		TranscriptionURL:     c.TranscriptionURL,
+		FeedListen:           c.FeedListen,
+		FeedBaseURL:          c.FeedBaseURL,
+		Feeds:                c.Feeds,
+		WebhookListen:        c.WebhookListen,
+		WebhookToken:         c.WebhookToken,
+		FileServerListen:     c.FileServerListen,
+		FileServerPassword:   c.FileServerPassword,
+		FileServerListing:    c.FileServerListing,
+		MaxLiveRecordings:    c.MaxLiveRecordings,
+		StationDirs:          c.StationDirs,
+	}
+
+	cfgYAML.NotificationTemplates = c.NotificationTemplates
+	if c.FileServerUser != radikron.DefaultFileServerUser {
+		cfgYAML.FileServerUser = c.FileServerUser
 	}
 
 	// Only include concurrency settings if they differ from defaults
@@ -248,9 +1031,72 @@ func (c *Config) SaveConfig(filename string) error {
 	if c.MaxEncodingConcurrency != radikron.MaxEncodingConcurrency {
 		cfgYAML.MaxEncodingConcurrency = &c.MaxEncodingConcurrency
 	}
+	cfgYAML.DeferredEncoding = c.DeferredEncoding
+	cfgYAML.AdBreakChapters = c.AdBreakChapters
+	if c.EncodingWindow != nil {
+		cfgYAML.EncodingWindow = c.EncodingWindow.String()
+	}
+	for _, w := range c.QuietHours {
+		cfgYAML.QuietHours = append(cfgYAML.QuietHours, w.String())
+	}
+	if c.PremiumMaxStreams != radikron.DefaultPremiumMaxStreams {
+		cfgYAML.PremiumMaxStreams = &c.PremiumMaxStreams
+	}
+	if c.MaxProgramAttempts != radikron.DefaultMaxProgramAttempts {
+		cfgYAML.MaxProgramAttempts = &c.MaxProgramAttempts
+	}
+	if c.DownloadStallTimeout != radikron.DefaultDownloadStallTimeout {
+		cfgYAML.DownloadStallTimeout = c.DownloadStallTimeout.String()
+	}
+	if minimumFreeSpace := c.MinimumFreeSpace / (radikron.Kilobytes * radikron.Kilobytes); minimumFreeSpace != radikron.DefaultMinimumFreeSpace {
+		cfgYAML.MinimumFreeSpace = &minimumFreeSpace // Convert bytes to MB
+	}
+	if c.DurationTolerance != radikron.DefaultDurationTolerance {
+		cfgYAML.DurationTolerance = c.DurationTolerance.String()
+	}
+	if c.RecoveryScan != radikron.RecoveryScanQuarantine {
+		cfgYAML.RecoveryScan = c.RecoveryScan
+	}
+	if c.DuplicateScan != radikron.DuplicateScanAll {
+		cfgYAML.DuplicateScan = c.DuplicateScan
+	}
+	if c.FilenameTemplate != radikron.DefaultFilenameTemplate {
+		cfgYAML.FilenameTemplate = c.FilenameTemplate
+	}
+	if c.TempCleanupInterval != 0 {
+		cfgYAML.TempCleanupInterval = c.TempCleanupInterval.String()
+	}
+	if c.GuideCacheTTL != 0 {
+		cfgYAML.GuideCacheTTL = c.GuideCacheTTL.String()
+	}
+	if !c.FillerFilter {
+		cfgYAML.FillerFilter = &c.FillerFilter
+	}
+	if c.RequestsPerSecond != radikron.DefaultRequestsPerSecond {
+		cfgYAML.RequestsPerSecond = &c.RequestsPerSecond
+	}
+
+	// Only include retry settings if they differ from defaults
+	setRetryYAML(&cfgYAML, c.Retry)
 
-	// Convert rules to YAML format
-	cfgYAML.Rules = convertRulesToYAML(c.Rules)
+	// Only include coordination settings if they differ from defaults
+	if c.InstanceID != defaultInstanceID() {
+		cfgYAML.InstanceID = c.InstanceID
+	}
+	if c.CoordinationLease != radikron.DefaultCoordinationLease {
+		cfgYAML.CoordinationLease = c.CoordinationLease.String()
+	}
+
+	cfgYAML.ThrottleSchedule = convertThrottleScheduleToYAML(c.ThrottleSchedule)
+	cfgYAML.TitleAliases = convertTitleAliasesToYAML(c.TitleAliases)
+
+	// Convert rules to YAML format, without the rules of the active profiles
+	rules := c.Rules
+	if c.BaseRules != nil {
+		rules = c.BaseRules
+	}
+	cfgYAML.Rules = convertRulesToYAML(rules)
+	cfgYAML.Profiles = convertProfilesToYAML(c.Profiles)
 
 	// Marshal to YAML
 	data, err := yaml.Marshal(&cfgYAML)
@@ -274,44 +1120,52 @@ func (c *Config) SaveConfig(filename string) error {
 // It handles DocumentNode -> root mapping and iterates mapping pairs to find the "rules" key.
 // Returns the rules node if found, or nil if not found or invalid.
 func findRulesNode(root *yaml.Node) *yaml.Node {
-	if root == nil {
-		return nil
-	}
+	return findNodeAt(root, "rules")
+}
 
-	// Handle DocumentNode -> get the root mapping
-	var rootMapping *yaml.Node
-	if root.Kind == yaml.DocumentNode {
-		if len(root.Content) == 0 {
+// findNodeAt finds the node at the path of the mapping keys in the YAML document, matching the keys
+// case-insensitively like viper. Returns nil if not found or invalid.
+func findNodeAt(root *yaml.Node, path ...string) *yaml.Node {
+	node := root
+	for _, key := range path {
+		if node == nil {
 			return nil
 		}
-		rootMapping = root.Content[0]
-	} else {
-		rootMapping = root
-	}
 
-	// Must be a mapping node
-	if rootMapping.Kind != yaml.MappingNode {
-		return nil
-	}
+		// Handle DocumentNode -> get the root mapping
+		if node.Kind == yaml.DocumentNode {
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		}
 
-	// Iterate through key-value pairs to find "rules"
-	for i := 0; i < len(rootMapping.Content); i += 2 {
-		if i+1 >= len(rootMapping.Content) {
-			continue
+		// Must be a mapping node
+		if node.Kind != yaml.MappingNode {
+			return nil
 		}
-		keyNode := rootMapping.Content[i]
-		if keyNode.Value == "rules" {
-			return rootMapping.Content[i+1]
+
+		// Iterate through key-value pairs to find the key
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if strings.EqualFold(node.Content[i].Value, key) {
+				next = node.Content[i+1]
+				break
+			}
 		}
+		node = next
 	}
-
-	return nil
+	return node
 }
 
 // parseRuleFromNode decodes a rule node into a radikron.Rule.
-// It decodes the ruleNode into a map, sets temporary viper keys for that rule,
-// unmarshals into a radikron.Rule, sets its name from nameNode, and returns it.
+// It decodes the ruleNode into a map and parses it with parseRuleFromMap under the name from nameNode.
 func parseRuleFromNode(nameNode, ruleNode *yaml.Node) (*radikron.Rule, error) {
+	return parseRuleNodeAt("rules", nameNode, ruleNode)
+}
+
+// parseRuleNodeAt decodes a rule node under the viper key prefix into a radikron.Rule.
+func parseRuleNodeAt(prefix string, nameNode, ruleNode *yaml.Node) (*radikron.Rule, error) {
 	if nameNode == nil || ruleNode == nil {
 		return nil, fmt.Errorf("nameNode and ruleNode must not be nil")
 	}
@@ -322,19 +1176,28 @@ func parseRuleFromNode(nameNode, ruleNode *yaml.Node) (*radikron.Rule, error) {
 	}
 
 	// Convert rule node to a map for viper to process
-	// Viper's UnmarshalKey respects mapstructure tags
 	var ruleMap map[string]any
 	if err := ruleNode.Decode(&ruleMap); err != nil {
 		return nil, fmt.Errorf("failed to decode rule '%s': %w", name, err)
 	}
 
+	return parseRuleFromMap(prefix, name, ruleMap)
+}
+
+// parseRuleFromMap decodes a rule map of any config format into a radikron.Rule.
+// It sets temporary viper keys for that rule under the prefix, unmarshals into a radikron.Rule, and sets its name.
+func parseRuleFromMap(prefix, name string, ruleMap map[string]any) (*radikron.Rule, error) {
+	if name == "" {
+		return nil, fmt.Errorf("rule name cannot be empty")
+	}
+
 	// Set the rule data in viper temporarily
-	ruleKey := fmt.Sprintf("rules.%s", name)
+	// Viper's UnmarshalKey respects mapstructure tags
+	ruleKey := fmt.Sprintf("%s.%s", prefix, name)
 	for k, v := range ruleMap {
 		viper.Set(fmt.Sprintf("%s.%s", ruleKey, k), v)
 	}
 
-	// Use viper's UnmarshalKey which respects mapstructure tags
 	rule := &radikron.Rule{}
 	if err := viper.UnmarshalKey(ruleKey, rule); err != nil {
 		return nil, fmt.Errorf("error reading the rule '%s': %w", name, err)
@@ -346,13 +1209,17 @@ func parseRuleFromNode(nameNode, ruleNode *yaml.Node) (*radikron.Rule, error) {
 
 // loadRules loads rules from the configuration, preserving the order from the config file
 func loadRules() (radikron.Rules, error) {
-	rules := radikron.Rules{}
+	return loadRulesAt("rules")
+}
 
+// loadRulesAt loads the rules at the path of the keys in the configuration, e.g., the rules of a profile,
+// preserving the order from the config file
+func loadRulesAt(path ...string) (radikron.Rules, error) {
 	// Get the config file path
 	configFile := viper.ConfigFileUsed()
 	if configFile == "" {
 		// Fallback to viper's method if config file path is not available
-		return loadRulesFromViper()
+		return loadRulesFromViperAt(strings.Join(path, "."))
 	}
 
 	// Validate config file exists before attempting to read
@@ -363,12 +1230,40 @@ func loadRules() (radikron.Rules, error) {
 		return nil, fmt.Errorf("failed to access config file: %w", err)
 	}
 
-	// Read the YAML file directly to preserve order
+	// Merge the rules of the included files, the later files replacing the rules of the same name
+	rules := radikron.Rules{}
+	for _, file := range rulesFiles(configFile) {
+		fileRules, err := loadFileRulesAt(file, path)
+		if err != nil {
+			return nil, err
+		}
+		rules = mergeRules(rules, fileRules)
+	}
+	return rules, nil
+}
+
+// loadFileRulesAt loads the rules at the path of the keys in a config file in the order of the file
+func loadFileRulesAt(configFile string, path []string) (radikron.Rules, error) {
+	// Read the config file directly to preserve order
 	data, err := os.ReadFile(configFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".toml":
+		return loadTOMLRules(data, path)
+	case ".json":
+		return loadJSONRules(data, path)
+	default:
+		return loadYAMLRules(data, path)
+	}
+}
+
+// loadYAMLRules loads the rules at the path of a YAML config in the order of the file
+func loadYAMLRules(data []byte, path []string) (radikron.Rules, error) {
+	rules := radikron.Rules{}
+
 	// Parse YAML to yaml.Node to preserve order
 	var rootNode yaml.Node
 	if err := yaml.Unmarshal(data, &rootNode); err != nil {
@@ -376,13 +1271,14 @@ func loadRules() (radikron.Rules, error) {
 	}
 
 	// Find the rules section in the YAML node
-	rulesNode := findRulesNode(&rootNode)
+	rulesNode := findNodeAt(&rootNode, path...)
 
 	if rulesNode == nil || rulesNode.Kind != yaml.MappingNode {
 		return rules, nil
 	}
 
 	// Iterate through rules in order (yaml.Node.Content preserves order)
+	prefix := strings.Join(path, ".")
 	for i := 0; i < len(rulesNode.Content); i += 2 {
 		if i+1 >= len(rulesNode.Content) {
 			continue
@@ -390,7 +1286,7 @@ func loadRules() (radikron.Rules, error) {
 		nameNode := rulesNode.Content[i]
 		ruleNode := rulesNode.Content[i+1]
 
-		rule, err := parseRuleFromNode(nameNode, ruleNode)
+		rule, err := parseRuleNodeAt(prefix, nameNode, ruleNode)
 		if err != nil {
 			return nil, err
 		}
@@ -400,14 +1296,203 @@ func loadRules() (radikron.Rules, error) {
 	return rules, nil
 }
 
+// loadJSONRules loads the rules at the path of a JSON config in the order of the file
+func loadJSONRules(data []byte, path []string) (radikron.Rules, error) {
+	rules := radikron.Rules{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	found, err := seekJSONKey(dec, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if !found {
+		return rules, nil
+	}
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	// Iterate through rules in order of the object keys
+	prefix := strings.Join(path, ".")
+	for dec.More() {
+		nameToken, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rules: %w", err)
+		}
+		name, _ := nameToken.(string)
+		var ruleMap map[string]any
+		if err := dec.Decode(&ruleMap); err != nil {
+			return nil, fmt.Errorf("failed to decode rule '%s': %w", name, err)
+		}
+		rule, err := parseRuleFromMap(prefix, name, ruleMap)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// seekJSONKey reads dec up to the value at the path of the object keys, matched case-insensitively,
+// and returns false if the path is not found in the object read
+func seekJSONKey(dec *json.Decoder, path []string) (bool, error) {
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return false, err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		key, _ := token.(string)
+		if strings.EqualFold(key, path[0]) {
+			if len(path) == 1 {
+				return true, nil
+			}
+			found, err := seekJSONKey(dec, path[1:])
+			if found || err != nil {
+				return found, err
+			}
+			continue
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return false, err
+		}
+	}
+	return false, expectJSONDelim(dec, '}')
+}
+
+// expectJSONDelim reads the next token from dec and returns an error unless it is delim
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %s but got %v", delim, token)
+	}
+	return nil
+}
+
+// loadTOMLRules loads the rules at the path of a TOML config in the order of the file
+func loadTOMLRules(data []byte, path []string) (radikron.Rules, error) {
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	for _, key := range path {
+		doc = lookupTOMLTable(doc, key)
+	}
+
+	names, err := tomlRuleNames(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	rules := radikron.Rules{}
+	prefix := strings.Join(path, ".")
+	for _, name := range names {
+		ruleMap, ok := doc[name].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("failed to decode rule '%s': not a table", name)
+		}
+		rule, err := parseRuleFromMap(prefix, name, ruleMap)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// lookupTOMLTable returns the table under the key matched case-insensitively, or nil
+func lookupTOMLTable(table map[string]any, key string) map[string]any {
+	for k, v := range table {
+		if strings.EqualFold(k, key) {
+			sub, _ := v.(map[string]any)
+			return sub
+		}
+	}
+	return nil
+}
+
+// tomlRuleNames returns the names of the rules at the path in the order they first appear in a TOML config,
+// either as [rules.<name>] tables, keys under a [rules] table, dotted rules.<name> keys, or an inline rules table
+func tomlRuleNames(data []byte, path []string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(key []string) {
+		if len(key) <= len(path) || !hasTOMLPrefix(key, path) {
+			return
+		}
+		if name := key[len(path)]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	var table []string
+	p := unstable.Parser{}
+	p.Reset(data)
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			table = tomlKey(expr.Key())
+			add(table)
+		case unstable.KeyValue:
+			key := append(append([]string{}, table...), tomlKey(expr.Key())...)
+			if len(key) == len(path) && hasTOMLPrefix(key, path) && expr.Value().Kind == unstable.InlineTable {
+				// rules = { name = { ... }, ... }
+				it := expr.Value().Children()
+				for it.Next() {
+					if it.Node().Kind == unstable.KeyValue {
+						add(append(key, tomlKey(it.Node().Key())...))
+					}
+				}
+				continue
+			}
+			add(key)
+		}
+	}
+	if err := p.Error(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// hasTOMLPrefix returns true if the key starts with the path, matched case-insensitively
+func hasTOMLPrefix(key, path []string) bool {
+	for i, part := range path {
+		if !strings.EqualFold(key[i], part) {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlKey returns the parts of a dotted TOML key
+func tomlKey(it unstable.Iterator) []string {
+	var parts []string
+	for it.Next() {
+		parts = append(parts, string(it.Node().Data))
+	}
+	return parts
+}
+
 // loadRulesFromViper is a fallback method when config file path is not available
 func loadRulesFromViper() (radikron.Rules, error) {
+	return loadRulesFromViperAt("rules")
+}
+
+// loadRulesFromViperAt is a fallback method of loadRulesAt when config file path is not available
+func loadRulesFromViperAt(key string) (radikron.Rules, error) {
 	rules := radikron.Rules{}
-	ruleMap := viper.GetStringMap("rules")
+	ruleMap := viper.GetStringMap(key)
 
 	for name := range ruleMap {
 		rule := &radikron.Rule{}
-		err := viper.UnmarshalKey(fmt.Sprintf("rules.%s", name), rule)
+		err := viper.UnmarshalKey(fmt.Sprintf("%s.%s", key, name), rule)
 		if err != nil {
 			return nil, fmt.Errorf("error reading the rule '%s': %w", name, err)
 		}
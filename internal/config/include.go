@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/iomz/radikron"
+	"github.com/spf13/viper"
+)
+
+// configFiles are the files of the last loaded config in the order they are merged:
+// the included files first and the config file last
+var configFiles []string
+
+// configSchema is the schema of one of the merged config files
+type configSchema struct {
+	file string
+	root *schemaNode
+}
+
+// ConfigFiles returns the files merged for the config file: the files in its include list,
+// each after its own includes, and the config file last
+func ConfigFiles(configFile string) ([]string, error) {
+	var files []string
+	var visit func(file string, stack []string) error
+	visit = func(file string, stack []string) error {
+		if slices.Contains(stack, file) {
+			return fmt.Errorf("include cycle: %s", strings.Join(append(stack, file), " -> "))
+		}
+		if slices.Contains(files, file) {
+			return nil
+		}
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			if len(stack) > 0 {
+				return fmt.Errorf("failed to include %s from %s: %w", file, stack[len(stack)-1], err)
+			}
+			return fmt.Errorf("error reading config: %w", err)
+		}
+		for _, include := range v.GetStringSlice("include") {
+			// the includes are relative to the including file
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(file), include)
+			}
+			if err := visit(filepath.Clean(include), append(stack, file)); err != nil {
+				return err
+			}
+		}
+		files = append(files, file)
+		return nil
+	}
+	if err := visit(filepath.Clean(configFile), nil); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// mergeConfigFiles replaces the configuration read by viper with the files merged in order,
+// the later files overriding the keys of the earlier ones; the last file remains the config file used
+func mergeConfigFiles(files []string) error {
+	if len(files) < 2 {
+		return nil
+	}
+	configFile := files[len(files)-1]
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("error reading config: %w", err)
+		}
+		if err := viper.MergeConfigMap(v.AllSettings()); err != nil {
+			return fmt.Errorf("failed to merge %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// rulesFiles returns the files to load the rules from in order: the merged files of the config file used
+func rulesFiles(configFile string) []string {
+	if len(configFiles) > 0 && configFiles[len(configFiles)-1] == configFile {
+		return configFiles
+	}
+	return []string{configFile}
+}
+
+// mergeRules returns the rules with the overrides, an override replacing the rule of the same name in its place
+func mergeRules(rules, overrides radikron.Rules) radikron.Rules {
+	for _, o := range overrides {
+		i := slices.IndexFunc(rules, func(r *radikron.Rule) bool { return r.Name == o.Name })
+		if i < 0 {
+			rules = append(rules, o)
+		} else {
+			rules[i] = o
+		}
+	}
+	return rules
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/iomz/radikron"
+	"github.com/spf13/viper"
+)
+
+// ProfileDateLayout is the layout of the yearly dates a profile is active from and to
+const ProfileDateLayout = "01-02"
+
+// Profile overrides the rules during a yearly date range, e.g., for the program lineup changes (改編期)
+type Profile struct {
+	Name string
+	// From and To are the yearly dates (MM-DD) in JST the profile is active on, both inclusive;
+	// the range wraps around the new year if From is after To
+	From         string
+	To           string
+	Rules        radikron.Rules
+	DisableRules []string
+}
+
+// profileYAML represents a profile in YAML format
+type profileYAML struct {
+	From         string               `yaml:"from"`
+	To           string               `yaml:"to"`
+	DisableRules []string             `yaml:"disable-rules,omitempty"`
+	Rules        map[string]*ruleYAML `yaml:"rules,omitempty"`
+}
+
+// parseProfileDate parses a yearly date of a profile to its month and day as MMDD
+func parseProfileDate(s string) (int, error) {
+	t, err := time.Parse(ProfileDateLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q, expected MM-DD", s)
+	}
+	return int(t.Month())*100 + t.Day(), nil
+}
+
+// ActiveAt returns true if the profile is active on the date of t in JST
+func (p *Profile) ActiveAt(t time.Time) bool {
+	from, err := parseProfileDate(p.From)
+	if err != nil {
+		return false
+	}
+	to, err := parseProfileDate(p.To)
+	if err != nil {
+		return false
+	}
+	t = t.In(radikron.Location)
+	day := int(t.Month())*100 + t.Day()
+	if from <= to {
+		return from <= day && day <= to
+	}
+	return day >= from || day <= to
+}
+
+// loadProfiles loads the profiles from the configuration sorted by name, validating their dates and rules
+func loadProfiles(baseRules radikron.Rules) ([]Profile, error) {
+	names := make([]string, 0)
+	for name := range viper.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ruleNames := make(map[string]bool, len(baseRules))
+	for _, r := range baseRules {
+		ruleNames[r.Name] = true
+	}
+
+	profiles := make([]Profile, 0, len(names))
+	for _, name := range names {
+		key := fmt.Sprintf("profiles.%s", name)
+		p := Profile{
+			Name:         name,
+			From:         viper.GetString(key + ".from"),
+			To:           viper.GetString(key + ".to"),
+			DisableRules: viper.GetStringSlice(key + ".disable-rules"),
+		}
+		if _, err := parseProfileDate(p.From); err != nil {
+			return nil, fmt.Errorf("profile '%s': from: %w", name, err)
+		}
+		if _, err := parseProfileDate(p.To); err != nil {
+			return nil, fmt.Errorf("profile '%s': to: %w", name, err)
+		}
+		for _, r := range p.DisableRules {
+			if !ruleNames[r] {
+				return nil, fmt.Errorf("profile '%s' disables unknown rule: %s", name, r)
+			}
+		}
+		rules, err := loadRulesAt("profiles", name, "rules")
+		if err != nil {
+			return nil, fmt.Errorf("profile '%s': %w", name, err)
+		}
+		p.Rules = rules
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// applyProfiles returns the rules in effect at t: the rules of the active profiles come first, in the order
+// of the profile names, followed by the base rules neither replaced by a profile rule of the same name nor disabled
+func applyProfiles(baseRules radikron.Rules, profiles []Profile, t time.Time) (rules radikron.Rules, active []string) {
+	overridden := make(map[string]bool)
+	added := make(map[string]bool)
+	for i := range profiles {
+		p := &profiles[i]
+		if !p.ActiveAt(t) {
+			continue
+		}
+		active = append(active, p.Name)
+		for _, r := range p.Rules {
+			if !added[r.Name] {
+				rules = append(rules, r)
+			}
+			added[r.Name] = true
+			overridden[r.Name] = true
+		}
+		for _, name := range p.DisableRules {
+			overridden[name] = true
+		}
+	}
+	if len(active) == 0 {
+		return baseRules, nil
+	}
+	for _, r := range baseRules {
+		if !overridden[r.Name] {
+			rules = append(rules, r)
+		}
+	}
+	return rules, active
+}
+
+// profileTime returns the time to activate the profiles at: the current time of radikron if set, or now
+func profileTime() time.Time {
+	if radikron.CurrentTime.IsZero() {
+		return time.Now().In(radikron.Location)
+	}
+	return radikron.CurrentTime
+}
+
+// convertProfilesToYAML converts the profiles to YAML format
+func convertProfilesToYAML(profiles []Profile) map[string]*profileYAML {
+	if len(profiles) == 0 {
+		return nil
+	}
+	result := make(map[string]*profileYAML, len(profiles))
+	for _, p := range profiles {
+		result[p.Name] = &profileYAML{
+			From:         p.From,
+			To:           p.To,
+			DisableRules: p.DisableRules,
+			Rules:        convertRulesToYAML(p.Rules),
+		}
+	}
+	return result
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iomz/radikron"
+)
+
+func TestProfileActiveAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		date     time.Time
+		want     bool
+	}{
+		{"inside", "03-25", "04-10", time.Date(2025, 4, 1, 12, 0, 0, 0, radikron.Location), true},
+		{"first day", "03-25", "04-10", time.Date(2025, 3, 25, 0, 0, 0, 0, radikron.Location), true},
+		{"last day", "03-25", "04-10", time.Date(2025, 4, 10, 23, 59, 0, 0, radikron.Location), true},
+		{"outside", "03-25", "04-10", time.Date(2025, 4, 11, 0, 0, 0, 0, radikron.Location), false},
+		{"wrapped in december", "12-25", "01-05", time.Date(2025, 12, 31, 0, 0, 0, 0, radikron.Location), true},
+		{"wrapped in january", "12-25", "01-05", time.Date(2026, 1, 3, 0, 0, 0, 0, radikron.Location), true},
+		{"wrapped outside", "12-25", "01-05", time.Date(2026, 1, 6, 0, 0, 0, 0, radikron.Location), false},
+		// 2025-04-10 20:00 UTC is already 04-11 in JST
+		{"jst", "03-25", "04-10", time.Date(2025, 4, 10, 20, 0, 0, 0, time.UTC), false},
+		{"invalid", "13-01", "04-10", time.Date(2025, 4, 1, 0, 0, 0, 0, radikron.Location), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{From: tt.from, To: tt.to}
+			if got := p.ActiveAt(tt.date); got != tt.want {
+				t.Errorf("ActiveAt(%v) = %v, want %v", tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyProfiles(t *testing.T) {
+	rule := func(name string) *radikron.Rule {
+		r := &radikron.Rule{}
+		r.SetName(name)
+		return r
+	}
+	base := radikron.Rules{rule("news"), rule("drama"), rule("music")}
+	profiles := []Profile{
+		{Name: "a", From: "03-25", To: "04-10", Rules: radikron.Rules{rule("drama"), rule("special")}, DisableRules: []string{"news"}},
+		{Name: "b", From: "01-01", To: "12-31", Rules: radikron.Rules{rule("special"), rule("extra")}},
+		{Name: "c", From: "10-01", To: "10-10", Rules: radikron.Rules{rule("autumn")}},
+	}
+	names := func(rules radikron.Rules) string {
+		var s []string
+		for _, r := range rules {
+			s = append(s, r.Name)
+		}
+		return strings.Join(s, ",")
+	}
+
+	rules, active := applyProfiles(base, profiles, time.Date(2025, 4, 1, 0, 0, 0, 0, radikron.Location))
+	if got := strings.Join(active, ","); got != "a,b" {
+		t.Errorf("expected the profiles a and b to be active, got %s", got)
+	}
+	if got := names(rules); got != "drama,special,extra,music" {
+		t.Errorf("unexpected rules in effect: %s", got)
+	}
+
+	rules, active = applyProfiles(base, profiles[:1], time.Date(2025, 5, 1, 0, 0, 0, 0, radikron.Location))
+	if len(active) != 0 || names(rules) != "news,drama,music" {
+		t.Errorf("expected the base rules without an active profile, got %s (%v)", names(rules), active)
+	}
+}
+
+func TestLoadConfigProfiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yml")
+	content := `rules:
+  news:
+    station-id: TBS
+    title: Morning News
+  music:
+    keyword: jazz
+profiles:
+  spring:
+    from: "03-25"
+    to: "04-10"
+    disable-rules: [news]
+    rules:
+      news-new:
+        station-id: TBS
+        title: New Morning News
+      music:
+        keyword: bossa
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+
+	withCwd(t, tmpDir)
+
+	origTime := radikron.CurrentTime
+	t.Cleanup(func() { radikron.CurrentTime = origTime })
+	radikron.CurrentTime = time.Date(2025, 4, 1, 5, 0, 0, 0, radikron.Location)
+
+	t.Setenv(radikron.EnvRadicronHome, filepath.Join(tmpDir, "radiko_home"))
+	cfg, err := LoadConfig("config.yml")
+	if err != nil {
+		t.Fatalf("expected no error loading config, got: %v", err)
+	}
+	if len(cfg.ActiveProfiles) != 1 || cfg.ActiveProfiles[0] != "spring" {
+		t.Errorf("expected the spring profile to be active, got %v", cfg.ActiveProfiles)
+	}
+	if len(cfg.Rules) != 2 || cfg.Rules[0].Name != "news-new" || cfg.Rules[1].Name != "music" {
+		t.Fatalf("unexpected rules in effect: %+v", cfg.Rules)
+	}
+	if cfg.Rules[1].Keyword != "bossa" {
+		t.Errorf("expected the profile rule to replace the base rule, got keyword %s", cfg.Rules[1].Keyword)
+	}
+	if cfg.Rules[0].Title != "New Morning News" {
+		t.Errorf("expected the title of the profile rule, got %s", cfg.Rules[0].Title)
+	}
+
+	// the saved config keeps the base rules and the profiles apart
+	if err := cfg.SaveConfig(configFile); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("failed to read saved config: %v", err)
+	}
+	for _, want := range []string{"profiles:", "from: 03-25", "disable-rules:", "title: Morning News", "keyword: jazz"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q to be saved, got:\n%s", want, data)
+		}
+	}
+
+	radikron.CurrentTime = time.Date(2025, 5, 1, 5, 0, 0, 0, radikron.Location)
+	cfg, err = LoadConfig("config.yml")
+	if err != nil {
+		t.Fatalf("expected no error reloading config, got: %v", err)
+	}
+	if len(cfg.ActiveProfiles) != 0 || len(cfg.Rules) != 2 {
+		t.Fatalf("expected the base rules after the profile, got %+v (%v)", cfg.Rules, cfg.ActiveProfiles)
+	}
+	for _, r := range cfg.Rules {
+		if r.Name == "music" && r.Keyword != "jazz" {
+			t.Errorf("expected the base music rule after the profile, got keyword %s", r.Keyword)
+		}
+	}
+
+	if err := os.WriteFile(configFile, []byte("profiles:\n  bad:\n    from: \"04-31\"\n    to: \"05-01\"\n"), 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+	if _, err := LoadConfig("config.yml"); err == nil {
+		t.Error("expected an error for an invalid profile date")
+	}
+	if err := os.WriteFile(configFile, []byte("profiles:\n  bad:\n    from: \"04-01\"\n    to: \"05-01\"\n    disable-rules: [missing]\n"), 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+	if _, err := LoadConfig("config.yml"); err == nil {
+		t.Error("expected an error for disabling an unknown rule")
+	}
+}
+
+func TestLoadConfigProfilesTOML(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.toml")
+	content := `[rules.news]
+station-id = "TBS"
+
+[profiles.Autumn]
+from = "09-28"
+to = "10-05"
+
+[profiles.Autumn.rules.zeta]
+title = "Zeta"
+
+[profiles.Autumn.rules.alpha]
+title = "Alpha"
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+
+	withCwd(t, tmpDir)
+
+	origTime := radikron.CurrentTime
+	t.Cleanup(func() { radikron.CurrentTime = origTime })
+	radikron.CurrentTime = time.Date(2025, 10, 1, 5, 0, 0, 0, radikron.Location)
+
+	t.Setenv(radikron.EnvRadicronHome, filepath.Join(tmpDir, "radiko_home"))
+	cfg, err := LoadConfig("config.toml")
+	if err != nil {
+		t.Fatalf("expected no error loading config, got: %v", err)
+	}
+	var names []string
+	for _, r := range cfg.Rules {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, ","); got != "zeta,alpha,news" {
+		t.Errorf("expected the profile rules in the file order first, got %s", got)
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/iomz/radikron"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// configKeys are the keys allowed at the top level of the config file
+	configKeys = yamlKeys(configYAML{})
+	// ruleKeys are the keys allowed in a rule
+	ruleKeys = yamlKeys(ruleYAML{})
+	// profileKeys are the keys allowed in a profile
+	profileKeys = yamlKeys(profileYAML{})
+)
+
+// yamlKeys returns the set of the yaml keys of the struct fields, the same keys SaveConfig writes
+func yamlKeys(v any) map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// ValidationError is a problem found in the config file, at the line if known
+type ValidationError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Msg)
+}
+
+// schemaNode is a mapping in the config file with the lines of its keys, independent of the format
+type schemaNode struct {
+	keys []schemaKey
+}
+
+// schemaKey is a key of a mapping in the config file, with its value if it is a mapping
+type schemaKey struct {
+	name string
+	line int // 0 if unknown
+	node *schemaNode
+}
+
+// lookup returns the key at the path of the keys matched case-insensitively like viper
+func (n *schemaNode) lookup(path ...string) (schemaKey, bool) {
+	var found schemaKey
+	for _, name := range path {
+		if n == nil {
+			return schemaKey{}, false
+		}
+		ok := false
+		for _, k := range n.keys {
+			if strings.EqualFold(k.name, name) {
+				found, ok = k, true
+				break
+			}
+		}
+		if !ok {
+			return schemaKey{}, false
+		}
+		n = found.node
+	}
+	return found, true
+}
+
+// loadSchema reads the mappings of the config file by its format; only YAML has the lines of the keys
+func loadSchema(configFile string) (*schemaNode, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".toml":
+		var m map[string]any
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		return mapSchema(m), nil
+	case ".json":
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		return mapSchema(m), nil
+	default:
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		return yamlSchema(&root), nil
+	}
+}
+
+// yamlSchema converts a YAML node to a schemaNode, or nil if it is not a mapping
+func yamlSchema(n *yaml.Node) *schemaNode {
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return &schemaNode{}
+		}
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	node := &schemaNode{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		node.keys = append(node.keys, schemaKey{
+			name: n.Content[i].Value,
+			line: n.Content[i].Line,
+			node: yamlSchema(n.Content[i+1]),
+		})
+	}
+	return node
+}
+
+// mapSchema converts a decoded mapping to a schemaNode in the order of the keys
+func mapSchema(m map[string]any) *schemaNode {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	node := &schemaNode{}
+	for _, name := range names {
+		key := schemaKey{name: name}
+		if sub, ok := m[name].(map[string]any); ok {
+			key.node = mapSchema(sub)
+		}
+		node.keys = append(node.keys, key)
+	}
+	return node
+}
+
+// validateKeys returns the errors of all the unknown keys at the top level, in the rules, and in the profiles,
+// which viper would silently ignore, e.g., a typo like staton-id making a rule match every station
+func validateKeys(file string, root *schemaNode) error {
+	var errs []error
+	check := func(n *schemaNode, allowed map[string]bool, where string) {
+		if n == nil {
+			return
+		}
+		for _, k := range n.keys {
+			if allowed[strings.ToLower(k.name)] {
+				continue
+			}
+			msg := fmt.Sprintf("unknown key %q%s", k.name, where)
+			if s := suggestKey(k.name, allowed); s != "" {
+				msg += fmt.Sprintf(", did you mean %q?", s)
+			}
+			errs = append(errs, &ValidationError{File: file, Line: k.line, Msg: msg})
+		}
+	}
+	checkRules := func(rules schemaKey, where string) {
+		if rules.node == nil {
+			return
+		}
+		for _, r := range rules.node.keys {
+			check(r.node, ruleKeys, fmt.Sprintf(" in rule[%s]%s", r.name, where))
+		}
+	}
+
+	check(root, configKeys, "")
+	if rules, ok := root.lookup("rules"); ok {
+		checkRules(rules, "")
+	}
+	if profiles, ok := root.lookup("profiles"); ok && profiles.node != nil {
+		for _, p := range profiles.node.keys {
+			check(p.node, profileKeys, fmt.Sprintf(" in profile[%s]", p.name))
+			if rules, ok := p.node.lookup("rules"); ok {
+				checkRules(rules, fmt.Sprintf(" of profile[%s]", p.name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateRules returns the errors of the rules which match every program or have an invalid dow or window,
+// at the lines of their names in the last of the merged files defining them;
+// prefix is the path of the keys to the rules in the config files
+func validateRules(schemas []configSchema, rules radikron.Rules, prefix ...string) error {
+	var errs []error
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			file, line := schemas[len(schemas)-1].file, 0
+			for i := len(schemas) - 1; i >= 0; i-- {
+				if key, ok := schemas[i].root.lookup(append(prefix, r.Name)...); ok {
+					file, line = schemas[i].file, key.line
+					break
+				}
+			}
+			errs = append(errs, &ValidationError{File: file, Line: line, Msg: err.Error()})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateConfigRules validates the base rules and the rules of all the profiles, active or not
+func validateConfigRules(schemas []configSchema, c *Config) error {
+	errs := []error{validateRules(schemas, c.BaseRules, "rules")}
+	for _, p := range c.Profiles {
+		errs = append(errs, validateRules(schemas, p.Rules, "profiles", p.Name, "rules"))
+	}
+	return errors.Join(errs...)
+}
+
+// suggestKey returns the allowed key closest to the unknown key within a few edits, or "" if none
+func suggestKey(name string, allowed map[string]bool) string {
+	best, bestDistance := "", 3 // suggest only within 2 edits
+	for key := range allowed {
+		if d := editDistance(strings.ToLower(name), key); d < bestDistance || (d == bestDistance && best != "" && key < best) {
+			best, bestDistance = key, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iomz/radikron"
+)
+
+func TestLoadConfigUnknownKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yml")
+	content := `area-id: JP13
+minimum-ouput-size: 2
+rules:
+  tbs:
+    staton-id: TBS
+    title: News
+profiles:
+  spring:
+    from: "03-25"
+    untill: "04-10"
+    to: "04-10"
+    rules:
+      extra:
+        keywrd: jazz
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+
+	withCwd(t, tmpDir)
+
+	t.Setenv(radikron.EnvRadicronHome, filepath.Join(tmpDir, "radiko_home"))
+	_, err := LoadConfig("config.yml")
+	if err == nil {
+		t.Fatal("expected an error for the unknown keys")
+	}
+	for _, want := range []string{
+		`config.yml:2: unknown key "minimum-ouput-size", did you mean "minimum-output-size"?`,
+		`config.yml:5: unknown key "staton-id" in rule[tbs], did you mean "station-id"?`,
+		`config.yml:10: unknown key "untill" in profile[spring]`,
+		`config.yml:14: unknown key "keywrd" in rule[extra] of profile[spring], did you mean "keyword"?`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in the error, got:\n%v", want, err)
+		}
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("expected a ValidationError, got %T", err)
+	}
+}
+
+func TestLoadConfigUnknownKeysTOML(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.toml")
+	content := `[rules.tbs]
+staton-id = "TBS"
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+
+	withCwd(t, tmpDir)
+
+	t.Setenv(radikron.EnvRadicronHome, filepath.Join(tmpDir, "radiko_home"))
+	_, err := LoadConfig("config.toml")
+	if err == nil || !strings.Contains(err.Error(), `config.toml: unknown key "staton-id" in rule[tbs]`) {
+		t.Errorf("expected an error for the unknown key without a line, got %v", err)
+	}
+}
+
+func TestLoadConfigInvalidRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yml")
+	content := `rules:
+  ok:
+    title: News
+  everything:
+    folder: all
+  weekdays:
+    title: Drama
+    dow: [monday]
+  recent:
+    keyword: jazz
+    window: 2 days
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+
+	withCwd(t, tmpDir)
+
+	t.Setenv(radikron.EnvRadicronHome, filepath.Join(tmpDir, "radiko_home"))
+	_, err := LoadConfig("config.yml")
+	if err == nil {
+		t.Fatal("expected an error for the invalid rules")
+	}
+	for _, want := range []string{
+		"config.yml:4: rule[everything] has no criteria",
+		`config.yml:6: rule[weekdays] has an invalid dow "monday"`,
+		`config.yml:9: rule[recent] has an invalid window "2 days"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in the error, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadConfigTemplate(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "config.yml.template"))
+	if err != nil {
+		t.Fatalf("failed to read the template: %v", err)
+	}
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "config.yml"), data, 0600); err != nil {
+		t.Fatalf("failed to create test config: %v", err)
+	}
+
+	withCwd(t, tmpDir)
+
+	t.Setenv(radikron.EnvRadicronHome, filepath.Join(tmpDir, "radiko_home"))
+	if _, err := LoadConfig("config.yml"); err != nil {
+		t.Errorf("expected the template to be valid, got: %v", err)
+	}
+}
+
+func TestSuggestKey(t *testing.T) {
+	if got := suggestKey("staton-id", ruleKeys); got != "station-id" {
+		t.Errorf("suggestKey(staton-id) = %q, want station-id", got)
+	}
+	if got := suggestKey("completely-different", ruleKeys); got != "" {
+		t.Errorf("suggestKey(completely-different) = %q, want none", got)
+	}
+}
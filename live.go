@@ -0,0 +1,286 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// RecordsLive returns true if the program is recorded from the live stream on air by the mode of its rule:
+// always in RuleModeLive, and in RuleModeAuto if its station has no timefree
+func (a *Asset) RecordsLive(prog *Prog) bool {
+	switch prog.Mode {
+	case RuleModeLive:
+		return true
+	case RuleModeTimefree:
+		return false
+	}
+	station, ok := a.Stations[prog.StationID]
+	return ok && !station.TimeFree
+}
+
+// planLiveRecording plans the live recording of the program for RecordLive,
+// skipping the program already on air, whose beginning is no longer in the stream, and the one over
+func planLiveRecording(ctx context.Context, prog *Prog, startTime, endTime time.Time) {
+	asset := GetAsset(ctx)
+	if !endTime.After(CurrentTime) {
+		return
+	}
+	if !startTime.After(CurrentTime) {
+		emitDownloadSkipped(ctx, "live program on air", prog.StationID, prog.Title, prog.Ft)
+		emitLogMessage(ctx, "info", fmt.Sprintf(
+			"missed the start of the live program, skipping [%s]%s (%s)", prog.StationID, prog.Title, prog.Ft))
+		return
+	}
+	if asset.ReadOnly {
+		emitProgramMatched(ctx, prog.StationID, prog.Title, prog.Ft, prog.RuleName)
+		return
+	}
+	if asset.NotifyUpcoming {
+		notifyUpcomingProgram(ctx, prog, startTime.Add(-LiveLeadTime))
+	}
+
+	scheduleProgram(asset, prog)
+
+	asset.mu.Lock()
+	defer asset.mu.Unlock()
+	for _, p := range asset.livePlans {
+		if p.StationID == prog.StationID && p.Ft == prog.Ft {
+			return
+		}
+	}
+	asset.livePlans = append(asset.livePlans, prog)
+}
+
+// splitLivePlan returns the planned programs to join the stream of by the time the recordings before them end,
+// starting from now, in the order of the start time, and the later ones
+func splitLivePlan(progs Progs, now time.Time) (due, later Progs) {
+	progs = append(Progs(nil), progs...)
+	sort.SliceStable(progs, func(i, j int) bool { return progs[i].Ft < progs[j].Ft })
+	horizon := now
+	for i, p := range progs {
+		start, err := ParseDatetime(p.Ft)
+		if err != nil {
+			continue
+		}
+		if start.Add(-LiveLeadTime).After(horizon) {
+			return due, progs[i:]
+		}
+		due = append(due, p)
+		if end, err := ParseDatetime(p.To); err == nil && end.After(horizon) {
+			horizon = end
+		}
+	}
+	return due, nil
+}
+
+// RecordLive starts the live recordings planned by Download which join the stream before the ones in progress end,
+// within MaxLiveRecordings at once, and sets the next fetch time to join the stream of the later ones.
+// Each recording waits in the background for LiveLeadTime before its program, and is added to wg until saved.
+func RecordLive(ctx context.Context, wg *sync.WaitGroup) {
+	asset := GetAsset(ctx)
+	if asset == nil {
+		return
+	}
+	asset.mu.Lock()
+	planned := asset.livePlans
+	asset.livePlans = nil
+	asset.mu.Unlock()
+
+	due, later := splitLivePlan(planned, CurrentTime)
+	if len(later) > 0 {
+		if start, err := ParseDatetime(later[0].Ft); err == nil {
+			next := start.Add(-LiveLeadTime)
+			if asset.NextFetchTime == nil || asset.NextFetchTime.After(next) {
+				asset.NextFetchTime = &next
+			}
+		}
+	}
+
+	for _, prog := range ResolveLiveConflicts(ctx, due) {
+		startTime, err := ParseDatetime(prog.Ft)
+		if err != nil {
+			continue
+		}
+		emitLogMessage(ctx, "info", fmt.Sprintf(
+			"recording [%s]%s live at %s for rule[%s]", prog.StationID, prog.Title, prog.Ft, prog.RuleName))
+		wg.Add(1)
+		go func(prog *Prog) {
+			defer wg.Done()
+			if !waitUntil(ctx, startTime.Add(-LiveLeadTime)) {
+				return
+			}
+			if err := startDownload(ctx, wg, prog, startTime, true); err != nil {
+				log.Printf("live recording failed: %s", err)
+			}
+		}(prog)
+	}
+}
+
+// waitUntil blocks until t, returning false if ctx is done first
+func waitUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// recordLiveProgram records the program from the live stream at prog.M3U8 until LiveLeadTime after it ends,
+// and saves the output like downloadProgram; the recording canceled on air is discarded, as it cannot resume
+func recordLiveProgram(
+	ctx context.Context, // the context for the request
+	wg *sync.WaitGroup, // the wg to notify
+	prog *Prog, // the program metadata
+	output *radigo.OutputConfig, // the file configuration
+	finish func(downloaded bool), // called with the result when finished (optional)
+) {
+	defer wg.Done()
+	completed := false
+	saved := false
+	// transcribe after finish releases the program (e.g., the premium stream), but before wg.Done
+	defer func() {
+		if saved {
+			transcribeOutput(ctx, prog, output)
+		}
+	}()
+	if finish != nil {
+		defer func() { finish(completed) }()
+	}
+
+	endTime, err := ParseDatetime(prog.To)
+	if err != nil {
+		log.Printf("invalid end time format '%s': %s", prog.To, err)
+		return
+	}
+
+	// the cleanup of the tmp dir must not see the dir before it is marked in use
+	activeAACDirsMu.Lock()
+	aacDir, err := tempAACDir()
+	if err == nil {
+		activeAACDirs[aacDir]++
+	}
+	activeAACDirsMu.Unlock()
+	if err != nil {
+		log.Printf("failed to create the aac dir: %s", err)
+		return
+	}
+	defer releaseAACDir(aacDir)
+	defer os.RemoveAll(aacDir)
+
+	started := time.Now()
+	progress := newDownloadProgress(ctx, prog, 0, 0)
+	concatedFile, err := recordLiveStream(ctx, prog, aacDir, endTime.Add(LiveLeadTime), progress)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("live recording canceled [%s]%s: %s", prog.StationID, prog.Title, err)
+			return
+		}
+		log.Printf("failed to record the live stream: %s", err)
+		return
+	}
+
+	emitDownloadCompleted(ctx, prog, output.AbsPath())
+	bytes, retries := progress.stats()
+	logDownloadRecord(ctx, newDownloadRecord(prog, output.AbsPath(), started, bytes, retries))
+
+	// Encode after all the downloads complete, not to delay the next fetch, or after the quiet hours
+	if asset := GetAsset(ctx); asset != nil && output.AudioFormat() == radigo.AudioFormatMP3 &&
+		(asset.DeferredEncoding || asset.ActiveQuietHours(throttleNow()) != nil) {
+		if err = deferEncoding(prog, concatedFile, output); err != nil {
+			log.Printf("failed to queue the encoding: %s", err)
+			return
+		}
+		completed = true
+		emitLogMessage(ctx, "info", fmt.Sprintf("queued the encoding of [%s]%s", prog.StationID, prog.Title))
+		return
+	}
+
+	completed = finalizeOutput(ctx, prog, concatedFile, output)
+	saved = completed
+}
+
+// recordLiveStream appends the new segments of the live playlist at prog.M3U8 to the concatenated file in aacDir
+// every LivePollInterval until stop, and returns the file.
+// A segment failing every retry is skipped with a warning, as the stream moves on without it.
+// The chapters split at the discontinuities of the recorded segments are set to the program if the asset has AdBreakChapters.
+func recordLiveStream(ctx context.Context, prog *Prog, aacDir string, stop time.Time, progress *downloadProgress) (string, error) {
+	concatedFile := filepath.Join(aacDir, concatedFileName)
+	f, err := os.OpenFile(concatedFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, OutputFilePermissions)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	recorded := map[string]bool{}
+	var appended []mediaSegment
+	for {
+		segments, err := getMediaSegmentsFromM3U8(ctx, prog.M3U8)
+		if errors.Is(err, errAuthExpired) {
+			// the token expired mid-program: join the stream again with a new token
+			if prog.M3U8, err = liveStreamM3U8(ctx, prog); err == nil {
+				segments, err = getMediaSegmentsFromM3U8(ctx, prog.M3U8)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+
+		for _, s := range segments {
+			link := s.uri
+			name := segmentFileName(link)
+			if recorded[name] {
+				continue
+			}
+			recorded[name] = true
+			attempts := 0
+			err := currentRetryPolicy(ctx).Do(ctx, func() error {
+				attempts++
+				return downloadLink(ctx, link, aacDir)
+			})
+			progress.retry(attempts - 1)
+			if err != nil {
+				if ctx.Err() != nil {
+					return "", ctx.Err()
+				}
+				emitLogMessage(ctx, "warn", fmt.Sprintf("failed to record a segment of [%s]%s: %s", prog.StationID, prog.Title, err))
+				continue
+			}
+			segment := filepath.Join(aacDir, name)
+			n, err := appendSegment(f, segment)
+			if err != nil {
+				return "", fmt.Errorf("failed to append %s: %w", name, err)
+			}
+			if err := os.Remove(segment); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+			progress.add(n)
+			appended = append(appended, s)
+		}
+
+		if !time.Now().Before(stop) {
+			break
+		}
+		if !waitUntil(ctx, time.Now().Add(LivePollInterval)) {
+			return "", ctx.Err()
+		}
+	}
+	if len(recorded) == 0 {
+		return "", errors.New("no segments in the live stream")
+	}
+	if asset := GetAsset(ctx); asset != nil && asset.AdBreakChapters {
+		prog.Chapters = chapterEnds(appended)
+	}
+	return concatedFile, f.Close()
+}
@@ -0,0 +1,173 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecordsLive(t *testing.T) {
+	asset := &Asset{Stations: Stations{
+		"TBS":      {TimeFree: true},
+		"HOUSOU-D": {TimeFree: false},
+	}}
+	tests := []struct {
+		stationID string
+		mode      string
+		want      bool
+	}{
+		{"TBS", "", false},
+		{"TBS", RuleModeAuto, false},
+		{"TBS", RuleModeLive, true},
+		{"HOUSOU-D", "", true},
+		{"HOUSOU-D", RuleModeTimefree, false},
+		{"UNKNOWN", RuleModeAuto, false},
+	}
+	for _, tt := range tests {
+		if got := asset.RecordsLive(&Prog{StationID: tt.stationID, Mode: tt.mode}); got != tt.want {
+			t.Errorf("RecordsLive(%s, %q) = %v, want %v", tt.stationID, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestPlanLiveRecording(t *testing.T) {
+	defer func(t time.Time) { CurrentTime = t }(CurrentTime)
+	CurrentTime = time.Date(2023, 6, 5, 12, 0, 0, 0, Location)
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	asset := &Asset{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	progs := Progs{
+		{StationID: "FMT", Title: "Over", Ft: "20230605100000", To: "20230605110000"},
+		{StationID: "FMT", Title: "On Air", Ft: "20230605113000", To: "20230605123000"},
+		{StationID: "FMT", Title: "Music", Ft: "20230605130000", To: "20230605140000"},
+		{StationID: "FMT", Title: "Music", Ft: "20230605130000", To: "20230605140000"}, // from the queue
+	}
+	for _, p := range progs {
+		if err := Download(ctx, &sync.WaitGroup{}, &Prog{StationID: p.StationID, Title: p.Title, Ft: p.Ft, To: p.To, Mode: RuleModeLive}); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+	}
+	asset.mu.Lock()
+	planned := asset.livePlans
+	asset.mu.Unlock()
+	if len(planned) != 1 || planned[0].Title != "Music" {
+		t.Errorf("expected only the program not yet on air to be planned once, got %+v", planned)
+	}
+	if asset.NextFetchTime != nil {
+		t.Errorf("expected the live programs not to set the next fetch time, got %v", asset.NextFetchTime)
+	}
+}
+
+func TestSplitLivePlan(t *testing.T) {
+	now := time.Date(2023, 6, 5, 12, 59, 0, 0, Location)
+	first := &Prog{Title: "First", Ft: "20230605130000", To: "20230605140000"}
+	during := &Prog{Title: "During", Ft: "20230605133000", To: "20230605150000"}
+	next := &Prog{Title: "Next", Ft: "20230605150100", To: "20230605160000"}
+	later := &Prog{Title: "Later", Ft: "20230605170000", To: "20230605180000"}
+
+	due, rest := splitLivePlan(Progs{later, during, next, first}, now)
+	if len(due) != 3 || due[0] != first || due[1] != during || due[2] != next {
+		t.Errorf("expected the programs starting before the recordings end, got %+v", due)
+	}
+	if len(rest) != 1 || rest[0] != later {
+		t.Errorf("expected the later program to wait for the next fetch, got %+v", rest)
+	}
+
+	due, rest = splitLivePlan(Progs{later}, now)
+	if len(due) != 0 || len(rest) != 1 {
+		t.Errorf("expected no program due, got %+v, %+v", due, rest)
+	}
+}
+
+func TestRecordLive_NextFetchTime(t *testing.T) {
+	defer func(t time.Time) { CurrentTime = t }(CurrentTime)
+	CurrentTime = time.Now().In(Location)
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	asset := &Asset{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	start := CurrentTime.Add(time.Hour)
+	prog := &Prog{
+		StationID: "FMT",
+		Title:     "Music",
+		Ft:        start.Format(DatetimeLayout),
+		To:        start.Add(time.Hour).Format(DatetimeLayout),
+		Mode:      RuleModeLive,
+	}
+	if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	wg := &sync.WaitGroup{}
+	RecordLive(ctx, wg)
+	wg.Wait()
+
+	want := start.Truncate(time.Second).Add(-LiveLeadTime)
+	if asset.NextFetchTime == nil || !asset.NextFetchTime.Equal(want) {
+		t.Errorf("NextFetchTime = %v, want %v", asset.NextFetchTime, want)
+	}
+	asset.mu.Lock()
+	defer asset.mu.Unlock()
+	if asset.livePlans != nil {
+		t.Error("expected the plan to be taken by RecordLive")
+	}
+}
+
+func TestRecordLiveStream(t *testing.T) {
+	segment := append(id3v2Tag("PRIV timestamp"), bytes.Repeat([]byte{0xff}, 512)...)
+	var polls atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".m3u8") {
+			// the live playlist slides by a segment on every poll
+			n := polls.Add(1)
+			fmt.Fprintf(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXTINF:5.0,\n%s/%d.aac\n#EXTINF:5.0,\n%s/%d.aac\n",
+				n, server.URL, n, server.URL, n+1)
+			return
+		}
+		if r.URL.Path == "/2.aac" {
+			// a segment truncated for good
+			w.Header().Set("Content-Length", "1024")
+			_, _ = w.Write(segment[:10])
+			return
+		}
+		_, _ = w.Write(segment)
+	}))
+	defer server.Close()
+
+	aacDir := t.TempDir()
+	asset := &Asset{Retry: RetryPolicy{MaxAttempts: 1}}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	prog := &Prog{StationID: "FMT", Title: "Music", M3U8: server.URL + "/playlist.m3u8"}
+
+	// the recording stops after the first poll past the stop time
+	concated, err := recordLiveStream(ctx, prog, aacDir, time.Now(), nil)
+	if err != nil {
+		t.Fatalf("recordLiveStream failed: %v", err)
+	}
+	blob, err := os.ReadFile(concated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1.aac is saved without its tag, and 2.aac is skipped
+	if len(blob) != 512 {
+		t.Errorf("recorded %d bytes, want 512", len(blob))
+	}
+	if entries, _ := os.ReadDir(aacDir); len(entries) != 1 {
+		t.Errorf("expected only the concatenated file in the aac dir, got %d entries", len(entries))
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := recordLiveStream(canceled, prog, t.TempDir(), time.Now().Add(time.Hour), nil); err == nil {
+		t.Error("expected the canceled recording to fail")
+	}
+}
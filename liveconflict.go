@@ -0,0 +1,108 @@
+package radikron
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// liveRecording is a program planned to record live with the priority of its rule
+type liveRecording struct {
+	prog     *Prog
+	start    time.Time
+	end      time.Time
+	priority int // the index of the rule, the earlier the higher
+}
+
+// rulePriority returns the index of the rule of the program in the rules (the first rule wins a program),
+// or len(rules) if the rule is not found
+func rulePriority(rules Rules, prog *Prog) int {
+	for i, r := range rules {
+		if r.Name == prog.RuleName {
+			return i
+		}
+	}
+	return len(rules)
+}
+
+// lowerPriority returns true if a is dropped before b: the later rule, then the later start
+func (a liveRecording) lowerPriority(b liveRecording) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if !a.start.Equal(b.start) {
+		return a.start.After(b.start)
+	}
+	return a.prog.StationID > b.prog.StationID
+}
+
+// resolveLiveConflicts returns the programs to record live without more than limit of them at once
+// (0 for no limit), dropping the ones of the later rules from each overlap, and the dropped ones
+func resolveLiveConflicts(progs Progs, rules Rules, limit int) (kept, dropped Progs) {
+	if limit <= 0 {
+		return progs, nil
+	}
+	recs := make([]liveRecording, 0, len(progs))
+	for _, p := range progs {
+		start, err := ParseDatetime(p.Ft)
+		if err != nil {
+			// keep the program which cannot be scheduled, not to drop it silently
+			kept = append(kept, p)
+			continue
+		}
+		end, err := ParseDatetime(p.To)
+		if err != nil || !end.After(start) {
+			end = start
+		}
+		recs = append(recs, liveRecording{prog: p, start: start, end: end, priority: rulePriority(rules, p)})
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].start.Before(recs[j].start) })
+
+	// sweep the recordings by the start time, keeping at most limit of them on air
+	var onAir []liveRecording
+	for _, r := range recs {
+		active := onAir[:0]
+		for _, a := range onAir {
+			if a.end.After(r.start) {
+				active = append(active, a)
+			}
+		}
+		onAir = append(active, r)
+		if len(onAir) <= limit {
+			continue
+		}
+		lowest := 0
+		for i := range onAir {
+			if onAir[i].lowerPriority(onAir[lowest]) {
+				lowest = i
+			}
+		}
+		dropped = append(dropped, onAir[lowest].prog)
+		onAir = append(onAir[:lowest], onAir[lowest+1:]...)
+	}
+
+	droppedSet := make(map[*Prog]bool, len(dropped))
+	for _, p := range dropped {
+		droppedSet[p] = true
+	}
+	for _, r := range recs {
+		if !droppedSet[r.prog] {
+			kept = append(kept, r.prog)
+		}
+	}
+	return kept, dropped
+}
+
+// ResolveLiveConflicts returns the programs to record live within the asset's MaxLiveRecordings at once,
+// dropping the ones of the later rules from each overlap, and emits a conflict event listing the dropped ones
+func ResolveLiveConflicts(ctx context.Context, progs Progs) Progs {
+	asset := GetAsset(ctx)
+	if asset == nil {
+		return progs
+	}
+	kept, dropped := resolveLiveConflicts(progs, asset.Rules, asset.MaxLiveRecordings)
+	if len(dropped) > 0 {
+		emitScheduleConflict(ctx, dropped, asset.MaxLiveRecordings)
+	}
+	return kept
+}
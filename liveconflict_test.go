@@ -0,0 +1,49 @@
+package radikron
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveLiveConflicts(t *testing.T) {
+	rules := Rules{{Name: "music"}, {Name: "talk"}, {Name: "news"}}
+	music := &Prog{StationID: "FMT", Title: "Music", Ft: "20230605130000", To: "20230605150000", RuleName: "music"}
+	talk := &Prog{StationID: "TBS", Title: "Talk", Ft: "20230605140000", To: "20230605160000", RuleName: "talk"}
+	news := &Prog{StationID: "QRR", Title: "News", Ft: "20230605143000", To: "20230605144500", RuleName: "news"}
+	later := &Prog{StationID: "LFR", Title: "Later", Ft: "20230605160000", To: "20230605170000", RuleName: "news"}
+
+	kept, dropped := resolveLiveConflicts(Progs{news, talk, music, later}, rules, 2)
+	if len(dropped) != 1 || dropped[0] != news {
+		t.Errorf("expected the news of the last rule to be dropped, got %+v", dropped)
+	}
+	if len(kept) != 3 || kept[0] != music || kept[1] != talk || kept[2] != later {
+		t.Errorf("expected the others in the start order, got %+v", kept)
+	}
+
+	_, dropped = resolveLiveConflicts(Progs{news, talk, music, later}, rules, 1)
+	if len(dropped) != 2 || dropped[0] != talk || dropped[1] != news {
+		t.Errorf("expected the talk and the news to be dropped for the music, got %+v", dropped)
+	}
+
+	if kept, dropped := resolveLiveConflicts(Progs{news, talk, music}, rules, 0); len(kept) != 3 || dropped != nil {
+		t.Errorf("expected no limit, got %+v, %+v", kept, dropped)
+	}
+}
+
+func TestResolveLiveConflicts_Event(t *testing.T) {
+	asset := &Asset{Rules: Rules{{Name: "first"}, {Name: "second"}}, MaxLiveRecordings: 1}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	first := &Prog{StationID: "FMT", Title: "First", Ft: "20230605130000", To: "20230605140000", RuleName: "first"}
+	second := &Prog{StationID: "TBS", Title: "Second", Ft: "20230605130000", To: "20230605140000", RuleName: "second"}
+	kept := ResolveLiveConflicts(ctx, Progs{second, first})
+	if len(kept) != 1 || kept[0] != first {
+		t.Errorf("expected the program of the first rule, got %+v", kept)
+	}
+	if len(emitter.scheduleConflicts) != 1 || emitter.scheduleConflicts[0].limit != 1 ||
+		len(emitter.scheduleConflicts[0].dropped) != 1 || emitter.scheduleConflicts[0].dropped[0] != second {
+		t.Errorf("expected a conflict event with the dropped program, got %+v", emitter.scheduleConflicts)
+	}
+}
@@ -0,0 +1,80 @@
+package radikron
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// LogDedupWindow is how long the repeats of a log message are collapsed into a summary after its first emission
+const LogDedupWindow = time.Minute
+
+// logURLPattern matches the URLs in the log messages, e.g., of the segments, which differ among the repeats
+var logURLPattern = regexp.MustCompile(`https?://[^\s"']+`)
+
+// logDedup collapses the repeats of the log messages emitted by emitLogMessage
+var logDedup = newLogDeduper(LogDedupWindow)
+
+// logDeduper emits the first of the identical log messages and collapses their repeats in its window
+// into one "message ×N in the last minute" at the end of the window
+type logDeduper struct {
+	mu      sync.Mutex
+	window  time.Duration
+	repeats map[logDedupKey]*logRepeat
+}
+
+// logDedupKey identifies the identical messages of an emitter, ignoring the URLs in them
+type logDedupKey struct {
+	emitter EventEmitter
+	level   string
+	message string
+}
+
+// logRepeat is a message emitted in the window with the number of its emissions
+type logRepeat struct {
+	message string
+	count   int
+}
+
+func newLogDeduper(window time.Duration) *logDeduper {
+	return &logDeduper{
+		window:  window,
+		repeats: make(map[logDedupKey]*logRepeat),
+	}
+}
+
+// suppress returns true if the message is a repeat in the window to be collapsed,
+// or false for the first message, which opens the window
+func (d *logDeduper) suppress(emitter EventEmitter, level, message string) bool {
+	key := logDedupKey{emitter: emitter, level: level, message: logURLPattern.ReplaceAllString(message, "<url>")}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r, ok := d.repeats[key]; ok {
+		r.count++
+		return true
+	}
+	d.repeats[key] = &logRepeat{message: message, count: 1}
+	time.AfterFunc(d.window, func() { d.flush(key) })
+	return false
+}
+
+// flush closes the window of the message, emitting the summary of its repeats if any
+func (d *logDeduper) flush(key logDedupKey) {
+	d.mu.Lock()
+	r := d.repeats[key]
+	delete(d.repeats, key)
+	d.mu.Unlock()
+	if r == nil || r.count < 2 {
+		return
+	}
+	key.emitter.EmitLogMessage(key.level, fmt.Sprintf("%s ×%d in the last %s", r.message, r.count, d.windowString()))
+}
+
+func (d *logDeduper) windowString() string {
+	if d.window == time.Minute {
+		return "minute"
+	}
+	return d.window.String()
+}
@@ -0,0 +1,80 @@
+package radikron
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncLogEmitter records the log messages, safe for the summaries emitted from the timers
+type syncLogEmitter struct {
+	LogEventEmitter
+	mu       sync.Mutex
+	messages []string
+}
+
+func (e *syncLogEmitter) EmitLogMessage(level, message string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.messages = append(e.messages, level+": "+message)
+}
+
+func (e *syncLogEmitter) logged() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string{}, e.messages...)
+}
+
+func TestLogDeduper(t *testing.T) {
+	d := newLogDeduper(50 * time.Millisecond)
+	emitter := &syncLogEmitter{}
+	emit := func(level, message string) {
+		if !d.suppress(emitter, level, message) {
+			emitter.EmitLogMessage(level, message)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		emit("warn", "failed to download: GET https://radiko.jp/seg"+strings.Repeat("1", i)+".aac: 404")
+	}
+	emit("warn", "another warning")
+	emit("error", "another warning")
+	if got := emitter.logged(); len(got) != 3 {
+		t.Fatalf("expected the first of each message only, got %v", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	got := emitter.logged()
+	if len(got) != 4 {
+		t.Fatalf("expected one summary of the repeats, got %v", got)
+	}
+	if want := "warn: failed to download: GET https://radiko.jp/seg.aac: 404 ×5 in the last 50ms"; got[3] != want {
+		t.Errorf("unexpected summary %q, want %q", got[3], want)
+	}
+
+	// the window is over, so the message is emitted again
+	emit("warn", "another warning")
+	if got := emitter.logged(); len(got) != 5 {
+		t.Errorf("expected the message after the window to be emitted, got %v", got)
+	}
+}
+
+func TestEmitLogMessage_Dedup(t *testing.T) {
+	emitter := &syncLogEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
+	for i := 0; i < 3; i++ {
+		emitLogMessage(ctx, "warn", "the same station failing every chunk")
+	}
+	if got := emitter.logged(); len(got) != 1 {
+		t.Errorf("expected the repeats to be collapsed, got %v", got)
+	}
+
+	// another emitter, e.g., of the next test, is not affected
+	other := &syncLogEmitter{}
+	emitLogMessage(context.WithValue(context.Background(), ContextKey("eventEmitter"), other), "warn", "the same station failing every chunk")
+	if got := other.logged(); len(got) != 1 {
+		t.Errorf("expected the message of another emitter, got %v", got)
+	}
+}
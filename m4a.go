@@ -0,0 +1,130 @@
+package radikron
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// remuxAACtoM4A copies the ADTS AAC stream into an MP4 container with ffmpeg, without re-encoding
+func remuxAACtoM4A(ctx context.Context, sourceFile, destFile string, metadata ...string) error {
+	return remuxM4A(ctx, sourceFile, "", destFile, metadata...)
+}
+
+// remuxM4A copies the AAC stream of the source into an MP4 container with ffmpeg,
+// with the chapters in the ffmetadata file unless chaptersFile is empty
+func remuxM4A(ctx context.Context, sourceFile, chaptersFile, destFile string, metadata ...string) error {
+	if err := simulateFailure("remux"); err != nil {
+		return err
+	}
+	ffmpegPath, err := lookFFmpeg(GetAsset(ctx))
+	if err != nil {
+		return err
+	}
+
+	// -c copy: keep the AAC stream as is
+	// -bsf:a aac_adtstoasc: convert the ADTS headers to the MP4 AudioSpecificConfig
+	// -f mp4: the MP4 container, as the destination may have the .part extension
+	// -movflags use_metadata_tags: keep the custom metadata keys (e.g., the program ID)
+	args := []string{"-i", sourceFile}
+	if chaptersFile != "" {
+		// the audio of the source with the chapters of the ffmetadata file
+		args = append(args, "-i", chaptersFile, "-map", "0:a", "-map_chapters", "1")
+	}
+	args = append(args, "-c", "copy", "-bsf:a", "aac_adtstoasc")
+	args = append(args, metadata...)
+	args = append(args,
+		"-f", "mp4",
+		"-movflags", "use_metadata_tags",
+		"-y",
+		"-loglevel", "error",
+		destFile,
+	)
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg remux failed: %w (stderr: %s)", err, stderr.String())
+	}
+	return nil
+}
+
+// m4aMetadata returns the ffmpeg arguments setting the MP4 metadata atoms of the program,
+// the counterparts of the ID3v2 frames written by writeID3Tag
+func m4aMetadata(asset *Asset, output *radigo.OutputConfig, prog *Prog) ([]string, error) {
+	values, err := asset.programTags(output, prog)
+	if err != nil {
+		return nil, err
+	}
+	tags := [][2]string{
+		{"title", values[TagTitle]},
+		{"artist", values[TagArtist]},
+		{"album", values[TagAlbum]},
+		{"comment", values[TagComment]},
+		{"album_artist", values[TagAlbumArtist]},
+		{ID3v2ProgramID, prog.ID},
+		{"date", values[TagYear]},
+		{"genre", values[TagGenre]},
+		{"track", values[TagTrack]},
+	}
+	var args []string
+	for _, t := range tags {
+		if t[1] != "" {
+			args = append(args, "-metadata", t[0]+"="+t[1])
+		}
+	}
+	return args, nil
+}
+
+// writeM4ATags sets the metadata atoms and the chapters of the program in the m4a output,
+// remuxing it to a temporary file and replacing the output with it
+func writeM4ATags(ctx context.Context, output *radigo.OutputConfig, prog *Prog) error {
+	metadata, err := m4aMetadata(GetAsset(ctx), output, prog)
+	if err != nil {
+		return err
+	}
+	chaptersFile := ""
+	if len(prog.Chapters) > 1 {
+		chaptersFile = output.AbsPath() + ".chapters"
+		if err := writeFFMetadataChapters(chaptersFile, prog.Chapters); err != nil {
+			return fmt.Errorf("failed to write the chapters: %w", err)
+		}
+		defer os.Remove(chaptersFile)
+	}
+	tmpPath := output.AbsPath() + ".tags"
+	if err := remuxM4A(ctx, output.AbsPath(), chaptersFile, tmpPath, metadata...); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, output.AbsPath()); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace the output with the tagged file: %w", err)
+	}
+	return nil
+}
+
+// readM4AProgramID returns the program ID in the metadata of the m4a file with ffprobe, or "" if none
+func readM4AProgramID(ctx context.Context, path string) (string, error) {
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return "", errFFprobeNotFound
+	}
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-show_entries", "format_tags="+ID3v2ProgramID,
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
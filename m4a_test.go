@@ -0,0 +1,92 @@
+package radikron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// fakeFFmpeg writes a fake ffmpeg recording its arguments to argsFile and copying the input to the output
+func fakeFFmpeg(t *testing.T, argsFile string) string {
+	t.Helper()
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\necho \"$@\" >> " + argsFile + "\n" +
+		"in=\"$2\"\nfor last; do :; done\ncp \"$in\" \"$last\"\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return ffmpeg
+}
+
+func TestM4AMetadata(t *testing.T) {
+	output := &radigo.OutputConfig{FileBaseName: "2023-06-05-1300_FMT_Test", FileFormat: AudioFormatM4A}
+	prog := &Prog{ID: "FMT-1", Title: "Test", Pfm: "Host", Ft: "20230605130000", RuleName: "rule"}
+
+	args, err := m4aMetadata(nil, output, prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(args, " ")
+	want := "-metadata title=2023-06-05-1300_FMT_Test -metadata artist=Host -metadata album=Test " +
+		"-metadata album_artist=rule -metadata " + ID3v2ProgramID + "=FMT-1 -metadata date=2023"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWriteOutputFile_M4A(t *testing.T) {
+	tmpDir := t.TempDir()
+	argsFile := filepath.Join(tmpDir, "args")
+	asset := &Asset{FFmpegPath: fakeFFmpeg(t, argsFile)}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	concated := filepath.Join(tmpDir, "concated.aac")
+	if err := os.WriteFile(concated, []byte("aac"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output := newOutputConfigFromPath(tmpDir, "out", AudioFormatM4A+PartFileExt)
+	if err := writeOutputFile(ctx, concated, output); err != nil {
+		t.Fatalf("writeOutputFile failed: %v", err)
+	}
+	if _, err := os.Stat(output.AbsPath()); err != nil {
+		t.Errorf("expected the m4a output: %v", err)
+	}
+	if _, err := os.Stat(concated); !os.IsNotExist(err) {
+		t.Error("expected the concatenated file to be removed after the remux")
+	}
+
+	prog := &Prog{ID: "FMT-1", Title: "Test", Ft: "20230605130000"}
+	if err := writeM4ATags(ctx, output, prog); err != nil {
+		t.Fatalf("writeM4ATags failed: %v", err)
+	}
+	if _, err := os.Stat(output.AbsPath() + ".tags"); !os.IsNotExist(err) {
+		t.Error("expected the temporary tagged file to be renamed")
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 ffmpeg runs, got %q", lines)
+	}
+	for _, want := range []string{"-c copy", "-bsf:a aac_adtstoasc", "-f mp4"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected %q in the remux args %q", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], "-metadata "+ID3v2ProgramID+"=FMT-1") {
+		t.Errorf("expected the program ID in the tag args %q", lines[1])
+	}
+}
+
+func TestIsAudioOutput_M4A(t *testing.T) {
+	if !isAudioOutput("2023-06-05-1300_FMT_Test.m4a") {
+		t.Error("expected an m4a file to be an output")
+	}
+}
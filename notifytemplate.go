@@ -0,0 +1,105 @@
+package radikron
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// NotificationBackendDesktop is the desktop notifications of the CLI, the backend the notification templates apply to
+const NotificationBackendDesktop = "desktop"
+
+// The notifications with the templates of their title ("<notification>-title") and body ("<notification>-body")
+const (
+	// NotificationSaved notifies a program saved
+	NotificationSaved = "saved"
+	// NotificationFailed notifies a program given up after failing
+	NotificationFailed = "failed"
+)
+
+// NotificationData is the data of the notification templates, e.g., {{.Title}} on {{.StationID}},
+// {{.SizeMB}} MB of {{.File}}, or the {{.Duration}} of the program
+type NotificationData struct {
+	StationID string
+	Title     string
+	Ft        string
+	Rule      string
+	// Path is the saved file, and File its name
+	Path string
+	File string
+	// Size is the bytes of the saved file
+	Size int64
+	// Duration is the length of the program
+	Duration time.Duration
+	// Attempts is the number of the failed attempts of the program given up
+	Attempts int
+}
+
+// SizeMB returns the size of the saved file in MB with a decimal
+func (d NotificationData) SizeMB() string {
+	return fmt.Sprintf("%.1f", float64(d.Size)/Kilobytes/Kilobytes)
+}
+
+// SetNotificationTemplates sets the templates of the notifications of each backend
+// (e.g., "desktop": {"saved-title": "録音完了: {{.Title}}"}) overriding the default texts of the asset's notifications
+func (a *Asset) SetNotificationTemplates(templates map[string]map[string]string) error {
+	parsed, err := parseNotificationTemplates(templates)
+	if err != nil {
+		return err
+	}
+	a.notificationTemplates = parsed
+	return nil
+}
+
+// ValidateNotificationTemplates returns an error if a backend or a notification is unknown,
+// or its template does not parse or refers to an unknown field
+func ValidateNotificationTemplates(templates map[string]map[string]string) error {
+	_, err := parseNotificationTemplates(templates)
+	return err
+}
+
+func parseNotificationTemplates(templates map[string]map[string]string) (map[string]map[string]*template.Template, error) {
+	parsed := make(map[string]map[string]*template.Template, len(templates))
+	for backend, texts := range templates {
+		if backend != NotificationBackendDesktop {
+			return nil, fmt.Errorf("unknown notification backend: %s", backend)
+		}
+		parsed[backend] = make(map[string]*template.Template, len(texts))
+		for name, text := range texts {
+			notification, part, _ := strings.Cut(name, "-")
+			if (notification != NotificationSaved && notification != NotificationFailed) || (part != "title" && part != "body") {
+				return nil, fmt.Errorf("unknown notification template of %s: %s", backend, name)
+			}
+			t, err := template.New(name).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid notification template %s of %s: %w", name, backend, err)
+			}
+			// a field misspelled fails on any data
+			if err := t.Execute(&strings.Builder{}, NotificationData{}); err != nil {
+				return nil, fmt.Errorf("invalid notification template %s of %s: %w", name, backend, err)
+			}
+			parsed[backend][name] = t
+		}
+	}
+	return parsed, nil
+}
+
+// NotificationText returns the text of the notification part (e.g., "saved-title") for the backend
+// by the asset's template, or fallback without one
+func (a *Asset) NotificationText(backend, name string, data NotificationData, fallback string) string {
+	if a == nil {
+		return fallback
+	}
+	t := a.notificationTemplates[backend][name]
+	if t == nil {
+		return fallback
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		log.Printf("failed to render the notification template %s of %s: %v", name, backend, err)
+		return fallback
+	}
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package radikron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateNotificationTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates map[string]map[string]string
+		wantErr   bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]map[string]string{"desktop": {"saved-title": "{{.Title}}", "failed-body": "{{.Attempts}}"}}, false},
+		{"unknown backend", map[string]map[string]string{"slack": {"saved-title": "{{.Title}}"}}, true},
+		{"unknown notification", map[string]map[string]string{"desktop": {"matched-title": "{{.Title}}"}}, true},
+		{"unknown part", map[string]map[string]string{"desktop": {"saved-icon": "{{.Title}}"}}, true},
+		{"unparsable", map[string]map[string]string{"desktop": {"saved-title": "{{.Title"}}, true},
+		{"unknown field", map[string]map[string]string{"desktop": {"saved-title": "{{.Name}}"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateNotificationTemplates(tt.templates); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNotificationTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotificationText(t *testing.T) {
+	asset := &Asset{}
+	if err := asset.SetNotificationTemplates(map[string]map[string]string{
+		NotificationBackendDesktop: {"saved-body": "{{.StationID}} {{.Title}} {{.SizeMB}} MB, {{.Duration}}"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := NotificationData{StationID: "FMT", Title: "サンデー・ソングブック", Size: 3 * Kilobytes * Kilobytes / 2, Duration: 55 * time.Minute}
+	if got := asset.NotificationText(NotificationBackendDesktop, "saved-body", data, "default"); got != "FMT サンデー・ソングブック 1.5 MB, 55m0s" {
+		t.Errorf("unexpected text %q", got)
+	}
+	if got := asset.NotificationText(NotificationBackendDesktop, "saved-title", data, "default"); got != "default" {
+		t.Errorf("expected the default without a template, got %q", got)
+	}
+	if got := (*Asset)(nil).NotificationText(NotificationBackendDesktop, "saved-body", data, "default"); got != "default" {
+		t.Errorf("expected the default without an asset, got %q", got)
+	}
+}
@@ -0,0 +1,112 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// outputIndex holds the paths of the outputs in the downloads dir, or nil until built
+	outputIndex   map[string]bool
+	outputIndexMu sync.RWMutex
+	// writingTargets holds the output paths being written by this process
+	writingTargets   = map[string]bool{}
+	writingTargetsMu sync.Mutex
+)
+
+// RefreshOutputIndex rebuilds the index of the outputs in the asset's downloads dir,
+// so that the duplicate checks in the configured folders look up the index instead of stat-ing each folder.
+// Call it once per iteration before the downloads start.
+func RefreshOutputIndex(ctx context.Context) {
+	asset := GetAsset(ctx)
+	if asset == nil {
+		return
+	}
+	index, err := buildOutputIndex(asset.DownloadDir)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to index the downloads: %v", err))
+		index = nil // stat each folder instead
+	}
+	outputIndexMu.Lock()
+	defer outputIndexMu.Unlock()
+	outputIndex = index
+}
+
+// buildOutputIndex returns the paths of the outputs in the downloads dir
+func buildOutputIndex(downloadDir string) (map[string]bool, error) {
+	root, err := getRadicronPath(downloadDir)
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]bool{}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			// skip the unreadable dirs
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && isAudioOutput(d.Name()) {
+			index[path] = true
+		}
+		return nil
+	})
+	return index, err
+}
+
+// outputExists returns true if the output exists, looking up the index if built
+func outputExists(path string) bool {
+	outputIndexMu.RLock()
+	index := outputIndex
+	outputIndexMu.RUnlock()
+	if index == nil {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	outputIndexMu.RLock()
+	defer outputIndexMu.RUnlock()
+	return outputIndex[path]
+}
+
+// indexOutput adds the output saved or moved during the iteration to the index
+func indexOutput(path string) {
+	outputIndexMu.Lock()
+	defer outputIndexMu.Unlock()
+	if outputIndex != nil {
+		outputIndex[path] = true
+	}
+}
+
+// unindexOutput removes the output moved or removed during the iteration from the index
+func unindexOutput(path string) {
+	outputIndexMu.Lock()
+	defer outputIndexMu.Unlock()
+	delete(outputIndex, path)
+}
+
+// acquireTarget marks the output path as being written by this process;
+// it returns false if another download is already writing to it
+func acquireTarget(path string) bool {
+	writingTargetsMu.Lock()
+	defer writingTargetsMu.Unlock()
+	if writingTargets[path] {
+		return false
+	}
+	writingTargets[path] = true
+	return true
+}
+
+// releaseTarget clears the writing mark of the output path
+func releaseTarget(path string) {
+	writingTargetsMu.Lock()
+	defer writingTargetsMu.Unlock()
+	delete(writingTargets, path)
+}
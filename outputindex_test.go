@@ -0,0 +1,153 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestRefreshOutputIndex(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	t.Cleanup(func() { outputIndex = nil })
+
+	downloadsDir, err := getRadicronPath("downloads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(downloadsDir, "jazz", "saved.mp3")
+	if err := os.MkdirAll(filepath.Dir(saved), DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{saved, filepath.Join(downloadsDir, "cover.jpg")} {
+		if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset := &Asset{DownloadDir: "downloads"}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	RefreshOutputIndex(ctx)
+
+	if !outputExists(saved) {
+		t.Errorf("expected %s in the index", saved)
+	}
+	if len(outputIndex) != 1 {
+		t.Errorf("expected only the audio outputs indexed, got %v", outputIndex)
+	}
+
+	// a file saved by another process during the iteration is not seen until the next refresh
+	later := filepath.Join(downloadsDir, "later.mp3")
+	if err := os.WriteFile(later, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if outputExists(later) {
+		t.Error("expected the index to be used instead of stat")
+	}
+	indexOutput(later)
+	if !outputExists(later) {
+		t.Error("expected the indexed output to exist")
+	}
+	unindexOutput(later)
+	if outputExists(later) {
+		t.Error("expected the unindexed output not to exist")
+	}
+
+	// without an index, the outputs are stat-ed
+	outputIndex = nil
+	if !outputExists(later) {
+		t.Error("expected stat without an index")
+	}
+}
+
+func TestHandleDuplicate_RuleScan(t *testing.T) {
+	downloadsDir, cleanup := setupHandleDuplicateTest(t)
+	defer cleanup()
+	t.Cleanup(func() { outputIndex = nil })
+
+	// the program is saved in the default folder and in the folder of another rule
+	for _, dir := range []string{downloadsDir, filepath.Join(downloadsDir, "jazz")} {
+		if err := os.MkdirAll(dir, DirPermissions); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "test-file.aac"), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(downloadsDir, "citypop"), DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	rules := Rules{{Folder: "jazz"}, {Folder: "citypop"}}
+	output, err := newOutputConfig("test-file", radigo.AudioFormatAAC, "downloads", "citypop")
+	if err != nil {
+		t.Fatalf("newOutputConfig failed: %v", err)
+	}
+
+	asset := &Asset{DownloadDir: "downloads", DuplicateScan: DuplicateScanAll}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	RefreshOutputIndex(ctx)
+
+	// the copy in jazz is found, so the default copy stays
+	err = handleDuplicate(ctx, "test-file", radigo.AudioFormatAAC, "downloads", "citypop",
+		output, rules, "TEST", "Test Program", "20230605100000")
+	if err != nil {
+		t.Errorf("expected the duplicate in jazz to be found, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(downloadsDir, "test-file.aac")); err != nil {
+		t.Errorf("expected the default copy to stay: %v", err)
+	}
+
+	// only the rule's folder is checked, so the default copy is moved to it
+	asset.DuplicateScan = DuplicateScanRule
+	err = handleDuplicate(ctx, "test-file", radigo.AudioFormatAAC, "downloads", "citypop",
+		output, rules, "TEST", "Test Program", "20230605100000")
+	if !errors.Is(err, errSkipAfterMove) {
+		t.Errorf("expected the default copy to be moved, got %v", err)
+	}
+	if !outputExists(output.AbsPath()) {
+		t.Error("expected the moved output in the index")
+	}
+}
+
+func TestDownload_TargetBeingWritten(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+	emitter := &mockEventEmitter{}
+	asset := &Asset{DownloadDir: "downloads", OutputFormat: radigo.AudioFormatAAC, Schedules: Schedules{}}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	prog := &Prog{ID: "1", StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"}
+	output, err := newOutputConfig("2023-06-05-1300_FMT_Test", radigo.AudioFormatAAC, "downloads", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another program sharing the output path is being written
+	if !acquireTarget(output.AbsPath()) {
+		t.Fatal("expected the target to be free")
+	}
+	t.Cleanup(func() { releaseTarget(output.AbsPath()) })
+
+	var wg sync.WaitGroup
+	if err := Download(ctx, &wg, prog); err != nil {
+		t.Fatalf("expected the program to be skipped, got %v", err)
+	}
+	if len(emitter.downloadSkipped) != 1 || emitter.downloadSkipped[0].reason != "already downloading" {
+		t.Errorf("expected an already downloading skip, got %v", emitter.downloadSkipped)
+	}
+	if len(emitter.downloadStarted) != 0 {
+		t.Errorf("expected no download, got %v", emitter.downloadStarted)
+	}
+	// the program is released for the next iteration
+	if !acquireInFlight(prog) {
+		t.Error("expected the program not to stay in flight")
+	}
+	releaseInFlight(prog)
+}
@@ -0,0 +1,127 @@
+package radikron
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// errDeviceUnsupported is returned when the platform cannot report the filesystem of a path
+	errDeviceUnsupported = errors.New("the filesystem of a path is not available on this platform")
+	// pathDevice returns the ID of the filesystem of path; replaced in tests
+	pathDevice = deviceID
+	// containerMarkers are the files the container runtimes leave at the root, e.g., of Docker and Podman
+	containerMarkers = []string{"/.dockerenv", "/run/.containerenv"}
+	// containerRoot is the root of the filesystem of the container; replaced in tests
+	containerRoot = "/"
+)
+
+// RadicronPaths are the directories radikron keeps its state (Home), the downloads in progress (Tmp),
+// and the saved files (Downloads) in
+type RadicronPaths struct {
+	Home      string
+	Tmp       string
+	Downloads string
+}
+
+// ResolvePaths returns the paths of RADICRON_HOME, its tmp, and the download-dir in it
+func ResolvePaths(downloadDir string) (RadicronPaths, error) {
+	var p RadicronPaths
+	var err error
+	if p.Home, err = getRadicronPath(""); err != nil {
+		return p, err
+	}
+	if p.Tmp, err = getRadicronPath("tmp"); err != nil {
+		return p, err
+	}
+	if p.Downloads, err = getRadicronPath(downloadDir); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// ValidatePaths creates the paths and returns an error unless each is writable.
+// In a container, it also returns an error if the downloads are mounted but RADICRON_HOME is not,
+// as the state and the downloads in progress would be lost with the container;
+// the warnings are the paths that work but are likely a mistake, e.g., nothing mounted at all
+func ValidatePaths(p RadicronPaths) (warnings []string, err error) {
+	for _, dir := range []struct{ name, path string }{
+		{EnvRadicronHome, p.Home},
+		{"tmp", p.Tmp},
+		{"downloads", p.Downloads},
+	} {
+		if err := checkWritable(dir.path); err != nil {
+			return nil, fmt.Errorf("%s (%s) is not writable: %w", dir.name, dir.path, err)
+		}
+	}
+
+	// the mounts are unknown on the platforms not reporting the filesystems
+	if inContainer() {
+		homeMounted, homeErr := isMounted(p.Home)
+		downloadsMounted, downloadsErr := isMounted(p.Downloads)
+		switch {
+		case homeErr != nil || downloadsErr != nil:
+		case downloadsMounted && !homeMounted:
+			return nil, fmt.Errorf(
+				"%s is mounted but %s (%s) is not: mount a volume at %s, e.g., `./radiko:%s`, "+
+					"not to lose the history, the queue, and the downloads in progress with the container",
+				p.Downloads, EnvRadicronHome, p.Home, p.Home, p.Home)
+		case !homeMounted:
+			warnings = append(warnings, fmt.Sprintf(
+				"%s (%s) is not mounted: the downloads are lost with the container unless a volume is mounted at %s",
+				EnvRadicronHome, p.Home, p.Home))
+		}
+	}
+
+	// the finished downloads are copied instead of moved across the filesystems
+	tmpDevice, tmpErr := pathDevice(p.Tmp)
+	downloadsDevice, downloadsErr := pathDevice(p.Downloads)
+	if tmpErr == nil && downloadsErr == nil && tmpDevice != downloadsDevice {
+		warnings = append(warnings, fmt.Sprintf(
+			"tmp (%s) and downloads (%s) are on different filesystems: the saved files are copied instead of moved",
+			p.Tmp, p.Downloads))
+	}
+	return warnings, nil
+}
+
+// checkWritable returns an error unless a file can be written in dir, creating dir if missing
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".writable-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// inContainer returns true if radikron runs in a container
+func inContainer() bool {
+	for _, marker := range containerMarkers {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isMounted returns true if path, or a directory above it, is on another filesystem than the root of the container
+func isMounted(path string) (bool, error) {
+	rootDevice, err := pathDevice(containerRoot)
+	if err != nil {
+		return false, err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+	device, err := pathDevice(resolved)
+	if err != nil {
+		return false, err
+	}
+	return device != rootDevice, nil
+}
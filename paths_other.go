@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package radikron
+
+// deviceID is not supported on this platform
+func deviceID(_ string) (uint64, error) {
+	return 0, errDeviceUnsupported
+}
@@ -0,0 +1,100 @@
+package radikron
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	p, err := ResolvePaths("archive")
+	if err != nil {
+		t.Fatalf("ResolvePaths failed: %v", err)
+	}
+	want := RadicronPaths{Home: home, Tmp: filepath.Join(home, "tmp"), Downloads: filepath.Join(home, "archive")}
+	if p != want {
+		t.Errorf("expected %+v, got %+v", want, p)
+	}
+}
+
+func TestValidatePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	origMarkers, origDevice, origRoot := containerMarkers, pathDevice, containerRoot
+	t.Cleanup(func() { containerMarkers, pathDevice, containerRoot = origMarkers, origDevice, origRoot })
+	containerMarkers = []string{filepath.Join(home, "missing")}
+
+	p, err := ResolvePaths(DefaultDownloadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings, err := ValidatePaths(p)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("expected the paths valid, got %v %v", warnings, err)
+	}
+	for _, dir := range []string{p.Tmp, p.Downloads} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("expected %s created: %v", dir, err)
+		}
+	}
+
+	// a file in the way of the downloads
+	blocked := p
+	blocked.Downloads = filepath.Join(home, "file", "downloads")
+	if err := os.WriteFile(filepath.Join(home, "file"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidatePaths(blocked); err == nil || !strings.Contains(err.Error(), "downloads") {
+		t.Errorf("expected the downloads not writable, got %v", err)
+	}
+
+	// in a container, the filesystems told by the paths
+	marker := filepath.Join(home, ".dockerenv")
+	if err := os.WriteFile(marker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	containerMarkers = []string{marker}
+	containerRoot = "/"
+	mounts := map[string]uint64{}
+	pathDevice = func(path string) (uint64, error) {
+		// the deepest mount
+		device, longest := uint64(1), 0
+		for prefix, d := range mounts {
+			if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+				device, longest = d, len(prefix)
+			}
+		}
+		return device, nil
+	}
+
+	// only the downloads mounted
+	mounts[p.Downloads] = 2
+	if _, err := ValidatePaths(p); err == nil || !strings.Contains(err.Error(), "mount a volume at "+home) {
+		t.Errorf("expected an error for RADICRON_HOME not mounted, got %v", err)
+	}
+
+	// nothing mounted
+	delete(mounts, p.Downloads)
+	warnings, err = ValidatePaths(p)
+	if err != nil || len(warnings) != 1 || !strings.Contains(warnings[0], "is not mounted") {
+		t.Errorf("expected a warning for RADICRON_HOME not mounted, got %v %v", warnings, err)
+	}
+
+	// RADICRON_HOME mounted, and the downloads on another filesystem
+	mounts[home] = 2
+	mounts[p.Downloads] = 3
+	warnings, err = ValidatePaths(p)
+	if err != nil || len(warnings) != 1 || !strings.Contains(warnings[0], "different filesystems") {
+		t.Errorf("expected a warning for the downloads copied, got %v %v", warnings, err)
+	}
+
+	// RADICRON_HOME mounted with everything in it
+	delete(mounts, p.Downloads)
+	warnings, err = ValidatePaths(p)
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("expected the paths valid, got %v %v", warnings, err)
+	}
+}
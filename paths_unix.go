@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package radikron
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// deviceID returns the ID of the filesystem of path
+func deviceID(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil //nolint:gosec,unconvert
+}
@@ -0,0 +1,171 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// usesPremiumStream returns true if the program is downloaded with the premium session
+func usesPremiumStream(asset *Asset, prog *Prog) bool {
+	return prog.AreaFree && asset.PremiumDevice != nil
+}
+
+// acquirePremiumStream blocks until a premium stream is available or ctx is done,
+// so that the playlist requests pause at the simultaneous-stream limit instead of failing.
+// The queued programs get the streams in the order of their timefree expiry.
+// The returned func releases the stream.
+func acquirePremiumStream(ctx context.Context, asset *Asset, prog *Prog) (release func(), err error) {
+	sem := asset.premiumStreamSem()
+
+	if sem.TryAcquire() {
+		return sem.Release, nil
+	}
+
+	emitLogMessage(ctx, "info", fmt.Sprintf(
+		"premium stream limit (%d) reached, queueing [%s]%s", sem.Cap(), prog.StationID, prog.Title))
+	if err := sem.Acquire(ctx, timefreeExpiry(prog)); err != nil {
+		return nil, err
+	}
+	return sem.Release, nil
+}
+
+// premiumStreamSem returns the limit of the programs downloading with the asset's premium session at once,
+// DefaultPremiumMaxStreams until InitSemaphores sets it
+func (a *Asset) premiumStreamSem() *prioritySemaphore {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.premiumStreams == nil {
+		a.premiumStreams = newPrioritySemaphore(DefaultPremiumMaxStreams)
+	}
+	return a.premiumStreams
+}
+
+// premiumSessions are the radiko_sessions of the premium logins by mail,
+// reused by the assets of the following fetches instead of logging in again
+var (
+	premiumSessionMu sync.Mutex
+	premiumSessions  = make(map[string]string)
+)
+
+// premiumLoginResponse is the response of the member login API
+type premiumLoginResponse struct {
+	Session  string `json:"radiko_session"`
+	AreaFree any    `json:"areafree"`
+}
+
+// LoginPremium logs in to the premium account and returns its radiko_session,
+// or an error if the account has no areafree membership
+func LoginPremium(ctx context.Context, mail, pass string) (string, error) {
+	form := url.Values{"mail": {mail}, "pass": {pass}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APIMemberLogin, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req) //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to the premium account: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to log in to the premium account %s: %s", mail, resp.Status)
+	}
+	var login premiumLoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return "", fmt.Errorf("failed to read the premium login: %w", err)
+	}
+	if login.Session == "" {
+		return "", fmt.Errorf("failed to log in to the premium account %s: no session", mail)
+	}
+	if fmt.Sprint(login.AreaFree) != "1" {
+		return "", fmt.Errorf("the account %s has no areafree membership", mail)
+	}
+	return login.Session, nil
+}
+
+// LogoutPremium logs out of the premium sessions logged in by the assets
+func LogoutPremium(ctx context.Context) {
+	premiumSessionMu.Lock()
+	sessions := premiumSessions
+	premiumSessions = make(map[string]string)
+	premiumSessionMu.Unlock()
+	for _, session := range sessions {
+		form := url.Values{"radiko_session": {session}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, APIMemberLogout, strings.NewReader(form.Encode()))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if resp, err := httpClient.Do(req); err == nil { //nolint:gosec
+			resp.Body.Close()
+		}
+	}
+}
+
+// premiumDevice returns the device authorized with the premium session, logging in on the first call,
+// or nil if no premium account is configured. A stale session is logged in again once.
+func (a *Asset) premiumDevice(ctx context.Context) (*Device, error) {
+	premiumSessionMu.Lock()
+	defer premiumSessionMu.Unlock()
+	if a.PremiumDevice != nil || a.PremiumMail == "" {
+		return a.PremiumDevice, nil
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		session, ok := premiumSessions[a.PremiumMail]
+		if !ok {
+			var err error
+			session, err = LoginPremium(ctx, a.PremiumMail, a.PremiumPass)
+			if err != nil {
+				return nil, err
+			}
+			premiumSessions[a.PremiumMail] = session
+		}
+		device, err := a.newDevice()
+		if err != nil {
+			return nil, err
+		}
+		device.Session = session
+		err = currentRetryPolicy(ctx).Do(ctx, func() error {
+			return device.Auth(ctx, a, a.PremiumAreaID)
+		})
+		if err == nil {
+			a.PremiumDevice, a.PremiumAreaID = device, device.AreaID
+			return device, nil
+		}
+		delete(premiumSessions, a.PremiumMail)
+		if ok {
+			continue // the cached session may have expired
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("failed to authorize the premium session of %s", a.PremiumMail)
+}
+
+// readPremiumArea reads the area authorized by auth2 for the premium session, e.g., "JP13,東京都,tokyo Japan"
+func (d *Device) readPremiumArea(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponseStatus(resp); err != nil {
+		return fmt.Errorf("premium auth failed: %w", err)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	areaID, _, _ := strings.Cut(strings.TrimSpace(string(body)), ",")
+	if areaID == "" {
+		return permanent(errors.New("premium auth failed: no area"))
+	}
+	d.AreaID = areaID
+	return nil
+}
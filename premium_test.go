@@ -0,0 +1,180 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUsesPremiumStream(t *testing.T) {
+	asset := &Asset{}
+	prog := &Prog{AreaFree: true}
+	if usesPremiumStream(asset, prog) {
+		t.Error("usesPremiumStream should be false without the premium session")
+	}
+	asset.PremiumDevice = &Device{}
+	if !usesPremiumStream(asset, prog) {
+		t.Error("usesPremiumStream should be true for an areafree program with the premium session")
+	}
+	prog.AreaFree = false
+	if usesPremiumStream(asset, prog) {
+		t.Error("usesPremiumStream should be false for a program without areafree")
+	}
+}
+
+func TestAcquirePremiumStream(t *testing.T) {
+	asset := &Asset{PremiumMaxStreams: 1}
+	InitSemaphores(asset)
+
+	prog := &Prog{StationID: "MBS", Title: "Test Program"}
+	release, err := acquirePremiumStream(context.Background(), asset, prog)
+	if err != nil {
+		t.Fatalf("acquirePremiumStream failed: %v", err)
+	}
+
+	// the second program waits at the limit
+	acquired := make(chan func(), 1)
+	go func() {
+		r, err := acquirePremiumStream(context.Background(), asset, prog)
+		if err == nil {
+			acquired <- r
+		}
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquirePremiumStream should wait at the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case r := <-acquired:
+		r()
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquirePremiumStream should proceed once a stream is released")
+	}
+}
+
+func TestPremiumStreamSem(t *testing.T) {
+	asset := &Asset{}
+	sem := asset.premiumStreamSem()
+	if sem.Cap() != DefaultPremiumMaxStreams {
+		t.Errorf("expected the default limit %d before InitSemaphores, got %d", DefaultPremiumMaxStreams, sem.Cap())
+	}
+
+	// the reloaded asset keeps the streams of the previous one
+	next := &Asset{PremiumMaxStreams: 2}
+	next.Inherit(asset)
+	InitSemaphores(next)
+	if next.premiumStreamSem() != sem || sem.Cap() != 2 {
+		t.Errorf("expected the inherited streams resized to 2, got %d", next.premiumStreamSem().Cap())
+	}
+}
+
+func TestAcquirePremiumStream_Canceled(t *testing.T) {
+	asset := &Asset{PremiumMaxStreams: 1}
+	InitSemaphores(asset)
+
+	prog := &Prog{StationID: "MBS", Title: "Test Program"}
+	release, err := acquirePremiumStream(context.Background(), asset, prog)
+	if err != nil {
+		t.Fatalf("acquirePremiumStream failed: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := acquirePremiumStream(ctx, asset, prog); !errors.Is(err, context.Canceled) {
+		t.Errorf("acquirePremiumStream => %v, want %v", err, context.Canceled)
+	}
+}
+
+// rewriteTransport sends all the requests to the test server, keeping their paths
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLoginPremium(t *testing.T) {
+	var loggedOut atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch {
+		case r.URL.Path == "/v4/api/member/login" && r.PostForm.Get("pass") == "correct":
+			areafree := "1"
+			if r.PostForm.Get("mail") == "free@example.com" {
+				areafree = "0"
+			}
+			_, _ = w.Write([]byte(`{"radiko_session":"session-` + r.PostForm.Get("mail") + `","areafree":"` + areafree + `"}`))
+		case r.URL.Path == "/v4/api/member/login":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v4/api/member/logout":
+			loggedOut.Store(r.PostForm.Get("radiko_session"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+	origClient := httpClient
+	defer func() { httpClient = origClient }()
+	httpClient = &http.Client{Transport: rewriteTransport{target: target}}
+	ctx := context.Background()
+
+	session, err := LoginPremium(ctx, "premium@example.com", "correct")
+	if err != nil || session != "session-premium@example.com" {
+		t.Errorf("expected the session, got %q (%v)", session, err)
+	}
+	if _, err := LoginPremium(ctx, "free@example.com", "correct"); err == nil || !strings.Contains(err.Error(), "no areafree membership") {
+		t.Errorf("expected no areafree membership, got %v", err)
+	}
+	if _, err := LoginPremium(ctx, "premium@example.com", "wrong"); err == nil {
+		t.Error("expected an error for the wrong password")
+	}
+
+	premiumSessionMu.Lock()
+	premiumSessions["premium@example.com"] = session
+	premiumSessionMu.Unlock()
+	LogoutPremium(ctx)
+	if got, _ := loggedOut.Load().(string); got != session {
+		t.Errorf("expected the session to be logged out, got %q", got)
+	}
+	if len(premiumSessions) != 0 {
+		t.Errorf("expected no sessions after logout, got %v", premiumSessions)
+	}
+}
+
+func TestPremiumDevice_NotConfigured(t *testing.T) {
+	device, err := (&Asset{}).premiumDevice(context.Background())
+	if device != nil || err != nil {
+		t.Errorf("expected no premium device without an account, got %v (%v)", device, err)
+	}
+}
+
+func TestReadPremiumArea(t *testing.T) {
+	respond := func(status int, body string) *http.Response {
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(strings.NewReader(body))}
+	}
+	d := &Device{Session: "session"}
+	if err := d.readPremiumArea(respond(http.StatusOK, "JP27,大阪府,osaka Japan\r\n"), nil); err != nil || d.AreaID != "JP27" {
+		t.Errorf("expected JP27, got %q (%v)", d.AreaID, err)
+	}
+	if err := d.readPremiumArea(respond(http.StatusUnauthorized, ""), nil); err == nil {
+		t.Error("expected an error for the rejected session")
+	}
+	if err := d.readPremiumArea(respond(http.StatusOK, ""), nil); err == nil {
+		t.Error("expected an error for no area")
+	}
+}
@@ -0,0 +1,164 @@
+package radikron
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// prioritySemaphore limits the concurrency like a buffered channel,
+// but hands a released slot to the waiter with the earliest timefree expiry,
+// so that a backlog never loses the programs about to fall out of the timefree window
+type prioritySemaphore struct {
+	mu      sync.Mutex
+	size    int
+	used    int
+	seq     uint64
+	waiters semWaiters
+}
+
+// semWaiter is a blocked Acquire
+type semWaiter struct {
+	expiry time.Time
+	seq    uint64 // FIFO among the same expiry
+	ready  chan struct{}
+	index  int // in the heap, -1 once handed a slot
+}
+
+// semWaiters is a min-heap of the waiters ordered by expiry
+type semWaiters []*semWaiter
+
+func (ws semWaiters) Len() int { return len(ws) }
+
+func (ws semWaiters) Less(i, j int) bool {
+	if !ws[i].expiry.Equal(ws[j].expiry) {
+		return ws[i].expiry.Before(ws[j].expiry)
+	}
+	return ws[i].seq < ws[j].seq
+}
+
+func (ws semWaiters) Swap(i, j int) {
+	ws[i], ws[j] = ws[j], ws[i]
+	ws[i].index = i
+	ws[j].index = j
+}
+
+func (ws *semWaiters) Push(x any) {
+	w := x.(*semWaiter)
+	w.index = len(*ws)
+	*ws = append(*ws, w)
+}
+
+func (ws *semWaiters) Pop() any {
+	old := *ws
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*ws = old[:n-1]
+	return w
+}
+
+// newPrioritySemaphore returns a semaphore with size slots
+func newPrioritySemaphore(size int) *prioritySemaphore {
+	return &prioritySemaphore{size: size}
+}
+
+// Cap returns the number of slots
+func (s *prioritySemaphore) Cap() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// TryAcquire takes a slot without blocking; it returns false if none is free
+func (s *prioritySemaphore) TryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used < s.size && len(s.waiters) == 0 {
+		s.used++
+		return true
+	}
+	return false
+}
+
+// Acquire blocks until a slot is handed to the caller in the order of expiry, or ctx is done
+func (s *prioritySemaphore) Acquire(ctx context.Context, expiry time.Time) error {
+	s.mu.Lock()
+	if s.used < s.size && len(s.waiters) == 0 {
+		s.used++
+		s.mu.Unlock()
+		return nil
+	}
+	s.seq++
+	w := &semWaiter{expiry: expiry, seq: s.seq, ready: make(chan struct{})}
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&s.waiters, w.index)
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+		s.mu.Unlock()
+		// handed a slot while canceled: pass it on
+		s.Release()
+		return ctx.Err()
+	}
+}
+
+// Release frees the slot, handing it to the waiter with the earliest expiry if any
+func (s *prioritySemaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// after shrinking, the slots over the new size are not handed over
+	if len(s.waiters) > 0 && s.used <= s.size {
+		w := heap.Pop(&s.waiters).(*semWaiter)
+		close(w.ready)
+		return
+	}
+	s.used--
+}
+
+// SetCap resizes the semaphore in place; the slots added are handed to the waiters,
+// and the slots removed are taken back as the holders release them
+func (s *prioritySemaphore) SetCap(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size = size
+	for s.used < s.size && len(s.waiters) > 0 {
+		w := heap.Pop(&s.waiters).(*semWaiter)
+		close(w.ready)
+		s.used++
+	}
+}
+
+// timefreeExpiry returns when the program falls out of the timefree window,
+// or the end of the window from now if the start time is invalid
+func timefreeExpiry(prog *Prog) time.Time {
+	start, err := ParseDatetime(prog.Ft)
+	if err != nil {
+		return CurrentTime.Add(TimefreeWindow)
+	}
+	return start.Add(TimefreeWindow)
+}
+
+// withTimefreeExpiry returns the ctx carrying the expiry of the program being downloaded
+func withTimefreeExpiry(ctx context.Context, prog *Prog) context.Context {
+	return context.WithValue(ctx, ContextKey("timefreeExpiry"), timefreeExpiry(prog))
+}
+
+// timefreeExpiryFromContext returns the expiry set by withTimefreeExpiry,
+// or the end of the window from now if not set
+func timefreeExpiryFromContext(ctx context.Context) time.Time {
+	if expiry, ok := ctx.Value(ContextKey("timefreeExpiry")).(time.Time); ok {
+		return expiry
+	}
+	return CurrentTime.Add(TimefreeWindow)
+}
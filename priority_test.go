@@ -0,0 +1,155 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until n waiters are queued on the semaphore
+func waitForWaiters(t *testing.T, s *prioritySemaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		queued := len(s.waiters)
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", n)
+}
+
+func TestPrioritySemaphore_Order(t *testing.T) {
+	s := newPrioritySemaphore(1)
+	if err := s.Acquire(context.Background(), time.Time{}); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	base := time.Date(2023, 6, 5, 0, 0, 0, 0, time.UTC)
+	expiries := []time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour, time.Hour}
+	order := make(chan int, len(expiries))
+	for i, d := range expiries {
+		go func(i int, expiry time.Time) {
+			if err := s.Acquire(context.Background(), expiry); err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			order <- i
+		}(i, base.Add(d))
+		// queue one by one for a deterministic FIFO among the same expiry
+		waitForWaiters(t, s, i+1)
+	}
+
+	want := []int{1, 3, 2, 0}
+	for _, w := range want {
+		s.Release()
+		if got := <-order; got != w {
+			t.Errorf("expected waiter %d, got %d", w, got)
+		}
+	}
+	s.Release()
+	if !s.TryAcquire() {
+		t.Error("expected a free slot after all releases")
+	}
+}
+
+func TestPrioritySemaphore_TryAcquire(t *testing.T) {
+	s := newPrioritySemaphore(2)
+	if s.Cap() != 2 {
+		t.Errorf("expected cap 2, got %d", s.Cap())
+	}
+	if !s.TryAcquire() || !s.TryAcquire() {
+		t.Fatal("expected two free slots")
+	}
+	if s.TryAcquire() {
+		t.Error("expected no free slot")
+	}
+	s.Release()
+	if !s.TryAcquire() {
+		t.Error("expected a released slot")
+	}
+}
+
+func TestPrioritySemaphore_Cancel(t *testing.T) {
+	s := newPrioritySemaphore(1)
+	if !s.TryAcquire() {
+		t.Fatal("expected a free slot")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Acquire(ctx, time.Time{}) }()
+	waitForWaiters(t, s, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	waitForWaiters(t, s, 0)
+
+	// the canceled waiter does not take the released slot
+	s.Release()
+	if !s.TryAcquire() {
+		t.Error("expected the released slot to be free")
+	}
+}
+
+func TestPrioritySemaphore_SetCap(t *testing.T) {
+	s := newPrioritySemaphore(2)
+	for i := 0; i < 2; i++ {
+		if !s.TryAcquire() {
+			t.Fatalf("expected slot %d to be free", i)
+		}
+	}
+
+	// shrinking keeps the held slots until released
+	s.SetCap(1)
+	s.Release()
+	if s.TryAcquire() {
+		t.Error("expected no free slot over the shrunk size")
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if err := s.Acquire(context.Background(), time.Time{}); err != nil {
+			t.Errorf("Acquire failed: %v", err)
+		}
+		close(acquired)
+	}()
+	waitForWaiters(t, s, 1)
+
+	// growing hands the new slot to the waiter
+	s.SetCap(2)
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the waiter to get the added slot")
+	}
+	if s.Cap() != 2 {
+		t.Errorf("expected cap 2, got %d", s.Cap())
+	}
+	if s.TryAcquire() {
+		t.Error("expected no free slot")
+	}
+}
+
+func TestTimefreeExpiry(t *testing.T) {
+	prog := &Prog{Ft: "20230605100000"}
+	want := time.Date(2023, 6, 12, 10, 0, 0, 0, Location)
+	if got := timefreeExpiry(prog); !got.Equal(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	ctx := withTimefreeExpiry(context.Background(), prog)
+	if got := timefreeExpiryFromContext(ctx); !got.Equal(want) {
+		t.Errorf("expected %v from the context, got %v", want, got)
+	}
+
+	CurrentTime = time.Date(2023, 6, 5, 16, 0, 0, 0, Location)
+	if got := timefreeExpiryFromContext(context.Background()); !got.Equal(CurrentTime.Add(TimefreeWindow)) {
+		t.Errorf("expected the end of the window from now, got %v", got)
+	}
+}
@@ -1,32 +1,51 @@
 package radikron
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 // Prog contains the solicited program metadata
 type Prog struct {
-	ID         string
-	StationID  string
-	Ft         string
-	To         string
-	Title      string
-	Desc       string
-	Info       string
-	Pfm        string
-	Tags       []string
-	Genre      ProgGenre
-	M3U8       string
-	RuleName   string // name of the rule that matched this program
-	RuleFolder string // folder from the rule that matched this program
+	ID         string    `json:"id"`
+	StationID  string    `json:"station-id"`
+	Ft         string    `json:"ft"`
+	To         string    `json:"to"`
+	Title      string    `json:"title"`
+	Desc       string    `json:"desc,omitempty"`
+	Info       string    `json:"info,omitempty"`
+	Pfm        string    `json:"pfm,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Img        string    `json:"img,omitempty"` // the URL of the program image
+	Tags       []string  `json:"tags,omitempty"`
+	Genre      ProgGenre `json:"genre"`
+	Genres     []Genre   `json:"genres,omitempty"` // program and personality genres with their IDs
+	M3U8       string    `json:"-"`
+	RuleName   string    `json:"rule-name,omitempty"`   // name of the rule that matched this program
+	RuleFolder string    `json:"rule-folder,omitempty"` // folder from the rule that matched this program
+	AreaFree   bool      `json:"areafree,omitempty"`    // the rule that matched this program uses the premium (areafree) session
+	Mode       string    `json:"mode,omitempty"`        // the mode of the rule that matched this program to record it in
+	// FilenameCharset is the charset of the rule that matched this program to name its output in
+	FilenameCharset string `json:"filename-charset,omitempty"`
+	// Episode is the number of the program in the rule that matched it, 0 if not numbered
+	Episode int `json:"episode,omitempty"`
+	// Chapters are the ends of the parts of the recording between the ad breaks, if split into chapters
+	Chapters []time.Duration `json:"chapters,omitempty"`
 }
 
 type ProgGenre struct {
-	Personality string
-	Program     string
+	Personality string `json:"personality,omitempty"`
+	Program     string `json:"program,omitempty"`
+}
+
+// Genre is a radiko genre code with its name
+type Genre struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 // Progs is a slice of Prog.
@@ -49,12 +68,20 @@ func (ps *Progs) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			Desc:      p.Desc,
 			Info:      p.Info,
 			Pfm:       p.Pfm,
+			URL:       p.URL,
+			Img:       p.Img,
 			M3U8:      "",
 		}
+		normalizeProgramTimes(prog)
 		prog.Genre = ProgGenre{
 			Personality: p.Genre.Personality.Name,
 			Program:     p.Genre.Program.Name,
 		}
+		for _, g := range []XMLProgItem{p.Genre.Program, p.Genre.Personality} {
+			if g.ID != "" || g.Name != "" {
+				prog.Genres = append(prog.Genres, Genre{ID: g.ID, Name: g.Name})
+			}
+		}
 		for _, t := range p.Tag.Item {
 			prog.Tags = append(prog.Tags, t.Name)
 		}
@@ -73,6 +100,8 @@ type XMLProg struct {
 	Desc  string `xml:"desc"`
 	Info  string `xml:"info"`
 	Pfm   string `xml:"pfm"`
+	URL   string `xml:"url"`
+	Img   string `xml:"img"`
 	Tag   struct {
 		Item []XMLProgItem `xml:"item"`
 	} `xml:"tag"`
@@ -106,17 +135,50 @@ type XMLWeeklyStation struct {
 	Progs     XMLProgs `xml:"progs"`
 }
 
-// FetchWeeklyPrograms returns the weekly programs.
-func FetchWeeklyPrograms(stationID string) (Progs, error) {
+// FetchWeeklyPrograms returns the weekly programs, fetched through the proxy of the asset in ctx.
+// The guide cached within the GuideCacheTTL of the asset in ctx, if set, is used instead of fetching it again.
+func FetchWeeklyPrograms(ctx context.Context, stationID string) (Progs, error) {
+	var ttl time.Duration
+	if asset := GetAsset(ctx); asset != nil {
+		ttl = asset.GuideCacheTTL
+	}
+	if ttl > 0 {
+		if body := readCachedGuide(stationID, ttl); body != nil {
+			progs := Progs{}
+			if err := xml.Unmarshal(body, &progs); err == nil {
+				return progs, nil
+			}
+		}
+	}
+
 	endpoint := fmt.Sprintf(APIWeeklyProgram, stationID)
 
-	resp, err := http.Get(endpoint) //nolint:gosec,noctx
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return Progs{}, err
+	}
+	resp, err := httpClient.Do(req) //nolint:gosec
 	if err != nil {
 		return Progs{}, err
 	}
 	defer resp.Body.Close()
 
-	return decodeWeeklyProgram(resp.Body)
+	if ttl <= 0 {
+		return decodeWeeklyProgram(resp.Body)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Progs{}, err
+	}
+	progs := Progs{}
+	if err := xml.Unmarshal(body, &progs); err != nil {
+		return progs, err
+	}
+	// a failure to cache only costs a fetch next time
+	if resp.StatusCode == http.StatusOK {
+		_ = writeCachedGuide(stationID, body, time.Now())
+	}
+	return progs, nil
 }
 
 func decodeWeeklyProgram(iorc io.ReadCloser) (Progs, error) {
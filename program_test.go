@@ -72,6 +72,16 @@ func TestWeeklyProgramUnmarshal(t *testing.T) {
 		t.Errorf("p.Genre.Program => %v, want %v", got, want)
 	}
 
+	if len(p.Genres) != 2 {
+		t.Fatalf("p.Genres => %v, want 2 genres", p.Genres)
+	}
+	if p.Genres[0].ID != "P007" || p.Genres[0].Name != "トーク" {
+		t.Errorf("p.Genres[0] => %v, want {P007 トーク}", p.Genres[0])
+	}
+	if p.Genres[1].ID != "C010" || p.Genres[1].Name != "タレント" {
+		t.Errorf("p.Genres[1] => %v, want {C010 タレント}", p.Genres[1])
+	}
+
 	got = strings.Join(p.Tags, ",")
 	want = "山崎怜奈,音楽との出会いが楽しめる,作業がはかどる,気分転換におすすめ,学生におすすめ"
 	if got != want {
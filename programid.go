@@ -0,0 +1,107 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bogem/id3v2"
+)
+
+var (
+	// programIDIndex caches the program IDs read from the tags of the downloaded files by path
+	programIDIndex   = map[string]programIDEntry{}
+	programIDIndexMu sync.Mutex
+)
+
+// programIDEntry is the program ID of a file, valid while the file is unchanged
+type programIDEntry struct {
+	modTime time.Time
+	size    int64
+	id      string
+}
+
+// readProgramID returns the program ID in the TXXX frame of the audio file
+// (or the metadata of an m4a file), or "" if none
+func readProgramID(path string) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), "."+AudioFormatM4A) {
+		return readM4AProgramID(context.Background(), path)
+	}
+	tag, err := id3v2.Open(path, id3v2.Options{
+		Parse:       true,
+		ParseFrames: []string{"User defined text information frame"},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer tag.Close()
+
+	for _, f := range tag.GetFrames(tag.CommonID("User defined text information frame")) {
+		if udtf, ok := f.(id3v2.UserDefinedTextFrame); ok && udtf.Description == ID3v2ProgramID {
+			return udtf.Value, nil
+		}
+	}
+	return "", nil
+}
+
+// findDownloadedProgram returns the path of the file in the downloads dir tagged with the program ID,
+// so that a file renamed after the download is still recognized.
+// Only the files added or changed since the last call are read.
+func findDownloadedProgram(downloadDir, programID string) (string, bool) {
+	if programID == "" {
+		return "", false
+	}
+	root, err := getRadicronPath(downloadDir)
+	if err != nil {
+		return "", false
+	}
+
+	programIDIndexMu.Lock()
+	defer programIDIndexMu.Unlock()
+
+	found := ""
+	seen := map[string]bool{}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// skip the unreadable dirs
+			if d != nil && d.IsDir() && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isAudioOutput(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		seen[path] = true
+		entry, ok := programIDIndex[path]
+		if !ok || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+			entry = programIDEntry{modTime: info.ModTime(), size: info.Size()}
+			entry.id, _ = readProgramID(path)
+			programIDIndex[path] = entry
+		}
+		if found == "" && entry.id == programID {
+			found = path
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", false
+	}
+
+	// forget the files removed or renamed
+	for path := range programIDIndex {
+		if !seen[path] {
+			delete(programIDIndex, path)
+		}
+	}
+	return found, found != ""
+}
@@ -0,0 +1,66 @@
+package radikron
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestFindDownloadedProgram(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	dir := filepath.Join(home, "downloads", "music")
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	output := newOutputConfigFromPath(dir, "2023-06-05-1300_FMT_Test", radigo.AudioFormatAAC)
+	if err := os.WriteFile(output.AbsPath(), make([]byte, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	prog := &Prog{ID: "FMT20230605130000", StationID: "FMT", Title: "Test", Ft: "20230605130000"}
+	if err := writeID3Tag(nil, output, prog); err != nil {
+		t.Fatalf("writeID3Tag failed: %v", err)
+	}
+
+	if id, err := readProgramID(output.AbsPath()); err != nil || id != prog.ID {
+		t.Fatalf("expected the program ID %s in the tag, got %q, %v", prog.ID, id, err)
+	}
+
+	// an untagged file is never a match
+	if err := os.WriteFile(filepath.Join(home, "downloads", "untagged.aac"), []byte("audio"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// renamed by the user
+	renamed := filepath.Join(dir, "my favorite show.aac")
+	if err := os.Rename(output.AbsPath(), renamed); err != nil {
+		t.Fatal(err)
+	}
+	path, ok := findDownloadedProgram("downloads", prog.ID)
+	if !ok || path != renamed {
+		t.Errorf("expected the renamed file %s, got %q", renamed, path)
+	}
+	if _, ok := findDownloadedProgram("downloads", "OTHER20230605130000"); ok {
+		t.Error("expected no file for another program")
+	}
+	if _, ok := findDownloadedProgram("downloads", ""); ok {
+		t.Error("expected no file for an empty program ID")
+	}
+
+	// removed by the user
+	if err := os.Remove(renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := findDownloadedProgram("downloads", prog.ID); ok {
+		t.Error("expected the removed file to be forgotten")
+	}
+	programIDIndexMu.Lock()
+	_, cached := programIDIndex[renamed]
+	programIDIndexMu.Unlock()
+	if cached {
+		t.Error("expected the removed file to be dropped from the index")
+	}
+}
@@ -0,0 +1,116 @@
+package radikron
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// downloadProgress tracks the completed segments of a program download
+// and reports them whenever the completed percentage changes
+type downloadProgress struct {
+	ctx       context.Context
+	stationID string
+	title     string
+	total     int
+
+	mu       sync.Mutex
+	done     int
+	bytes    int64
+	retries  int
+	reported int // the last reported percentage
+	// active are the segments being downloaded, and lastActivity when a segment last started or completed
+	active       int
+	lastActivity time.Time
+}
+
+// newDownloadProgress returns a progress tracker for the program with total segments,
+// of which done have already been completed (e.g., resumed from the manifest)
+func newDownloadProgress(ctx context.Context, prog *Prog, total, done int) *downloadProgress {
+	p := &downloadProgress{
+		ctx:       ctx,
+		stationID: prog.StationID,
+		title:     prog.Title,
+		total:     total,
+		done:      done,
+		reported:  -1,
+		// the playlist was just fetched
+		lastActivity: time.Now(),
+	}
+	if total > 0 {
+		p.reported = done * 100 / total
+	}
+	return p
+}
+
+// add records a completed segment of n bytes
+func (p *downloadProgress) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	p.bytes += n
+	p.lastActivity = time.Now()
+	if p.total <= 0 {
+		return
+	}
+	percent := p.done * 100 / p.total
+	if percent == p.reported && p.done < p.total {
+		return
+	}
+	p.reported = percent
+	emitDownloadProgress(p.ctx, p.stationID, p.title, p.done, p.total, p.bytes)
+}
+
+// begin records a segment starting to download, returning the func to call when it ends
+func (p *downloadProgress) begin() (end func()) {
+	if p == nil {
+		return func() {}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active++
+	p.lastActivity = time.Now()
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.active--
+	}
+}
+
+// idle returns how long the segments being downloaded have made no progress,
+// or 0 if none is being downloaded (e.g., waiting for a worker or a retry)
+func (p *downloadProgress) idle() time.Duration {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active == 0 {
+		return 0
+	}
+	return time.Since(p.lastActivity)
+}
+
+// retry records n retried segment requests
+func (p *downloadProgress) retry(n int) {
+	if p == nil || n <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries += n
+}
+
+// stats returns the bytes downloaded and the retries so far
+func (p *downloadProgress) stats() (bytes int64, retries int) {
+	if p == nil {
+		return 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.bytes, p.retries
+}
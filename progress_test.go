@@ -0,0 +1,102 @@
+package radikron
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDownloadProgress_EmitsOnPercentChange(t *testing.T) {
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
+	prog := &Prog{StationID: "FMT", Title: "Test Program"}
+
+	// 200 segments: a report every 2 segments
+	p := newDownloadProgress(ctx, prog, 200, 0)
+	for i := 0; i < 200; i++ {
+		p.add(10)
+	}
+
+	if len(emitter.downloadProgress) != 100 {
+		t.Fatalf("expected 100 progress events, got %d", len(emitter.downloadProgress))
+	}
+	last := emitter.downloadProgress[len(emitter.downloadProgress)-1]
+	if last.done != 200 || last.total != 200 || last.bytes != 2000 {
+		t.Errorf("unexpected last progress: %+v", last)
+	}
+}
+
+func TestDownloadProgress_Resumed(t *testing.T) {
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
+	prog := &Prog{StationID: "FMT", Title: "Test Program"}
+
+	p := newDownloadProgress(ctx, prog, 4, 3)
+	p.add(5)
+
+	if len(emitter.downloadProgress) != 1 {
+		t.Fatalf("expected 1 progress event, got %d", len(emitter.downloadProgress))
+	}
+	if got := emitter.downloadProgress[0]; got.done != 4 || got.total != 4 || got.bytes != 5 {
+		t.Errorf("unexpected progress: %+v", got)
+	}
+}
+
+func TestDownloadProgress_Nil(t *testing.T) {
+	var p *downloadProgress
+	p.add(1) // must not panic
+}
+
+func TestBulkDownload_Progress(t *testing.T) {
+	InitSemaphores(&Asset{
+		MaxDownloadingConcurrency: 2,
+		MaxEncodingConcurrency:    1,
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
+	prog := &Prog{StationID: "FMT", Title: "Test Program"}
+	urls := []string{
+		server.URL + "/chunk1.aac",
+		server.URL + "/chunk2.aac",
+		server.URL + "/chunk3.aac",
+	}
+
+	progress := newDownloadProgress(ctx, prog, len(urls), 0)
+	if err := bulkDownload(ctx, urls, t.TempDir(), nil, progress); err != nil {
+		t.Fatalf("bulkDownload failed: %v", err)
+	}
+
+	if len(emitter.downloadProgress) != len(urls) {
+		t.Fatalf("expected %d progress events, got %d", len(urls), len(emitter.downloadProgress))
+	}
+	last := emitter.downloadProgress[len(emitter.downloadProgress)-1]
+	if last.done != 3 || last.total != 3 || last.bytes != 30 {
+		t.Errorf("unexpected last progress: %+v", last)
+	}
+}
+
+func TestDownloadProgress_Stats(t *testing.T) {
+	p := newDownloadProgress(context.Background(), &Prog{StationID: "FMT", Title: "Test Program"}, 4, 0)
+	p.add(10)
+	p.add(20)
+	p.retry(2)
+	p.retry(0)
+
+	bytes, retries := p.stats()
+	if bytes != 30 || retries != 2 {
+		t.Errorf("expected 30 bytes and 2 retries, got %d and %d", bytes, retries)
+	}
+
+	var none *downloadProgress
+	none.retry(1)
+	if bytes, retries := none.stats(); bytes != 0 || retries != 0 {
+		t.Errorf("expected no stats for a nil progress, got %d and %d", bytes, retries)
+	}
+}
@@ -0,0 +1,207 @@
+package radikron
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeepForever is the keep of a rule whose recordings are never pruned
+const KeepForever = "forever"
+
+// KeepForeverDuration is the KeepDuration of KeepForever
+const KeepForeverDuration = time.Duration(math.MaxInt64)
+
+// PruneOptions selects the recordings PruneRecordings removes
+type PruneOptions struct {
+	// OlderThan is the age of the recordings to remove, 0 for none; the keep of a rule overrides it in its folders
+	OlderThan time.Duration
+	// MaxSize is the total size in bytes to cut the recordings down to, the oldest first, 0 for no limit;
+	// the recordings younger than the keep of their rule are never removed for it
+	MaxSize int64
+	// Folder limits the recordings to this folder under the downloads dir and its subfolders, "" for all
+	Folder string
+	// Trash moves the recordings to TrashDirName in RADICRON_HOME instead of deleting them
+	Trash bool
+	// DryRun only lists the recordings to remove
+	DryRun bool
+}
+
+// PrunedRecording is a recording removed, or to be removed in the dry run, with the sidecar files next to it
+type PrunedRecording struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+	// Reason is why the recording is removed, e.g., "older than 90d"
+	Reason string
+}
+
+// prunable is a recording in the scope of the prune with the keep of its folder
+type prunable struct {
+	PrunedRecording
+	keep time.Duration
+}
+
+// PruneRecordings removes the recordings in the downloads dir older than the keep of their rule's folder,
+// or than the OlderThan of the options without one, and then the oldest ones until the rest fit in the MaxSize,
+// by their modification time (the broadcast time with preserve-timestamp); returns the recordings removed,
+// oldest first, up to the first failure
+func PruneRecordings(downloadDir string, rules Rules, opts PruneOptions, now time.Time) ([]PrunedRecording, error) {
+	downloadsDir, err := getRadicronPath(downloadDir)
+	if err != nil {
+		return nil, err
+	}
+	recordings, err := findPrunable(downloadsDir, keepByFolder(rules), filepath.ToSlash(filepath.Clean(opts.Folder)))
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []PrunedRecording
+	var kept []prunable
+	var total int64
+	for _, r := range recordings {
+		maxAge, limit := opts.OlderThan, "older than "+formatAge(opts.OlderThan)
+		if r.keep > 0 {
+			maxAge, limit = r.keep, "older than the keep "+formatAge(r.keep)+" of its folder"
+		}
+		if maxAge > 0 && maxAge != KeepForeverDuration && now.Sub(r.Modified) > maxAge {
+			r.Reason = limit
+			pruned = append(pruned, r.PrunedRecording)
+			continue
+		}
+		kept = append(kept, r)
+		total += r.Size
+	}
+	// the oldest first until the rest fit
+	for _, r := range kept {
+		if opts.MaxSize <= 0 || total <= opts.MaxSize {
+			break
+		}
+		if r.keep > 0 && (r.keep == KeepForeverDuration || now.Sub(r.Modified) <= r.keep) {
+			continue
+		}
+		r.Reason = "over the max size"
+		pruned = append(pruned, r.PrunedRecording)
+		total -= r.Size
+	}
+	sort.SliceStable(pruned, func(i, j int) bool { return pruned[i].Modified.Before(pruned[j].Modified) })
+	if opts.DryRun {
+		return pruned, nil
+	}
+
+	for i, r := range pruned {
+		if err := removeRecording(downloadsDir, r.Path, opts.Trash); err != nil {
+			return pruned[:i], fmt.Errorf("failed to remove %s: %w", r.Path, err)
+		}
+	}
+	return pruned, nil
+}
+
+// keepByFolder returns the longest keep of the rules saving to each folder
+func keepByFolder(rules Rules) map[string]time.Duration {
+	keeps := map[string]time.Duration{}
+	for _, r := range rules {
+		keep, err := r.KeepDuration()
+		if err != nil || keep == 0 {
+			continue
+		}
+		folders := []string{r.Folder}
+		for _, folder := range r.FolderByTag {
+			folders = append(folders, folder)
+		}
+		for _, folder := range folders {
+			if folder == "" {
+				continue
+			}
+			folder = filepath.ToSlash(filepath.Clean(folder))
+			keeps[folder] = max(keeps[folder], keep)
+		}
+	}
+	return keeps
+}
+
+// findPrunable returns the recordings in the folder of the downloads dir ("." for all), oldest first,
+// skipping the hidden files and dirs, e.g., the part files
+func findPrunable(downloadsDir string, keeps map[string]time.Duration, folder string) ([]prunable, error) {
+	var recordings []prunable
+	err := filepath.WalkDir(downloadsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == downloadsDir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if path != downloadsDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isAudioOutput(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(downloadsDir, path)
+		if err != nil {
+			return err
+		}
+		dir := filepath.ToSlash(filepath.Dir(rel))
+		if folder != "." && dir != folder && !strings.HasPrefix(dir, folder+"/") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		recordings = append(recordings, prunable{
+			PrunedRecording: PrunedRecording{Path: path, Size: info.Size(), Modified: info.ModTime()},
+			keep:            keeps[dir],
+		})
+		return nil
+	})
+	sort.SliceStable(recordings, func(i, j int) bool { return recordings[i].Modified.Before(recordings[j].Modified) })
+	return recordings, err
+}
+
+// removeRecording removes the recording with its sidecar files and transcript,
+// or moves them to the trash keeping their path under the downloads dir
+func removeRecording(downloadsDir, path string, trash bool) error {
+	if !trash {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		for _, ext := range sidecarExts() {
+			_ = os.Remove(sidecarPath(path, ext))
+		}
+		return nil
+	}
+	rel, err := filepath.Rel(downloadsDir, path)
+	if err != nil {
+		return err
+	}
+	dest, err := getRadicronPath(filepath.Join(TrashDirName, rel))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), DirPermissions); err != nil {
+		return err
+	}
+	if err := moveFile(path, dest); err != nil {
+		return err
+	}
+	moveSidecars(path, dest)
+	return nil
+}
+
+// formatAge returns the age in days if whole, e.g., "90d", or as a duration
+func formatAge(d time.Duration) string {
+	day := OneDay * time.Hour
+	if d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,87 @@
+package radikron
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeRecording writes a recording of size bytes modified age before now under the downloads dir
+func writeRecording(t *testing.T, downloadsDir, rel string, size int, now time.Time, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(downloadsDir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modified := now.Add(-age)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPruneRecordings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	downloadsDir := filepath.Join(home, "downloads")
+	now := time.Date(2023, 6, 5, 12, 0, 0, 0, Location)
+	day := OneDay * time.Hour
+
+	oldRoot := writeRecording(t, downloadsDir, "old.mp3", 10, now, 100*day)
+	newRoot := writeRecording(t, downloadsDir, "new.mp3", 10, now, 10*day)
+	oldCitypop := writeRecording(t, downloadsDir, "citypop/old.aac", 10, now, 40*day)
+	newCitypop := writeRecording(t, downloadsDir, "citypop/new.aac", 10, now, 20*day)
+	archive := writeRecording(t, downloadsDir, "archive/old.m4a", 10, now, 400*day)
+	writeRecording(t, downloadsDir, "citypop/old.txt", 10, now, 40*day)
+	writeRecording(t, downloadsDir, ".tmp/part.mp3", 10, now, 400*day)
+	rules := Rules{
+		{Name: "citypop", Keyword: "シティポップ", Folder: "citypop", Keep: "30d"},
+		{Name: "archive", Title: "Archive", Folder: "archive", Keep: KeepForever},
+	}
+
+	// the dry run only lists
+	pruned, err := PruneRecordings("downloads", rules, PruneOptions{OlderThan: 90 * day, DryRun: true}, now)
+	if err != nil {
+		t.Fatalf("PruneRecordings failed: %v", err)
+	}
+	if len(pruned) != 2 || pruned[0].Path != oldRoot || pruned[1].Path != oldCitypop {
+		t.Fatalf("expected the old recordings outside the keep, got %+v", pruned)
+	}
+	if pruned[0].Reason != "older than 90d" || pruned[1].Reason != "older than the keep 30d of its folder" {
+		t.Errorf("unexpected reasons %q and %q", pruned[0].Reason, pruned[1].Reason)
+	}
+	if _, err := os.Stat(oldRoot); err != nil {
+		t.Errorf("expected the dry run to keep the recording: %v", err)
+	}
+
+	// the folder only, with the transcript
+	if _, err := PruneRecordings("downloads", rules, PruneOptions{Folder: "citypop"}, now); err != nil {
+		t.Fatalf("PruneRecordings failed: %v", err)
+	}
+	for path, want := range map[string]bool{oldRoot: true, oldCitypop: false, newCitypop: true, sidecarPath(oldCitypop, TranscriptExt): false} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("expected %s to exist: %v", path, want)
+		}
+	}
+
+	// the oldest ones over the size to the trash, never the ones the keep protects
+	pruned, err = PruneRecordings("downloads", rules, PruneOptions{MaxSize: 30, Trash: true}, now)
+	if err != nil {
+		t.Fatalf("PruneRecordings failed: %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].Path != oldRoot || pruned[0].Reason != "over the max size" {
+		t.Fatalf("expected the oldest recording without a keep, got %+v", pruned)
+	}
+	if _, err := os.Stat(filepath.Join(home, TrashDirName, "old.mp3")); err != nil {
+		t.Errorf("expected the recording in the trash: %v", err)
+	}
+	for _, path := range []string{newRoot, newCitypop, archive} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s kept: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// queueMu serializes the updates to the queue file
+	queueMu sync.Mutex
+	// inFlight holds the programs being downloaded by this process
+	inFlight   = map[string]*Prog{}
+	inFlightMu sync.Mutex
+)
+
+// queueFile persists the programs matched by a rule but not downloaded yet,
+// so that they survive restarts even after dropping out of the weekly program guide
+type queueFile struct {
+	Version  int              `json:"version"`
+	Programs map[string]*Prog `json:"programs"`
+}
+
+// queuePath returns the path to the queue file
+func queuePath() (string, error) {
+	home, err := getRadicronPath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, QueueFileName), nil
+}
+
+// loadQueueFile reads the queue file; the caller must hold queueMu
+func loadQueueFile(path string) (*queueFile, error) {
+	q := &queueFile{Version: QueueVersion, Programs: map[string]*Prog{}}
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the queue: %w", err)
+	}
+	if err := json.Unmarshal(blob, q); err != nil {
+		return nil, fmt.Errorf("failed to parse the queue: %w", err)
+	}
+	if q.Version > QueueVersion {
+		return nil, fmt.Errorf("unsupported queue version: %d", q.Version)
+	}
+	if q.Programs == nil {
+		q.Programs = map[string]*Prog{}
+	}
+	return q, nil
+}
+
+// updateQueue applies update to the queue file and saves it if update returns true
+func updateQueue(update func(q *queueFile) bool) error {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+
+	path, err := queuePath()
+	if err != nil {
+		return err
+	}
+	q, err := loadQueueFile(path)
+	if err != nil {
+		return err
+	}
+	if !update(q) {
+		return nil
+	}
+	q.Version = QueueVersion
+
+	if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+		return fmt.Errorf("failed to create RADICRON_HOME: %w", err)
+	}
+	blob, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, blob, FilePermissions)
+}
+
+// enqueueProgram records the program in the queue until it is downloaded
+func enqueueProgram(ctx context.Context, prog *Prog) {
+	err := updateQueue(func(q *queueFile) bool {
+		key := programLockKey(prog)
+		if _, ok := q.Programs[key]; ok {
+			return false
+		}
+		q.Programs[key] = prog
+		return true
+	})
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to queue [%s]%s: %v", prog.StationID, prog.Title, err))
+	}
+}
+
+// dequeueProgram removes the program from the queue once it needs no more download
+func dequeueProgram(ctx context.Context, prog *Prog) {
+	err := updateQueue(func(q *queueFile) bool {
+		key := programLockKey(prog)
+		if _, ok := q.Programs[key]; !ok {
+			return false
+		}
+		delete(q.Programs, key)
+		return true
+	})
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to dequeue [%s]%s: %v", prog.StationID, prog.Title, err))
+	}
+}
+
+// LoadQueue returns the queued programs in the order of their start time
+func LoadQueue() (Progs, error) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+
+	path, err := queuePath()
+	if err != nil {
+		return nil, err
+	}
+	q, err := loadQueueFile(path)
+	if err != nil {
+		return nil, err
+	}
+	progs := make(Progs, 0, len(q.Programs))
+	for _, prog := range q.Programs {
+		progs = append(progs, prog)
+	}
+	sort.Slice(progs, func(i, j int) bool {
+		if progs[i].Ft != progs[j].Ft {
+			return progs[i].Ft < progs[j].Ft
+		}
+		return progs[i].StationID < progs[j].StationID
+	})
+	return progs, nil
+}
+
+// PendingQueue returns the programs queued before a restart to download them again.
+// The programs no longer available on timefree are dropped from the queue.
+func PendingQueue(ctx context.Context) (Progs, error) {
+	progs, err := LoadQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(Progs, 0, len(progs))
+	for _, prog := range progs {
+		if isTimefreeExpired(prog, CurrentTime) {
+			emitLogMessage(ctx, "info", fmt.Sprintf(
+				"queued program is no longer available on timefree, dropping [%s]%s (%s)", prog.StationID, prog.Title, prog.Ft))
+			dequeueProgram(ctx, prog)
+			continue
+		}
+		pending = append(pending, prog)
+	}
+	return pending, nil
+}
+
+// isTimefreeExpired returns true if the program has dropped out of the timefree window at now
+func isTimefreeExpired(prog *Prog, now time.Time) bool {
+	start, err := ParseDatetime(prog.Ft)
+	if err != nil {
+		return true
+	}
+	return now.After(start.Add(TimefreeWindow))
+}
+
+// acquireInFlight marks the program as being downloaded by this process;
+// it returns false if the program is already in flight
+func acquireInFlight(prog *Prog) bool {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+	key := programLockKey(prog)
+	if inFlight[key] != nil {
+		return false
+	}
+	inFlight[key] = prog
+	return true
+}
+
+// releaseInFlight clears the in-flight mark of the program
+func releaseInFlight(prog *Prog) {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+	delete(inFlight, programLockKey(prog))
+}
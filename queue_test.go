@@ -0,0 +1,177 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestQueue_EnqueueDequeue(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	ctx := context.Background()
+
+	later := &Prog{ID: "2", StationID: "TBS", Ft: "20230605130000", To: "20230605140000", Title: "Later", RuleName: "rule"}
+	earlier := &Prog{ID: "1", StationID: "FMT", Ft: "20230605100000", To: "20230605110000", Title: "Earlier", AreaFree: true}
+	enqueueProgram(ctx, later)
+	enqueueProgram(ctx, earlier)
+	enqueueProgram(ctx, earlier) // no duplicate
+
+	progs, err := LoadQueue()
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	if len(progs) != 2 || progs[0].ID != "1" || progs[1].ID != "2" {
+		t.Fatalf("expected the programs in the start time order, got %+v", progs)
+	}
+	if progs[1].RuleName != "rule" || !progs[0].AreaFree {
+		t.Errorf("expected the rule fields to be restored, got %+v %+v", progs[0], progs[1])
+	}
+
+	blob, err := os.ReadFile(filepath.Join(home, QueueFileName))
+	if err != nil {
+		t.Fatalf("failed to read the queue file: %v", err)
+	}
+	var q queueFile
+	if err := json.Unmarshal(blob, &q); err != nil {
+		t.Fatalf("failed to parse the queue file: %v", err)
+	}
+	if q.Version != QueueVersion {
+		t.Errorf("expected version %d, got %d", QueueVersion, q.Version)
+	}
+
+	dequeueProgram(ctx, earlier)
+	progs, err = LoadQueue()
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	if len(progs) != 1 || progs[0].ID != "2" {
+		t.Errorf("expected only the later program, got %+v", progs)
+	}
+}
+
+func TestLoadQueue_Empty(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	progs, err := LoadQueue()
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	if len(progs) != 0 {
+		t.Errorf("expected no programs, got %d", len(progs))
+	}
+}
+
+func TestLoadQueue_NewerVersion(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	if err := os.WriteFile(filepath.Join(home, QueueFileName), []byte(`{"version":99}`), 0600); err != nil {
+		t.Fatalf("failed to write the queue file: %v", err)
+	}
+	if _, err := LoadQueue(); err == nil {
+		t.Error("expected an error for a newer queue version")
+	}
+}
+
+func TestPendingQueue_DropsExpired(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	ctx := context.Background()
+	CurrentTime = time.Date(2023, 6, 12, 12, 0, 0, 0, Location)
+
+	expired := &Prog{ID: "old", StationID: "FMT", Ft: "20230604100000", To: "20230604110000"}
+	available := &Prog{ID: "new", StationID: "FMT", Ft: "20230610100000", To: "20230610110000"}
+	enqueueProgram(ctx, expired)
+	enqueueProgram(ctx, available)
+
+	pending, err := PendingQueue(ctx)
+	if err != nil {
+		t.Fatalf("PendingQueue failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != "new" {
+		t.Errorf("expected only the available program, got %+v", pending)
+	}
+	progs, _ := LoadQueue()
+	if len(progs) != 1 {
+		t.Errorf("expected the expired program to be dropped from the queue, got %d programs", len(progs))
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	prog := &Prog{ID: "in-flight"}
+	if !acquireInFlight(prog) {
+		t.Fatal("expected to acquire the program")
+	}
+	if acquireInFlight(prog) {
+		t.Error("expected the program to be in flight")
+	}
+	releaseInFlight(prog)
+	if !acquireInFlight(prog) {
+		t.Error("expected to acquire the released program")
+	}
+	releaseInFlight(prog)
+}
+
+func TestDownload_Queue(t *testing.T) {
+	testDir := t.TempDir()
+	t.Setenv(EnvRadicronHome, testDir)
+	CurrentTime = time.Date(2023, 6, 5, 16, 0, 0, 0, Location)
+
+	asset := &Asset{
+		OutputFormat: radigo.AudioFormatAAC,
+		DownloadDir:  "downloads",
+		Rules:        Rules{},
+		Schedules:    Schedules{},
+	}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	prog := &Prog{
+		ID:        "test-queue",
+		StationID: "FMT",
+		Title:     "Test Program",
+		Ft:        "20230605130000",
+		To:        "20230605140000",
+	}
+
+	// the program being downloaded stays in the queue
+	if !acquireInFlight(prog) {
+		t.Fatal("expected to acquire the program")
+	}
+	if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	releaseInFlight(prog)
+	if len(emitter.downloadSkipped) != 1 || emitter.downloadSkipped[0].reason != "already downloading" {
+		t.Errorf("expected an already downloading skip event, got %+v", emitter.downloadSkipped)
+	}
+	progs, err := LoadQueue()
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	if len(progs) != 1 || progs[0].ID != prog.ID {
+		t.Fatalf("expected the program in the queue, got %+v", progs)
+	}
+
+	// the program found at the target is dequeued
+	output := newOutputConfigFromPath(
+		filepath.Join(testDir, "downloads"), "2023-06-05-1300_FMT_Test Program", radigo.AudioFormatAAC)
+	if err := os.WriteFile(output.AbsPath(), []byte("audio"), 0600); err != nil {
+		t.Fatalf("failed to create the output: %v", err)
+	}
+	if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	progs, err = LoadQueue()
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	if len(progs) != 0 {
+		t.Errorf("expected the existing program to be dequeued, got %+v", progs)
+	}
+}
@@ -0,0 +1,70 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ActiveQuietHours returns the first window of the asset's quiet hours containing t, or nil
+func (a *Asset) ActiveQuietHours(t time.Time) *ThrottleWindow {
+	if a == nil {
+		return nil
+	}
+	for i := range a.QuietHours {
+		if a.QuietHours[i].Contains(t) {
+			return &a.QuietHours[i]
+		}
+	}
+	return nil
+}
+
+// QuietHoursEnd returns when the quiet hours containing t end, following the adjacent windows,
+// or the zero time if t is not in the quiet hours
+func (a *Asset) QuietHoursEnd(t time.Time) time.Time {
+	var end time.Time
+	// a day of the windows covers them all
+	for i := 0; i <= len(a.QuietHours); i++ {
+		w := a.ActiveQuietHours(t)
+		if w == nil {
+			break
+		}
+		t = w.End(t)
+		end = t
+	}
+	return end
+}
+
+// deferForQuietHours queues the program to download once the quiet hours containing now end,
+// fetching again then; returns false if now is not in the quiet hours
+func deferForQuietHours(ctx context.Context, asset *Asset, prog *Prog, now time.Time) bool {
+	end := asset.QuietHoursEnd(now)
+	if end.IsZero() {
+		return false
+	}
+	enqueueProgram(ctx, prog)
+	if asset.NextFetchTime == nil || asset.NextFetchTime.After(end) {
+		asset.NextFetchTime = &end
+	}
+	emitLogMessage(ctx, "info", fmt.Sprintf("quiet hours, deferring [%s]%s until %s",
+		prog.StationID, prog.Title, end.Format(DatetimeLayout)))
+	return true
+}
+
+// waitQuietHours blocks until the asset's quiet hours end, returning false if ctx is done first
+func waitQuietHours(ctx context.Context, asset *Asset) bool {
+	now := throttleNow()
+	end := asset.QuietHoursEnd(now)
+	if end.IsZero() {
+		return true
+	}
+	emitLogMessage(ctx, "info", fmt.Sprintf("waiting for the quiet hours to end at %v", end))
+	timer := time.NewTimer(end.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,93 @@
+package radikron
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestQuietHoursEnd(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2023, 6, day, hour, minute, 0, 0, Location)
+	}
+	night, _ := ParseThrottleWindow("23:00", "02:00", 0, 0)
+	early, _ := ParseThrottleWindow("02:00", "07:00", 0, 0)
+	asset := &Asset{QuietHours: []ThrottleWindow{night, early}}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{"outside", at(5, 12, 0), time.Time{}},
+		{"adjacent windows", at(5, 23, 30), at(6, 7, 0)},
+		{"second window", at(6, 3, 0), at(6, 7, 0)},
+		{"end", at(6, 7, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := asset.QuietHoursEnd(tt.t); !got.Equal(tt.want) {
+				t.Errorf("QuietHoursEnd(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+	if (&Asset{}).QuietHoursEnd(at(5, 23, 30)) != (time.Time{}) {
+		t.Error("expected no quiet hours without the windows")
+	}
+}
+
+func TestDownload_QuietHours(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 6, 3, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+
+	quiet, _ := ParseThrottleWindow("01:00", "07:00", 0, 0)
+	emitter := &mockEventEmitter{}
+	asset := &Asset{
+		OutputFormat: radigo.AudioFormatAAC,
+		DownloadDir:  "downloads",
+		Rules:        Rules{},
+		Schedules:    Schedules{},
+		QuietHours:   []ThrottleWindow{quiet},
+	}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	// ended before the quiet hours
+	prog := &Prog{ID: "FMT-1", StationID: "FMT", Title: "Test Program", Ft: "20230606000000", To: "20230606010000"}
+	if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+		t.Fatalf("Download should not return error in the quiet hours: %v", err)
+	}
+
+	want := time.Date(2023, 6, 6, 7, 0, 0, 0, Location)
+	if asset.NextFetchTime == nil || !asset.NextFetchTime.Equal(want) {
+		t.Errorf("expected the next fetch at %v, got %v", want, asset.NextFetchTime)
+	}
+	if len(emitter.downloadStarted) != 0 {
+		t.Error("expected the program not to be downloaded in the quiet hours")
+	}
+	if queued, err := LoadQueue(); err != nil || len(queued) != 1 || queued[0].ID != "FMT-1" {
+		t.Errorf("expected the program queued for after the quiet hours, got %v, %v", queued, err)
+	}
+}
+
+func TestWaitQuietHours(t *testing.T) {
+	now := time.Date(2023, 6, 5, 3, 0, 0, 0, Location)
+	origNow := throttleNow
+	throttleNow = func() time.Time { return now }
+	t.Cleanup(func() { throttleNow = origNow })
+
+	if !waitQuietHours(context.Background(), &Asset{}) {
+		t.Error("expected no wait without the quiet hours")
+	}
+	quiet, _ := ParseThrottleWindow("01:00", "07:00", 0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if waitQuietHours(ctx, &Asset{QuietHours: []ThrottleWindow{quiet}}) {
+		t.Error("expected the wait canceled in the quiet hours")
+	}
+}
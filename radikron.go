@@ -3,6 +3,8 @@ package radikron
 import (
 	"context"
 	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,23 +20,43 @@ func init() { //nolint:gochecknoinits
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	useProxyForRadiko()
 }
 
 // EventEmitter defines the interface for emitting structured events.
 // Implementations can provide structured events to external systems (e.g., GUI).
 type EventEmitter interface {
-	// EmitDownloadStarted emits when a download starts
-	EmitDownloadStarted(stationID, title, startTime, uri string)
-	// EmitDownloadCompleted emits when a download completes successfully (file written to disk)
-	EmitDownloadCompleted(stationID, title, filePath string)
+	// EmitDownloadStarted emits when a download of the program starts
+	EmitDownloadStarted(prog *Prog, uri string)
+	// EmitDownloadCompleted emits when a download of the program completes successfully (file written to disk)
+	EmitDownloadCompleted(prog *Prog, filePath string)
 	// EmitFileSaved emits when a file is fully saved with metadata tags
 	EmitFileSaved(stationID, title, filePath string)
 	// EmitDownloadSkipped emits when a download is skipped (duplicate, already exists, etc.)
 	EmitDownloadSkipped(reason string, stationID, title, startTime string)
+	// EmitDownloadProgress emits when the downloaded segments of a program increase
+	EmitDownloadProgress(stationID, title string, done, total int, bytes int64)
 	// EmitEncodingStarted emits when encoding to MP3 starts
 	EmitEncodingStarted(filePath string)
 	// EmitEncodingCompleted emits when encoding to MP3 completes successfully
 	EmitEncodingCompleted(filePath string)
+	// EmitProgramMatched emits when a program matches a rule in read-only mode (nothing is downloaded)
+	EmitProgramMatched(stationID, title, startTime, ruleName string)
+	// EmitProgramUpcoming emits when a rule matches a future program, with the radiko share link to listen live
+	EmitProgramUpcoming(stationID, title, startTime, ruleName, shareURL, downloadTime string)
+	// EmitProgramExtended emits when a matched program runs longer than its usual slot (e.g., a year-end special)
+	EmitProgramExtended(stationID, title, startTime string, usualMinutes, minutes int)
+	// EmitProgramFailed emits when a program is given up after failing attempts times
+	EmitProgramFailed(stationID, title, startTime string, attempts int)
+	// EmitDownloadStalled emits when a download making no progress for timeout is canceled to resume from the queue
+	EmitDownloadStalled(stationID, title, startTime string, timeout time.Duration)
+	// EmitFileMoved emits when a saved file is moved (e.g., from the downloads dir to the folder of its rule)
+	EmitFileMoved(oldPath, newPath string)
+	// EmitScheduleConflict emits when the live recordings overlap beyond the limit, with the dropped programs
+	EmitScheduleConflict(dropped []*Prog, limit int)
+	// EmitConfigSummary emits the effective configuration on startup
+	EmitConfigSummary(summary ConfigSummary)
 	// EmitLogMessage emits a general log message (for backward compatibility)
 	EmitLogMessage(level string, message string)
 }
@@ -49,3 +71,138 @@ func GetEventEmitter(ctx context.Context) EventEmitter {
 	}
 	return emitter
 }
+
+var (
+	// defaultEventEmitter handles the events without an EventEmitter in the context
+	defaultEventEmitter   EventEmitter = LogEventEmitter{}
+	defaultEventEmitterMu sync.RWMutex
+)
+
+// SetDefaultEventEmitter sets the EventEmitter of the events without one in the context,
+// e.g., a SlogEventEmitter for the JSON logs; nil restores LogEventEmitter
+func SetDefaultEventEmitter(emitter EventEmitter) {
+	if emitter == nil {
+		emitter = LogEventEmitter{}
+	}
+	defaultEventEmitterMu.Lock()
+	defer defaultEventEmitterMu.Unlock()
+	defaultEventEmitter = emitter
+}
+
+// DefaultEventEmitter returns the EventEmitter of the events without one in the context
+func DefaultEventEmitter() EventEmitter {
+	defaultEventEmitterMu.RLock()
+	defer defaultEventEmitterMu.RUnlock()
+	return defaultEventEmitter
+}
+
+// eventEmitter returns the EventEmitter from context, or DefaultEventEmitter if none is set (CLI mode)
+func eventEmitter(ctx context.Context) EventEmitter {
+	if emitter := GetEventEmitter(ctx); emitter != nil {
+		return emitter
+	}
+	return DefaultEventEmitter()
+}
+
+// LogEventEmitter implements EventEmitter by logging the events, as in CLI mode.
+// Embed it to handle some events (e.g., to notify them) and log the rest.
+type LogEventEmitter struct{}
+
+// Ensure LogEventEmitter implements EventEmitter at compile time
+var _ EventEmitter = LogEventEmitter{}
+
+// EmitDownloadStarted implements EventEmitter
+func (LogEventEmitter) EmitDownloadStarted(prog *Prog, uri string) {
+	log.Printf("start downloading [%s]%s (%s): %s", prog.StationID, prog.Title, prog.Ft, uri)
+}
+
+// EmitDownloadCompleted implements EventEmitter
+func (LogEventEmitter) EmitDownloadCompleted(prog *Prog, filePath string) {
+	log.Printf("download completed [%s]%s: %s", prog.StationID, prog.Title, filePath)
+}
+
+// EmitFileSaved implements EventEmitter
+func (LogEventEmitter) EmitFileSaved(stationID, title, filePath string) {
+	log.Printf("+file saved: %s", filePath)
+}
+
+// EmitDownloadSkipped implements EventEmitter
+func (LogEventEmitter) EmitDownloadSkipped(reason, stationID, title, startTime string) {
+	if stationID != "" && title != "" && startTime != "" {
+		log.Printf("-skip %s [%s]%s (%s)", reason, stationID, title, startTime)
+	} else {
+		log.Printf("-skip %s", reason)
+	}
+}
+
+// EmitDownloadProgress implements EventEmitter, logging at every 10%
+func (LogEventEmitter) EmitDownloadProgress(stationID, title string, done, total int, bytes int64) {
+	if total > 0 && (done == total || done*10/total > (done-1)*10/total) {
+		log.Printf("downloading [%s]%s: %d/%d segments (%d%%, %.1f MB)",
+			stationID, title, done, total, done*100/total, float64(bytes)/Kilobytes/Kilobytes)
+	}
+}
+
+// EmitEncodingStarted implements EventEmitter
+func (LogEventEmitter) EmitEncodingStarted(filePath string) {
+	log.Printf("start encoding to MP3: %s", filePath)
+}
+
+// EmitEncodingCompleted implements EventEmitter
+func (LogEventEmitter) EmitEncodingCompleted(filePath string) {
+	log.Printf("finish encoding to MP3: %s", filePath)
+}
+
+// EmitProgramMatched implements EventEmitter
+func (LogEventEmitter) EmitProgramMatched(stationID, title, startTime, ruleName string) {
+	log.Printf("*match rule[%s] [%s]%s (%s)", ruleName, stationID, title, startTime)
+}
+
+// EmitProgramUpcoming implements EventEmitter
+func (LogEventEmitter) EmitProgramUpcoming(stationID, title, startTime, ruleName, shareURL, downloadTime string) {
+	log.Printf("*upcoming rule[%s] [%s]%s (%s), downloading at %s, listen: %s",
+		ruleName, stationID, title, startTime, downloadTime, shareURL)
+}
+
+// EmitProgramExtended implements EventEmitter
+func (LogEventEmitter) EmitProgramExtended(stationID, title, startTime string, usualMinutes, minutes int) {
+	log.Printf("!extended [%s]%s (%s) runs %d minutes, usually %d minutes",
+		stationID, title, startTime, minutes, usualMinutes)
+}
+
+// EmitProgramFailed implements EventEmitter
+func (LogEventEmitter) EmitProgramFailed(stationID, title, startTime string, attempts int) {
+	log.Printf("!failed [%s]%s (%s) after %d attempts, giving up", stationID, title, startTime, attempts)
+}
+
+// EmitDownloadStalled implements EventEmitter
+func (LogEventEmitter) EmitDownloadStalled(stationID, title, startTime string, timeout time.Duration) {
+	log.Printf("!stalled [%s]%s (%s) made no progress for %s, resuming it later", stationID, title, startTime, timeout)
+}
+
+// EmitFileMoved implements EventEmitter
+func (LogEventEmitter) EmitFileMoved(oldPath, newPath string) {
+	log.Printf("moved file: %s -> %s", oldPath, newPath)
+}
+
+// EmitScheduleConflict implements EventEmitter
+func (LogEventEmitter) EmitScheduleConflict(dropped []*Prog, limit int) {
+	for _, p := range dropped {
+		log.Printf("!conflict dropped the live recording [%s]%s (%s) of rule[%s] over %d at once",
+			p.StationID, p.Title, p.Ft, p.RuleName, limit)
+	}
+}
+
+// EmitConfigSummary implements EventEmitter
+func (LogEventEmitter) EmitConfigSummary(summary ConfigSummary) {
+	log.Printf("effective configuration: %s", summary)
+}
+
+// EmitLogMessage implements EventEmitter
+func (LogEventEmitter) EmitLogMessage(level, message string) {
+	// Use default level if empty or missing
+	if level == "" {
+		level = "INFO"
+	}
+	log.Printf("[%s] %s", strings.ToUpper(level), message)
+}
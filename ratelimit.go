@@ -0,0 +1,99 @@
+package radikron
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by the requests to radiko
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rateLimiter allowing rps requests per second
+// with bursts of up to rps (at least 1) requests
+func newRateLimiter(rps float64) *rateLimiter {
+	burst := math.Max(1, math.Ceil(rps))
+	return &rateLimiter{
+		rate:   rps,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns the time to wait until it is available
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Wait blocks until a request is allowed or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// InitRateLimiter initializes the asset's rate limiter shared by segment downloads and playlist fetches
+// based on its RequestsPerSecond, or that of the throttle window active now; zero or negative disables the limit
+func InitRateLimiter(asset *Asset) {
+	if asset == nil {
+		return
+	}
+
+	_, requestsPerSecond := asset.throttledLimits(throttleNow())
+
+	asset.mu.Lock()
+	defer asset.mu.Unlock()
+
+	if requestsPerSecond <= 0 {
+		asset.requestLimiter = nil
+		return
+	}
+	// Keep the current bucket if the rate is unchanged
+	if asset.requestLimiter == nil || asset.requestLimiter.rate != requestsPerSecond {
+		asset.requestLimiter = newRateLimiter(requestsPerSecond)
+	}
+}
+
+// waitRateLimit blocks until the next request to radiko is allowed by the rate limiter of the asset in ctx
+func waitRateLimit(ctx context.Context) error {
+	asset := GetAsset(ctx)
+	if asset == nil {
+		return nil
+	}
+	asset.mu.Lock()
+	l := asset.requestLimiter
+	asset.mu.Unlock()
+
+	if l == nil {
+		return nil
+	}
+	return l.Wait(ctx)
+}
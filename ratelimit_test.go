@@ -0,0 +1,72 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100)
+
+	// the burst is allowed without waiting
+	for i := 0; i < 100; i++ {
+		if d := l.reserve(); d != 0 {
+			t.Fatalf("reserve #%d => %v, want 0", i, d)
+		}
+	}
+	// the next request waits for a token
+	if d := l.reserve(); d <= 0 || d > 10*time.Millisecond {
+		t.Errorf("reserve after the burst => %v, want (0, 10ms]", d)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	l := newRateLimiter(0.01)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait => %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestInitRateLimiter(t *testing.T) {
+	asset := &Asset{RequestsPerSecond: 5}
+	InitRateLimiter(asset)
+	if asset.requestLimiter == nil || asset.requestLimiter.rate != 5 {
+		t.Fatalf("requestLimiter => %+v, want rate 5", asset.requestLimiter)
+	}
+	l := asset.requestLimiter
+
+	// the same rate keeps the bucket
+	InitRateLimiter(asset)
+	if asset.requestLimiter != l {
+		t.Error("requestLimiter should be kept for the same rate")
+	}
+
+	// the reloaded asset keeps the bucket of the previous one
+	next := &Asset{RequestsPerSecond: 5}
+	next.Inherit(asset)
+	InitRateLimiter(next)
+	if next.requestLimiter != l {
+		t.Error("requestLimiter should be inherited for the same rate")
+	}
+
+	asset.RequestsPerSecond = 0
+	InitRateLimiter(asset)
+	if asset.requestLimiter != nil {
+		t.Error("requestLimiter should be nil for unlimited")
+	}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	if err := waitRateLimit(ctx); err != nil {
+		t.Errorf("waitRateLimit failed: %v", err)
+	}
+	if err := waitRateLimit(context.Background()); err != nil {
+		t.Errorf("waitRateLimit without an asset failed: %v", err)
+	}
+}
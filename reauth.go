@@ -0,0 +1,77 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// errAuthExpired is returned when radiko rejects a request with 401/403 as the auth token expired
+var errAuthExpired = errors.New("auth token expired")
+
+// reauthMu serializes the re-auth of the devices and guards their AuthToken
+var reauthMu sync.Mutex
+
+// checkAuthStatus returns errAuthExpired if the response is 401 or 403
+func checkAuthStatus(resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %s", errAuthExpired, resp.Status)
+	}
+	return nil
+}
+
+// deviceAuthToken returns the current auth token of the device
+func deviceAuthToken(device *Device) string {
+	reauthMu.Lock()
+	defer reauthMu.Unlock()
+	return device.AuthToken
+}
+
+// reauthDevice authorizes the device for the area again if it still has the expired token,
+// so that the concurrent downloads hitting the expiry together authorize only once
+func reauthDevice(ctx context.Context, asset *Asset, device *Device, areaID, expiredToken string) error {
+	reauthMu.Lock()
+	defer reauthMu.Unlock()
+	if device.AuthToken != expiredToken {
+		return nil
+	}
+	emitLogMessage(ctx, "info", fmt.Sprintf("auth token expired, authorizing again for %s", areaID))
+	if err := device.Auth(ctx, asset, areaID); err != nil {
+		return fmt.Errorf("failed to authorize again for %s: %w", areaID, err)
+	}
+	return nil
+}
+
+// refreshChunklist fetches the playlist of the program again after the auth token expired mid-session,
+// authorizing again if needed. If previous is not nil, the new chunklist must have the same segments
+// so that the download continues where it stopped.
+func refreshChunklist(ctx context.Context, prog *Prog, previous []string) ([]string, error) {
+	uri, err := timeshiftProgM3U8(ctx, prog)
+	if err != nil {
+		return nil, err
+	}
+	prog.M3U8 = uri
+	chunklist, err := getChunklistFromM3U8(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && !sameSegments(previous, chunklist) {
+		return nil, errors.New("the chunklist changed after authorizing again")
+	}
+	return chunklist, nil
+}
+
+// sameSegments returns true if the chunklists have the same segment files in the same order
+func sameSegments(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if segmentFileName(a[i]) != segmentFileName(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckAuthStatus(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusNotFound:            false,
+		http.StatusUnauthorized:        true,
+		http.StatusForbidden:           true,
+		http.StatusInternalServerError: false,
+	} {
+		err := checkAuthStatus(&http.Response{StatusCode: status, Status: http.StatusText(status)})
+		if got := errors.Is(err, errAuthExpired); got != want {
+			t.Errorf("status %d: expected errAuthExpired %v, got %v", status, want, err)
+		}
+	}
+}
+
+func TestReauthDevice_AlreadyRenewed(t *testing.T) {
+	// another download has already authorized again, so the device is not authorized again
+	device := &Device{AuthToken: "new"}
+	if err := reauthDevice(context.Background(), &Asset{}, device, "JP13", "expired"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if got := deviceAuthToken(device); got != "new" {
+		t.Errorf("expected the renewed token to be kept, got %s", got)
+	}
+}
+
+func TestSameSegments(t *testing.T) {
+	a := []string{"https://a.example.com/1.aac", "https://a.example.com/2.aac"}
+	b := []string{"https://b.example.com/1.aac", "https://b.example.com/2.aac"}
+	if !sameSegments(a, b) {
+		t.Error("expected the same segments")
+	}
+	if sameSegments(a, b[:1]) {
+		t.Error("expected different lengths to differ")
+	}
+	if sameSegments(a, []string{b[1], b[0]}) {
+		t.Error("expected a different order to differ")
+	}
+}
+
+func TestDownloadLink_AuthExpired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("forbidden"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	err := downloadLink(context.Background(), server.URL+"/test.aac", tmpDir)
+	if !errors.Is(err, errAuthExpired) {
+		t.Errorf("expected errAuthExpired, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "test.aac")); !os.IsNotExist(err) {
+		t.Error("the error body should not be saved as a segment")
+	}
+}
+
+func TestBulkDownload_AuthExpired(t *testing.T) {
+	asset := &Asset{MaxDownloadingConcurrency: 10, Retry: RetryPolicy{MaxAttempts: 3}}
+	InitSemaphores(asset)
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := bulkDownload(ctx, []string{server.URL + "/chunk1.aac"}, t.TempDir(), nil, nil)
+	if !errors.Is(err, errAuthExpired) {
+		t.Errorf("expected errAuthExpired, got %v", err)
+	}
+	// retrying with the expired token is pointless
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected 1 request without retries, got %d", got)
+	}
+}
+
+func TestGetChunklistFromM3U8_AuthExpired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if _, err := getChunklistFromM3U8(context.Background(), server.URL+"/chunklist.m3u8"); !errors.Is(err, errAuthExpired) {
+		t.Errorf("expected errAuthExpired, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// fetchWeeklyPrograms fetches the program guide to re-queue the recovered programs
+var fetchWeeklyPrograms = FetchWeeklyPrograms
+
+// brokenOutput is an output file left empty or too small by a crash
+type brokenOutput struct {
+	path      string
+	folder    string // the rule folder under the downloads dir
+	stationID string
+	startTime time.Time
+}
+
+// parseOutputFileName returns the station ID and the start time from the output file name
+// ("<OutputDatetimeLayout>_<station ID>_<title>.<ext>")
+func parseOutputFileName(name string) (stationID string, startTime time.Time, ok bool) {
+	parts := strings.SplitN(strings.TrimSuffix(name, filepath.Ext(name)), "_", 3)
+	if len(parts) < 3 || parts[1] == "" {
+		return "", time.Time{}, false
+	}
+	startTime, err := time.ParseInLocation(OutputDatetimeLayout, parts[0], Location)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[1], startTime, true
+}
+
+// isAudioOutput returns true if the file has the extension of an output format
+func isAudioOutput(name string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	return ext == radigo.AudioFormatAAC || ext == radigo.AudioFormatMP3 || ext == AudioFormatM4A
+}
+
+// findBrokenOutputs returns the output files in the downloads dir smaller than the minimum size,
+// and the part files of the outputs left unfinished
+func findBrokenOutputs(downloadsDir string, minimumSize int64) ([]brokenOutput, error) {
+	var broken []brokenOutput
+	err := filepath.WalkDir(downloadsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == downloadsDir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		// a part file is an output left unfinished
+		part := strings.HasSuffix(d.Name(), PartFileExt)
+		if d.IsDir() || !isAudioOutput(strings.TrimSuffix(d.Name(), PartFileExt)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !part && info.Size() > 0 && info.Size() >= minimumSize {
+			return nil
+		}
+		b := brokenOutput{path: path}
+		if rel, err := filepath.Rel(downloadsDir, filepath.Dir(path)); err == nil && rel != "." {
+			b.folder = rel
+		}
+		b.stationID, b.startTime, _ = parseOutputFileName(d.Name())
+		broken = append(broken, b)
+		return nil
+	})
+	return broken, err
+}
+
+// RecoverDownloads scans the downloads dir at startup for the empty or too small files left by a crash,
+// removes or quarantines them as the asset's RecoveryScan, and queues their programs again
+// if still available on timefree. It returns the number of the files recovered.
+func RecoverDownloads(ctx context.Context, asset *Asset) (int, error) {
+	if asset == nil || asset.RecoveryScan == RecoveryScanOff || asset.ReadOnly {
+		return 0, nil
+	}
+	downloadsDir, err := getRadicronPath(asset.DownloadDir)
+	if err != nil {
+		return 0, err
+	}
+	broken, err := findBrokenOutputs(downloadsDir, asset.MinimumOutputSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan the downloads: %w", err)
+	}
+
+	recovered := 0
+	guides := map[string]Progs{}
+	for _, b := range broken {
+		if err := discardBrokenOutput(asset, downloadsDir, b.path); err != nil {
+			emitLogMessage(ctx, "error", fmt.Sprintf("failed to recover %s: %v", b.path, err))
+			continue
+		}
+		recovered++
+
+		if b.stationID == "" || isTimefreeExpired(&Prog{Ft: FormatDatetime(b.startTime)}, CurrentTime) {
+			continue
+		}
+		progs, ok := guides[b.stationID]
+		if !ok {
+			if progs, err = fetchWeeklyPrograms(ctx, b.stationID); err != nil {
+				emitLogMessage(ctx, "error", fmt.Sprintf("failed to fetch the programs of %s to re-queue: %v", b.stationID, err))
+			}
+			guides[b.stationID] = progs
+		}
+		if prog := findRecoveredProgram(asset, progs, b); prog != nil {
+			enqueueProgram(ctx, prog)
+			emitLogMessage(ctx, "info", fmt.Sprintf("re-queued [%s]%s (%s)", prog.StationID, prog.Title, prog.Ft))
+		}
+	}
+	return recovered, nil
+}
+
+// discardBrokenOutput removes the file, or moves it to the quarantine dir keeping its path under the downloads dir
+func discardBrokenOutput(asset *Asset, downloadsDir, path string) error {
+	if asset.RecoveryScan == RecoveryScanRemove {
+		return os.Remove(path)
+	}
+	rel, err := filepath.Rel(downloadsDir, path)
+	if err != nil {
+		return err
+	}
+	dest, err := getRadicronPath(filepath.Join(QuarantineDirName, rel))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), DirPermissions); err != nil {
+		return err
+	}
+	return moveFile(path, dest)
+}
+
+// findRecoveredProgram returns the program of the broken output in the guide with the rule matching it, or nil
+func findRecoveredProgram(asset *Asset, progs Progs, b brokenOutput) *Prog {
+	for _, p := range progs {
+		start, err := ParseDatetime(p.Ft)
+		// the file name has the start time in minutes
+		if err != nil || !start.Truncate(time.Minute).Equal(b.startTime) {
+			continue
+		}
+		if rule := asset.Rules.FindMatchSilent(b.stationID, p); rule != nil {
+			rule.ApplyTo(p)
+		} else {
+			p.RuleFolder = b.folder
+		}
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseOutputFileName(t *testing.T) {
+	stationID, startTime, ok := parseOutputFileName("2023-06-05-1300_FMT_Title_with_underscores.aac")
+	if !ok {
+		t.Fatal("expected the file name to be parsed")
+	}
+	if stationID != "FMT" {
+		t.Errorf("expected FMT, got %s", stationID)
+	}
+	if want := time.Date(2023, 6, 5, 13, 0, 0, 0, Location); !startTime.Equal(want) {
+		t.Errorf("expected %v, got %v", want, startTime)
+	}
+
+	for _, name := range []string{"notes.aac", "2023-06-05-1300.aac", "20230605_FMT_Title.aac", "2023-06-05-1300__Title.aac"} {
+		if _, _, ok := parseOutputFileName(name); ok {
+			t.Errorf("expected %s not to be parsed", name)
+		}
+	}
+}
+
+func TestRecoverDownloads(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+
+	origFetch := fetchWeeklyPrograms
+	fetchWeeklyPrograms = func(_ context.Context, stationID string) (Progs, error) {
+		if stationID != "FMT" {
+			return nil, errors.New("unexpected station")
+		}
+		return Progs{
+			{StationID: "FMT", Title: "Other", Ft: "20230605120000", To: "20230605130000"},
+			{StationID: "FMT", Title: "Test", Ft: "20230605130000", To: "20230605140000"},
+		}, nil
+	}
+	t.Cleanup(func() { fetchWeeklyPrograms = origFetch })
+
+	downloads := filepath.Join(home, "downloads")
+	files := map[string]int{
+		"music/2023-06-05-1300_FMT_Test.aac": 0,    // empty, still on timefree
+		"2023-05-01-1300_TBS_Old.mp3":        10,   // too small, expired
+		"2023-06-05-1200_FMT_Fine.aac":       2048, // large enough
+		"cover.jpg":                          0,    // not an output
+	}
+	for name, size := range files {
+		path := filepath.Join(downloads, name)
+		if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset := &Asset{
+		DownloadDir:       "downloads",
+		MinimumOutputSize: 1024,
+		RecoveryScan:      RecoveryScanQuarantine,
+		Rules:             Rules{{Name: "test", StationID: "FMT", Title: "Test", Folder: "music"}},
+	}
+	recovered, err := RecoverDownloads(context.Background(), asset)
+	if err != nil {
+		t.Fatalf("RecoverDownloads failed: %v", err)
+	}
+	if recovered != 2 {
+		t.Errorf("expected 2 recovered files, got %d", recovered)
+	}
+
+	for _, name := range []string{"music/2023-06-05-1300_FMT_Test.aac", "2023-05-01-1300_TBS_Old.mp3"} {
+		if _, err := os.Stat(filepath.Join(downloads, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be moved", name)
+		}
+		if _, err := os.Stat(filepath.Join(home, QuarantineDirName, name)); err != nil {
+			t.Errorf("expected %s in the quarantine: %v", name, err)
+		}
+	}
+	for _, name := range []string{"2023-06-05-1200_FMT_Fine.aac", "cover.jpg"} {
+		if _, err := os.Stat(filepath.Join(downloads, name)); err != nil {
+			t.Errorf("expected %s to be kept: %v", name, err)
+		}
+	}
+
+	queued, err := LoadQueue()
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	if len(queued) != 1 || queued[0].Title != "Test" || queued[0].RuleName != "test" || queued[0].RuleFolder != "music" {
+		t.Errorf("expected the recovered program to be re-queued with its rule, got %+v", queued)
+	}
+}
+
+func TestRecoverDownloads_Remove(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	path := filepath.Join(home, "downloads", "2023-05-01-1300_TBS_Old.aac")
+	if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	asset := &Asset{DownloadDir: "downloads", RecoveryScan: RecoveryScanOff}
+	if recovered, err := RecoverDownloads(context.Background(), asset); err != nil || recovered != 0 {
+		t.Errorf("expected the scan to be off, got %d, %v", recovered, err)
+	}
+
+	asset.RecoveryScan = RecoveryScanRemove
+	recovered, err := RecoverDownloads(context.Background(), asset)
+	if err != nil || recovered != 1 {
+		t.Fatalf("expected 1 recovered file, got %d, %v", recovered, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the empty file to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(home, QuarantineDirName)); !os.IsNotExist(err) {
+		t.Error("expected no quarantine dir")
+	}
+
+	// no downloads dir yet
+	asset.DownloadDir = "missing"
+	if _, err := RecoverDownloads(context.Background(), asset); err != nil {
+		t.Errorf("expected no error without the downloads dir, got %v", err)
+	}
+}
+
+func TestFindBrokenOutputs_PartFile(t *testing.T) {
+	downloads := t.TempDir()
+	files := map[string]int{
+		"2023-06-05-1300_FMT_Test.mp3.part": 4096, // unfinished however large
+		"2023-06-05-1300_FMT_Test.mp3":      4096,
+		"notes.txt.part":                    0, // not an output
+	}
+	for name, size := range files {
+		if err := os.WriteFile(filepath.Join(downloads, name), make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broken, err := findBrokenOutputs(downloads, 1024)
+	if err != nil {
+		t.Fatalf("findBrokenOutputs failed: %v", err)
+	}
+	if len(broken) != 1 || filepath.Base(broken[0].path) != "2023-06-05-1300_FMT_Test.mp3.part" {
+		t.Fatalf("expected only the part file, got %+v", broken)
+	}
+	if broken[0].stationID != "FMT" || broken[0].startTime.IsZero() {
+		t.Errorf("expected the program of the part file, got %+v", broken[0])
+	}
+}
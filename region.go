@@ -3,7 +3,7 @@ package radikron
 import (
 	"encoding/xml"
 	"io"
-	"net/http"
+	"strings"
 )
 
 type XMLRegion struct {
@@ -17,16 +17,39 @@ type XMLRegionStations struct {
 }
 
 type XMLRegionStation struct {
-	ID     string `xml:"id"`
-	Name   string `xml:"name"`
-	AreaID string `xml:"area_id"`
-	Ruby   string `xml:"ruby"`
+	ID        string           `xml:"id"`
+	Name      string           `xml:"name"`
+	ASCIIName string           `xml:"ascii_name"`
+	AreaID    string           `xml:"area_id"`
+	Ruby      string           `xml:"ruby"`
+	AreaFree  int              `xml:"areafree"`
+	TimeFree  int              `xml:"timefree"`
+	Logos     []XMLStationLogo `xml:"logo"`
+	Banner    string           `xml:"banner"`
+	Href      string           `xml:"href"`
+}
+
+type XMLStationLogo struct {
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+	URL    string `xml:",chardata"`
+}
+
+// LogoURL returns the URL of the largest logo of the station, or "" if none
+func (s *XMLRegionStation) LogoURL() string {
+	url, size := "", 0
+	for _, l := range s.Logos {
+		if l.URL != "" && (url == "" || l.Width*l.Height > size) {
+			url, size = strings.TrimSpace(l.URL), l.Width*l.Height
+		}
+	}
+	return url
 }
 
 func FetchXMLRegion() (XMLRegion, error) {
 	region := XMLRegion{}
 
-	resp, err := http.Get(APIRegionFull) //nolint:noctx
+	resp, err := httpClient.Get(APIRegionFull) //nolint:noctx
 	if err != nil {
 		return region, err
 	}
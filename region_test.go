@@ -1,6 +1,7 @@
 package radikron
 
 import (
+	"encoding/xml"
 	"testing"
 )
 
@@ -26,3 +27,38 @@ func TestFetchXMLRegion(t *testing.T) {
 		t.Errorf("failed to fetch all the stations (%v instead of %v)", stationCount, nStations)
 	}
 }
+
+func TestXMLRegionStationMetadata(t *testing.T) {
+	blob := `<region>
+<stations region_id="kanto" region_name="関東">
+<station>
+<id>TBS</id>
+<name>TBSラジオ</name>
+<ascii_name>TBS RADIO</ascii_name>
+<ruby>てぃーびーえすらじお</ruby>
+<areafree>1</areafree>
+<timefree>1</timefree>
+<logo logo_type="application_logo" width="224" height="100">https://radiko.jp/v2/static/station/logo/TBS/224x100.png</logo>
+<logo logo_type="application_logo" width="448" height="200">https://radiko.jp/v2/static/station/logo/TBS/448x200.png</logo>
+<logo logo_type="application_logo" width="258" height="60">https://radiko.jp/v2/static/station/logo/TBS/258x60.png</logo>
+<banner>https://radiko.jp/res/banner/TBS/20230101.png</banner>
+<href>https://www.tbsradio.jp/</href>
+<area_id>JP13</area_id>
+</station>
+</stations>
+</region>`
+	region := XMLRegion{}
+	if err := xml.Unmarshal([]byte(blob), &region); err != nil {
+		t.Fatalf("failed to parse the region: %v", err)
+	}
+	station := region.Region[0].Stations[0]
+	if station.ASCIIName != "TBS RADIO" || station.AreaFree != 1 || station.TimeFree != 1 {
+		t.Errorf("unexpected station metadata: %+v", station)
+	}
+	if got := station.LogoURL(); got != "https://radiko.jp/v2/static/station/logo/TBS/448x200.png" {
+		t.Errorf("expected the largest logo, got %s", got)
+	}
+	if got := (&XMLRegionStation{}).LogoURL(); got != "" {
+		t.Errorf("expected no logo, got %s", got)
+	}
+}
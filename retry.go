@@ -0,0 +1,142 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// defaultRetryPolicy returns the RetryPolicy of the assets without one, replaced by the tests to retry without delay
+var defaultRetryPolicy = DefaultRetryPolicy
+
+// RetryPolicy defines how failed requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one
+	MaxAttempts int
+	// InitialDelay before the first retry
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between retries
+	MaxDelay time.Duration
+	// Multiplier for the exponential backoff
+	Multiplier float64
+	// Jitter randomizes the delay by up to this fraction (0.0 - 1.0)
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the default RetryPolicy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  MaxRetryAttempts,
+		InitialDelay: DefaultRetryInitialDelay,
+		MaxDelay:     DefaultRetryMaxDelay,
+		Multiplier:   DefaultRetryMultiplier,
+		Jitter:       DefaultRetryJitter,
+	}
+}
+
+// retryPolicy returns the asset's RetryPolicy, or DefaultRetryPolicy if the asset has none
+func (a *Asset) retryPolicy() RetryPolicy {
+	if a == nil || a.Retry == (RetryPolicy{}) {
+		return defaultRetryPolicy()
+	}
+	p := a.Retry
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = MaxRetryAttempts
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1
+	}
+	p.Jitter = math.Max(0, math.Min(1, p.Jitter))
+	return p
+}
+
+// currentRetryPolicy returns the RetryPolicy of the asset in ctx
+func currentRetryPolicy(ctx context.Context) RetryPolicy {
+	return GetAsset(ctx).retryPolicy()
+}
+
+// permanentError wraps an error that retrying never recovers from, e.g., a 404 or an invalid playlist
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent marks err as not retryable, so that RetryPolicy.Do gives up on it right away
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// isPermanent returns true if err is marked as not retryable
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// checkResponseStatus returns an error for a response other than 2xx,
+// not retryable for the 4xx responses but 429 Too Many Requests
+func checkResponseStatus(resp *http.Response) error {
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return permanent(fmt.Errorf("unexpected status: %s", resp.Status))
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
+
+// Delay returns the delay before the given retry (1 for the first retry)
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	if retry <= 0 || p.InitialDelay <= 0 {
+		return 0
+	}
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(retry-1))
+	if p.MaxDelay > 0 {
+		delay = math.Min(delay, float64(p.MaxDelay))
+	}
+	if p.Jitter > 0 {
+		// +/- Jitter * delay
+		delay += delay * p.Jitter * (2*rand.Float64() - 1) //nolint:gosec
+	}
+	return time.Duration(delay)
+}
+
+// Do calls fn until it succeeds, the attempts are exhausted, fn returns errAuthExpired or a permanent error, or ctx is done.
+// It returns the last error from fn, or the context error if ctx is done while waiting.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(p.Delay(i))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		// retrying with the expired auth token or a permanent error never succeeds
+		if errors.Is(err, errAuthExpired) || isPermanent(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// retry without delay so that the tests on failing requests run fast
+	defaultRetryPolicy = func() RetryPolicy { return RetryPolicy{MaxAttempts: MaxRetryAttempts} }
+	os.Exit(m.Run())
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: time.Second,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   2,
+	}
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.retry); got != tt.want {
+			t.Errorf("Delay(%d) => %v, want %v", tt.retry, got, tt.want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := p.Delay(1)
+		if got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("Delay(1) with jitter => %v, want between 500ms and 1.5s", got)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3}
+
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("Do => %v with %d calls, want nil with 2 calls", err, calls)
+	}
+
+	calls = 0
+	errFail := errors.New("fail")
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return errFail
+	})
+	if !errors.Is(err, errFail) || calls != 3 {
+		t.Errorf("Do => %v with %d calls, want %v with 3 calls", err, calls, errFail)
+	}
+}
+
+func TestRetryPolicyDo_Permanent(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3}
+
+	calls := 0
+	errFail := errors.New("fail")
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return permanent(errFail)
+	})
+	if !errors.Is(err, errFail) || calls != 1 {
+		t.Errorf("Do => %v with %d calls, want %v with 1 call", err, calls, errFail)
+	}
+}
+
+func TestCheckResponseStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantErr   bool
+		permanent bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusNotFound, true, true},
+		{http.StatusBadRequest, true, true},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusServiceUnavailable, true, false},
+	}
+	for _, tt := range tests {
+		err := checkResponseStatus(&http.Response{StatusCode: tt.status, Status: http.StatusText(tt.status)})
+		if (err != nil) != tt.wantErr || isPermanent(err) != tt.permanent {
+			t.Errorf("checkResponseStatus(%d) => %v, want error %v and permanent %v", tt.status, err, tt.wantErr, tt.permanent)
+		}
+	}
+}
+
+func TestGetChunklistFromM3U8_NotFound(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		http.NotFound(w, nil)
+	}))
+	defer srv.Close()
+
+	if _, err := getChunklistFromM3U8(context.Background(), srv.URL); err == nil {
+		t.Error("expected an error for 404")
+	}
+	if calls != 1 {
+		t.Errorf("expected 404 not to be retried, got %d requests", calls)
+	}
+}
+
+func TestRetryPolicyDo_ContextCanceled(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		cancel()
+		return errors.New("fail")
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Do => %v with %d calls, want %v with 1 call", err, calls, context.Canceled)
+	}
+}
+
+func TestAssetRetryPolicy(t *testing.T) {
+	asset := &Asset{Retry: RetryPolicy{MaxAttempts: 0, Multiplier: 0.5, Jitter: 2}}
+	got := currentRetryPolicy(context.WithValue(context.Background(), ContextKey("asset"), asset))
+	if got.MaxAttempts != MaxRetryAttempts {
+		t.Errorf("MaxAttempts => %v, want %v", got.MaxAttempts, MaxRetryAttempts)
+	}
+	if got.Multiplier != 1 {
+		t.Errorf("Multiplier => %v, want 1", got.Multiplier)
+	}
+	if got.Jitter != 1 {
+		t.Errorf("Jitter => %v, want 1", got.Jitter)
+	}
+	if got := currentRetryPolicy(context.Background()); got != defaultRetryPolicy() {
+		t.Errorf("without an asset => %+v, want the default", got)
+	}
+}
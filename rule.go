@@ -1,11 +1,45 @@
 package radikron
 
 import (
+	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// weekdays maps the dow values of the rules to the days of the week
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// GenreAliases maps the English genre names to the radiko genre names
+var GenreAliases = map[string]string{
+	"anime":   "アニメ",
+	"drama":   "ドラマ",
+	"music":   "音楽",
+	"news":    "ニュース",
+	"sports":  "スポーツ",
+	"talk":    "トーク",
+	"variety": "バラエティ",
+}
+
+// The modes of a rule to record its programs in
+const (
+	// RuleModeAuto records the programs live on the stations without timefree, and from timefree otherwise
+	RuleModeAuto = "auto"
+	// RuleModeTimefree downloads the programs from timefree after they end
+	RuleModeTimefree = "timefree"
+	// RuleModeLive records the programs from the live stream on air
+	RuleModeLive = "live"
+)
+
 type Rules []*Rule
 
 func (rs Rules) HasMatch(stationID string, p *Prog) bool {
@@ -56,6 +90,51 @@ func (rs Rules) HasRuleForStationID(stationID string) bool {
 	return false
 }
 
+// GuideStations returns the stations whose guides the rules need to match the programs starting until the time,
+// in the order of stations, skipping the rules whose window and dow leave no day to match before it
+func (rs Rules) GuideStations(stations []string, until time.Time) []string {
+	needed := map[string]bool{}
+	all := false
+	for _, r := range rs {
+		if !r.CanMatchUntil(until) {
+			continue
+		}
+		if !r.HasStationID() {
+			all = true
+			break
+		}
+		needed[r.StationID] = true
+	}
+	result := []string{}
+	for _, s := range stations {
+		if all || needed[s] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// CanMatchUntil returns false if no program starting until the time is on a day of the dow
+// and in the window counted back from CurrentTime, i.e., the rule cannot match any program until then
+func (r *Rule) CanMatchUntil(until time.Time) bool {
+	if !r.HasDoW() || !r.HasWindow() {
+		// the past week in the guide has every day of the week
+		return true
+	}
+	window, err := ParseWindow(r.Window)
+	if err != nil {
+		return true
+	}
+	from := CurrentTime.Add(-window).In(Location)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, Location)
+	for ; !day.After(until); day = day.AddDate(0, 0, 1) {
+		if r.MatchDoW(day.Format(DatetimeLayout)) {
+			return true
+		}
+	}
+	return false
+}
+
 type Rule struct {
 	Name      string   `mapstructure:"name"`       // required
 	Title     string   `mapstructure:"title"`      // required if pfm and keyword are unset
@@ -65,13 +144,27 @@ type Rule struct {
 	StationID string   `mapstructure:"station-id"` // optional
 	Window    string   `mapstructure:"window"`     // optional
 	Folder    string   `mapstructure:"folder"`     // optional
+	Genre     []string `mapstructure:"genre"`      // optional
+	AreaFree  bool     `mapstructure:"areafree"`   // optional, requires the premium session
+	Mode      string   `mapstructure:"mode"`       // optional, RuleModeAuto by default
+	// FolderByTag routes the programs with a tag to its folder instead of Folder, optional
+	FolderByTag map[string]string `mapstructure:"folder-by-tag"`
+	// FilenameCharset normalizes the file names of the programs, e.g., FilenameCharsetASCII, optional
+	FilenameCharset string `mapstructure:"filename-charset"`
+	// Keep is how long the prune command keeps the recordings in the folders of the rule,
+	// a duration like 2160h, days like 90d, or KeepForever, optional
+	Keep string `mapstructure:"keep"`
+
+	// titleAliases are the renamed titles the rule matches alike, set by SetTitleAliases
+	titleAliases TitleAliases
 }
 
 // Match returns true if the rule matches the program
 // 1. check the Window filter
 // 2. check the DoW filter
 // 3. check the StationID
-// 4. match the criteria
+// 4. check the Genre filter
+// 5. match the criteria
 func (r *Rule) Match(stationID string, p *Prog) bool {
 	return r.match(stationID, p, false)
 }
@@ -96,17 +189,101 @@ func (r *Rule) match(stationID string, p *Prog, suppressLogs bool) bool {
 		return false
 	}
 
-	// 4. match
+	// 4. check genre
+	if !r.MatchGenre(p.Genres) {
+		return false
+	}
+
+	// 5. match
 	if r.matchPfm(p.Pfm, suppressLogs) && r.matchTitle(p.Title, suppressLogs) && r.matchKeyword(p, suppressLogs) {
 		return true
 	}
 	return false
 }
 
+// ParseWindow parses the window of a rule, a duration like 48h or a number of days like 7d
+func ParseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+		return time.Duration(n) * OneDay * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window %q", s)
+	}
+	return d, nil
+}
+
+// Validate returns an error if the rule has no criteria, i.e., matches every program,
+// or has a dow or a window which never works
+func (r *Rule) Validate() error {
+	if !r.HasTitle() && !r.HasKeyword() && !r.HasPfm() && !r.HasStationID() &&
+		!r.HasGenre() && !r.HasDoW() && !r.HasWindow() {
+		return fmt.Errorf("rule[%s] has no criteria and would match every program", r.Name)
+	}
+	for _, d := range r.DoW {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("rule[%s] has an invalid dow %q, expected one of sun, mon, tue, wed, thu, fri, or sat", r.Name, d)
+		}
+	}
+	if r.HasWindow() {
+		window, err := ParseWindow(r.Window)
+		if err != nil {
+			return fmt.Errorf("rule[%s] has an %w, expected a duration like 48h or days like 7d", r.Name, err)
+		}
+		if window <= 0 {
+			return fmt.Errorf("rule[%s] has a window %q which is not positive", r.Name, r.Window)
+		}
+	}
+	switch r.Mode {
+	case "", RuleModeAuto, RuleModeTimefree, RuleModeLive:
+	default:
+		return fmt.Errorf("rule[%s] has an invalid mode %q, expected timefree, live, or auto", r.Name, r.Mode)
+	}
+	if !ValidFilenameCharset(r.FilenameCharset) {
+		return fmt.Errorf("rule[%s] has an invalid filename-charset %q, expected ascii or no-emoji", r.Name, r.FilenameCharset)
+	}
+	if r.Keep != "" {
+		if _, err := r.KeepDuration(); err != nil {
+			return fmt.Errorf("rule[%s] has an invalid keep %q, expected a duration like 2160h, days like 90d, or forever", r.Name, r.Keep)
+		}
+		if r.Folder == "" && len(r.FolderByTag) == 0 {
+			return fmt.Errorf("rule[%s] has a keep but no folder or folder-by-tag to keep the recordings in", r.Name)
+		}
+	}
+	return nil
+}
+
+// KeepDuration returns how long to keep the recordings of the rule, KeepForeverDuration for KeepForever,
+// or 0 without a keep
+func (r *Rule) KeepDuration() (time.Duration, error) {
+	switch r.Keep {
+	case "":
+		return 0, nil
+	case KeepForever:
+		return KeepForeverDuration, nil
+	}
+	d, err := ParseWindow(r.Keep)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("keep %q is not positive", r.Keep)
+	}
+	return d, nil
+}
+
 func (r *Rule) HasDoW() bool {
 	return len(r.DoW) > 0
 }
 
+func (r *Rule) HasGenre() bool {
+	return len(r.Genre) > 0
+}
+
 func (r *Rule) HasPfm() bool {
 	return r.Pfm != ""
 }
@@ -135,24 +312,36 @@ func (r *Rule) MatchDoW(ft string) bool {
 	if !r.HasDoW() {
 		return true
 	}
-	dow := map[string]time.Weekday{
-		"sun": time.Sunday,
-		"mon": time.Monday,
-		"tue": time.Tuesday,
-		"wed": time.Wednesday,
-		"thu": time.Thursday,
-		"fri": time.Friday,
-		"sat": time.Saturday,
-	}
-	st, _ := time.ParseInLocation(DatetimeLayout, ft, Location)
+	st, _ := ParseDatetime(ft)
 	for _, d := range r.DoW {
-		if st.Weekday() == dow[strings.ToLower(d)] {
+		if wd, ok := weekdays[strings.ToLower(d)]; ok && st.Weekday() == wd {
 			return true
 		}
 	}
 	return false
 }
 
+// MatchGenre returns true if any of the genres matches the rule's genre filter.
+// A filter value matches a genre ID (e.g., "P007"), a part of the genre name (e.g., "アニメ"),
+// or an English alias in GenreAliases (e.g., "music").
+func (r *Rule) MatchGenre(genres []Genre) bool {
+	if !r.HasGenre() {
+		return true
+	}
+	for _, rg := range r.Genre {
+		name := rg
+		if alias, ok := GenreAliases[strings.ToLower(rg)]; ok {
+			name = alias
+		}
+		for _, g := range genres {
+			if strings.EqualFold(g.ID, rg) || (g.Name != "" && strings.Contains(g.Name, name)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (r *Rule) MatchKeyword(p *Prog) bool {
 	return r.matchKeyword(p, false)
 }
@@ -232,9 +421,18 @@ func (r *Rule) matchTitle(title string, suppressLogs bool) bool {
 	if !r.HasTitle() {
 		return true // if no title, match all
 	}
-	if strings.Contains(title, r.Title) {
+	// the old and new titles of a renamed program match the same rule
+	for _, t := range r.titleAliases.variants(r.Title) {
+		if strings.Contains(title, t) {
+			if !suppressLogs {
+				log.Printf("rule[%s] matched with title: '%s'", r.Name, title)
+			}
+			return true
+		}
+	}
+	if t := r.titleAliases.Canonical(title); t != title && strings.Contains(t, r.Title) {
 		if !suppressLogs {
-			log.Printf("rule[%s] matched with title: '%s'", r.Name, title)
+			log.Printf("rule[%s] matched with title: '%s' (renamed to '%s')", r.Name, title, t)
 		}
 		return true
 	}
@@ -245,12 +443,12 @@ func (r *Rule) MatchWindow(ft string) bool {
 	if !r.HasWindow() {
 		return true
 	}
-	startTime, err := time.ParseInLocation(DatetimeLayout, ft, Location)
+	startTime, err := ParseDatetime(ft)
 	if err != nil {
 		log.Printf("invalid start time format '%s': %s", ft, err)
 		return false
 	}
-	fetchWindow, err := time.ParseDuration(r.Window)
+	fetchWindow, err := ParseWindow(r.Window)
 	if err != nil {
 		log.Printf("parsing [%s].window failed: %v (using 24h)", r.Name, err)
 		fetchWindow = time.Hour * OneDay
@@ -262,6 +460,29 @@ func (r *Rule) MatchWindow(ft string) bool {
 	return true
 }
 
+// FolderFor returns the folder to save the program matched by the rule:
+// the folder of the first program tag in FolderByTag, or Folder if none
+func (r *Rule) FolderFor(p *Prog) string {
+	for _, tag := range p.Tags {
+		for t, folder := range r.FolderByTag {
+			// the config keys are lowercased
+			if strings.EqualFold(t, tag) {
+				return folder
+			}
+		}
+	}
+	return r.Folder
+}
+
+// ApplyTo sets the rule's settings on the program it matched, for the download to follow
+func (r *Rule) ApplyTo(p *Prog) {
+	p.RuleName = r.Name
+	p.RuleFolder = r.FolderFor(p)
+	p.AreaFree = r.AreaFree
+	p.Mode = r.Mode
+	p.FilenameCharset = r.FilenameCharset
+}
+
 func (r *Rule) SetName(name string) {
 	r.Name = name
 }
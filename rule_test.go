@@ -1,6 +1,8 @@
 package radikron
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -12,62 +14,43 @@ var matchtests = []struct {
 	out       bool
 }{
 	{
-		&Rule{"matchtests", "Title", []string{}, "Keyword", "Pfm", "FMT", "", ""},
+		&Rule{Name: "matchtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
 		"FMT",
 		&Prog{
-			"ID",
-			"FMT",
-			"20230625050000",
-			"20230625060000",
-			"Title",
-			"Keyword",
-			"",
-			"Pfm",
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "FMT",
+			Ft:        "20230625050000",
+			To:        "20230625060000",
+			Title:     "Title",
+			Desc:      "Keyword",
+			Pfm:       "Pfm",
 		},
 		true,
 	},
 	{
-		&Rule{"matchtests", "RadioProgram", []string{}, "Keyword", "Pfm", "FMT", "", ""},
+		&Rule{Name: "matchtests", Title: "RadioProgram", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
 		"FMT",
 		&Prog{
-			"ID",
-			"FMT",
-			"20230625050000",
-			"20230625060000",
-			"Title", // title doesn't match
-			"Keyword",
-			"",
-			"Pfm",
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "FMT",
+			Ft:        "20230625050000",
+			To:        "20230625060000",
+			Title:     "Title", // title doesn't match
+			Desc:      "Keyword",
+			Pfm:       "Pfm",
 		},
 		false,
 	},
 	{
-		&Rule{"matchtests", "RadioProgram", []string{}, "", "Someone", "FMT", "", ""},
+		&Rule{Name: "matchtests", Title: "RadioProgram", Pfm: "Someone", StationID: "FMT"},
 		"FMT",
 		&Prog{
-			"ID",
-			"FMT",
-			"20230625050000",
-			"20230625060000",
-			"RadioProgram",
-			"",
-			"",
-			"Pfm", // Pfm doesn't match
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "FMT",
+			Ft:        "20230625050000",
+			To:        "20230625060000",
+			Title:     "RadioProgram",
+			Pfm:       "Pfm", // Pfm doesn't match
 		},
 		false,
 	},
@@ -85,49 +68,37 @@ func TestMatch(t *testing.T) {
 	}
 
 	// Test Match with window exclusion
-	r := &Rule{"matchtests", "Title", []string{}, "Keyword", "Pfm", "FMT", "1h", ""}
+	r := &Rule{Name: "matchtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "1h"}
 	p := &Prog{
-		"ID",
-		"FMT",
-		time.Now().Add(-2 * time.Hour).Format("20060102150405"),
-		"20230625060000",
-		"Title",
-		"Keyword",
-		"",
-		"Pfm",
-		[]string{},
-		ProgGenre{},
-		"",
-		"",
-		"",
+		ID:        "ID",
+		StationID: "FMT",
+		Ft:        time.Now().Add(-2 * time.Hour).Format("20060102150405"),
+		To:        "20230625060000",
+		Title:     "Title",
+		Desc:      "Keyword",
+		Pfm:       "Pfm",
 	}
 	if r.Match("FMT", p) {
 		t.Error("Match should return false when window excludes the program")
 	}
 
 	// Test Match with DoW exclusion
-	r2 := &Rule{"matchtests", "Title", []string{"mon"}, "Keyword", "Pfm", "FMT", "", ""}
+	r2 := &Rule{Name: "matchtests", Title: "Title", DoW: []string{"mon"}, Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"}
 	p2 := &Prog{
-		"ID",
-		"FMT",
-		"20230625050000", // Sunday
-		"20230625060000",
-		"Title",
-		"Keyword",
-		"",
-		"Pfm",
-		[]string{},
-		ProgGenre{},
-		"",
-		"",
-		"",
+		ID:        "ID",
+		StationID: "FMT",
+		Ft:        "20230625050000", // Sunday
+		To:        "20230625060000",
+		Title:     "Title",
+		Desc:      "Keyword",
+		Pfm:       "Pfm",
 	}
 	if r2.Match("FMT", p2) {
 		t.Error("Match should return false when DoW doesn't match")
 	}
 
 	// Test Match with station ID exclusion
-	r3 := &Rule{"matchtests", "Title", []string{}, "Keyword", "Pfm", "TBS", "", ""}
+	r3 := &Rule{Name: "matchtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "TBS"}
 	if r3.Match("FMT", p2) {
 		t.Error("Match should return false when station ID doesn't match")
 	}
@@ -139,17 +110,17 @@ var dowtests = []struct {
 	out bool
 }{
 	{
-		&Rule{"dowtests", "Title", []string{}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "dowtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		"20230625050000", // sun
 		true,
 	},
 	{
-		&Rule{"dowtests", "Title", []string{"sun"}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "dowtests", Title: "Title", DoW: []string{"sun"}, Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		"20230625050000", // sun
 		true,
 	},
 	{
-		&Rule{"dowtests", "Title", []string{"mon", "tue"}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "dowtests", Title: "Title", DoW: []string{"mon", "tue"}, Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		"20230625050000", // sun
 		false,
 	},
@@ -170,135 +141,102 @@ var keywordtests = []struct {
 	out  bool
 }{
 	{
-		&Rule{"keywordtests", "Title", []string{}, "", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "keywordtests", Title: "Title", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		&Prog{
-			"ID",
-			"StationID",
-			"Ft",
-			"To",
-			"Title",
-			"Desc",
-			"Info",
-			"Pfm",
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "StationID",
+			Ft:        "Ft",
+			To:        "To",
+			Title:     "Title",
+			Desc:      "Desc",
+			Info:      "Info",
+			Pfm:       "Pfm",
 		},
 		true,
 	},
 	{
-		&Rule{"keywordtests", "Title", []string{}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "keywordtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		&Prog{
-			"ID",
-			"StationID",
-			"Ft",
-			"To",
-			"Keyword", // match
-			"Desc",
-			"Info",
-			"Pfm",
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "StationID",
+			Ft:        "Ft",
+			To:        "To",
+			Title:     "Keyword", // match
+			Desc:      "Desc",
+			Info:      "Info",
+			Pfm:       "Pfm",
 		},
 		true,
 	},
 	{
-		&Rule{"keywordtests", "Title", []string{}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "keywordtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		&Prog{
-			"ID",
-			"StationID",
-			"Ft",
-			"To",
-			"Title",
-			"Keyword", // match
-			"Info",
-			"Pfm",
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "StationID",
+			Ft:        "Ft",
+			To:        "To",
+			Title:     "Title",
+			Desc:      "Keyword", // match
+			Info:      "Info",
+			Pfm:       "Pfm",
 		},
 		true,
 	},
 	{
-		&Rule{"keywordtests", "Title", []string{}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "keywordtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		&Prog{
-			"ID",
-			"StationID",
-			"Ft",
-			"To",
-			"Title",
-			"Desc",
-			"Keyword", // match
-			"Pfm",
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "StationID",
+			Ft:        "Ft",
+			To:        "To",
+			Title:     "Title",
+			Desc:      "Desc",
+			Info:      "Keyword", // match
+			Pfm:       "Pfm",
 		},
 		true,
 	},
 	{
-		&Rule{"keywordtests", "Title", []string{}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "keywordtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		&Prog{
-			"test",
-			"test",
-			"test",
-			"test",
-			"test",
-			"test",
-			"test",
-			"Keyword", // match
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "test",
+			StationID: "test",
+			Ft:        "test",
+			To:        "test",
+			Title:     "test",
+			Desc:      "test",
+			Info:      "test",
+			Pfm:       "Keyword", // match
 		},
 		true,
 	},
 	{
-		&Rule{"keywordtests", "Title", []string{}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "keywordtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		&Prog{
-			"test",
-			"test",
-			"test",
-			"test",
-			"test",
-			"test",
-			"test",
-			"test",
-			[]string{"Keyword"}, // match
-			ProgGenre{},
-			"test",
-			"",
-			"",
+			ID:        "test",
+			StationID: "test",
+			Ft:        "test",
+			To:        "test",
+			Title:     "test",
+			Desc:      "test",
+			Info:      "test",
+			Pfm:       "test",
+			Tags:      []string{"Keyword"}, // match
+			M3U8:      "test",
 		},
 		true,
 	},
 	{
-		&Rule{"keywordtests", "Title", []string{}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "keywordtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		&Prog{
-			"ID",
-			"StationID",
-			"Ft",
-			"To",
-			"Title",
-			"Desc",
-			"Info",
-			"Pfm",
-			[]string{},
-			ProgGenre{},
-			"",
-			"",
-			"",
+			ID:        "ID",
+			StationID: "StationID",
+			Ft:        "Ft",
+			To:        "To",
+			Title:     "Title",
+			Desc:      "Desc",
+			Info:      "Info",
+			Pfm:       "Pfm",
 		},
 		false,
 	},
@@ -319,17 +257,17 @@ var pfmtests = []struct {
 	out bool
 }{
 	{
-		&Rule{"pfmtests", "Title", []string{"sun"}, "Keyword", "", "StationID", "Window", ""},
+		&Rule{Name: "pfmtests", Title: "Title", DoW: []string{"sun"}, Keyword: "Keyword", StationID: "StationID", Window: "Window"},
 		"Pfm",
 		true,
 	},
 	{
-		&Rule{"pfmtests", "", []string{}, "", "Pfm", "", "", ""},
+		&Rule{Name: "pfmtests", Pfm: "Pfm"},
 		"Pfm",
 		true,
 	},
 	{
-		&Rule{"pfmtests", "", []string{}, "", "Pfm", "", "", ""},
+		&Rule{Name: "pfmtests", Pfm: "Pfm"},
 		"Someone",
 		false,
 	},
@@ -350,17 +288,17 @@ var stationtests = []struct {
 	out       bool
 }{
 	{
-		&Rule{"stationtests", "Title", []string{"sun"}, "Keyword", "Pfm", "FMT", "Window", ""},
+		&Rule{Name: "stationtests", Title: "Title", DoW: []string{"sun"}, Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "Window"},
 		"FMT",
 		true,
 	},
 	{
-		&Rule{"stationtests", "", []string{}, "", "", "", "", ""},
+		&Rule{Name: "stationtests"},
 		"FMT",
 		true,
 	},
 	{
-		&Rule{"stationtests", "", []string{}, "", "", "FMT", "", ""},
+		&Rule{Name: "stationtests", StationID: "FMT"},
 		"TBS",
 		false,
 	},
@@ -375,23 +313,69 @@ func TestMatchStationID(t *testing.T) {
 	}
 }
 
+var genretests = []struct {
+	in     *Rule
+	genres []Genre
+	out    bool
+}{
+	{
+		&Rule{Name: "genretests"},
+		[]Genre{{ID: "P007", Name: "トーク"}},
+		true,
+	},
+	{
+		&Rule{Name: "genretests", Genre: []string{"p007"}},
+		[]Genre{{ID: "P007", Name: "トーク"}},
+		true,
+	},
+	{
+		&Rule{Name: "genretests", Genre: []string{"アニメ"}},
+		[]Genre{{ID: "C003", Name: "アニメ・声優"}},
+		true,
+	},
+	{
+		&Rule{Name: "genretests", Genre: []string{"Music"}},
+		[]Genre{{ID: "P001", Name: "音楽"}},
+		true,
+	},
+	{
+		&Rule{Name: "genretests", Genre: []string{"music", "news"}},
+		[]Genre{{ID: "P007", Name: "トーク"}, {ID: "C010", Name: "タレント"}},
+		false,
+	},
+	{
+		&Rule{Name: "genretests", Genre: []string{"music"}},
+		[]Genre{},
+		false,
+	},
+}
+
+func TestMatchGenre(t *testing.T) {
+	for _, tt := range genretests {
+		got := tt.in.MatchGenre(tt.genres)
+		if got != tt.out {
+			t.Errorf("(%v).MatchGenre(%v) => %v, want %v", tt.in, tt.genres, got, tt.out)
+		}
+	}
+}
+
 var titletests = []struct {
 	in    *Rule
 	title string
 	out   bool
 }{
 	{
-		&Rule{"titletests", "Title", []string{"sun"}, "Keyword", "Pfm", "FMT", "Window", ""},
+		&Rule{Name: "titletests", Title: "Title", DoW: []string{"sun"}, Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "Window"},
 		"Title",
 		true,
 	},
 	{
-		&Rule{"titletests", "", []string{}, "", "", "", "", ""},
+		&Rule{Name: "titletests"},
 		"Title",
 		true,
 	},
 	{
-		&Rule{"titletests", "Title", []string{}, "", "", "FMT", "", ""},
+		&Rule{Name: "titletests", Title: "Title", StationID: "FMT"},
 		"Radio",
 		false,
 	},
@@ -412,17 +396,17 @@ var windowtests = []struct {
 	out bool
 }{
 	{
-		&Rule{"windowtests", "Title", []string{"sun"}, "Keyword", "Pfm", "FMT", "", ""},
+		&Rule{Name: "windowtests", Title: "Title", DoW: []string{"sun"}, Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
 		"20230625050000",
 		true,
 	},
 	{
-		&Rule{"windowtests", "", []string{}, "", "", "", "24h", ""},
+		&Rule{Name: "windowtests", Window: "24h"},
 		time.Now().Add(-1 * time.Hour).Format("20060102150405"),
 		true,
 	},
 	{
-		&Rule{"windowtests", "", []string{}, "", "", "", "24h", ""},
+		&Rule{Name: "windowtests", Window: "24h"},
 		time.Now().Add(time.Duration(-48) * time.Hour).Format("20060102150405"),
 		false,
 	},
@@ -440,14 +424,14 @@ func TestMatchWindow(t *testing.T) {
 	}
 
 	// Test with invalid time format
-	r := &Rule{"windowtests", "Title", []string{}, "Keyword", "Pfm", "FMT", "24h", ""}
+	r := &Rule{Name: "windowtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "24h"}
 	got := r.MatchWindow("invalid-time")
 	if got {
 		t.Error("MatchWindow should return false for invalid time format")
 	}
 
 	// Test with invalid window duration
-	r2 := &Rule{"windowtests", "Title", []string{}, "Keyword", "Pfm", "FMT", "invalid", ""}
+	r2 := &Rule{Name: "windowtests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "invalid"}
 	got = r2.MatchWindow(time.Now().Add(-1 * time.Hour).Format("20060102150405"))
 	if !got {
 		t.Error("MatchWindow should handle invalid window duration gracefully")
@@ -459,11 +443,11 @@ var ruletests = []struct {
 	out bool
 }{
 	{
-		&Rule{"ruletests", "Title", []string{"sun"}, "Keyword", "Pfm", "StationID", "Window", ""},
+		&Rule{Name: "ruletests", Title: "Title", DoW: []string{"sun"}, Keyword: "Keyword", Pfm: "Pfm", StationID: "StationID", Window: "Window"},
 		true,
 	},
 	{
-		&Rule{"ruletests", "", []string{}, "", "", "", "", ""},
+		&Rule{Name: "ruletests"},
 		false,
 	},
 }
@@ -476,6 +460,18 @@ func TestHasDoW(t *testing.T) {
 	}
 }
 
+func TestHasGenre(t *testing.T) {
+	for _, tt := range ruletests {
+		if tt.in.HasGenre() {
+			t.Errorf("(%v).HasGenre => %v, want %v", tt.in, true, false)
+		}
+	}
+	r := &Rule{Name: "genretests", Genre: []string{"music"}}
+	if !r.HasGenre() {
+		t.Errorf("(%v).HasGenre => %v, want %v", r, false, true)
+	}
+}
+
 func TestHasPfm(t *testing.T) {
 	for _, tt := range ruletests {
 		if tt.in.HasPfm() != tt.out {
@@ -524,6 +520,40 @@ func TestSetName(t *testing.T) {
 	}
 }
 
+func TestFolderFor(t *testing.T) {
+	r := &Rule{Folder: "talk", FolderByTag: map[string]string{"アニメ": "anime", "j-pop": "music"}}
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{nil, "talk"},
+		{[]string{"ニュース"}, "talk"},
+		{[]string{"ニュース", "アニメ"}, "anime"},
+		{[]string{"J-POP", "アニメ"}, "music"}, // the first tag in the mapping wins, ignoring the case
+	}
+	for _, tt := range tests {
+		if got := r.FolderFor(&Prog{Tags: tt.tags}); got != tt.want {
+			t.Errorf("FolderFor(%v) => %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestRule_ApplyTo(t *testing.T) {
+	r := &Rule{
+		Name:            "show",
+		Folder:          "talk",
+		FolderByTag:     map[string]string{"アニメ": "anime"},
+		AreaFree:        true,
+		Mode:            RuleModeLive,
+		FilenameCharset: FilenameCharsetASCII,
+	}
+	p := &Prog{Tags: []string{"アニメ"}}
+	r.ApplyTo(p)
+	if p.RuleName != "show" || p.RuleFolder != "anime" || !p.AreaFree || p.Mode != RuleModeLive || p.FilenameCharset != FilenameCharsetASCII {
+		t.Errorf("expected the rule's settings on the program, got %+v", p)
+	}
+}
+
 func TestHasRuleFor(t *testing.T) {
 	var rulestests = []struct {
 		in  Rules
@@ -532,16 +562,16 @@ func TestHasRuleFor(t *testing.T) {
 	}{
 		{
 			Rules{
-				&Rule{"rulestests", "Title", []string{}, "Keyword", "Pfm", "FMT", "Window", ""},
-				&Rule{"rulestests", "Title", []string{}, "Keyword", "Pfm", "TBS", "Window", ""},
+				&Rule{Name: "rulestests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "Window"},
+				&Rule{Name: "rulestests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "TBS", Window: "Window"},
 			},
 			"FMT",
 			true,
 		},
 		{
 			Rules{
-				&Rule{"rulestests", "Title", []string{}, "Keyword", "Pfm", "FMT", "Window", ""},
-				&Rule{"rulestests", "Title", []string{}, "Keyword", "Pfm", "TBS", "Window", ""},
+				&Rule{Name: "rulestests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "Window"},
+				&Rule{Name: "rulestests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "TBS", Window: "Window"},
 			},
 			"MBS",
 			false,
@@ -562,15 +592,15 @@ func TestHasRuleWithoutStationID(t *testing.T) {
 	}{
 		{
 			Rules{
-				&Rule{"hrwsitests", "Title", []string{}, "Keyword", "Pfm", "", "Window", ""},
-				&Rule{"hrwsitests", "Title", []string{}, "Keyword", "Pfm", "TBS", "Window", ""},
+				&Rule{Name: "hrwsitests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", Window: "Window"},
+				&Rule{Name: "hrwsitests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "TBS", Window: "Window"},
 			},
 			true,
 		},
 		{
 			Rules{
-				&Rule{"hrwsitests", "Title", []string{}, "Keyword", "Pfm", "FMT", "Window", ""},
-				&Rule{"hrwsitests", "Title", []string{}, "Keyword", "Pfm", "TBS", "Window", ""},
+				&Rule{Name: "hrwsitests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT", Window: "Window"},
+				&Rule{Name: "hrwsitests", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "TBS", Window: "Window"},
 			},
 			false,
 		},
@@ -595,47 +625,35 @@ func TestHasMatch(t *testing.T) {
 	}{
 		{
 			Rules{
-				&Rule{"rule1", "Title", []string{}, "Keyword", "Pfm", "FMT", "", ""},
-				&Rule{"rule2", "OtherTitle", []string{}, "OtherKeyword", "OtherPfm", "TBS", "", ""},
+				&Rule{Name: "rule1", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
+				&Rule{Name: "rule2", Title: "OtherTitle", Keyword: "OtherKeyword", Pfm: "OtherPfm", StationID: "TBS"},
 			},
 			"FMT",
 			&Prog{
-				"ID",
-				"FMT",
-				"20230625050000",
-				"20230625060000",
-				"Title",
-				"Keyword",
-				"",
-				"Pfm",
-				[]string{},
-				ProgGenre{},
-				"",
-				"",
-				"",
+				ID:        "ID",
+				StationID: "FMT",
+				Ft:        "20230625050000",
+				To:        "20230625060000",
+				Title:     "Title",
+				Desc:      "Keyword",
+				Pfm:       "Pfm",
 			},
 			true,
 		},
 		{
 			Rules{
-				&Rule{"rule1", "Title", []string{}, "Keyword", "Pfm", "FMT", "", ""},
-				&Rule{"rule2", "OtherTitle", []string{}, "OtherKeyword", "OtherPfm", "TBS", "", ""},
+				&Rule{Name: "rule1", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
+				&Rule{Name: "rule2", Title: "OtherTitle", Keyword: "OtherKeyword", Pfm: "OtherPfm", StationID: "TBS"},
 			},
 			"MBS",
 			&Prog{
-				"ID",
-				"MBS",
-				"20230625050000",
-				"20230625060000",
-				"Title",
-				"Keyword",
-				"",
-				"Pfm",
-				[]string{},
-				ProgGenre{},
-				"",
-				"",
-				"",
+				ID:        "ID",
+				StationID: "MBS",
+				Ft:        "20230625050000",
+				To:        "20230625060000",
+				Title:     "Title",
+				Desc:      "Keyword",
+				Pfm:       "Pfm",
 			},
 			false,
 		},
@@ -643,19 +661,13 @@ func TestHasMatch(t *testing.T) {
 			Rules{},
 			"FMT",
 			&Prog{
-				"ID",
-				"FMT",
-				"20230625050000",
-				"20230625060000",
-				"Title",
-				"Keyword",
-				"",
-				"Pfm",
-				[]string{},
-				ProgGenre{},
-				"",
-				"",
-				"",
+				ID:        "ID",
+				StationID: "FMT",
+				Ft:        "20230625050000",
+				To:        "20230625060000",
+				Title:     "Title",
+				Desc:      "Keyword",
+				Pfm:       "Pfm",
 			},
 			false,
 		},
@@ -681,70 +693,52 @@ func TestFindMatch(t *testing.T) {
 	}{
 		{
 			Rules{
-				&Rule{"rule1", "Title", []string{}, "Keyword", "Pfm", "FMT", "", ""},
-				&Rule{"rule2", "OtherTitle", []string{}, "OtherKeyword", "OtherPfm", "TBS", "", ""},
+				&Rule{Name: "rule1", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
+				&Rule{Name: "rule2", Title: "OtherTitle", Keyword: "OtherKeyword", Pfm: "OtherPfm", StationID: "TBS"},
 			},
 			"FMT",
 			&Prog{
-				"ID",
-				"FMT",
-				"20230625050000",
-				"20230625060000",
-				"Title",
-				"Keyword",
-				"",
-				"Pfm",
-				[]string{},
-				ProgGenre{},
-				"",
-				"",
-				"",
+				ID:        "ID",
+				StationID: "FMT",
+				Ft:        "20230625050000",
+				To:        "20230625060000",
+				Title:     "Title",
+				Desc:      "Keyword",
+				Pfm:       "Pfm",
 			},
-			&Rule{"rule1", "Title", []string{}, "Keyword", "Pfm", "FMT", "", ""},
+			&Rule{Name: "rule1", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
 		},
 		{
 			Rules{
-				&Rule{"rule1", "Title", []string{}, "Keyword", "Pfm", "FMT", "", ""},
-				&Rule{"rule2", "OtherTitle", []string{}, "OtherKeyword", "OtherPfm", "TBS", "", ""},
+				&Rule{Name: "rule1", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
+				&Rule{Name: "rule2", Title: "OtherTitle", Keyword: "OtherKeyword", Pfm: "OtherPfm", StationID: "TBS"},
 			},
 			"TBS",
 			&Prog{
-				"ID",
-				"TBS",
-				"20230625050000",
-				"20230625060000",
-				"OtherTitle",
-				"OtherKeyword",
-				"",
-				"OtherPfm",
-				[]string{},
-				ProgGenre{},
-				"",
-				"",
-				"",
+				ID:        "ID",
+				StationID: "TBS",
+				Ft:        "20230625050000",
+				To:        "20230625060000",
+				Title:     "OtherTitle",
+				Desc:      "OtherKeyword",
+				Pfm:       "OtherPfm",
 			},
-			&Rule{"rule2", "OtherTitle", []string{}, "OtherKeyword", "OtherPfm", "TBS", "", ""},
+			&Rule{Name: "rule2", Title: "OtherTitle", Keyword: "OtherKeyword", Pfm: "OtherPfm", StationID: "TBS"},
 		},
 		{
 			Rules{
-				&Rule{"rule1", "Title", []string{}, "Keyword", "Pfm", "FMT", "", ""},
-				&Rule{"rule2", "OtherTitle", []string{}, "OtherKeyword", "OtherPfm", "TBS", "", ""},
+				&Rule{Name: "rule1", Title: "Title", Keyword: "Keyword", Pfm: "Pfm", StationID: "FMT"},
+				&Rule{Name: "rule2", Title: "OtherTitle", Keyword: "OtherKeyword", Pfm: "OtherPfm", StationID: "TBS"},
 			},
 			"MBS",
 			&Prog{
-				"ID",
-				"MBS",
-				"20230625050000",
-				"20230625060000",
-				"Title",
-				"Keyword",
-				"",
-				"Pfm",
-				[]string{},
-				ProgGenre{},
-				"",
-				"",
-				"",
+				ID:        "ID",
+				StationID: "MBS",
+				Ft:        "20230625050000",
+				To:        "20230625060000",
+				Title:     "Title",
+				Desc:      "Keyword",
+				Pfm:       "Pfm",
 			},
 			nil,
 		},
@@ -765,3 +759,104 @@ func TestFindMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"48h", 48 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 0, true},
+		{"d", 0, true},
+		{"week", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWindow(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseWindow(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    *Rule
+		wantErr string
+	}{
+		{"title", &Rule{Name: "r", Title: "Title"}, ""},
+		{"station only", &Rule{Name: "r", StationID: "TBS", Folder: "tbs"}, ""},
+		{"dow and window", &Rule{Name: "r", DoW: []string{"Mon", "sun"}, Window: "7d"}, ""},
+		{"empty", &Rule{Name: "r", Folder: "all"}, "has no criteria"},
+		{"wildcard station", &Rule{Name: "r", StationID: "*"}, "has no criteria"},
+		{"invalid dow", &Rule{Name: "r", Title: "Title", DoW: []string{"monday"}}, `invalid dow "monday"`},
+		{"invalid window", &Rule{Name: "r", Title: "Title", Window: "2 days"}, `invalid window "2 days"`},
+		{"zero window", &Rule{Name: "r", Title: "Title", Window: "0d"}, "not positive"},
+		{"live mode", &Rule{Name: "r", Title: "Title", Mode: RuleModeLive}, ""},
+		{"invalid mode", &Rule{Name: "r", Title: "Title", Mode: "radio"}, `invalid mode "radio"`},
+		{"ascii filenames", &Rule{Name: "r", Title: "Title", FilenameCharset: FilenameCharsetASCII}, ""},
+		{"invalid filename charset", &Rule{Name: "r", Title: "Title", FilenameCharset: "romaji"}, `invalid filename-charset "romaji"`},
+		{"keep", &Rule{Name: "r", Title: "Title", Folder: "f", Keep: "90d"}, ""},
+		{"keep forever by tag", &Rule{Name: "r", Title: "Title", FolderByTag: map[string]string{"a": "b"}, Keep: KeepForever}, ""},
+		{"invalid keep", &Rule{Name: "r", Title: "Title", Folder: "f", Keep: "3 months"}, `invalid keep "3 months"`},
+		{"zero keep", &Rule{Name: "r", Title: "Title", Folder: "f", Keep: "0d"}, `invalid keep "0d"`},
+		{"keep without folder", &Rule{Name: "r", Title: "Title", Keep: "90d"}, "no folder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGuideStations(t *testing.T) {
+	defer func(t time.Time) { CurrentTime = t }(CurrentTime)
+	CurrentTime = time.Date(2023, 6, 6, 12, 0, 0, 0, Location) // Tuesday
+	until := CurrentTime.Add(OneDay * time.Hour)
+	stations := []string{"TBS", "FMT", "LFR"}
+
+	saturday := &Rule{Name: "saturday", Keyword: "jazz", DoW: []string{"sat"}, Window: "24h"}
+	if saturday.CanMatchUntil(until) {
+		t.Error("expected the saturday rule not to match until wednesday")
+	}
+	if !saturday.CanMatchUntil(CurrentTime.Add(5 * OneDay * time.Hour)) {
+		t.Error("expected the saturday rule to match by sunday")
+	}
+	monday := &Rule{Name: "monday", Keyword: "jazz", DoW: []string{"mon"}, Window: "2d"}
+	if !monday.CanMatchUntil(until) {
+		t.Error("expected the monday rule to match yesterday's programs")
+	}
+	if !(&Rule{Name: "dow", Keyword: "jazz", DoW: []string{"sat"}}).CanMatchUntil(until) {
+		t.Error("expected the rule without a window to match the past week")
+	}
+
+	tests := []struct {
+		name  string
+		rules Rules
+		want  []string
+	}{
+		{"station rules", Rules{{Name: "fmt", StationID: "FMT"}, {Name: "other", StationID: "QRR"}}, []string{"FMT"}},
+		{"wildcard rule", Rules{{Name: "fmt", StationID: "FMT"}, {Name: "all", Keyword: "jazz"}}, stations},
+		{"narrow wildcard rule", Rules{{Name: "fmt", StationID: "FMT"}, saturday}, []string{"FMT"}},
+		{"narrow station rule", Rules{{Name: "lfr", StationID: "LFR", DoW: []string{"sat"}, Window: "24h"}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.GuideStations(stations, until); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GuideStations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package radikron
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuleOverlap is a pair of rules where one matches all the programs of the other.
+// Winner comes first in the config, so it is applied to the programs both match.
+type RuleOverlap struct {
+	Winner string `json:"winner"`
+	Other  string `json:"other"`
+	// Shadowed is true if the winner matches all the programs of the other rule, which never applies
+	Shadowed bool `json:"shadowed"`
+	// SameFolder is true if both rules save to the same folder
+	SameFolder bool `json:"same-folder"`
+}
+
+// String describes the overlap and the rule winning it
+func (o RuleOverlap) String() string {
+	if o.Shadowed {
+		return fmt.Sprintf("rule[%s] never applies: rule[%s] matches all its programs first", o.Other, o.Winner)
+	}
+	return fmt.Sprintf("rule[%s] matches all the programs of rule[%s] but rule[%s] applies to them first", o.Other, o.Winner, o.Winner)
+}
+
+// Overlaps returns the pairs of rules where one matches all the programs of the other, in the config order.
+// The first matching rule sets the folder, so an overlap of the rules with different folders may route
+// the programs to a surprising folder.
+func (rs Rules) Overlaps() []RuleOverlap {
+	var overlaps []RuleOverlap
+	for i, winner := range rs {
+		for _, other := range rs[i+1:] {
+			shadowed := winner.subsumes(other)
+			if !shadowed && !other.subsumes(winner) {
+				continue
+			}
+			overlaps = append(overlaps, RuleOverlap{
+				Winner:     winner.Name,
+				Other:      other.Name,
+				Shadowed:   shadowed,
+				SameFolder: winner.Folder == other.Folder,
+			})
+		}
+	}
+	return overlaps
+}
+
+// subsumes returns true if the rule matches all the programs matched by o, judging from their criteria
+func (r *Rule) subsumes(o *Rule) bool {
+	if r.HasStationID() && r.StationID != o.StationID {
+		return false
+	}
+	if r.HasDoW() && !isSubset(o.DoW, r.DoW, strings.EqualFold) {
+		return false
+	}
+	if r.HasGenre() && !isSubset(o.Genre, r.Genre, sameGenre) {
+		return false
+	}
+	if r.HasWindow() {
+		window, err := ParseWindow(r.Window)
+		if err != nil {
+			return false
+		}
+		if ow, err := ParseWindow(o.Window); !o.HasWindow() || err != nil || ow > window {
+			return false
+		}
+	}
+	if r.HasTitle() && !(o.HasTitle() && strings.Contains(r.titleAliases.Canonical(o.Title), r.titleAliases.Canonical(r.Title))) {
+		return false
+	}
+	if r.HasPfm() && !(o.HasPfm() && strings.Contains(o.Pfm, r.Pfm)) {
+		return false
+	}
+	// a keyword also matches the title and the pfm
+	if r.HasKeyword() {
+		implied := (o.HasKeyword() && strings.Contains(o.Keyword, r.Keyword)) ||
+			(o.HasTitle() && strings.Contains(o.Title, r.Keyword)) ||
+			(o.HasPfm() && strings.Contains(o.Pfm, r.Keyword))
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// sameGenre returns true if the genre filters are the same, resolving the GenreAliases
+func sameGenre(a, b string) bool {
+	if alias, ok := GenreAliases[strings.ToLower(a)]; ok {
+		a = alias
+	}
+	if alias, ok := GenreAliases[strings.ToLower(b)]; ok {
+		b = alias
+	}
+	return strings.EqualFold(a, b)
+}
+
+// isSubset returns true if sub is not empty and every value of sub is in set
+func isSubset(sub, set []string, equal func(a, b string) bool) bool {
+	if len(sub) == 0 {
+		return false
+	}
+	for _, s := range sub {
+		found := false
+		for _, v := range set {
+			if equal(s, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
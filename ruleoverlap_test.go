@@ -0,0 +1,72 @@
+package radikron
+
+import "testing"
+
+func TestRules_Overlaps(t *testing.T) {
+	rules := Rules{
+		{Name: "citypop", Title: "City Pop", StationID: "FMT", Folder: "citypop"},
+		{Name: "citypop-weekly", Title: "City Pop Weekly", StationID: "FMT", DoW: []string{"mon"}, Folder: "weekly"},
+		{Name: "music", Genre: []string{"music"}, Folder: "music"},
+		{Name: "music-fmt", StationID: "FMT", Genre: []string{"音楽"}, Folder: "music"},
+		{Name: "news", Keyword: "ニュース"},
+		{Name: "tbs-news", StationID: "TBS", Title: "TBSニュース"},
+		{Name: "tbs", StationID: "TBS", Pfm: "Host"},
+	}
+	overlaps := rules.Overlaps()
+	want := []RuleOverlap{
+		{Winner: "citypop", Other: "citypop-weekly", Shadowed: true},
+		{Winner: "music", Other: "music-fmt", Shadowed: true, SameFolder: true},
+		{Winner: "news", Other: "tbs-news", Shadowed: true, SameFolder: true},
+	}
+	if len(overlaps) != len(want) {
+		t.Fatalf("expected %d overlaps, got %+v", len(want), overlaps)
+	}
+	for i := range want {
+		if overlaps[i] != want[i] {
+			t.Errorf("overlaps[%d] = %+v, want %+v", i, overlaps[i], want[i])
+		}
+	}
+}
+
+func TestRules_Overlaps_Broader(t *testing.T) {
+	rules := Rules{
+		{Name: "specific", Title: "City Pop", DoW: []string{"mon"}, Window: "24h", Folder: "a"},
+		{Name: "broad", Title: "City", Window: "48h", Folder: "b"},
+	}
+	overlaps := rules.Overlaps()
+	if len(overlaps) != 1 {
+		t.Fatalf("expected an overlap, got %+v", overlaps)
+	}
+	o := overlaps[0]
+	if o.Winner != "specific" || o.Other != "broad" || o.Shadowed || o.SameFolder {
+		t.Errorf("unexpected overlap: %+v", o)
+	}
+	if got, want := o.String(), "rule[broad] matches all the programs of rule[specific] but rule[specific] applies to them first"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRules_Overlaps_TitleAliases(t *testing.T) {
+	rules := Rules{
+		{Name: "new", Title: "New Show"},
+		{Name: "old", Title: "Old Show"},
+	}
+	rules.SetTitleAliases(newTitleAliases(t, map[string]string{"Old Show": "New Show"}))
+	if overlaps := rules.Overlaps(); len(overlaps) != 1 || !overlaps[0].Shadowed {
+		t.Errorf("expected the renamed title to be shadowed, got %+v", overlaps)
+	}
+}
+
+func TestRules_Overlaps_None(t *testing.T) {
+	rules := Rules{
+		{Name: "a", Title: "A", StationID: "FMT"},
+		{Name: "b", Title: "A", StationID: "TBS"},
+		{Name: "c", Title: "A", DoW: []string{"mon", "tue"}},
+		{Name: "d", Title: "A", DoW: []string{"tue", "wed"}},
+		{Name: "e", Keyword: "x"},
+		{Name: "f", Keyword: "y"},
+	}
+	if overlaps := rules.Overlaps(); len(overlaps) != 0 {
+		t.Errorf("expected no overlaps, got %+v", overlaps)
+	}
+}
@@ -0,0 +1,248 @@
+package radikron
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvAWSAccessKeyID and EnvAWSSecretAccessKey are the credentials of the S3 storage,
+	// and EnvAWSSessionToken the token of the temporary ones
+	EnvAWSAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	EnvAWSSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	EnvAWSSessionToken    = "AWS_SESSION_TOKEN"
+	// s3DefaultRegion is the region of the S3 storage without one in the URL or AWS_REGION
+	s3DefaultRegion = "us-east-1"
+	// s3UnsignedPayload streams the uploads without hashing them first
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Storage saves the outputs to a bucket of S3 or an S3-compatible service, e.g., MinIO,
+// with the path-style requests signed by AWS Signature Version 4
+type S3Storage struct {
+	Endpoint *url.URL
+	Bucket   string
+	// Prefix is the key prefix of the outputs, without the slashes around
+	Prefix string
+	Region string
+
+	accessKeyID, secretAccessKey, sessionToken string
+}
+
+// newS3Storage returns the storage of "s3://bucket/prefix?region=ap-northeast-1&endpoint=https://minio:9000"
+func newS3Storage(u *url.URL) (*S3Storage, error) {
+	q := u.Query()
+	region := q.Get("region")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	endpoint := q.Get("endpoint")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	e, err := url.Parse(endpoint)
+	if err != nil || e.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	e.Path = strings.TrimSuffix(e.Path, "/")
+	s := &S3Storage{
+		Endpoint:        e,
+		Bucket:          u.Host,
+		Prefix:          strings.Trim(u.Path, "/"),
+		Region:          region,
+		accessKeyID:     os.Getenv(EnvAWSAccessKeyID),
+		secretAccessKey: os.Getenv(EnvAWSSecretAccessKey),
+		sessionToken:    os.Getenv(EnvAWSSessionToken),
+	}
+	if s.accessKeyID == "" || s.secretAccessKey == "" {
+		return nil, fmt.Errorf("the S3 storage needs %s and %s", EnvAWSAccessKeyID, EnvAWSSecretAccessKey)
+	}
+	return s, nil
+}
+
+// key returns the object key of the name
+func (s *S3Storage) key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+// objectPath returns the escaped path of the object of the name on the endpoint
+func (s *S3Storage) objectPath(name string) string {
+	return s.Endpoint.EscapedPath() + "/" + awsEscape(s.Bucket, false) + "/" + awsEscape(s.key(name), false)
+}
+
+// do signs and sends the request of the object, returning the response with its body closed
+func (s *S3Storage) do(ctx context.Context, method, name string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := *s.Endpoint
+	u.RawPath = s.objectPath(name)
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, time.Now().UTC())
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp, nil
+}
+
+// sign adds the Authorization header of AWS Signature Version 4 to the request without hashing the body
+func (s *S3Storage) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") || lk == "content-type" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	for _, v := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape escapes the string as AWS Signature Version 4 does: all but the unreserved characters,
+// and the slashes too unless escapeSlash is false for a path
+func awsEscape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *S3Storage) Save(ctx context.Context, localPath, name string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, name, f, info.Size(), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PUT %s: %s", s.key(name), resp.Status)
+	}
+	f.Close()
+	return os.Remove(localPath)
+}
+
+func (s *S3Storage) Exists(ctx context.Context, name string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, name, http.NoBody, 0, nil)
+	if err != nil {
+		return false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("HEAD %s: %s", s.key(name), resp.Status)
+}
+
+// Move copies the object and deletes the source, as S3 has no rename
+func (s *S3Storage) Move(ctx context.Context, from, to string) error {
+	header := http.Header{"X-Amz-Copy-Source": {"/" + awsEscape(s.Bucket, false) + "/" + awsEscape(s.key(from), false)}}
+	resp, err := s.do(ctx, http.MethodPut, to, http.NoBody, 0, header)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("copy %s to %s: %s", s.key(from), s.key(to), resp.Status)
+	}
+	return s.Remove(ctx, from)
+}
+
+func (s *S3Storage) Remove(ctx context.Context, name string) error {
+	resp, err := s.do(ctx, http.MethodDelete, name, http.NoBody, 0, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DELETE %s: %s", s.key(name), resp.Status)
+	}
+	return nil
+}
+
+func (s *S3Storage) String() string {
+	if s.Prefix == "" {
+		return "s3://" + s.Bucket
+	}
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
@@ -0,0 +1,114 @@
+package radikron
+
+import (
+	"sort"
+	"sync"
+)
+
+// The states of the programs in the schedules
+const (
+	// ScheduleWaiting is a program matched on timefree, waiting for the end of its airing
+	ScheduleWaiting = "waiting"
+	// ScheduleLive is a program matched to record live, waiting for the start of its airing
+	ScheduleLive = "live"
+	// ScheduleQueued is a program in the queue, waiting for a download slot or for a retry
+	ScheduleQueued = "queued"
+	// ScheduleDownloading is a program being downloaded or recorded by this process
+	ScheduleDownloading = "downloading"
+)
+
+// ScheduleEntry is a program in the schedules of the running radikron
+type ScheduleEntry struct {
+	StationID string `json:"station-id"`
+	Title     string `json:"title"`
+	Ft        string `json:"ft"`
+	To        string `json:"to"`
+	Rule      string `json:"rule,omitempty"`
+	State     string `json:"state"`
+}
+
+// scheduledProgram is a matched program Download left to a later fetch or to RecordLive
+type scheduledProgram struct {
+	prog *Prog
+	live bool
+}
+
+var (
+	// scheduled are the programs matched by the last asset and not downloaded yet,
+	// as each fetch creates a new asset to match all the programs again
+	scheduled      = map[string]scheduledProgram{}
+	scheduledAsset *Asset
+	scheduledMu    sync.Mutex
+)
+
+// scheduleProgram adds the program matched by the asset to the schedules until startDownload takes it,
+// forgetting the ones matched by an older asset
+func scheduleProgram(asset *Asset, prog *Prog) {
+	scheduledMu.Lock()
+	defer scheduledMu.Unlock()
+	if asset != scheduledAsset {
+		scheduled = map[string]scheduledProgram{}
+		scheduledAsset = asset
+	}
+	scheduled[programLockKey(prog)] = scheduledProgram{prog: prog, live: asset.RecordsLive(prog)}
+}
+
+// unscheduleProgram removes the program from the schedules as its download starts
+func unscheduleProgram(prog *Prog) {
+	scheduledMu.Lock()
+	defer scheduledMu.Unlock()
+	delete(scheduled, programLockKey(prog))
+}
+
+// ScheduledPrograms returns the programs matched and not downloaded yet in the order of their start time:
+// the ones waiting for their airing and the ones in the queue, being downloaded or not
+func ScheduledPrograms() ([]ScheduleEntry, error) {
+	queue, err := LoadQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]ScheduleEntry{}
+	scheduledMu.Lock()
+	for key, s := range scheduled {
+		state := ScheduleWaiting
+		if s.live {
+			state = ScheduleLive
+		}
+		entries[key] = newScheduleEntry(s.prog, state)
+	}
+	scheduledMu.Unlock()
+
+	for _, prog := range queue {
+		entries[programLockKey(prog)] = newScheduleEntry(prog, ScheduleQueued)
+	}
+	// the live recordings are not queued, as they cannot resume
+	inFlightMu.Lock()
+	for key, prog := range inFlight {
+		entries[key] = newScheduleEntry(prog, ScheduleDownloading)
+	}
+	inFlightMu.Unlock()
+
+	list := make([]ScheduleEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Ft != list[j].Ft {
+			return list[i].Ft < list[j].Ft
+		}
+		return list[i].StationID < list[j].StationID
+	})
+	return list, nil
+}
+
+func newScheduleEntry(prog *Prog, state string) ScheduleEntry {
+	return ScheduleEntry{
+		StationID: prog.StationID,
+		Title:     prog.Title,
+		Ft:        prog.Ft,
+		To:        prog.To,
+		Rule:      prog.RuleName,
+		State:     state,
+	}
+}
@@ -0,0 +1,52 @@
+package radikron
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestScheduledPrograms(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	ctx := context.Background()
+	asset := &Asset{Stations: Stations{"FMT": {TimeFree: true}, "JOAK": {TimeFree: false}}}
+
+	waiting := &Prog{ID: "1", StationID: "FMT", Ft: "20230605130000", To: "20230605140000", Title: "Waiting", RuleName: "a"}
+	live := &Prog{ID: "2", StationID: "JOAK", Ft: "20230605120000", To: "20230605130000", Title: "Live", RuleName: "b"}
+	queued := &Prog{ID: "3", StationID: "TBS", Ft: "20230605100000", To: "20230605110000", Title: "Queued", RuleName: "c"}
+	downloading := &Prog{ID: "4", StationID: "TBS", Ft: "20230605090000", To: "20230605100000", Title: "Downloading"}
+	scheduleProgram(asset, waiting)
+	scheduleProgram(asset, live)
+	scheduleProgram(asset, queued)
+	unscheduleProgram(queued)
+	enqueueProgram(ctx, queued)
+	enqueueProgram(ctx, downloading)
+	if !acquireInFlight(downloading) {
+		t.Fatal("acquireInFlight failed")
+	}
+	defer releaseInFlight(downloading)
+
+	entries, err := ScheduledPrograms()
+	if err != nil {
+		t.Fatalf("ScheduledPrograms failed: %v", err)
+	}
+	want := []ScheduleEntry{
+		{StationID: "TBS", Title: "Downloading", Ft: "20230605090000", To: "20230605100000", State: ScheduleDownloading},
+		{StationID: "TBS", Title: "Queued", Ft: "20230605100000", To: "20230605110000", Rule: "c", State: ScheduleQueued},
+		{StationID: "JOAK", Title: "Live", Ft: "20230605120000", To: "20230605130000", Rule: "b", State: ScheduleLive},
+		{StationID: "FMT", Title: "Waiting", Ft: "20230605130000", To: "20230605140000", Rule: "a", State: ScheduleWaiting},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ScheduledPrograms() = %+v, want %+v", entries, want)
+	}
+
+	// the next fetch matches the programs again with a new asset
+	scheduleProgram(&Asset{}, downloading)
+	entries, err = ScheduledPrograms()
+	if err != nil {
+		t.Fatalf("ScheduledPrograms failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected the programs of the old asset to be forgotten, got %+v", entries)
+	}
+}
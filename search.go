@@ -0,0 +1,134 @@
+package radikron
+
+import (
+	"errors"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchSnippetRunes is the number of the characters shown around a match in the snippet
+const searchSnippetRunes = 60
+
+// htmlTagPattern matches the HTML tags in the program info
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// SearchResult is a downloaded program matching the local search
+type SearchResult struct {
+	DownloadRecord
+	// Snippet is the text around the first match in the transcript or the description, if any
+	Snippet string `json:"snippet,omitempty"`
+	// Transcript is true if the snippet is from the transcript of the output
+	Transcript bool `json:"transcript,omitempty"`
+}
+
+// plainText returns the text of the HTML with the whitespace collapsed
+func plainText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(s, " "))), " ")
+}
+
+// searchTerms returns the case-folded words of the query, all of which a match must contain
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// matchesTerms returns true if the texts contain all the terms, case-insensitively
+func matchesTerms(terms []string, texts ...string) bool {
+	haystack := strings.ToLower(strings.Join(texts, "\n"))
+	for _, term := range terms {
+		if !strings.Contains(haystack, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchSnippet returns the text around the first of the terms found in the text, or empty if none
+func searchSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	// fold the case rune by rune to keep the offsets of the original text
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = unicode.ToLower(r)
+	}
+	lower := string(folded)
+	for _, term := range terms {
+		i := strings.Index(lower, term)
+		if i < 0 {
+			continue
+		}
+		start := utf8.RuneCountInString(lower[:i])
+		from := max(start-searchSnippetRunes/2, 0)
+		to := min(start+utf8.RuneCountInString(term)+searchSnippetRunes/2, len(runes))
+		snippet := strings.Join(strings.Fields(string(runes[from:to])), " ")
+		if from > 0 {
+			snippet = "…" + snippet
+		}
+		if to < len(runes) {
+			snippet += "…"
+		}
+		return snippet
+	}
+	return ""
+}
+
+// readTranscript returns the transcript saved next to the output, or empty if none
+func readTranscript(outputPath string) string {
+	if outputPath == "" {
+		return ""
+	}
+	blob, err := os.ReadFile(sidecarPath(outputPath, TranscriptExt))
+	if err != nil {
+		return ""
+	}
+	return string(blob)
+}
+
+// SearchDownloads returns the downloaded programs in the history whose title, station, pfm, description,
+// or transcript contain all the words of the query, case-insensitively, the newest first
+func SearchDownloads(query string) ([]SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, errors.New("empty query")
+	}
+	records, err := LoadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		transcript := readTranscript(r.Path)
+		if !matchesTerms(terms, r.Title, r.StationID, r.Pfm, r.Desc, r.Info, transcript) {
+			continue
+		}
+		result := SearchResult{DownloadRecord: r}
+		if result.Snippet = searchSnippet(transcript, terms); result.Snippet != "" {
+			result.Transcript = true
+		} else if result.Snippet = searchSnippet(r.Desc, terms); result.Snippet == "" {
+			result.Snippet = searchSnippet(r.Info, terms)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SearchPrograms returns the programs in the guide whose title, pfm, or description contain
+// all the words of the query, case-insensitively
+func SearchPrograms(progs Progs, query string) Progs {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	var matched Progs
+	for _, p := range progs {
+		if matchesTerms(terms, p.Title, p.Pfm, p.Desc, plainText(p.Info)) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
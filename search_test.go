@@ -0,0 +1,87 @@
+package radikron
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchDownloads(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	output := filepath.Join(home, "downloads", "2023-06-05-1300_FMT_City Pop.mp3")
+	if err := os.MkdirAll(filepath.Dir(output), DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	transcript := "今日は山下達郎の特集です。 Ride on Time をかけましょう。"
+	if err := os.WriteFile(sidecarPath(output, TranscriptExt), []byte(transcript), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	cityPop := &Prog{ID: "1", StationID: "FMT", Title: "City Pop", Ft: "20230605130000", Pfm: "DJ", Info: "<p>80s <b>Japanese</b> pop</p>"}
+	news := &Prog{ID: "2", StationID: "TBS", Title: "News", Ft: "20230605150000", Desc: "the news of the day"}
+	if err := recordDownload(newDownloadRecord(cityPop, output, time.Now(), 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordDownload(newDownloadRecord(news, "", time.Now(), 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := SearchDownloads("ride ON time")
+	if err != nil {
+		t.Fatalf("SearchDownloads failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Title != "City Pop" || !results[0].Transcript ||
+		!strings.Contains(results[0].Snippet, "Ride on Time") {
+		t.Errorf("expected the transcript match, got %+v", results)
+	}
+
+	results, err = SearchDownloads("japanese pop")
+	if err != nil || len(results) != 1 || results[0].Transcript || results[0].Snippet != "80s Japanese pop" {
+		t.Errorf("expected the info match, got %+v, %v", results, err)
+	}
+
+	// the newest first
+	results, err = SearchDownloads("the")
+	if err != nil || len(results) != 1 || results[0].Title != "News" {
+		t.Errorf("expected the description match, got %+v, %v", results, err)
+	}
+	if results, _ := SearchDownloads("city news"); len(results) != 0 {
+		t.Errorf("expected all the words to match, got %+v", results)
+	}
+	if _, err := SearchDownloads("  "); err == nil {
+		t.Error("expected an error for an empty query")
+	}
+}
+
+func TestSearchPrograms(t *testing.T) {
+	progs := Progs{
+		{Title: "City Pop", Pfm: "DJ"},
+		{Title: "News", Info: "<p>山下達郎 interview</p>"},
+	}
+	if matched := SearchPrograms(progs, "山下達郎"); len(matched) != 1 || matched[0].Title != "News" {
+		t.Errorf("expected the info match, got %+v", matched)
+	}
+	if matched := SearchPrograms(progs, "dj city"); len(matched) != 1 || matched[0].Title != "City Pop" {
+		t.Errorf("expected the title and pfm match, got %+v", matched)
+	}
+	if matched := SearchPrograms(progs, ""); matched != nil {
+		t.Errorf("expected no match for an empty query, got %+v", matched)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	text := strings.Repeat("a", 100) + "Keyword" + strings.Repeat("b", 100)
+	snippet := searchSnippet(text, []string{"keyword"})
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") || !strings.Contains(snippet, "Keyword") {
+		t.Errorf("unexpected snippet: %q", snippet)
+	}
+	if snippet := searchSnippet("ÄÖÜ keyword", []string{"keyword"}); snippet != "ÄÖÜ keyword" {
+		t.Errorf("unexpected snippet: %q", snippet)
+	}
+	if snippet := searchSnippet("nothing", []string{"keyword"}); snippet != "" {
+		t.Errorf("expected no snippet, got %q", snippet)
+	}
+}
@@ -0,0 +1,236 @@
+package radikron
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// secretKeyringPrefix refers to a secret in the OS keyring, e.g., keyring:proxy
+	secretKeyringPrefix = "keyring:"
+	// secretFilePrefix refers to a secret in the encrypted secrets file, e.g., secret:proxy
+	secretFilePrefix = "secret:"
+	// secretKeyringService is the service name of the secrets in the OS keyring
+	secretKeyringService = "radikron"
+	// secretsFileVersion is the format version of the encrypted secrets file
+	secretsFileVersion = 1
+	// scrypt parameters to derive the key from the master key
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	secretKeyLen = 32
+	secretSalt   = 16
+)
+
+// keyringLookup reads a secret from the OS keyring; replaced in tests
+var keyringLookup = lookupKeyring
+
+// secretsFile is the encrypted secrets file in RADICRON_HOME
+type secretsFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// IsSecretRef returns true if the config value refers to a secret instead of holding it
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretKeyringPrefix) || strings.HasPrefix(value, secretFilePrefix)
+}
+
+// ResolveSecret returns the secret the config value refers to:
+// "keyring:<name>" is read from the OS keyring (service "radikron"), and
+// "secret:<name>" from the secrets file in RADICRON_HOME encrypted with EnvMasterKey.
+// Any other value is returned as is.
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretKeyringPrefix):
+		name := strings.TrimPrefix(value, secretKeyringPrefix)
+		if name == "" {
+			return "", errors.New("empty secret name in keyring reference")
+		}
+		secret, err := keyringLookup(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %q from the keyring: %w", name, err)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, secretFilePrefix):
+		name := strings.TrimPrefix(value, secretFilePrefix)
+		if name == "" {
+			return "", errors.New("empty secret name in secret reference")
+		}
+		secrets, err := loadSecrets()
+		if err != nil {
+			return "", err
+		}
+		secret, ok := secrets[name]
+		if !ok {
+			return "", fmt.Errorf("secret %q not found in %s", name, SecretsFileName)
+		}
+		return secret, nil
+	default:
+		return value, nil
+	}
+}
+
+// StoreSecret saves the secret to the secrets file in RADICRON_HOME encrypted with EnvMasterKey,
+// so that the config can refer to it as "secret:<name>"
+func StoreSecret(name, secret string) error {
+	if name == "" {
+		return errors.New("empty secret name")
+	}
+	secrets, err := loadSecrets()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if secrets == nil {
+		secrets = map[string]string{}
+	}
+	secrets[name] = secret
+	return saveSecrets(secrets)
+}
+
+// secretsPath returns the path to the secrets file
+func secretsPath() (string, error) {
+	home, err := getRadicronPath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, SecretsFileName), nil
+}
+
+// masterKey returns the master key from the environment
+func masterKey() ([]byte, error) {
+	key := os.Getenv(EnvMasterKey)
+	if key == "" {
+		return nil, fmt.Errorf("%s is not set", EnvMasterKey)
+	}
+	return []byte(key), nil
+}
+
+// secretsCipher returns the AEAD with the key derived from the master key and salt
+func secretsCipher(salt []byte) (cipher.AEAD, error) {
+	master, err := masterKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(master, salt, scryptN, scryptR, scryptP, secretKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadSecrets decrypts the secrets file;
+// it returns an error wrapping os.ErrNotExist if there is no secrets file
+func loadSecrets() (map[string]string, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the secrets file: %w", err)
+	}
+
+	var f secretsFile
+	if err := json.Unmarshal(blob, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse the secrets file: %w", err)
+	}
+	if f.Version != secretsFileVersion {
+		return nil, fmt.Errorf("unsupported secrets file version: %d", f.Version)
+	}
+	aead, err := secretsCipher(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce in the secrets file")
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the secrets file (wrong %s?)", EnvMasterKey)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse the secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// saveSecrets encrypts the secrets with a fresh salt and nonce and writes the secrets file
+func saveSecrets(secrets map[string]string) error {
+	path, err := secretsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+		return fmt.Errorf("failed to create RADICRON_HOME: %w", err)
+	}
+
+	f := secretsFile{Version: secretsFileVersion, Salt: make([]byte, secretSalt)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := secretsCipher(f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	f.Data = aead.Seal(nil, f.Nonce, plain, nil)
+
+	blob, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, blob, FilePermissions)
+}
+
+// lookupKeyring reads the secret from the OS keyring with the platform's command-line tool
+func lookupKeyring(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", secretKeyringService, "-a", name, "-w")
+	case "windows":
+		return "", fmt.Errorf("keyring is not supported on windows, use %s<name> instead", secretFilePrefix)
+	default:
+		// the Secret Service (GNOME Keyring, KWallet) via libsecret
+		cmd = exec.Command("secret-tool", "lookup", "service", secretKeyringService, "account", name)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -0,0 +1,197 @@
+package radikron
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// unsafeDirNameChars matches the characters not allowed in the aac dir name
+var unsafeDirNameChars = regexp.MustCompile(`[^0-9A-Za-z_-]`)
+
+// segmentManifest records the completed segments of a program download
+// so that an interrupted download can resume after a crash or restart
+type segmentManifest struct {
+	Version   int             `json:"version"`
+	ProgramID string          `json:"program-id"`
+	Chunklist []string        `json:"chunklist"`
+	Completed map[string]bool `json:"completed"` // segment file names
+	// Appended is the number of the segments appended to the concatenated file in the chunklist order
+	Appended int `json:"appended"`
+	// ConcatedSize is the size of the concatenated file after the appended segments
+	ConcatedSize int64 `json:"concated-size"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// segmentFileName returns the file name of the segment for the link
+func segmentFileName(link string) string {
+	_, fileName := filepath.Split(link)
+	return fileName
+}
+
+// programAACDir returns the dir to store the aac files for the program, creating it if needed.
+// Unlike tempAACDir, the dir name is derived from the program ID so that it survives restarts.
+func programAACDir(programID string) (string, error) {
+	fullPath, err := getRadicronPath("tmp")
+	if err != nil {
+		return "", err
+	}
+	aacDir := filepath.Join(fullPath, "aac-"+unsafeDirNameChars.ReplaceAllString(programID, "_"))
+	if err := os.MkdirAll(aacDir, DirPermissions); err != nil {
+		return "", err
+	}
+	return aacDir, nil
+}
+
+// loadSegmentManifest loads the manifest beside the aac dir if it exists for the program,
+// and removes the files in the aac dir that are not recorded as completed
+// (e.g., partially written segments or stale concatenated files).
+func loadSegmentManifest(aacDir, programID string, chunklist []string) (*segmentManifest, error) {
+	m := &segmentManifest{
+		Version:   SegmentManifestVersion,
+		ProgramID: programID,
+		Completed: map[string]bool{},
+		path:      aacDir + SegmentManifestExt,
+	}
+
+	blob, err := os.ReadFile(m.path)
+	switch {
+	case err == nil:
+		var saved segmentManifest
+		jsonErr := json.Unmarshal(blob, &saved)
+		if jsonErr == nil && saved.Version == SegmentManifestVersion && saved.ProgramID == programID && saved.Completed != nil {
+			m.Completed = saved.Completed
+			// the appended segments are valid only if the chunklist has not changed up to them
+			// and the concatenated file has them
+			if saved.Appended <= len(chunklist) && equalChunklist(saved.Chunklist, chunklist, saved.Appended) &&
+				hasConcatedSize(aacDir, saved.ConcatedSize) {
+				m.Appended = saved.Appended
+				m.ConcatedSize = saved.ConcatedSize
+			} else if saved.Appended > 0 {
+				// the appended segments have been removed, so download all over again
+				m.Completed = map[string]bool{}
+			}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read the segment manifest: %w", err)
+	}
+
+	// only keep the completed segments of the current chunklist
+	current := map[string]bool{}
+	for _, link := range chunklist {
+		current[segmentFileName(link)] = true
+	}
+	for name := range m.Completed {
+		if !current[name] {
+			delete(m.Completed, name)
+		}
+	}
+	m.Chunklist = chunklist
+
+	for _, link := range chunklist[:m.Appended] {
+		m.Completed[segmentFileName(link)] = true
+	}
+
+	entries, err := os.ReadDir(aacDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name() == concatedFileName && m.Appended > 0 {
+			continue
+		}
+		if !m.Completed[e.Name()] {
+			if err := os.RemoveAll(filepath.Join(aacDir, e.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// hasConcatedSize returns true if the concatenated file in aacDir has at least size bytes
+func hasConcatedSize(aacDir string, size int64) bool {
+	info, err := os.Stat(filepath.Join(aacDir, concatedFileName))
+	if err != nil {
+		return size == 0
+	}
+	return info.Size() >= size
+}
+
+// equalChunklist returns true if the first n segments of a and b are the same
+func equalChunklist(a, b []string, n int) bool {
+	if len(a) < n || len(b) < n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if segmentFileName(a[i]) != segmentFileName(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isCompleted returns true if the segment has been downloaded
+func (m *segmentManifest) isCompleted(link string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Completed[segmentFileName(link)]
+}
+
+// markCompleted records the segment as downloaded and persists the manifest
+func (m *segmentManifest) markCompleted(link string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Completed[segmentFileName(link)] = true
+	return m.save()
+}
+
+// appendedState returns the number of the appended segments and the size of the concatenated file
+func (m *segmentManifest) appendedState() (appended int, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Appended, m.ConcatedSize
+}
+
+// markAppended records the segments appended to the concatenated file and persists the manifest
+func (m *segmentManifest) markAppended(appended int, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Appended = appended
+	m.ConcatedSize = size
+	return m.save()
+}
+
+// completedCount returns the number of downloaded segments
+func (m *segmentManifest) completedCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Completed)
+}
+
+// save writes the manifest atomically; the caller must hold m.mu unless m is not shared yet
+func (m *segmentManifest) save() error {
+	blob, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.path, blob, FilePermissions)
+}
+
+// remove deletes the manifest file
+func (m *segmentManifest) remove() error {
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package radikron
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProgramAACDir(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+
+	dir, err := programAACDir("12345/../x")
+	if err != nil {
+		t.Fatalf("programAACDir failed: %v", err)
+	}
+	if filepath.Base(dir) != "aac-12345____x" {
+		t.Errorf("programAACDir => %v, want aac-12345____x", filepath.Base(dir))
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("programAACDir should create the dir: %v", err)
+	}
+
+	// the same program ID should give the same dir
+	dir2, err := programAACDir("12345/../x")
+	if err != nil {
+		t.Fatalf("programAACDir failed: %v", err)
+	}
+	if dir != dir2 {
+		t.Errorf("programAACDir => %v, want %v", dir2, dir)
+	}
+}
+
+func TestLoadSegmentManifest(t *testing.T) {
+	aacDir := filepath.Join(t.TempDir(), "aac-test")
+	if err := os.MkdirAll(aacDir, DirPermissions); err != nil {
+		t.Fatalf("Failed to create the aac dir: %v", err)
+	}
+	chunklist := []string{
+		"http://example.com/chunk1.aac",
+		"http://example.com/chunk2.aac",
+	}
+
+	m, err := loadSegmentManifest(aacDir, "test-id", chunklist)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+	if m.completedCount() != 0 {
+		t.Errorf("completedCount => %v, want 0", m.completedCount())
+	}
+	if _, err := os.Stat(aacDir + SegmentManifestExt); err != nil {
+		t.Errorf("the manifest should be saved beside the aac dir: %v", err)
+	}
+
+	// simulate a crash after chunk1 is completed and chunk2 is partially written
+	for _, name := range []string{"chunk1.aac", "chunk2.aac", "concated.aac"} {
+		if err := os.WriteFile(filepath.Join(aacDir, name), []byte(name), FilePermissions); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	if err := m.markCompleted(chunklist[0]); err != nil {
+		t.Fatalf("markCompleted failed: %v", err)
+	}
+
+	resumed, err := loadSegmentManifest(aacDir, "test-id", chunklist)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+	if !resumed.isCompleted(chunklist[0]) {
+		t.Error("chunk1 should be completed after resume")
+	}
+	if resumed.isCompleted(chunklist[1]) {
+		t.Error("chunk2 should not be completed after resume")
+	}
+	if _, err := os.Stat(filepath.Join(aacDir, "chunk1.aac")); err != nil {
+		t.Errorf("the completed segment should be kept: %v", err)
+	}
+	for _, name := range []string{"chunk2.aac", "concated.aac"} {
+		if _, err := os.Stat(filepath.Join(aacDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed on resume", name)
+		}
+	}
+
+	// a manifest for another program should be ignored
+	other, err := loadSegmentManifest(aacDir, "other-id", chunklist)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+	if other.completedCount() != 0 {
+		t.Errorf("completedCount => %v, want 0", other.completedCount())
+	}
+
+	if err := other.remove(); err != nil {
+		t.Errorf("remove failed: %v", err)
+	}
+	if _, err := os.Stat(aacDir + SegmentManifestExt); !os.IsNotExist(err) {
+		t.Error("the manifest should be removed")
+	}
+	// removing twice should not fail
+	if err := other.remove(); err != nil {
+		t.Errorf("remove failed: %v", err)
+	}
+}
+
+func TestBulkDownload_ResumeWithManifest(t *testing.T) {
+	InitSemaphores(&Asset{
+		MaxDownloadingConcurrency: 10,
+		MaxEncodingConcurrency:    2,
+	})
+
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		_, _ = w.Write([]byte("content for " + filepath.Base(r.URL.Path)))
+	}))
+	defer server.Close()
+
+	aacDir := filepath.Join(t.TempDir(), "aac-test")
+	if err := os.MkdirAll(aacDir, DirPermissions); err != nil {
+		t.Fatalf("Failed to create the aac dir: %v", err)
+	}
+	urls := []string{
+		server.URL + "/chunk1.aac",
+		server.URL + "/chunk2.aac",
+		server.URL + "/chunk3.aac",
+	}
+
+	m, err := loadSegmentManifest(aacDir, "test-id", urls)
+	if err != nil {
+		t.Fatalf("loadSegmentManifest failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(aacDir, "chunk1.aac"), []byte("done"), FilePermissions); err != nil {
+		t.Fatalf("Failed to create chunk1.aac: %v", err)
+	}
+	if err := m.markCompleted(urls[0]); err != nil {
+		t.Fatalf("markCompleted failed: %v", err)
+	}
+
+	appender, err := newSegmentAppender(aacDir, urls, m)
+	if err != nil {
+		t.Fatalf("newSegmentAppender failed: %v", err)
+	}
+	if err := bulkDownload(context.Background(), urls, aacDir, appender, nil); err != nil {
+		t.Fatalf("bulkDownload failed: %v", err)
+	}
+	if got := atomic.LoadInt64(&requests); got != 2 {
+		t.Errorf("requests => %v, want 2 (the completed segment should be skipped)", got)
+	}
+	if m.completedCount() != len(urls) {
+		t.Errorf("completedCount => %v, want %v", m.completedCount(), len(urls))
+	}
+}
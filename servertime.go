@@ -0,0 +1,35 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RadikoServerTime returns the time of radiko by the Date header of its station list,
+// to check the clock the programs are scheduled by
+func RadikoServerTime(ctx context.Context) (time.Time, error) {
+	return serverTime(ctx, APIRegionFull)
+}
+
+// serverTime returns the time of the server at url by its Date header
+func serverTime(ctx context.Context, url string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, http.NoBody)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: invalid Date header: %w", url, err)
+	}
+	return date, nil
+}
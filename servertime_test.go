@@ -0,0 +1,32 @@
+package radikron
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerTime(t *testing.T) {
+	date := time.Date(2023, 6, 5, 4, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Date", date.Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	got, err := serverTime(context.Background(), server.URL+"/v3/station/region/full.xml")
+	if err != nil {
+		t.Fatalf("serverTime failed: %v", err)
+	}
+	if !got.Equal(date) {
+		t.Errorf("expected %v, got %v", date, got)
+	}
+	if _, err := serverTime(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("expected an error for 404")
+	}
+}
@@ -0,0 +1,144 @@
+package radikron
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ParseShareURL returns the station ID and the time in the radiko share link
+// ("https://radiko.jp/share/?sid=TBS&t=20230605010000"), the timefree link
+// ("https://radiko.jp/#!/ts/TBS/20230605010000"), or the short form "TBS/20230605010000"
+func ParseShareURL(ref string) (stationID, ft string, err error) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case strings.Contains(ref, "/share/"):
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid share url '%s': %w", ref, err)
+		}
+		stationID, ft = u.Query().Get("sid"), u.Query().Get("t")
+	case strings.Contains(ref, "#!/ts/"):
+		stationID, ft, _ = strings.Cut(ref[strings.Index(ref, "#!/ts/")+len("#!/ts/"):], "/")
+	default:
+		stationID, ft, _ = strings.Cut(ref, "/")
+	}
+	if stationID == "" {
+		return "", "", fmt.Errorf("no station in '%s'", ref)
+	}
+	at, err := ParseDatetime(ft)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid time in '%s': %w", ref, err)
+	}
+	return stationID, FormatDatetime(at), nil
+}
+
+// ReadShareURLs returns the share links in r, one per line, skipping the blank lines and the # comments
+func ReadShareURLs(r io.Reader) ([]string, error) {
+	var refs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	return refs, scanner.Err()
+}
+
+// findProgramAt returns the program airing at ft in the guide, or nil
+func findProgramAt(progs Progs, ft string) *Prog {
+	at, err := ParseDatetime(ft)
+	if err != nil {
+		return nil
+	}
+	for _, p := range progs {
+		from, err := ParseDatetime(p.Ft)
+		if err != nil {
+			continue
+		}
+		to, err := ParseDatetime(p.To)
+		if err != nil {
+			continue
+		}
+		if !at.Before(from) && at.Before(to) {
+			return p
+		}
+	}
+	return nil
+}
+
+// resolveShareURL returns the program of the share link in the weekly guide of its station, fetched once into guides,
+// with the fields of the rule of the asset matching it, if any
+func resolveShareURL(ctx context.Context, asset *Asset, guides map[string]Progs, ref string) (*Prog, error) {
+	stationID, ft, err := ParseShareURL(ref)
+	if err != nil {
+		return nil, err
+	}
+	progs, ok := guides[stationID]
+	if !ok {
+		if progs, err = fetchWeeklyPrograms(ctx, stationID); err != nil {
+			return nil, fmt.Errorf("failed to fetch the %s program for '%s': %w", stationID, ref, err)
+		}
+		guides[stationID] = progs
+	}
+	prog := findProgramAt(progs, ft)
+	if prog == nil {
+		return nil, fmt.Errorf("no program at %s on %s for '%s'", ft, stationID, ref)
+	}
+	if rule := asset.Rules.FindMatchSilent(stationID, prog); rule != nil {
+		rule.ApplyTo(prog)
+	}
+	return prog, nil
+}
+
+// QueueShareURL adds the program of the share link to the queue, which the next fetch downloads
+// like the programs queued before a restart, or records live if the rule of the asset matching it says so
+func QueueShareURL(ctx context.Context, ref string) (*Prog, error) {
+	asset := GetAsset(ctx)
+	if asset == nil {
+		return nil, errors.New("asset not found in context")
+	}
+	prog, err := resolveShareURL(ctx, asset, map[string]Progs{}, ref)
+	if err != nil {
+		return nil, err
+	}
+	if isTimefreeExpired(prog, CurrentTime) {
+		return nil, fmt.Errorf("[%s]%s (%s) is no longer available on timefree", prog.StationID, prog.Title, prog.Ft)
+	}
+	enqueueProgram(ctx, prog)
+	return prog, nil
+}
+
+// DownloadShareURLs downloads the programs of the share links with the normal queue, concurrency, and tagging,
+// e.g., to backfill a newly discovered show. The rule matching a program, if any, sets its folder.
+// It returns the number of the programs started and the errors of the links that failed.
+func DownloadShareURLs(ctx context.Context, wg *sync.WaitGroup, refs []string) (int, error) {
+	asset := GetAsset(ctx)
+	if asset == nil {
+		return 0, errors.New("asset not found in context")
+	}
+
+	var errs []error
+	guides := map[string]Progs{}
+	started := 0
+	for _, ref := range refs {
+		prog, err := resolveShareURL(ctx, asset, guides, ref)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := Download(ctx, wg, prog); err != nil {
+			errs = append(errs, fmt.Errorf("failed to download '%s': %w", ref, err))
+			continue
+		}
+		started++
+	}
+	return started, errors.Join(errs...)
+}
@@ -0,0 +1,160 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseShareURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       string
+		stationID string
+		ft        string
+		wantErr   bool
+	}{
+		{"share url", "https://radiko.jp/share/?sid=TBS&t=20230605010000", "TBS", "20230605010000", false},
+		{"share url from ShareURL", ShareURL(&Prog{StationID: "FMT", Ft: "20230605130000"}), "FMT", "20230605130000", false},
+		{"timefree url", "https://radiko.jp/#!/ts/QRR/20230605220000", "QRR", "20230605220000", false},
+		{"short form", " LFR/20230606010000 ", "LFR", "20230606010000", false},
+		{"after midnight in the 24:00 notation", "LFR/20231231250000", "LFR", "20240101010000", false},
+		{"no station", "https://radiko.jp/share/?t=20230605010000", "", "", true},
+		{"no time", "https://radiko.jp/share/?sid=TBS", "", "", true},
+		{"invalid time", "TBS/2023-06-05", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stationID, ft, err := ParseShareURL(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseShareURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stationID != tt.stationID || ft != tt.ft {
+				t.Errorf("ParseShareURL() = %s, %s, want %s, %s", stationID, ft, tt.stationID, tt.ft)
+			}
+		})
+	}
+}
+
+func TestReadShareURLs(t *testing.T) {
+	refs, err := ReadShareURLs(strings.NewReader("# backfill\nTBS/20230605010000\n\n  https://radiko.jp/share/?sid=TBS&t=20230612010000  \n"))
+	if err != nil {
+		t.Fatalf("ReadShareURLs failed: %v", err)
+	}
+	if len(refs) != 2 || refs[0] != "TBS/20230605010000" || refs[1] != "https://radiko.jp/share/?sid=TBS&t=20230612010000" {
+		t.Errorf("unexpected refs: %q", refs)
+	}
+}
+
+func TestFindProgramAt(t *testing.T) {
+	progs := Progs{
+		{Title: "A", Ft: "20230605010000", To: "20230605030000"},
+		{Title: "B", Ft: "20230605030000", To: "20230605050000"},
+	}
+	for ft, want := range map[string]string{"20230605010000": "A", "20230605020000": "A", "20230605030000": "B"} {
+		if p := findProgramAt(progs, ft); p == nil || p.Title != want {
+			t.Errorf("findProgramAt(%s) = %v, want %s", ft, p, want)
+		}
+	}
+	if p := findProgramAt(progs, "20230605050000"); p != nil {
+		t.Errorf("expected no program at the end, got %s", p.Title)
+	}
+}
+
+func TestDownloadShareURLs(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+
+	fetched := 0
+	origFetch := fetchWeeklyPrograms
+	fetchWeeklyPrograms = func(_ context.Context, stationID string) (Progs, error) {
+		fetched++
+		if stationID != "TBS" {
+			return nil, errors.New("unexpected station")
+		}
+		return Progs{
+			{ID: "1", StationID: "TBS", Title: "Show", Ft: "20230605010000", To: "20230605030000"},
+			{ID: "2", StationID: "TBS", Title: "News", Ft: "20230605030000", To: "20230605040000"},
+		}, nil
+	}
+	t.Cleanup(func() { fetchWeeklyPrograms = origFetch })
+
+	// report the matches only, without downloading
+	asset := &Asset{ReadOnly: true, Rules: Rules{{Name: "show", Title: "Show", Folder: "shows"}}}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	refs := []string{
+		"https://radiko.jp/share/?sid=TBS&t=20230605010000",
+		"TBS/20230605030000",
+		"TBS/20230605050000", // no program
+		"QRR/20230605010000", // guide error
+		"not a link",
+	}
+	started, err := DownloadShareURLs(ctx, &sync.WaitGroup{}, refs)
+	if started != 2 {
+		t.Errorf("expected 2 programs started, got %d", started)
+	}
+	if err == nil || strings.Count(err.Error(), "\n") != 2 {
+		t.Errorf("expected 3 errors, got %v", err)
+	}
+	if fetched != 2 {
+		t.Errorf("expected the guide fetched once per station, got %d", fetched)
+	}
+	if len(emitter.programMatched) != 2 {
+		t.Fatalf("expected 2 programs reported, got %d", len(emitter.programMatched))
+	}
+	if emitter.programMatched[0].ruleName != "show" || emitter.programMatched[1].ruleName != "" {
+		t.Errorf("expected only the first program to have the rule, got %+v", emitter.programMatched)
+	}
+
+	if _, err := DownloadShareURLs(context.Background(), &sync.WaitGroup{}, refs); err == nil {
+		t.Error("expected an error without an asset")
+	}
+}
+
+func TestQueueShareURL(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+
+	origFetch := fetchWeeklyPrograms
+	fetchWeeklyPrograms = func(_ context.Context, stationID string) (Progs, error) {
+		return Progs{
+			{ID: "1", StationID: "TBS", Title: "Show", Ft: "20230605010000", To: "20230605030000"},
+			{ID: "2", StationID: "TBS", Title: "Old", Ft: "20230530010000", To: "20230530030000"},
+		}, nil
+	}
+	t.Cleanup(func() { fetchWeeklyPrograms = origFetch })
+
+	asset := &Asset{Rules: Rules{{Name: "show", Title: "Show", Folder: "shows"}}}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	prog, err := QueueShareURL(ctx, "TBS/20230605020000")
+	if err != nil {
+		t.Fatalf("QueueShareURL failed: %v", err)
+	}
+	if prog.ID != "1" || prog.RuleName != "show" || prog.RuleFolder != "shows" {
+		t.Errorf("unexpected program %+v", prog)
+	}
+	queued, err := LoadQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].ID != "1" || queued[0].RuleFolder != "shows" {
+		t.Errorf("expected the program in the queue, got %+v", queued)
+	}
+
+	if _, err := QueueShareURL(ctx, "TBS/20230530010000"); err == nil {
+		t.Error("expected an error for the program out of timefree")
+	}
+	if _, err := QueueShareURL(context.Background(), "TBS/20230605020000"); err == nil {
+		t.Error("expected an error without an asset")
+	}
+}
@@ -0,0 +1,146 @@
+package radikron
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// programSidecar is the program metadata written to the JSON sidecar file
+type programSidecar struct {
+	ID        string   `json:"id,omitempty"`
+	StationID string   `json:"station-id"`
+	Title     string   `json:"title"`
+	Ft        string   `json:"ft"`
+	To        string   `json:"to"`
+	Pfm       string   `json:"pfm,omitempty"`
+	Info      string   `json:"info,omitempty"`
+	Desc      string   `json:"desc,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Genres    []Genre  `json:"genres,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	ShareURL  string   `json:"share-url"`
+	Rule      string   `json:"rule,omitempty"`
+	File      string   `json:"file"`
+}
+
+// programNFO is the program metadata written to the Kodi-style NFO sidecar file
+type programNFO struct {
+	XMLName   xml.Name `xml:"episodedetails"`
+	Title     string   `xml:"title"`
+	ShowTitle string   `xml:"showtitle"`
+	Plot      string   `xml:"plot,omitempty"`
+	Outline   string   `xml:"outline,omitempty"`
+	Aired     string   `xml:"aired,omitempty"`
+	Runtime   int      `xml:"runtime,omitempty"`
+	Studio    string   `xml:"studio"`
+	Credits   []string `xml:"credits,omitempty"`
+	Genres    []string `xml:"genre,omitempty"`
+	Tags      []string `xml:"tag,omitempty"`
+	UniqueID  *nfoID   `xml:"uniqueid,omitempty"`
+}
+
+type nfoID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// sidecarPath returns the path of the sidecar file with the extension next to the output
+func sidecarPath(outputPath, ext string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ext
+}
+
+// writeSidecars writes the program metadata next to the output in the formats (SidecarJSON or SidecarNFO)
+func writeSidecars(output *radigo.OutputConfig, prog *Prog, formats []string) error {
+	for _, format := range formats {
+		var data []byte
+		var err error
+		switch format {
+		case SidecarJSON:
+			data, err = json.MarshalIndent(newProgramSidecar(output, prog), "", "  ")
+		case SidecarNFO:
+			data, err = xml.MarshalIndent(newProgramNFO(output, prog), "", "  ")
+			data = append([]byte(xml.Header), data...)
+		default:
+			return fmt.Errorf("unsupported sidecar format: %s", format)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode the %s sidecar: %w", format, err)
+		}
+		if err := writeFileAtomic(sidecarPath(output.AbsPath(), "."+format), data, OutputFilePermissions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newProgramSidecar(output *radigo.OutputConfig, prog *Prog) *programSidecar {
+	return &programSidecar{
+		ID:        prog.ID,
+		StationID: prog.StationID,
+		Title:     prog.Title,
+		Ft:        prog.Ft,
+		To:        prog.To,
+		Pfm:       prog.Pfm,
+		Info:      prog.Info,
+		Desc:      prog.Desc,
+		Tags:      prog.Tags,
+		Genres:    prog.Genres,
+		URL:       prog.URL,
+		ShareURL:  ShareURL(prog),
+		Rule:      prog.RuleName,
+		File:      filepath.Base(output.AbsPath()),
+	}
+}
+
+func newProgramNFO(output *radigo.OutputConfig, prog *Prog) *programNFO {
+	nfo := &programNFO{
+		Title:     output.FileBaseName,
+		ShowTitle: prog.Title,
+		Plot:      prog.Info,
+		Outline:   prog.Desc,
+		Studio:    prog.StationID,
+		Tags:      prog.Tags,
+	}
+	if prog.Pfm != "" {
+		nfo.Credits = []string{prog.Pfm}
+	}
+	for _, g := range prog.Genres {
+		nfo.Genres = append(nfo.Genres, g.Name)
+	}
+	ft, ftErr := ParseDatetime(prog.Ft)
+	to, toErr := ParseDatetime(prog.To)
+	if ftErr == nil {
+		nfo.Aired = ft.Format(time.DateOnly)
+		if toErr == nil {
+			nfo.Runtime = int(to.Sub(ft).Minutes())
+		}
+	}
+	if prog.ID != "" {
+		nfo.UniqueID = &nfoID{Type: "radiko", Default: true, Value: prog.ID}
+	}
+	return nfo
+}
+
+// sidecarExts returns the extensions of the sidecar files and the transcript next to an output
+func sidecarExts() []string {
+	return []string{"." + SidecarJSON, "." + SidecarNFO, TranscriptExt}
+}
+
+// moveSidecars moves the sidecar files and the transcript of the output moved from source to dest, if any
+func moveSidecars(source, dest string) {
+	for _, ext := range sidecarExts() {
+		from := sidecarPath(source, ext)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		_ = moveFile(from, sidecarPath(dest, ext))
+	}
+}
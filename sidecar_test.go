@@ -0,0 +1,98 @@
+package radikron
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestWriteSidecars(t *testing.T) {
+	dir := t.TempDir()
+	output := &radigo.OutputConfig{DirFullPath: dir, FileBaseName: "2023-06-05-1300_FMT_Test", FileFormat: radigo.AudioFormatMP3}
+	prog := &Prog{
+		ID:        "FMT_20230605130000",
+		StationID: "FMT",
+		Ft:        "20230605130000",
+		To:        "20230605145500",
+		Title:     "Test",
+		Pfm:       "DJ",
+		Info:      "<p>the full description</p>",
+		Desc:      "short",
+		Tags:      []string{"music"},
+		Genres:    []Genre{{ID: "C001", Name: "Music"}},
+		URL:       "https://example.com/test",
+		RuleName:  "test-rule",
+	}
+
+	if err := writeSidecars(output, prog, []string{SidecarJSON, SidecarNFO}); err != nil {
+		t.Fatalf("writeSidecars failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "2023-06-05-1300_FMT_Test.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar programSidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if sidecar.Info != prog.Info || sidecar.URL != prog.URL || sidecar.Rule != "test-rule" ||
+		sidecar.File != "2023-06-05-1300_FMT_Test.mp3" || sidecar.ShareURL != ShareURL(prog) {
+		t.Errorf("unexpected JSON sidecar: %+v", sidecar)
+	}
+	// the sidecars are read by the media servers like the outputs
+	info, err := os.Stat(filepath.Join(dir, "2023-06-05-1300_FMT_Test.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0044 == 0 {
+		t.Errorf("expected the sidecar readable by the others, got %v", info.Mode().Perm())
+	}
+
+	nfo, err := os.ReadFile(filepath.Join(dir, "2023-06-05-1300_FMT_Test.nfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<episodedetails>",
+		"<showtitle>Test</showtitle>",
+		"<plot>&lt;p&gt;the full description&lt;/p&gt;</plot>",
+		"<aired>2023-06-05</aired>",
+		"<runtime>115</runtime>",
+		"<credits>DJ</credits>",
+		"<genre>Music</genre>",
+		`<uniqueid type="radiko" default="true">FMT_20230605130000</uniqueid>`,
+	} {
+		if !strings.Contains(string(nfo), want) {
+			t.Errorf("expected %s in the NFO sidecar, got:\n%s", want, nfo)
+		}
+	}
+
+	if err := writeSidecars(output, prog, []string{"yaml"}); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestMoveSidecars(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "citypop")
+	if err := os.MkdirAll(dest, DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	moveSidecars(filepath.Join(dir, "test.aac"), filepath.Join(dest, "test.aac"))
+
+	if _, err := os.Stat(filepath.Join(dest, "test.json")); err != nil {
+		t.Errorf("expected the JSON sidecar to be moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "test.nfo")); !os.IsNotExist(err) {
+		t.Errorf("expected no NFO sidecar, got %v", err)
+	}
+}
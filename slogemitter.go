@@ -0,0 +1,172 @@
+package radikron
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlogEventEmitter implements EventEmitter by logging the events with structured fields
+// (event, station, program_id, title, ft, rule, path, duration, ...) for the log aggregators,
+// e.g., with slog.NewJSONHandler
+type SlogEventEmitter struct {
+	logger *slog.Logger
+	mu     sync.Mutex
+	// started are the start times of the downloads and the encodings in progress, for their durations
+	started map[string]time.Time
+}
+
+// Ensure SlogEventEmitter implements EventEmitter at compile time
+var _ EventEmitter = (*SlogEventEmitter)(nil)
+
+// NewSlogEventEmitter returns a SlogEventEmitter logging to logger
+func NewSlogEventEmitter(logger *slog.Logger) *SlogEventEmitter {
+	return &SlogEventEmitter{logger: logger, started: map[string]time.Time{}}
+}
+
+func (e *SlogEventEmitter) log(level slog.Level, msg, event string, attrs ...slog.Attr) {
+	e.logger.LogAttrs(context.Background(), level, msg, append([]slog.Attr{slog.String("event", event)}, attrs...)...)
+}
+
+// start records the start of the download or the encoding of key
+func (e *SlogEventEmitter) start(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.started[key] = time.Now()
+}
+
+// duration returns the attribute of the seconds since the start of key, if recorded
+func (e *SlogEventEmitter) duration(key string) []slog.Attr {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	started, ok := e.started[key]
+	if !ok {
+		return nil
+	}
+	delete(e.started, key)
+	return []slog.Attr{slog.Float64("duration", time.Since(started).Seconds())}
+}
+
+func progAttrs(prog *Prog) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("station", prog.StationID),
+		slog.String("program_id", prog.ID),
+		slog.String("title", prog.Title),
+		slog.String("ft", prog.Ft),
+	}
+	if prog.RuleName != "" {
+		attrs = append(attrs, slog.String("rule", prog.RuleName))
+	}
+	return attrs
+}
+
+func programAttrs(stationID, title, startTime string) []slog.Attr {
+	return []slog.Attr{slog.String("station", stationID), slog.String("title", title), slog.String("ft", startTime)}
+}
+
+// EmitDownloadStarted implements EventEmitter
+func (e *SlogEventEmitter) EmitDownloadStarted(prog *Prog, uri string) {
+	e.start(programLockKey(prog))
+	e.log(slog.LevelInfo, "download started", "download_started", append(progAttrs(prog), slog.String("uri", uri))...)
+}
+
+// EmitDownloadCompleted implements EventEmitter
+func (e *SlogEventEmitter) EmitDownloadCompleted(prog *Prog, filePath string) {
+	attrs := append(progAttrs(prog), slog.String("path", filePath))
+	e.log(slog.LevelInfo, "download completed", "download_completed", append(attrs, e.duration(programLockKey(prog))...)...)
+}
+
+// EmitFileSaved implements EventEmitter
+func (e *SlogEventEmitter) EmitFileSaved(stationID, title, filePath string) {
+	e.log(slog.LevelInfo, "file saved", "file_saved",
+		slog.String("station", stationID), slog.String("title", title), slog.String("path", filePath))
+}
+
+// EmitDownloadSkipped implements EventEmitter
+func (e *SlogEventEmitter) EmitDownloadSkipped(reason, stationID, title, startTime string) {
+	attrs := []slog.Attr{slog.String("reason", reason)}
+	if stationID != "" {
+		attrs = append(attrs, programAttrs(stationID, title, startTime)...)
+	}
+	e.log(slog.LevelInfo, "download skipped", "download_skipped", attrs...)
+}
+
+// EmitDownloadProgress implements EventEmitter, logging at every 10% like LogEventEmitter
+func (e *SlogEventEmitter) EmitDownloadProgress(stationID, title string, done, total int, bytes int64) {
+	if total > 0 && (done == total || done*10/total > (done-1)*10/total) {
+		e.log(slog.LevelInfo, "download progress", "download_progress",
+			slog.String("station", stationID), slog.String("title", title),
+			slog.Int("done", done), slog.Int("total", total), slog.Int64("bytes", bytes))
+	}
+}
+
+// EmitEncodingStarted implements EventEmitter
+func (e *SlogEventEmitter) EmitEncodingStarted(filePath string) {
+	e.start(filePath)
+	e.log(slog.LevelInfo, "encoding started", "encoding_started", slog.String("path", filePath))
+}
+
+// EmitEncodingCompleted implements EventEmitter
+func (e *SlogEventEmitter) EmitEncodingCompleted(filePath string) {
+	e.log(slog.LevelInfo, "encoding completed", "encoding_completed",
+		append([]slog.Attr{slog.String("path", filePath)}, e.duration(filePath)...)...)
+}
+
+// EmitProgramMatched implements EventEmitter
+func (e *SlogEventEmitter) EmitProgramMatched(stationID, title, startTime, ruleName string) {
+	e.log(slog.LevelInfo, "program matched", "program_matched",
+		append(programAttrs(stationID, title, startTime), slog.String("rule", ruleName))...)
+}
+
+// EmitProgramUpcoming implements EventEmitter
+func (e *SlogEventEmitter) EmitProgramUpcoming(stationID, title, startTime, ruleName, shareURL, downloadTime string) {
+	e.log(slog.LevelInfo, "program upcoming", "program_upcoming",
+		append(programAttrs(stationID, title, startTime), slog.String("rule", ruleName),
+			slog.String("share_url", shareURL), slog.String("download_time", downloadTime))...)
+}
+
+// EmitProgramExtended implements EventEmitter
+func (e *SlogEventEmitter) EmitProgramExtended(stationID, title, startTime string, usualMinutes, minutes int) {
+	e.log(slog.LevelWarn, "program extended", "program_extended",
+		append(programAttrs(stationID, title, startTime), slog.Int("usual_minutes", usualMinutes), slog.Int("minutes", minutes))...)
+}
+
+// EmitProgramFailed implements EventEmitter
+func (e *SlogEventEmitter) EmitProgramFailed(stationID, title, startTime string, attempts int) {
+	e.log(slog.LevelError, "program failed", "program_failed",
+		append(programAttrs(stationID, title, startTime), slog.Int("attempts", attempts))...)
+}
+
+// EmitDownloadStalled implements EventEmitter
+func (e *SlogEventEmitter) EmitDownloadStalled(stationID, title, startTime string, timeout time.Duration) {
+	e.log(slog.LevelWarn, "download stalled", "download_stalled",
+		append(programAttrs(stationID, title, startTime), slog.Float64("timeout", timeout.Seconds()))...)
+}
+
+// EmitFileMoved implements EventEmitter
+func (e *SlogEventEmitter) EmitFileMoved(oldPath, newPath string) {
+	e.log(slog.LevelInfo, "file moved", "file_moved", slog.String("from", oldPath), slog.String("path", newPath))
+}
+
+// EmitScheduleConflict implements EventEmitter
+func (e *SlogEventEmitter) EmitScheduleConflict(dropped []*Prog, limit int) {
+	for _, p := range dropped {
+		e.log(slog.LevelWarn, "live recording dropped", "schedule_conflict", append(progAttrs(p), slog.Int("limit", limit))...)
+	}
+}
+
+// EmitConfigSummary implements EventEmitter
+func (e *SlogEventEmitter) EmitConfigSummary(summary ConfigSummary) {
+	e.log(slog.LevelInfo, "effective configuration", "config_summary", slog.Any("config", summary))
+}
+
+// EmitLogMessage implements EventEmitter
+func (e *SlogEventEmitter) EmitLogMessage(level, message string) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		l = slog.LevelInfo
+	}
+	e.log(l, message, "log")
+}
@@ -0,0 +1,67 @@
+package radikron
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogEventEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewSlogEventEmitter(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	prog := &Prog{ID: "FMT20230605130000", StationID: "FMT", Title: "山下達郎のサンデー・ソングブック", Ft: "20230605130000", RuleName: "tatsuro"}
+	emitter.EmitDownloadStarted(prog, "https://example.com/playlist.m3u8")
+	emitter.EmitDownloadCompleted(prog, "/downloads/test.aac")
+	emitter.EmitDownloadSkipped("already exists", "FMT", "Test", "20230605130000")
+	emitter.EmitLogMessage("error", "failed")
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r map[string]any
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("invalid JSON log: %v", err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+
+	started := records[0]
+	if started["event"] != "download_started" || started["station"] != "FMT" || started["program_id"] != "FMT20230605130000" ||
+		started["rule"] != "tatsuro" || started["title"] != prog.Title {
+		t.Errorf("unexpected download_started %v", started)
+	}
+	if _, ok := started["duration"]; ok {
+		t.Errorf("expected no duration at the start, got %v", started)
+	}
+	completed := records[1]
+	if completed["event"] != "download_completed" || completed["path"] != "/downloads/test.aac" {
+		t.Errorf("unexpected download_completed %v", completed)
+	}
+	if _, ok := completed["duration"].(float64); !ok {
+		t.Errorf("expected the duration of the download, got %v", completed)
+	}
+	if records[2]["reason"] != "already exists" || records[2]["ft"] != "20230605130000" {
+		t.Errorf("unexpected download_skipped %v", records[2])
+	}
+	if records[3]["level"] != "ERROR" || records[3]["msg"] != "failed" || records[3]["event"] != "log" {
+		t.Errorf("unexpected log %v", records[3])
+	}
+}
+
+func TestSetDefaultEventEmitter(t *testing.T) {
+	emitter := NewSlogEventEmitter(slog.Default())
+	SetDefaultEventEmitter(emitter)
+	defer SetDefaultEventEmitter(nil)
+	if DefaultEventEmitter() != emitter {
+		t.Error("expected the default emitter to be set")
+	}
+	SetDefaultEventEmitter(nil)
+	if _, ok := DefaultEventEmitter().(LogEventEmitter); !ok {
+		t.Error("expected LogEventEmitter to be restored")
+	}
+}
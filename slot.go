@@ -0,0 +1,172 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// slotHistorySize is the number of the airings kept per slot to tell the usual length
+	slotHistorySize = 8
+	// extendedMinimum is the least extension over the usual length reported as an anomaly
+	extendedMinimum = 10 * time.Minute
+)
+
+var (
+	// slotsMu serializes the updates to the slots file
+	slotsMu sync.Mutex
+	// reportedExtended holds the extended programs already reported by this process
+	reportedExtended = map[string]bool{}
+)
+
+// slotAiring is a past airing of a slot
+type slotAiring struct {
+	Key     string `json:"key"`
+	Minutes int    `json:"minutes"`
+}
+
+// slotsFile records the length of the recent airings of the matched programs per station and title,
+// so that the special editions (e.g., year-end specials) longer than usual can be detected
+type slotsFile struct {
+	Version int                     `json:"version"`
+	Slots   map[string][]slotAiring `json:"slots"`
+}
+
+// slotKey identifies the regular slot of the program, which keeps its history when renamed
+func slotKey(aliases TitleAliases, prog *Prog) string {
+	return prog.StationID + "|" + aliases.Canonical(prog.Title)
+}
+
+// programDuration returns the length of the program from its Ft and To
+func programDuration(prog *Prog) (time.Duration, error) {
+	from, err := ParseDatetime(prog.Ft)
+	if err != nil {
+		return 0, fmt.Errorf("invalid start time format '%s': %w", prog.Ft, err)
+	}
+	to, err := ParseDatetime(prog.To)
+	if err != nil {
+		return 0, fmt.Errorf("invalid end time format '%s': %w", prog.To, err)
+	}
+	return to.Sub(from), nil
+}
+
+// recordSlot adds the airing to the slot history of the program, shared by its renamed titles,
+// and returns the usual length of the slot from the other airings (0 if unknown)
+func recordSlot(aliases TitleAliases, prog *Prog, duration time.Duration) (time.Duration, error) {
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+
+	home, err := getRadicronPath("")
+	if err != nil {
+		return 0, err
+	}
+	path := filepath.Join(home, SlotsFileName)
+
+	f := slotsFile{Version: SlotsVersion, Slots: map[string][]slotAiring{}}
+	blob, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return 0, fmt.Errorf("failed to read the slots: %w", err)
+	default:
+		if err := json.Unmarshal(blob, &f); err != nil {
+			return 0, fmt.Errorf("failed to parse the slots: %w", err)
+		}
+		if f.Slots == nil {
+			f.Slots = map[string][]slotAiring{}
+		}
+	}
+
+	slot := slotKey(aliases, prog)
+	key := programLockKey(prog)
+	airings := f.Slots[slot]
+	var others []int
+	recorded := false
+	for _, a := range airings {
+		if a.Key == key {
+			recorded = true
+			continue
+		}
+		others = append(others, a.Minutes)
+	}
+	usual := time.Duration(median(others)) * time.Minute
+
+	if recorded {
+		return usual, nil
+	}
+	airings = append(airings, slotAiring{Key: key, Minutes: int(duration.Minutes())})
+	if len(airings) > slotHistorySize {
+		airings = airings[len(airings)-slotHistorySize:]
+	}
+	f.Slots[slot] = airings
+	f.Version = SlotsVersion
+
+	if err := os.MkdirAll(home, DirPermissions); err != nil {
+		return 0, fmt.Errorf("failed to create RADICRON_HOME: %w", err)
+	}
+	if blob, err = json.Marshal(f); err != nil {
+		return 0, err
+	}
+	return usual, writeFileAtomic(path, blob, FilePermissions)
+}
+
+// median returns the median of the values, or 0 if empty
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// isExtended returns true if the length is longer than the usual length
+// by more than a quarter of it (and at least extendedMinimum)
+func isExtended(duration, usual time.Duration) bool {
+	if usual <= 0 {
+		return false
+	}
+	margin := usual / 4
+	if margin < extendedMinimum {
+		margin = extendedMinimum
+	}
+	return duration > usual+margin
+}
+
+// checkExtendedProgram reports the program once if it runs longer than its usual slot,
+// e.g., a year-end special, and returns true if so
+func checkExtendedProgram(ctx context.Context, prog *Prog) bool {
+	duration, err := programDuration(prog)
+	if err != nil {
+		return false
+	}
+	var aliases TitleAliases
+	if asset := GetAsset(ctx); asset != nil {
+		aliases = asset.TitleAliases
+	}
+	usual, err := recordSlot(aliases, prog, duration)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to record the slot of [%s]%s: %v", prog.StationID, prog.Title, err))
+		return false
+	}
+	if !isExtended(duration, usual) {
+		return false
+	}
+
+	slotsMu.Lock()
+	key := programLockKey(prog)
+	reported := reportedExtended[key]
+	reportedExtended[key] = true
+	slotsMu.Unlock()
+	if !reported {
+		emitProgramExtended(ctx, prog.StationID, prog.Title, prog.Ft, int(usual.Minutes()), int(duration.Minutes()))
+	}
+	return true
+}
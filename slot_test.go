@@ -0,0 +1,97 @@
+package radikron
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestIsExtended(t *testing.T) {
+	tests := []struct {
+		duration, usual time.Duration
+		want            bool
+	}{
+		{time.Hour, 0, false},
+		{time.Hour, time.Hour, false},
+		{65 * time.Minute, time.Hour, false},
+		{80 * time.Minute, time.Hour, true},
+		{25 * time.Minute, 10 * time.Minute, true},
+		{18 * time.Minute, 10 * time.Minute, false},
+		{4 * time.Hour, 2 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		if got := isExtended(tt.duration, tt.usual); got != tt.want {
+			t.Errorf("isExtended(%v, %v) = %v, want %v", tt.duration, tt.usual, got, tt.want)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if got := median(nil); got != 0 {
+		t.Errorf("expected 0 for no values, got %d", got)
+	}
+	if got := median([]int{120, 60, 60}); got != 60 {
+		t.Errorf("expected 60, got %d", got)
+	}
+}
+
+func TestRecordSlot(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+
+	first := &Prog{ID: "1", StationID: "FMT", Title: "Weekly", Ft: "20231223130000", To: "20231223140000"}
+	usual, err := recordSlot(nil, first, time.Hour)
+	if err != nil {
+		t.Fatalf("recordSlot failed: %v", err)
+	}
+	if usual != 0 {
+		t.Errorf("expected no usual length for the first airing, got %v", usual)
+	}
+	// recording the same airing again does not count it as another airing
+	if usual, _ = recordSlot(nil, first, time.Hour); usual != 0 {
+		t.Errorf("expected no usual length from the same airing, got %v", usual)
+	}
+
+	special := &Prog{ID: "2", StationID: "FMT", Title: "Weekly", Ft: "20231230130000", To: "20231230170000"}
+	usual, err = recordSlot(nil, special, 4*time.Hour)
+	if err != nil {
+		t.Fatalf("recordSlot failed: %v", err)
+	}
+	if usual != time.Hour {
+		t.Errorf("expected the usual length of 1h, got %v", usual)
+	}
+}
+
+func TestDownload_ExtendedProgram(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	CurrentTime = time.Date(2023, 12, 20, 12, 0, 0, 0, Location)
+
+	asset := &Asset{
+		OutputFormat: radigo.AudioFormatAAC,
+		DownloadDir:  "downloads",
+		Rules:        Rules{},
+		Schedules:    Schedules{},
+	}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	// future programs are only recorded and reported
+	regular := &Prog{ID: "ext-1", StationID: "FMT", Title: "Weekly", Ft: "20231223130000", To: "20231223140000"}
+	special := &Prog{ID: "ext-2", StationID: "FMT", Title: "Weekly", Ft: "20231230130000", To: "20231230170000"}
+	for _, prog := range []*Prog{regular, special, special} {
+		if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+	}
+
+	if len(emitter.programExtended) != 1 {
+		t.Fatalf("expected 1 extended event, got %d", len(emitter.programExtended))
+	}
+	got := emitter.programExtended[0]
+	if got.startTime != special.Ft || got.usualMinutes != 60 || got.minutes != 240 {
+		t.Errorf("unexpected extended event: %+v", got)
+	}
+}
@@ -0,0 +1,179 @@
+package radikron
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// stateMigration upgrades the persistent state in RADICRON_HOME to version
+type stateMigration struct {
+	version     int
+	description string
+	migrate     func(home string) error
+}
+
+// stateMigrations are applied in order to the state older than their version.
+// Append a new migration and bump StateSchemaVersion when the persistent state format changes.
+var stateMigrations = []stateMigration{
+	{
+		version:     1,
+		description: "add the schema version to the segment manifests",
+		migrate:     migrateSegmentManifestsV1,
+	},
+	{
+		version:     2,
+		description: "record the segments appended to the concatenated file in the segment manifests",
+		migrate:     migrateSegmentManifestsV2,
+	},
+}
+
+// stateFile records the schema version of the persistent state
+type stateFile struct {
+	Version int `json:"version"`
+}
+
+// MigrateState upgrades the persistent state in RADICRON_HOME (e.g., the segment manifests)
+// to StateSchemaVersion so that upgrades never require wiping RADICRON_HOME.
+// It fails if the state was written by a newer version of radikron.
+func MigrateState() error {
+	home, err := getRadicronPath("")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(home, DirPermissions); err != nil {
+		return fmt.Errorf("failed to create RADICRON_HOME: %w", err)
+	}
+
+	path := filepath.Join(home, StateFileName)
+	version, err := loadStateVersion(path)
+	if err != nil {
+		return err
+	}
+	if version > StateSchemaVersion {
+		return fmt.Errorf(
+			"the state in %s has schema version %d, newer than the supported version %d: upgrade radikron",
+			home, version, StateSchemaVersion)
+	}
+
+	for _, m := range stateMigrations {
+		if m.version <= version {
+			continue
+		}
+		log.Printf("migrating the state to version %d: %s", m.version, m.description)
+		if err := m.migrate(home); err != nil {
+			return fmt.Errorf("failed to migrate the state to version %d: %w", m.version, err)
+		}
+		// record each step so that an interrupted migration resumes from there
+		if err := saveStateVersion(path, m.version); err != nil {
+			return err
+		}
+		version = m.version
+	}
+
+	if version < StateSchemaVersion {
+		return saveStateVersion(path, StateSchemaVersion)
+	}
+	return nil
+}
+
+// loadStateVersion returns the schema version in the state file, or 0 if it does not exist
+func loadStateVersion(path string) (int, error) {
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the state file: %w", err)
+	}
+	var s stateFile
+	if err := json.Unmarshal(blob, &s); err != nil {
+		return 0, fmt.Errorf("failed to parse the state file %s: %w", path, err)
+	}
+	return s.Version, nil
+}
+
+// saveStateVersion writes the schema version to the state file atomically
+func saveStateVersion(path string, version int) error {
+	blob, err := json.Marshal(stateFile{Version: version})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, blob, FilePermissions)
+}
+
+// writeFileAtomic writes the data to a temporary file with perm and renames it to path,
+// e.g., FilePermissions for the state or OutputFilePermissions for the files beside the outputs
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename %s: %w", path, err)
+	}
+	return nil
+}
+
+// migrateSegmentManifestsV1 adds the version to the segment manifests written before versioning
+func migrateSegmentManifestsV1(home string) error {
+	return updateSegmentManifests(home, func(m map[string]any) bool {
+		if _, ok := m["version"]; ok {
+			return false
+		}
+		m["version"] = 1
+		return true
+	})
+}
+
+// migrateSegmentManifestsV2 marks the segment manifests as having no segment appended yet,
+// since the segments were concatenated only after all of them were downloaded
+func migrateSegmentManifestsV2(home string) error {
+	return updateSegmentManifests(home, func(m map[string]any) bool {
+		if v, ok := m["version"].(float64); ok && v >= 2 {
+			return false
+		}
+		m["version"] = 2
+		m["appended"] = 0
+		m["concated-size"] = 0
+		return true
+	})
+}
+
+// updateSegmentManifests rewrites the segment manifests in RADICRON_HOME updated by update,
+// which returns false to leave the manifest as is
+func updateSegmentManifests(home string, update func(m map[string]any) bool) error {
+	manifests, err := filepath.Glob(filepath.Join(home, "tmp", "aac-*"+SegmentManifestExt))
+	if err != nil {
+		return err
+	}
+	for _, path := range manifests {
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var m map[string]any
+		if err := json.Unmarshal(blob, &m); err != nil {
+			// a broken manifest is discarded, and the download restarts from scratch
+			log.Printf("removing the broken segment manifest %s: %v", path, err)
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if !update(m) {
+			continue
+		}
+		if blob, err = json.Marshal(m); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, blob, FilePermissions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
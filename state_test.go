@@ -0,0 +1,72 @@
+package radikron
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateState(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	// a segment manifest written before versioning
+	tmpDir := filepath.Join(home, "tmp")
+	if err := os.MkdirAll(tmpDir, DirPermissions); err != nil {
+		t.Fatalf("Failed to create the tmp dir: %v", err)
+	}
+	manifestPath := filepath.Join(tmpDir, "aac-12345"+SegmentManifestExt)
+	legacy := `{"program-id":"12345","chunklist":["http://example.com/chunk1.aac"],"completed":{"chunk1.aac":true}}`
+	if err := os.WriteFile(manifestPath, []byte(legacy), FilePermissions); err != nil {
+		t.Fatalf("Failed to write the manifest: %v", err)
+	}
+	brokenPath := filepath.Join(tmpDir, "aac-broken"+SegmentManifestExt)
+	if err := os.WriteFile(brokenPath, []byte("{"), FilePermissions); err != nil {
+		t.Fatalf("Failed to write the manifest: %v", err)
+	}
+
+	if err := MigrateState(); err != nil {
+		t.Fatalf("MigrateState failed: %v", err)
+	}
+
+	version, err := loadStateVersion(filepath.Join(home, StateFileName))
+	if err != nil {
+		t.Fatalf("loadStateVersion failed: %v", err)
+	}
+	if version != StateSchemaVersion {
+		t.Errorf("version => %v, want %v", version, StateSchemaVersion)
+	}
+
+	blob, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read the manifest: %v", err)
+	}
+	var m segmentManifest
+	if err := json.Unmarshal(blob, &m); err != nil {
+		t.Fatalf("Failed to parse the manifest: %v", err)
+	}
+	if m.Version != SegmentManifestVersion || !m.Completed["chunk1.aac"] {
+		t.Errorf("migrated manifest => version %d, completed %v, want version %d with chunk1.aac completed", m.Version, m.Completed, SegmentManifestVersion)
+	}
+	if _, err := os.Stat(brokenPath); !os.IsNotExist(err) {
+		t.Error("the broken manifest should be removed")
+	}
+
+	// migrating again is a no-op
+	if err := MigrateState(); err != nil {
+		t.Errorf("MigrateState on the current version failed: %v", err)
+	}
+}
+
+func TestMigrateState_Newer(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	if err := saveStateVersion(filepath.Join(home, StateFileName), StateSchemaVersion+1); err != nil {
+		t.Fatalf("saveStateVersion failed: %v", err)
+	}
+	if err := MigrateState(); err == nil {
+		t.Error("MigrateState should fail for the state written by a newer version")
+	}
+}
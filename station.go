@@ -0,0 +1,87 @@
+package radikron
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// StationInfo is the metadata of a station for the frontends to render its branding instead of the raw ID
+type StationInfo struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	ASCIIName  string   `json:"ascii-name,omitempty"`
+	Ruby       string   `json:"ruby,omitempty"`
+	LogoURL    string   `json:"logo-url,omitempty"`
+	BannerURL  string   `json:"banner-url,omitempty"`
+	Href       string   `json:"href,omitempty"`
+	Areas      []string `json:"areas"`
+	RegionID   string   `json:"region-id,omitempty"`
+	RegionName string   `json:"region-name,omitempty"`
+	AreaFree   bool     `json:"areafree"`
+	TimeFree   bool     `json:"timefree"`
+	// Available is true if the station is watched, i.e., in the area, an extra station, or of a rule
+	Available bool `json:"available"`
+}
+
+// StationInfos returns the metadata of all the stations sorted by ID
+func (a *Asset) StationInfos() []StationInfo {
+	available := make(map[string]bool, len(a.AvailableStations))
+	for _, id := range a.AvailableStations {
+		available[id] = true
+	}
+	infos := make([]StationInfo, 0, len(a.Stations))
+	for id, s := range a.Stations {
+		infos = append(infos, StationInfo{
+			ID:         id,
+			Name:       s.Name,
+			ASCIIName:  s.ASCIIName,
+			Ruby:       s.Ruby,
+			LogoURL:    s.LogoURL,
+			BannerURL:  s.BannerURL,
+			Href:       s.Href,
+			Areas:      s.Areas,
+			RegionID:   s.RegionID,
+			RegionName: s.RegionName,
+			AreaFree:   s.AreaFree,
+			TimeFree:   s.TimeFree,
+			Available:  available[id],
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// serveStations serves the metadata of all the stations of the asset as JSON
+func serveStations(w http.ResponseWriter, r *http.Request) {
+	infos := []StationInfo{}
+	if asset := GetAsset(r.Context()); asset != nil {
+		infos = asset.StationInfos()
+	}
+	writeJSON(w, infos)
+}
+
+// serveStation serves the metadata of the station of the ID as JSON
+func serveStation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var infos []StationInfo
+	if asset := GetAsset(r.Context()); asset != nil {
+		infos = asset.StationInfos()
+	}
+	i := sort.Search(len(infos), func(i int) bool { return infos[i].ID >= id })
+	if i == len(infos) || infos[i].ID != id {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, infos[i])
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(blob)
+}
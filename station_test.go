@@ -0,0 +1,66 @@
+package radikron
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStationInfos(t *testing.T) {
+	asset := &Asset{
+		AvailableStations: []string{"TBS"},
+		Stations: Stations{
+			"TBS": &Station{Areas: []string{"JP13"}, Name: "TBSラジオ", LogoURL: "https://example.com/tbs.png", RegionID: "kanto", AreaFree: true, TimeFree: true},
+			"MBS": &Station{Areas: []string{"JP27", "JP26"}, Name: "MBSラジオ", RegionID: "kinki"},
+		},
+	}
+	infos := asset.StationInfos()
+	if len(infos) != 2 || infos[0].ID != "MBS" || infos[1].ID != "TBS" {
+		t.Fatalf("expected the stations sorted by ID, got %+v", infos)
+	}
+	if infos[0].Available || !infos[1].Available {
+		t.Errorf("expected only TBS to be available, got %+v", infos)
+	}
+	if infos[1].LogoURL != "https://example.com/tbs.png" || !infos[1].AreaFree || infos[1].RegionID != "kanto" {
+		t.Errorf("unexpected metadata of TBS: %+v", infos[1])
+	}
+}
+
+func TestServeStations(t *testing.T) {
+	asset := &Asset{Stations: Stations{
+		"MBS": {Name: "MBSラジオ", Areas: []string{"JP27"}},
+		"TBS": {Name: "TBSラジオ", Areas: []string{"JP13"}, LogoURL: "https://example.com/tbs.png"},
+	}}
+	handler := NewFeedHandler(func() *Asset { return asset })
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stations", http.NoBody))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var infos []StationInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &infos); err != nil || len(infos) != 2 {
+		t.Fatalf("expected 2 stations, got %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stations/TBS", http.NoBody))
+	var info StationInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil || info.LogoURL != "https://example.com/tbs.png" {
+		t.Errorf("unexpected station: %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stations/NOPE", http.NoBody))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown station, got %d", rec.Code)
+	}
+
+	asset = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stations", http.NoBody))
+	if rec.Body.String() != "[]" {
+		t.Errorf("expected an empty list before the configuration, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,118 @@
+package radikron
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is how many of the last error messages the status keeps
+const maxRecentErrors = 10
+
+// StatusError is an error message logged by the running radikron
+type StatusError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Status is a snapshot of the running radikron for the status command
+type Status struct {
+	Started   time.Time  `json:"started"`
+	NextFetch *time.Time `json:"next-fetch,omitempty"`
+	// FetchDuration is the moving average of how long the fetches take until the downloads start
+	FetchDuration time.Duration   `json:"fetch-duration"`
+	Downloads     []ScheduleEntry `json:"downloads"`
+	// Encodes are the encodings running or waiting for a worker, and Deferred the ones waiting for the encoding-window
+	Encodes  int           `json:"encodes"`
+	Deferred int           `json:"deferred-encodes"`
+	Errors   []StatusError `json:"errors"`
+	// APISignals are the responses of radiko refusing the requests in the last 24 hours
+	APISignals APISignalStats `json:"api-signals"`
+}
+
+var (
+	statusMu sync.Mutex
+	// statusStarted is when the control API started serving
+	statusStarted time.Time
+	// statusAsset is the asset of the last fetch, and statusNextFetch its next fetch time
+	statusAsset     *Asset
+	statusNextFetch *time.Time
+	recentErrors    []StatusError
+)
+
+// SetStatusAsset records the asset of the fetch just completed for the status, with its next fetch time
+func SetStatusAsset(asset *Asset) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	statusAsset = asset
+	statusNextFetch = nil
+	if asset != nil && asset.NextFetchTime != nil {
+		next := *asset.NextFetchTime
+		statusNextFetch = &next
+	}
+}
+
+// StatusAsset returns the asset of the last fetch, or nil before the first fetch completes
+func StatusAsset() *Asset {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return statusAsset
+}
+
+// withAsset serves the requests with the asset of current, if any, in their context,
+// so that a server started once follows the reloads of the configuration
+func withAsset(current func() *Asset, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if asset := current(); asset != nil {
+			r = r.WithContext(context.WithValue(r.Context(), ContextKey("asset"), asset))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordError keeps the error message for the status, dropping the oldest beyond maxRecentErrors
+func recordError(message string) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	recentErrors = append(recentErrors, StatusError{Time: time.Now(), Message: message})
+	if len(recentErrors) > maxRecentErrors {
+		recentErrors = recentErrors[len(recentErrors)-maxRecentErrors:]
+	}
+}
+
+// CurrentStatus returns the status of this process
+func CurrentStatus() Status {
+	statusMu.Lock()
+	s := Status{
+		Started:       statusStarted,
+		NextFetch:     statusNextFetch,
+		FetchDuration: FetchDuration(),
+		Errors:        append([]StatusError{}, recentErrors...),
+	}
+	asset := statusAsset
+	statusMu.Unlock()
+
+	inFlightMu.Lock()
+	s.Downloads = make([]ScheduleEntry, 0, len(inFlight))
+	for _, prog := range inFlight {
+		s.Downloads = append(s.Downloads, newScheduleEntry(prog, ScheduleDownloading))
+	}
+	inFlightMu.Unlock()
+	sort.Slice(s.Downloads, func(i, j int) bool { return s.Downloads[i].Ft < s.Downloads[j].Ft })
+
+	if asset != nil {
+		stats := asset.encodePool().Stats()
+		s.Encodes = stats.Running + stats.Queued
+	}
+	if dir, err := encodeQueueDir(); err == nil {
+		if keys, err := pendingEncodeJobs(dir); err == nil {
+			s.Deferred = len(keys)
+		}
+	}
+	if signals, err := LoadAPISignals(time.Now().Add(-OneDay * time.Hour)); err == nil {
+		s.APISignals = SumAPISignals(signals)
+	}
+	return s
+}
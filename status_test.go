@@ -0,0 +1,64 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCurrentStatus(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	defer SetStatusAsset(nil)
+
+	next := time.Date(2023, 6, 5, 14, 5, 0, 0, Location)
+	asset := &Asset{NextFetchTime: &next}
+	SetStatusAsset(asset)
+	// the next fetch of the asset moves on after the snapshot
+	later := next.Add(time.Hour)
+	asset.NextFetchTime = &later
+
+	prog := &Prog{ID: "1", StationID: "FMT", Ft: "20230605130000", To: "20230605140000", Title: "Test", RuleName: "rule"}
+	if !acquireInFlight(prog) {
+		t.Fatal("acquireInFlight failed")
+	}
+	defer releaseInFlight(prog)
+	if err := os.MkdirAll(filepath.Join(home, EncodeQueueDirName), DirPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, EncodeQueueDirName, "FMT_1.json"), []byte("{}"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	s := CurrentStatus()
+	if s.NextFetch == nil || !s.NextFetch.Equal(next) {
+		t.Errorf("expected the next fetch at %v, got %v", next, s.NextFetch)
+	}
+	if len(s.Downloads) != 1 || s.Downloads[0].Title != "Test" || s.Downloads[0].Rule != "rule" {
+		t.Errorf("unexpected downloads %+v", s.Downloads)
+	}
+	if s.Encodes != 0 || s.Deferred != 1 {
+		t.Errorf("expected 0 encodes and 1 deferred, got %d and %d", s.Encodes, s.Deferred)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	statusMu.Lock()
+	recentErrors = nil
+	statusMu.Unlock()
+
+	emitLogMessage(context.Background(), "info", "not an error")
+	for i := 0; i < maxRecentErrors+2; i++ {
+		emitLogMessage(context.Background(), "error", fmt.Sprintf("error %d", i))
+	}
+	errs := CurrentStatus().Errors
+	if len(errs) != maxRecentErrors {
+		t.Fatalf("expected %d errors, got %d", maxRecentErrors, len(errs))
+	}
+	if errs[0].Message != "error 2" || errs[len(errs)-1].Message != fmt.Sprintf("error %d", maxRecentErrors+1) {
+		t.Errorf("expected the last errors, got %+v", errs)
+	}
+}
@@ -0,0 +1,59 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+)
+
+// ConfigSummary is the effective configuration reported on startup
+type ConfigSummary struct {
+	AreaID                    string  `json:"area-id"`
+	Stations                  int     `json:"stations"`
+	Rules                     int     `json:"rules"`
+	OutputFormat              string  `json:"file-format"`
+	Home                      string  `json:"home"`
+	DownloadDir               string  `json:"downloads"`
+	MaxDownloadingConcurrency int     `json:"max-downloading-concurrency"`
+	MaxEncodingConcurrency    int     `json:"max-encoding-concurrency"`
+	RequestsPerSecond         float64 `json:"requests-per-second"`
+	ReadOnly                  bool    `json:"read-only"`
+}
+
+// NewConfigSummary returns the ConfigSummary of the asset for the area
+func NewConfigSummary(asset *Asset, areaID string) ConfigSummary {
+	home, err := getRadicronPath("")
+	if err != nil {
+		home = ""
+	}
+	downloadDir, err := getRadicronPath(asset.DownloadDir)
+	if err != nil {
+		downloadDir = asset.DownloadDir
+	}
+	return ConfigSummary{
+		AreaID:                    areaID,
+		Stations:                  len(asset.AvailableStations),
+		Rules:                     len(asset.Rules),
+		OutputFormat:              asset.OutputFormat,
+		Home:                      home,
+		DownloadDir:               downloadDir,
+		MaxDownloadingConcurrency: asset.MaxDownloadingConcurrency,
+		MaxEncodingConcurrency:    asset.MaxEncodingConcurrency,
+		RequestsPerSecond:         asset.RequestsPerSecond,
+		ReadOnly:                  asset.ReadOnly,
+	}
+}
+
+// String returns the summary in a single line of key=value pairs
+func (s ConfigSummary) String() string {
+	return fmt.Sprintf(
+		"area-id=%s stations=%d rules=%d file-format=%s home=%q downloads=%q "+
+			"max-downloading-concurrency=%d max-encoding-concurrency=%d requests-per-second=%g read-only=%t",
+		s.AreaID, s.Stations, s.Rules, s.OutputFormat, s.Home, s.DownloadDir,
+		s.MaxDownloadingConcurrency, s.MaxEncodingConcurrency, s.RequestsPerSecond, s.ReadOnly,
+	)
+}
+
+// EmitConfigSummary emits the effective configuration if emitter is available, otherwise logs it
+func EmitConfigSummary(ctx context.Context, summary ConfigSummary) {
+	eventEmitter(ctx).EmitConfigSummary(summary)
+}
@@ -0,0 +1,66 @@
+package radikron
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestNewConfigSummary(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+
+	asset := &Asset{
+		AvailableStations:         []string{"FMT", "TBS"},
+		Rules:                     Rules{&Rule{Name: "test"}},
+		OutputFormat:              radigo.AudioFormatMP3,
+		DownloadDir:               "downloads",
+		MaxDownloadingConcurrency: 64,
+		MaxEncodingConcurrency:    2,
+		RequestsPerSecond:         10,
+	}
+	s := NewConfigSummary(asset, "JP13")
+
+	want := ConfigSummary{
+		AreaID:                    "JP13",
+		Stations:                  2,
+		Rules:                     1,
+		OutputFormat:              radigo.AudioFormatMP3,
+		Home:                      home,
+		DownloadDir:               filepath.Join(home, "downloads"),
+		MaxDownloadingConcurrency: 64,
+		MaxEncodingConcurrency:    2,
+		RequestsPerSecond:         10,
+	}
+	if s != want {
+		t.Errorf("NewConfigSummary => %+v, want %+v", s, want)
+	}
+
+	for _, kv := range []string{"area-id=JP13", "stations=2", "rules=1", "file-format=mp3", "requests-per-second=10", "read-only=false"} {
+		if !strings.Contains(s.String(), kv) {
+			t.Errorf("String() => %s, want to contain %s", s, kv)
+		}
+	}
+}
+
+func TestEmitConfigSummary_WithEmitter(t *testing.T) {
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("eventEmitter"), emitter)
+
+	EmitConfigSummary(ctx, ConfigSummary{AreaID: "JP13"})
+
+	if len(emitter.configSummaries) != 1 {
+		t.Fatalf("Expected 1 config summary event, got %d", len(emitter.configSummaries))
+	}
+	if emitter.configSummaries[0].AreaID != "JP13" {
+		t.Errorf("Expected area JP13, got %s", emitter.configSummaries[0].AreaID)
+	}
+}
+
+func TestEmitConfigSummary_WithoutEmitter(t *testing.T) {
+	t.Parallel()
+	EmitConfigSummary(context.Background(), ConfigSummary{AreaID: "JP13"})
+}
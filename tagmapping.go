@@ -0,0 +1,108 @@
+package radikron
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// The tags of the outputs which the tag templates can set
+const (
+	TagTitle       = "title"
+	TagArtist      = "artist"
+	TagAlbum       = "album"
+	TagAlbumArtist = "album-artist"
+	TagGenre       = "genre"
+	TagYear        = "year"
+	TagComment     = "comment"
+	TagTrack       = "track"
+)
+
+// tagData is the data of the tag templates: the program fields (e.g., {{.Title}}, {{.Pfm}}, {{.RuleName}})
+// with the broadcast {{.Date}} and {{.Year}}, and the output {{.FileName}}
+type tagData struct {
+	*Prog
+	Date     string
+	Year     string
+	FileName string
+}
+
+// SetTagTemplates sets the templates of the tags (e.g., "title": "{{.Title}} {{.Date}}")
+// overriding the default tags of the asset's outputs
+func (a *Asset) SetTagTemplates(templates map[string]string) error {
+	parsed, err := parseTagTemplates(templates)
+	if err != nil {
+		return err
+	}
+	a.tagTemplates = parsed
+	return nil
+}
+
+// ValidateTagTemplates returns an error if a tag is unknown or its template does not parse
+func ValidateTagTemplates(templates map[string]string) error {
+	_, err := parseTagTemplates(templates)
+	return err
+}
+
+func parseTagTemplates(templates map[string]string) (map[string]*template.Template, error) {
+	parsed := make(map[string]*template.Template, len(templates))
+	for tag, text := range templates {
+		switch tag {
+		case TagTitle, TagArtist, TagAlbum, TagAlbumArtist, TagGenre, TagYear, TagComment, TagTrack:
+		default:
+			return nil, fmt.Errorf("unknown tag: %s", tag)
+		}
+		t, err := template.New(tag).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of the %s tag: %w", tag, err)
+		}
+		parsed[tag] = t
+	}
+	return parsed, nil
+}
+
+// programTags returns the tags of the output, the defaults overridden by the tag templates of the asset
+func (a *Asset) programTags(output *radigo.OutputConfig, prog *Prog) (map[string]string, error) {
+	data := tagData{Prog: prog, FileName: output.FileBaseName}
+	if ft, err := ParseDatetime(prog.Ft); err == nil {
+		data.Date = ft.Format(time.DateOnly)
+		data.Year = ft.Format("2006")
+	} else if len(prog.Ft) >= 4 {
+		data.Year = prog.Ft[:4]
+	}
+
+	tags := map[string]string{
+		TagTitle:       output.FileBaseName,
+		TagArtist:      prog.Pfm,
+		TagAlbum:       prog.Title,
+		TagAlbumArtist: prog.RuleName,
+		TagYear:        data.Year,
+		TagComment:     prog.Info,
+	}
+	if prog.Episode > 0 {
+		tags[TagTrack] = strconv.Itoa(prog.Episode)
+	}
+
+	var tagTemplates map[string]*template.Template
+	if a != nil {
+		tagTemplates = a.tagTemplates
+	}
+	names := make([]string, 0, len(tagTemplates))
+	for tag := range tagTemplates {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	for _, tag := range names {
+		var sb strings.Builder
+		if err := tagTemplates[tag].Execute(&sb, data); err != nil {
+			return nil, fmt.Errorf("failed to render the %s tag: %w", tag, err)
+		}
+		tags[tag] = sb.String()
+	}
+	return tags, nil
+}
@@ -0,0 +1,114 @@
+package radikron
+
+import (
+	"os"
+	"testing"
+
+	"github.com/bogem/id3v2"
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestProgramTags(t *testing.T) {
+	asset := &Asset{}
+	output := &radigo.OutputConfig{FileBaseName: "2023-06-05-1300_FMT_Test", FileFormat: radigo.AudioFormatAAC}
+	prog := &Prog{StationID: "FMT", Title: "Test", Pfm: "Host", Info: "info", Ft: "20230605130000", RuleName: "rule"}
+
+	tags, err := asset.programTags(output, prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags[TagTitle] != output.FileBaseName || tags[TagAlbumArtist] != "rule" || tags[TagYear] != "2023" || tags[TagGenre] != "" {
+		t.Errorf("unexpected default tags: %v", tags)
+	}
+
+	if err := asset.SetTagTemplates(map[string]string{
+		TagTitle:       "{{.Title}} {{.Date}}",
+		TagAlbumArtist: "{{.StationID}}",
+		TagGenre:       "Radio",
+		TagComment:     "",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tags, err = asset.programTags(output, prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		TagTitle:       "Test 2023-06-05",
+		TagArtist:      "Host",
+		TagAlbum:       "Test",
+		TagAlbumArtist: "FMT",
+		TagGenre:       "Radio",
+		TagYear:        "2023",
+		TagComment:     "",
+	}
+	for tag, value := range want {
+		if tags[tag] != value {
+			t.Errorf("expected %s %q, got %q", tag, value, tags[tag])
+		}
+	}
+}
+
+func TestSetTagTemplates_Invalid(t *testing.T) {
+	asset := &Asset{}
+	for _, templates := range []map[string]string{
+		{"composer": "{{.Pfm}}"},
+		{TagTitle: "{{.Title"},
+	} {
+		if err := asset.SetTagTemplates(templates); err == nil {
+			t.Errorf("expected an error for %v", templates)
+		}
+	}
+	// an unknown field fails on rendering
+	if err := asset.SetTagTemplates(map[string]string{TagTitle: "{{.Season}}"}); err != nil {
+		t.Fatal(err)
+	}
+	output := &radigo.OutputConfig{FileBaseName: "test", FileFormat: radigo.AudioFormatAAC}
+	if _, err := asset.programTags(output, &Prog{Ft: "20230605130000"}); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestWriteID3Tag_TagTemplates(t *testing.T) {
+	asset := &Asset{}
+	if err := asset.SetTagTemplates(map[string]string{TagTitle: "{{.Title}} {{.Date}}", TagGenre: "Radio"}); err != nil {
+		t.Fatal(err)
+	}
+	output := newOutputConfigFromPath(t.TempDir(), "test", radigo.AudioFormatAAC)
+	if err := os.WriteFile(output.AbsPath(), make([]byte, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	prog := &Prog{StationID: "FMT", Title: "Test", Ft: "20230605130000"}
+	if err := writeID3Tag(asset, output, prog); err != nil {
+		t.Fatalf("writeID3Tag failed: %v", err)
+	}
+
+	tag, err := id3v2.Open(output.AbsPath(), id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tag.Close()
+	if tag.Title() != "Test 2023-06-05" || tag.Genre() != "Radio" {
+		t.Errorf("expected the templated tags, got title %q genre %q", tag.Title(), tag.Genre())
+	}
+}
+
+func TestWriteID3Tag_Episode(t *testing.T) {
+	output := newOutputConfigFromPath(t.TempDir(), "test", radigo.AudioFormatAAC)
+	if err := os.WriteFile(output.AbsPath(), make([]byte, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	prog := &Prog{StationID: "FMT", Title: "Test", Ft: "20230605130000", RuleName: "rule", Episode: 7}
+	if err := writeID3Tag(nil, output, prog); err != nil {
+		t.Fatalf("writeID3Tag failed: %v", err)
+	}
+
+	tag, err := id3v2.Open(output.AbsPath(), id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tag.Close()
+	if got := tag.GetTextFrame(tag.CommonID("Track number/Position in set")).Text; got != "7" {
+		t.Errorf("expected the episode as the track number, got %q", got)
+	}
+}
@@ -0,0 +1,177 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// throttleNow returns the time to pick the throttle window at
+var throttleNow = time.Now
+
+// throttleWatcher re-applies the limits of the throttle schedule of its latest asset as the time of day changes
+type throttleWatcher struct {
+	mu    sync.Mutex
+	asset *Asset
+	// active is the window applied last, "" for the configured limits
+	active string
+	once   sync.Once
+}
+
+// ThrottleWindow overrides the download limits during a time of day in JST,
+// e.g., to limit the bandwidth during the work hours and download at full speed at night
+type ThrottleWindow struct {
+	// From and To are the minutes from midnight; the window wraps around midnight if To is before From
+	From int
+	To   int
+	// MaxDownloadingConcurrency during the window, 0 to keep the configured value
+	MaxDownloadingConcurrency int
+	// RequestsPerSecond during the window, 0 to keep the configured value
+	RequestsPerSecond float64
+}
+
+// ParseThrottleWindow returns the window from the "HH:MM" times in JST
+func ParseThrottleWindow(from, to string, maxDownloadingConcurrency int, requestsPerSecond float64) (ThrottleWindow, error) {
+	w := ThrottleWindow{
+		MaxDownloadingConcurrency: maxDownloadingConcurrency,
+		RequestsPerSecond:         requestsPerSecond,
+	}
+	var err error
+	if w.From, err = parseClock(from); err != nil {
+		return w, err
+	}
+	if w.To, err = parseClock(to); err != nil {
+		return w, err
+	}
+	if w.From == w.To {
+		return w, fmt.Errorf("empty window: %s-%s", from, to)
+	}
+	if maxDownloadingConcurrency < 0 {
+		return w, fmt.Errorf("max-downloading-concurrency must not be negative: %d", maxDownloadingConcurrency)
+	}
+	if requestsPerSecond < 0 {
+		return w, fmt.Errorf("requests-per-second must not be negative: %v", requestsPerSecond)
+	}
+	return w, nil
+}
+
+// parseClock returns the minutes from midnight of "HH:MM"
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s': %w", s, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// String returns the window as "HH:MM-HH:MM"
+func (w ThrottleWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.From/60, w.From%60, w.To/60, w.To%60)
+}
+
+// Contains returns true if t in JST falls in the window
+func (w ThrottleWindow) Contains(t time.Time) bool {
+	t = t.In(Location)
+	m := t.Hour()*60 + t.Minute()
+	if w.From < w.To {
+		return w.From <= m && m < w.To
+	}
+	return w.From <= m || m < w.To
+}
+
+// End returns the first end of the window after t
+func (w ThrottleWindow) End(t time.Time) time.Time {
+	t = t.In(Location)
+	end := time.Date(t.Year(), t.Month(), t.Day(), 0, w.To, 0, 0, Location)
+	if !end.After(t) {
+		end = end.Add(OneDay * time.Hour)
+	}
+	return end
+}
+
+// ActiveThrottleWindow returns the first window of the throttle schedule containing t, or nil
+func (a *Asset) ActiveThrottleWindow(t time.Time) *ThrottleWindow {
+	for i := range a.ThrottleSchedule {
+		if a.ThrottleSchedule[i].Contains(t) {
+			return &a.ThrottleSchedule[i]
+		}
+	}
+	return nil
+}
+
+// throttledLimits returns the downloading concurrency and the request rate in effect at t
+func (a *Asset) throttledLimits(t time.Time) (maxDownloadingConcurrency int, requestsPerSecond float64) {
+	maxDownloadingConcurrency = a.MaxDownloadingConcurrency
+	requestsPerSecond = a.RequestsPerSecond
+	if w := a.ActiveThrottleWindow(t); w != nil {
+		if w.MaxDownloadingConcurrency > 0 {
+			maxDownloadingConcurrency = w.MaxDownloadingConcurrency
+		}
+		if w.RequestsPerSecond > 0 {
+			requestsPerSecond = w.RequestsPerSecond
+		}
+	}
+	return maxDownloadingConcurrency, requestsPerSecond
+}
+
+// WatchThrottleSchedule applies the limits of the asset's throttle schedule as the time of day changes,
+// resizing the running downloads' pool and rate limiter in place.
+// The watcher carried over by Inherit watches the asset of each reload instead of the previous one.
+func WatchThrottleSchedule(asset *Asset) {
+	if asset == nil {
+		return
+	}
+	asset.mu.Lock()
+	if asset.throttle == nil {
+		asset.throttle = &throttleWatcher{}
+	}
+	w := asset.throttle
+	asset.mu.Unlock()
+
+	w.mu.Lock()
+	w.asset = asset
+	w.mu.Unlock()
+	w.apply()
+
+	if len(asset.ThrottleSchedule) == 0 {
+		return
+	}
+	w.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for range ticker.C {
+				w.apply()
+			}
+		}()
+	})
+}
+
+// apply re-applies the limits of the watched asset if the active window changed
+func (w *throttleWatcher) apply() {
+	now := throttleNow()
+	w.mu.Lock()
+	asset := w.asset
+	window := ""
+	if tw := asset.ActiveThrottleWindow(now); tw != nil {
+		window = tw.String()
+	}
+	changed := window != w.active
+	w.active = window
+	w.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	InitSemaphores(asset)
+	InitRateLimiter(asset)
+
+	maxDownloadingConcurrency, requestsPerSecond := asset.throttledLimits(now)
+	if window == "" {
+		window = "none"
+	}
+	emitLogMessage(context.Background(), "info", fmt.Sprintf(
+		"throttle window %s: max-downloading-concurrency=%d requests-per-second=%g",
+		window, maxDownloadingConcurrency, requestsPerSecond))
+}
@@ -0,0 +1,126 @@
+package radikron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseThrottleWindow(t *testing.T) {
+	w, err := ParseThrottleWindow("09:00", "18:30", 4, 2)
+	if err != nil {
+		t.Fatalf("ParseThrottleWindow failed: %v", err)
+	}
+	if w.From != 9*60 || w.To != 18*60+30 {
+		t.Errorf("expected 540-1110, got %d-%d", w.From, w.To)
+	}
+	if w.String() != "09:00-18:30" {
+		t.Errorf("expected 09:00-18:30, got %s", w)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		maxDL    int
+		rps      float64
+	}{
+		{"invalid from", "9am", "18:00", 0, 0},
+		{"invalid to", "09:00", "25:00", 0, 0},
+		{"empty", "09:00", "09:00", 0, 0},
+		{"negative concurrency", "09:00", "18:00", -1, 0},
+		{"negative rate", "09:00", "18:00", 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseThrottleWindow(tt.from, tt.to, tt.maxDL, tt.rps); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestThrottleWindow_Contains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, 6, 5, hour, minute, 0, 0, Location)
+	}
+	work, _ := ParseThrottleWindow("09:00", "18:00", 4, 0)
+	night, _ := ParseThrottleWindow("23:00", "05:00", 0, 1)
+
+	tests := []struct {
+		name string
+		w    ThrottleWindow
+		t    time.Time
+		want bool
+	}{
+		{"start", work, at(9, 0), true},
+		{"inside", work, at(12, 30), true},
+		{"end", work, at(18, 0), false},
+		{"before", work, at(8, 59), false},
+		{"wrap before midnight", night, at(23, 30), true},
+		{"wrap after midnight", night, at(4, 59), true},
+		{"wrap outside", night, at(12, 0), false},
+		{"in UTC", work, time.Date(2023, 6, 5, 3, 0, 0, 0, time.UTC), true}, // 12:00 JST
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.w.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsset_ThrottledLimits(t *testing.T) {
+	work, _ := ParseThrottleWindow("09:00", "18:00", 4, 0)
+	asset := &Asset{
+		MaxDownloadingConcurrency: 64,
+		RequestsPerSecond:         10,
+		ThrottleSchedule:          []ThrottleWindow{work},
+	}
+
+	maxDL, rps := asset.throttledLimits(time.Date(2023, 6, 5, 12, 0, 0, 0, Location))
+	if maxDL != 4 || rps != 10 {
+		t.Errorf("expected 4 and the configured 10 during the window, got %d and %g", maxDL, rps)
+	}
+	maxDL, rps = asset.throttledLimits(time.Date(2023, 6, 5, 20, 0, 0, 0, Location))
+	if maxDL != 64 || rps != 10 {
+		t.Errorf("expected the configured limits outside the window, got %d and %g", maxDL, rps)
+	}
+	if w := asset.ActiveThrottleWindow(time.Date(2023, 6, 5, 20, 0, 0, 0, Location)); w != nil {
+		t.Errorf("expected no active window, got %s", w)
+	}
+}
+
+func TestApplyThrottleSchedule(t *testing.T) {
+	now := time.Date(2023, 6, 5, 12, 0, 0, 0, Location)
+	origNow := throttleNow
+	throttleNow = func() time.Time { return now }
+	t.Cleanup(func() { throttleNow = origNow })
+
+	work, _ := ParseThrottleWindow("09:00", "18:00", 4, 2)
+	asset := &Asset{
+		MaxDownloadingConcurrency: 64,
+		RequestsPerSecond:         10,
+		ThrottleSchedule:          []ThrottleWindow{work},
+	}
+	InitSemaphores(asset)
+	InitRateLimiter(asset)
+	w := &throttleWatcher{asset: asset}
+
+	w.apply()
+	if got := asset.DownloadPool.Stats().Size; got != 4 {
+		t.Errorf("expected the downloading concurrency 4 during the window, got %d", got)
+	}
+	if got := asset.requestLimiter.rate; got != 2 {
+		t.Errorf("expected the rate 2 during the window, got %g", got)
+	}
+
+	// the window ends
+	now = time.Date(2023, 6, 5, 18, 0, 0, 0, Location)
+	w.apply()
+	if got := asset.DownloadPool.Stats().Size; got != 64 {
+		t.Errorf("expected the configured downloading concurrency after the window, got %d", got)
+	}
+	if got := asset.requestLimiter.rate; got != 10 {
+		t.Errorf("expected the configured rate after the window, got %g", got)
+	}
+}
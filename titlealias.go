@@ -0,0 +1,123 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// TitleAliases maps the old titles of the renamed programs to their new titles, so that the dedupe,
+// the slot history, and the rule matching treat the old and new titles as the same series
+type TitleAliases map[string]string
+
+// NewTitleAliases returns the TitleAliases of the old→new titles, or an error if they are invalid
+func NewTitleAliases(aliases map[string]string) (TitleAliases, error) {
+	if err := ValidateTitleAliases(aliases); err != nil {
+		return nil, err
+	}
+	copied := make(TitleAliases, len(aliases))
+	for from, to := range aliases {
+		if from != to {
+			copied[from] = to
+		}
+	}
+	return copied, nil
+}
+
+// SetTitleAliases sets the old→new titles of the renamed programs of the asset and its rules
+func (a *Asset) SetTitleAliases(aliases map[string]string) error {
+	ta, err := NewTitleAliases(aliases)
+	if err != nil {
+		return err
+	}
+	a.TitleAliases = ta
+	a.Rules.SetTitleAliases(ta)
+	return nil
+}
+
+// SetTitleAliases makes the rules match the old and new titles of the renamed programs alike
+func (rs Rules) SetTitleAliases(aliases TitleAliases) {
+	for _, r := range rs {
+		r.titleAliases = aliases
+	}
+}
+
+// ValidateTitleAliases returns an error if a title is empty or the renames loop back
+func ValidateTitleAliases(aliases map[string]string) error {
+	for from, to := range aliases {
+		if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return fmt.Errorf("title alias must have both titles: '%s' -> '%s'", from, to)
+		}
+	}
+	// a cycle has no canonical title
+	renames := make(map[string]string, len(aliases))
+	for from, to := range aliases {
+		if from != to {
+			renames[from] = to
+		}
+	}
+	for from, to := range renames {
+		seen := map[string]bool{from: true}
+		for title, ok := to, true; ok; title, ok = renames[title] {
+			if seen[title] {
+				return fmt.Errorf("title alias '%s' renames back to itself", from)
+			}
+			seen[title] = true
+		}
+	}
+	return nil
+}
+
+// Canonical returns the latest title of the series, following the renames
+func (ta TitleAliases) Canonical(title string) string {
+	for i := 0; i <= len(ta); i++ {
+		next, ok := ta[title]
+		if !ok {
+			break
+		}
+		title = next
+	}
+	return title
+}
+
+// variants returns all the titles of the series, the canonical one first
+func (ta TitleAliases) variants(title string) []string {
+	canonical := ta.Canonical(title)
+	variants := []string{canonical}
+	for from := range ta {
+		if ta.Canonical(from) == canonical {
+			variants = append(variants, from)
+		}
+	}
+	return variants
+}
+
+// findAliasedDuplicate returns the path of the program downloaded under an old title of the series,
+// looking in the target dir, the configured folders, and the default download dir, or the storage of the asset
+func findAliasedDuplicate(ctx context.Context, asset *Asset, prog *Prog, startTime time.Time, output *radigo.OutputConfig) (string, bool) {
+	configuredFolders := collectConfiguredFolders(outputFolder(asset, prog), asset.Rules)
+	defaultPath, err := getRadicronPath(asset.outputDir())
+	if err != nil {
+		return "", false
+	}
+	for _, title := range asset.TitleAliases.variants(prog.Title)[1:] {
+		fileBaseName, err := asset.outputFileBaseName(prog, title, startTime)
+		if err != nil {
+			return "", false
+		}
+		for _, dir := range []string{output.DirFullPath, defaultPath} {
+			existing := newOutputConfigFromPath(dir, fileBaseName, output.FileFormat)
+			if existing.IsExist() || existsInStorage(ctx, existing.AbsPath()) {
+				return existing.AbsPath(), true
+			}
+		}
+		if exists, existingPath := checkConfiguredFoldersForDuplicate(
+			ctx, configuredFolders, asset.outputDir(), fileBaseName, output.FileFormat, ""); exists {
+			return existingPath, true
+		}
+	}
+	return "", false
+}
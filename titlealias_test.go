@@ -0,0 +1,110 @@
+package radikron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTitleAliases returns the title aliases for the test
+func newTitleAliases(t *testing.T, aliases map[string]string) TitleAliases {
+	t.Helper()
+	ta, err := NewTitleAliases(aliases)
+	if err != nil {
+		t.Fatalf("NewTitleAliases failed: %v", err)
+	}
+	return ta
+}
+
+func TestValidateTitleAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"rename", map[string]string{"A": "B"}, false},
+		{"chain", map[string]string{"A": "B", "B": "C"}, false},
+		{"same title", map[string]string{"A": "A", "B": "A"}, false},
+		{"empty", map[string]string{"A": " "}, true},
+		{"cycle", map[string]string{"A": "B", "B": "C", "C": "A"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateTitleAliases(tt.aliases); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTitleAliases() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTitleAliases_Canonical(t *testing.T) {
+	aliases := newTitleAliases(t, map[string]string{"Old": "Mid", "Mid": "New"})
+
+	for _, title := range []string{"Old", "Mid", "New"} {
+		if got := aliases.Canonical(title); got != "New" {
+			t.Errorf("Canonical(%s) = %s, want New", title, got)
+		}
+	}
+	if got := aliases.Canonical("Other"); got != "Other" {
+		t.Errorf("expected an unrelated title unchanged, got %s", got)
+	}
+	if got := TitleAliases(nil).Canonical("Old"); got != "Old" {
+		t.Errorf("expected the title unchanged without aliases, got %s", got)
+	}
+	if got := aliases.variants("Mid"); len(got) != 3 || got[0] != "New" {
+		t.Errorf("expected the 3 titles with New first, got %v", got)
+	}
+}
+
+func TestRule_MatchTitleAliases(t *testing.T) {
+	oldRule := &Rule{Name: "old", Title: "Old Show"}
+	newRule := &Rule{Name: "new", Title: "New Show"}
+	Rules{oldRule, newRule}.SetTitleAliases(newTitleAliases(t, map[string]string{"Old Show": "New Show"}))
+	for _, title := range []string{"Old Show", "New Show", "New Show 特別編"} {
+		if !oldRule.MatchTitle(title) {
+			t.Errorf("expected the rule with the old title to match %s", title)
+		}
+		if !newRule.MatchTitle(title) {
+			t.Errorf("expected the rule with the new title to match %s", title)
+		}
+	}
+	if oldRule.MatchTitle("Other Show") {
+		t.Error("expected the rule not to match an unrelated title")
+	}
+}
+
+func TestFindAliasedDuplicate(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv(EnvRadicronHome, tmpDir)
+	asset := &Asset{DownloadDir: "downloads", Rules: Rules{{Name: "r", Title: "New Show", Folder: "shows"}}}
+	if err := asset.SetTitleAliases(map[string]string{"Old Show": "New Show"}); err != nil {
+		t.Fatalf("SetTitleAliases failed: %v", err)
+	}
+	prog := &Prog{StationID: "TBS", Title: "New Show", Ft: "20230605010000", RuleFolder: "shows"}
+	startTime := time.Date(2023, 6, 5, 1, 0, 0, 0, Location)
+	output, err := newOutputConfig("2023-06-05-0100_TBS_New Show", "aac", "downloads", "shows")
+	if err != nil {
+		t.Fatalf("newOutputConfig failed: %v", err)
+	}
+
+	if _, ok := findAliasedDuplicate(context.Background(), asset, prog, startTime, output); ok {
+		t.Error("expected no duplicate before the download")
+	}
+
+	// downloaded under the old title before the rename
+	dir := filepath.Join(tmpDir, "downloads")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create the downloads dir: %v", err)
+	}
+	old := filepath.Join(dir, "2023-06-05-0100_TBS_Old Show.aac")
+	if err := os.WriteFile(old, []byte("audio"), 0o600); err != nil {
+		t.Fatalf("failed to create the old file: %v", err)
+	}
+	path, ok := findAliasedDuplicate(context.Background(), asset, prog, startTime, output)
+	if !ok || path != old {
+		t.Errorf("expected the file under the old title, got %s, %v", path, ok)
+	}
+}
@@ -0,0 +1,145 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// activeAACDirs counts the downloads of this process using each aac dir
+	activeAACDirs   = map[string]int{}
+	activeAACDirsMu sync.Mutex
+
+	// tmpCleanupAsset is the latest asset watched for its TempCleanupInterval
+	tmpCleanupAsset *Asset
+	tmpCleanupMu    sync.Mutex
+	tmpCleanupOnce  sync.Once
+)
+
+// releaseAACDir marks the aac dir no longer used by the download
+func releaseAACDir(aacDir string) {
+	activeAACDirsMu.Lock()
+	defer activeAACDirsMu.Unlock()
+	if activeAACDirs[aacDir] <= 1 {
+		delete(activeAACDirs, aacDir)
+		return
+	}
+	activeAACDirs[aacDir]--
+}
+
+// CleanupTempDirs removes the aac dirs and their segment manifests in RADICRON_HOME/tmp left by crashed runs,
+// keeping the ones used by the downloads in progress and the ones of the queued programs to resume.
+// Returns the number of the dirs removed and the bytes freed.
+func CleanupTempDirs(ctx context.Context) (removed int, freed int64, err error) {
+	tmp, err := getRadicronPath("tmp")
+	if err != nil {
+		return 0, 0, err
+	}
+	entries, err := os.ReadDir(tmp)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read the tmp dir: %w", err)
+	}
+
+	// the queued programs resume from their aac dirs
+	queued, err := LoadQueue()
+	if err != nil {
+		return 0, 0, err
+	}
+	keep := map[string]bool{}
+	for _, p := range queued {
+		if p.ID != "" {
+			keep["aac-"+programLockKey(p)] = true
+		}
+	}
+
+	activeAACDirsMu.Lock()
+	defer activeAACDirsMu.Unlock()
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), SegmentManifestExt)
+		if !strings.HasPrefix(name, "aac") || keep[name] {
+			continue
+		}
+		path := filepath.Join(tmp, e.Name())
+		if activeAACDirs[filepath.Join(tmp, name)] > 0 {
+			continue
+		}
+		size := diskUsage(path)
+		if err := os.RemoveAll(path); err != nil {
+			emitLogMessage(ctx, "error", fmt.Sprintf("failed to remove %s: %v", path, err))
+			continue
+		}
+		freed += size
+		if e.IsDir() {
+			removed++
+		}
+	}
+	return removed, freed, nil
+}
+
+// diskUsage returns the total size of the files under path
+func diskUsage(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// WatchTempCleanup cleans up the tmp dir at the TempCleanupInterval of the latest asset, if set
+func WatchTempCleanup(asset *Asset) {
+	if asset == nil {
+		return
+	}
+	tmpCleanupMu.Lock()
+	tmpCleanupAsset = asset
+	tmpCleanupMu.Unlock()
+
+	if asset.TempCleanupInterval <= 0 {
+		return
+	}
+	tmpCleanupOnce.Do(func() {
+		go func() {
+			last := time.Now()
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				tmpCleanupMu.Lock()
+				interval := tmpCleanupAsset.TempCleanupInterval
+				tmpCleanupMu.Unlock()
+				if interval <= 0 || now.Sub(last) < interval {
+					continue
+				}
+				last = now
+				logTempCleanup(context.Background())
+			}
+		}()
+	})
+}
+
+// logTempCleanup cleans up the tmp dir and reports the result
+func logTempCleanup(ctx context.Context) {
+	removed, freed, err := CleanupTempDirs(ctx)
+	if err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to clean up the tmp dir: %v", err))
+		return
+	}
+	if removed > 0 {
+		emitLogMessage(ctx, "info", fmt.Sprintf("removed %d stale aac dirs (%.1f MB) from the tmp dir",
+			removed, float64(freed)/Kilobytes/Kilobytes))
+	}
+}
@@ -0,0 +1,74 @@
+package radikron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanupTempDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(EnvRadicronHome, home)
+	tmp := filepath.Join(home, "tmp")
+
+	files := map[string]int{
+		"aac123456/segment1.aac": 1000, // crashed download without a program ID
+		"aac-stale/segment1.aac": 2000, // crashed download no longer queued
+		"aac-stale.json":         10,
+		"aac-queued/segment.aac": 3000, // kept to resume
+		"aac-queued.json":        10,
+		"aac-orphan.json":        10, // manifest without its dir
+		"other/file":             10, // not an aac dir
+	}
+	for name, size := range files {
+		path := filepath.Join(tmp, name)
+		if err := os.MkdirAll(filepath.Dir(path), DirPermissions); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enqueueProgram(context.Background(), &Prog{ID: "queued", StationID: "FMT", Ft: "20230605130000"})
+
+	// in use by a download in progress
+	active, _, err := prepareAACDir(&Prog{ID: "active"}, nil)
+	if err != nil {
+		t.Fatalf("prepareAACDir failed: %v", err)
+	}
+
+	removed, freed, err := CleanupTempDirs(context.Background())
+	if err != nil {
+		t.Fatalf("CleanupTempDirs failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 dirs removed, got %d", removed)
+	}
+	if freed != 3020 {
+		t.Errorf("expected 3020 bytes freed, got %d", freed)
+	}
+	for _, name := range []string{"aac123456", "aac-stale", "aac-stale.json", "aac-orphan.json"} {
+		if _, err := os.Stat(filepath.Join(tmp, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s removed", name)
+		}
+	}
+	for _, name := range []string{"aac-queued", "aac-queued.json", "other", filepath.Base(active)} {
+		if _, err := os.Stat(filepath.Join(tmp, name)); err != nil {
+			t.Errorf("expected %s kept: %v", name, err)
+		}
+	}
+
+	// removed once the download finishes without resuming
+	releaseAACDir(active)
+	if removed, _, err = CleanupTempDirs(context.Background()); err != nil || removed != 1 {
+		t.Errorf("expected the released dir removed, got %d, %v", removed, err)
+	}
+}
+
+func TestCleanupTempDirs_NoTmpDir(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	if removed, _, err := CleanupTempDirs(context.Background()); err != nil || removed != 0 {
+		t.Errorf("expected nothing to clean up, got %d, %v", removed, err)
+	}
+}
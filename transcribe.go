@@ -0,0 +1,113 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+// TranscriptExt is the extension of the transcript saved next to the output
+const TranscriptExt = ".txt"
+
+// transcribeMu runs one transcription at a time not to flood the Whisper server with the hours of audio
+var transcribeMu sync.Mutex
+
+// ValidateTranscriptionURL returns an error if the URL of the Whisper server is not an http(s) URL
+func ValidateTranscriptionURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid transcription-url %q: %w", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported transcription-url scheme %q: use http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid transcription-url %q: missing host", rawURL)
+	}
+	return nil
+}
+
+// transcribeOutput saves the transcript of the output from the Whisper server of the asset, if any.
+// The transcript is best-effort like the sidecar files: the output is kept even if it fails.
+func transcribeOutput(ctx context.Context, prog *Prog, output *radigo.OutputConfig) {
+	asset := GetAsset(ctx)
+	// the outputs saved to a remote storage are not kept to transcribe
+	if asset == nil || asset.TranscriptionURL == "" || asset.Storage != nil {
+		return
+	}
+	transcribeMu.Lock()
+	defer transcribeMu.Unlock()
+
+	transcript := sidecarPath(output.AbsPath(), TranscriptExt)
+	if err := requestTranscript(ctx, asset.TranscriptionURL, output.AbsPath(), transcript); err != nil {
+		emitLogMessage(ctx, "error", fmt.Sprintf("failed to transcribe [%s]%s: %v", prog.StationID, prog.Title, err))
+		return
+	}
+	emitLogMessage(ctx, "info", fmt.Sprintf("transcribed [%s]%s to %s", prog.StationID, prog.Title, transcript))
+}
+
+// requestTranscript posts the audio to the transcription endpoint (e.g., "http://localhost:8080/inference"
+// of whisper.cpp or "/v1/audio/transcriptions" of an OpenAI-compatible server) and writes the text to dest
+func requestTranscript(ctx context.Context, endpoint, audioPath, dest string) error {
+	audio, err := os.Open(audioPath)
+	if err != nil {
+		return err
+	}
+	defer audio.Close()
+
+	// stream the audio rather than buffering the whole program in memory
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeTranscriptionForm(form, audio))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := httpClient.Do(req) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the transcription server returned %s", resp.Status)
+	}
+	text, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if len(text) == 0 {
+		return errors.New("empty transcript")
+	}
+	return writeFileAtomic(dest, text, OutputFilePermissions)
+}
+
+func writeTranscriptionForm(form *multipart.Writer, audio *os.File) error {
+	if err := form.WriteField("response_format", "text"); err != nil {
+		return err
+	}
+	part, err := form.CreateFormFile("file", filepath.Base(audio.Name()))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return err
+	}
+	return form.Close()
+}
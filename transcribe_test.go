@@ -0,0 +1,94 @@
+package radikron
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestTranscribeOutput(t *testing.T) {
+	var gotFormat, gotFile, gotAudio string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotFormat = r.FormValue("response_format")
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		audio, _ := io.ReadAll(file)
+		gotFile, gotAudio = header.Filename, string(audio)
+		_, _ = io.WriteString(w, "hello from the show\n")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	output := &radigo.OutputConfig{DirFullPath: dir, FileBaseName: "2023-06-05-1300_FMT_Test", FileFormat: radigo.AudioFormatMP3}
+	if err := os.WriteFile(output.AbsPath(), []byte("audio"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	prog := &Prog{StationID: "FMT", Title: "Test"}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{TranscriptionURL: srv.URL})
+
+	transcribeOutput(ctx, prog, output)
+
+	if gotFormat != "text" || gotFile != "2023-06-05-1300_FMT_Test.mp3" || gotAudio != "audio" {
+		t.Errorf("unexpected request: response_format=%q file=%q audio=%q", gotFormat, gotFile, gotAudio)
+	}
+	transcript, err := os.ReadFile(filepath.Join(dir, "2023-06-05-1300_FMT_Test.txt"))
+	if err != nil {
+		t.Fatalf("expected the transcript: %v", err)
+	}
+	if string(transcript) != "hello from the show\n" {
+		t.Errorf("unexpected transcript: %q", transcript)
+	}
+	info, err := os.Stat(filepath.Join(dir, "2023-06-05-1300_FMT_Test.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0044 == 0 {
+		t.Errorf("expected the transcript readable by the others, got %v", info.Mode().Perm())
+	}
+}
+
+func TestTranscribeOutput_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "model not loaded", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	output := &radigo.OutputConfig{DirFullPath: dir, FileBaseName: "test", FileFormat: radigo.AudioFormatAAC}
+	if err := os.WriteFile(output.AbsPath(), []byte("audio"), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{TranscriptionURL: srv.URL})
+
+	transcribeOutput(ctx, &Prog{StationID: "FMT", Title: "Test"}, output)
+
+	if _, err := os.Stat(filepath.Join(dir, "test.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no transcript on a server error, got %v", err)
+	}
+	if _, err := os.Stat(output.AbsPath()); err != nil {
+		t.Errorf("expected the output to be kept: %v", err)
+	}
+}
+
+func TestValidateTranscriptionURL(t *testing.T) {
+	for _, u := range []string{"", "http://localhost:8080/inference", "https://whisper.example.com/v1/audio/transcriptions"} {
+		if err := ValidateTranscriptionURL(u); err != nil {
+			t.Errorf("expected %q to be valid: %v", u, err)
+		}
+	}
+	for _, u := range []string{"localhost:8080", "ftp://localhost/inference", "http://"} {
+		if err := ValidateTranscriptionURL(u); err == nil {
+			t.Errorf("expected %q to be invalid", u)
+		}
+	}
+}
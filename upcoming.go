@@ -0,0 +1,36 @@
+package radikron
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// notifiedPrograms holds the upcoming programs already notified by this process
+	notifiedPrograms   = map[string]bool{}
+	notifiedProgramsMu sync.Mutex
+)
+
+// ShareURL returns the radiko share link to the program,
+// which plays it live on air and on timefree afterward
+func ShareURL(prog *Prog) string {
+	return fmt.Sprintf(RadikoShareURL, prog.StationID, prog.Ft)
+}
+
+// notifyUpcomingProgram reports the future program matched by a rule once,
+// with the share link to listen live and the time it will be downloaded
+func notifyUpcomingProgram(ctx context.Context, prog *Prog, downloadTime time.Time) {
+	notifiedProgramsMu.Lock()
+	key := programLockKey(prog)
+	if notifiedPrograms[key] {
+		notifiedProgramsMu.Unlock()
+		return
+	}
+	notifiedPrograms[key] = true
+	notifiedProgramsMu.Unlock()
+
+	emitProgramUpcoming(ctx, prog.StationID, prog.Title, prog.Ft, prog.RuleName,
+		ShareURL(prog), FormatDatetime(downloadTime))
+}
@@ -0,0 +1,70 @@
+package radikron
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestShareURL(t *testing.T) {
+	prog := &Prog{StationID: "FMT", Ft: "20230605130000"}
+	want := "https://radiko.jp/share/?sid=FMT&t=20230605130000"
+	if got := ShareURL(prog); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestDownload_NotifyUpcoming(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	CurrentTime = time.Date(2023, 6, 5, 12, 0, 0, 0, Location)
+
+	newCtx := func(notify bool) (context.Context, *mockEventEmitter) {
+		asset := &Asset{
+			OutputFormat:   radigo.AudioFormatAAC,
+			DownloadDir:    "downloads",
+			Rules:          Rules{},
+			Schedules:      Schedules{},
+			NotifyUpcoming: notify,
+		}
+		emitter := &mockEventEmitter{}
+		ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+		return context.WithValue(ctx, ContextKey("eventEmitter"), emitter), emitter
+	}
+
+	prog := &Prog{
+		ID:        "test-upcoming",
+		StationID: "FMT",
+		Title:     "Test Program",
+		Ft:        "20230605130000",
+		To:        "20230605140000",
+		RuleName:  "test-rule",
+	}
+
+	ctx, emitter := newCtx(false)
+	if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if len(emitter.programUpcoming) != 0 {
+		t.Errorf("expected no upcoming event when disabled, got %d", len(emitter.programUpcoming))
+	}
+
+	ctx, emitter = newCtx(true)
+	for i := 0; i < 2; i++ {
+		if err := Download(ctx, &sync.WaitGroup{}, prog); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+	}
+	if len(emitter.programUpcoming) != 1 {
+		t.Fatalf("expected 1 upcoming event, got %d", len(emitter.programUpcoming))
+	}
+	got := emitter.programUpcoming[0]
+	if got.shareURL != ShareURL(prog) || got.ruleName != "test-rule" {
+		t.Errorf("unexpected upcoming event: %+v", got)
+	}
+	if got.downloadTime != "20230605140500" {
+		t.Errorf("expected the download after the end and the buffer, got %s", got.downloadTime)
+	}
+}
@@ -0,0 +1,45 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errDownloadStalled cancels a download whose segments made no progress for the DownloadStallTimeout
+var errDownloadStalled = errors.New("download stalled")
+
+// watchDownload returns the context of the download canceled with errDownloadStalled
+// once its segments make no progress for the DownloadStallTimeout of the asset (0 never),
+// instead of holding the download slot until the process exits; stop ends the watch
+func watchDownload(ctx context.Context, progress *downloadProgress) (watched context.Context, stop func()) {
+	watched, cancel := context.WithCancelCause(ctx)
+	asset := GetAsset(ctx)
+	if asset == nil || asset.DownloadStallTimeout <= 0 {
+		return watched, func() { cancel(nil) }
+	}
+	timeout := asset.DownloadStallTimeout
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(max(timeout/10, 10*time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-watched.Done():
+				return
+			case <-ticker.C:
+				if progress.idle() >= timeout {
+					cancel(errDownloadStalled)
+					return
+				}
+			}
+		}
+	}()
+	return watched, func() {
+		close(done)
+		cancel(nil)
+	}
+}
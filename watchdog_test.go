@@ -0,0 +1,145 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestWatchDownload(t *testing.T) {
+	prog := &Prog{StationID: "FMT", Title: "Test Program"}
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{})
+		progress := newDownloadProgress(ctx, prog, 2, 0)
+		progress.begin()
+		watched, stop := watchDownload(ctx, progress)
+		defer stop()
+		select {
+		case <-watched.Done():
+			t.Error("the download should never be canceled without a timeout")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("progressing", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{DownloadStallTimeout: 100 * time.Millisecond})
+		progress := newDownloadProgress(ctx, prog, 10, 0)
+		watched, stop := watchDownload(ctx, progress)
+		defer stop()
+		for range 5 {
+			end := progress.begin()
+			time.Sleep(40 * time.Millisecond)
+			progress.add(1)
+			end()
+		}
+		// waiting for a worker is no stall
+		time.Sleep(150 * time.Millisecond)
+		if err := watched.Err(); err != nil {
+			t.Errorf("the progressing download should not be canceled: %v", err)
+		}
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ContextKey("asset"), &Asset{DownloadStallTimeout: 50 * time.Millisecond})
+		progress := newDownloadProgress(ctx, prog, 2, 0)
+		defer progress.begin()()
+		watched, stop := watchDownload(ctx, progress)
+		defer stop()
+		select {
+		case <-watched.Done():
+			if !errors.Is(context.Cause(watched), errDownloadStalled) {
+				t.Errorf("expected errDownloadStalled, got %v", context.Cause(watched))
+			}
+		case <-time.After(time.Second):
+			t.Error("the stalled download should be canceled")
+		}
+	})
+}
+
+func TestDownloadProgram_Stalled(t *testing.T) {
+	testDir := t.TempDir()
+	t.Setenv(EnvRadicronHome, testDir)
+	InitSemaphores(&Asset{})
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".m3u8"):
+			_, _ = w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXTINF:5.0,\n" + server.URL +
+				"/1.aac\n#EXTINF:5.0,\n" + server.URL + "/2.aac\n#EXT-X-ENDLIST\n"))
+		case strings.HasSuffix(r.URL.Path, "/2.aac"):
+			// the connection hangs without an error
+			<-r.Context().Done()
+		default:
+			_, _ = w.Write(make([]byte, 1024))
+		}
+	}))
+	defer server.Close()
+
+	downloadsDir := filepath.Join(testDir, "downloads")
+	if err := os.MkdirAll(downloadsDir, DirPermissions); err != nil {
+		t.Fatalf("Failed to create the downloads dir: %v", err)
+	}
+	output := newOutputConfigFromPath(downloadsDir, "test-output", radigo.AudioFormatAAC)
+	prog := &Prog{
+		ID:        "test-stalled",
+		StationID: "FMT",
+		Title:     "Test Program",
+		Ft:        "20230605130000",
+		M3U8:      server.URL + "/playlist.m3u8",
+	}
+	asset := &Asset{DownloadStallTimeout: 100 * time.Millisecond, MaxProgramAttempts: DefaultMaxProgramAttempts}
+	emitter := &mockEventEmitter{}
+	ctx := context.WithValue(context.Background(), ContextKey("asset"), asset)
+	ctx = context.WithValue(ctx, ContextKey("eventEmitter"), emitter)
+
+	var downloaded *bool
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go downloadProgram(ctx, wg, prog, output, func(ok bool) { downloaded = &ok })
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the stalled download should be canceled")
+	}
+
+	if downloaded == nil || *downloaded {
+		t.Error("the stalled download should finish unsuccessfully")
+	}
+	if len(emitter.downloadStalled) != 1 || emitter.downloadStalled[0].timeout != asset.DownloadStallTimeout {
+		t.Errorf("expected a download stalled event, got %v", emitter.downloadStalled)
+	}
+	if _, err := os.Stat(output.AbsPath()); !os.IsNotExist(err) {
+		t.Error("the stalled download should not be saved")
+	}
+	// the completed segment is kept to resume the download
+	aacDir, err := programAACDir(prog.ID)
+	if err != nil {
+		t.Fatalf("programAACDir failed: %v", err)
+	}
+	if _, err := os.Stat(aacDir + SegmentManifestExt); err != nil {
+		t.Errorf("the segment manifest should be kept: %v", err)
+	}
+	rec, err := recordFailedAttempt(prog, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Attempts != 2 {
+		t.Errorf("the stall should count as a failed attempt, got %d attempts", rec.Attempts-1)
+	}
+}
@@ -0,0 +1,116 @@
+package radikron
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookShutdownTimeout is how long the webhook server waits for the requests in flight on shutdown
+	webhookShutdownTimeout = 5 * time.Second
+	// maxWebhookBodySize is the largest body of the webhooks read, 1 MiB
+	maxWebhookBodySize = 1 << 20
+)
+
+// webhookAuthorized returns true if the request bears the WebhookToken of the asset in its context
+// in the Authorization header ("Bearer <token>") or, for the automations without the headers, in the token parameter
+func webhookAuthorized(r *http.Request) bool {
+	asset := GetAsset(r.Context())
+	if asset == nil || asset.WebhookToken == "" {
+		return false
+	}
+	token := asset.WebhookToken
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		given = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// NewWebhookHandler returns the handler of the inbound webhooks for the automations, e.g., Home Assistant:
+// "POST /hooks/fetch-now" calls fetchNow to fetch the program guides right away, and "POST /hooks/add-rule"
+// queues a one-off recording of the program of the share link in the url field of its JSON or form body,
+// matched against the rules of the asset of current, e.g., StatusAsset
+func NewWebhookHandler(current func() *Asset, fetchNow func()) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/fetch-now", func(w http.ResponseWriter, _ *http.Request) {
+		fetchNow()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("POST /hooks/add-rule", func(w http.ResponseWriter, r *http.Request) {
+		serveAddRule(w, r, fetchNow)
+	})
+	return withAsset(current, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !webhookAuthorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func serveAddRule(w http.ResponseWriter, r *http.Request, fetchNow func()) {
+	var body struct {
+		URL string `json:"url"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxWebhookBodySize)
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err = json.NewDecoder(r.Body).Decode(&body)
+	} else if err = r.ParseForm(); err == nil {
+		body.URL = r.FormValue("url")
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, fmt.Sprintf("invalid body: %v", err), status)
+		return
+	}
+	if body.URL == "" {
+		http.Error(w, "no url", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	prog, err := QueueShareURL(ctx, body.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	emitLogMessage(ctx, "info", fmt.Sprintf("queued [%s]%s (%s) by the webhook", prog.StationID, prog.Title, prog.Ft))
+	fetchNow()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(newScheduleEntry(prog, ScheduleQueued))
+}
+
+// ServeWebhooks serves the inbound webhooks on addr with the asset of current until ctx is done
+func ServeWebhooks(ctx context.Context, addr string, current func() *Asset, fetchNow func()) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           NewWebhookHandler(current, fetchNow),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
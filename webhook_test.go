@@ -0,0 +1,97 @@
+package radikron
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	t.Setenv(EnvRadicronHome, t.TempDir())
+	origTime := CurrentTime
+	CurrentTime = time.Date(2023, 6, 7, 0, 0, 0, 0, Location)
+	t.Cleanup(func() { CurrentTime = origTime })
+	origFetch := fetchWeeklyPrograms
+	fetchWeeklyPrograms = func(_ context.Context, stationID string) (Progs, error) {
+		return Progs{{ID: "1", StationID: "TBS", Title: "Show", Ft: "20230605010000", To: "20230605030000"}}, nil
+	}
+	t.Cleanup(func() { fetchWeeklyPrograms = origFetch })
+	asset := &Asset{}
+	fetches := 0
+	handler := NewWebhookHandler(func() *Asset { return asset }, func() { fetches++ })
+	request := func(path, auth, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// never served without an asset or a token
+	noAsset := NewWebhookHandler(func() *Asset { return nil }, func() { fetches++ })
+	rec := httptest.NewRecorder()
+	noAsset.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/hooks/fetch-now", http.NoBody))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the asset, got %d", rec.Code)
+	}
+	if rec := request("/hooks/fetch-now", "Bearer ", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the token set, got %d", rec.Code)
+	}
+	asset.WebhookToken = "secret"
+	if rec := request("/hooks/fetch-now", "Bearer wrong", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong token, got %d", rec.Code)
+	}
+	if rec := request("/hooks/fetch-now", "Bearer secret", "", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("expected 202, got %d", rec.Code)
+	}
+	if rec := request("/hooks/fetch-now?token=secret", "", "", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("expected 202 with the token parameter, got %d", rec.Code)
+	}
+	if fetches != 2 {
+		t.Errorf("expected 2 fetches, got %d", fetches)
+	}
+
+	asset.Rules = Rules{{Name: "show", Title: "Show"}}
+	if rec := request("/hooks/add-rule", "Bearer secret", "application/json", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without the url, got %d", rec.Code)
+	}
+	large := `{"url":"` + strings.Repeat("x", maxWebhookBodySize) + `"}`
+	if rec := request("/hooks/add-rule", "Bearer secret", "application/json", large); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for the large JSON body, got %d", rec.Code)
+	}
+	large = "url=" + strings.Repeat("x", maxWebhookBodySize)
+	if rec := request("/hooks/add-rule", "Bearer secret", "application/x-www-form-urlencoded", large); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for the large form body, got %d", rec.Code)
+	}
+	if rec := request("/hooks/add-rule", "Bearer secret", "application/json", `{"url":"TBS/20230605050000"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for no program, got %d", rec.Code)
+	}
+	form := url.Values{"url": {"https://radiko.jp/share/?sid=TBS&t=20230605010000"}}.Encode()
+	rec = request("/hooks/add-rule", "Bearer secret", "application/x-www-form-urlencoded", form)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	var entry ScheduleEntry
+	if err := json.NewDecoder(rec.Body).Decode(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Title != "Show" || entry.Rule != "show" || entry.State != ScheduleQueued {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if fetches != 3 {
+		t.Errorf("expected a fetch for the queued program, got %d", fetches)
+	}
+	if queued, err := LoadQueue(); err != nil || len(queued) != 1 {
+		t.Errorf("expected the program in the queue, got %+v, %v", queued, err)
+	}
+}
@@ -0,0 +1,170 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errPoolShutdown is returned by WorkerPool.Run after the pool is shut down
+var errPoolShutdown = errors.New("worker pool is shut down")
+
+var (
+	// defaultDownloadPool runs the segment downloads without an asset having its own pool
+	defaultDownloadPool = NewWorkerPool("download", MaxDownloadingConcurrency)
+	// defaultEncodePool runs the encodings without an asset having its own pool
+	defaultEncodePool = NewWorkerPool("encode", MaxEncodingConcurrency)
+)
+
+// WorkerPool runs the jobs with a limited concurrency, starting the queued jobs
+// in the order of their programs' timefree expiry.
+// Unlike a buffered channel, it is resized in place without disturbing the running jobs,
+// and shut down to reject the new jobs and wait for the running ones.
+type WorkerPool struct {
+	name    string
+	sem     *prioritySemaphore
+	stop    context.Context
+	cancel  context.CancelFunc
+	jobs    sync.WaitGroup
+	mu      sync.Mutex
+	closed  bool
+	running int
+}
+
+// PoolStats is a snapshot of a WorkerPool
+type PoolStats struct {
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	Running int    `json:"running"`
+	Queued  int    `json:"queued"`
+}
+
+// NewWorkerPool returns a WorkerPool running up to size jobs at once
+func NewWorkerPool(name string, size int) *WorkerPool {
+	stop, cancel := context.WithCancel(context.Background())
+	return &WorkerPool{
+		name:   name,
+		sem:    newPrioritySemaphore(size),
+		stop:   stop,
+		cancel: cancel,
+	}
+}
+
+// Run blocks until a worker is free, or ctx is done or the pool is shut down while waiting,
+// and runs job on it, returning its error
+func (p *WorkerPool) Run(ctx context.Context, expiry time.Time, job func() error) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errPoolShutdown
+	}
+	p.jobs.Add(1)
+	p.mu.Unlock()
+	defer p.jobs.Done()
+
+	// stop waiting for a worker on shutdown
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(p.stop, cancel)()
+	if err := p.sem.Acquire(waitCtx, expiry); err != nil {
+		if ctx.Err() == nil {
+			return errPoolShutdown
+		}
+		return err
+	}
+	defer p.sem.Release()
+
+	p.mu.Lock()
+	p.running++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}()
+	return job()
+}
+
+// Resize changes the number of the workers; the running jobs over the new size finish undisturbed
+func (p *WorkerPool) Resize(size int) {
+	p.sem.SetCap(size)
+}
+
+// Stats returns the number of the workers and the running and queued jobs
+func (p *WorkerPool) Stats() PoolStats {
+	p.sem.mu.Lock()
+	size, queued := p.sem.size, len(p.sem.waiters)
+	p.sem.mu.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{Name: p.name, Size: size, Running: p.running, Queued: queued}
+}
+
+// Shutdown rejects the new jobs, drops the queued ones, and waits for the running ones or ctx to be done
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// downloadPool returns the pool for the segment downloads of the asset
+func (a *Asset) downloadPool() *WorkerPool {
+	if a == nil {
+		return defaultDownloadPool
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.DownloadPool == nil {
+		return defaultDownloadPool
+	}
+	return a.DownloadPool
+}
+
+// encodePool returns the pool for the encodings of the asset
+func (a *Asset) encodePool() *WorkerPool {
+	if a == nil {
+		return defaultEncodePool
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.EncodePool == nil {
+		return defaultEncodePool
+	}
+	return a.EncodePool
+}
+
+// PoolStats returns the snapshots of the asset's worker pools
+func (a *Asset) PoolStats() []PoolStats {
+	return []PoolStats{a.downloadPool().Stats(), a.encodePool().Stats()}
+}
+
+// Shutdown shuts down the asset's worker pools, waiting for the running jobs or ctx to be done
+func (a *Asset) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	pools := []*WorkerPool{a.DownloadPool, a.EncodePool}
+	a.mu.Unlock()
+	for _, p := range pools {
+		if p == nil {
+			continue
+		}
+		if err := p.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
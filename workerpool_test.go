@@ -0,0 +1,188 @@
+package radikron
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWorkerPool_Run(t *testing.T) {
+	p := NewWorkerPool("test", 2)
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.Run(context.Background(), time.Time{}, func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&peak)
+					if n <= m || atomic.CompareAndSwapInt32(&peak, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("expected at most 2 running jobs, got %d", peak)
+	}
+
+	jobErr := errors.New("job failed")
+	if err := p.Run(context.Background(), time.Time{}, func() error { return jobErr }); !errors.Is(err, jobErr) {
+		t.Errorf("expected the job error, got %v", err)
+	}
+}
+
+func TestWorkerPool_StatsAndResize(t *testing.T) {
+	p := NewWorkerPool("test", 1)
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = p.Run(context.Background(), time.Time{}, func() error {
+				<-release
+				return nil
+			})
+		}()
+	}
+	waitFor(t, func() bool {
+		s := p.Stats()
+		return s.Running == 1 && s.Queued == 2
+	})
+	if s := p.Stats(); s.Name != "test" || s.Size != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// growing starts the queued jobs
+	p.Resize(3)
+	waitFor(t, func() bool {
+		s := p.Stats()
+		return s.Running == 3 && s.Queued == 0
+	})
+	close(release)
+	wg.Wait()
+	if s := p.Stats(); s.Size != 3 || s.Running != 0 {
+		t.Errorf("unexpected stats after the jobs: %+v", s)
+	}
+}
+
+func TestWorkerPool_Shutdown(t *testing.T) {
+	p := NewWorkerPool("test", 1)
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		_ = p.Run(context.Background(), time.Time{}, func() error {
+			<-release
+			return nil
+		})
+		close(finished)
+	}()
+	waitFor(t, func() bool { return p.Stats().Running == 1 })
+
+	queued := make(chan error, 1)
+	go func() {
+		queued <- p.Run(context.Background(), time.Time{}, func() error {
+			t.Error("the queued job should not run after shutdown")
+			return nil
+		})
+	}()
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+
+	// the running job outlives the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline exceeded, got %v", err)
+	}
+	if err := <-queued; !errors.Is(err, errPoolShutdown) {
+		t.Errorf("expected the queued job to be dropped, got %v", err)
+	}
+	if err := p.Run(context.Background(), time.Time{}, func() error { return nil }); !errors.Is(err, errPoolShutdown) {
+		t.Errorf("expected a new job to be rejected, got %v", err)
+	}
+
+	close(release)
+	<-finished
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected the shutdown to complete, got %v", err)
+	}
+}
+
+func TestWorkerPool_RunCanceled(t *testing.T) {
+	p := NewWorkerPool("test", 1)
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		_ = p.Run(context.Background(), time.Time{}, func() error {
+			<-release
+			return nil
+		})
+	}()
+	waitFor(t, func() bool { return p.Stats().Running == 1 })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Run(ctx, time.Time{}, func() error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestAsset_Pools(t *testing.T) {
+	t.Cleanup(func() { InitSemaphores(&Asset{}) })
+
+	var nilAsset *Asset
+	if nilAsset.downloadPool() != defaultDownloadPool || nilAsset.encodePool() != defaultEncodePool {
+		t.Error("expected the default pools without an asset")
+	}
+
+	asset := &Asset{MaxDownloadingConcurrency: 3, MaxEncodingConcurrency: 2}
+	InitSemaphores(asset)
+	pools := asset.PoolStats()
+	if len(pools) != 2 {
+		t.Fatalf("expected 2 pools, got %d", len(pools))
+	}
+	if pools[0].Name != "download" || pools[0].Size != 3 {
+		t.Errorf("unexpected download pool: %+v", pools[0])
+	}
+	if pools[1].Name != "encode" || pools[1].Size != 2 {
+		t.Errorf("unexpected encode pool: %+v", pools[1])
+	}
+
+	// resized in place
+	pool := asset.DownloadPool
+	asset.MaxDownloadingConcurrency = 5
+	InitSemaphores(asset)
+	if asset.DownloadPool != pool || pool.Stats().Size != 5 {
+		t.Errorf("expected the pool resized to 5, got %+v", asset.DownloadPool.Stats())
+	}
+
+	if err := asset.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+}
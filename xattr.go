@@ -0,0 +1,41 @@
+package radikron
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+const (
+	// XattrProgramID is the extended attribute for the program ID
+	XattrProgramID = "user.radikron.program-id"
+	// XattrRule is the extended attribute for the matched rule name
+	XattrRule = "user.radikron.rule"
+	// XattrStationID is the extended attribute for the station ID
+	XattrStationID = "user.radikron.station-id"
+)
+
+// errXattrUnsupported is returned when the platform does not support extended attributes
+var errXattrUnsupported = errors.New("extended attributes are not supported on this platform")
+
+// writeXattrs writes the program metadata to the extended attributes of the output file
+func writeXattrs(output *radigo.OutputConfig, prog *Prog) error {
+	attrs := []struct {
+		name  string
+		value string
+	}{
+		{XattrProgramID, prog.ID},
+		{XattrRule, prog.RuleName},
+		{XattrStationID, prog.StationID},
+	}
+	for _, attr := range attrs {
+		if attr.value == "" {
+			continue
+		}
+		if err := setXattr(output.AbsPath(), attr.name, attr.value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", attr.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+
+package radikron
+
+// setXattr is not supported on this platform
+func setXattr(_, _, _ string) error {
+	return errXattrUnsupported
+}
+
+// getXattr is not supported on this platform
+func getXattr(_, _ string) (string, error) {
+	return "", errXattrUnsupported
+}
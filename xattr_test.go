@@ -0,0 +1,67 @@
+package radikron
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/yyoshiki41/radigo"
+)
+
+func TestWriteXattrs(t *testing.T) {
+	tmpDir := t.TempDir()
+	output := newOutputConfigFromPath(tmpDir, "test-xattr", radigo.AudioFormatAAC)
+	if err := os.WriteFile(output.AbsPath(), []byte("test content"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	prog := &Prog{
+		ID:        "12345",
+		StationID: "FMT",
+		RuleName:  "test-rule",
+	}
+	err := writeXattrs(output, prog)
+	if errors.Is(err, errXattrUnsupported) {
+		t.Skip("extended attributes are not supported on this platform")
+	}
+	if err != nil {
+		// e.g., tmpfs without user xattr support
+		t.Skipf("extended attributes are not supported on this filesystem: %v", err)
+	}
+
+	tests := map[string]string{
+		XattrProgramID: prog.ID,
+		XattrRule:      prog.RuleName,
+		XattrStationID: prog.StationID,
+	}
+	for name, want := range tests {
+		got, err := getXattr(output.AbsPath(), name)
+		if err != nil {
+			t.Errorf("getXattr(%s) failed: %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s => %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestWriteXattrs_SkipsEmptyValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	output := newOutputConfigFromPath(tmpDir, "test-xattr-empty", radigo.AudioFormatAAC)
+	if err := os.WriteFile(output.AbsPath(), []byte("test content"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// no metadata to write, so no xattr syscall should be made
+	if err := writeXattrs(output, &Prog{}); err != nil {
+		t.Errorf("writeXattrs with empty values should not fail: %v", err)
+	}
+}
+
+func TestWriteXattrs_NonexistentFile(t *testing.T) {
+	output := newOutputConfigFromPath(t.TempDir(), "nonexistent", radigo.AudioFormatAAC)
+	if err := writeXattrs(output, &Prog{ID: "12345"}); err == nil {
+		t.Error("writeXattrs should fail for a nonexistent file")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package radikron
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// setXattr sets the extended attribute on the file
+func setXattr(path, name, value string) error {
+	return unix.Setxattr(path, name, []byte(value), 0)
+}
+
+// getXattr returns the extended attribute of the file
+func getXattr(path, name string) (string, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Getxattr(path, name, buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:size]), nil
+}